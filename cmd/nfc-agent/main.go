@@ -15,10 +15,13 @@ import (
 	"github.com/SimplyPrint/nfc-agent/internal/api"
 	"github.com/SimplyPrint/nfc-agent/internal/certs"
 	"github.com/SimplyPrint/nfc-agent/internal/config"
+	"github.com/SimplyPrint/nfc-agent/internal/core"
 	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/SimplyPrint/nfc-agent/internal/mqtt"
 	"github.com/SimplyPrint/nfc-agent/internal/service"
 	"github.com/SimplyPrint/nfc-agent/internal/settings"
 	"github.com/SimplyPrint/nfc-agent/internal/tray"
+	"github.com/SimplyPrint/nfc-agent/internal/web"
 	"github.com/SimplyPrint/nfc-agent/internal/welcome"
 )
 
@@ -42,6 +45,23 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nEnvironment variables:\n")
 		fmt.Fprintf(os.Stderr, "  NFC_AGENT_PORT  Port to listen on (default: 32145)\n")
 		fmt.Fprintf(os.Stderr, "  NFC_AGENT_HOST  Host to bind to (default: 127.0.0.1)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_UI_PORT  Serve the web UI on this port instead of the API port (default: same port)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_UI_HOST  Host to bind the separate web UI port to (default: 127.0.0.1)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MAX_NDEF_RECORDS  Max records per multi-record write (default: 16)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MAX_NDEF_PAYLOAD  Max total payload bytes per multi-record write (default: 8192)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_SLOW_OP_MS  Warn when a card operation exceeds this many ms (default: 2000)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MAX_READ_PAGES  Max pages/blocks read per NDEF read (default: card capacity)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_CARD_SETTLE_MS  Wait before retrying a failed first read of a newly placed card, 0 disables (default: 150)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MAX_CONCURRENT_OPS  Max card operations running at once (default: 4)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MAX_QUEUED_OPS  Max operations waiting for a slot before 503 (default: 16)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MQTT_URL  Publish card events to this MQTT broker, e.g. mqtt://host:1883 (default: disabled)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_MQTT_TOPIC_PREFIX  Topic prefix for card events (default: nfc-agent)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_IDLE_SHUTDOWN_SEC  Exit after this many seconds without clients or activity (default: disabled)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_SELF_CHECK  Check for a working reader at startup and every 30s (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_EXCLUSIVE_READER  Connect to cards exclusively so other apps cannot interfere (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_ALLOW_RAW_APDU  Enable the raw APDU script endpoint (default: false)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_CORS_MAX_AGE  Seconds browsers cache CORS preflight responses (default: 7200)\n")
+		fmt.Fprintf(os.Stderr, "  NFC_AGENT_CORS_ORIGINS  Comma-separated origins allowed to call the API (default: any)\n")
 	}
 
 	flag.Parse()
@@ -126,6 +146,9 @@ func run(cfg *config.Config, headless bool) {
 
 	// Load user settings
 	userSettings, _ := settings.Load()
+	logging.SetScrubCrashReports(!userSettings.DisableCrashScrubbing)
+	logging.SetScrubSensitive(userSettings.ScrubSensitive)
+	core.UltralightPassword = settings.UltralightPassword
 
 	// Initialize Sentry for crash reporting (opt-in via settings or NFC_AGENT_SENTRY=1)
 	if logging.InitSentry(api.Version, userSettings.CrashReporting) {
@@ -136,6 +159,46 @@ func run(cfg *config.Config, headless bool) {
 		"version": api.Version,
 	})
 
+	// Apply card operation limits from the environment
+	core.SetNDEFLimits(cfg.MaxNDEFRecords, cfg.MaxNDEFPayloadBytes)
+	if cfg.SlowOperationThreshold > 0 {
+		core.SlowOperationThreshold = cfg.SlowOperationThreshold
+	}
+	core.MaxNDEFReadPages = cfg.MaxReadPages
+	core.PageWriteDelay = cfg.PageWriteDelay
+	core.WriteSettleDelay = cfg.WriteSettleDelay
+	core.CardSettleDelay = cfg.CardSettleDelay
+	if cfg.MaxConcurrentOperations > 0 {
+		core.MaxConcurrentOperations = cfg.MaxConcurrentOperations
+	}
+	if cfg.MaxQueuedOperations > 0 {
+		core.MaxQueuedOperations = cfg.MaxQueuedOperations
+	}
+	if cfg.ExclusiveReader {
+		core.ExclusiveReader = true
+		// Another application holding a reader is not fatal: each operation retries
+		core.CheckExclusiveAccess()
+	}
+	api.AllowRawAPDU = cfg.AllowRawAPDU
+	if cfg.CORSMaxAge > 0 {
+		api.CORSMaxAge = cfg.CORSMaxAge
+	}
+	api.CORSAllowedOrigins = cfg.CORSAllowedOrigins
+
+	// Serve a custom web UI if configured, falling back to the embedded one
+	if cfg.WebRootOverride != "" {
+		if info, err := os.Stat(cfg.WebRootOverride); err != nil || !info.IsDir() {
+			logging.Warn(logging.CatSystem, "Web root override is not a directory, serving the embedded UI", map[string]any{
+				"path": cfg.WebRootOverride,
+			})
+		} else {
+			web.RootOverride = cfg.WebRootOverride
+			logging.Info(logging.CatSystem, "Serving web UI override", map[string]any{
+				"path": cfg.WebRootOverride,
+			})
+		}
+	}
+
 	// Initialize update checker
 	api.InitUpdateChecker()
 
@@ -145,10 +208,40 @@ func run(cfg *config.Config, headless bool) {
 		os.Exit(0)
 	})
 
-	mux := api.NewMux()
+	// Publish card events to MQTT if configured
+	if cfg.MQTTURL != "" {
+		publisher, err := mqtt.NewPublisher(mqtt.Options{
+			URL:      cfg.MQTTURL,
+			ClientID: mqtt.DefaultClientID(),
+			Username: cfg.MQTTUsername,
+			Password: cfg.MQTTPassword,
+		})
+		if err != nil {
+			logging.Error(logging.CatSystem, "MQTT disabled: invalid configuration", map[string]any{
+				"error": err.Error(),
+			})
+		} else {
+			api.StartMQTTBridge(publisher, cfg.MQTTTopicPrefix)
+			logging.Info(logging.CatSystem, "Publishing card events to MQTT", map[string]any{
+				"topicPrefix": cfg.MQTTTopicPrefix,
+			})
+		}
+	}
+
+	// With a separate UI port the API port serves no web UI. The UI port
+	// serves the API too, since the UI calls it on its own origin.
+	wsHandler := api.InitWebSocket()
+	var mux, uiMux *http.ServeMux
+	if cfg.UIPort != 0 {
+		mux = api.NewAPIMux()
+		uiMux = api.NewMux()
+		uiMux.HandleFunc("/v1/ws", wsHandler)
+	} else {
+		mux = api.NewMux()
+	}
 
 	// Add WebSocket endpoint
-	mux.HandleFunc("/v1/ws", api.InitWebSocket())
+	mux.HandleFunc("/v1/ws", wsHandler)
 
 	addr := cfg.Address()
 
@@ -181,6 +274,37 @@ func run(cfg *config.Config, headless bool) {
 			log.Fatalf("failed to listen: %v", err)
 		}
 
+		if uiMux != nil {
+			uiAddr := cfg.UIAddress()
+			uiLn, err := net.Listen("tcp", uiAddr)
+			if err != nil {
+				log.Fatalf("failed to listen for web UI: %v", err)
+			}
+			log.Printf("Web UI available at http://%s\n", uiAddr)
+			logging.Info(logging.CatSystem, "Web UI server started", map[string]any{
+				"address": uiAddr,
+			})
+			go func() {
+				uiServer := &http.Server{Handler: uiMux}
+				if err := uiServer.Serve(uiLn); err != nil {
+					log.Fatalf("web UI server error: %v", err)
+				}
+			}()
+		}
+
+		// Warn early about a missing or broken reader on headless units
+		if cfg.SelfCheck {
+			core.StartSelfCheck()
+		}
+
+		// Exit when nothing has used the agent for a while
+		if cfg.IdleShutdown > 0 {
+			api.StartIdleShutdown(cfg.IdleShutdown)
+			logging.Info(logging.CatSystem, "Idle shutdown enabled", map[string]any{
+				"idleSeconds": int(cfg.IdleShutdown.Seconds()),
+			})
+		}
+
 		// If TLS is configured, wrap with mux listener for HTTP/HTTPS on same port
 		var listener net.Listener = ln
 		if tlsConfig != nil {
@@ -203,7 +327,12 @@ func run(cfg *config.Config, headless bool) {
 		// Create tray app with quit handler
 		// Pass isFirstRun so welcome prompts can be shown after tray initialization
 		// (avoids race condition with Cocoa event loop on macOS)
-		trayApp := tray.New(addr, welcome.IsFirstRun(), func() {
+		// "Open status page" goes to the web UI, wherever it is served
+		trayAddr := addr
+		if uiMux != nil {
+			trayAddr = cfg.UIAddress()
+		}
+		trayApp := tray.New(trayAddr, welcome.IsFirstRun(), func() {
 			log.Println("Shutting down...")
 			os.Exit(0)
 		})
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+)
+
+// apduDebugMode is the debug value that returns the APDU exchanges and log
+// entries of a single request in its debugLog.
+const apduDebugMode = "apdu"
+
+// checkAPDUDebug validates a debug field of requests that only support the
+// APDU trace.
+func checkAPDUDebug(debug string) error {
+	if debug != "" && debug != apduDebugMode {
+		return fmt.Errorf("debug must be %q", apduDebugMode)
+	}
+	return nil
+}
+
+// newAPDUDebug returns a trace to pass in the request's read or write options
+// if debug is "apdu", and nil otherwise.
+func newAPDUDebug(debug string) *core.APDUTrace {
+	if debug != apduDebugMode {
+		return nil
+	}
+	return core.NewAPDUTrace()
+}
+
+// apduDebugLog returns the lines trace recorded; nil for no trace.
+func apduDebugLog(trace *core.APDUTrace) []string {
+	if trace == nil {
+		return nil
+	}
+	return trace.Lines()
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// auditedRoutes maps the reader sub-endpoints that change a card to their
+// audit operation name, by method. Mutating requests to sub-endpoints not
+// listed here (e.g. claim, derive-key) are not audited.
+var auditedRoutes = map[string]map[string]string{
+	"card":            {http.MethodPost: "write_card"},
+	"erase":           {http.MethodPost: "erase_card", http.MethodDelete: "erase_card"},
+	"lock":            {http.MethodPost: "lock_card"},
+	"password":        {http.MethodPost: "set_password", http.MethodDelete: "remove_password"},
+	"records":         {http.MethodPost: "write_records"},
+	"mifare":          {http.MethodPost: "write_mifare_block"},
+	"ultralight":      {http.MethodPost: "write_ultralight_page"},
+	"counter":         {http.MethodPost: "increment_counter"},
+	"ntag":            {http.MethodPost: "write_mirror_url"},
+	"test-write":      {http.MethodPost: "test_write"},
+	"kv":              {http.MethodPost: "write_key_values"},
+	"app-data":        {http.MethodPost: "write_app_data"},
+	"provision-spool": {http.MethodPost: "provision_spool"},
+	"openprinttag":    {http.MethodPatch: "patch_openprinttag_aux"},
+	"script":          {http.MethodPost: "apdu_script"},
+}
+
+// auditedSubRoutes names the operations of POST sub-endpoints that differ
+// from their parent route.
+var auditedSubRoutes = map[string]string{
+	"lock/pages":            "lock_pages",
+	"mifare/batch":          "write_mifare_blocks",
+	"mifare/aes-write":      "aes_encrypt_and_write_block",
+	"mifare/sector-trailer": "write_mifare_sector_trailer",
+	"mifare/derive-key":     "",
+	"ultralight/batch":      "write_ultralight_pages",
+}
+
+// auditedWSMessages lists the WebSocket messages that change a card. Each is
+// audited when its response or error is sent. batch_write_session and
+// erase_session audit every tag they change instead.
+var auditedWSMessages = map[string]bool{
+	"write_card":                  true,
+	"erase_card":                  true,
+	"lock_card":                   true,
+	"set_password":                true,
+	"remove_password":             true,
+	"write_records":               true,
+	"write_raw_ndef":              true,
+	"write_mifare_block":          true,
+	"write_mifare_blocks":         true,
+	"write_ultralight_page":       true,
+	"write_ultralight_pages":      true,
+	"increment_counter":           true,
+	"test_write":                  true,
+	"aes_encrypt_and_write_block": true,
+	"write_mifare_sector_trailer": true,
+}
+
+// httpAuditOperation returns the audit operation for a reader request, or ""
+// if the request doesn't change the card. parts is the split request path.
+func httpAuditOperation(method string, parts []string) string {
+	if len(parts) < 4 {
+		return ""
+	}
+	op := auditedRoutes[parts[3]][method]
+	if op == "" || len(parts) < 5 {
+		return op
+	}
+	if sub, ok := auditedSubRoutes[parts[3]+"/"+parts[4]]; ok && method == http.MethodPost {
+		return sub
+	}
+	return op
+}
+
+// requestAuthenticated reports whether r carries the local API token.
+func requestAuthenticated(r *http.Request) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		return false
+	}
+	token, err := apiToken()
+	return err == nil && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// auditResult returns the result and error fields of an audit entry.
+func auditResult(err error) (string, string) {
+	if err != nil {
+		return "failure", err.Error()
+	}
+	return "success", ""
+}
+
+// auditWriter captures the status and error message of a response for the
+// audit log.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status >= 400 && w.body.Len() < 4096 {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// maxAuditBodyBytes caps how much of a request body auditHTTP keeps to find
+// its expectUID; the UID of a larger body is not recorded.
+const maxAuditBodyBytes = 64 << 10
+
+// cappedBuffer keeps the first maxAuditBodyBytes written to it and discards
+// the rest.
+type cappedBuffer struct {
+	buf bytes.Buffer
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := maxAuditBodyBytes - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// Bytes returns the bytes kept.
+func (b *cappedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// auditHTTP wraps w to audit the reader request once the handler returns,
+// and r's body to find the card UID the request expects. Call the returned
+// function when the request is done.
+func auditHTTP(w http.ResponseWriter, r *http.Request, readerName, operation string) (http.ResponseWriter, func()) {
+	aw := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+	var reqBody cappedBuffer
+	if r.Body != nil {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, &reqBody), r.Body}
+	}
+
+	return aw, func() {
+		entry := logging.AuditEntry{
+			Client:        r.RemoteAddr,
+			Authenticated: requestAuthenticated(r),
+			Reader:        readerName,
+			Operation:     operation,
+			UID:           requestExpectUID(r, reqBody.Bytes()),
+			Result:        "success",
+		}
+		if aw.status >= 400 {
+			entry.Result = "failure"
+			var body struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(aw.body.Bytes(), &body) == nil && body.Error != "" {
+				entry.Error = body.Error
+			} else {
+				entry.Error = strings.TrimSpace(aw.body.String())
+			}
+		}
+		logging.Audit(entry)
+	}
+}
+
+// requestExpectUID returns the expectUID of a request, given as a query
+// parameter, form field or JSON body field.
+func requestExpectUID(r *http.Request, body []byte) string {
+	if uid := r.URL.Query().Get("expectUID"); uid != "" {
+		return uid
+	}
+	if uid := r.PostForm.Get("expectUID"); uid != "" {
+		return uid
+	}
+	var req struct {
+		ExpectUID string `json:"expectUID"`
+	}
+	json.Unmarshal(body, &req)
+	return req.ExpectUID
+}
+
+// startAudit records a card-changing WebSocket request, to be audited when
+// its response is sent.
+func (c *WSClient) startAudit(msg WSMessage) {
+	var req struct {
+		ReaderIndex int    `json:"readerIndex"`
+		ExpectUID   string `json:"expectUID"`
+	}
+	json.Unmarshal(msg.Payload, &req)
+	entry := logging.AuditEntry{
+		Client:        c.remoteAddr,
+		Authenticated: c.authenticated,
+		Operation:     msg.Type,
+		UID:           req.ExpectUID,
+	}
+	if readers := core.ListReaders(); req.ReaderIndex >= 0 && req.ReaderIndex < len(readers) {
+		entry.Reader = readers[req.ReaderIndex].Name
+	}
+
+	c.mu.Lock()
+	if c.audits == nil {
+		c.audits = make(map[string]logging.AuditEntry)
+	}
+	c.audits[msg.ID] = entry
+	c.mu.Unlock()
+}
+
+// finishAudit audits the pending request with the given ID, if any, by the
+// type of its response.
+func (c *WSClient) finishAudit(id, msgType, errMsg string) {
+	c.mu.Lock()
+	entry, ok := c.audits[id]
+	delete(c.audits, id)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	switch msgType {
+	case "error":
+		entry.Result, entry.Error = "failure", errMsg
+	case "canceled":
+		entry.Result = "canceled"
+	default:
+		entry.Result = "success"
+	}
+	logging.Audit(entry)
+}
+
+// auditTag audits one tag changed by a batch write or erase session.
+func (c *WSClient) auditTag(operation, readerName, uid string, err error) {
+	entry := logging.AuditEntry{
+		Client:        c.remoteAddr,
+		Authenticated: c.authenticated,
+		Reader:        readerName,
+		Operation:     operation,
+		UID:           uid,
+	}
+	entry.Result, entry.Error = auditResult(err)
+	logging.Audit(entry)
+}
+
+// handleAudit returns the audit log of card-changing operations
+// GET /v1/audit?limit=&reader=&client=
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAPIToken(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 100
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = min(l, logging.MaxAuditEntries)
+	}
+	reader, client := query.Get("reader"), query.Get("client")
+
+	entries := logging.AuditEntries(limit, func(e logging.AuditEntry) bool {
+		return (reader == "" || e.Reader == reader) && (client == "" || strings.HasPrefix(e.Client, client))
+	})
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"entries":    entries,
+		"maxEntries": logging.MaxAuditEntries,
+	})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errReaderClaimed is returned when another client holds a claim on the reader.
+var errReaderClaimed = errors.New("reader is claimed by another client")
+
+// Claim lease bounds.
+const (
+	defaultClaimLease = 30 * time.Second
+	maxClaimLease     = 10 * time.Minute
+)
+
+// ReaderClaim describes an active exclusive claim on a reader.
+type ReaderClaim struct {
+	Reader    string    `json:"reader"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	LeaseMs   int64     `json:"leaseMs"`
+	owner     string
+}
+
+// claimRegistry tracks which client, if any, has exclusive use of each reader.
+// Expired claims are dropped lazily on the next lookup.
+type claimRegistry struct {
+	mu     sync.Mutex
+	claims map[string]*ReaderClaim
+	now    func() time.Time // Overridden in tests
+}
+
+func newClaimRegistry() *claimRegistry {
+	return &claimRegistry{
+		claims: make(map[string]*ReaderClaim),
+		now:    time.Now,
+	}
+}
+
+var readerClaims = newClaimRegistry()
+
+// claimLease converts a requested lease in milliseconds to a duration,
+// applying the default for zero and rejecting values outside the bounds.
+func claimLease(leaseMs int) (time.Duration, error) {
+	if leaseMs == 0 {
+		return defaultClaimLease, nil
+	}
+	lease := time.Duration(leaseMs) * time.Millisecond
+	if lease < time.Second || lease > maxClaimLease {
+		return 0, fmt.Errorf("leaseMs must be between 1000 and %d", maxClaimLease.Milliseconds())
+	}
+	return lease, nil
+}
+
+// activeLocked returns the unexpired claim on reader, if any.
+func (r *claimRegistry) activeLocked(reader string) *ReaderClaim {
+	claim := r.claims[reader]
+	if claim != nil && !r.now().Before(claim.ExpiresAt) {
+		delete(r.claims, reader)
+		return nil
+	}
+	return claim
+}
+
+// claim gives owner exclusive use of reader for lease. The owner may renew its
+// own claim; a claim held by anyone else fails with errReaderClaimed.
+func (r *claimRegistry) claim(reader, owner string, lease time.Duration) (ReaderClaim, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing := r.activeLocked(reader); existing != nil && existing.owner != owner {
+		return ReaderClaim{}, errReaderClaimed
+	}
+
+	claim := &ReaderClaim{
+		Reader:    reader,
+		ExpiresAt: r.now().Add(lease),
+		LeaseMs:   lease.Milliseconds(),
+		owner:     owner,
+	}
+	r.claims[reader] = claim
+	return *claim, nil
+}
+
+// release drops owner's claim on reader. It reports false if owner held no
+// active claim on it.
+func (r *claimRegistry) release(reader, owner string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing := r.activeLocked(reader); existing == nil || existing.owner != owner {
+		return false
+	}
+	delete(r.claims, reader)
+	return true
+}
+
+// releaseAll drops every claim held by owner, e.g. when a client disconnects.
+func (r *claimRegistry) releaseAll(owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for reader, claim := range r.claims {
+		if claim.owner == owner {
+			delete(r.claims, reader)
+		}
+	}
+}
+
+// check returns errReaderClaimed if reader is claimed by someone other than owner.
+func (r *claimRegistry) check(reader, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing := r.activeLocked(reader); existing != nil && existing.owner != owner {
+		return fmt.Errorf("%w (%s)", errReaderClaimed, reader)
+	}
+	return nil
+}
+
+// newClaimToken returns a random token identifying an HTTP claim holder.
+func newClaimToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
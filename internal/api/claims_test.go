@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimRegistry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := newClaimRegistry()
+	r.now = func() time.Time { return now }
+
+	if _, err := r.claim("Reader", "a", time.Minute); err != nil {
+		t.Fatalf("claim failed: %v", err)
+	}
+	if err := r.check("Reader", "a"); err != nil {
+		t.Errorf("owner should pass check, got %v", err)
+	}
+	if err := r.check("Reader", "b"); !errors.Is(err, errReaderClaimed) {
+		t.Errorf("expected errReaderClaimed for other client, got %v", err)
+	}
+	if err := r.check("Other Reader", "b"); err != nil {
+		t.Errorf("unclaimed reader should pass check, got %v", err)
+	}
+	if _, err := r.claim("Reader", "b", time.Minute); !errors.Is(err, errReaderClaimed) {
+		t.Errorf("expected errReaderClaimed when claiming a held reader, got %v", err)
+	}
+	if r.release("Reader", "b") {
+		t.Error("release by non-owner should fail")
+	}
+
+	// Owner renews, then the lease runs out
+	now = now.Add(30 * time.Second)
+	claim, err := r.claim("Reader", "a", time.Minute)
+	if err != nil {
+		t.Fatalf("renew failed: %v", err)
+	}
+	if want := now.Add(time.Minute); !claim.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", claim.ExpiresAt, want)
+	}
+	now = now.Add(time.Minute)
+	if err := r.check("Reader", "b"); err != nil {
+		t.Errorf("expired claim should not block, got %v", err)
+	}
+	if _, err := r.claim("Reader", "b", time.Minute); err != nil {
+		t.Errorf("claim after expiry failed: %v", err)
+	}
+	if !r.release("Reader", "b") {
+		t.Error("release by owner should succeed")
+	}
+}
+
+func TestClaimRegistry_ReleaseAll(t *testing.T) {
+	r := newClaimRegistry()
+	r.claim("Reader 1", "a", time.Minute)
+	r.claim("Reader 2", "a", time.Minute)
+	r.claim("Reader 3", "b", time.Minute)
+
+	r.releaseAll("a")
+
+	if err := r.check("Reader 1", "c"); err != nil {
+		t.Errorf("Reader 1 should be released, got %v", err)
+	}
+	if err := r.check("Reader 2", "c"); err != nil {
+		t.Errorf("Reader 2 should be released, got %v", err)
+	}
+	if err := r.check("Reader 3", "c"); !errors.Is(err, errReaderClaimed) {
+		t.Errorf("Reader 3 should still be claimed, got %v", err)
+	}
+}
+
+func TestClaimLease(t *testing.T) {
+	tests := []struct {
+		leaseMs int
+		want    time.Duration
+		wantErr bool
+	}{
+		{0, defaultClaimLease, false},
+		{5000, 5 * time.Second, false},
+		{500, 0, true},
+		{-1, 0, true},
+		{int(maxClaimLease.Milliseconds()) + 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := claimLease(tt.leaseMs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("claimLease(%d) error = %v, wantErr %v", tt.leaseMs, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("claimLease(%d) = %v, want %v", tt.leaseMs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// supportedCardFormats lists the values accepted for the "format" parameter.
+var supportedCardFormats = map[string]bool{
+	"":             true,
+	"opt-sections": true,
+	"nfctools":     true,
+	"records-json": true,
+	"hass":         true,
+}
+
+// supportedReadPreferences lists the values accepted for the "prefer" parameter.
+var supportedReadPreferences = map[string]bool{
+	"":             true,
+	"openprinttag": true,
+	"url":          true,
+	"text":         true,
+}
+
+// formatCard converts a card read into the representation requested via the
+// "format" query/payload parameter. An empty format returns the card as-is.
+func formatCard(card *core.Card, format string) (interface{}, error) {
+	switch format {
+	case "":
+		return card, nil
+	case "opt-sections":
+		return openPrintTagSections(card)
+	case "nfctools":
+		return nfcToolsCard(card), nil
+	case "records-json":
+		return recordsJSONCard(card), nil
+	case "hass":
+		return hassTagCard(card), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// openPrintTagSections decodes the first OpenPrintTag record on the card into
+// its meta/main/aux sections.
+func openPrintTagSections(card *core.Card) (interface{}, error) {
+	for _, rec := range card.Records {
+		mediaType := core.MediaType(rec.Type)
+		if rec.TNF != 0x02 || (mediaType != openprinttag.MIMEType && mediaType != "application/cbor") {
+			continue
+		}
+		opt, err := openprinttag.Decode(rec.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode OpenPrintTag record: %w", err)
+		}
+		return opt.ToSections(), nil
+	}
+	return nil, fmt.Errorf("no OpenPrintTag record found on card")
+}
+
+// NFCToolsTag is a card in the JSON shape exported by the NFC Tools app.
+type NFCToolsTag struct {
+	TagType      string           `json:"tagType,omitempty"`
+	TechList     []string         `json:"techList"`
+	SerialNumber string           `json:"serialNumber"`   // UID as colon-separated uppercase hex
+	ATQA         string           `json:"atqa,omitempty"` // e.g. "0x0044"
+	SAK          string           `json:"sak,omitempty"`  // e.g. "0x00"
+	Size         int              `json:"size,omitempty"` // Memory size in bytes
+	Writable     bool             `json:"writable"`
+	Records      []NFCToolsRecord `json:"records"`
+}
+
+// NFCToolsRecord is an NDEF record in the NFC Tools export shape.
+type NFCToolsRecord struct {
+	TNF     byte   `json:"tnf"`
+	Type    string `json:"type"`
+	Payload string `json:"payload"`           // Raw payload as uppercase hex
+	Content string `json:"content,omitempty"` // Decoded payload (text, URL, JSON, ...)
+}
+
+// nfcToolsCard maps a card read onto the NFC Tools export schema. It only
+// reshapes existing Card fields so it follows detection improvements.
+func nfcToolsCard(card *core.Card) *NFCToolsTag {
+	tag := &NFCToolsTag{
+		TagType:      card.Type,
+		TechList:     nfcToolsTechList(card),
+		SerialNumber: colonHex(card.UID),
+		Size:         card.Size,
+		Writable:     card.Writable,
+		Records:      make([]NFCToolsRecord, 0, len(card.Records)),
+	}
+	if card.ATQA != "" {
+		tag.ATQA = "0x" + strings.ToUpper(card.ATQA)
+	}
+	if card.SAK != "" {
+		tag.SAK = "0x" + strings.ToUpper(card.SAK)
+	}
+	for _, rec := range card.Records {
+		tag.Records = append(tag.Records, NFCToolsRecord{
+			TNF:     rec.TNF,
+			Type:    rec.Type,
+			Payload: strings.ToUpper(hex.EncodeToString(rec.Payload)),
+			Content: rec.Data,
+		})
+	}
+	return tag
+}
+
+// nfcToolsTechList derives the Android-style technology list NFC Tools reports
+// from the detected protocol and card type.
+func nfcToolsTechList(card *core.Card) []string {
+	techs := []string{}
+	switch card.Protocol {
+	case "NFC-A":
+		techs = append(techs, "android.nfc.tech.NfcA")
+	case "NFC-V":
+		techs = append(techs, "android.nfc.tech.NfcV")
+	}
+	switch {
+	case strings.HasPrefix(card.Type, "NTAG"), strings.HasPrefix(card.Type, "MIFARE Ultralight"):
+		techs = append(techs, "android.nfc.tech.MifareUltralight")
+	case card.Type == "MIFARE Classic":
+		techs = append(techs, "android.nfc.tech.MifareClassic")
+	}
+	if len(card.Records) > 0 {
+		techs = append(techs, "android.nfc.tech.Ndef")
+	}
+	return techs
+}
+
+// HassTag is a card in the shape of Home Assistant's tag_scanned event data,
+// ready to forward to its tag scan API or a webhook.
+type HassTag struct {
+	TagID string `json:"tag_id"` // Card UID, as in the regular read and MQTT events
+}
+
+// hassTagCard maps a card read onto a Home Assistant tag scan.
+func hassTagCard(card *core.Card) *HassTag {
+	return &HassTag{TagID: card.UID}
+}
+
+// colonHex formats a hex string as colon-separated uppercase byte pairs.
+func colonHex(s string) string {
+	s = strings.ToUpper(s)
+	pairs := make([]string, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		pairs = append(pairs, s[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
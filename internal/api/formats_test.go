@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+func TestFormatCard_Default(t *testing.T) {
+	card := &core.Card{UID: "04aabbcc"}
+
+	result, err := formatCard(card, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != card {
+		t.Error("expected card to be returned unchanged")
+	}
+}
+
+func TestFormatCard_Unsupported(t *testing.T) {
+	if _, err := formatCard(&core.Card{}, "xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+	if supportedCardFormats["xml"] {
+		t.Error("xml should not be a supported format")
+	}
+}
+
+func TestFormatCard_OptSections(t *testing.T) {
+	input := &openprinttag.Input{
+		MaterialName:  "PLA",
+		BrandName:     "TestBrand",
+		MaterialType:  0,
+		NominalWeight: 1000,
+	}
+	payload, err := input.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	card := &core.Card{
+		UID: "04aabbcc",
+		Records: []core.ParsedRecord{
+			{TNF: 0x01, Type: "U", Data: "https://example.com", DataType: "url"},
+			{TNF: 0x02, Type: openprinttag.MIMEType, Payload: payload},
+		},
+	}
+
+	result, err := formatCard(card, "opt-sections")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sections, ok := result.(*openprinttag.Sections)
+	if !ok {
+		t.Fatalf("expected *openprinttag.Sections, got %T", result)
+	}
+	if sections.Main["materialName"] != "PLA" {
+		t.Errorf("expected materialName PLA, got %v", sections.Main["materialName"])
+	}
+	if _, ok := sections.Aux["consumedWeight"]; !ok {
+		t.Error("expected zero-valued aux keys to be present")
+	}
+
+	// No OpenPrintTag record
+	if _, err := formatCard(&core.Card{UID: "04aabbcc"}, "opt-sections"); err == nil {
+		t.Error("expected error when card has no OpenPrintTag record")
+	}
+}
+
+func TestFormatCard_Hass(t *testing.T) {
+	card := &core.Card{UID: "04a1b2c3d4e5f6", Type: "NTAG215"}
+
+	result, err := formatCard(card, "hass")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if want := `{"tag_id":"04a1b2c3d4e5f6"}`; string(data) != want {
+		t.Errorf("hass format = %s, want %s", data, want)
+	}
+}
+
+func TestFormatCard_NFCTools(t *testing.T) {
+	card := &core.Card{
+		UID:      "04a1b2c3d4e5f6",
+		ATQA:     "0044",
+		SAK:      "00",
+		Type:     "NTAG215",
+		Protocol: "NFC-A",
+		Size:     504,
+		Writable: true,
+		Records: []core.ParsedRecord{
+			{TNF: 0x01, Type: "U", Data: "https://example.com", DataType: "url", Payload: []byte{0x04, 'e', 'x'}},
+		},
+	}
+
+	result, err := formatCard(card, "nfctools")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tag, ok := result.(*NFCToolsTag)
+	if !ok {
+		t.Fatalf("expected *NFCToolsTag, got %T", result)
+	}
+	if tag.SerialNumber != "04:A1:B2:C3:D4:E5:F6" {
+		t.Errorf("SerialNumber = %q", tag.SerialNumber)
+	}
+	if tag.ATQA != "0x0044" || tag.SAK != "0x00" {
+		t.Errorf("ATQA/SAK = %q/%q", tag.ATQA, tag.SAK)
+	}
+	wantTech := []string{"android.nfc.tech.NfcA", "android.nfc.tech.MifareUltralight", "android.nfc.tech.Ndef"}
+	if strings.Join(tag.TechList, ",") != strings.Join(wantTech, ",") {
+		t.Errorf("TechList = %v, want %v", tag.TechList, wantTech)
+	}
+	if len(tag.Records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(tag.Records))
+	}
+	rec := tag.Records[0]
+	if rec.TNF != 0x01 || rec.Type != "U" || rec.Payload != "046578" || rec.Content != "https://example.com" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+
+	// Blank ISO 15693 tag: records is an empty list, not null
+	blank := nfcToolsCard(&core.Card{UID: "e004010203040506", Protocol: "NFC-V", Type: "ICode SLIX"})
+	if blank.Records == nil || len(blank.Records) != 0 {
+		t.Errorf("expected empty records, got %v", blank.Records)
+	}
+	if strings.Join(blank.TechList, ",") != "android.nfc.tech.NfcV" {
+		t.Errorf("TechList = %v", blank.TechList)
+	}
+}
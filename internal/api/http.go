@@ -1,15 +1,23 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/SimplyPrint/nfc-agent/internal/core"
 	"github.com/SimplyPrint/nfc-agent/internal/data"
@@ -80,25 +88,41 @@ func InitUpdateChecker() {
 	updateChecker = updater.NewChecker(Version)
 }
 
-// NewMux constructs and returns the HTTP mux for the API.
+// NewMux constructs and returns the HTTP mux for the API, with the embedded
+// status web UI served at the root.
 func NewMux() *http.ServeMux {
-	mux := http.NewServeMux()
-
-	// Serve embedded status web UI at root
+	mux := NewAPIMux()
 	mux.Handle("/", web.Handler())
+	return mux
+}
+
+// NewAPIMux constructs the HTTP mux for the API routes only, for serving the
+// web UI on a separate port.
+func NewAPIMux() *http.ServeMux {
+	mux := http.NewServeMux()
 
-	// API routes
 	mux.HandleFunc("/v1/readers", corsMiddleware(handleListReaders))
 	mux.HandleFunc("/v1/readers/", corsMiddleware(handleReaderRoutes)) // Note the trailing slash for sub-paths
 	mux.HandleFunc("/v1/supported-readers", corsMiddleware(handleSupportedReaders))
 	mux.HandleFunc("/v1/version", corsMiddleware(handleVersion))
 	mux.HandleFunc("/v1/health", corsMiddleware(handleHealth))
+	mux.HandleFunc("/v1/ready", corsMiddleware(handleReady))
+	mux.HandleFunc("/v1/system", corsMiddleware(handleSystem))
+	mux.HandleFunc("/v1/capabilities", corsMiddleware(handleCapabilities))
+	mux.HandleFunc("/v1/stats", corsMiddleware(handleStats))
 	mux.HandleFunc("/v1/logs", corsMiddleware(handleLogs))
+	mux.HandleFunc("/v1/audit", corsMiddleware(handleAudit))
 	mux.HandleFunc("/v1/crashes", corsMiddleware(handleCrashes))
 	mux.HandleFunc("/v1/settings", corsMiddleware(handleSettings))
+	mux.HandleFunc("/v1/keys", corsMiddleware(handleKeyProfiles))
+	mux.HandleFunc("/v1/keys/", corsMiddleware(handleKeyProfiles))
 	mux.HandleFunc("/v1/shutdown", corsMiddleware(handleShutdown))
+	mux.HandleFunc("/v1/maintenance/", corsMiddleware(handleMaintenance))
 	mux.HandleFunc("/v1/autostart", corsMiddleware(handleAutostart))
 	mux.HandleFunc("/v1/updates", corsMiddleware(handleUpdates))
+	mux.HandleFunc("/v1/openapi.json", corsMiddleware(handleOpenAPI))
+
+	muxBuilt.Store(true)
 	return mux
 }
 
@@ -144,24 +168,99 @@ func recoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// corsMiddleware adds CORS headers to allow browser access from any origin.
+// CORSMaxAge is how long browsers may cache a preflight response.
+var CORSMaxAge = 2 * time.Hour
+
+// CORSAllowedOrigins limits browser access to these origins. If empty, any
+// origin is allowed.
+var CORSAllowedOrigins []string
+
+const (
+	corsAllowMethods = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders = "Content-Type, Authorization, X-Reader-Claim"
+)
+
+// corsMiddleware adds CORS headers to allow browser access from any origin,
+// or only from CORSAllowedOrigins if set. State-changing requests from other
+// origins are refused with 403, since a browser sends simple requests (e.g. a
+// form POST) without a preflight and only blocks reading the response.
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		noteActivity()
+		preflight := r.Method == http.MethodOptions
+		if setCORSHeaders(w.Header(), r, preflight) && preflight {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(CORSMaxAge.Seconds())))
+		}
 
 		// Handle preflight requests
-		if r.Method == http.MethodOptions {
+		if preflight {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
 
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !originAllowed(r) {
+			logging.Warn(logging.CatHTTP, "Request from disallowed origin refused", map[string]any{
+				"origin": r.Header.Get("Origin"),
+				"method": r.Method,
+				"path":   r.URL.Path,
+			})
+			respondJSON(w, http.StatusForbidden, map[string]string{
+				"error": "origin not allowed",
+				"code":  "ORIGIN_NOT_ALLOWED",
+			})
+			return
+		}
+
 		// Wrap with recovery middleware
 		recoveryMiddleware(next)(w, r)
 	}
 }
 
+// originAllowed reports whether r may act on the agent: always without an
+// allowlist or without an Origin header (non-browser clients), otherwise only
+// from an origin in CORSAllowedOrigins.
+func originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return len(CORSAllowedOrigins) == 0 || origin == "" || slices.Contains(CORSAllowedOrigins, origin)
+}
+
+// setCORSHeaders sets the CORS response headers for r and reports whether
+// its origin is allowed. With an allowlist, the allowed origin is echoed and a
+// preflight gets back the method and headers it asked for; other origins get
+// no CORS headers, so browsers block them.
+func setCORSHeaders(h http.Header, r *http.Request, preflight bool) bool {
+	if len(CORSAllowedOrigins) == 0 {
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+		h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		return true
+	}
+
+	h.Add("Vary", "Origin")
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" || !slices.Contains(CORSAllowedOrigins, origin) {
+		return false
+	}
+
+	h.Set("Access-Control-Allow-Origin", origin)
+	methods, headers := corsAllowMethods, corsAllowHeaders
+	if preflight {
+		if m := r.Header.Get("Access-Control-Request-Method"); m != "" {
+			methods = m
+		}
+		if hdrs := r.Header.Get("Access-Control-Request-Headers"); hdrs != "" {
+			headers = hdrs
+		}
+	}
+	h.Set("Access-Control-Allow-Methods", methods)
+	h.Set("Access-Control-Allow-Headers", headers)
+	return true
+}
+
 func handleListReaders(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
@@ -170,6 +269,27 @@ func handleListReaders(w http.ResponseWriter, r *http.Request) {
 
 	readers := core.ListReaders()
 
+	if v := r.URL.Query().Get("capability"); v != "" {
+		capabilities := strings.Split(v, ",")
+		for _, c := range capabilities {
+			if !data.ValidCapability(c) {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("unknown capability: %s", c),
+				})
+				return
+			}
+		}
+		table, err := data.GetSupportedReaders()
+		if err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{
+				"error": "failed to load supported readers",
+			})
+			return
+		}
+		respondJSON(w, http.StatusOK, filterReadersByCapability(readers, table, capabilities))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(readers); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -177,6 +297,41 @@ func handleListReaders(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// capableReader is a reader returned by GET /v1/readers?capability=.
+type capableReader struct {
+	Index int `json:"index"`
+	core.Reader
+	CapabilityUnknown bool `json:"capabilityUnknown,omitempty"` // Model not in the supported-readers table, so not checked
+}
+
+// filterReadersByCapability returns the readers whose model has all of the
+// capabilities, going by the supported-readers table. Readers of unknown
+// models are kept and flagged; SAM slots never match.
+func filterReadersByCapability(readers []core.Reader, table []data.SupportedReader, capabilities []string) []capableReader {
+	matches := []capableReader{}
+	for i, reader := range readers {
+		if reader.Type == "sam" {
+			continue
+		}
+		model, ok := data.MatchReader(table, reader.Name)
+		if !ok {
+			matches = append(matches, capableReader{Index: i, Reader: reader, CapabilityUnknown: true})
+			continue
+		}
+		capable := true
+		for _, c := range capabilities {
+			if !model.HasCapability(c) {
+				capable = false
+				break
+			}
+		}
+		if capable {
+			matches = append(matches, capableReader{Index: i, Reader: reader})
+		}
+	}
+	return matches
+}
+
 func handleReaderRoutes(w http.ResponseWriter, r *http.Request) {
 	// Parse path: /v1/readers/{index}/...
 	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
@@ -213,15 +368,34 @@ func handleReaderRoutes(w http.ResponseWriter, r *http.Request) {
 
 	readerName := readers[readerIndex].Name
 
+	if operation := httpAuditOperation(r.Method, parts); operation != "" {
+		var done func()
+		w, done = auditHTTP(w, r, readerName, operation)
+		defer done()
+	}
+
 	// Route to appropriate handler based on path
 	if len(parts) >= 4 {
+		if parts[3] != "claim" {
+			if err := readerClaims.check(readerName, httpClaimOwner(r)); err != nil {
+				respondCardError(w, http.StatusConflict, err)
+				return
+			}
+		}
+
 		switch parts[3] {
 		case "card":
 			handleReaderCard(w, r, readerName)
+		case "cards":
+			handleCardsInField(w, r, readerName)
 		case "erase":
 			handleEraseCard(w, r, readerName)
 		case "lock":
-			handleLockCard(w, r, readerName)
+			if len(parts) >= 5 {
+				handleLockSubroute(w, r, readerName, parts)
+			} else {
+				handleLockCard(w, r, readerName)
+			}
 		case "password":
 			handlePassword(w, r, readerName)
 		case "records":
@@ -230,6 +404,28 @@ func handleReaderRoutes(w http.ResponseWriter, r *http.Request) {
 			handleMifareBlock(w, r, readerName, parts)
 		case "ultralight":
 			handleUltralightPage(w, r, readerName, parts)
+		case "counter":
+			handleCounter(w, r, readerName, parts)
+		case "ntag":
+			handleNTAG(w, r, readerName, parts)
+		case "layout":
+			handleMemoryLayout(w, r, readerName)
+		case "iso15693":
+			handleISO15693(w, r, readerName, parts)
+		case "test-write":
+			handleTestWrite(w, r, readerName)
+		case "kv":
+			handleKeyValues(w, r, readerName)
+		case "app-data":
+			handleAppData(w, r, readerName)
+		case "provision-spool":
+			handleProvisionSpool(w, r, readerName)
+		case "openprinttag":
+			handleOpenPrintTagAux(w, r, readerName, parts)
+		case "script":
+			handleAPDUScript(w, r, readerName)
+		case "claim":
+			handleReaderClaim(w, r, readerName)
 		default:
 			respondJSON(w, http.StatusNotFound, map[string]string{
 				"error": "unknown endpoint",
@@ -242,157 +438,173 @@ func handleReaderRoutes(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func handleReaderCard(w http.ResponseWriter, r *http.Request, readerName string) {
-	switch r.Method {
-	case http.MethodGet:
-		// Read card UID and info
-		card, err := core.GetCardUID(readerName)
-		if err != nil {
-			logging.Debug(logging.CatHTTP, "Card read failed", map[string]any{
-				"reader": readerName,
-				"error":  err.Error(),
-			})
-			respondJSON(w, http.StatusNotFound, map[string]string{
-				"error": err.Error(),
-			})
-			return
-		}
-		logData := map[string]any{
-			"reader": readerName,
-			"uid":    card.UID,
-			"type":   card.Type,
-		}
-		if card.Data != "" {
-			logData["data"] = card.Data
-			logData["dataType"] = card.DataType
-		}
-		if card.URL != "" {
-			logData["url"] = card.URL
-		}
-		logging.Info(logging.CatCard, "Tag read", logData)
-		respondJSON(w, http.StatusOK, card)
-
-	case http.MethodPost:
-		// Write data to card
-		var req struct {
-			Data     string `json:"data"`     // Data to write (string for text/json, base64 for binary)
-			DataType string `json:"dataType"` // "text", "json", "binary", or "url"
-			URL      string `json:"url"`      // Optional URL to write as first record
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondJSON(w, http.StatusBadRequest, map[string]string{
-				"error": "invalid request body",
-			})
-			return
-		}
+// handleCardsInField lists the UIDs of all cards in the reader's field
+// GET /v1/readers/{n}/cards
+func handleCardsInField(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
 
-		// Validate data type
-		if req.DataType == "" {
-			req.DataType = "text" // Default to text
-		}
+	uids, err := core.ListCardsInField(readerName)
+	if err != nil {
+		respondCardError(w, http.StatusNotFound, err)
+		return
+	}
 
-		// Convert data based on type
-		var dataBytes []byte
-		switch req.DataType {
-		case "text", "json", "url":
-			dataBytes = []byte(req.Data)
-		case "binary":
-			// Decode base64 for binary data
-			var err error
-			dataBytes, err = base64.StdEncoding.DecodeString(req.Data)
-			if err != nil {
-				respondJSON(w, http.StatusBadRequest, map[string]string{
-					"error": "invalid base64 data for binary type",
-				})
-				return
-			}
-		case "openprinttag":
-			// Validate JSON structure for openprinttag
-			var input openprinttag.Input
-			if err := json.Unmarshal([]byte(req.Data), &input); err != nil {
-				respondJSON(w, http.StatusBadRequest, map[string]string{
-					"error": "invalid openprinttag JSON format: " + err.Error(),
-				})
-				return
-			}
-			dataBytes = []byte(req.Data)
-		default:
-			respondJSON(w, http.StatusBadRequest, map[string]string{
-				"error": "dataType must be 'text', 'json', 'binary', 'url', or 'openprinttag'",
-			})
-			return
-		}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"uids":  uids,
+		"count": len(uids),
+	})
+}
 
-		// Write data to card (with optional URL)
-		if err := core.WriteDataWithURL(readerName, dataBytes, req.DataType, req.URL); err != nil {
-			logging.Error(logging.CatCard, "Tag write failed", map[string]any{
-				"reader": readerName,
-				"error":  err.Error(),
-			})
-			respondJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": err.Error(),
-			})
-			return
-		}
+// isFormRequest reports whether the request body is URL-encoded form data.
+// Anything else, including a missing Content-Type, is treated as JSON.
+func isFormRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
 
-		logData := map[string]any{
-			"reader":   readerName,
-			"dataType": req.DataType,
-			"dataLen":  len(dataBytes),
-		}
-		if req.URL != "" {
-			logData["url"] = req.URL
-		}
-		logging.Info(logging.CatCard, "Tag written", logData)
-		respondJSON(w, http.StatusOK, map[string]string{
-			"success": "data written successfully",
+// handleNTAG routes the NTAG21x configuration endpoints
+func handleNTAG(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	if len(parts) < 5 {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /ntag/config or /ntag/mirror-url)",
 		})
+		return
+	}
 
+	switch parts[4] {
+	case "config":
+		handleNTAGConfig(w, r, readerName)
+	case "mirror-url":
+		handleMirroredURL(w, r, readerName)
 	default:
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /ntag/config or /ntag/mirror-url)",
+		})
+	}
+}
+
+// handleNTAGConfig returns the decoded configuration pages of an NTAG21x tag
+// GET /v1/readers/{n}/ntag/config
+func handleNTAGConfig(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodGet {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, err := core.ReadNTAGConfig(readerName)
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
 	}
+
+	respondJSON(w, http.StatusOK, cfg)
 }
 
-func handleEraseCard(w http.ResponseWriter, r *http.Request, readerName string) {
-	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+// handleMemoryLayout returns the page/block layout of the card on the reader
+// GET /v1/readers/{n}/layout
+func handleMemoryLayout(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodGet {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
-	logging.Info(logging.CatCard, "Erasing card", map[string]any{
-		"reader": readerName,
-	})
-	if err := core.EraseCard(readerName); err != nil {
-		logging.Error(logging.CatCard, "Card erase failed", map[string]any{
-			"reader": readerName,
-			"error":  err.Error(),
+	layout, err := core.ReadMemoryLayout(readerName)
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, layout)
+}
+
+// AllowRawAPDU enables POST /v1/readers/{n}/script, which sends arbitrary
+// APDUs to the card. Off by default, since a wrong command can lock a card.
+var AllowRawAPDU bool
+
+// handleAPDUScript runs a sequence of raw APDUs in one card session
+// POST /v1/readers/{n}/script with {"steps": [{"apdu": "FFCA000000", "expectStatus": "9000"}]}
+func handleAPDUScript(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !AllowRawAPDU {
+		respondJSON(w, http.StatusForbidden, map[string]string{
+			"error": "raw APDU scripts are disabled (set NFC_AGENT_ALLOW_RAW_APDU=true)",
+			"code":  "RAW_APDU_DISABLED",
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
+		return
+	}
+	if !requireAPIToken(w, r) {
+		return
+	}
+
+	var req struct {
+		Steps []core.APDUStep `json:"steps"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+		return
+	}
+	if err := core.ValidateAPDUScript(req.Steps); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	logging.Info(logging.CatCard, "Card erased successfully", map[string]any{
-		"reader": readerName,
-	})
-	respondJSON(w, http.StatusOK, map[string]string{
-		"success": "card erased successfully",
+	results, err := core.RunAPDUScript(readerName, req.Steps)
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"results":   results,
+		"completed": len(results) == len(req.Steps) && results[len(results)-1].OK,
 	})
 }
 
-func handleLockCard(w http.ResponseWriter, r *http.Request, readerName string) {
+// handleISO15693 routes the ISO 15693 endpoints
+func handleISO15693(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	if len(parts) < 5 || parts[4] != "dump" {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /iso15693/dump)",
+		})
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	dump, err := core.DumpISO15693(readerName)
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, dump)
+}
+
+// handleMirroredURL writes a URL template and configures the UID/counter mirror
+// POST /v1/readers/{n}/ntag/mirror-url with {"template": "https://x.io/t?id={UID}"}
+func handleMirroredURL(w http.ResponseWriter, r *http.Request, readerName string) {
 	if r.Method != http.MethodPost {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Require confirmation parameter to prevent accidental locking
 	var req struct {
-		Confirm bool `json:"confirm"`
+		Template  string `json:"template"`
+		Force     bool   `json:"force"`     // Overwrite a write-protected OpenPrintTag
+		ExpectUID string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
 			"error": "invalid request body",
@@ -400,39 +612,522 @@ func handleLockCard(w http.ResponseWriter, r *http.Request, readerName string) {
 		return
 	}
 
-	if !req.Confirm {
+	if err := core.ValidateMirrorTemplate(req.Template); err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "must set confirm=true to lock card (WARNING: this is IRREVERSIBLE)",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	logging.Warn(logging.CatCard, "Locking card permanently", map[string]any{
-		"reader": readerName,
-	})
-	if err := core.LockCard(readerName); err != nil {
-		logging.Error(logging.CatCard, "Card lock failed", map[string]any{
-			"reader": readerName,
-			"error":  err.Error(),
-		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+	opts := core.WriteOptions{Force: req.Force, ExpectUID: req.ExpectUID}
+	if err := core.WriteMirroredURLWithOptions(readerName, req.Template, opts); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, core.ErrExceedsUserMemory) {
+			status = http.StatusBadRequest
+		}
+		respondCardError(w, status, err)
 		return
 	}
 
-	logging.Warn(logging.CatCard, "Card locked permanently", map[string]any{
-		"reader": readerName,
-	})
 	respondJSON(w, http.StatusOK, map[string]string{
-		"success": "card locked permanently",
+		"success": "mirrored URL written successfully",
 	})
 }
 
-func handlePassword(w http.ResponseWriter, r *http.Request, readerName string) {
+func handleReaderCard(w http.ResponseWriter, r *http.Request, readerName string) {
 	switch r.Method {
-	case http.MethodPost:
-		// Set password
+	case http.MethodGet:
+		format := r.URL.Query().Get("format")
+		if !supportedCardFormats[format] {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported format: %s", format),
+			})
+			return
+		}
+
+		maxPages := 0
+		if v := r.URL.Query().Get("maxPages"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "maxPages must be a positive integer",
+				})
+				return
+			}
+			maxPages = n
+		}
+
+		prefer := r.URL.Query().Get("prefer")
+		if !supportedReadPreferences[prefer] {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported prefer value: %s", prefer),
+			})
+			return
+		}
+
+		encoding := r.URL.Query().Get("encoding")
+		if !core.IsBinaryEncoding(encoding) {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported encoding: %s", encoding),
+			})
+			return
+		}
+
+		blocks := 0
+		if v := r.URL.Query().Get("blocks"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > core.MaxMifareBlocks {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("blocks must be between 1 and %d", core.MaxMifareBlocks),
+				})
+				return
+			}
+			blocks = n
+		}
+
+		includeRaw := false
+		if v := r.URL.Query().Get("includeRaw"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "includeRaw must be true or false",
+				})
+				return
+			}
+			includeRaw = b
+		}
+
+		readAll := false
+		if v := r.URL.Query().Get("readAll"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "readAll must be true or false",
+				})
+				return
+			}
+			readAll = b
+		}
+
+		checkBCC := false
+		if v := r.URL.Query().Get("bcc"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "bcc must be true or false",
+				})
+				return
+			}
+			checkBCC = b
+		}
+
+		debug, debugMode := false, ""
+		if v := r.URL.Query().Get("debug"); v == apduDebugMode {
+			debugMode = v
+		} else if v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "debug must be true, false or apdu",
+				})
+				return
+			}
+			debug = b
+		}
+
+		// Read card UID and info
+		trace := newAPDUDebug(debugMode)
+		card, err := core.GetCardUIDWithOptions(readerName, core.ReadOptions{
+			Lang:         r.URL.Query().Get("lang"),
+			MaxPages:     maxPages,
+			IncludeRaw:   includeRaw,
+			MifareBlocks: blocks,
+			Prefer:       prefer,
+			Encoding:     encoding,
+			ReadAll:      readAll,
+			Debug:        debug,
+			CheckBCC:     checkBCC,
+			Trace:        trace,
+		})
+		debugLog := apduDebugLog(trace)
+		if err != nil {
+			logging.Debug(logging.CatHTTP, "Card read failed", map[string]any{
+				"reader": readerName,
+				"error":  err.Error(),
+			})
+			respondCardErrorLog(w, http.StatusNotFound, err, debugLog)
+			return
+		}
+		card.DebugLog = debugLog
+		logData := map[string]any{
+			"reader": readerName,
+			"uid":    card.UID,
+			"type":   card.Type,
+		}
+		if card.Data != "" {
+			logData["data"] = card.Data
+			logData["dataType"] = card.DataType
+		}
+		if card.URL != "" {
+			logData["url"] = card.URL
+		}
+		logging.Info(logging.CatCard, "Tag read", logData)
+
+		response, err := formatCard(card, format)
+		if err != nil {
+			respondJSON(w, http.StatusUnprocessableEntity, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		respondJSON(w, http.StatusOK, response)
+
+	case http.MethodPost:
+		// Write data to card
+		r.Body = http.MaxBytesReader(w, r.Body, maxWriteBodyBytes)
+		var req struct {
+			Data     string `json:"data"`     // Data to write (string for text/json, base64 for binary)
+			DataType string `json:"dataType"` // "text", "json", "binary", or "url"
+			URL      string `json:"url"`      // Optional URL to write as first record
+			Force    bool   `json:"force"`    // Overwrite a write-protected OpenPrintTag
+
+			ExpectUID string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+
+			ControlTLVs []controlTLVRequest `json:"controlTlvs"` // Optional TLVs before the NDEF TLV (JSON only)
+
+			LongRecords bool `json:"longRecords"` // Advanced: force long-record encoding (JSON only)
+
+			IdempotencyKey string `json:"idempotencyKey"` // Retries with this key within the window aren't written again
+
+			Encode bool `json:"encode"` // Percent-encode characters not allowed in the URL instead of rejecting it
+		}
+
+		if isFormRequest(r) {
+			// Form fields for clients that can't send JSON (e.g. curl -d)
+			if err := r.ParseForm(); err != nil {
+				respondInvalidBody(w, err, "invalid form body")
+				return
+			}
+			req.Data = r.PostForm.Get("data")
+			req.DataType = r.PostForm.Get("dataType")
+			req.URL = r.PostForm.Get("url")
+			if v := r.PostForm.Get("force"); v != "" {
+				force, err := strconv.ParseBool(v)
+				if err != nil {
+					respondJSON(w, http.StatusBadRequest, map[string]string{
+						"error": "force must be true or false",
+					})
+					return
+				}
+				req.Force = force
+			}
+			req.ExpectUID = r.PostForm.Get("expectUID")
+			req.IdempotencyKey = r.PostForm.Get("idempotencyKey")
+			if v := r.PostForm.Get("encode"); v != "" {
+				encode, err := strconv.ParseBool(v)
+				if err != nil {
+					respondJSON(w, http.StatusBadRequest, map[string]string{
+						"error": "encode must be true or false",
+					})
+					return
+				}
+				req.Encode = encode
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondInvalidBody(w, err, "invalid request body")
+			return
+		}
+
+		// Validate data type. Untyped form data is text unless it isn't valid
+		// UTF-8; a JSON string always decodes to valid UTF-8, so JSON bodies
+		// default to text.
+		rawBinary := false
+		if req.DataType == "" {
+			req.DataType = "text"
+			if isFormRequest(r) {
+				req.DataType = sniffDataType(req.Data)
+				rawBinary = req.DataType == "binary"
+			}
+		}
+
+		// Convert data based on type
+		var dataBytes []byte
+		switch req.DataType {
+		case "text", "json", "url":
+			if req.DataType == "text" && !utf8.ValidString(req.Data) {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": textNotUTF8Message,
+				})
+				return
+			}
+			dataBytes = []byte(req.Data)
+		case "binary":
+			if rawBinary {
+				// Sniffed binary is sent as is, not base64
+				dataBytes = []byte(req.Data)
+				break
+			}
+			// Decode base64 for binary data
+			var err error
+			dataBytes, err = base64.StdEncoding.DecodeString(req.Data)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "invalid base64 data for binary type",
+				})
+				return
+			}
+		case "openprinttag":
+			// Validate JSON structure for openprinttag
+			var input openprinttag.Input
+			if err := json.Unmarshal([]byte(req.Data), &input); err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "invalid openprinttag JSON format: " + err.Error(),
+				})
+				return
+			}
+			dataBytes = []byte(req.Data)
+		case "cbor":
+			// Any JSON document, stored as CBOR
+			if !json.Valid([]byte(req.Data)) {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "invalid JSON data for cbor type",
+				})
+				return
+			}
+			dataBytes = []byte(req.Data)
+		default:
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "dataType must be 'text', 'json', 'binary', 'url', 'cbor', or 'openprinttag'",
+			})
+			return
+		}
+
+		if err := prepareWriteURIs(&req.URL, &dataBytes, req.DataType, req.Encode); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		controlTLVs, err := parseControlTLVs(req.ControlTLVs)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		debugMode := r.URL.Query().Get("debug")
+		if err := checkAPDUDebug(debugMode); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Write data to card (with optional URL)
+		trace := newAPDUDebug(debugMode)
+		opts := core.WriteOptions{Force: req.Force, ControlTLVs: controlTLVs, ExpectUID: req.ExpectUID, LongRecords: req.LongRecords, Trace: trace}
+		fingerprint := idempotencyFingerprint("write", req.DataType, dataBytes, req.URL, req.Force, req.ExpectUID, req.ControlTLVs, req.LongRecords)
+		replayed, err := idempotentWrites.do(idempotencyScope(readerName, req.IdempotencyKey), fingerprint, func() error {
+			return core.WriteDataWithOptions(readerName, dataBytes, req.DataType, req.URL, opts)
+		})
+		debugLog := apduDebugLog(trace)
+		if err != nil {
+			// A reused key never reached the card
+			if !errors.Is(err, errIdempotencyKeyReused) {
+				logging.Error(logging.CatCard, "Tag write failed", map[string]any{
+					"reader": readerName,
+					"error":  err.Error(),
+				})
+			}
+			respondCardErrorLog(w, http.StatusInternalServerError, err, debugLog)
+			return
+		}
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		} else {
+			logData := map[string]any{
+				"reader":   readerName,
+				"dataType": req.DataType,
+				"dataLen":  len(dataBytes),
+			}
+			if req.URL != "" {
+				logData["url"] = req.URL
+			}
+			logging.Info(logging.CatCard, "Tag written", logData)
+		}
+
+		if debugLog != nil {
+			respondJSON(w, http.StatusOK, map[string]any{
+				"success":  "data written successfully",
+				"debugLog": debugLog,
+			})
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "data written successfully",
+		})
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func handleEraseCard(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Erase has no body; the optional UID guard and full erase are query parameters
+	opts := core.WriteOptions{ExpectUID: r.URL.Query().Get("expectUID")}
+	if full := r.URL.Query().Get("full"); full != "" {
+		var err error
+		if opts.ZeroMemory, err = strconv.ParseBool(full); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "full must be true or false",
+			})
+			return
+		}
+	}
+
+	logging.Info(logging.CatCard, "Erasing card", map[string]any{
+		"reader": readerName,
+	})
+	if err := core.EraseCardWithOptions(readerName, opts); err != nil {
+		logging.Error(logging.CatCard, "Card erase failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	logging.Info(logging.CatCard, "Card erased successfully", map[string]any{
+		"reader": readerName,
+	})
+	respondJSON(w, http.StatusOK, map[string]string{
+		"success": "card erased successfully",
+	})
+}
+
+func handleLockCard(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Require confirmation parameter to prevent accidental locking
+	var req struct {
+		Confirm   bool   `json:"confirm"`
+		ExpectUID string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	if !req.Confirm {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "must set confirm=true to lock card (WARNING: this is IRREVERSIBLE)",
+		})
+		return
+	}
+
+	logging.Warn(logging.CatCard, "Locking card permanently", map[string]any{
+		"reader": readerName,
+	})
+	if err := core.LockCardWithOptions(readerName, core.WriteOptions{ExpectUID: req.ExpectUID}); err != nil {
+		logging.Error(logging.CatCard, "Card lock failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	logging.Warn(logging.CatCard, "Card locked permanently", map[string]any{
+		"reader": readerName,
+	})
+	respondJSON(w, http.StatusOK, map[string]string{
+		"success": "card locked permanently",
+	})
+}
+
+// handleLockSubroute routes the endpoints under /lock. Unknown ones are
+// rejected rather than falling through to locking the whole card.
+func handleLockSubroute(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	switch parts[4] {
+	case "pages":
+		handleLockPages(w, r, readerName)
+	default:
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /lock or /lock/pages)",
+		})
+	}
+}
+
+// handleLockPages permanently locks only the given pages of an NTAG21x tag
+// POST /v1/readers/{n}/lock/pages
+func handleLockPages(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Pages     []int  `json:"pages"`
+		Confirm   bool   `json:"confirm"`
+		ExpectUID string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	if len(req.Pages) == 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "pages is required",
+		})
+		return
+	}
+
+	if !req.Confirm {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "must set confirm=true to lock pages (WARNING: this is IRREVERSIBLE)",
+		})
+		return
+	}
+
+	if err := core.LockPagesWithOptions(readerName, req.Pages, core.WriteOptions{ExpectUID: req.ExpectUID}); err != nil {
+		logging.Error(logging.CatCard, "Page lock failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	logging.Warn(logging.CatCard, "Pages locked permanently", map[string]any{
+		"reader": readerName,
+		"pages":  req.Pages,
+	})
+	respondJSON(w, http.StatusOK, map[string]string{
+		"success": "pages locked permanently",
+	})
+}
+
+func handlePassword(w http.ResponseWriter, r *http.Request, readerName string) {
+	switch r.Method {
+	case http.MethodPost:
+		// Set password
 		var req struct {
 			Password  string `json:"password"`  // 4 bytes as hex string (8 chars)
 			Pack      string `json:"pack"`      // 2 bytes as hex string (4 chars)
@@ -467,46 +1162,343 @@ func handlePassword(w http.ResponseWriter, r *http.Request, readerName string) {
 		}
 
 		if err := core.SetPassword(readerName, password, pack, byte(req.StartPage)); err != nil {
-			respondJSON(w, http.StatusInternalServerError, map[string]string{
+			respondCardError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "password set successfully",
+		})
+
+	case http.MethodDelete:
+		// Remove password
+		var req struct {
+			Password string `json:"password"` // Current password to authenticate
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+			return
+		}
+
+		password, err := hex.DecodeString(req.Password)
+		if err != nil || len(password) != 4 {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "password must be 8 hex characters (4 bytes)",
+			})
+			return
+		}
+
+		if err := core.RemovePassword(readerName, password); err != nil {
+			respondCardError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "password removed successfully",
+		})
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// maxWriteBodyBytes caps the body of a card write, as wsReadLimit caps a
+// WebSocket message, so an oversized request is refused before its data is
+// decoded.
+const maxWriteBodyBytes = wsReadLimit
+
+// respondInvalidBody answers a request whose body could not be decoded: 413
+// if it was larger than maxWriteBodyBytes, otherwise 400 with message.
+func respondInvalidBody(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("request body too large (max %d bytes)", tooLarge.Limit),
+		})
+		return
+	}
+	respondJSON(w, http.StatusBadRequest, map[string]string{
+		"error": message,
+	})
+}
+
+func handleMultipleRecords(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Records []core.NDEFRecord `json:"records"`
+		Force   bool              `json:"force"` // Overwrite a write-protected OpenPrintTag
+
+		ExpectUID string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+
+		ControlTLVs []controlTLVRequest `json:"controlTlvs"` // Optional TLVs before the NDEF TLV
+
+		LongRecords bool `json:"longRecords"` // Advanced: force long-record encoding
+
+		IdempotencyKey string `json:"idempotencyKey"` // Retries with this key within the window aren't written again
+
+		Encode bool `json:"encode"` // Percent-encode characters not allowed in url records instead of rejecting them
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxWriteBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondInvalidBody(w, err, "invalid request body")
+		return
+	}
+
+	if len(req.Records) == 0 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "records array cannot be empty",
+		})
+		return
+	}
+
+	if req.Encode {
+		if err := encodeURIRecords(req.Records); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
 				"error": err.Error(),
 			})
 			return
 		}
+	}
+
+	if err := core.ValidateNDEFRecords(req.Records); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	controlTLVs, err := parseControlTLVs(req.ControlTLVs)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	opts := core.WriteOptions{Force: req.Force, ControlTLVs: controlTLVs, ExpectUID: req.ExpectUID, LongRecords: req.LongRecords}
+	fingerprint := idempotencyFingerprint("records", req.Records, req.Force, req.ExpectUID, req.ControlTLVs, req.LongRecords)
+	replayed, err := idempotentWrites.do(idempotencyScope(readerName, req.IdempotencyKey), fingerprint, func() error {
+		return core.WriteMultipleRecordsWithOptions(readerName, req.Records, opts)
+	})
+	if err != nil {
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"success": "records written successfully",
+	})
+}
+
+// controlTLVRequest is a lock-control, memory-control or proprietary TLV to
+// write before the NDEF TLV.
+type controlTLVRequest struct {
+	Type  byte   `json:"type"`  // 1 = lock control, 2 = memory control, 253 = proprietary
+	Value string `json:"value"` // Hex string
+}
+
+// textNotUTF8Message is the error message for text writes whose data isn't valid UTF-8.
+const textNotUTF8Message = "data is not valid UTF-8 text; use dataType 'binary' (base64) for binary data"
+
+// prepareWriteURIs checks the URL of a card write, and the data of a url
+// write, percent-encoding them first if encode is set.
+func prepareWriteURIs(uri *string, data *[]byte, dataType string, encode bool) error {
+	check := func(s string) (string, error) {
+		if encode {
+			return core.EncodeURI(s)
+		}
+		return s, core.CheckURI(s)
+	}
+	if *uri != "" {
+		checked, err := check(*uri)
+		if err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+		*uri = checked
+	}
+	if dataType == "url" {
+		checked, err := check(string(*data))
+		if err != nil {
+			return fmt.Errorf("data: %w", err)
+		}
+		*data = []byte(checked)
+	}
+	return nil
+}
+
+// encodeURIRecords percent-encodes the URIs of url records in place.
+func encodeURIRecords(records []core.NDEFRecord) error {
+	for i := range records {
+		if records[i].Type != "url" {
+			continue
+		}
+		encoded, err := core.EncodeURI(records[i].Data)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		records[i].Data = encoded
+	}
+	return nil
+}
+
+// sniffDataType picks the dataType for a write that didn't set one: "text"
+// for valid UTF-8, otherwise "binary" (the raw bytes, not base64).
+func sniffDataType(data string) string {
+	if utf8.ValidString(data) {
+		return "text"
+	}
+	return "binary"
+}
+
+// parseControlTLVs decodes and validates control TLVs from a write request.
+func parseControlTLVs(reqs []controlTLVRequest) ([]core.TLV, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	tlvs := make([]core.TLV, len(reqs))
+	for i, req := range reqs {
+		value, err := hex.DecodeString(req.Value)
+		if err != nil {
+			return nil, fmt.Errorf("controlTlvs[%d]: value must be a hex string", i)
+		}
+		tlvs[i] = core.TLV{Type: req.Type, Value: value}
+	}
+	if err := core.ValidateControlTLVs(tlvs); err != nil {
+		return nil, err
+	}
+	return tlvs, nil
+}
+
+// handleKeyValues reads or replaces the key-value store on a card
+// GET /v1/readers/{n}/kv - Read stored values
+// POST /v1/readers/{n}/kv - Replace stored values with {"values": {...}}, optionally expiring
+func handleKeyValues(w http.ResponseWriter, r *http.Request, readerName string) {
+	switch r.Method {
+	case http.MethodGet:
+		kv, err := core.ReadKeyValues(readerName)
+		if errors.Is(err, core.ErrNoKeyValues) {
+			respondJSON(w, http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			respondCardError(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, kv)
+
+	case http.MethodPost:
+		var req struct {
+			Values    map[string]string `json:"values"`
+			ExpiresAt int64             `json:"expiresAt"` // Optional, unix time in seconds
+			TTL       int64             `json:"ttl"`       // Optional, seconds from now (instead of expiresAt)
+			Force     bool              `json:"force"`     // Overwrite a write-protected OpenPrintTag
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+			return
+		}
+		expiresAt, err := requestExpiry(req.ExpiresAt, req.TTL)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := core.WriteKeyValuesWithOptions(readerName, req.Values, expiresAt, core.WriteOptions{Force: req.Force}); err != nil {
+			respondCardError(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "values written successfully",
+		})
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// requestExpiry returns the expiry in unix seconds requested by either an
+// absolute expiresAt or a ttl in seconds from now, or 0 for none.
+func requestExpiry(expiresAt, ttl int64) (int64, error) {
+	switch {
+	case expiresAt != 0 && ttl != 0:
+		return 0, fmt.Errorf("expiresAt and ttl are mutually exclusive")
+	case expiresAt < 0 || ttl < 0:
+		return 0, fmt.Errorf("expiresAt and ttl must not be negative")
+	case ttl > 0:
+		return time.Now().Unix() + ttl, nil
+	}
+	return expiresAt, nil
+}
+
+// handleAppData reads or writes the card's versioned SimplyPrint app data record
+// GET/POST /v1/readers/{n}/app-data
+func handleAppData(w http.ResponseWriter, r *http.Request, readerName string) {
+	switch r.Method {
+	case http.MethodGet:
+		app, err := core.ReadAppData(readerName)
+		if errors.Is(err, core.ErrNoAppData) {
+			respondJSON(w, http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, core.ErrUnsupportedAppDataVersion) {
+			respondJSON(w, http.StatusUnprocessableEntity, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			respondCardError(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, app)
 
-		respondJSON(w, http.StatusOK, map[string]string{
-			"success": "password set successfully",
-		})
-
-	case http.MethodDelete:
-		// Remove password
+	case http.MethodPost:
 		var req struct {
-			Password string `json:"password"` // Current password to authenticate
+			Data      json.RawMessage `json:"data"`
+			ExpiresAt int64           `json:"expiresAt"` // Optional, unix time in seconds
+			TTL       int64           `json:"ttl"`       // Optional, seconds from now (instead of expiresAt)
+			Force     bool            `json:"force"`     // Overwrite a write-protected OpenPrintTag
 		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Data) == 0 {
 			respondJSON(w, http.StatusBadRequest, map[string]string{
-				"error": "invalid request body",
+				"error": "invalid request body (expected {\"data\": ...})",
 			})
 			return
 		}
-
-		password, err := hex.DecodeString(req.Password)
-		if err != nil || len(password) != 4 {
+		expiresAt, err := requestExpiry(req.ExpiresAt, req.TTL)
+		if err != nil {
 			respondJSON(w, http.StatusBadRequest, map[string]string{
-				"error": "password must be 8 hex characters (4 bytes)",
+				"error": err.Error(),
 			})
 			return
 		}
 
-		if err := core.RemovePassword(readerName, password); err != nil {
-			respondJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": err.Error(),
-			})
+		if err := core.WriteAppDataWithOptions(readerName, req.Data, expiresAt, core.WriteOptions{Force: req.Force}); err != nil {
+			respondCardError(w, http.StatusInternalServerError, err)
 			return
 		}
-
-		respondJSON(w, http.StatusOK, map[string]string{
-			"success": "password removed successfully",
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"success": "app data written successfully",
+			"version": core.AppDataVersion,
 		})
 
 	default:
@@ -514,16 +1506,68 @@ func handlePassword(w http.ResponseWriter, r *http.Request, readerName string) {
 	}
 }
 
-func handleMultipleRecords(w http.ResponseWriter, r *http.Request, readerName string) {
+// handleOpenPrintTagAux applies a JSON Patch (RFC 6902) to the aux section of
+// the card's OpenPrintTag
+// PATCH /v1/readers/{n}/openprinttag/aux?expectUID=...
+func handleOpenPrintTagAux(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	if len(parts) < 5 || parts[4] != "aux" {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /openprinttag/aux)",
+		})
+		return
+	}
+	if r.Method != http.MethodPatch {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ops []openprinttag.AuxPatchOp
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid request body (expected a JSON Patch array)",
+		})
+		return
+	}
+	// Reject bad patches, including ones touching the main section, before reading the card
+	if err := openprinttag.ValidateAuxPatch(ops); err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	opts := core.WriteOptions{ExpectUID: r.URL.Query().Get("expectUID")}
+	opt, err := core.PatchOpenPrintTagAux(readerName, ops, opts)
+	if errors.Is(err, core.ErrNoOpenPrintTag) {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, opt.ToResponse())
+}
+
+// handleProvisionSpool writes an OpenPrintTag, optionally password-protects
+// it and signals the reader, then verifies the data, in one card session
+// POST /v1/readers/{n}/provision-spool
+func handleProvisionSpool(w http.ResponseWriter, r *http.Request, readerName string) {
 	if r.Method != http.MethodPost {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		Records []core.NDEFRecord `json:"records"`
+		Input    openprinttag.Input `json:"input"`
+		Protect  bool               `json:"protect"`  // Password-protect writes from page 4
+		Password string             `json:"password"` // Hex string, 8 chars = 4 bytes; required with protect
+		Feedback bool               `json:"feedback"` // Beep/flash the reader when done
+		Force    bool               `json:"force"`    // Overwrite a write-protected OpenPrintTag
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
 			"error": "invalid request body",
@@ -531,23 +1575,124 @@ func handleMultipleRecords(w http.ResponseWriter, r *http.Request, readerName st
 		return
 	}
 
-	if len(req.Records) == 0 {
+	password, err := parseUltralightPassword(req.Password)
+	if err == nil && req.Protect && password == nil {
+		err = errors.New("password is required with protect")
+	}
+	if err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "records array cannot be empty",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	if err := core.WriteMultipleRecords(readerName, req.Records); err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+	result, err := core.ProvisionSpool(readerName, req.Input, core.ProvisionOptions{
+		Protect:  req.Protect,
+		Password: password,
+		Feedback: req.Feedback,
+		Force:    req.Force,
+	})
+	if err != nil {
+		var provErr *core.ProvisionError
+		step := ""
+		status := http.StatusInternalServerError
+		if errors.As(err, &provErr) {
+			step = provErr.Step
+			if step == core.ProvisionStepEncode {
+				status = http.StatusBadRequest
+			}
+		}
+		status, code := cardErrorStatus(status, err)
+		setRetryAfter(w, code)
+		respondJSON(w, status, struct {
+			*core.ProvisionResult
+			Error      string `json:"error"`
+			Code       string `json:"code,omitempty"`
+			FailedStep string `json:"failedStep,omitempty"`
+		}{result, err.Error(), code, step})
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"success": "records written successfully",
-	})
+	respondJSON(w, http.StatusOK, result)
+}
+
+// claimTokenHeader carries the token returned by POST /v1/readers/{n}/claim.
+// Requests presenting it may use the claimed reader.
+const claimTokenHeader = "X-Reader-Claim"
+
+// httpClaimOwner returns the claim owner identity of an HTTP request.
+func httpClaimOwner(r *http.Request) string {
+	return "http:" + r.Header.Get(claimTokenHeader)
+}
+
+// handleReaderClaim takes or releases exclusive use of a reader
+// POST /v1/readers/{n}/claim - Claim for {"leaseMs": n} (renews when X-Reader-Claim is sent)
+// DELETE /v1/readers/{n}/claim - Release the claim named by X-Reader-Claim
+func handleReaderClaim(w http.ResponseWriter, r *http.Request, readerName string) {
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			LeaseMs int `json:"leaseMs"`
+		}
+		// The body is optional; an empty one claims for the default lease
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid JSON body",
+			})
+			return
+		}
+		lease, err := claimLease(req.LeaseMs)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		token := r.Header.Get(claimTokenHeader)
+		if token == "" {
+			if token, err = newClaimToken(); err != nil {
+				respondJSON(w, http.StatusInternalServerError, map[string]string{
+					"error": "failed to generate claim token",
+				})
+				return
+			}
+		}
+
+		claim, err := readerClaims.claim(readerName, "http:"+token, lease)
+		if err != nil {
+			respondCardError(w, http.StatusConflict, err)
+			return
+		}
+
+		logging.Info(logging.CatHTTP, "Reader claimed", map[string]any{
+			"reader":  readerName,
+			"leaseMs": claim.LeaseMs,
+		})
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"token":     token,
+			"reader":    claim.Reader,
+			"expiresAt": claim.ExpiresAt,
+			"leaseMs":   claim.LeaseMs,
+		})
+
+	case http.MethodDelete:
+		if !readerClaims.release(readerName, httpClaimOwner(r)) {
+			respondJSON(w, http.StatusNotFound, map[string]string{
+				"error": "no claim held with this token",
+			})
+			return
+		}
+		logging.Info(logging.CatHTTP, "Reader claim released", map[string]any{
+			"reader": readerName,
+		})
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "claim released",
+		})
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
 }
 
 func handleVersion(w http.ResponseWriter, r *http.Request) {
@@ -583,12 +1728,154 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	respondJSON(w, http.StatusOK, healthStatus())
+}
+
+// Readiness state reported by /v1/ready.
+var (
+	muxBuilt   atomic.Bool // NewMux has registered the routes
+	hubRunning atomic.Bool // The WebSocket hub loop is running
+)
+
+// pcscReady is core.PCSCReady, overridden in tests.
+var pcscReady = core.PCSCReady
+
+// pcscStatus is core.GetPCSCStatus, overridden in tests.
+var pcscStatus = core.GetPCSCStatus
+
+// handleReady is the readiness check: 200 once the routes are registered,
+// the WebSocket hub is running and a PC/SC context has been established at
+// least once, 503 until then. /v1/health stays the liveness check.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks := map[string]bool{
+		"mux":       muxBuilt.Load(),
+		"websocket": hubRunning.Load(),
+		"pcsc":      pcscReady(),
+	}
+	status, code := "ready", http.StatusOK
+	for _, ok := range checks {
+		if !ok {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	respondJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// handleSystem reports the PC/SC stack and reader firmware versions for bug
+// reports. It answers even when the PC/SC service is down, with what is known.
+func handleSystem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, err := core.GetSystemInfo()
+	if err != nil {
+		logging.Debug(logging.CatHTTP, "System info incomplete", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, struct {
+		AgentVersion string `json:"agentVersion"`
+		*core.SystemInfo
+	}{Version, info})
+}
+
+// healthStatus reports "ok", or "degraded" while the PC/SC service is down or
+// the self-check (if enabled) finds no reader.
+func healthStatus() map[string]interface{} {
 	// Check if we can list readers (basic health check)
 	readers := core.ListReaders()
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"status":      "ok",
+	status := "ok"
+	pcsc := pcscStatus()
+	if !pcsc.Available {
+		status = "degraded"
+	}
+
+	health := map[string]interface{}{
+		"status":      status,
 		"readerCount": len(readers),
+		"pcsc":        pcsc,
+	}
+
+	// The startup self-check also reports a missing reader as degraded
+	if check := core.GetSelfCheckStatus(); check.Enabled {
+		if check.CheckedAt != nil && !check.OK {
+			health["status"] = "degraded"
+		}
+		health["selfCheck"] = check
+	}
+
+	return health
+}
+
+// operationDuration describes how long a card operation is expected to take.
+// Clients can use MaxMs to pick request timeouts.
+type operationDuration struct {
+	TypicalMs int `json:"typicalMs"`
+	MaxMs     int `json:"maxMs"`
+}
+
+// operationDurations lists expected durations per operation, keyed by the
+// WebSocket message type. Values are conservative estimates across supported readers.
+var operationDurations = map[string]operationDuration{
+	"read_card":                   {TypicalMs: 300, MaxMs: 3000},
+	"list_cards":                  {TypicalMs: 300, MaxMs: 3000},
+	"read_ntag_config":            {TypicalMs: 300, MaxMs: 3000},
+	"write_card":                  {TypicalMs: 500, MaxMs: 5000},
+	"write_records":               {TypicalMs: 500, MaxMs: 5000},
+	"write_raw_ndef":              {TypicalMs: 500, MaxMs: 5000},
+	"read_raw_ndef":               {TypicalMs: 300, MaxMs: 3000},
+	"erase_card":                  {TypicalMs: 200, MaxMs: 2000},
+	"lock_card":                   {TypicalMs: 200, MaxMs: 2000},
+	"set_password":                {TypicalMs: 200, MaxMs: 2000},
+	"remove_password":             {TypicalMs: 200, MaxMs: 2000},
+	"read_mifare_block":           {TypicalMs: 100, MaxMs: 1000},
+	"write_mifare_block":          {TypicalMs: 100, MaxMs: 1000},
+	"read_mifare_blocks":          {TypicalMs: 1000, MaxMs: 10000},
+	"write_mifare_blocks":         {TypicalMs: 1000, MaxMs: 10000},
+	"read_ultralight_page":        {TypicalMs: 100, MaxMs: 1000},
+	"write_ultralight_page":       {TypicalMs: 100, MaxMs: 1000},
+	"write_ultralight_pages":      {TypicalMs: 500, MaxMs: 5000},
+	"derive_uid_key_aes":          {TypicalMs: 100, MaxMs: 1000},
+	"aes_encrypt_and_write_block": {TypicalMs: 150, MaxMs: 1500},
+	"write_mifare_sector_trailer": {TypicalMs: 150, MaxMs: 1500},
+	"increment_counter":           {TypicalMs: 200, MaxMs: 2000},
+	"test_write":                  {TypicalMs: 300, MaxMs: 3000},
+}
+
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"operations":               operationDurations,
+		"slowOperationThresholdMs": core.SlowOperationThreshold.Milliseconds(),
+	})
+}
+
+// handleStats returns observed card operation latency per operation and card type.
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"operations":   logging.LatencyStats(),
+		"sampleWindow": logging.MaxLatencySamples,
 	})
 }
 
@@ -616,6 +1903,76 @@ func handleShutdown(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
+// respondCardError writes a card operation error. A busy reader is reported as
+// 409 with code READER_BUSY so clients can ask the user to close the other app;
+// a reader claimed by another client is 409 with code READER_CLAIMED. When the
+// agent is overloaded it is 503 with code TOO_MANY_OPERATIONS and Retry-After.
+func respondCardError(w http.ResponseWriter, status int, err error) {
+	respondCardErrorLog(w, status, err, nil)
+}
+
+// respondCardErrorLog is respondCardError with the request's APDU trace, if
+// it asked for one, in debugLog.
+func respondCardErrorLog(w http.ResponseWriter, status int, err error, debugLog []string) {
+	status, code := cardErrorStatus(status, err)
+	setRetryAfter(w, code)
+	response := map[string]any{
+		"error": err.Error(),
+	}
+	if code != "" {
+		response["code"] = code
+	}
+	if debugLog != nil {
+		response["debugLog"] = debugLog
+	}
+	respondJSON(w, status, response)
+}
+
+// cardErrorStatus maps a card operation error to its HTTP status and error
+// code, falling back to status and no code for errors without one.
+func cardErrorStatus(status int, err error) (int, string) {
+	switch {
+	case errors.Is(err, core.ErrReaderBusy):
+		return http.StatusConflict, "READER_BUSY"
+	case errors.Is(err, errReaderClaimed):
+		return http.StatusConflict, "READER_CLAIMED"
+	case errors.Is(err, core.ErrPCSCUnavailable):
+		return http.StatusServiceUnavailable, "PCSC_UNAVAILABLE"
+	case errors.Is(err, core.ErrWriteProtected):
+		return http.StatusForbidden, "WRITE_PROTECTED"
+	case errors.Is(err, core.ErrNDEFReadOnly):
+		return http.StatusForbidden, "NDEF_READ_ONLY"
+	case errors.Is(err, core.ErrPasswordRequired):
+		return http.StatusUnauthorized, "PASSWORD_REQUIRED"
+	case errors.Is(err, core.ErrBlockReadRejected):
+		return http.StatusForbidden, "READ_REJECTED"
+	case errors.Is(err, core.ErrTooManyOperations):
+		return http.StatusServiceUnavailable, "TOO_MANY_OPERATIONS"
+	case errors.Is(err, core.ErrUIDMismatch):
+		return http.StatusConflict, "UID_MISMATCH"
+	case errors.Is(err, core.ErrMADAuthFailed):
+		return http.StatusForbidden, "MAD_AUTH_FAILED"
+	case errors.Is(err, errIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED"
+	case errors.Is(err, core.ErrInvalidLockPages):
+		return http.StatusBadRequest, "INVALID_LOCK_PAGES"
+	case errors.Is(err, core.ErrNDEFIncomplete):
+		return http.StatusConflict, "NDEF_INCOMPLETE"
+	}
+	return status, ""
+}
+
+// operationRetryAfter is the Retry-After value, in seconds, sent when the
+// concurrent card operation queue is full.
+const operationRetryAfter = "1"
+
+// setRetryAfter tells the client when to retry a rejected operation.
+func setRetryAfter(w http.ResponseWriter, code string) {
+	if code == "TOO_MANY_OPERATIONS" {
+		w.Header().Set("Retry-After", operationRetryAfter)
+	}
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -766,55 +2123,174 @@ func handleLogs(w http.ResponseWriter, r *http.Request) {
 			"success": "logs cleared",
 		})
 
-	default:
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func handleCrashes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+
+		// Check if requesting a specific crash log
+		filename := query.Get("file")
+		if filename != "" {
+			content, err := logging.ReadCrashLog(filename)
+			if err != nil {
+				respondJSON(w, http.StatusNotFound, map[string]string{
+					"error": "crash log not found: " + err.Error(),
+				})
+				return
+			}
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"filename": filename,
+				"content":  content,
+			})
+			return
+		}
+
+		// List crash logs
+		limit := 20
+		if limitStr := query.Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+				limit = l
+				if limit > 100 {
+					limit = 100
+				}
+			}
+		}
+
+		logs, err := logging.GetCrashLogs(limit)
+		if err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{
+				"error": "failed to list crash logs: " + err.Error(),
+			})
+			return
+		}
+
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"crashes":  logs,
+			"crashDir": logging.CrashLogDir(),
+		})
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// apiToken returns the local API token. Overridden in tests.
+var apiToken = settings.APIToken
+
+// requireAPIToken checks the request's "Authorization: Bearer <token>" header
+// against the local API token. On failure it writes a 401 and returns false.
+func requireAPIToken(w http.ResponseWriter, r *http.Request) bool {
+	token, err := apiToken()
+	if err != nil {
+		logging.Error(logging.CatHTTP, "Failed to load API token", map[string]any{
+			"error": err.Error(),
+		})
+		respondJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "API token unavailable",
+		})
+		return false
+	}
+
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		respondJSON(w, http.StatusUnauthorized, map[string]string{
+			"error": "missing or invalid API token",
+		})
+		return false
 	}
+	return true
 }
 
-func handleCrashes(w http.ResponseWriter, r *http.Request) {
+// handleKeyProfiles manages stored MIFARE key profiles. All methods require
+// the API token; key material is never returned.
+// GET /v1/keys - List profile names
+// POST /v1/keys/{name} - Store {"keyA": "hex", "keyB": "hex", "password": "hex"} (any may be omitted)
+// DELETE /v1/keys/{name} - Remove a profile
+func handleKeyProfiles(w http.ResponseWriter, r *http.Request) {
+	if !requireAPIToken(w, r) {
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/keys"), "/")
+
 	switch r.Method {
 	case http.MethodGet:
-		query := r.URL.Query()
+		if name != "" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"profiles": settings.KeyProfileNames(),
+		})
 
-		// Check if requesting a specific crash log
-		filename := query.Get("file")
-		if filename != "" {
-			content, err := logging.ReadCrashLog(filename)
-			if err != nil {
-				respondJSON(w, http.StatusNotFound, map[string]string{
-					"error": "crash log not found: " + err.Error(),
-				})
-				return
-			}
-			respondJSON(w, http.StatusOK, map[string]interface{}{
-				"filename": filename,
-				"content":  content,
+	case http.MethodPost:
+		var req struct {
+			KeyA     string `json:"keyA"`     // Optional, hex string, 12 chars = 6 bytes
+			KeyB     string `json:"keyB"`     // Optional, hex string, 12 chars = 6 bytes
+			Password string `json:"password"` // Optional, Ultralight EV1/NTAG password, 8 hex chars = 4 bytes
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "invalid JSON body",
 			})
 			return
 		}
 
-		// List crash logs
-		limit := 20
-		if limitStr := query.Get("limit"); limitStr != "" {
-			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-				limit = l
-				if limit > 100 {
-					limit = 100
-				}
-			}
+		keyA, errA := parseMifareKey(req.KeyA)
+		keyB, errB := parseMifareKey(req.KeyB)
+		password, errP := parseUltralightPassword(req.Password)
+		if err := errors.Join(errA, errB, errP); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
 		}
 
-		logs, err := logging.GetCrashLogs(limit)
-		if err != nil {
+		profile := settings.KeyProfile{KeyA: keyA, KeyB: keyB, Password: password}
+		if err := settings.ValidateKeyProfile(name, profile); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err := settings.SetKeyProfile(name, profile); err != nil {
 			respondJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": "failed to list crash logs: " + err.Error(),
+				"error": fmt.Sprintf("failed to save key profile: %v", err),
 			})
 			return
 		}
 
+		logging.Info(logging.CatHTTP, "Key profile stored", map[string]any{
+			"profile": name,
+		})
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"crashes":  logs,
-			"crashDir": logging.CrashLogDir(),
+			"name":     name,
+			"keyA":     keyA != nil,
+			"keyB":     keyB != nil,
+			"password": password != nil,
+		})
+
+	case http.MethodDelete:
+		if err := settings.DeleteKeyProfile(name); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, settings.ErrKeyProfileNotFound) {
+				status = http.StatusNotFound
+			}
+			respondJSON(w, status, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		logging.Info(logging.CatHTTP, "Key profile deleted", map[string]any{
+			"profile": name,
+		})
+		respondJSON(w, http.StatusOK, map[string]string{
+			"success": "key profile deleted",
 		})
 
 	default:
@@ -828,12 +2304,20 @@ func handleSettings(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s := settings.Get()
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"crashReporting": s.CrashReporting,
+			"crashReporting":    s.CrashReporting,
+			"scrubCrashReports": !s.DisableCrashScrubbing,
+			"scrubSensitive":    s.ScrubSensitive,
+
+			"ultralightPasswordProfile": s.UltralightPasswordProfile,
 		})
 
 	case http.MethodPost:
 		var req struct {
-			CrashReporting *bool `json:"crashReporting"`
+			CrashReporting    *bool `json:"crashReporting"`
+			ScrubCrashReports *bool `json:"scrubCrashReports"`
+			ScrubSensitive    *bool `json:"scrubSensitive"`
+
+			UltralightPasswordProfile *string `json:"ultralightPasswordProfile"` // Key profile name, "" to clear
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			respondJSON(w, http.StatusBadRequest, map[string]string{
@@ -841,6 +2325,11 @@ func handleSettings(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		// Pointing reads at a key profile uses its password, so it needs the
+		// same token as managing profiles; check before changing anything
+		if req.UltralightPasswordProfile != nil && !requireAPIToken(w, r) {
+			return
+		}
 
 		if req.CrashReporting != nil {
 			if err := settings.SetCrashReporting(*req.CrashReporting); err != nil {
@@ -851,10 +2340,42 @@ func handleSettings(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if req.ScrubCrashReports != nil || req.ScrubSensitive != nil {
+			s := settings.Get()
+			scrubCrash, scrubSensitive := !s.DisableCrashScrubbing, s.ScrubSensitive
+			if req.ScrubCrashReports != nil {
+				scrubCrash = *req.ScrubCrashReports
+			}
+			if req.ScrubSensitive != nil {
+				scrubSensitive = *req.ScrubSensitive
+			}
+			if err := settings.SetScrubbing(scrubCrash, scrubSensitive); err != nil {
+				respondJSON(w, http.StatusInternalServerError, map[string]string{
+					"error": "failed to save settings: " + err.Error(),
+				})
+				return
+			}
+			logging.SetScrubCrashReports(scrubCrash)
+			logging.SetScrubSensitive(scrubSensitive)
+		}
+
+		if req.UltralightPasswordProfile != nil {
+			if err := settings.SetUltralightPasswordProfile(*req.UltralightPasswordProfile); err != nil {
+				respondJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "invalid ultralightPasswordProfile: " + err.Error(),
+				})
+				return
+			}
+		}
+
 		s := settings.Get()
 		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"crashReporting": s.CrashReporting,
-			"message":        "Settings updated. Restart may be required for some changes to take effect.",
+			"crashReporting":    s.CrashReporting,
+			"scrubCrashReports": !s.DisableCrashScrubbing,
+			"scrubSensitive":    s.ScrubSensitive,
+			"message":           "Settings updated. Restart may be required for some changes to take effect.",
+
+			"ultralightPasswordProfile": s.UltralightPasswordProfile,
 		})
 
 	default:
@@ -904,6 +2425,137 @@ func parseMifareKeyType(kt string) byte {
 	return 'A'
 }
 
+// errKeyProfileUnauthorized is returned when a request without the API token
+// names a key profile.
+var errKeyProfileUnauthorized = errors.New("key profiles require the API token")
+
+// resolveMifareKey returns the key and key type for a MIFARE operation, either
+// from an inline hex key or from a stored key profile. With a profile, keyType
+// picks its key A or B; if empty, key A is used when the profile has one.
+// Profiles are only used for authenticated requests, since their keys are as
+// sensitive as the profiles themselves.
+func resolveMifareKey(keyHex, keyType, profile string, authenticated bool) ([]byte, byte, error) {
+	if profile == "" {
+		key, err := parseMifareKey(keyHex)
+		return key, parseMifareKeyType(keyType), err
+	}
+	if keyHex != "" {
+		return nil, 0, fmt.Errorf("key and key profile are mutually exclusive")
+	}
+	if !authenticated {
+		return nil, 0, errKeyProfileUnauthorized
+	}
+
+	p, err := settings.GetKeyProfile(profile)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case keyType == "" && p.KeyA == nil:
+		return p.KeyB, 'B', nil
+	case parseMifareKeyType(keyType) == 'B':
+		if p.KeyB == nil {
+			return nil, 0, fmt.Errorf("key profile %s has no key B", profile)
+		}
+		return p.KeyB, 'B', nil
+	default:
+		if p.KeyA == nil {
+			return nil, 0, fmt.Errorf("key profile %s has no key A", profile)
+		}
+		return p.KeyA, 'A', nil
+	}
+}
+
+// mifareKeyErrorStatus is the HTTP status for a resolveMifareKey error.
+func mifareKeyErrorStatus(err error) int {
+	if errors.Is(err, errKeyProfileUnauthorized) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
+
+// mifareBlockResponse builds the response for a block read. With decode
+// "value" the block is also decoded as a value block; valueBlock reports
+// whether it held one.
+func mifareBlockResponse(block int, data []byte, decode string) map[string]interface{} {
+	resp := map[string]interface{}{
+		"block": block,
+		"data":  hex.EncodeToString(data),
+	}
+	if decode != "value" {
+		return resp
+	}
+
+	value, err := core.DecodeMifareValue(data)
+	if err != nil {
+		resp["valueBlock"] = false
+		resp["decodeError"] = err.Error()
+		return resp
+	}
+	resp["valueBlock"] = true
+	resp["value"] = value.Value
+	resp["address"] = value.Address
+	return resp
+}
+
+// handleMifareRange reads a contiguous MIFARE Classic block range
+// GET /v1/readers/{n}/mifare?start=4&count=12 - Optional query params: key (hex), keyType (A/B)
+func handleMifareRange(w http.ResponseWriter, r *http.Request, readerName string) {
+	query := r.URL.Query()
+	start, err := strconv.Atoi(query.Get("start"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "start must be a block number",
+		})
+		return
+	}
+	count := 1
+	if v := query.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "count must be a number",
+			})
+			return
+		}
+	}
+
+	key, keyType, err := resolveMifareKey(query.Get("key"), query.Get("keyType"), query.Get("keyProfile"), requestAuthenticated(r))
+	if err != nil {
+		respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	data, err := core.ReadMifareBlocks(readerName, start, count, key, keyType)
+	if err != nil {
+		logging.Debug(logging.CatHTTP, "MIFARE range read failed", map[string]any{
+			"reader": readerName,
+			"start":  start,
+			"count":  count,
+			"error":  err.Error(),
+		})
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	blocks := make([]map[string]interface{}, len(data))
+	for i, d := range data {
+		blocks[i] = map[string]interface{}{"block": start + i}
+		if d == nil {
+			blocks[i]["trailer"] = true
+			continue
+		}
+		blocks[i]["data"] = hex.EncodeToString(d)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"start":  start,
+		"count":  count,
+		"blocks": blocks,
+	})
+}
+
 // handleMifareBlock handles read/write operations on MIFARE Classic blocks
 // GET /v1/readers/{n}/mifare/{block} - Read block
 // POST /v1/readers/{n}/mifare/{block} - Write block
@@ -911,11 +2563,18 @@ func parseMifareKeyType(kt string) byte {
 // POST /v1/readers/{n}/mifare/derive-key - Derive key from UID via AES
 // POST /v1/readers/{n}/mifare/aes-write/{block} - AES encrypt and write block
 // POST /v1/readers/{n}/mifare/sector-trailer/{block} - Write sector trailer with keys and access bits
+// GET /v1/readers/{n}/mifare/mad - Read the MIFARE Application Directory
 func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	// GET /v1/readers/{n}/mifare?start=&count= reads a block range
+	if (len(parts) < 5 || parts[4] == "") && r.Method == http.MethodGet && r.URL.Query().Get("start") != "" {
+		handleMifareRange(w, r, readerName)
+		return
+	}
+
 	// Expect path: /v1/readers/{n}/mifare/{block or operation}
 	if len(parts) < 5 {
 		respondJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "missing block number or operation (use /mifare/{block}, /mifare/batch, /mifare/derive-key, /mifare/aes-write/{block}, or /mifare/sector-trailer/{block})",
+			"error": "missing block number or operation (use /mifare/{block}, /mifare/batch, /mifare/derive-key, /mifare/aes-write/{block}, /mifare/sector-trailer/{block}, or /mifare/mad)",
 		})
 		return
 	}
@@ -934,6 +2593,9 @@ func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string
 	case "sector-trailer":
 		handleMifareSectorTrailer(w, r, readerName, parts)
 		return
+	case "mad":
+		handleMifareMAD(w, r, readerName)
+		return
 	}
 
 	blockNum, err := strconv.Atoi(parts[4])
@@ -947,15 +2609,21 @@ func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string
 	switch r.Method {
 	case http.MethodGet:
 		// Read block
-		// Optional query params: key (hex), keyType (A/B)
-		key, err := parseMifareKey(r.URL.Query().Get("key"))
-		if err != nil {
+		// Optional query params: key (hex), keyType (A/B), decode (value)
+		decode := r.URL.Query().Get("decode")
+		if decode != "" && decode != "value" {
 			respondJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported decode: %s", decode),
+			})
+			return
+		}
+		key, keyType, err := resolveMifareKey(r.URL.Query().Get("key"), r.URL.Query().Get("keyType"), r.URL.Query().Get("keyProfile"), requestAuthenticated(r))
+		if err != nil {
+			respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
 				"error": err.Error(),
 			})
 			return
 		}
-		keyType := parseMifareKeyType(r.URL.Query().Get("keyType"))
 
 		data, err := core.ReadMifareBlock(readerName, blockNum, key, keyType)
 		if err != nil {
@@ -964,23 +2632,19 @@ func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string
 				"block":  blockNum,
 				"error":  err.Error(),
 			})
-			respondJSON(w, http.StatusBadRequest, map[string]string{
-				"error": err.Error(),
-			})
+			respondCardError(w, http.StatusBadRequest, err)
 			return
 		}
 
-		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"block": blockNum,
-			"data":  hex.EncodeToString(data),
-		})
+		respondJSON(w, http.StatusOK, mifareBlockResponse(blockNum, data, decode))
 
 	case http.MethodPost:
 		// Write block
 		var req struct {
-			Data    string `json:"data"`    // Hex string, 32 chars = 16 bytes
-			Key     string `json:"key"`     // Optional, hex string, 12 chars = 6 bytes
-			KeyType string `json:"keyType"` // Optional, "A" or "B"
+			Data       string `json:"data"`       // Hex string, 32 chars = 16 bytes
+			Key        string `json:"key"`        // Optional, hex string, 12 chars = 6 bytes
+			KeyType    string `json:"keyType"`    // Optional, "A" or "B"
+			KeyProfile string `json:"keyProfile"` // Optional, stored key profile name instead of key
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1000,14 +2664,13 @@ func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string
 		}
 
 		// Parse optional key
-		key, err := parseMifareKey(req.Key)
+		key, keyType, err := resolveMifareKey(req.Key, req.KeyType, req.KeyProfile, requestAuthenticated(r))
 		if err != nil {
-			respondJSON(w, http.StatusBadRequest, map[string]string{
+			respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
 				"error": err.Error(),
 			})
 			return
 		}
-		keyType := parseMifareKeyType(req.KeyType)
 
 		if err := core.WriteMifareBlock(readerName, blockNum, data, key, keyType); err != nil {
 			logging.Debug(logging.CatHTTP, "MIFARE write failed", map[string]any{
@@ -1015,9 +2678,7 @@ func handleMifareBlock(w http.ResponseWriter, r *http.Request, readerName string
 				"block":  blockNum,
 				"error":  err.Error(),
 			})
-			respondJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": err.Error(),
-			})
+			respondCardError(w, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -1073,7 +2734,7 @@ func handleUltralightPage(w http.ResponseWriter, r *http.Request, readerName str
 				"page":   pageNum,
 				"error":  err.Error(),
 			})
-			respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			respondCardError(w, http.StatusBadRequest, err)
 			return
 		}
 
@@ -1114,7 +2775,7 @@ func handleUltralightPage(w http.ResponseWriter, r *http.Request, readerName str
 				"page":   pageNum,
 				"error":  err.Error(),
 			})
-			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			respondCardError(w, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -1137,6 +2798,89 @@ func parseUltralightPassword(pwdHex string) ([]byte, error) {
 	return pwd, nil
 }
 
+// handleCounter increments a software counter stored in a user memory page of
+// an NTAG or MIFARE Ultralight
+// POST /v1/readers/{n}/counter/{page} - optional body: {"password": "hex"}
+func handleCounter(w http.ResponseWriter, r *http.Request, readerName string, parts []string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(parts) < 5 {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "missing page number (use /counter/{page})",
+		})
+		return
+	}
+
+	pageNum, err := strconv.Atoi(parts[4])
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid page number",
+		})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"` // Optional, hex string, 8 chars = 4 bytes
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	password, err := parseUltralightPassword(req.Password)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	value, err := core.IncrementTagCounter(readerName, pageNum, password)
+	if err != nil {
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"page":  pageNum,
+		"value": value,
+	})
+}
+
+// handleTestWrite checks that the card can be written without changing it
+// POST /v1/readers/{n}/test-write - writes, verifies and restores a scratch page
+func handleTestWrite(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := core.TestWrite(readerName)
+	if err != nil && (result == nil || errors.Is(err, core.ErrReaderBusy)) {
+		respondCardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":  err == nil,
+		"page":     result.Page,
+		"method":   result.Method,
+		"restored": result.Restored,
+	}
+	if err != nil {
+		logging.Warn(logging.CatHTTP, "Write test failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		response["error"] = err.Error()
+		respondJSON(w, http.StatusInternalServerError, response)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 // handleUltralightBatch handles batch write operations on MIFARE Ultralight pages
 // POST /v1/readers/{n}/ultralight/batch - Write multiple pages in a single card session
 func handleUltralightBatch(w http.ResponseWriter, r *http.Request, readerName string) {
@@ -1150,7 +2894,10 @@ func handleUltralightBatch(w http.ResponseWriter, r *http.Request, readerName st
 			Page int    `json:"page"`
 			Data string `json:"data"` // Hex string, 8 chars = 4 bytes
 		} `json:"pages"`
-		Password string `json:"password"` // Optional, hex string, 8 chars = 4 bytes
+		Password        string `json:"password"`        // Optional, hex string, 8 chars = 4 bytes
+		RollbackOnError bool   `json:"rollbackOnError"` // Restore written pages if a later page fails
+		Verify          bool   `json:"verify"`          // Read each page back and fail it on a mismatch
+		ExpectUID       string `json:"expectUID"`       // Abort with UID_MISMATCH unless the card has this UID
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1185,13 +2932,13 @@ func handleUltralightBatch(w http.ResponseWriter, r *http.Request, readerName st
 		return
 	}
 
-	results, err := core.WriteUltralightPages(readerName, pages, password)
+	results, rollback, err := core.WriteUltralightPagesContext(r.Context(), readerName, pages, password, req.RollbackOnError, req.Verify, req.ExpectUID)
 	if err != nil {
 		logging.Debug(logging.CatHTTP, "Ultralight batch write failed", map[string]any{
 			"reader": readerName,
 			"error":  err.Error(),
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondCardError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1203,11 +2950,45 @@ func handleUltralightBatch(w http.ResponseWriter, r *http.Request, readerName st
 		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"results": results,
 		"written": successCount,
 		"total":   len(results),
-	})
+	}
+	if rollback != nil {
+		response["rollback"] = rollback
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleMifareMAD decodes the MIFARE Application Directory
+// GET /v1/readers/{n}/mifare/mad?key=&keyProfile= (key defaults to the public MAD key)
+func handleMifareMAD(w http.ResponseWriter, r *http.Request, readerName string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	key, _, err := resolveMifareKey(r.URL.Query().Get("key"), "A", r.URL.Query().Get("keyProfile"), requestAuthenticated(r))
+	if err != nil {
+		respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	mad, err := core.ReadMAD(readerName, key)
+	if errors.Is(err, core.ErrNoMAD) {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondCardError(w, http.StatusBadRequest, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, mad)
 }
 
 // handleMifareBatch handles batch write operations on MIFARE Classic blocks
@@ -1223,8 +3004,10 @@ func handleMifareBatch(w http.ResponseWriter, r *http.Request, readerName string
 			Block int    `json:"block"`
 			Data  string `json:"data"` // Hex string, 32 chars = 16 bytes
 		} `json:"blocks"`
-		Key     string `json:"key"`     // Hex string, 12 chars = 6 bytes
-		KeyType string `json:"keyType"` // "A" or "B"
+		Key        string `json:"key"`        // Hex string, 12 chars = 6 bytes
+		KeyType    string `json:"keyType"`    // "A" or "B"
+		KeyProfile string `json:"keyProfile"` // Optional, stored key profile name instead of key
+		ExpectUID  string `json:"expectUID"`  // Abort with UID_MISMATCH unless the card has this UID
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1253,20 +3036,19 @@ func handleMifareBatch(w http.ResponseWriter, r *http.Request, readerName string
 		}
 	}
 
-	key, err := parseMifareKey(req.Key)
+	key, keyType, err := resolveMifareKey(req.Key, req.KeyType, req.KeyProfile, requestAuthenticated(r))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		respondJSON(w, mifareKeyErrorStatus(err), map[string]string{"error": err.Error()})
 		return
 	}
-	keyType := parseMifareKeyType(req.KeyType)
 
-	results, err := core.WriteMifareBlocks(readerName, blocks, key, keyType)
+	results, err := core.WriteMifareBlocksContext(r.Context(), readerName, blocks, key, keyType, req.ExpectUID)
 	if err != nil {
 		logging.Debug(logging.CatHTTP, "MIFARE batch write failed", map[string]any{
 			"reader": readerName,
 			"error":  err.Error(),
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		respondCardError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1318,9 +3100,7 @@ func handleMifareDeriveKey(w http.ResponseWriter, r *http.Request, readerName st
 			"reader": readerName,
 			"error":  err.Error(),
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		respondCardError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1354,10 +3134,11 @@ func handleMifareAESWrite(w http.ResponseWriter, r *http.Request, readerName str
 	}
 
 	var req struct {
-		Data        string `json:"data"`        // Hex string, 32 chars = 16 bytes
-		AESKey      string `json:"aesKey"`      // Hex string, 32 chars = 16 bytes
-		AuthKey     string `json:"authKey"`     // Hex string, 12 chars = 6 bytes
-		AuthKeyType string `json:"authKeyType"` // "A" or "B"
+		Data           string `json:"data"`           // Hex string, 32 chars = 16 bytes
+		AESKey         string `json:"aesKey"`         // Hex string, 32 chars = 16 bytes
+		AuthKey        string `json:"authKey"`        // Hex string, 12 chars = 6 bytes
+		AuthKeyType    string `json:"authKeyType"`    // "A" or "B"
+		AuthKeyProfile string `json:"authKeyProfile"` // Optional, stored key profile name instead of authKey
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1383,14 +3164,13 @@ func handleMifareAESWrite(w http.ResponseWriter, r *http.Request, readerName str
 		return
 	}
 
-	authKey, err := parseMifareKey(req.AuthKey)
+	authKey, authKeyType, err := resolveMifareKey(req.AuthKey, req.AuthKeyType, req.AuthKeyProfile, requestAuthenticated(r))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]string{
+		respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
 			"error": err.Error(),
 		})
 		return
 	}
-	authKeyType := parseMifareKeyType(req.AuthKeyType)
 
 	if err := core.AESEncryptAndWriteBlock(readerName, blockNum, data, aesKey, authKey, authKeyType); err != nil {
 		logging.Debug(logging.CatHTTP, "MIFARE AES write failed", map[string]any{
@@ -1398,9 +3178,7 @@ func handleMifareAESWrite(w http.ResponseWriter, r *http.Request, readerName str
 			"block":  blockNum,
 			"error":  err.Error(),
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		respondCardError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1435,11 +3213,12 @@ func handleMifareSectorTrailer(w http.ResponseWriter, r *http.Request, readerNam
 	}
 
 	var req struct {
-		KeyA        string `json:"keyA"`        // New Key A, 12 hex chars = 6 bytes
-		KeyB        string `json:"keyB"`        // New Key B, 12 hex chars = 6 bytes
-		AccessBits  string `json:"accessBits"`  // Access bits, 6 or 8 hex chars (optional, preserves existing if empty)
-		AuthKey     string `json:"authKey"`     // Key for authentication, 12 hex chars = 6 bytes
-		AuthKeyType string `json:"authKeyType"` // "A" or "B"
+		KeyA           string `json:"keyA"`           // New Key A, 12 hex chars = 6 bytes
+		KeyB           string `json:"keyB"`           // New Key B, 12 hex chars = 6 bytes
+		AccessBits     string `json:"accessBits"`     // Access bits, 6 or 8 hex chars (optional, preserves existing if empty)
+		AuthKey        string `json:"authKey"`        // Key for authentication, 12 hex chars = 6 bytes
+		AuthKeyType    string `json:"authKeyType"`    // "A" or "B"
+		AuthKeyProfile string `json:"authKeyProfile"` // Optional, stored key profile name instead of authKey
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1477,14 +3256,13 @@ func handleMifareSectorTrailer(w http.ResponseWriter, r *http.Request, readerNam
 		}
 	}
 
-	authKey, err := parseMifareKey(req.AuthKey)
+	authKey, authKeyType, err := resolveMifareKey(req.AuthKey, req.AuthKeyType, req.AuthKeyProfile, requestAuthenticated(r))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]string{
+		respondJSON(w, mifareKeyErrorStatus(err), map[string]string{
 			"error": err.Error(),
 		})
 		return
 	}
-	authKeyType := parseMifareKeyType(req.AuthKeyType)
 
 	if err := core.WriteSectorTrailer(readerName, blockNum, keyA, keyB, accessBits, authKey, authKeyType); err != nil {
 		logging.Debug(logging.CatHTTP, "MIFARE update trailer failed", map[string]any{
@@ -1492,9 +3270,7 @@ func handleMifareSectorTrailer(w http.ResponseWriter, r *http.Request, readerNam
 			"block":  blockNum,
 			"error":  err.Error(),
 		})
-		respondJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		respondCardError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -2,10 +2,19 @@ package api
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/data"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
 )
 
 func TestHandleVersion(t *testing.T) {
@@ -68,7 +77,16 @@ func TestHandleVersion_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+// stubPCSCStatus makes healthStatus see the given PC/SC state for the test.
+func stubPCSCStatus(t *testing.T, status core.PCSCStatus) {
+	t.Helper()
+	orig := pcscStatus
+	pcscStatus = func() core.PCSCStatus { return status }
+	t.Cleanup(func() { pcscStatus = orig })
+}
+
 func TestHandleHealth(t *testing.T) {
+	stubPCSCStatus(t, core.PCSCStatus{Available: true})
 	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
 	w := httptest.NewRecorder()
 
@@ -93,6 +111,15 @@ func TestHandleHealth(t *testing.T) {
 	}
 }
 
+func TestHandleHealth_PCSCLost(t *testing.T) {
+	since := time.Now()
+	stubPCSCStatus(t, core.PCSCStatus{Since: &since, Error: "service stopped"})
+
+	if status := healthStatus()["status"]; status != "degraded" {
+		t.Errorf("expected status 'degraded' while PC/SC is lost, got %v", status)
+	}
+}
+
 func TestHandleHealth_MethodNotAllowed(t *testing.T) {
 	methods := []string{http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
 
@@ -110,6 +137,54 @@ func TestHandleHealth_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+func TestHandleReady(t *testing.T) {
+	origMux, origHub, origPCSC := muxBuilt.Load(), hubRunning.Load(), pcscReady
+	defer func() {
+		muxBuilt.Store(origMux)
+		hubRunning.Store(origHub)
+		pcscReady = origPCSC
+	}()
+
+	tests := []struct {
+		name       string
+		mux, hub   bool
+		pcsc       bool
+		wantStatus int
+	}{
+		{"ready", true, true, true, http.StatusOK},
+		{"mux not built", false, true, true, http.StatusServiceUnavailable},
+		{"hub not running", true, false, true, http.StatusServiceUnavailable},
+		{"pcsc never established", true, true, false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			muxBuilt.Store(tt.mux)
+			hubRunning.Store(tt.hub)
+			pcscReady = func() bool { return tt.pcsc }
+
+			w := httptest.NewRecorder()
+			handleReady(w, httptest.NewRequest(http.MethodGet, "/v1/ready", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			var result struct {
+				Status string          `json:"status"`
+				Checks map[string]bool `json:"checks"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if want := tt.wantStatus == http.StatusOK; (result.Status == "ready") != want {
+				t.Errorf("unexpected status %q", result.Status)
+			}
+			if result.Checks["pcsc"] != tt.pcsc || result.Checks["websocket"] != tt.hub {
+				t.Errorf("unexpected checks %v", result.Checks)
+			}
+		})
+	}
+}
+
 func TestCORSMiddleware(t *testing.T) {
 	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -144,10 +219,10 @@ func TestCORSMiddleware(t *testing.T) {
 				if w.Header().Get("Access-Control-Allow-Origin") != "*" {
 					t.Error("expected Access-Control-Allow-Origin header to be '*'")
 				}
-				if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, DELETE, OPTIONS" {
+				if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PATCH, DELETE, OPTIONS" {
 					t.Error("expected Access-Control-Allow-Methods header")
 				}
-				if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" {
+				if w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization, X-Reader-Claim" {
 					t.Error("expected Access-Control-Allow-Headers header")
 				}
 			}
@@ -178,6 +253,101 @@ func TestCORSMiddleware_PreflightResponse(t *testing.T) {
 	}
 }
 
+func TestCORSMiddleware_PreflightMaxAge(t *testing.T) {
+	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodOptions, "/test", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "7200" {
+		t.Errorf("expected default Access-Control-Max-Age 7200, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("expected no Access-Control-Max-Age on a non-preflight response, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_AllowedOrigins(t *testing.T) {
+	CORSAllowedOrigins = []string{"https://app.example.com"}
+	t.Cleanup(func() { CORSAllowedOrigins = nil })
+	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodOptions, "/v1/readers/0/openprinttag/aux", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-custom")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("expected the allowed origin to be echoed, got %q", h.Get("Access-Control-Allow-Origin"))
+	}
+	if h.Get("Access-Control-Allow-Methods") != "PATCH" || h.Get("Access-Control-Allow-Headers") != "content-type, x-custom" {
+		t.Errorf("expected requested method and headers to be reflected, got %q and %q",
+			h.Get("Access-Control-Allow-Methods"), h.Get("Access-Control-Allow-Headers"))
+	}
+	if h.Get("Access-Control-Max-Age") == "" || h.Values("Vary")[0] != "Origin" {
+		t.Errorf("expected Max-Age and Vary headers, got %v", h)
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/v1/readers", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin for other origins, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("expected no Access-Control-Max-Age for other origins, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_RefusesOtherOrigins(t *testing.T) {
+	CORSAllowedOrigins = []string{"https://app.example.com"}
+	t.Cleanup(func() { CORSAllowedOrigins = nil })
+	called := false
+	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		allowed bool
+	}{
+		{"allowed origin", http.MethodPost, "https://app.example.com", true},
+		{"other origin", http.MethodPost, "https://evil.example.com", false},
+		{"other origin read", http.MethodGet, "https://evil.example.com", true},
+		{"no origin", http.MethodDelete, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			req := httptest.NewRequest(tt.method, "/v1/readers/0/card", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			if called != tt.allowed {
+				t.Errorf("handler called = %v, want %v", called, tt.allowed)
+			}
+			if !tt.allowed && (w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "ORIGIN_NOT_ALLOWED")) {
+				t.Errorf("expected 403 ORIGIN_NOT_ALLOWED, got %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/ws", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	if upgrader.CheckOrigin(req) {
+		t.Error("expected WebSocket connections from other origins to be rejected")
+	}
+}
+
 func TestRespondJSON(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -272,6 +442,22 @@ func TestNewMux_RootServesWebUI(t *testing.T) {
 	}
 }
 
+func TestNewAPIMux_NoWebUI(t *testing.T) {
+	mux := NewAPIMux()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("root of the API-only mux should be 404, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("API routes should still be served, got %d", w.Code)
+	}
+}
+
 func TestHandleListReaders(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/v1/readers", nil)
 	w := httptest.NewRecorder()
@@ -446,50 +632,1007 @@ func TestHandleReaderCard_WriteRequest_InvalidJSON(t *testing.T) {
 	}
 }
 
-// Benchmark tests
-func BenchmarkHandleVersion(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		req := httptest.NewRequest(http.MethodGet, "/v1/version", nil)
-		w := httptest.NewRecorder()
-		handleVersion(w, req)
+func TestHandleReaderCard_WriteRequest_Form(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid base64", "data=not*base64&dataType=binary", http.StatusBadRequest},
+		{"invalid force", "data=hello&force=maybe", http.StatusBadRequest},
+		{"unsupported dataType", "data=hello&dataType=xml", http.StatusBadRequest},
+		{"malformed form", "data=%zz", http.StatusBadRequest},
+		{"text not UTF-8", "data=%FF%FE&dataType=text", http.StatusBadRequest},
+		{"URL with a space", "dataType=url&data=https%3A%2F%2Fexample.com%2Fa+b", http.StatusBadRequest},
+		{"invalid encode", "dataType=url&data=https%3A%2F%2Fexample.com&encode=maybe", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/card", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+			w := httptest.NewRecorder()
+
+			handleReaderCard(w, req, "Test Reader")
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
 	}
 }
 
-func BenchmarkHandleHealth(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
-		w := httptest.NewRecorder()
-		handleHealth(w, req)
+func TestHandleReaderCard_WriteRequest_FormSniffsBinary(t *testing.T) {
+	// Untyped form data that isn't UTF-8 is written as binary, not refused
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/card", strings.NewReader("data=%FF%FE%00%01"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	handleReaderCard(w, req, "Test Reader")
+
+	if w.Code == http.StatusBadRequest {
+		t.Errorf("untyped binary form data was refused: %s", w.Body.String())
 	}
 }
 
-func BenchmarkCORSMiddleware(b *testing.B) {
-	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+func TestHandleReaderCard_InvalidDebug(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodGet, "/v1/readers/0/card?debug=verbose", ""},
+		{http.MethodPost, "/v1/readers/0/card?debug=true", `{"data": "hello"}`},
+	}
 
-	for i := 0; i < b.N; i++ {
-		req := httptest.NewRequest(http.MethodGet, "/test", nil)
-		w := httptest.NewRecorder()
-		handler(w, req)
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handleReaderCard(w, req, "Test Reader")
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
 	}
 }
 
-func BenchmarkRespondJSON(b *testing.B) {
-	data := map[string]interface{}{
-		"key":    "value",
-		"number": 42,
-		"array":  []string{"a", "b", "c"},
+func TestSniffDataType(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"hello", "text"},
+		{"héllo ✓", "text"},
+		{"", "text"},
+		{"\xff\xfe\x00\x01", "binary"},
 	}
 
-	for i := 0; i < b.N; i++ {
-		w := httptest.NewRecorder()
-		respondJSON(w, http.StatusOK, data)
+	for _, tt := range tests {
+		if got := sniffDataType(tt.data); got != tt.want {
+			t.Errorf("sniffDataType(%q) = %q, want %q", tt.data, got, tt.want)
+		}
 	}
 }
 
-func BenchmarkNewMux(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		NewMux()
+func TestIsFormRequest(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/x-www-form-urlencoded", true},
+		{"Application/X-WWW-Form-Urlencoded; charset=utf-8", true},
+		{"application/json", false},
+		{"multipart/form-data; boundary=x", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/card", nil)
+		req.Header.Set("Content-Type", tt.contentType)
+		if got := isFormRequest(req); got != tt.want {
+			t.Errorf("isFormRequest(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestHandleMultipleRecords_OversizedRecordArray(t *testing.T) {
+	records := make([]core.NDEFRecord, core.MaxNDEFRecords+1)
+	for i := range records {
+		records[i] = core.NDEFRecord{Type: "text", Data: "hello"}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"records": records})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/records", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handleMultipleRecords(w, req, "Test Reader")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var result map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result["error"] == "" {
+		t.Error("expected error message in response")
+	}
+}
+
+func TestWriteHandlers_OversizedBody(t *testing.T) {
+	data := strings.Repeat("A", maxWriteBodyBytes)
+	tests := []struct {
+		name        string
+		handler     func(http.ResponseWriter, *http.Request, string)
+		contentType string
+		body        string
+	}{
+		{"records", handleMultipleRecords, "application/json", `{"records": [{"type": "text", "data": "` + data + `"}]}`},
+		{"card JSON", handleReaderCard, "application/json", `{"data": "` + data + `"}`},
+		{"card form", handleReaderCard, "application/x-www-form-urlencoded", "data=" + data},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/card", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			tt.handler(w, req, "Test Reader")
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	logging.ResetLatencyStats()
+	defer logging.ResetLatencyStats()
+	logging.RecordLatency("read_card", "NTAG215", 40*time.Millisecond)
+	logging.RecordLatency("read_card", "ICode SLIX", 300*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/stats", nil)
+	w := httptest.NewRecorder()
+
+	handleStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var result struct {
+		Operations []logging.LatencyStat `json:"operations"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(result.Operations) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(result.Operations))
+	}
+	if result.Operations[0].CardType != "ICode SLIX" || result.Operations[0].P50Ms != 300 {
+		t.Errorf("unexpected first entry: %+v", result.Operations[0])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/stats", nil)
+	w = httptest.NewRecorder()
+	handleStats(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleCapabilities(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/capabilities", nil)
+	w := httptest.NewRecorder()
+
+	handleCapabilities(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var result struct {
+		Operations               map[string]operationDuration `json:"operations"`
+		SlowOperationThresholdMs int64                        `json:"slowOperationThresholdMs"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	for _, op := range []string{"read_card", "write_card", "write_records"} {
+		d, ok := result.Operations[op]
+		if !ok {
+			t.Errorf("expected duration metadata for %s", op)
+			continue
+		}
+		if d.MaxMs < d.TypicalMs {
+			t.Errorf("%s: maxMs %d below typicalMs %d", op, d.MaxMs, d.TypicalMs)
+		}
+	}
+	if result.SlowOperationThresholdMs <= 0 {
+		t.Errorf("expected positive slowOperationThresholdMs, got %d", result.SlowOperationThresholdMs)
+	}
+}
+
+func TestRespondCardError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"reader busy", fmt.Errorf("%w (Test Reader): sharing violation", core.ErrReaderBusy), http.StatusConflict, "READER_BUSY"},
+		{"reader claimed", fmt.Errorf("%w (Test Reader)", errReaderClaimed), http.StatusConflict, "READER_CLAIMED"},
+		{"write protected", core.ErrWriteProtected, http.StatusForbidden, "WRITE_PROTECTED"},
+		{"ndef read-only", core.ErrNDEFReadOnly, http.StatusForbidden, "NDEF_READ_ONLY"},
+		{"read rejected", fmt.Errorf("block 3: %w", core.ErrBlockReadRejected), http.StatusForbidden, "READ_REJECTED"},
+		{"too many operations", fmt.Errorf("%w: 4 running, 16 queued", core.ErrTooManyOperations), http.StatusServiceUnavailable, "TOO_MANY_OPERATIONS"},
+		{"uid mismatch", fmt.Errorf("%w: card has 04b2, expected 04a1", core.ErrUIDMismatch), http.StatusConflict, "UID_MISMATCH"},
+		{"pcsc unavailable", fmt.Errorf("failed to establish context: %w", core.ErrPCSCUnavailable), http.StatusServiceUnavailable, "PCSC_UNAVAILABLE"},
+		{"invalid lock pages", fmt.Errorf("%w: page 16 can only be locked together with pages 16-17", core.ErrInvalidLockPages), http.StatusBadRequest, "INVALID_LOCK_PAGES"},
+		{"ndef incomplete", fmt.Errorf("%w: last record is missing the ME flag", core.ErrNDEFIncomplete), http.StatusConflict, "NDEF_INCOMPLETE"},
+		{"generic error", errors.New("failed to connect to reader"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respondCardError(w, http.StatusInternalServerError, tt.err)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var result map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if result["code"] != tt.expectedCode {
+				t.Errorf("expected code %q, got %q", tt.expectedCode, result["code"])
+			}
+			if result["error"] != tt.err.Error() {
+				t.Errorf("expected error %q, got %q", tt.err.Error(), result["error"])
+			}
+			if retry := w.Header().Get("Retry-After"); (retry != "") != (tt.expectedCode == "TOO_MANY_OPERATIONS") {
+				t.Errorf("unexpected Retry-After header %q", retry)
+			}
+		})
+	}
+}
+
+func TestHandleCounter_Validation(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"GET not allowed", http.MethodGet, "/v1/readers/0/counter/8", http.StatusMethodNotAllowed},
+		{"missing page", http.MethodPost, "/v1/readers/0/counter", http.StatusBadRequest},
+		{"invalid page", http.MethodPost, "/v1/readers/0/counter/abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			parts := strings.Split(strings.Trim(tt.path, "/"), "/")
+
+			handleCounter(w, req, "Test Reader", parts)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleTestWrite_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/readers/0/test-write", nil)
+	w := httptest.NewRecorder()
+
+	handleTestWrite(w, req, "Test Reader")
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleKeyValues_Validation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/v1/readers/0/kv", nil)
+	w := httptest.NewRecorder()
+	handleKeyValues(w, req, "Test Reader")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/readers/0/kv", strings.NewReader(`{"values": {"a": 1}}`))
+	w = httptest.NewRecorder()
+	handleKeyValues(w, req, "Test Reader")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for non-string value, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/readers/0/kv", strings.NewReader(`{"values": {"a": "1"}, "expiresAt": 1700000000, "ttl": 60}`))
+	w = httptest.NewRecorder()
+	handleKeyValues(w, req, "Test Reader")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for expiresAt with ttl, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRequestExpiry(t *testing.T) {
+	if got, err := requestExpiry(0, 0); got != 0 || err != nil {
+		t.Errorf("expected no expiry, got %d (%v)", got, err)
+	}
+	if got, err := requestExpiry(1700000000, 0); got != 1700000000 || err != nil {
+		t.Errorf("expected absolute expiry, got %d (%v)", got, err)
+	}
+	before := time.Now().Unix()
+	if got, err := requestExpiry(0, 60); err != nil || got < before+60 || got > time.Now().Unix()+60 {
+		t.Errorf("expected expiry 60s from now, got %d (%v)", got, err)
+	}
+	if _, err := requestExpiry(0, -1); err == nil {
+		t.Error("expected error for negative ttl")
+	}
+}
+
+func TestHandleReaderCard_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unsupported format", "format=xml"},
+		{"invalid maxPages", "maxPages=0"},
+		{"invalid includeRaw", "includeRaw=maybe"},
+		{"zero blocks", "blocks=0"},
+		{"too many blocks", "blocks=300"},
+		{"unsupported prefer", "prefer=json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/readers/0/card?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handleReaderCard(w, req, "Test Reader")
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleMifareRange_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"non-numeric start", "start=abc"},
+		{"non-numeric count", "start=4&count=x"},
+		{"start out of range", "start=300"},
+		{"zero count", "start=4&count=0"},
+		{"range past last block", "start=250&count=10"},
+		{"invalid key", "start=4&key=zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/readers/0/mifare?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handleMifareRange(w, req, "Test Reader")
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleReaderClaim(t *testing.T) {
+	t.Cleanup(func() { readerClaims = newClaimRegistry() })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/claim", strings.NewReader(`{"leaseMs": 5000}`))
+	w := httptest.NewRecorder()
+	handleReaderClaim(w, req, "Test Reader")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var result struct {
+		Token   string `json:"token"`
+		LeaseMs int64  `json:"leaseMs"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Token == "" || result.LeaseMs != 5000 {
+		t.Fatalf("unexpected claim response: %+v", result)
+	}
+
+	// Another client is refused
+	req = httptest.NewRequest(http.MethodPost, "/v1/readers/0/claim", nil)
+	w = httptest.NewRecorder()
+	handleReaderClaim(w, req, "Test Reader")
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d for second claim, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Releasing without the token fails, with it succeeds
+	req = httptest.NewRequest(http.MethodDelete, "/v1/readers/0/claim", nil)
+	w = httptest.NewRecorder()
+	handleReaderClaim(w, req, "Test Reader")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without token, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/readers/0/claim", nil)
+	req.Header.Set(claimTokenHeader, result.Token)
+	w = httptest.NewRecorder()
+	handleReaderClaim(w, req, "Test Reader")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d with token, got %d", http.StatusOK, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/readers/0/claim", strings.NewReader(`{"leaseMs": 10}`))
+	w = httptest.NewRecorder()
+	handleReaderClaim(w, req, "Test Reader")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for short lease, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestMifareBlockResponse(t *testing.T) {
+	valueBlock, _ := hex.DecodeString("640000009bffffff6400000005fa05fa")
+
+	resp := mifareBlockResponse(5, valueBlock, "")
+	if _, ok := resp["valueBlock"]; ok {
+		t.Error("valueBlock should only be set with decode=value")
+	}
+
+	resp = mifareBlockResponse(5, valueBlock, "value")
+	if resp["valueBlock"] != true || resp["value"] != int32(100) || resp["address"] != byte(5) {
+		t.Errorf("unexpected decoded response: %v", resp)
+	}
+
+	resp = mifareBlockResponse(4, make([]byte, 16), "value")
+	if resp["valueBlock"] != false || resp["decodeError"] == nil {
+		t.Errorf("expected valueBlock false with decodeError, got %v", resp)
+	}
+	if resp["data"] != strings.Repeat("00", 16) {
+		t.Errorf("raw data should still be returned, got %v", resp["data"])
+	}
+}
+
+func TestHandleKeyProfiles_RequiresToken(t *testing.T) {
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken })
+
+	tests := []struct {
+		name   string
+		method string
+		auth   string
+	}{
+		{"no token", http.MethodGet, ""},
+		{"wrong token", http.MethodGet, "Bearer wrong"},
+		{"store without token", http.MethodPost, ""},
+		{"delete without token", http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/keys/transit", strings.NewReader(`{"keyA": "FFFFFFFFFFFF"}`))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+
+			handleKeyProfiles(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleKeyProfiles_InvalidBody(t *testing.T) {
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken })
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"invalid JSON", "/v1/keys/transit", `not json`},
+		{"no keys", "/v1/keys/transit", `{}`},
+		{"bad key", "/v1/keys/transit", `{"keyA": "FFFF"}`},
+		{"missing name", "/v1/keys", `{"keyA": "FFFFFFFFFFFF"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret-token")
+			w := httptest.NewRecorder()
+
+			handleKeyProfiles(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestResolveMifareKey_Inline(t *testing.T) {
+	key, keyType, err := resolveMifareKey("A0A1A2A3A4A5", "B", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hex.EncodeToString(key) != "a0a1a2a3a4a5" || keyType != 'B' {
+		t.Errorf("got key %x type %c, want a0a1a2a3a4a5 B", key, keyType)
+	}
+
+	if _, _, err := resolveMifareKey("A0A1A2A3A4A5", "", "transit", true); err == nil {
+		t.Error("expected error when both key and keyProfile are given")
+	}
+}
+
+func TestHandleSettings_PasswordProfileRequiresToken(t *testing.T) {
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/settings", strings.NewReader(`{"ultralightPasswordProfile": "spools"}`))
+	w := httptest.NewRecorder()
+	handleSettings(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestResolveMifareKey_ProfileRequiresToken(t *testing.T) {
+	_, _, err := resolveMifareKey("", "A", "transit", false)
+	if !errors.Is(err, errKeyProfileUnauthorized) {
+		t.Fatalf("expected errKeyProfileUnauthorized, got %v", err)
+	}
+	if got := mifareKeyErrorStatus(err); got != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", got)
+	}
+}
+
+func TestHandleCardsInField_MethodNotAllowed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/cards", nil)
+	w := httptest.NewRecorder()
+
+	handleCardsInField(w, req, "Test Reader")
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleNTAG_Routing(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		parts  []string
+		body   string
+		want   int
+	}{
+		{"missing subresource", http.MethodGet, []string{"", "v1", "readers", "ntag"}, "", http.StatusNotFound},
+		{"unknown subresource", http.MethodGet, []string{"", "v1", "readers", "ntag", "pages"}, "", http.StatusNotFound},
+		{"config wrong method", http.MethodPost, []string{"", "v1", "readers", "ntag", "config"}, "", http.StatusMethodNotAllowed},
+		{"mirror-url wrong method", http.MethodGet, []string{"", "v1", "readers", "ntag", "mirror-url"}, "", http.StatusMethodNotAllowed},
+		{"mirror-url invalid body", http.MethodPost, []string{"", "v1", "readers", "ntag", "mirror-url"}, "{", http.StatusBadRequest},
+		{"mirror-url no placeholder", http.MethodPost, []string{"", "v1", "readers", "ntag", "mirror-url"}, `{"template": "https://x.io/t"}`, http.StatusBadRequest},
+		{"mirror-url split placeholders", http.MethodPost, []string{"", "v1", "readers", "ntag", "mirror-url"}, `{"template": "https://x.io/{UID}/{CNT}"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/readers/0/ntag", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handleNTAG(w, req, "Test Reader", tt.parts)
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleISO15693_Routing(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		parts  []string
+		want   int
+	}{
+		{"missing subresource", http.MethodGet, []string{"", "v1", "readers", "iso15693"}, http.StatusNotFound},
+		{"unknown subresource", http.MethodGet, []string{"", "v1", "readers", "iso15693", "blocks"}, http.StatusNotFound},
+		{"dump wrong method", http.MethodPost, []string{"", "v1", "readers", "iso15693", "dump"}, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/readers/0/iso15693", nil)
+			w := httptest.NewRecorder()
+
+			handleISO15693(w, req, "Test Reader", tt.parts)
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleProvisionSpool_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid body", http.MethodPost, "{", http.StatusBadRequest},
+		{"protect without password", http.MethodPost, `{"input": {"materialName": "PLA"}, "protect": true}`, http.StatusBadRequest},
+		{"invalid password", http.MethodPost, `{"input": {"materialName": "PLA"}, "password": "1234"}`, http.StatusBadRequest},
+		{"invalid uuid", http.MethodPost, `{"input": {"materialName": "PLA", "instanceUuid": "nope"}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/readers/0/provision-spool", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handleProvisionSpool(w, req, "Test Reader")
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleOpenPrintTagAux_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"unknown endpoint", http.MethodPatch, "/v1/readers/0/openprinttag/main", "[]", http.StatusNotFound},
+		{"wrong method", http.MethodPost, "/v1/readers/0/openprinttag/aux", "[]", http.StatusMethodNotAllowed},
+		{"not an array", http.MethodPatch, "/v1/readers/0/openprinttag/aux", `{"op": "replace"}`, http.StatusBadRequest},
+		{"empty patch", http.MethodPatch, "/v1/readers/0/openprinttag/aux", "[]", http.StatusBadRequest},
+		{"main section", http.MethodPatch, "/v1/readers/0/openprinttag/aux", `[{"op": "replace", "path": "/main/brandName", "value": "X"}]`, http.StatusBadRequest},
+		{"unsupported op", http.MethodPatch, "/v1/readers/0/openprinttag/aux", `[{"op": "copy", "from": "/workgroup", "path": "/generalPurposeUser"}]`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handleOpenPrintTagAux(w, req, "Test Reader", strings.Split(strings.Trim(tt.path, "/"), "/"))
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleAPDUScript_Gated(t *testing.T) {
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken; AllowRawAPDU = false })
+
+	tests := []struct {
+		name    string
+		enabled bool
+		auth    string
+		body    string
+		want    int
+	}{
+		{"disabled", false, "Bearer secret-token", `{"steps": [{"apdu": "FFCA000000"}]}`, http.StatusForbidden},
+		{"no token", true, "", `{"steps": [{"apdu": "FFCA000000"}]}`, http.StatusUnauthorized},
+		{"no steps", true, "Bearer secret-token", `{"steps": []}`, http.StatusBadRequest},
+		{"short APDU", true, "Bearer secret-token", `{"steps": [{"apdu": "FFCA"}]}`, http.StatusBadRequest},
+		{"bad status", true, "Bearer secret-token", `{"steps": [{"apdu": "FFCA000000", "expectStatus": "90"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AllowRawAPDU = tt.enabled
+			req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/script", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+
+			handleAPDUScript(w, req, "Test Reader")
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleLockPages_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"invalid body", `{"pages": "4"}`, "invalid request body"},
+		{"no pages", `{"confirm": true}`, "pages is required"},
+		{"not confirmed", `{"pages": [4, 5]}`, "confirm=true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/lock/pages", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handleLockPages(w, req, "Test Reader")
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected error containing %q, got %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPAuditOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodPost, "/v1/readers/0/card", "write_card"},
+		{http.MethodGet, "/v1/readers/0/card", ""},
+		{http.MethodDelete, "/v1/readers/0/erase", "erase_card"},
+		{http.MethodDelete, "/v1/readers/0/password", "remove_password"},
+		{http.MethodPost, "/v1/readers/0/lock", "lock_card"},
+		{http.MethodPost, "/v1/readers/0/lock/pages", "lock_pages"},
+		{http.MethodPost, "/v1/readers/0/mifare/4", "write_mifare_block"},
+		{http.MethodPost, "/v1/readers/0/mifare/batch", "write_mifare_blocks"},
+		{http.MethodPost, "/v1/readers/0/mifare/sector-trailer", "write_mifare_sector_trailer"},
+		{http.MethodPost, "/v1/readers/0/mifare/derive-key", ""},
+		{http.MethodPatch, "/v1/readers/0/openprinttag/aux", "patch_openprinttag_aux"},
+		{http.MethodPost, "/v1/readers/0/claim", ""},
+	}
+
+	for _, tt := range tests {
+		parts := strings.Split(strings.TrimPrefix(tt.path, "/"), "/")
+		if got := httpAuditOperation(tt.method, parts); got != tt.want {
+			t.Errorf("%s %s: got %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAuditHTTP_RecordsFailure(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/card", strings.NewReader(`{"data": "x", "expectUID": "04A1B2C3"}`))
+	req.RemoteAddr = "192.0.2.10:4321"
+	rec := httptest.NewRecorder()
+
+	w, done := auditHTTP(rec, req, "Audit Test Reader", "write_card")
+	var body map[string]string
+	json.NewDecoder(req.Body).Decode(&body)
+	respondJSON(w, http.StatusConflict, map[string]string{"error": "card UID mismatch"})
+	done()
+
+	entries := logging.AuditEntries(1, nil)
+	if len(entries) != 1 {
+		t.Fatal("expected an audit entry")
+	}
+	e := entries[0]
+	if e.Reader != "Audit Test Reader" || e.Operation != "write_card" || e.Client != "192.0.2.10:4321" || e.Authenticated {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e.UID != "04A1B2C3" || e.Result != "failure" || e.Error != "card UID mismatch" {
+		t.Errorf("unexpected result: %+v", e)
+	}
+
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken })
+
+	w2 := httptest.NewRecorder()
+	handleAudit(w2, httptest.NewRequest(http.MethodGet, "/v1/audit", nil))
+	if w2.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the API token, got %d", w2.Code)
+	}
+
+	w2 = httptest.NewRecorder()
+	auditReq := httptest.NewRequest(http.MethodGet, "/v1/audit?reader=Audit+Test+Reader&limit=5", nil)
+	auditReq.Header.Set("Authorization", "Bearer secret-token")
+	handleAudit(w2, auditReq)
+	var resp struct {
+		Entries []logging.AuditEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(w2.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) == 0 || resp.Entries[0].Hash != e.Hash {
+		t.Errorf("GET /v1/audit entries = %+v", resp.Entries)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	var b cappedBuffer
+	for _, chunk := range []int{maxAuditBodyBytes - 10, 100} {
+		if n, err := b.Write(make([]byte, chunk)); err != nil || n != chunk {
+			t.Errorf("Write = %d, %v; want every byte accepted", n, err)
+		}
+	}
+	if len(b.Bytes()) != maxAuditBodyBytes {
+		t.Errorf("kept %d bytes, want %d", len(b.Bytes()), maxAuditBodyBytes)
+	}
+}
+
+func TestHandleProvisionSpool_ReportsFailedStep(t *testing.T) {
+	body := `{"input": {"materialName": "PLA", "brandName": "Acme", "instanceUuid": "nope"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/readers/0/provision-spool", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handleProvisionSpool(w, req, "Test Reader")
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp["failedStep"] != core.ProvisionStepEncode {
+		t.Errorf("failedStep = %v, want %q", resp["failedStep"], core.ProvisionStepEncode)
+	}
+	if resp["written"] != false {
+		t.Errorf("written = %v, want false", resp["written"])
+	}
+}
+
+func TestParseControlTLVs(t *testing.T) {
+	tlvs, err := parseControlTLVs([]controlTLVRequest{{Type: 1, Value: "A00C34"}})
+	if err != nil || len(tlvs) != 1 || tlvs[0].Type != core.TLVLockControl {
+		t.Fatalf("parseControlTLVs() = %+v, %v", tlvs, err)
+	}
+
+	for _, req := range []controlTLVRequest{
+		{Type: 1, Value: "zz"},
+		{Type: 1, Value: "A0"},
+		{Type: 3, Value: "D00000"},
+	} {
+		if _, err := parseControlTLVs([]controlTLVRequest{req}); err == nil {
+			t.Errorf("expected error for %+v", req)
+		}
+	}
+}
+
+func TestHandleSystem(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/system", nil)
+	w := httptest.NewRecorder()
+	handleSystem(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/system", nil)
+	w = httptest.NewRecorder()
+	handleSystem(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 even without PC/SC, got %d", w.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	for _, key := range []string{"agentVersion", "os", "pcsc", "readers"} {
+		if _, ok := resp[key]; !ok {
+			t.Errorf("response missing %q", key)
+		}
+	}
+}
+
+// Benchmark tests
+func BenchmarkHandleVersion(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/version", nil)
+		w := httptest.NewRecorder()
+		handleVersion(w, req)
+	}
+}
+
+func BenchmarkHandleHealth(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+		w := httptest.NewRecorder()
+		handleHealth(w, req)
+	}
+}
+
+func BenchmarkCORSMiddleware(b *testing.B) {
+	handler := corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+	}
+}
+
+func BenchmarkRespondJSON(b *testing.B) {
+	data := map[string]interface{}{
+		"key":    "value",
+		"number": 42,
+		"array":  []string{"a", "b", "c"},
+	}
+
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		respondJSON(w, http.StatusOK, data)
+	}
+}
+
+func BenchmarkNewMux(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewMux()
+	}
+}
+
+func TestFilterReadersByCapability(t *testing.T) {
+	table, err := data.GetSupportedReaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readers := []core.Reader{
+		{ID: "reader-0", Name: "ACS ACR122U PICC Interface 00 00", Type: "picc"},
+		{ID: "reader-1", Name: "ACS ACR1252U PICC Reader 00 00", Type: "picc"},
+		{ID: "reader-2", Name: "ACS ACR1252U SAM Reader 00 01", Type: "sam"},
+		{ID: "reader-3", Name: "Generic NFC Reader", Type: "picc"},
+	}
+
+	got := filterReadersByCapability(readers, table, []string{"iso15693-write"})
+	if len(got) != 2 {
+		t.Fatalf("expected 2 readers, got %+v", got)
+	}
+	if got[0].Index != 1 || got[0].CapabilityUnknown {
+		t.Errorf("expected the ACR1252U at index 1, got %+v", got[0])
+	}
+	if got[1].Index != 3 || !got[1].CapabilityUnknown {
+		t.Errorf("expected the unknown reader flagged, got %+v", got[1])
+	}
+
+	if got := filterReadersByCapability(readers, table, []string{"ntag-write", "display"}); len(got) != 2 || got[0].Index != 1 {
+		t.Errorf("expected all capabilities to be required, got %+v", got)
+	}
+}
+
+func TestHandleListReaders_UnknownCapability(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleListReaders(w, httptest.NewRequest(http.MethodGet, "/v1/readers?capability=teleport", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errIdempotencyKeyReused is returned when an idempotency key is sent again
+// with a different request.
+var errIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// idempotencyWindow is how long a successful write is remembered under its key.
+const idempotencyWindow = 2 * time.Minute
+
+// idempotencyEntry is one remembered write. done is closed once the write has
+// finished; err is its result.
+type idempotencyEntry struct {
+	fingerprint string
+	done        chan struct{}
+	err         error
+	expires     time.Time
+}
+
+// idempotencyCache remembers successful writes by idempotency key so retries
+// of the same request aren't written to the card twice. Failed writes are
+// forgotten, so a retry runs again. Expired keys are dropped on the next call.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	now     func() time.Time // Overridden in tests
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		entries: make(map[string]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+var idempotentWrites = newIdempotencyCache()
+
+// idempotencyFingerprint hashes the fields that identify a request, so a key
+// reused for a different write can be told apart from a retry.
+func idempotencyFingerprint(parts ...any) string {
+	data, _ := json.Marshal(parts)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// do runs fn once per key. A later call with the same key and fingerprint
+// within the window returns the first call's result with replayed set,
+// waiting for it if it's still running. An empty key always runs fn.
+func (c *idempotencyCache) do(key, fingerprint string, fn func() error) (replayed bool, err error) {
+	if key == "" {
+		return false, fn()
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	for k, entry := range c.entries {
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			continue
+		}
+		delete(c.entries, k)
+	}
+	if entry, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		if entry.fingerprint != fingerprint {
+			return false, errIdempotencyKeyReused
+		}
+		<-entry.done
+		if entry.err != nil {
+			// The first attempt failed and was forgotten; run this one
+			return c.do(key, fingerprint, fn)
+		}
+		return true, nil
+	}
+	entry := &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	err = fn()
+
+	c.mu.Lock()
+	entry.err = err
+	if err != nil {
+		delete(c.entries, key)
+	} else {
+		entry.expires = c.now().Add(idempotencyWindow)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+	return false, err
+}
+
+// clear forgets the finished writes and returns how many it dropped. Writes
+// still running are kept, so their retries keep waiting for them.
+func (c *idempotencyCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cleared := 0
+	for k, entry := range c.entries {
+		if !entry.expires.IsZero() {
+			delete(c.entries, k)
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// idempotencyScope keys an idempotency key by reader, so the same key on
+// different readers names different writes.
+func idempotencyScope(readerName, key string) string {
+	if key == "" {
+		return ""
+	}
+	return readerName + "\x00" + key
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newIdempotencyCache()
+	c.now = func() time.Time { return now }
+
+	writes := 0
+	write := func() error {
+		writes++
+		return nil
+	}
+
+	if replayed, err := c.do("key", "a", write); err != nil || replayed {
+		t.Fatalf("first call: replayed=%v err=%v", replayed, err)
+	}
+	if replayed, err := c.do("key", "a", write); err != nil || !replayed {
+		t.Errorf("retry: replayed=%v err=%v, want replayed", replayed, err)
+	}
+	if writes != 1 {
+		t.Errorf("writes = %d, want 1", writes)
+	}
+	if _, err := c.do("key", "b", write); !errors.Is(err, errIdempotencyKeyReused) {
+		t.Errorf("expected errIdempotencyKeyReused for a different request, got %v", err)
+	}
+
+	// Once the window has passed the key can be used again
+	now = now.Add(idempotencyWindow)
+	if replayed, err := c.do("key", "b", write); err != nil || replayed {
+		t.Errorf("after expiry: replayed=%v err=%v", replayed, err)
+	}
+	if writes != 2 {
+		t.Errorf("writes = %d, want 2", writes)
+	}
+
+	// No key, no caching
+	for i := 0; i < 2; i++ {
+		if replayed, _ := c.do("", "a", write); replayed {
+			t.Error("empty key should never replay")
+		}
+	}
+	if writes != 4 {
+		t.Errorf("writes = %d, want 4", writes)
+	}
+}
+
+func TestIdempotencyCacheForgetsFailures(t *testing.T) {
+	c := newIdempotencyCache()
+	failure := errors.New("card removed")
+
+	if _, err := c.do("key", "a", func() error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	ran := false
+	replayed, err := c.do("key", "a", func() error {
+		ran = true
+		return nil
+	})
+	if err != nil || replayed || !ran {
+		t.Errorf("retry after failure: replayed=%v err=%v ran=%v, want a fresh write", replayed, err, ran)
+	}
+}
+
+func TestIdempotencyCacheConcurrentRetry(t *testing.T) {
+	c := newIdempotencyCache()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.do("key", "a", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	result := make(chan bool)
+	go func() {
+		replayed, _ := c.do("key", "a", func() error {
+			t.Error("concurrent retry should not write")
+			return nil
+		})
+		result <- replayed
+	}()
+
+	close(release)
+	if !<-result {
+		t.Error("concurrent retry should replay the first write")
+	}
+	wg.Wait()
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	if got := idempotencyScope("Reader", ""); got != "" {
+		t.Errorf("empty key scope = %q, want empty", got)
+	}
+	if idempotencyScope("Reader A", "key") == idempotencyScope("Reader B", "key") {
+		t.Error("same key on different readers should be different scopes")
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// idleCountdown is how long before an idle shutdown the remaining time is logged.
+const idleCountdown = time.Minute
+
+// lastActivityAt is when an HTTP API request last arrived or a WebSocket
+// client was last seen connected, in unix nanoseconds.
+var lastActivityAt atomic.Int64
+
+// noteActivity records API activity for the idle shutdown.
+func noteActivity() {
+	lastActivityAt.Store(time.Now().UnixNano())
+}
+
+// wsClientCount returns the number of connected WebSocket clients.
+func wsClientCount() int {
+	if wsHub == nil {
+		return 0
+	}
+	wsHub.mu.RLock()
+	defer wsHub.mu.RUnlock()
+	return len(wsHub.clients)
+}
+
+// StartIdleShutdown calls the shutdown handler once no WebSocket client has
+// been connected and no HTTP request or card operation has happened for
+// timeout. The remaining time is logged during the last minute.
+func StartIdleShutdown(timeout time.Duration) {
+	noteActivity() // Count from startup
+	interval := idleCheckInterval(timeout)
+
+	go func() {
+		defer logging.RecoverAndLog("Idle shutdown", false)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			remaining := idleRemaining(time.Now(), timeout)
+			if remaining > 0 {
+				if remaining <= idleCountdown {
+					logging.Info(logging.CatSystem, "Idle, shutting down soon", map[string]any{
+						"remainingSeconds": int(remaining.Round(time.Second).Seconds()),
+					})
+				}
+				continue
+			}
+
+			if shutdownHandler == nil {
+				logging.Warn(logging.CatSystem, "Idle shutdown due but no shutdown handler is set", nil)
+				return
+			}
+			logging.Info(logging.CatSystem, "Shutting down after being idle", map[string]any{
+				"idleSeconds": int(timeout.Seconds()),
+			})
+			shutdownHandler()
+			return
+		}
+	}()
+}
+
+// idleRemaining returns how long until an idle shutdown is due at now; zero
+// or less means it is due. While a WebSocket client is connected it's the
+// full timeout, counting down again from when the last one disconnects.
+func idleRemaining(now time.Time, timeout time.Duration) time.Duration {
+	if wsClientCount() > 0 {
+		lastActivityAt.Store(now.UnixNano())
+		return timeout
+	}
+	lastActive := time.Unix(0, lastActivityAt.Load())
+	if op := core.LastOperationTime(); op.After(lastActive) {
+		lastActive = op
+	}
+	return timeout - now.Sub(lastActive)
+}
+
+// idleCheckInterval is how often the idle shutdown checks for activity: a
+// tenth of the timeout, between 1 and 30 seconds.
+func idleCheckInterval(timeout time.Duration) time.Duration {
+	return min(max(timeout/10, time.Second), 30*time.Second)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleRemaining(t *testing.T) {
+	origHub := wsHub
+	defer func() { wsHub = origHub }()
+	wsHub = NewWSHub()
+
+	// Ahead of any card operation other tests ran
+	now := time.Now().Add(24 * time.Hour)
+	lastActivityAt.Store(now.Add(-4 * time.Minute).UnixNano())
+	if got := idleRemaining(now, 5*time.Minute); got != time.Minute {
+		t.Errorf("idleRemaining = %v, want 1m", got)
+	}
+	if got := idleRemaining(now.Add(time.Minute), 5*time.Minute); got > 0 {
+		t.Errorf("idleRemaining = %v, want the shutdown to be due", got)
+	}
+
+	// A connected client keeps the agent up and restarts the countdown
+	client := &WSClient{}
+	wsHub.clients[client] = true
+	later := now.Add(time.Hour)
+	if got := idleRemaining(later, 5*time.Minute); got != 5*time.Minute {
+		t.Errorf("idleRemaining with a client = %v, want the full timeout", got)
+	}
+	delete(wsHub.clients, client)
+	if got := idleRemaining(later.Add(time.Minute), 5*time.Minute); got != 4*time.Minute {
+		t.Errorf("idleRemaining after the client left = %v, want 4m", got)
+	}
+}
+
+func TestIdleCheckInterval(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{5 * time.Second, time.Second},
+		{time.Minute, 6 * time.Second},
+		{time.Hour, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := idleCheckInterval(tt.timeout); got != tt.want {
+			t.Errorf("idleCheckInterval(%v) = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// clearCaches empties the in-memory caches of core and api and returns the
+// number of entries removed, by cache name.
+func clearCaches() map[string]int {
+	cleared := core.ClearCaches()
+	cleared["idempotencyKeys"] = idempotentWrites.clear()
+	return cleared
+}
+
+// handleMaintenance serves maintenance actions. All require the API token.
+// POST /v1/maintenance/clear-caches - Flush in-memory caches without a restart
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/maintenance"), "/")
+	if action != "clear-caches" {
+		respondJSON(w, http.StatusNotFound, map[string]string{
+			"error": "unknown endpoint (use /v1/maintenance/clear-caches)",
+		})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAPIToken(w, r) {
+		return
+	}
+
+	cleared := clearCaches()
+	logging.Info(logging.CatSystem, "In-memory caches cleared", map[string]any{
+		"cleared": cleared,
+	})
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"cleared": cleared,
+	})
+}
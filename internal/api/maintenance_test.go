@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleMaintenance_ClearCaches(t *testing.T) {
+	origToken := apiToken
+	apiToken = func() (string, error) { return "secret-token", nil }
+	t.Cleanup(func() { apiToken = origToken })
+
+	if _, err := idempotentWrites.do("maintenance-test", "a", func() error { return nil }); err != nil {
+		t.Fatalf("seeding idempotency cache: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/maintenance/clear-caches", nil)
+	w := httptest.NewRecorder()
+	handleMaintenance(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/maintenance/clear-caches", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	w = httptest.NewRecorder()
+	handleMaintenance(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Cleared map[string]int `json:"cleared"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, name := range []string{"cardTypes", "readerErrors", "idempotencyKeys"} {
+		if _, ok := resp.Cleared[name]; !ok {
+			t.Errorf("response is missing %q: %v", name, resp.Cleared)
+		}
+	}
+	if resp.Cleared["idempotencyKeys"] < 1 {
+		t.Errorf("expected the seeded idempotency key to be cleared, got %v", resp.Cleared)
+	}
+
+	replayed, _ := idempotentWrites.do("maintenance-test", "a", func() error { return nil })
+	if replayed {
+		t.Error("key should be forgotten after clearing caches")
+	}
+}
+
+func TestHandleMaintenance_Routes(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/v1/maintenance/clear-caches", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/maintenance/restart", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handleMaintenance(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+	}
+}
+
+func TestIdempotencyCacheClearKeepsRunningWrites(t *testing.T) {
+	c := newIdempotencyCache()
+	c.do("done", "a", func() error { return nil })
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go c.do("running", "a", func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if cleared := c.clear(); cleared != 1 {
+		t.Errorf("cleared = %d, want 1", cleared)
+	}
+	c.mu.Lock()
+	_, kept := c.entries["running"]
+	c.mu.Unlock()
+	if !kept {
+		t.Error("a running write should be kept")
+	}
+	close(release)
+}
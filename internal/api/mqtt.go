@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/SimplyPrint/nfc-agent/internal/mqtt"
+)
+
+// MQTT bridge subscriptions, matching the default WebSocket subscription
+// interval.
+const (
+	mqttPollInterval = 500 * time.Millisecond
+	mqttReaderRescan = 5 * time.Second
+)
+
+// mqttBridge publishes card_detected and card_removed events for every reader,
+// with the same payloads WebSocket subscriptions send. It subscribes to each
+// reader in event mode through an internal client that is never registered
+// with the hub, so it shares the subscription machinery, never holds a claim
+// and pauses while any client claims a reader.
+type mqttBridge struct {
+	publish func(topic string, payload []byte) bool
+	prefix  string
+	read    func(readerName string) (*core.Card, error) // nil reads like a WebSocket subscription
+
+	client *WSClient
+}
+
+// StartMQTTBridge publishes card events from all readers to pub under the
+// topics <prefix>/card_detected and <prefix>/card_removed. Publishing never
+// blocks the subscriptions.
+func StartMQTTBridge(pub *mqtt.Publisher, prefix string) {
+	b := newMQTTBridge(pub.Publish, prefix)
+	go b.forward()
+	go b.run()
+}
+
+func newMQTTBridge(publish func(topic string, payload []byte) bool, prefix string) *mqttBridge {
+	return &mqttBridge{
+		publish: publish,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		client: &WSClient{
+			send:          make(chan []byte, 256),
+			subscribed:    make(map[string]bool),
+			pollTickers:   make(map[string]*time.Ticker),
+			lastUIDs:      make(map[string]string),
+			subscriptions: make(map[string]*wsSubscription),
+			operations:    make(map[string]context.CancelFunc),
+			remoteAddr:    "mqtt",
+		},
+	}
+}
+
+// run keeps one subscription per connected reader.
+func (b *mqttBridge) run() {
+	defer logging.RecoverAndLog("MQTT bridge", false)
+
+	for {
+		b.syncReaders(core.ListReaders())
+		time.Sleep(mqttReaderRescan)
+	}
+}
+
+// syncReaders subscribes to new readers and unsubscribes from removed ones.
+func (b *mqttBridge) syncReaders(readers []core.Reader) {
+	present := make(map[string]bool, len(readers))
+	var added []int
+	b.client.mu.Lock()
+	for i, reader := range readers {
+		present[reader.Name] = true
+		if _, ok := b.client.subscriptions[reader.Name]; !ok {
+			added = append(added, i)
+		}
+	}
+	for name := range b.client.subscriptions {
+		if !present[name] {
+			b.client.stopSubscriptionLocked(name)
+			delete(b.client.lastUIDs, name)
+		}
+	}
+	b.client.mu.Unlock()
+
+	for _, i := range added {
+		b.client.startSubscription(i, readers[i].Name, int(mqttPollInterval/time.Millisecond), "event", false, b.read)
+	}
+}
+
+// forward publishes the card events of the bridge's subscriptions. The reader
+// index is looked up when the event is published, since it shifts as readers
+// are connected and removed.
+func (b *mqttBridge) forward() {
+	defer logging.RecoverAndLog("MQTT bridge", false)
+
+	for msg := range b.client.send {
+		b.forwardMessage(msg, core.ListReaders())
+	}
+}
+
+func (b *mqttBridge) forwardMessage(msg []byte, readers []core.Reader) {
+	var event WSMessage
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return
+	}
+	if event.Type != "card_detected" && event.Type != "card_removed" {
+		return
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return
+	}
+	readerName, _ := payload["readerName"].(string)
+	for i, reader := range readers {
+		if reader.Name == readerName {
+			payload["readerIndex"] = i
+			break
+		}
+	}
+	b.emit(event.Type, payload)
+}
+
+// emit publishes an event, logging it if the publisher's queue is full.
+func (b *mqttBridge) emit(event string, payload map[string]interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if !b.publish(b.prefix+"/"+event, data) {
+		logging.Warn(logging.CatSystem, "MQTT queue full, event dropped", map[string]any{
+			"event":  event,
+			"reader": payload["readerName"],
+		})
+	}
+}
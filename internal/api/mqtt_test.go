@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+)
+
+type publishedEvent struct {
+	topic   string
+	payload map[string]interface{}
+}
+
+// newTestMQTTBridge returns a bridge publishing to the returned channel.
+func newTestMQTTBridge() (*mqttBridge, chan publishedEvent) {
+	events := make(chan publishedEvent, 8)
+	b := newMQTTBridge(func(topic string, payload []byte) bool {
+		var decoded map[string]interface{}
+		json.Unmarshal(payload, &decoded)
+		events <- publishedEvent{topic, decoded}
+		return true
+	}, "home/nfc/")
+	return b, events
+}
+
+func TestMQTTBridge_ForwardsCardEvents(t *testing.T) {
+	b, events := newTestMQTTBridge()
+	readers := []core.Reader{{Name: "Reader B"}}
+
+	send := func(msgType string, payload map[string]interface{}) {
+		data, _ := json.Marshal(payload)
+		msg, _ := json.Marshal(WSMessage{Type: msgType, Payload: data})
+		b.forwardMessage(msg, readers)
+	}
+	send("subscribed", map[string]interface{}{"readerIndex": 1})
+	// Subscribed as reader 1; reader 0 has since been removed
+	send("card_detected", map[string]interface{}{
+		"readerIndex": 1,
+		"readerName":  "Reader B",
+		"card":        map[string]interface{}{"uid": "04a1"},
+	})
+	send("card_removed", map[string]interface{}{"readerIndex": 1, "readerName": "Reader B"})
+
+	want := []string{"home/nfc/card_detected", "home/nfc/card_removed"}
+	for _, topic := range want {
+		ev := <-events
+		if ev.topic != topic {
+			t.Fatalf("topic = %q, want %q", ev.topic, topic)
+		}
+		if ev.payload["readerIndex"] != float64(0) {
+			t.Errorf("readerIndex = %v, want the current index 0", ev.payload["readerIndex"])
+		}
+		if ev.payload["readerName"] != "Reader B" {
+			t.Errorf("readerName = %v", ev.payload["readerName"])
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %s", ev.topic) // "subscribed" isn't published
+	default:
+	}
+}
+
+func TestMQTTBridge_SyncReaders(t *testing.T) {
+	origWait := waitCardPresenceChange
+	defer func() { waitCardPresenceChange = origWait }()
+	waitCardPresenceChange = func(ctx context.Context, readerName string, present bool) (bool, error) {
+		<-ctx.Done()
+		return present, ctx.Err()
+	}
+
+	b, events := newTestMQTTBridge()
+	b.read = func(readerName string) (*core.Card, error) {
+		return &core.Card{UID: "04" + readerName[len(readerName)-1:], Type: "NTAG215"}, nil
+	}
+	go b.forward()
+	defer b.syncReaders(nil)
+
+	b.syncReaders([]core.Reader{{Name: "Reader A"}, {Name: "Reader B"}})
+	seen := map[interface{}]bool{}
+	for len(seen) < 2 {
+		select {
+		case ev := <-events:
+			if ev.topic != "home/nfc/card_detected" {
+				t.Fatalf("topic = %q, want card_detected", ev.topic)
+			}
+			seen[ev.payload["readerName"]] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for card_detected, got %v", seen)
+		}
+	}
+
+	b.syncReaders([]core.Reader{{Name: "Reader B"}})
+	b.client.mu.Lock()
+	defer b.client.mu.Unlock()
+	if _, ok := b.client.subscriptions["Reader A"]; ok || len(b.client.subscriptions) != 1 {
+		t.Errorf("removed reader still subscribed: %v", b.client.subscriptions)
+	}
+	if sub := b.client.subscriptions["Reader B"]; sub == nil || sub.Mode != "event" {
+		t.Errorf("expected an event-mode subscription on Reader B, got %+v", sub)
+	}
+}
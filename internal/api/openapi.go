@@ -0,0 +1,465 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// apiRoute describes one HTTP operation for the OpenAPI document. Request and
+// Response are zero values of the body types; nil means no body, or an object
+// the spec doesn't describe further.
+type apiRoute struct {
+	Method   string
+	Path     string
+	Summary  string
+	Query    []string // Optional query parameters
+	Request  interface{}
+	Response interface{}
+}
+
+// Request bodies of the handlers that decode into local structs. They mirror
+// the handlers' fields and exist only to describe them.
+type (
+	writeCardRequest struct {
+		Data        string              `json:"data"`
+		DataType    string              `json:"dataType"` // "text", "json", "binary", "url", "cbor" or "openprinttag"
+		URL         string              `json:"url,omitempty"`
+		Force       bool                `json:"force,omitempty"`
+		ExpectUID   string              `json:"expectUID,omitempty"`
+		ControlTLVs []controlTLVRequest `json:"controlTlvs,omitempty"`
+		LongRecords bool                `json:"longRecords,omitempty"`
+
+		IdempotencyKey string `json:"idempotencyKey,omitempty"`
+		Encode         bool   `json:"encode,omitempty"`
+	}
+	writeRecordsRequest struct {
+		Records     []core.NDEFRecord   `json:"records"`
+		Force       bool                `json:"force,omitempty"`
+		ExpectUID   string              `json:"expectUID,omitempty"`
+		ControlTLVs []controlTLVRequest `json:"controlTlvs,omitempty"`
+		LongRecords bool                `json:"longRecords,omitempty"`
+
+		IdempotencyKey string `json:"idempotencyKey,omitempty"`
+		Encode         bool   `json:"encode,omitempty"`
+	}
+	lockCardRequest struct {
+		Confirm   bool   `json:"confirm"`
+		ExpectUID string `json:"expectUID,omitempty"`
+	}
+	lockPagesRequest struct {
+		Pages     []int  `json:"pages"`
+		Confirm   bool   `json:"confirm"`
+		ExpectUID string `json:"expectUID,omitempty"`
+	}
+	setPasswordRequest struct {
+		Password  string `json:"password"` // 8 hex chars
+		Pack      string `json:"pack"`     // 4 hex chars
+		StartPage int    `json:"startPage"`
+	}
+	passwordRequest struct {
+		Password string `json:"password,omitempty"` // 8 hex chars
+	}
+	mirrorURLRequest struct {
+		Template  string `json:"template"`
+		Force     bool   `json:"force,omitempty"`
+		ExpectUID string `json:"expectUID,omitempty"`
+	}
+	keyValuesRequest struct {
+		Values    map[string]string `json:"values"`
+		ExpiresAt int64             `json:"expiresAt,omitempty"` // Unix time in seconds
+		TTL       int64             `json:"ttl,omitempty"`       // Seconds from now, instead of expiresAt
+		Force     bool              `json:"force,omitempty"`
+	}
+	appDataRequest struct {
+		Data      json.RawMessage `json:"data"`
+		ExpiresAt int64           `json:"expiresAt,omitempty"` // Unix time in seconds
+		TTL       int64           `json:"ttl,omitempty"`       // Seconds from now, instead of expiresAt
+		Force     bool            `json:"force,omitempty"`
+	}
+	provisionSpoolRequest struct {
+		Input    openprinttag.Input `json:"input"`
+		Protect  bool               `json:"protect,omitempty"`
+		Password string             `json:"password,omitempty"`
+		Feedback bool               `json:"feedback,omitempty"`
+		Force    bool               `json:"force,omitempty"`
+	}
+	apduScriptRequest struct {
+		Steps []core.APDUStep `json:"steps"`
+	}
+	apduScriptResponse struct {
+		Results   []core.APDUResult `json:"results"`
+		Completed bool              `json:"completed"`
+	}
+	claimRequest struct {
+		LeaseMs int `json:"leaseMs,omitempty"`
+	}
+	mifareBlockRequest struct {
+		Data       string `json:"data"` // 32 hex chars
+		Key        string `json:"key,omitempty"`
+		KeyType    string `json:"keyType,omitempty"` // "A" or "B"
+		KeyProfile string `json:"keyProfile,omitempty"`
+	}
+	mifareBatchRequest struct {
+		Blocks []struct {
+			Block int    `json:"block"`
+			Data  string `json:"data"` // 32 hex chars
+		} `json:"blocks"`
+		Key        string `json:"key,omitempty"`
+		KeyType    string `json:"keyType,omitempty"`
+		KeyProfile string `json:"keyProfile,omitempty"`
+		ExpectUID  string `json:"expectUID,omitempty"`
+	}
+	mifareBatchResponse struct {
+		Results []core.MifareWriteResult `json:"results"`
+	}
+	deriveKeyRequest struct {
+		AESKey string `json:"aesKey"` // 32 hex chars
+	}
+	aesWriteRequest struct {
+		Data           string `json:"data"`   // 32 hex chars
+		AESKey         string `json:"aesKey"` // 32 hex chars
+		AuthKey        string `json:"authKey,omitempty"`
+		AuthKeyType    string `json:"authKeyType,omitempty"`
+		AuthKeyProfile string `json:"authKeyProfile,omitempty"`
+	}
+	sectorTrailerRequest struct {
+		KeyA           string `json:"keyA"`
+		KeyB           string `json:"keyB"`
+		AccessBits     string `json:"accessBits,omitempty"`
+		AuthKey        string `json:"authKey,omitempty"`
+		AuthKeyType    string `json:"authKeyType,omitempty"`
+		AuthKeyProfile string `json:"authKeyProfile,omitempty"`
+	}
+	ultralightPageRequest struct {
+		Data     string `json:"data"` // 8 hex chars
+		Password string `json:"password,omitempty"`
+	}
+	ultralightBatchRequest struct {
+		Pages []struct {
+			Page int    `json:"page"`
+			Data string `json:"data"` // 8 hex chars
+		} `json:"pages"`
+		Password        string `json:"password,omitempty"`
+		RollbackOnError bool   `json:"rollbackOnError,omitempty"`
+		Verify          bool   `json:"verify,omitempty"`
+		ExpectUID       string `json:"expectUID,omitempty"`
+	}
+	ultralightBatchResponse struct {
+		Results []core.UltralightWriteResult `json:"results"`
+	}
+	keyProfileRequest struct {
+		KeyA     string `json:"keyA,omitempty"`
+		KeyB     string `json:"keyB,omitempty"`
+		Password string `json:"password,omitempty"`
+	}
+	auditResponse struct {
+		Entries    []logging.AuditEntry `json:"entries"`
+		MaxEntries int                  `json:"maxEntries"`
+	}
+	successResponse struct {
+		Success string `json:"success"`
+	}
+	errorResponse struct {
+		Error string `json:"error"`
+		Code  string `json:"code,omitempty"` // e.g. READER_BUSY, UID_MISMATCH, WRITE_PROTECTED
+	}
+)
+
+// apiRoutes lists the HTTP API for GET /v1/openapi.json. Keep it in step with
+// NewMux and handleReaderRoutes.
+var apiRoutes = []apiRoute{
+	{http.MethodGet, "/v1/readers", "List connected readers, or with capability those that have it", []string{"capability"}, nil, []core.Reader{}},
+	{http.MethodGet, "/v1/readers/{n}/card", "Read the card", []string{"format", "lang", "maxPages", "prefer", "encoding", "blocks", "includeRaw", "readAll", "bcc", "debug"}, nil, core.Card{}},
+	{http.MethodPost, "/v1/readers/{n}/card", "Write data to the card", []string{"debug"}, writeCardRequest{}, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/cards", "List the UIDs of all cards in the field", nil, nil, nil},
+	{http.MethodPost, "/v1/readers/{n}/erase", "Erase the card's NDEF data", []string{"expectUID", "full"}, nil, successResponse{}},
+	{http.MethodDelete, "/v1/readers/{n}/erase", "Erase the card's NDEF data", []string{"expectUID", "full"}, nil, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/lock", "Permanently lock the card", nil, lockCardRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/lock/pages", "Permanently lock specific NTAG21x pages", nil, lockPagesRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/password", "Set password protection", nil, setPasswordRequest{}, successResponse{}},
+	{http.MethodDelete, "/v1/readers/{n}/password", "Remove password protection", nil, passwordRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/records", "Write multiple NDEF records", nil, writeRecordsRequest{}, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/ntag/config", "Decode NTAG21x configuration and lock bytes", nil, nil, core.NTAGConfig{}},
+	{http.MethodPost, "/v1/readers/{n}/ntag/mirror-url", "Write a URL with a live UID/counter mirror", nil, mirrorURLRequest{}, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/layout", "Memory map of the card", nil, nil, core.Layout{}},
+	{http.MethodGet, "/v1/readers/{n}/iso15693/dump", "Dump the memory of an ISO 15693 tag", nil, nil, core.ISO15693Dump{}},
+	{http.MethodGet, "/v1/readers/{n}/kv", "Read key-value pairs stored on the card", nil, nil, core.KeyValues{}},
+	{http.MethodPost, "/v1/readers/{n}/kv", "Replace the card's data with key-value pairs", nil, keyValuesRequest{}, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/app-data", "Read the versioned SimplyPrint app data record", nil, nil, core.AppData{}},
+	{http.MethodPost, "/v1/readers/{n}/app-data", "Replace the card's data with an app data record", nil, appDataRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/provision-spool", "Write, protect and verify an OpenPrintTag spool", nil, provisionSpoolRequest{}, core.ProvisionResult{}},
+	{http.MethodPatch, "/v1/readers/{n}/openprinttag/aux", "Update the OpenPrintTag aux section with a JSON Patch", []string{"expectUID"}, []openprinttag.AuxPatchOp{}, nil},
+	{http.MethodPost, "/v1/readers/{n}/script", "Run a sequence of raw APDUs (requires NFC_AGENT_ALLOW_RAW_APDU)", nil, apduScriptRequest{}, apduScriptResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/test-write", "Non-destructive write test", nil, nil, core.WriteTestResult{}},
+	{http.MethodPost, "/v1/readers/{n}/counter/{page}", "Increment a counter stored in a user page (NTAG and MIFARE Ultralight only)", nil, passwordRequest{}, nil},
+	{http.MethodPost, "/v1/readers/{n}/claim", "Claim exclusive use of the reader", nil, claimRequest{}, nil},
+	{http.MethodDelete, "/v1/readers/{n}/claim", "Release a claim", nil, nil, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/mifare", "Read a range of MIFARE Classic blocks", []string{"start", "count", "key", "keyType", "keyProfile"}, nil, nil},
+	{http.MethodGet, "/v1/readers/{n}/mifare/{block}", "Read a MIFARE Classic block", []string{"key", "keyType", "keyProfile"}, nil, nil},
+	{http.MethodPost, "/v1/readers/{n}/mifare/{block}", "Write a MIFARE Classic block", nil, mifareBlockRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/mifare/batch", "Write multiple MIFARE Classic blocks", nil, mifareBatchRequest{}, mifareBatchResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/mifare/derive-key", "Derive a 6-byte key from the UID via AES", nil, deriveKeyRequest{}, nil},
+	{http.MethodPost, "/v1/readers/{n}/mifare/aes-write/{block}", "AES encrypt and write a block", nil, aesWriteRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/mifare/sector-trailer/{block}", "Write a sector trailer", nil, sectorTrailerRequest{}, successResponse{}},
+	{http.MethodGet, "/v1/readers/{n}/mifare/mad", "Decode the MIFARE Application Directory", []string{"key", "keyProfile"}, nil, core.MAD{}},
+	{http.MethodGet, "/v1/readers/{n}/ultralight/{page}", "Read a MIFARE Ultralight page", []string{"password"}, nil, nil},
+	{http.MethodPost, "/v1/readers/{n}/ultralight/{page}", "Write a MIFARE Ultralight page", nil, ultralightPageRequest{}, successResponse{}},
+	{http.MethodPost, "/v1/readers/{n}/ultralight/batch", "Write multiple MIFARE Ultralight pages", nil, ultralightBatchRequest{}, ultralightBatchResponse{}},
+	{http.MethodGet, "/v1/supported-readers", "List supported reader models", nil, nil, nil},
+	{http.MethodGet, "/v1/version", "Version and update info", nil, nil, nil},
+	{http.MethodGet, "/v1/health", "Health check", nil, nil, nil},
+	{http.MethodGet, "/v1/ready", "Readiness check", nil, nil, nil},
+	{http.MethodGet, "/v1/system", "PC/SC, reader and driver details for bug reports", nil, nil, nil},
+	{http.MethodGet, "/v1/capabilities", "Expected duration per operation", nil, nil, nil},
+	{http.MethodGet, "/v1/stats", "Observed latency per operation and card type", nil, nil, nil},
+	{http.MethodGet, "/v1/logs", "Recent log entries", []string{"limit", "level", "category"}, nil, nil},
+	{http.MethodDelete, "/v1/logs", "Clear the log", nil, nil, successResponse{}},
+	{http.MethodGet, "/v1/audit", "Audit log of card-changing operations", []string{"limit", "reader", "client"}, nil, auditResponse{}},
+	{http.MethodGet, "/v1/crashes", "Crash logs", nil, nil, nil},
+	{http.MethodGet, "/v1/settings", "User settings", nil, nil, nil},
+	{http.MethodPost, "/v1/settings", "Update user settings", nil, nil, nil},
+	{http.MethodGet, "/v1/keys", "List stored MIFARE key profiles (requires API token)", nil, nil, nil},
+	{http.MethodPost, "/v1/keys/{name}", "Store a key profile (requires API token)", nil, keyProfileRequest{}, successResponse{}},
+	{http.MethodDelete, "/v1/keys/{name}", "Delete a key profile (requires API token)", nil, nil, successResponse{}},
+	{http.MethodPost, "/v1/shutdown", "Shut the agent down", nil, nil, nil},
+	{http.MethodPost, "/v1/maintenance/clear-caches", "Clear in-memory caches (requires API token)", nil, nil, nil},
+	{http.MethodGet, "/v1/autostart", "Autostart status", nil, nil, nil},
+	{http.MethodPost, "/v1/autostart", "Enable autostart", nil, nil, nil},
+	{http.MethodDelete, "/v1/autostart", "Disable autostart", nil, nil, nil},
+	{http.MethodGet, "/v1/updates", "Check for updates", nil, nil, nil},
+	{http.MethodGet, "/v1/openapi.json", "This OpenAPI document", nil, nil, nil},
+}
+
+// openAPISchemas are described even if no route references them directly.
+var openAPISchemas = []interface{}{
+	openprinttag.Response{},
+	core.HandoverInput{},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// handleOpenAPI serves the OpenAPI 3 description of the HTTP API
+// GET /v1/openapi.json
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	openAPIOnce.Do(func() {
+		openAPIDoc, _ = json.Marshal(buildOpenAPI(apiRoutes))
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDoc)
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// buildOpenAPI builds the OpenAPI document for routes. Schemas are generated
+// from the Go types by their JSON tags.
+func buildOpenAPI(routes []apiRoute) map[string]interface{} {
+	reg := &schemaRegistry{schemas: map[string]interface{}{}}
+	errorRef := reg.schemaFor(reflect.TypeOf(errorResponse{}))
+	for _, v := range openAPISchemas {
+		reg.schemaFor(reflect.TypeOf(v))
+	}
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		var params []interface{}
+		for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			paramType := "integer"
+			if m[1] == "name" {
+				paramType = "string"
+			}
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": paramType},
+			})
+		}
+		for _, q := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name": q, "in": "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		okResponse := map[string]interface{}{"description": "Success"}
+		if route.Response != nil {
+			okResponse["content"] = jsonContent(reg.schemaFor(reflect.TypeOf(route.Response)))
+		}
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"responses": map[string]interface{}{
+				"200":     okResponse,
+				"default": map[string]interface{}{"description": "Error", "content": jsonContent(errorRef)},
+			},
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if route.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(reg.schemaFor(reflect.TypeOf(route.Request))),
+			}
+		}
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "NFC Agent API",
+			"version": Version,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": "http://127.0.0.1:32145"},
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": reg.schemas},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// operationID derives an operation ID such as "postReadersNMifareBatch".
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(route.Path, "/v1/"), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '{' || r == '}'
+	}) {
+		id += upperFirst(part)
+	}
+	return id
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// schemaRegistry collects the named struct schemas of the document.
+type schemaRegistry struct {
+	schemas map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the schema of t. Named structs are added to the
+// registry's components and referenced.
+func (reg *schemaRegistry) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reg.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": reg.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": reg.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return reg.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := reg.schemas[name]; !ok {
+			reg.schemas[name] = map[string]interface{}{} // Placeholder for recursive types
+			reg.schemas[name] = reg.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes a struct's JSON-encoded fields. Fields without
+// omitempty are listed as required.
+func (reg *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := reg.structSchema(f.Type)
+			for k, v := range embedded["properties"].(map[string]interface{}) {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = reg.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName is the component name of a named type, e.g. "Card" or
+// "OpenPrintTagInput".
+func schemaName(t reflect.Type) string {
+	name := upperFirst(t.Name())
+	if strings.HasSuffix(t.PkgPath(), "/openprinttag") {
+		name = "OpenPrintTag" + name
+	}
+	return name
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHandleOpenAPI(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+	handleOpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+
+	for _, name := range []string{"Card", "NDEFRecord", "OpenPrintTagInput", "OpenPrintTagResponse", "ErrorResponse"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("missing schema %s", name)
+		}
+	}
+
+	// Every $ref must point at a described schema
+	for _, m := range strings.Split(w.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name := m[:strings.IndexByte(m, '"')]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("unresolved $ref %s", name)
+		}
+	}
+
+	// Every audited reader endpoint must be described
+	for route, methods := range auditedRoutes {
+		for method := range methods {
+			found := false
+			for path, ops := range doc.Paths {
+				if strings.HasPrefix(path, "/v1/readers/{n}/"+route) {
+					if _, ok := ops[strings.ToLower(method)]; ok {
+						found = true
+					}
+				}
+			}
+			if !found {
+				t.Errorf("no %s operation for reader route %q", method, route)
+			}
+		}
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type node struct {
+		Name     string  `json:"name"`
+		Note     string  `json:"note,omitempty"`
+		Skipped  string  `json:"-"`
+		Raw      []byte  `json:"raw,omitempty"`
+		Children []*node `json:"children,omitempty"`
+	}
+	reg := &schemaRegistry{schemas: map[string]interface{}{}}
+	ref := reg.schemaFor(reflect.TypeOf(node{}))
+	if ref["$ref"] != "#/components/schemas/Node" {
+		t.Fatalf("expected a reference to Node, got %v", ref)
+	}
+
+	schema := reg.schemas["Node"].(map[string]interface{})
+	props := schema["properties"].(map[string]interface{})
+	if _, ok := props["Skipped"]; ok {
+		t.Error(`fields tagged "-" should be skipped`)
+	}
+	if got := props["raw"].(map[string]interface{})["format"]; got != "byte" {
+		t.Errorf("expected []byte as format byte, got %v", got)
+	}
+	items := props["children"].(map[string]interface{})["items"].(map[string]interface{})
+	if items["$ref"] != "#/components/schemas/Node" {
+		t.Errorf("expected recursive reference, got %v", items)
+	}
+	if required := schema["required"].([]string); len(required) != 1 || required[0] != "name" {
+		t.Errorf("expected only name required, got %v", required)
+	}
+}
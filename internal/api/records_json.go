@@ -0,0 +1,380 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// MIME types of the connection handover records decoded by records-json.
+const (
+	wifiMIMEType        = core.WiFiMIMEType
+	bluetoothMIMEType   = core.BluetoothOOBMIMEType
+	bluetoothLEMIMEType = "application/vnd.bluetooth.le.oob"
+)
+
+// maxSmartPosterDepth bounds Smart Poster nesting so a malicious tag can't
+// recurse without limit.
+const maxSmartPosterDepth = 4
+
+// RecordsJSONCard is a card in the "records-json" format: every Card field,
+// with records replaced by typed JSON objects.
+type RecordsJSONCard struct {
+	*core.Card
+	Records []map[string]interface{} `json:"records"`
+}
+
+// recordsJSONCard decodes each record on the card into a typed JSON object.
+func recordsJSONCard(card *core.Card) *RecordsJSONCard {
+	return &RecordsJSONCard{
+		Card:    card,
+		Records: recordsJSON(card.Records, 0),
+	}
+}
+
+func recordsJSON(records []core.ParsedRecord, depth int) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(records))
+	for _, rec := range records {
+		out = append(out, recordJSON(rec, depth))
+	}
+	resolveHandoverCarriers(out, records)
+	return out
+}
+
+// resolveHandoverCarriers attaches to each handover carrier the decoded record
+// its carrierRef points at, by record ID.
+func resolveHandoverCarriers(out []map[string]interface{}, records []core.ParsedRecord) {
+	for _, obj := range out {
+		carriers, ok := obj["carriers"].([]map[string]interface{})
+		if !ok || obj["type"] != "handover" {
+			continue
+		}
+		for _, carrier := range carriers {
+			for i, rec := range records {
+				if rec.ID != "" && rec.ID == carrier["carrierRef"] {
+					carrier["carrier"] = out[i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// recordJSON decodes a single record. Every object carries "type" plus the raw
+// "tnf" and "recordType"; records that fail to decode fall back to their
+// generic representation with an "error" field.
+func recordJSON(rec core.ParsedRecord, depth int) map[string]interface{} {
+	obj := map[string]interface{}{
+		"tnf":        rec.TNF,
+		"recordType": rec.Type,
+	}
+	if rec.ID != "" {
+		obj["id"] = rec.ID
+	}
+
+	switch {
+	case rec.TNF == 0x00:
+		obj["type"] = "empty"
+	case rec.TNF == 0x01 && rec.Type == "U" && rec.DataType == "url":
+		obj["type"] = "uri"
+		obj["uri"] = rec.Data
+	case rec.TNF == 0x01 && rec.Type == "T" && rec.DataType == "text":
+		obj["type"] = "text"
+		obj["lang"] = rec.Lang
+		obj["text"] = rec.Data
+	case rec.TNF == 0x01 && rec.Type == "Sp" && depth < maxSmartPosterDepth:
+		obj["type"] = "smartposter"
+		obj["records"] = recordsJSON(core.ParseNDEFMessage(rec.Payload), depth+1)
+	case rec.TNF == 0x01 && rec.Type == "Hs" && rec.DataType == "handover":
+		version, carriers, _ := core.ParseHandoverSelect(rec.Payload)
+		list := make([]map[string]interface{}, 0, len(carriers))
+		for _, ac := range carriers {
+			list = append(list, map[string]interface{}{
+				"cps":        ac.CPS,
+				"carrierRef": ac.CarrierRef,
+			})
+		}
+		obj["type"] = "handover"
+		obj["version"] = version
+		obj["carriers"] = list
+	case rec.TNF == 0x01 && rec.Type == "act" && len(rec.Payload) == 1:
+		obj["type"] = "action"
+		obj["action"] = smartPosterAction(rec.Payload[0])
+	case rec.TNF == 0x02:
+		mimeRecordJSON(obj, rec)
+	default:
+		obj["type"] = "unknown"
+		obj["payload"] = hex.EncodeToString(rec.Payload)
+	}
+	return obj
+}
+
+// mimeRecordJSON fills obj for a MIME record.
+func mimeRecordJSON(obj map[string]interface{}, rec core.ParsedRecord) {
+	mediaType := core.MediaType(rec.Type)
+	obj["mimeType"] = rec.Type
+
+	var err error
+	switch {
+	case rec.DataType == "openprinttag":
+		var opt *openprinttag.OpenPrintTag
+		if opt, err = openprinttag.Decode(rec.Payload); err == nil {
+			obj["type"] = "openprinttag"
+			obj["openprinttag"] = opt.ToResponse()
+			return
+		}
+	case rec.DataType == "json" && json.Valid(rec.Payload):
+		obj["type"] = "json"
+		obj["json"] = json.RawMessage(rec.Payload)
+		return
+	case mediaType == wifiMIMEType:
+		var wifi map[string]interface{}
+		if wifi, err = decodeWiFiCredential(rec.Payload); err == nil {
+			obj["type"] = "wifi"
+			for k, v := range wifi {
+				obj[k] = v
+			}
+			return
+		}
+	case mediaType == bluetoothMIMEType:
+		var bt map[string]interface{}
+		if bt, err = decodeBluetoothOOB(rec.Payload); err == nil {
+			obj["type"] = "bluetooth"
+			for k, v := range bt {
+				obj[k] = v
+			}
+			return
+		}
+	case mediaType == bluetoothLEMIMEType:
+		obj["type"] = "bluetooth-le"
+		for k, v := range decodeBluetoothLEOOB(rec.Payload) {
+			obj[k] = v
+		}
+		return
+	}
+
+	obj["type"] = "mime"
+	obj["data"] = rec.Data
+	obj["dataType"] = rec.DataType
+	if err != nil {
+		obj["error"] = err.Error()
+	}
+}
+
+// smartPosterAction names a Smart Poster action record value.
+func smartPosterAction(b byte) string {
+	switch b {
+	case 0x00:
+		return "do"
+	case 0x01:
+		return "save"
+	case 0x02:
+		return "open"
+	default:
+		return fmt.Sprintf("0x%02x", b)
+	}
+}
+
+// wscAttributes splits a Wi-Fi Simple Configuration TLV sequence (2-byte ID,
+// 2-byte length, both big-endian) into its attributes.
+func wscAttributes(data []byte) (map[uint16][]byte, error) {
+	attrs := make(map[uint16][]byte)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated WSC attribute header")
+		}
+		id := binary.BigEndian.Uint16(data[0:2])
+		n := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+n {
+			return nil, fmt.Errorf("WSC attribute 0x%04X exceeds payload", id)
+		}
+		if _, ok := attrs[id]; !ok {
+			attrs[id] = data[4 : 4+n]
+		}
+		data = data[4+n:]
+	}
+	return attrs, nil
+}
+
+// decodeWiFiCredential decodes the first credential of a Wi-Fi Simple
+// Configuration token (application/vnd.wfa.wsc).
+func decodeWiFiCredential(payload []byte) (map[string]interface{}, error) {
+	top, err := wscAttributes(payload)
+	if err != nil {
+		return nil, err
+	}
+	credential, ok := top[core.WSCCredential]
+	if !ok {
+		return nil, fmt.Errorf("no Wi-Fi credential in WSC record")
+	}
+	attrs, err := wscAttributes(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	wifi := map[string]interface{}{
+		"ssid": string(attrs[core.WSCSSID]),
+	}
+	if key, ok := attrs[core.WSCNetworkKey]; ok {
+		wifi["networkKey"] = string(key)
+	}
+	if v := attrs[core.WSCAuthType]; len(v) == 2 {
+		wifi["authType"] = wifiAuthType(binary.BigEndian.Uint16(v))
+	}
+	if v := attrs[core.WSCEncryptionType]; len(v) == 2 {
+		wifi["encryptionType"] = wifiEncryptionType(binary.BigEndian.Uint16(v))
+	}
+	if v := attrs[core.WSCMACAddress]; len(v) == 6 {
+		wifi["macAddress"] = colonHex(hex.EncodeToString(v))
+	}
+	return wifi, nil
+}
+
+func wifiAuthType(v uint16) string {
+	switch v {
+	case 0x0001:
+		return "open"
+	case 0x0002:
+		return "wpa-personal"
+	case 0x0004:
+		return "shared"
+	case 0x0008:
+		return "wpa-enterprise"
+	case 0x0010:
+		return "wpa2-enterprise"
+	case 0x0020:
+		return "wpa2-personal"
+	case 0x0022:
+		return "wpa/wpa2-personal"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
+
+func wifiEncryptionType(v uint16) string {
+	switch v {
+	case 0x0001:
+		return "none"
+	case 0x0002:
+		return "wep"
+	case 0x0004:
+		return "tkip"
+	case 0x0008:
+		return "aes"
+	case 0x000C:
+		return "aes/tkip"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
+
+// Bluetooth EIR/AD data types used in OOB records.
+const (
+	btShortName     = 0x08
+	btCompleteName  = 0x09
+	btClassOfDevice = 0x0D
+	btLEAddress     = 0x1B
+	btLERole        = 0x1C
+)
+
+// bluetoothEIR walks a sequence of EIR/AD structures (length, type, data),
+// stopping at a zero length or truncated entry.
+func bluetoothEIR(data []byte, fn func(typ byte, value []byte)) {
+	for len(data) >= 2 {
+		n := int(data[0])
+		if n == 0 || len(data) < 1+n {
+			return
+		}
+		fn(data[1], data[2:1+n])
+		data = data[1+n:]
+	}
+}
+
+// bluetoothAddress formats a little-endian Bluetooth device address.
+func bluetoothAddress(le []byte) string {
+	parts := make([]string, len(le))
+	for i, b := range le {
+		parts[len(le)-1-i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// decodeBluetoothOOB decodes a Bluetooth BR/EDR out-of-band record
+// (application/vnd.bluetooth.ep.oob): OOB length, device address, then EIR data.
+func decodeBluetoothOOB(payload []byte) (map[string]interface{}, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("bluetooth OOB record too short")
+	}
+	n := int(binary.LittleEndian.Uint16(payload[0:2]))
+	if n < 8 || n > len(payload) {
+		return nil, fmt.Errorf("invalid bluetooth OOB data length %d", n)
+	}
+
+	bt := map[string]interface{}{
+		"address": bluetoothAddress(payload[2:8]),
+	}
+	bluetoothEIR(payload[8:n], func(typ byte, value []byte) {
+		switch typ {
+		case btCompleteName:
+			bt["name"] = string(value)
+		case btShortName:
+			if _, ok := bt["name"]; !ok {
+				bt["name"] = string(value)
+			}
+		case btClassOfDevice:
+			if len(value) == 3 {
+				bt["classOfDevice"] = fmt.Sprintf("0x%02x%02x%02x", value[2], value[1], value[0])
+			}
+		}
+	})
+	return bt, nil
+}
+
+// decodeBluetoothLEOOB decodes a Bluetooth LE out-of-band record
+// (application/vnd.bluetooth.le.oob), which is a sequence of AD structures.
+func decodeBluetoothLEOOB(payload []byte) map[string]interface{} {
+	bt := map[string]interface{}{}
+	bluetoothEIR(payload, func(typ byte, value []byte) {
+		switch typ {
+		case btLEAddress:
+			if len(value) == 7 {
+				bt["address"] = bluetoothAddress(value[:6])
+				bt["addressType"] = "public"
+				if value[6]&0x01 != 0 {
+					bt["addressType"] = "random"
+				}
+			}
+		case btLERole:
+			if len(value) == 1 {
+				bt["role"] = bluetoothLERole(value[0])
+			}
+		case btCompleteName:
+			bt["name"] = string(value)
+		case btShortName:
+			if _, ok := bt["name"]; !ok {
+				bt["name"] = string(value)
+			}
+		}
+	})
+	return bt
+}
+
+func bluetoothLERole(v byte) string {
+	switch v {
+	case 0x00:
+		return "peripheral"
+	case 0x01:
+		return "central"
+	case 0x02:
+		return "peripheral-preferred"
+	case 0x03:
+		return "central-preferred"
+	default:
+		return fmt.Sprintf("0x%02x", v)
+	}
+}
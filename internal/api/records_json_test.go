@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/SimplyPrint/nfc-agent/internal/core"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestRecordJSON_WellKnown(t *testing.T) {
+	uri := recordJSON(core.ParsedRecord{TNF: 0x01, Type: "U", Data: "https://example.com", DataType: "url"}, 0)
+	if uri["type"] != "uri" || uri["uri"] != "https://example.com" {
+		t.Errorf("unexpected URI record: %v", uri)
+	}
+
+	text := recordJSON(core.ParsedRecord{TNF: 0x01, Type: "T", Data: "Hallo", DataType: "text", Lang: "de"}, 0)
+	if text["type"] != "text" || text["lang"] != "de" || text["text"] != "Hallo" {
+		t.Errorf("unexpected text record: %v", text)
+	}
+
+	empty := recordJSON(core.ParsedRecord{TNF: 0x00, DataType: "empty"}, 0)
+	if empty["type"] != "empty" {
+		t.Errorf("unexpected empty record: %v", empty)
+	}
+
+	ext := recordJSON(core.ParsedRecord{TNF: 0x04, Type: "example.com:t", Payload: []byte{0xAB}}, 0)
+	if ext["type"] != "unknown" || ext["payload"] != "ab" || ext["recordType"] != "example.com:t" {
+		t.Errorf("unexpected external record: %v", ext)
+	}
+}
+
+func TestRecordJSON_SmartPoster(t *testing.T) {
+	// Nested message: URI "https://example.com", text "Example" (en), action "open"
+	payload := mustHex(t, "91010c55046578616d706c652e636f6d"+
+		"11010a5402656e4578616d706c65"+
+		"51030161637402")
+
+	sp := recordJSON(core.ParsedRecord{TNF: 0x01, Type: "Sp", Payload: payload}, 0)
+	if sp["type"] != "smartposter" {
+		t.Fatalf("expected smartposter, got %v", sp)
+	}
+	nested := sp["records"].([]map[string]interface{})
+	if len(nested) != 3 {
+		t.Fatalf("expected 3 nested records, got %d: %v", len(nested), nested)
+	}
+	if nested[0]["type"] != "uri" || nested[0]["uri"] != "https://example.com" {
+		t.Errorf("unexpected nested URI: %v", nested[0])
+	}
+	if nested[1]["type"] != "text" || nested[1]["text"] != "Example" {
+		t.Errorf("unexpected nested title: %v", nested[1])
+	}
+	if nested[2]["type"] != "action" || nested[2]["action"] != "open" {
+		t.Errorf("unexpected nested action: %v", nested[2])
+	}
+}
+
+func TestRecordJSON_WiFi(t *testing.T) {
+	credential := "" +
+		"10450007" + hex.EncodeToString([]byte("HomeNet")) + // SSID
+		"10030002" + "0020" + // WPA2-Personal
+		"100f0002" + "0008" + // AES
+		"10270008" + hex.EncodeToString([]byte("secret12")) + // Network key
+		"10200006" + "001122334455" // MAC
+	payload := mustHex(t, "104a000110"+"100e"+hex.EncodeToString([]byte{0, byte(len(credential) / 2)})+credential)
+
+	wifi := recordJSON(core.ParsedRecord{TNF: 0x02, Type: wifiMIMEType, Payload: payload}, 0)
+	want := map[string]interface{}{
+		"type":           "wifi",
+		"ssid":           "HomeNet",
+		"networkKey":     "secret12",
+		"authType":       "wpa2-personal",
+		"encryptionType": "aes",
+		"macAddress":     "00:11:22:33:44:55",
+	}
+	for k, v := range want {
+		if wifi[k] != v {
+			t.Errorf("%s = %v, want %v", k, wifi[k], v)
+		}
+	}
+
+	broken := recordJSON(core.ParsedRecord{TNF: 0x02, Type: wifiMIMEType, Payload: []byte{0x10, 0x45, 0x00}}, 0)
+	if broken["type"] != "mime" || broken["error"] == nil {
+		t.Errorf("expected mime fallback with error, got %v", broken)
+	}
+}
+
+func TestRecordJSON_Bluetooth(t *testing.T) {
+	// Length 0x0016, address 66:55:44:33:22:11, name "Speaker", class of device 0x240404
+	payload := mustHex(t, "1600"+"112233445566"+"0809"+hex.EncodeToString([]byte("Speaker"))+"040d040424")
+
+	bt := recordJSON(core.ParsedRecord{TNF: 0x02, Type: bluetoothMIMEType, Payload: payload}, 0)
+	if bt["type"] != "bluetooth" || bt["address"] != "66:55:44:33:22:11" || bt["name"] != "Speaker" || bt["classOfDevice"] != "0x240404" {
+		t.Errorf("unexpected bluetooth record: %v", bt)
+	}
+
+	le := recordJSON(core.ParsedRecord{TNF: 0x02, Type: bluetoothLEMIMEType, Payload: mustHex(t, "081b11223344556601"+"021c00"+"0409"+hex.EncodeToString([]byte("Tag")))}, 0)
+	if le["type"] != "bluetooth-le" || le["address"] != "66:55:44:33:22:11" || le["addressType"] != "random" || le["role"] != "peripheral" || le["name"] != "Tag" {
+		t.Errorf("unexpected bluetooth LE record: %v", le)
+	}
+}
+
+func TestFormatCard_RecordsJSON(t *testing.T) {
+	card := &core.Card{
+		UID:  "04aabbcc",
+		Type: "NTAG215",
+		Records: []core.ParsedRecord{
+			{TNF: 0x02, Type: "application/json", Data: `{"a":1}`, DataType: "json", Payload: []byte(`{"a":1}`)},
+		},
+	}
+
+	result, err := formatCard(card, "records-json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var decoded struct {
+		UID     string `json:"uid"`
+		Type    string `json:"type"`
+		Records []struct {
+			Type     string          `json:"type"`
+			MimeType string          `json:"mimeType"`
+			JSON     json.RawMessage `json:"json"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if decoded.UID != "04aabbcc" || decoded.Type != "NTAG215" {
+		t.Errorf("card fields missing: %s", data)
+	}
+	if len(decoded.Records) != 1 || decoded.Records[0].Type != "json" || string(decoded.Records[0].JSON) != `{"a":1}` {
+		t.Errorf("unexpected records: %s", data)
+	}
+}
+
+func TestRecordsJSON_Handover(t *testing.T) {
+	records := []core.ParsedRecord{
+		// Version 1.2, one alternative carrier: active, reference "0"
+		{TNF: 0x01, Type: "Hs", DataType: "handover", Payload: mustHex(t, "12d102046163010130"+"00")},
+		{TNF: 0x02, Type: core.BluetoothOOBMIMEType, ID: "0", Payload: mustHex(t, "0800665544332211")},
+	}
+
+	out := recordsJSON(records, 0)
+	hs := out[0]
+	if hs["type"] != "handover" || hs["version"] != "1.2" {
+		t.Fatalf("unexpected handover record: %v", hs)
+	}
+	carriers := hs["carriers"].([]map[string]interface{})
+	if len(carriers) != 1 || carriers[0]["cps"] != "active" {
+		t.Fatalf("unexpected carriers: %v", carriers)
+	}
+	carrier, ok := carriers[0]["carrier"].(map[string]interface{})
+	if !ok || carrier["type"] != "bluetooth" || carrier["address"] != "11:22:33:44:55:66" {
+		t.Errorf("carrier reference not resolved: %v", carriers[0])
+	}
+	if out[1]["id"] != "0" {
+		t.Errorf("expected the record ID in the output, got %v", out[1])
+	}
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SimplyPrint/nfc-agent/internal/core"
@@ -17,9 +21,7 @@ import (
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for local use
-	},
+	CheckOrigin: originAllowed, // Any origin for local use, unless CORSAllowedOrigins is set
 }
 
 // WSMessage represents a WebSocket message
@@ -28,6 +30,9 @@ type WSMessage struct {
 	ID      string          `json:"id,omitempty"`      // Request ID for request/response matching
 	Payload json.RawMessage `json:"payload,omitempty"` // Message payload
 	Error   string          `json:"error,omitempty"`   // Error message if any
+	Code    string          `json:"code,omitempty"`    // Machine-readable error code, e.g. "READER_BUSY"
+
+	DebugLog []string `json:"debugLog,omitempty"` // APDU exchanges and log entries of a failed request that asked for them
 }
 
 // WSClient represents a connected WebSocket client
@@ -39,6 +44,51 @@ type WSClient struct {
 	subscribed  map[string]bool // Track subscribed readers for auto-read
 	pollTickers map[string]*time.Ticker
 	lastUIDs    map[string]string // Track last seen UID per reader
+
+	subscriptions map[string]*wsSubscription // Active subscription details per reader
+
+	binary        chan wsBinaryFrame // Outgoing control+binary frame pairs
+	pendingBinary *WSMessage         // Request waiting for its binary data frame
+
+	operations map[string]context.CancelFunc        // Running cancellable operations by request ID
+	readerOps  map[string]chan struct{}             // Closed when the last cancellable operation queued on each reader finishes
+	queuedOps  map[chan struct{}]context.CancelFunc // Every queued or running cancellable operation, by its done channel
+
+	closed chan struct{} // Closed on disconnect; messages sent after that are dropped
+	sendMu sync.RWMutex  // Held for writing while disconnecting, so no send races the close of send
+
+	remoteAddr    string
+	authenticated bool                          // Upgrade request carried the local API token
+	audits        map[string]logging.AuditEntry // Card-changing requests awaiting their response, by request ID
+
+	connectedAt      time.Time
+	messagesReceived atomic.Int64 // Text and binary frames read
+	messagesSent     atomic.Int64 // Text and binary frames written
+}
+
+// wsReadLimit is the largest message a client may send.
+const wsReadLimit = 512 * 1024
+
+// wsBinaryFrame is a JSON control frame followed by a binary frame carrying raw bytes.
+// Both are written back to back so the client always sees the control frame first.
+type wsBinaryFrame struct {
+	control []byte
+	data    []byte
+}
+
+// wsSubscription describes an active reader subscription on a client
+type wsSubscription struct {
+	ReaderIndex int       `json:"readerIndex"`
+	ReaderName  string    `json:"readerName"`
+	IntervalMs  int       `json:"intervalMs"`
+	Mode        string    `json:"mode"`               // "poll", or "event" to read only when a card is placed or removed
+	Feedback    bool      `json:"feedback,omitempty"` // Beep/flash the reader on each card_detected
+	Since       time.Time `json:"since"`
+	Paused      bool      `json:"paused,omitempty"`
+
+	cancel context.CancelFunc // Stops the poll goroutine
+	resync bool               // Take the next poll as the last-seen state without sending events
+	wake   chan struct{}      // Event mode: interrupts the status change wait on resume
 }
 
 // WSHub manages all WebSocket connections
@@ -65,6 +115,9 @@ func (h *WSHub) Run() {
 	// Re-panic after logging since hub crash is fatal
 	defer logging.RecoverAndLog("WebSocket hub", true)
 
+	hubRunning.Store(true)
+	defer hubRunning.Store(false)
+
 	for {
 		select {
 		case client := <-h.register:
@@ -122,6 +175,14 @@ func InitWebSocket() http.HandlerFunc {
 			subscribed:  make(map[string]bool),
 			pollTickers: make(map[string]*time.Ticker),
 			lastUIDs:    make(map[string]string),
+
+			subscriptions: make(map[string]*wsSubscription),
+			binary:        make(chan wsBinaryFrame, 16),
+			operations:    make(map[string]context.CancelFunc),
+			closed:        make(chan struct{}),
+			remoteAddr:    r.RemoteAddr,
+			authenticated: requestAuthenticated(r),
+			connectedAt:   time.Now(),
 		}
 
 		wsHub.register <- client
@@ -136,18 +197,14 @@ func (c *WSClient) readPump() {
 	defer logging.RecoverAndLog("WebSocket readPump", false)
 	// Cleanup (runs first)
 	defer func() {
-		// Stop all polling
-		c.mu.Lock()
-		for _, ticker := range c.pollTickers {
-			ticker.Stop()
-		}
-		c.mu.Unlock()
+		c.disconnect()
+		readerClaims.releaseAll(c.claimOwner())
 
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(512 * 1024) // 512KB max message size
+	c.conn.SetReadLimit(wsReadLimit)
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -155,7 +212,7 @@ func (c *WSClient) readPump() {
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logging.Warn(logging.CatWebSocket, "WebSocket unexpected close", map[string]any{
@@ -166,6 +223,14 @@ func (c *WSClient) readPump() {
 			}
 			break
 		}
+		c.messagesReceived.Add(1)
+
+		if messageType == websocket.BinaryMessage {
+			c.handleBinaryMessage(message)
+			continue
+		}
+		// The binary frame must follow its request directly
+		c.dropPendingBinary()
 
 		var msg WSMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -177,6 +242,43 @@ func (c *WSClient) readPump() {
 	}
 }
 
+// disconnect stops the client's subscriptions and background operations and
+// marks it closed, so nothing sends to it once the hub closes its send
+// channel.
+func (c *WSClient) disconnect() {
+	c.mu.Lock()
+	for readerKey := range c.pollTickers {
+		c.stopSubscriptionLocked(readerKey)
+	}
+	// Abort long operations nobody is waiting for anymore, including queued
+	// ones and ones sent without an id
+	for _, cancel := range c.queuedOps {
+		cancel()
+	}
+	c.mu.Unlock()
+
+	close(c.closed)
+	// Wait for sends in flight, which give up now that closed is closed
+	c.sendMu.Lock()
+	c.sendMu.Unlock()
+}
+
+// queue hands a message to the write pump, dropping it once the client has
+// disconnected.
+func (c *WSClient) queue(message []byte) {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+	select {
+	case c.send <- message:
+	case <-c.closed:
+	}
+}
+
 func (c *WSClient) writePump() {
 	ticker := time.NewTicker(54 * time.Second)
 	// Recover from panics (runs last due to LIFO)
@@ -207,6 +309,16 @@ func (c *WSClient) writePump() {
 			if err := w.Close(); err != nil {
 				return
 			}
+			c.messagesSent.Add(1)
+		case frame := <-c.binary:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.TextMessage, frame.control); err != nil {
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.BinaryMessage, frame.data); err != nil {
+				return
+			}
+			c.messagesSent.Add(2)
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -222,11 +334,26 @@ func (c *WSClient) handleMessage(msg WSMessage) {
 		"id":   msg.ID,
 	})
 
+	if auditedWSMessages[msg.Type] {
+		c.startAudit(msg)
+	}
+
+	if readerMessageTypes[msg.Type] {
+		if err := c.checkReaderClaim(msg.Payload); err != nil {
+			c.sendCardError(msg.ID, err)
+			return
+		}
+	}
+
 	switch msg.Type {
 	case "list_readers":
 		c.handleListReaders(msg.ID)
 	case "read_card":
 		c.handleReadCard(msg.ID, msg.Payload)
+	case "list_cards":
+		c.handleListCards(msg.ID, msg.Payload)
+	case "read_ntag_config":
+		c.handleReadNTAGConfig(msg.ID, msg.Payload)
 	case "write_card":
 		c.handleWriteCard(msg.ID, msg.Payload)
 	case "erase_card":
@@ -239,34 +366,70 @@ func (c *WSClient) handleMessage(msg WSMessage) {
 		c.handleRemovePassword(msg.ID, msg.Payload)
 	case "write_records":
 		c.handleWriteRecords(msg.ID, msg.Payload)
+	case "write_raw_ndef":
+		c.handleWriteRawNDEF(msg.ID, msg.Payload)
+	case "read_raw_ndef":
+		c.handleReadRawNDEF(msg.ID, msg.Payload)
 	case "subscribe":
 		c.handleSubscribe(msg.ID, msg.Payload)
 	case "unsubscribe":
 		c.handleUnsubscribe(msg.ID, msg.Payload)
+	case "list_subscriptions":
+		c.handleListSubscriptions(msg.ID)
+	case "cancel_all_subscriptions":
+		c.handleCancelAllSubscriptions(msg.ID)
+	case "pause_subscription":
+		c.handlePauseSubscription(msg.ID, msg.Payload)
+	case "resume_subscription":
+		c.handleResumeSubscription(msg.ID, msg.Payload)
 	case "supported_readers":
 		c.handleSupportedReaders(msg.ID)
 	case "version":
 		c.handleVersion(msg.ID)
 	case "health":
 		c.handleHealth(msg.ID)
+	case "stats":
+		c.handleStats(msg.ID)
+	case "echo":
+		c.handleEcho(msg.ID, msg.Payload)
 	case "read_mifare_block":
 		c.handleReadMifareBlock(msg.ID, msg.Payload)
 	case "write_mifare_block":
 		c.handleWriteMifareBlock(msg.ID, msg.Payload)
 	case "write_mifare_blocks":
-		c.handleWriteMifareBlocks(msg.ID, msg.Payload)
+		c.runCancellable(msg.ID, payloadReaderName(msg.Payload), func(ctx context.Context) {
+			c.handleWriteMifareBlocks(ctx, msg.ID, msg.Payload)
+		})
 	case "read_ultralight_page":
 		c.handleReadUltralightPage(msg.ID, msg.Payload)
 	case "write_ultralight_page":
 		c.handleWriteUltralightPage(msg.ID, msg.Payload)
 	case "write_ultralight_pages":
-		c.handleWriteUltralightPages(msg.ID, msg.Payload)
+		c.runCancellable(msg.ID, payloadReaderName(msg.Payload), func(ctx context.Context) {
+			c.handleWriteUltralightPages(ctx, msg.ID, msg.Payload)
+		})
+	case "cancel":
+		c.handleCancel(msg.ID, msg.Payload)
+	case "scan_session":
+		c.handleScanSession(msg.ID, msg.Payload)
+	case "batch_write_session":
+		c.handleBatchWriteSession(msg.ID, msg.Payload)
+	case "erase_session":
+		c.handleEraseSession(msg.ID, msg.Payload)
+	case "increment_counter":
+		c.handleIncrementCounter(msg.ID, msg.Payload)
+	case "test_write":
+		c.handleTestWrite(msg.ID, msg.Payload)
 	case "derive_uid_key_aes":
 		c.handleDeriveUIDKeyAES(msg.ID, msg.Payload)
 	case "aes_encrypt_and_write_block":
 		c.handleAESEncryptAndWriteBlock(msg.ID, msg.Payload)
 	case "write_mifare_sector_trailer":
 		c.handleWriteMifareSectorTrailer(msg.ID, msg.Payload)
+	case "claim_reader":
+		c.handleClaimReader(msg.ID, msg.Payload)
+	case "release_reader":
+		c.handleReleaseReader(msg.ID, msg.Payload)
 	default:
 		logging.Warn(logging.CatWebSocket, "Unknown message type", map[string]any{
 			"type": msg.Type,
@@ -275,6 +438,76 @@ func (c *WSClient) handleMessage(msg WSMessage) {
 	}
 }
 
+// readerMessageTypes lists the messages that access a card on the reader named
+// by their readerIndex. They are refused while another client claims the reader.
+var readerMessageTypes = map[string]bool{
+	"read_card":                   true,
+	"list_cards":                  true,
+	"read_ntag_config":            true,
+	"write_card":                  true,
+	"erase_card":                  true,
+	"lock_card":                   true,
+	"set_password":                true,
+	"remove_password":             true,
+	"write_records":               true,
+	"write_raw_ndef":              true,
+	"read_raw_ndef":               true,
+	"scan_session":                true,
+	"batch_write_session":         true,
+	"erase_session":               true,
+	"read_mifare_block":           true,
+	"write_mifare_block":          true,
+	"write_mifare_blocks":         true,
+	"read_ultralight_page":        true,
+	"write_ultralight_page":       true,
+	"write_ultralight_pages":      true,
+	"increment_counter":           true,
+	"test_write":                  true,
+	"derive_uid_key_aes":          true,
+	"aes_encrypt_and_write_block": true,
+	"write_mifare_sector_trailer": true,
+}
+
+// claimOwner returns the identity this client holds reader claims under.
+func (c *WSClient) claimOwner() string {
+	return fmt.Sprintf("ws:%p", c)
+}
+
+// checkReaderClaim returns errReaderClaimed if the reader addressed by payload
+// is claimed by another client. Malformed payloads are left to the handler.
+func (c *WSClient) checkReaderClaim(payload json.RawMessage) error {
+	readerName := payloadReaderName(payload)
+	if readerName == "" {
+		return nil
+	}
+	return readerClaims.check(readerName, c.claimOwner())
+}
+
+// payloadReaderName returns the name of the reader addressed by payload's
+// readerIndex, or "" if the payload is malformed or the index out of range.
+func payloadReaderName(payload json.RawMessage) string {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return ""
+	}
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		return ""
+	}
+	return readers[req.ReaderIndex].Name
+}
+
+// claimedRead reads the card like core.GetCardUID, but fails while another
+// client claims the reader.
+func (c *WSClient) claimedRead(readerName string) (*core.Card, error) {
+	if err := readerClaims.check(readerName, c.claimOwner()); err != nil {
+		return nil, err
+	}
+	return core.GetCardUID(readerName)
+}
+
 func (c *WSClient) sendResponse(id string, msgType string, payload interface{}) {
 	payloadBytes, _ := json.Marshal(payload)
 	response := WSMessage{
@@ -283,7 +516,8 @@ func (c *WSClient) sendResponse(id string, msgType string, payload interface{})
 		Payload: payloadBytes,
 	}
 	responseBytes, _ := json.Marshal(response)
-	c.send <- responseBytes
+	c.finishAudit(id, msgType, "")
+	c.queue(responseBytes)
 }
 
 func (c *WSClient) sendError(id string, errMsg string) {
@@ -293,7 +527,108 @@ func (c *WSClient) sendError(id string, errMsg string) {
 		Error: errMsg,
 	}
 	responseBytes, _ := json.Marshal(response)
-	c.send <- responseBytes
+	c.finishAudit(id, "error", errMsg)
+	c.queue(responseBytes)
+}
+
+// sendCardError sends a card operation error, tagging a busy reader with code
+// READER_BUSY, a reader claimed by another client with READER_CLAIMED, a
+// write-protected tag with WRITE_PROTECTED, a tag whose capability container
+// is read-only with NDEF_READ_ONLY, a lost PC/SC service with
+// PCSC_UNAVAILABLE and a full operation queue with TOO_MANY_OPERATIONS.
+func (c *WSClient) sendCardError(id string, err error) {
+	c.sendCardErrorLog(id, err, nil)
+}
+
+// sendCardErrorLog is sendCardError with the request's APDU trace, if it
+// asked for one, in debugLog.
+func (c *WSClient) sendCardErrorLog(id string, err error, debugLog []string) {
+	c.sendCardErrorPayload(id, err, debugLog, nil)
+}
+
+// sendCardErrorPayload is sendCardErrorLog with a payload describing how far
+// the failed operation got, omitted when nil.
+func (c *WSClient) sendCardErrorPayload(id string, err error, debugLog []string, payload interface{}) {
+	response := WSMessage{
+		Type:     "error",
+		ID:       id,
+		Error:    err.Error(),
+		DebugLog: debugLog,
+	}
+	if payload != nil {
+		response.Payload, _ = json.Marshal(payload)
+	}
+	switch {
+	case errors.Is(err, core.ErrReaderBusy):
+		response.Code = "READER_BUSY"
+	case errors.Is(err, errReaderClaimed):
+		response.Code = "READER_CLAIMED"
+	case errors.Is(err, core.ErrWriteProtected):
+		response.Code = "WRITE_PROTECTED"
+	case errors.Is(err, core.ErrNDEFReadOnly):
+		response.Code = "NDEF_READ_ONLY"
+	case errors.Is(err, core.ErrPasswordRequired):
+		response.Code = "PASSWORD_REQUIRED"
+	case errors.Is(err, core.ErrPCSCUnavailable):
+		response.Code = "PCSC_UNAVAILABLE"
+	case errors.Is(err, core.ErrTooManyOperations):
+		response.Code = "TOO_MANY_OPERATIONS"
+	case errors.Is(err, core.ErrUIDMismatch):
+		response.Code = "UID_MISMATCH"
+	case errors.Is(err, errIdempotencyKeyReused):
+		response.Code = "IDEMPOTENCY_KEY_REUSED"
+	}
+	responseBytes, _ := json.Marshal(response)
+	c.finishAudit(id, "error", err.Error())
+	c.queue(responseBytes)
+}
+
+// sendBinaryResponse sends a JSON control frame followed by a binary frame with data.
+func (c *WSClient) sendBinaryResponse(id string, msgType string, payload interface{}, data []byte) {
+	payloadBytes, _ := json.Marshal(payload)
+	response := WSMessage{
+		Type:    msgType,
+		ID:      id,
+		Payload: payloadBytes,
+	}
+	responseBytes, _ := json.Marshal(response)
+	select {
+	case c.binary <- wsBinaryFrame{control: responseBytes, data: data}:
+	case <-c.closed:
+	}
+}
+
+// handleBinaryMessage routes a binary frame to the request that announced it.
+func (c *WSClient) handleBinaryMessage(data []byte) {
+	c.mu.Lock()
+	pending := c.pendingBinary
+	c.pendingBinary = nil
+	c.mu.Unlock()
+
+	if pending == nil {
+		c.sendError("", "unexpected binary frame")
+		return
+	}
+
+	switch pending.Type {
+	case "write_raw_ndef":
+		c.writeRawNDEF(pending.ID, pending.Payload, data)
+	default:
+		c.sendError(pending.ID, "binary frame not supported for "+pending.Type)
+	}
+}
+
+// dropPendingBinary fails a request still waiting for its binary frame when
+// another text frame arrives in between.
+func (c *WSClient) dropPendingBinary() {
+	c.mu.Lock()
+	pending := c.pendingBinary
+	c.pendingBinary = nil
+	c.mu.Unlock()
+
+	if pending != nil {
+		c.sendError(pending.ID, "expected a binary frame right after "+pending.Type)
+	}
 }
 
 func (c *WSClient) handleListReaders(id string) {
@@ -303,7 +638,17 @@ func (c *WSClient) handleListReaders(id string) {
 
 func (c *WSClient) handleReadCard(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int `json:"readerIndex"`
+		ReaderIndex int    `json:"readerIndex"`
+		Lang        string `json:"lang"`       // Preferred text record language
+		Format      string `json:"format"`     // Optional response format, e.g. "opt-sections"
+		MaxPages    int    `json:"maxPages"`   // Optional page/block read budget
+		IncludeRaw  bool   `json:"includeRaw"` // Attach raw record hex to records
+		Blocks      int    `json:"blocks"`     // Optional MIFARE Classic block count override
+		Prefer      string `json:"prefer"`     // Record type for the top-level data: "openprinttag", "url" or "text"
+		Encoding    string `json:"encoding"`   // Encoding of binary payloads: "hex" (default) or "base64"
+		ReadAll     bool   `json:"readAll"`    // Return every NDEF message on the tag, not just the first
+		BCC         bool   `json:"bcc"`        // Check the UID check bytes stored on the tag
+		Debug       string `json:"debug"`      // "apdu" returns this read's APDU exchanges and log entries in debugLog
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -316,13 +661,109 @@ func (c *WSClient) handleReadCard(id string, payload json.RawMessage) {
 		return
 	}
 
-	card, err := core.GetCardUID(readers[req.ReaderIndex].Name)
+	if !supportedCardFormats[req.Format] {
+		c.sendError(id, fmt.Sprintf("unsupported format: %s", req.Format))
+		return
+	}
+
+	if req.MaxPages < 0 {
+		c.sendError(id, "maxPages must not be negative")
+		return
+	}
+	if req.Blocks < 0 || req.Blocks > core.MaxMifareBlocks {
+		c.sendError(id, fmt.Sprintf("blocks must be between 1 and %d", core.MaxMifareBlocks))
+		return
+	}
+	if !supportedReadPreferences[req.Prefer] {
+		c.sendError(id, fmt.Sprintf("unsupported prefer value: %s", req.Prefer))
+		return
+	}
+	if !core.IsBinaryEncoding(req.Encoding) {
+		c.sendError(id, fmt.Sprintf("unsupported encoding: %s", req.Encoding))
+		return
+	}
+	if err := checkAPDUDebug(req.Debug); err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
+
+	trace := newAPDUDebug(req.Debug)
+	card, err := core.GetCardUIDWithOptions(readers[req.ReaderIndex].Name, core.ReadOptions{
+		Lang:         req.Lang,
+		MaxPages:     req.MaxPages,
+		IncludeRaw:   req.IncludeRaw,
+		MifareBlocks: req.Blocks,
+		Prefer:       req.Prefer,
+		Encoding:     req.Encoding,
+		ReadAll:      req.ReadAll,
+		CheckBCC:     req.BCC,
+		Trace:        trace,
+	})
+	debugLog := apduDebugLog(trace)
+	if err != nil {
+		c.sendCardErrorLog(id, err, debugLog)
+		return
+	}
+	card.DebugLog = debugLog
+
+	response, err := formatCard(card, req.Format)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
 
-	c.sendResponse(id, "card", card)
+	c.sendResponse(id, "card", response)
+}
+
+func (c *WSClient) handleListCards(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	uids, err := core.ListCardsInField(readers[req.ReaderIndex].Name)
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	c.sendResponse(id, "cards", map[string]interface{}{
+		"uids":  uids,
+		"count": len(uids),
+	})
+}
+
+func (c *WSClient) handleReadNTAGConfig(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	cfg, err := core.ReadNTAGConfig(readers[req.ReaderIndex].Name)
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	c.sendResponse(id, "ntag_config", cfg)
 }
 
 func (c *WSClient) handleWriteCard(id string, payload json.RawMessage) {
@@ -331,6 +772,18 @@ func (c *WSClient) handleWriteCard(id string, payload json.RawMessage) {
 		Data        string `json:"data"`
 		DataType    string `json:"dataType"`
 		URL         string `json:"url"`
+		Force       bool   `json:"force"`     // Overwrite a write-protected OpenPrintTag
+		ExpectUID   string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+
+		ControlTLVs []controlTLVRequest `json:"controlTlvs"` // Optional TLVs before the NDEF TLV
+
+		LongRecords bool `json:"longRecords"` // Advanced: force long-record encoding
+
+		IdempotencyKey string `json:"idempotencyKey"` // Retries with this key within the window aren't written again
+
+		Debug string `json:"debug"` // "apdu" returns this write's APDU exchanges and log entries in debugLog
+
+		Encode bool `json:"encode"` // Percent-encode characters not allowed in the URL instead of rejecting it
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -342,7 +795,12 @@ func (c *WSClient) handleWriteCard(id string, payload json.RawMessage) {
 		c.sendError(id, "reader index out of range")
 		return
 	}
+	if err := checkAPDUDebug(req.Debug); err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
 
+	// Data in a JSON string is always valid UTF-8, so untyped data is text
 	if req.DataType == "" {
 		req.DataType = "text"
 	}
@@ -366,22 +824,54 @@ func (c *WSClient) handleWriteCard(id string, payload json.RawMessage) {
 			return
 		}
 		dataBytes = []byte(req.Data)
+	case "cbor":
+		// Any JSON document, stored as CBOR
+		if !json.Valid([]byte(req.Data)) {
+			c.sendError(id, "invalid JSON data for cbor type")
+			return
+		}
+		dataBytes = []byte(req.Data)
 	default:
-		c.sendError(id, "invalid dataType (must be 'text', 'json', 'binary', 'url', or 'openprinttag')")
+		c.sendError(id, "invalid dataType (must be 'text', 'json', 'binary', 'url', 'cbor', or 'openprinttag')")
+		return
+	}
+
+	if err := prepareWriteURIs(&req.URL, &dataBytes, req.DataType, req.Encode); err != nil {
+		c.sendError(id, err.Error())
 		return
 	}
 
-	if err := core.WriteDataWithURL(readers[req.ReaderIndex].Name, dataBytes, req.DataType, req.URL); err != nil {
+	controlTLVs, err := parseControlTLVs(req.ControlTLVs)
+	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
 
-	c.sendResponse(id, "write_success", map[string]string{"success": "data written"})
+	trace := newAPDUDebug(req.Debug)
+	opts := core.WriteOptions{Force: req.Force, ControlTLVs: controlTLVs, ExpectUID: req.ExpectUID, LongRecords: req.LongRecords, Trace: trace}
+	readerName := readers[req.ReaderIndex].Name
+	fingerprint := idempotencyFingerprint("write", req.DataType, dataBytes, req.URL, req.Force, req.ExpectUID, req.ControlTLVs, req.LongRecords)
+	replayed, err := idempotentWrites.do(idempotencyScope(readerName, req.IdempotencyKey), fingerprint, func() error {
+		return core.WriteDataWithOptions(readerName, dataBytes, req.DataType, req.URL, opts)
+	})
+	debugLog := apduDebugLog(trace)
+	if err != nil {
+		c.sendCardErrorLog(id, err, debugLog)
+		return
+	}
+
+	result := writeResult("data written", replayed)
+	if debugLog != nil {
+		result["debugLog"] = debugLog
+	}
+	c.sendResponse(id, "write_success", result)
 }
 
 func (c *WSClient) handleEraseCard(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int `json:"readerIndex"`
+		ReaderIndex int    `json:"readerIndex"`
+		ExpectUID   string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+		Full        bool   `json:"full"`      // Zero all user memory
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -394,8 +884,9 @@ func (c *WSClient) handleEraseCard(id string, payload json.RawMessage) {
 		return
 	}
 
-	if err := core.EraseCard(readers[req.ReaderIndex].Name); err != nil {
-		c.sendError(id, err.Error())
+	opts := core.WriteOptions{ExpectUID: req.ExpectUID, ZeroMemory: req.Full}
+	if err := core.EraseCardWithOptions(readers[req.ReaderIndex].Name, opts); err != nil {
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -404,8 +895,9 @@ func (c *WSClient) handleEraseCard(id string, payload json.RawMessage) {
 
 func (c *WSClient) handleLockCard(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int  `json:"readerIndex"`
-		Confirm     bool `json:"confirm"`
+		ReaderIndex int    `json:"readerIndex"`
+		Confirm     bool   `json:"confirm"`
+		ExpectUID   string `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -423,8 +915,8 @@ func (c *WSClient) handleLockCard(id string, payload json.RawMessage) {
 		return
 	}
 
-	if err := core.LockCard(readers[req.ReaderIndex].Name); err != nil {
-		c.sendError(id, err.Error())
+	if err := core.LockCardWithOptions(readers[req.ReaderIndex].Name, core.WriteOptions{ExpectUID: req.ExpectUID}); err != nil {
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -466,7 +958,7 @@ func (c *WSClient) handleSetPassword(id string, payload json.RawMessage) {
 	}
 
 	if err := core.SetPassword(readers[req.ReaderIndex].Name, password, pack, byte(req.StartPage)); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -496,7 +988,7 @@ func (c *WSClient) handleRemovePassword(id string, payload json.RawMessage) {
 	}
 
 	if err := core.RemovePassword(readers[req.ReaderIndex].Name, password); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -507,6 +999,16 @@ func (c *WSClient) handleWriteRecords(id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int               `json:"readerIndex"`
 		Records     []core.NDEFRecord `json:"records"`
+		Force       bool              `json:"force"`     // Overwrite a write-protected OpenPrintTag
+		ExpectUID   string            `json:"expectUID"` // Abort with UID_MISMATCH unless the card has this UID
+
+		ControlTLVs []controlTLVRequest `json:"controlTlvs"` // Optional TLVs before the NDEF TLV
+
+		LongRecords bool `json:"longRecords"` // Advanced: force long-record encoding
+
+		IdempotencyKey string `json:"idempotencyKey"` // Retries with this key within the window aren't written again
+
+		Encode bool `json:"encode"` // Percent-encode characters not allowed in url records instead of rejecting them
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -524,18 +1026,81 @@ func (c *WSClient) handleWriteRecords(id string, payload json.RawMessage) {
 		return
 	}
 
-	if err := core.WriteMultipleRecords(readers[req.ReaderIndex].Name, req.Records); err != nil {
+	if req.Encode {
+		if err := encodeURIRecords(req.Records); err != nil {
+			c.sendError(id, err.Error())
+			return
+		}
+	}
+
+	if err := core.ValidateNDEFRecords(req.Records); err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
+
+	controlTLVs, err := parseControlTLVs(req.ControlTLVs)
+	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
 
-	c.sendResponse(id, "records_written", map[string]string{"success": "records written"})
+	opts := core.WriteOptions{Force: req.Force, ControlTLVs: controlTLVs, ExpectUID: req.ExpectUID, LongRecords: req.LongRecords}
+	readerName := readers[req.ReaderIndex].Name
+	fingerprint := idempotencyFingerprint("records", req.Records, req.Force, req.ExpectUID, req.ControlTLVs, req.LongRecords)
+	replayed, err := idempotentWrites.do(idempotencyScope(readerName, req.IdempotencyKey), fingerprint, func() error {
+		return core.WriteMultipleRecordsWithOptions(readerName, req.Records, opts)
+	})
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	c.sendResponse(id, "records_written", writeResult("records written", replayed))
 }
 
-func (c *WSClient) handleSubscribe(id string, payload json.RawMessage) {
+// writeResult is the payload of a successful write, marking writes answered
+// from the idempotency cache.
+func writeResult(success string, replayed bool) map[string]any {
+	result := map[string]any{"success": success}
+	if replayed {
+		result["replayed"] = true
+	}
+	return result
+}
+
+// handleWriteRawNDEF writes a raw NDEF message. The bytes are either sent
+// base64-encoded in "data", or with "binary": true in a binary frame that
+// immediately follows this message.
+func (c *WSClient) handleWriteRawNDEF(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int `json:"readerIndex"`
-		IntervalMs  int `json:"intervalMs"`
+		Data   string `json:"data"`   // Base64 NDEF message (when not using a binary frame)
+		Binary bool   `json:"binary"` // Data follows in a binary frame
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	if req.Binary {
+		c.mu.Lock()
+		c.pendingBinary = &WSMessage{Type: "write_raw_ndef", ID: id, Payload: payload}
+		c.mu.Unlock()
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		c.sendError(id, "invalid base64 data")
+		return
+	}
+
+	c.writeRawNDEF(id, payload, data)
+}
+
+func (c *WSClient) writeRawNDEF(id string, payload json.RawMessage, data []byte) {
+	var req struct {
+		ReaderIndex int  `json:"readerIndex"`
+		Force       bool `json:"force"` // Overwrite a write-protected OpenPrintTag
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -548,93 +1113,812 @@ func (c *WSClient) handleSubscribe(id string, payload json.RawMessage) {
 		return
 	}
 
-	if req.IntervalMs < 100 {
-		req.IntervalMs = 500 // Default 500ms
+	if err := core.WriteRawNDEFWithOptions(readers[req.ReaderIndex].Name, data, core.WriteOptions{Force: req.Force}); err != nil {
+		c.sendCardError(id, err)
+		return
 	}
 
-	readerKey := readers[req.ReaderIndex].Name
+	c.sendResponse(id, "raw_ndef_written", map[string]interface{}{
+		"success": true,
+		"length":  len(data),
+	})
+}
 
-	c.mu.Lock()
-	// Stop existing ticker if any
-	if ticker, ok := c.pollTickers[readerKey]; ok {
-		ticker.Stop()
+// handleReadRawNDEF reads the raw NDEF message from a card. With "binary": true
+// the bytes are sent in a binary frame after the "raw_ndef" control frame.
+func (c *WSClient) handleReadRawNDEF(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int  `json:"readerIndex"`
+		Binary      bool `json:"binary"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	card, err := core.GetCardUID(readers[req.ReaderIndex].Name)
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"uid":    card.UID,
+		"length": len(card.RawNDEF),
+	}
+	if req.Binary {
+		response["binary"] = true
+		c.sendBinaryResponse(id, "raw_ndef", response, card.RawNDEF)
+		return
+	}
+
+	response["data"] = base64.StdEncoding.EncodeToString(card.RawNDEF)
+	c.sendResponse(id, "raw_ndef", response)
+}
+
+func (c *WSClient) handleSubscribe(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int    `json:"readerIndex"`
+		IntervalMs  int    `json:"intervalMs"`
+		Feedback    bool   `json:"feedback"` // Confirm each detected card on the reader's LED/buzzer
+		Mode        string `json:"mode"`     // "poll" (default) or "event"
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = "poll"
+	}
+	if req.Mode != "poll" && req.Mode != "event" {
+		c.sendError(id, "mode must be 'poll' or 'event'")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	if req.IntervalMs < 100 {
+		req.IntervalMs = 500 // Default 500ms
+	}
+
+	readerKey := readers[req.ReaderIndex].Name
+	c.startSubscription(req.ReaderIndex, readerKey, req.IntervalMs, req.Mode, req.Feedback, nil)
+
+	logging.Info(logging.CatWebSocket, "Client subscribed to reader", map[string]any{
+		"reader":     readerKey,
+		"intervalMs": req.IntervalMs,
+		"mode":       req.Mode,
+		"feedback":   req.Feedback,
+	})
+	c.sendResponse(id, "subscribed", map[string]interface{}{
+		"readerIndex": req.ReaderIndex,
+		"intervalMs":  req.IntervalMs,
+		"mode":        req.Mode,
+		"feedback":    req.Feedback,
+	})
+}
+
+// startSubscription subscribes c to readerName, replacing any subscription it
+// already has on that reader, and starts the goroutine that reads the card and
+// sends card_detected and card_removed. read overrides how the card is read;
+// nil reads like core.GetCardUID.
+func (c *WSClient) startSubscription(readerIndex int, readerName string, intervalMs int, mode string, feedback bool, read func(readerName string) (*core.Card, error)) {
+	interval := time.Duration(intervalMs) * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	// Stop existing subscription if any
+	c.stopSubscriptionLocked(readerName)
+
+	c.subscribed[readerName] = true
+	ticker := time.NewTicker(interval)
+	c.pollTickers[readerName] = ticker
+	sub := &wsSubscription{
+		ReaderIndex: readerIndex,
+		ReaderName:  readerName,
+		IntervalMs:  intervalMs,
+		Mode:        mode,
+		Feedback:    feedback,
+		Since:       time.Now(),
+		cancel:      cancel,
+	}
+	if mode == "event" {
+		ticker.Stop()
+		sub.wake = make(chan struct{}, 1)
+	}
+	c.subscriptions[readerName] = sub
+	c.mu.Unlock()
+
+	if read == nil {
+		// With no card seen yet, a failed read may be a card still settling in the field
+		read = func(readerName string) (*core.Card, error) {
+			c.mu.Lock()
+			placed := c.lastUIDs[readerName] == ""
+			c.mu.Unlock()
+			if placed {
+				return core.GetPlacedCardUID(readerName)
+			}
+			return core.GetCardUID(readerName)
+		}
 	}
 
-	c.subscribed[readerKey] = true
-	ticker := time.NewTicker(time.Duration(req.IntervalMs) * time.Millisecond)
-	c.pollTickers[readerKey] = ticker
-	c.mu.Unlock()
-
 	// Start polling goroutine
 	go func() {
 		defer logging.RecoverAndLog("WebSocket poll goroutine", false)
 
-		for range ticker.C {
-			c.mu.Lock()
-			if !c.subscribed[readerKey] {
-				c.mu.Unlock()
+		poller := &cardPoller{read: read}
+		if mode == "event" {
+			c.runEventSubscription(ctx, sub, ticker, poller)
+		} else {
+			c.runPollSubscription(ctx, sub, ticker, poller)
+		}
+	}()
+}
+
+// runPollSubscription reads the card on every tick of the subscription's ticker.
+func (c *WSClient) runPollSubscription(ctx context.Context, sub *wsSubscription, ticker *time.Ticker, poller *cardPoller) {
+	timeout := pollReadTimeout(time.Duration(sub.IntervalMs) * time.Millisecond)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.pollSubscription(ctx, sub, poller, timeout)
+	}
+}
+
+// runEventSubscription reads the card once, then again only when PC/SC
+// reports a card placed or removed, so a card sitting on the reader causes no
+// reader traffic. While the reader is claimed or the subscription paused, or
+// after a read that timed out, it rechecks every interval. If the reader's
+// state can't be tracked it falls back to polling.
+func (c *WSClient) runEventSubscription(ctx context.Context, sub *wsSubscription, ticker *time.Ticker, poller *cardPoller) {
+	interval := time.Duration(sub.IntervalMs) * time.Millisecond
+	timeout := pollReadTimeout(interval)
+	for {
+		var present bool
+		switch c.pollSubscription(ctx, sub, poller, timeout) {
+		case pollCard:
+			present = true
+		case pollNoCard:
+			present = false
+		default:
+			select {
+			case <-ctx.Done():
 				return
+			case <-time.After(interval):
 			}
-			c.mu.Unlock()
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
 
-			card, err := core.GetCardUID(readerKey)
-			if err != nil {
-				// Card removed - send event if we previously had a card
-				c.mu.Lock()
-				if c.lastUIDs[readerKey] != "" {
-					c.lastUIDs[readerKey] = ""
-					c.mu.Unlock()
-					logging.Info(logging.CatCard, "Card removed", map[string]any{
-						"reader": readerKey,
-					})
-					c.sendResponse("", "card_removed", map[string]interface{}{
-						"readerIndex": req.ReaderIndex,
-						"readerName":  readerKey,
-					})
-				} else {
-					c.mu.Unlock()
-				}
-				continue
+		waitCtx, cancelWait := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-sub.wake: // Resumed: poll to resync
+				cancelWait()
+			case <-waitCtx.Done():
 			}
-
-			// Check if this is a new card
+		}()
+		_, err := waitCardPresenceChange(waitCtx, sub.ReaderName, present)
+		woken := waitCtx.Err() != nil
+		cancelWait()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !woken {
+			logging.Warn(logging.CatWebSocket, "Card status changes unavailable, subscription falls back to polling", map[string]any{
+				"reader": sub.ReaderName,
+				"error":  err.Error(),
+			})
 			c.mu.Lock()
-			lastUID := c.lastUIDs[readerKey]
-			if card.UID != lastUID {
-				c.lastUIDs[readerKey] = card.UID
-				c.mu.Unlock()
-				logData := map[string]any{
-					"reader": readerKey,
-					"uid":    card.UID,
-					"type":   card.Type,
-				}
-				if card.Data != "" {
-					logData["data"] = card.Data
-					logData["dataType"] = card.DataType
-				}
-				if card.URL != "" {
-					logData["url"] = card.URL
-				}
-				logging.Info(logging.CatCard, "Tag read", logData)
-				c.sendResponse("", "card_detected", map[string]interface{}{
-					"readerIndex": req.ReaderIndex,
-					"readerName":  readerKey,
-					"card":        card,
-				})
-			} else {
-				c.mu.Unlock()
+			sub.Mode = "poll"
+			if !sub.Paused {
+				ticker.Reset(interval)
 			}
+			c.mu.Unlock()
+			c.runPollSubscription(ctx, sub, ticker, poller)
+			return
 		}
+	}
+}
+
+// waitCardPresenceChange is core.WaitCardPresenceChange, overridden in tests.
+var waitCardPresenceChange = core.WaitCardPresenceChange
+
+// pollOutcome is what pollSubscription found.
+type pollOutcome int
+
+const (
+	pollSkipped pollOutcome = iota // Not read (claimed, paused or unsubscribed), or the read timed out
+	pollNoCard                     // No readable card on the reader
+	pollCard                       // A card was read
+)
+
+// pollSubscription reads the card on the subscription's reader once and sends
+// card_detected or card_removed when it changed. It skips the read while
+// another client claims the reader or the subscription is paused.
+func (c *WSClient) pollSubscription(ctx context.Context, sub *wsSubscription, poller *cardPoller, timeout time.Duration) pollOutcome {
+	if readerClaims.check(sub.ReaderName, c.claimOwner()) != nil {
+		return pollSkipped // Paused while another client claims the reader
+	}
+	c.mu.Lock()
+	paused := sub.Paused
+	c.mu.Unlock()
+	if paused {
+		return pollSkipped
+	}
+
+	card, err := poller.poll(ctx, sub.ReaderName, timeout)
+	if ctx.Err() != nil {
+		return pollSkipped // Unsubscribed while the read was running
+	}
+	if errors.Is(err, errPollTimeout) {
+		logging.Warn(logging.CatCard, "Card read timed out, abandoning it", map[string]any{
+			"reader":    sub.ReaderName,
+			"timeoutMs": timeout.Milliseconds(),
+		})
+		return pollSkipped
+	}
+	if errors.Is(err, errPollBusy) {
+		return pollSkipped // A previously abandoned read is still stuck
+	}
+	if c.syncPausedSubscription(sub, card, err) {
+		return pollSkipped
+	}
+	if err != nil {
+		// Card removed - send event if we previously had a card
+		c.mu.Lock()
+		if c.lastUIDs[sub.ReaderName] != "" {
+			c.lastUIDs[sub.ReaderName] = ""
+			c.mu.Unlock()
+			logging.Info(logging.CatCard, "Card removed", map[string]any{
+				"reader": sub.ReaderName,
+			})
+			c.sendResponse("", "card_removed", map[string]interface{}{
+				"readerIndex": sub.ReaderIndex,
+				"readerName":  sub.ReaderName,
+			})
+		} else {
+			c.mu.Unlock()
+		}
+		return pollNoCard
+	}
+
+	// Check if this is a new card
+	c.mu.Lock()
+	lastUID := c.lastUIDs[sub.ReaderName]
+	if card.UID != lastUID {
+		c.lastUIDs[sub.ReaderName] = card.UID
+		c.mu.Unlock()
+		logData := map[string]any{
+			"reader": sub.ReaderName,
+			"uid":    card.UID,
+			"type":   card.Type,
+		}
+		if card.Data != "" {
+			logData["data"] = card.Data
+			logData["dataType"] = card.DataType
+		}
+		if card.URL != "" {
+			logData["url"] = card.URL
+		}
+		logging.Info(logging.CatCard, "Tag read", logData)
+		c.sendResponse("", "card_detected", map[string]interface{}{
+			"readerIndex": sub.ReaderIndex,
+			"readerName":  sub.ReaderName,
+			"card":        card,
+		})
+		if sub.Feedback {
+			go signalReaderFeedback(sub.ReaderName)
+		}
+	} else {
+		c.mu.Unlock()
+	}
+	return pollCard
+}
+
+// signalReaderFeedback confirms a scan on the reader's LED/buzzer. It runs
+// after the event is sent so it never delays it; readers without feedback
+// support are skipped silently.
+func signalReaderFeedback(readerName string) {
+	defer logging.RecoverAndLog("reader feedback", false)
+
+	err := signalSuccess(readerName)
+	if err != nil && !errors.Is(err, core.ErrFeedbackUnsupported) {
+		logging.Debug(logging.CatReader, "Reader feedback failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// signalSuccess is core.SignalSuccess, overridden in tests.
+var signalSuccess = core.SignalSuccess
+
+// Errors returned by cardPoller.poll when no read result is available.
+var (
+	errPollTimeout = errors.New("card read timed out")
+	errPollBusy    = errors.New("previous card read still in progress")
+)
+
+// minPollReadTimeout is the shortest time a subscription read may run: a full
+// NDEF read of a large or slow tag can take over a second, which short
+// intervals would otherwise abandon every time.
+var minPollReadTimeout = 2 * time.Second
+
+// pollReadTimeout returns how long a subscription read may run before it is
+// abandoned: 80% of the poll interval, so the next tick can proceed, but at
+// least minPollReadTimeout. Ticks that pass during a longer read are skipped.
+func pollReadTimeout(interval time.Duration) time.Duration {
+	return max(interval*4/5, minPollReadTimeout)
+}
+
+type pollResult struct {
+	card *core.Card
+	err  error
+}
+
+// cardPoller runs subscription reads off the poll goroutine so a wedged reader
+// can be abandoned instead of blocking it. PC/SC calls can't be interrupted,
+// so an abandoned read keeps running; no new read starts until it returns.
+type cardPoller struct {
+	read    func(readerName string) (*core.Card, error)
+	pending chan pollResult // Result channel of an abandoned read, nil if none
+}
+
+// poll reads the card on readerName, giving up after timeout or when ctx is
+// cancelled.
+func (p *cardPoller) poll(ctx context.Context, readerName string, timeout time.Duration) (*core.Card, error) {
+	if p.pending != nil {
+		select {
+		case <-p.pending: // Abandoned read finished; its result is stale
+			p.pending = nil
+		default:
+			return nil, errPollBusy
+		}
+	}
+
+	result := make(chan pollResult, 1)
+	go func() {
+		r := pollResult{err: errors.New("card read panicked")}
+		defer func() { result <- r }()
+		defer logging.RecoverAndLog("WebSocket poll read", false)
+		r.card, r.err = p.read(readerName)
 	}()
 
-	logging.Info(logging.CatWebSocket, "Client subscribed to reader", map[string]any{
-		"reader":     readerKey,
-		"intervalMs": req.IntervalMs,
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-result:
+		return r.card, r.err
+	case <-timer.C:
+		p.pending = result
+		return nil, errPollTimeout
+	case <-ctx.Done():
+		p.pending = result
+		return nil, ctx.Err()
+	}
+}
+
+// Scan session defaults and limits.
+const (
+	scanSessionInterval       = 250 * time.Millisecond
+	scanSessionReadTimeout    = 2 * time.Second // Per-read limit; a stuck read is abandoned
+	scanSessionDefaultTimeout = 60 * time.Second
+	scanSessionMaxCount       = 1000
+)
+
+// handleScanSession collects count distinct cards presented one at a time on a
+// reader. Each new UID is emitted as card_detected; session_complete carries
+// the collected UIDs once count is reached or the timeout expires.
+func (c *WSClient) handleScanSession(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex      int  `json:"readerIndex"`
+		Count            int  `json:"count"`            // Number of distinct cards to collect
+		TimeoutMs        int  `json:"timeoutMs"`        // Session timeout (default 60s)
+		ReportDuplicates bool `json:"reportDuplicates"` // Send duplicate_uid when a scanned UID is presented again
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+	if req.Count < 1 || req.Count > scanSessionMaxCount {
+		c.sendError(id, fmt.Sprintf("count must be between 1 and %d", scanSessionMaxCount))
+		return
+	}
+	if req.TimeoutMs < 0 {
+		c.sendError(id, "timeoutMs must not be negative")
+		return
+	}
+
+	timeout := scanSessionDefaultTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	readerName := readers[req.ReaderIndex].Name
+
+	c.runCancellable(id, readerName, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		logging.Info(logging.CatWebSocket, "Scan session started", map[string]any{
+			"reader":    readerName,
+			"count":     req.Count,
+			"timeoutMs": timeout.Milliseconds(),
+		})
+		c.sendResponse(id, "scan_session_started", map[string]interface{}{
+			"readerIndex": req.ReaderIndex,
+			"count":       req.Count,
+			"timeoutMs":   timeout.Milliseconds(),
+		})
+
+		var onDuplicate func(card *core.Card, previous time.Time, sightings int)
+		duplicates := 0
+		if req.ReportDuplicates {
+			onDuplicate = func(card *core.Card, previous time.Time, sightings int) {
+				duplicates++
+				logging.Warn(logging.CatWebSocket, "Duplicate UID in scan session", map[string]any{
+					"reader":    readerName,
+					"uid":       card.UID,
+					"sightings": sightings,
+				})
+				c.sendResponse(id, "duplicate_uid", map[string]interface{}{
+					"readerIndex":    req.ReaderIndex,
+					"readerName":     readerName,
+					"uid":            card.UID,
+					"card":           card,
+					"previousSeenAt": previous.UTC().Format(time.RFC3339Nano),
+					"sightings":      sightings,
+				})
+			}
+		}
+
+		uids, err := runScanSession(ctx, c.claimedRead, readerName, req.Count, scanSessionInterval, func(card *core.Card, scanned int) {
+			c.sendResponse(id, "card_detected", map[string]interface{}{
+				"readerIndex": req.ReaderIndex,
+				"readerName":  readerName,
+				"card":        card,
+				"scanned":     scanned,
+				"count":       req.Count,
+			})
+		}, onDuplicate)
+
+		response := map[string]interface{}{
+			"readerIndex": req.ReaderIndex,
+			"uids":        uids,
+			"scanned":     len(uids),
+			"count":       req.Count,
+		}
+		if req.ReportDuplicates {
+			response["duplicates"] = duplicates
+		}
+		if errors.Is(err, context.Canceled) {
+			c.sendResponse(id, "canceled", response)
+			return
+		}
+		response["timedOut"] = errors.Is(err, context.DeadlineExceeded)
+
+		logging.Info(logging.CatWebSocket, "Scan session finished", map[string]any{
+			"reader":   readerName,
+			"scanned":  len(uids),
+			"timedOut": response["timedOut"],
+		})
+		c.sendResponse(id, "session_complete", response)
 	})
-	c.sendResponse(id, "subscribed", map[string]interface{}{
-		"readerIndex": req.ReaderIndex,
-		"intervalMs":  req.IntervalMs,
+}
+
+// runScanSession polls readerName until count distinct cards have been seen or
+// ctx is done, and returns the UIDs in scan order. A card is only counted once
+// the previous one has been removed; repeated UIDs are not counted again.
+// onCard is called for each newly counted card, and onDuplicate, if not nil,
+// each time a counted UID is presented again, with the time of its previous
+// sighting and how often it has been seen.
+func runScanSession(ctx context.Context, read func(readerName string) (*core.Card, error), readerName string, count int, interval time.Duration, onCard func(card *core.Card, scanned int), onDuplicate func(card *core.Card, previous time.Time, sightings int)) ([]string, error) {
+	uids := []string{}
+	lastSeen := make(map[string]time.Time)
+	sightings := make(map[string]int)
+
+	err := watchPresentedCards(ctx, read, readerName, interval, func(card *core.Card) bool {
+		now := time.Now()
+		previous, seen := lastSeen[card.UID]
+		lastSeen[card.UID] = now
+		sightings[card.UID]++
+		if seen {
+			if onDuplicate != nil {
+				onDuplicate(card, previous, sightings[card.UID])
+			}
+			return false
+		}
+		uids = append(uids, card.UID)
+		onCard(card, len(uids))
+		return len(uids) >= count
+	})
+	return uids, err
+}
+
+// watchPresentedCards polls readerName and calls onPresent each time a card is
+// placed on it, i.e. once per presentation rather than on every poll. It
+// returns nil once onPresent reports done, or ctx.Err().
+func watchPresentedCards(ctx context.Context, read func(readerName string) (*core.Card, error), readerName string, interval time.Duration, onPresent func(card *core.Card) (done bool)) error {
+	poller := &cardPoller{read: read}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	present := false
+	for {
+		card, err := poller.poll(ctx, readerName, scanSessionReadTimeout)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		switch {
+		case errors.Is(err, errPollTimeout), errors.Is(err, errPollBusy):
+			// Reader is slow or stuck; keep the current presence state
+		case err != nil:
+			present = false // No card on the reader
+		case !present:
+			present = true
+			if onPresent(card) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// batchWriteResult is the outcome of writing one tag in a batch write session.
+type batchWriteResult struct {
+	UID     string `json:"uid"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// tagSession describes a session that runs the same action on count distinct
+// tags presented one at a time. Batch write and erase sessions differ only in
+// the action and in the names of their messages.
+type tagSession struct {
+	op       string         // Audit operation, e.g. "erase_session"
+	label    string         // Log label, e.g. "Erase session"
+	started  string         // Message sent when the session starts
+	tag      string         // Message sent for every tag attempt
+	complete string         // Message sent when the session ends
+	done     string         // Field counting the tags done, e.g. "erased"
+	options  map[string]any // Request options echoed when the session starts
+	action   func(readerName, uid string) error
+}
+
+// runTagSession validates the reader index, count and timeout shared by tag
+// sessions, then runs s until count distinct tags are done or the timeout
+// expires. Each tag is checked against the reader claim, audited and, once
+// done, confirmed on the reader's LED/buzzer.
+func (c *WSClient) runTagSession(id string, readerIndex, count, timeoutMs int, s tagSession) {
+	readers := core.ListReaders()
+	if readerIndex < 0 || readerIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+	if count < 1 || count > scanSessionMaxCount {
+		c.sendError(id, fmt.Sprintf("count must be between 1 and %d", scanSessionMaxCount))
+		return
+	}
+	if timeoutMs < 0 {
+		c.sendError(id, "timeoutMs must not be negative")
+		return
+	}
+
+	timeout := scanSessionDefaultTimeout
+	if timeoutMs > 0 {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	readerName := readers[readerIndex].Name
+
+	run := func(uid string) error {
+		if err := readerClaims.check(readerName, c.claimOwner()); err != nil {
+			return err
+		}
+		err := s.action(readerName, uid)
+		c.auditTag(s.op, readerName, uid, err)
+		if err != nil {
+			return err
+		}
+		// Tells the operator the tag can be removed
+		signalReaderFeedback(readerName)
+		return nil
+	}
+
+	c.runCancellable(id, readerName, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		logData := map[string]any{
+			"reader":    readerName,
+			"count":     count,
+			"timeoutMs": timeout.Milliseconds(),
+		}
+		for k, v := range s.options {
+			logData[k] = v
+		}
+		logging.Info(logging.CatWebSocket, s.label+" started", logData)
+		started := map[string]interface{}{
+			"readerIndex": readerIndex,
+			"count":       count,
+			"timeoutMs":   timeout.Milliseconds(),
+		}
+		for k, v := range s.options {
+			started[k] = v
+		}
+		c.sendResponse(id, s.started, started)
+
+		failed := 0
+		uids, err := runBatchWriteSession(ctx, c.claimedRead, run, readerName, count, scanSessionInterval, func(result batchWriteResult, done int) {
+			if !result.Success {
+				failed++
+			}
+			c.sendResponse(id, s.tag, map[string]interface{}{
+				"readerIndex": readerIndex,
+				"result":      result,
+				s.done:        done,
+				"count":       count,
+			})
+		})
+
+		response := map[string]interface{}{
+			"readerIndex": readerIndex,
+			"uids":        uids,
+			s.done:        len(uids),
+			"failed":      failed,
+			"count":       count,
+		}
+		if errors.Is(err, context.Canceled) {
+			c.sendResponse(id, "canceled", response)
+			return
+		}
+		response["timedOut"] = errors.Is(err, context.DeadlineExceeded)
+
+		logging.Info(logging.CatWebSocket, s.label+" finished", map[string]any{
+			"reader":   readerName,
+			s.done:     len(uids),
+			"failed":   failed,
+			"timedOut": response["timedOut"],
+		})
+		c.sendResponse(id, s.complete, response)
+	})
+}
+
+// handleBatchWriteSession writes the same records to count distinct tags
+// presented one at a time, turning the reader into an encoding station. Each
+// tag is reported as tag_written and written tags are confirmed on the
+// reader's LED/buzzer; batch_write_complete carries the written UIDs once
+// count is reached or the timeout expires.
+func (c *WSClient) handleBatchWriteSession(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int               `json:"readerIndex"`
+		Records     []core.NDEFRecord `json:"records"`
+		Count       int               `json:"count"`     // Number of distinct tags to write
+		Verify      bool              `json:"verify"`    // Read each tag back and fail it on a mismatch
+		TimeoutMs   int               `json:"timeoutMs"` // Session timeout (default 60s)
+		Encode      bool              `json:"encode"`    // Percent-encode characters not allowed in url records
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	if len(req.Records) == 0 {
+		c.sendError(id, "records array cannot be empty")
+		return
+	}
+	if req.Encode {
+		if err := encodeURIRecords(req.Records); err != nil {
+			c.sendError(id, err.Error())
+			return
+		}
+	}
+	if err := core.ValidateNDEFRecords(req.Records); err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
+
+	c.runTagSession(id, req.ReaderIndex, req.Count, req.TimeoutMs, tagSession{
+		op:       "batch_write_session",
+		label:    "Batch write session",
+		started:  "batch_write_session_started",
+		tag:      "tag_written",
+		complete: "batch_write_complete",
+		done:     "written",
+		options:  map[string]any{"verify": req.Verify},
+		action: func(readerName, uid string) error {
+			// ExpectUID makes sure the write lands on the tag that was detected
+			opts := core.WriteOptions{ExpectUID: uid, Verify: req.Verify}
+			return core.WriteMultipleRecordsWithOptions(readerName, req.Records, opts)
+		},
+	})
+}
+
+// handleEraseSession erases count distinct tags presented one at a time, like
+// a batch write session that writes an empty NDEF message (or, with full,
+// zeroes all user memory). Each tag is reported as tag_erased and erased tags
+// are confirmed on the reader's LED/buzzer; erase_session_complete carries the
+// erased UIDs once count is reached or the timeout expires.
+func (c *WSClient) handleEraseSession(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int  `json:"readerIndex"`
+		Count       int  `json:"count"`     // Number of distinct tags to erase
+		Full        bool `json:"full"`      // Zero all user memory
+		TimeoutMs   int  `json:"timeoutMs"` // Session timeout (default 60s)
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	c.runTagSession(id, req.ReaderIndex, req.Count, req.TimeoutMs, tagSession{
+		op:       "erase_session",
+		label:    "Erase session",
+		started:  "erase_session_started",
+		tag:      "tag_erased",
+		complete: "erase_session_complete",
+		done:     "erased",
+		options:  map[string]any{"full": req.Full},
+		action: func(readerName, uid string) error {
+			// ExpectUID makes sure the erase lands on the tag that was detected
+			opts := core.WriteOptions{ExpectUID: uid, ZeroMemory: req.Full}
+			return core.EraseCardWithOptions(readerName, opts)
+		},
+	})
+}
+
+// runBatchWriteSession writes each tag presented on readerName until count
+// distinct tags have been written or ctx is done, and returns the written
+// UIDs in order. Tags already written are skipped; a tag whose write failed
+// is retried when presented again. onTag is called for every write attempt.
+// Erase sessions use it with an erasing write.
+func runBatchWriteSession(ctx context.Context, read func(readerName string) (*core.Card, error), write func(uid string) error, readerName string, count int, interval time.Duration, onTag func(result batchWriteResult, written int)) ([]string, error) {
+	uids := []string{}
+	written := make(map[string]bool)
+
+	err := watchPresentedCards(ctx, read, readerName, interval, func(card *core.Card) bool {
+		if written[card.UID] {
+			return false
+		}
+		result := batchWriteResult{UID: card.UID}
+		if err := write(card.UID); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			written[card.UID] = true
+			uids = append(uids, card.UID)
+		}
+		onTag(result, len(uids))
+		return len(uids) >= count
 	})
+	return uids, err
 }
 
 func (c *WSClient) handleUnsubscribe(id string, payload json.RawMessage) {
@@ -655,21 +1939,196 @@ func (c *WSClient) handleUnsubscribe(id string, payload json.RawMessage) {
 	readerKey := readers[req.ReaderIndex].Name
 
 	c.mu.Lock()
+	c.stopSubscriptionLocked(readerKey)
+	c.mu.Unlock()
+
+	logging.Info(logging.CatWebSocket, "Client unsubscribed from reader", map[string]any{
+		"reader": readerKey,
+	})
+	c.sendResponse(id, "unsubscribed", map[string]interface{}{
+		"readerIndex": req.ReaderIndex,
+	})
+}
+
+// stopSubscriptionLocked stops polling for a reader. Caller must hold c.mu.
+func (c *WSClient) stopSubscriptionLocked(readerKey string) {
 	c.subscribed[readerKey] = false
+	if sub, ok := c.subscriptions[readerKey]; ok && sub.cancel != nil {
+		sub.cancel()
+	}
 	if ticker, ok := c.pollTickers[readerKey]; ok {
 		ticker.Stop()
 		delete(c.pollTickers, readerKey)
 	}
-	c.mu.Unlock()
+	delete(c.subscriptions, readerKey)
+}
 
-	logging.Info(logging.CatWebSocket, "Client unsubscribed from reader", map[string]any{
+// handlePauseSubscription stops polling a subscribed reader, keeping the
+// subscription's settings and last-seen card for resume_subscription.
+func (c *WSClient) handlePauseSubscription(id string, payload json.RawMessage) {
+	c.setSubscriptionPaused(id, payload, true)
+}
+
+// handleResumeSubscription restarts polling a paused subscription. The card on
+// the reader at that point is taken as already seen, unless emitOnResume is
+// set, in which case it is sent as card_detected.
+func (c *WSClient) handleResumeSubscription(id string, payload json.RawMessage) {
+	c.setSubscriptionPaused(id, payload, false)
+}
+
+func (c *WSClient) setSubscriptionPaused(id string, payload json.RawMessage, pause bool) {
+	var req struct {
+		ReaderIndex  int  `json:"readerIndex"`
+		EmitOnResume bool `json:"emitOnResume"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+	readerKey := readers[req.ReaderIndex].Name
+
+	if !c.setPaused(readerKey, pause, req.EmitOnResume) {
+		c.sendError(id, "not subscribed to reader")
+		return
+	}
+
+	msgType, logMsg := "subscription_resumed", "Subscription resumed"
+	if pause {
+		msgType, logMsg = "subscription_paused", "Subscription paused"
+	}
+	logging.Info(logging.CatWebSocket, logMsg, map[string]any{
 		"reader": readerKey,
 	})
-	c.sendResponse(id, "unsubscribed", map[string]interface{}{
+	c.sendResponse(id, msgType, map[string]interface{}{
 		"readerIndex": req.ReaderIndex,
 	})
 }
 
+// setPaused stops or restarts the poll ticker of the subscription to
+// readerKey. It returns false if the client isn't subscribed to the reader.
+func (c *WSClient) setPaused(readerKey string, pause, emitOnResume bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sub, ok := c.subscriptions[readerKey]
+	ticker := c.pollTickers[readerKey]
+	if !ok || ticker == nil {
+		return false
+	}
+	if pause {
+		ticker.Stop()
+	} else if sub.Paused {
+		if emitOnResume {
+			c.lastUIDs[readerKey] = ""
+		} else {
+			sub.resync = true
+		}
+		if sub.Mode == "event" {
+			select {
+			case sub.wake <- struct{}{}:
+			default:
+			}
+		} else {
+			ticker.Reset(time.Duration(sub.IntervalMs) * time.Millisecond)
+		}
+	}
+	sub.Paused = pause
+	return true
+}
+
+// syncPausedSubscription discards a poll result that arrived after the
+// subscription was paused, and records the first result after a resume as
+// the last-seen card without sending events. It reports whether the result
+// was handled.
+func (c *WSClient) syncPausedSubscription(sub *wsSubscription, card *core.Card, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sub.Paused {
+		return true
+	}
+	if !sub.resync {
+		return false
+	}
+	sub.resync = false
+	if err != nil {
+		c.lastUIDs[sub.ReaderName] = ""
+	} else {
+		c.lastUIDs[sub.ReaderName] = card.UID
+	}
+	return true
+}
+
+func (c *WSClient) handleListSubscriptions(id string) {
+	c.sendResponse(id, "subscriptions", map[string]interface{}{
+		"subscriptions": c.activeSubscriptions(),
+	})
+}
+
+// activeSubscriptions returns the client's subscriptions ordered by reader index.
+func (c *WSClient) activeSubscriptions() []wsSubscription {
+	c.mu.Lock()
+	subs := make([]wsSubscription, 0, len(c.subscriptions))
+	for _, sub := range c.subscriptions {
+		subs = append(subs, *sub)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].ReaderIndex < subs[j].ReaderIndex
+	})
+	return subs
+}
+
+// handleStats reports this connection's server-side state, for debugging
+// client reconnect logic and proxies.
+func (c *WSClient) handleStats(id string) {
+	stats := map[string]interface{}{
+		"connectedAt":      c.connectedAt,
+		"messagesReceived": c.messagesReceived.Load(),
+		"messagesSent":     c.messagesSent.Load(),
+		"subscriptions":    c.activeSubscriptions(),
+		"readLimit":        wsReadLimit,
+	}
+	if c.conn != nil {
+		stats["remoteAddr"] = c.conn.RemoteAddr().String()
+	}
+	c.sendResponse(id, "stats", stats)
+}
+
+// handleEcho returns the payload unchanged, for measuring round-trip latency.
+func (c *WSClient) handleEcho(id string, payload json.RawMessage) {
+	response := WSMessage{
+		Type:    "echo",
+		ID:      id,
+		Payload: payload,
+	}
+	responseBytes, _ := json.Marshal(response)
+	c.queue(responseBytes)
+}
+
+func (c *WSClient) handleCancelAllSubscriptions(id string) {
+	c.mu.Lock()
+	cancelled := 0
+	for readerKey := range c.pollTickers {
+		c.stopSubscriptionLocked(readerKey)
+		cancelled++
+	}
+	c.mu.Unlock()
+
+	logging.Info(logging.CatWebSocket, "Client cancelled all subscriptions", map[string]any{
+		"count": cancelled,
+	})
+	c.sendResponse(id, "subscriptions_cancelled", map[string]interface{}{
+		"cancelled": cancelled,
+	})
+}
+
 func (c *WSClient) handleSupportedReaders(id string) {
 	readers, err := data.GetSupportedReaders()
 	if err != nil {
@@ -704,19 +2163,17 @@ func (c *WSClient) handleVersion(id string) {
 }
 
 func (c *WSClient) handleHealth(id string) {
-	readers := core.ListReaders()
-	c.sendResponse(id, "health", map[string]interface{}{
-		"status":      "ok",
-		"readerCount": len(readers),
-	})
+	c.sendResponse(id, "health", healthStatus())
 }
 
 func (c *WSClient) handleReadMifareBlock(id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int    `json:"readerIndex"`
 		Block       int    `json:"block"`
-		Key         string `json:"key"`     // Optional, hex string
-		KeyType     string `json:"keyType"` // Optional, "A" or "B"
+		Key         string `json:"key"`        // Optional, hex string
+		KeyType     string `json:"keyType"`    // Optional, "A" or "B"
+		KeyProfile  string `json:"keyProfile"` // Optional, stored key profile name instead of key
+		Decode      string `json:"decode"`     // Optional, "value" to decode a value block
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -729,32 +2186,34 @@ func (c *WSClient) handleReadMifareBlock(id string, payload json.RawMessage) {
 		return
 	}
 
-	key, err := parseMifareKey(req.Key)
+	if req.Decode != "" && req.Decode != "value" {
+		c.sendError(id, fmt.Sprintf("unsupported decode: %s", req.Decode))
+		return
+	}
+
+	key, keyType, err := resolveMifareKey(req.Key, req.KeyType, req.KeyProfile, c.authenticated)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
-	keyType := parseMifareKeyType(req.KeyType)
 
 	data, err := core.ReadMifareBlock(readers[req.ReaderIndex].Name, req.Block, key, keyType)
 	if err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
-	c.sendResponse(id, "mifare_block", map[string]interface{}{
-		"block": req.Block,
-		"data":  hex.EncodeToString(data),
-	})
+	c.sendResponse(id, "mifare_block", mifareBlockResponse(req.Block, data, req.Decode))
 }
 
 func (c *WSClient) handleWriteMifareBlock(id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int    `json:"readerIndex"`
 		Block       int    `json:"block"`
-		Data        string `json:"data"`    // Hex string, 32 chars = 16 bytes
-		Key         string `json:"key"`     // Optional, hex string
-		KeyType     string `json:"keyType"` // Optional, "A" or "B"
+		Data        string `json:"data"`       // Hex string, 32 chars = 16 bytes
+		Key         string `json:"key"`        // Optional, hex string
+		KeyType     string `json:"keyType"`    // Optional, "A" or "B"
+		KeyProfile  string `json:"keyProfile"` // Optional, stored key profile name instead of key
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -774,15 +2233,14 @@ func (c *WSClient) handleWriteMifareBlock(id string, payload json.RawMessage) {
 		return
 	}
 
-	key, err := parseMifareKey(req.Key)
+	key, keyType, err := resolveMifareKey(req.Key, req.KeyType, req.KeyProfile, c.authenticated)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
-	keyType := parseMifareKeyType(req.KeyType)
 
 	if err := core.WriteMifareBlock(readers[req.ReaderIndex].Name, req.Block, data, key, keyType); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -792,15 +2250,177 @@ func (c *WSClient) handleWriteMifareBlock(id string, payload json.RawMessage) {
 	})
 }
 
-func (c *WSClient) handleWriteMifareBlocks(id string, payload json.RawMessage) {
+// runCancellable runs a long operation in its own goroutine so a later
+// "cancel" message with the same request ID can abort it. Operations on the
+// same reader run one at a time, in the order they were sent; one canceled
+// while queued still waits its turn and then finds its context done. All are
+// canceled when the client disconnects, and queued ones then don't run.
+func (c *WSClient) runCancellable(id, readerName string, op func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if id != "" {
+		c.mu.Lock()
+		if c.operations == nil {
+			c.operations = make(map[string]context.CancelFunc)
+		}
+		if _, exists := c.operations[id]; exists {
+			c.mu.Unlock()
+			cancel()
+			c.sendError(id, "an operation with this id is already running")
+			return
+		}
+		c.operations[id] = cancel
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	if c.readerOps == nil {
+		c.readerOps = make(map[string]chan struct{})
+	}
+	prev, done := c.readerOps[readerName], make(chan struct{})
+	c.readerOps[readerName] = done
+	if c.queuedOps == nil {
+		c.queuedOps = make(map[chan struct{}]context.CancelFunc)
+	}
+	c.queuedOps[done] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		defer logging.RecoverAndLog("WebSocket operation", false)
+		defer func() {
+			cancel()
+			c.mu.Lock()
+			if id != "" {
+				delete(c.operations, id)
+			}
+			if c.readerOps[readerName] == done {
+				delete(c.readerOps, readerName)
+			}
+			delete(c.queuedOps, done)
+			c.mu.Unlock()
+			close(done)
+		}()
+
+		if prev != nil {
+			<-prev
+		}
+		select {
+		case <-c.closed:
+			return // The client left while this was queued
+		default:
+		}
+		op(ctx)
+	}()
+}
+
+// handleCancel aborts the running operation whose request ID is given in the
+// payload. The operation itself replies with a "canceled" response.
+func (c *WSClient) handleCancel(id string, payload json.RawMessage) {
+	var req struct {
+		ID string `json:"id"` // Request ID of the operation to cancel
+	}
+	if err := json.Unmarshal(payload, &req); err != nil || req.ID == "" {
+		c.sendError(id, "invalid payload: id is required")
+		return
+	}
+
+	c.mu.Lock()
+	cancel, ok := c.operations[req.ID]
+	c.mu.Unlock()
+	if !ok {
+		c.sendError(id, "no running operation with id "+req.ID)
+		return
+	}
+
+	cancel()
+	logging.Info(logging.CatWebSocket, "Operation cancel requested", map[string]any{
+		"operation": req.ID,
+	})
+	c.sendResponse(id, "cancel_requested", map[string]interface{}{
+		"id": req.ID,
+	})
+}
+
+// handleClaimReader gives this client exclusive use of a reader for a lease.
+// Claiming again renews the lease; the claim is dropped on disconnect.
+func (c *WSClient) handleClaimReader(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+		LeaseMs     int `json:"leaseMs"` // Lease duration (default 30s, max 10min)
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	lease, err := claimLease(req.LeaseMs)
+	if err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
+
+	claim, err := readerClaims.claim(readers[req.ReaderIndex].Name, c.claimOwner(), lease)
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	logging.Info(logging.CatWebSocket, "Reader claimed", map[string]any{
+		"reader":  claim.Reader,
+		"leaseMs": claim.LeaseMs,
+	})
+	c.sendResponse(id, "reader_claimed", map[string]interface{}{
+		"readerIndex": req.ReaderIndex,
+		"expiresAt":   claim.ExpiresAt,
+		"leaseMs":     claim.LeaseMs,
+	})
+}
+
+func (c *WSClient) handleReleaseReader(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	if !readerClaims.release(readers[req.ReaderIndex].Name, c.claimOwner()) {
+		c.sendError(id, "reader is not claimed by this client")
+		return
+	}
+
+	logging.Info(logging.CatWebSocket, "Reader claim released", map[string]any{
+		"reader": readers[req.ReaderIndex].Name,
+	})
+	c.sendResponse(id, "reader_released", map[string]interface{}{
+		"readerIndex": req.ReaderIndex,
+	})
+}
+
+func (c *WSClient) handleWriteMifareBlocks(ctx context.Context, id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int `json:"readerIndex"`
 		Blocks      []struct {
 			Block int    `json:"block"`
 			Data  string `json:"data"` // Hex string, 32 chars = 16 bytes
 		} `json:"blocks"`
-		Key     string `json:"key"`     // Hex string, 12 chars = 6 bytes
-		KeyType string `json:"keyType"` // "A" or "B"
+		Key        string `json:"key"`        // Hex string, 12 chars = 6 bytes
+		KeyType    string `json:"keyType"`    // "A" or "B"
+		KeyProfile string `json:"keyProfile"` // Optional, stored key profile name instead of key
+		ExpectUID  string `json:"expectUID"`  // Abort with UID_MISMATCH unless the card has this UID
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -832,16 +2452,15 @@ func (c *WSClient) handleWriteMifareBlocks(id string, payload json.RawMessage) {
 		}
 	}
 
-	key, err := parseMifareKey(req.Key)
+	key, keyType, err := resolveMifareKey(req.Key, req.KeyType, req.KeyProfile, c.authenticated)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
-	keyType := parseMifareKeyType(req.KeyType)
 
-	results, err := core.WriteMifareBlocks(readers[req.ReaderIndex].Name, blocks, key, keyType)
-	if err != nil {
-		c.sendError(id, err.Error())
+	results, err := core.WriteMifareBlocksContext(ctx, readers[req.ReaderIndex].Name, blocks, key, keyType, req.ExpectUID)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -853,7 +2472,11 @@ func (c *WSClient) handleWriteMifareBlocks(id string, payload json.RawMessage) {
 		}
 	}
 
-	c.sendResponse(id, "mifare_write_blocks_success", map[string]interface{}{
+	responseType := "mifare_write_blocks_success"
+	if err != nil {
+		responseType = "canceled"
+	}
+	c.sendResponse(id, responseType, map[string]interface{}{
 		"results": results,
 		"written": successCount,
 		"total":   len(results),
@@ -885,7 +2508,7 @@ func (c *WSClient) handleReadUltralightPage(id string, payload json.RawMessage)
 
 	data, err := core.ReadUltralightPage(readers[req.ReaderIndex].Name, req.Page, password)
 	if err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -926,7 +2549,7 @@ func (c *WSClient) handleWriteUltralightPage(id string, payload json.RawMessage)
 	}
 
 	if err := core.WriteUltralightPage(readers[req.ReaderIndex].Name, req.Page, data, password); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -936,14 +2559,17 @@ func (c *WSClient) handleWriteUltralightPage(id string, payload json.RawMessage)
 	})
 }
 
-func (c *WSClient) handleWriteUltralightPages(id string, payload json.RawMessage) {
+func (c *WSClient) handleWriteUltralightPages(ctx context.Context, id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int `json:"readerIndex"`
 		Pages       []struct {
 			Page int    `json:"page"`
 			Data string `json:"data"` // Hex string, 8 chars = 4 bytes
 		} `json:"pages"`
-		Password string `json:"password"` // Optional, hex string, 8 chars = 4 bytes
+		Password        string `json:"password"`        // Optional, hex string, 8 chars = 4 bytes
+		RollbackOnError bool   `json:"rollbackOnError"` // Restore written pages if a later page fails
+		Verify          bool   `json:"verify"`          // Read each page back and fail it on a mismatch
+		ExpectUID       string `json:"expectUID"`       // Abort with UID_MISMATCH unless the card has this UID
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -981,9 +2607,9 @@ func (c *WSClient) handleWriteUltralightPages(id string, payload json.RawMessage
 		return
 	}
 
-	results, err := core.WriteUltralightPages(readers[req.ReaderIndex].Name, pages, password)
-	if err != nil {
-		c.sendError(id, err.Error())
+	results, rollback, err := core.WriteUltralightPagesContext(ctx, readers[req.ReaderIndex].Name, pages, password, req.RollbackOnError, req.Verify, req.ExpectUID)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -995,13 +2621,98 @@ func (c *WSClient) handleWriteUltralightPages(id string, payload json.RawMessage
 		}
 	}
 
-	c.sendResponse(id, "ultralight_write_pages_success", map[string]interface{}{
+	response := map[string]interface{}{
 		"results": results,
 		"written": successCount,
 		"total":   len(results),
+	}
+	if rollback != nil {
+		response["rollback"] = rollback
+	}
+
+	responseType := "ultralight_write_pages_success"
+	if err != nil {
+		responseType = "canceled"
+	}
+	c.sendResponse(id, responseType, response)
+}
+
+func (c *WSClient) handleIncrementCounter(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int    `json:"readerIndex"`
+		Page        int    `json:"page"`
+		Password    string `json:"password"` // Optional, hex string, 8 chars = 4 bytes
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	password, err := parseUltralightPassword(req.Password)
+	if err != nil {
+		c.sendError(id, err.Error())
+		return
+	}
+
+	value, err := core.IncrementTagCounter(readers[req.ReaderIndex].Name, req.Page, password)
+	if err != nil {
+		c.sendCardError(id, err)
+		return
+	}
+
+	c.sendResponse(id, "counter", map[string]interface{}{
+		"page":  req.Page,
+		"value": value,
 	})
 }
 
+func (c *WSClient) handleTestWrite(id string, payload json.RawMessage) {
+	var req struct {
+		ReaderIndex int `json:"readerIndex"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.sendError(id, "invalid payload")
+		return
+	}
+
+	readers := core.ListReaders()
+	if req.ReaderIndex < 0 || req.ReaderIndex >= len(readers) {
+		c.sendError(id, "reader index out of range")
+		return
+	}
+
+	readerName := readers[req.ReaderIndex].Name
+	result, err := core.TestWrite(readerName)
+	if err != nil && (result == nil || errors.Is(err, core.ErrReaderBusy)) {
+		c.sendCardError(id, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":  err == nil,
+		"page":     result.Page,
+		"method":   result.Method,
+		"restored": result.Restored,
+	}
+	if err != nil {
+		// Report which page was tried and whether it was restored, as over HTTP
+		logging.Warn(logging.CatWebSocket, "Write test failed", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		c.sendCardErrorPayload(id, err, nil, response)
+		return
+	}
+
+	c.sendResponse(id, "test_write", response)
+}
+
 func (c *WSClient) handleDeriveUIDKeyAES(id string, payload json.RawMessage) {
 	var req struct {
 		ReaderIndex int    `json:"readerIndex"`
@@ -1026,7 +2737,7 @@ func (c *WSClient) handleDeriveUIDKeyAES(id string, payload json.RawMessage) {
 
 	key, err := core.DeriveUIDKeyAES(readers[req.ReaderIndex].Name, aesKey)
 	if err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -1037,12 +2748,13 @@ func (c *WSClient) handleDeriveUIDKeyAES(id string, payload json.RawMessage) {
 
 func (c *WSClient) handleAESEncryptAndWriteBlock(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int    `json:"readerIndex"`
-		Block       int    `json:"block"`
-		Data        string `json:"data"`        // Hex string, 32 chars = 16 bytes
-		AESKey      string `json:"aesKey"`      // Hex string, 32 chars = 16 bytes
-		AuthKey     string `json:"authKey"`     // Hex string, 12 chars = 6 bytes
-		AuthKeyType string `json:"authKeyType"` // "A" or "B"
+		ReaderIndex    int    `json:"readerIndex"`
+		Block          int    `json:"block"`
+		Data           string `json:"data"`           // Hex string, 32 chars = 16 bytes
+		AESKey         string `json:"aesKey"`         // Hex string, 32 chars = 16 bytes
+		AuthKey        string `json:"authKey"`        // Hex string, 12 chars = 6 bytes
+		AuthKeyType    string `json:"authKeyType"`    // "A" or "B"
+		AuthKeyProfile string `json:"authKeyProfile"` // Optional, stored key profile name instead of authKey
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -1067,15 +2779,14 @@ func (c *WSClient) handleAESEncryptAndWriteBlock(id string, payload json.RawMess
 		return
 	}
 
-	authKey, err := parseMifareKey(req.AuthKey)
+	authKey, authKeyType, err := resolveMifareKey(req.AuthKey, req.AuthKeyType, req.AuthKeyProfile, c.authenticated)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
-	authKeyType := parseMifareKeyType(req.AuthKeyType)
 
 	if err := core.AESEncryptAndWriteBlock(readers[req.ReaderIndex].Name, req.Block, data, aesKey, authKey, authKeyType); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
@@ -1087,13 +2798,14 @@ func (c *WSClient) handleAESEncryptAndWriteBlock(id string, payload json.RawMess
 
 func (c *WSClient) handleWriteMifareSectorTrailer(id string, payload json.RawMessage) {
 	var req struct {
-		ReaderIndex int    `json:"readerIndex"`
-		Block       int    `json:"block"`       // Sector trailer block number
-		KeyA        string `json:"keyA"`        // New Key A, 12 hex chars = 6 bytes
-		KeyB        string `json:"keyB"`        // New Key B, 12 hex chars = 6 bytes
-		AccessBits  string `json:"accessBits"`  // Access bits, 6 or 8 hex chars (optional, preserves existing if empty)
-		AuthKey     string `json:"authKey"`     // Key for authentication, 12 hex chars = 6 bytes
-		AuthKeyType string `json:"authKeyType"` // "A" or "B"
+		ReaderIndex    int    `json:"readerIndex"`
+		Block          int    `json:"block"`          // Sector trailer block number
+		KeyA           string `json:"keyA"`           // New Key A, 12 hex chars = 6 bytes
+		KeyB           string `json:"keyB"`           // New Key B, 12 hex chars = 6 bytes
+		AccessBits     string `json:"accessBits"`     // Access bits, 6 or 8 hex chars (optional, preserves existing if empty)
+		AuthKey        string `json:"authKey"`        // Key for authentication, 12 hex chars = 6 bytes
+		AuthKeyType    string `json:"authKeyType"`    // "A" or "B"
+		AuthKeyProfile string `json:"authKeyProfile"` // Optional, stored key profile name instead of authKey
 	}
 	if err := json.Unmarshal(payload, &req); err != nil {
 		c.sendError(id, "invalid payload")
@@ -1128,15 +2840,14 @@ func (c *WSClient) handleWriteMifareSectorTrailer(id string, payload json.RawMes
 		}
 	}
 
-	authKey, err := parseMifareKey(req.AuthKey)
+	authKey, authKeyType, err := resolveMifareKey(req.AuthKey, req.AuthKeyType, req.AuthKeyProfile, c.authenticated)
 	if err != nil {
 		c.sendError(id, err.Error())
 		return
 	}
-	authKeyType := parseMifareKeyType(req.AuthKeyType)
 
 	if err := core.WriteSectorTrailer(readers[req.ReaderIndex].Name, req.Block, keyA, keyB, accessBits, authKey, authKeyType); err != nil {
-		c.sendError(id, err.Error())
+		c.sendCardError(id, err)
 		return
 	}
 
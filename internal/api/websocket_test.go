@@ -1,7 +1,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/SimplyPrint/nfc-agent/internal/core"
 	"github.com/gorilla/websocket"
 )
 
@@ -358,6 +362,7 @@ func TestWSClient_handleVersion(t *testing.T) {
 }
 
 func TestWSClient_handleHealth(t *testing.T) {
+	stubPCSCStatus(t, core.PCSCStatus{Available: true})
 	client := &WSClient{
 		send: make(chan []byte, 256),
 	}
@@ -568,6 +573,86 @@ func TestWSClient_handleWriteRecords_EmptyRecords(t *testing.T) {
 	}
 }
 
+func TestWSClient_handleListSubscriptions(t *testing.T) {
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		subscribed:    make(map[string]bool),
+		pollTickers:   make(map[string]*time.Ticker),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	client.subscriptions["Reader B"] = &wsSubscription{ReaderIndex: 1, ReaderName: "Reader B", IntervalMs: 250}
+	client.subscriptions["Reader A"] = &wsSubscription{ReaderIndex: 0, ReaderName: "Reader A", IntervalMs: 500}
+
+	client.handleListSubscriptions("test-id")
+
+	select {
+	case msg := <-client.send:
+		var decoded struct {
+			Type    string `json:"type"`
+			Payload struct {
+				Subscriptions []wsSubscription `json:"subscriptions"`
+			} `json:"payload"`
+		}
+		json.Unmarshal(msg, &decoded)
+
+		if decoded.Type != "subscriptions" {
+			t.Errorf("expected type 'subscriptions', got '%s'", decoded.Type)
+		}
+		subs := decoded.Payload.Subscriptions
+		if len(subs) != 2 {
+			t.Fatalf("expected 2 subscriptions, got %d", len(subs))
+		}
+		if subs[0].ReaderName != "Reader A" || subs[0].IntervalMs != 500 {
+			t.Errorf("unexpected first subscription: %+v", subs[0])
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for response")
+	}
+}
+
+func TestWSClient_handleCancelAllSubscriptions(t *testing.T) {
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		subscribed:    make(map[string]bool),
+		pollTickers:   make(map[string]*time.Ticker),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	for _, name := range []string{"Reader A", "Reader B"} {
+		client.subscribed[name] = true
+		client.pollTickers[name] = time.NewTicker(time.Hour)
+		client.subscriptions[name] = &wsSubscription{ReaderName: name, IntervalMs: 500}
+	}
+
+	client.handleCancelAllSubscriptions("test-id")
+
+	if len(client.pollTickers) != 0 || len(client.subscriptions) != 0 {
+		t.Errorf("expected all subscriptions to be removed, got %d tickers and %d subscriptions",
+			len(client.pollTickers), len(client.subscriptions))
+	}
+	if client.subscribed["Reader A"] || client.subscribed["Reader B"] {
+		t.Error("expected readers to be marked unsubscribed")
+	}
+
+	select {
+	case msg := <-client.send:
+		var decoded WSMessage
+		json.Unmarshal(msg, &decoded)
+
+		if decoded.Type != "subscriptions_cancelled" {
+			t.Errorf("expected type 'subscriptions_cancelled', got '%s'", decoded.Type)
+		}
+		var payload map[string]int
+		json.Unmarshal(decoded.Payload, &payload)
+		if payload["cancelled"] != 2 {
+			t.Errorf("expected 2 cancelled, got %d", payload["cancelled"])
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for response")
+	}
+}
+
 func TestInitWebSocket(t *testing.T) {
 	handler := InitWebSocket()
 
@@ -747,6 +832,686 @@ func TestWebSocket_ConcurrentClients(t *testing.T) {
 	}
 }
 
+func TestWSClient_sendBinaryResponse(t *testing.T) {
+	client := &WSClient{
+		binary: make(chan wsBinaryFrame, 1),
+	}
+
+	client.sendBinaryResponse("bin-id", "raw_ndef", map[string]int{"length": 3}, []byte{0xD0, 0x00, 0x00})
+
+	select {
+	case frame := <-client.binary:
+		var decoded WSMessage
+		if err := json.Unmarshal(frame.control, &decoded); err != nil {
+			t.Fatalf("failed to unmarshal control frame: %v", err)
+		}
+		if decoded.Type != "raw_ndef" || decoded.ID != "bin-id" {
+			t.Errorf("unexpected control frame: %+v", decoded)
+		}
+		if len(frame.data) != 3 {
+			t.Errorf("expected 3 data bytes, got %d", len(frame.data))
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for binary frame")
+	}
+}
+
+func TestWSClient_handleBinaryMessage(t *testing.T) {
+	client := &WSClient{
+		send: make(chan []byte, 256),
+	}
+
+	// A binary frame without a preceding request is rejected
+	client.handleBinaryMessage([]byte{0xD0, 0x00, 0x00})
+
+	select {
+	case msg := <-client.send:
+		var decoded WSMessage
+		json.Unmarshal(msg, &decoded)
+		if decoded.Type != "error" || decoded.Error != "unexpected binary frame" {
+			t.Errorf("unexpected response: %+v", decoded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for response")
+	}
+
+	// write_raw_ndef with binary: true waits for the data frame
+	client.handleWriteRawNDEF("raw-id", json.RawMessage(`{"readerIndex": 99, "binary": true}`))
+
+	select {
+	case msg := <-client.send:
+		t.Fatalf("expected no response before the binary frame, got %s", msg)
+	default:
+	}
+
+	client.handleBinaryMessage([]byte{0xD0, 0x00, 0x00})
+
+	select {
+	case msg := <-client.send:
+		var decoded WSMessage
+		json.Unmarshal(msg, &decoded)
+		if decoded.ID != "raw-id" {
+			t.Errorf("expected response for 'raw-id', got '%s'", decoded.ID)
+		}
+		if decoded.Error != "reader index out of range" {
+			t.Errorf("expected reader index error, got '%s'", decoded.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for response")
+	}
+
+	if client.pendingBinary != nil {
+		t.Error("pending binary request should be cleared")
+	}
+}
+
+func TestWSClient_dropPendingBinary(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.handleWriteRawNDEF("raw-1", json.RawMessage(`{"readerIndex": 0, "binary": true}`))
+	client.dropPendingBinary() // Any text frame before the binary frame
+	if decoded := readWSMessage(t, client); decoded.ID != "raw-1" || decoded.Type != "error" {
+		t.Errorf("expected an error for 'raw-1', got %s '%s'", decoded.Type, decoded.ID)
+	}
+
+	// A later binary frame is no longer taken as its data
+	client.handleBinaryMessage([]byte{0xD0, 0x00, 0x00})
+	if decoded := readWSMessage(t, client); decoded.ID != "" || decoded.Error != "unexpected binary frame" {
+		t.Errorf("expected an unexpected binary frame error, got '%s' for '%s'", decoded.Error, decoded.ID)
+	}
+
+	client.dropPendingBinary()
+	select {
+	case msg := <-client.send:
+		t.Errorf("expected no response without a pending request, got %s", msg)
+	default:
+	}
+}
+
+func TestCardPoller_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	poller := &cardPoller{read: func(readerName string) (*core.Card, error) {
+		calls++
+		if calls == 1 {
+			<-release // Wedged reader
+		}
+		return &core.Card{UID: "04aabbcc"}, nil
+	}}
+	ctx := context.Background()
+
+	if _, err := poller.poll(ctx, "Reader A", 20*time.Millisecond); !errors.Is(err, errPollTimeout) {
+		t.Fatalf("expected errPollTimeout, got %v", err)
+	}
+	// The abandoned read is still running, so no new read is started
+	if _, err := poller.poll(ctx, "Reader A", 20*time.Millisecond); !errors.Is(err, errPollBusy) {
+		t.Fatalf("expected errPollBusy, got %v", err)
+	}
+
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+
+	card, err := poller.poll(ctx, "Reader A", time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if card.UID != "04aabbcc" || calls != 2 {
+		t.Errorf("expected a fresh read, got %+v after %d calls", card, calls)
+	}
+}
+
+func TestCardPoller_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	poller := &cardPoller{read: func(readerName string) (*core.Card, error) {
+		<-release
+		return nil, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := poller.poll(ctx, "Reader A", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPollReadTimeout(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     time.Duration
+	}{
+		{100 * time.Millisecond, minPollReadTimeout},
+		{500 * time.Millisecond, minPollReadTimeout},
+		{5 * time.Second, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := pollReadTimeout(tt.interval); got != tt.want {
+			t.Errorf("pollReadTimeout(%v) = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestWSClient_PauseResumeSubscription(t *testing.T) {
+	newClient := func() (*WSClient, *wsSubscription) {
+		client := &WSClient{
+			send:          make(chan []byte, 256),
+			subscribed:    make(map[string]bool),
+			pollTickers:   make(map[string]*time.Ticker),
+			lastUIDs:      map[string]string{"Reader A": "04AABB"},
+			subscriptions: make(map[string]*wsSubscription),
+		}
+		sub := &wsSubscription{ReaderName: "Reader A", IntervalMs: 500}
+		client.subscribed["Reader A"] = true
+		client.pollTickers["Reader A"] = time.NewTicker(time.Hour)
+		client.subscriptions["Reader A"] = sub
+		t.Cleanup(client.pollTickers["Reader A"].Stop)
+		return client, sub
+	}
+	card := &core.Card{UID: "04CCDD"}
+
+	client, sub := newClient()
+	if client.setPaused("Reader B", true, false) {
+		t.Error("expected pausing an unsubscribed reader to fail")
+	}
+	if !client.setPaused("Reader A", true, false) || !sub.Paused {
+		t.Fatal("expected subscription to be paused")
+	}
+	// A read that finishes while paused is dropped
+	if !client.syncPausedSubscription(sub, card, nil) || client.lastUIDs["Reader A"] != "04AABB" {
+		t.Errorf("expected paused result to be dropped, last UID %q", client.lastUIDs["Reader A"])
+	}
+
+	// Resume: the card now present is recorded without an event
+	client.setPaused("Reader A", false, false)
+	if sub.Paused || !client.syncPausedSubscription(sub, card, nil) {
+		t.Fatal("expected the first poll after resume to be absorbed")
+	}
+	if client.lastUIDs["Reader A"] != "04CCDD" {
+		t.Errorf("last UID = %q, want 04CCDD", client.lastUIDs["Reader A"])
+	}
+	if client.syncPausedSubscription(sub, card, nil) {
+		t.Error("expected later polls to be handled normally")
+	}
+	if sub.IntervalMs != 500 || !client.subscribed["Reader A"] {
+		t.Error("expected subscription settings to survive pause/resume")
+	}
+
+	// emitOnResume: the present card is reported as new
+	client, sub = newClient()
+	client.setPaused("Reader A", true, false)
+	client.setPaused("Reader A", false, true)
+	if client.syncPausedSubscription(sub, card, nil) || client.lastUIDs["Reader A"] != "" {
+		t.Errorf("expected the present card to be emitted, last UID %q", client.lastUIDs["Reader A"])
+	}
+}
+
+func TestWSClient_stopSubscriptionLocked_Cancels(t *testing.T) {
+	client := &WSClient{
+		subscribed:    make(map[string]bool),
+		pollTickers:   make(map[string]*time.Ticker),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.subscribed["Reader A"] = true
+	client.pollTickers["Reader A"] = time.NewTicker(time.Hour)
+	client.subscriptions["Reader A"] = &wsSubscription{ReaderName: "Reader A", cancel: cancel}
+
+	client.stopSubscriptionLocked("Reader A")
+
+	if ctx.Err() == nil {
+		t.Error("expected subscription context to be cancelled")
+	}
+}
+
+func TestWSClient_runCancellable(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	client.runCancellable("op-1", "Reader A", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		finished <- ctx.Err()
+	})
+	<-started
+
+	// A second operation with the same ID is rejected
+	client.runCancellable("op-1", "Reader A", func(ctx context.Context) {
+		t.Error("duplicate operation should not run")
+	})
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error for duplicate id, got '%s'", decoded.Type)
+	}
+
+	client.handleCancel("cancel-1", json.RawMessage(`{"id": "op-1"}`))
+	if decoded := readWSMessage(t, client); decoded.Type != "cancel_requested" || decoded.ID != "cancel-1" {
+		t.Errorf("expected cancel_requested for cancel-1, got %s/%s", decoded.Type, decoded.ID)
+	}
+
+	select {
+	case err := <-finished:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("operation was not canceled")
+	}
+
+	// The finished operation is unregistered
+	time.Sleep(10 * time.Millisecond)
+	client.mu.Lock()
+	remaining := len(client.operations)
+	client.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("expected no registered operations, got %d", remaining)
+	}
+}
+
+func TestWSClient_runCancellable_SerializesPerReader(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) {
+		mu.Lock()
+		order = append(order, s)
+		mu.Unlock()
+	}
+
+	release := make(chan struct{})
+	otherDone := make(chan struct{})
+	allDone := make(chan struct{}, 3)
+	client.runCancellable("op-1", "Reader A", func(ctx context.Context) {
+		record("op-1 start")
+		<-release
+		record("op-1 end")
+		allDone <- struct{}{}
+	})
+	client.runCancellable("op-2", "Reader A", func(ctx context.Context) {
+		record("op-2")
+		allDone <- struct{}{}
+	})
+	// Another reader doesn't wait for Reader A
+	client.runCancellable("op-3", "Reader B", func(ctx context.Context) {
+		close(otherDone)
+		allDone <- struct{}{}
+	})
+
+	select {
+	case <-otherDone:
+	case <-time.After(time.Second):
+		t.Fatal("operation on another reader waited for Reader A")
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-allDone:
+		case <-time.After(time.Second):
+			t.Fatal("operations did not finish")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[0] != "op-1 start" || order[1] != "op-1 end" || order[2] != "op-2" {
+		t.Errorf("operations on one reader overlapped or reordered: %v", order)
+	}
+}
+
+func TestWSClient_disconnect_StopsOperations(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256), closed: make(chan struct{})}
+
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	client.runCancellable("", "Reader A", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done() // Operations without an id are canceled too
+		client.sendResponse("", "canceled", nil)
+		close(finished)
+	})
+	queuedRan := make(chan struct{}, 1)
+	client.runCancellable("", "Reader A", func(ctx context.Context) {
+		queuedRan <- struct{}{}
+	})
+
+	<-started
+	client.disconnect()
+	close(client.send) // As the hub does on unregister
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("running operation was not canceled")
+	}
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-queuedRan:
+		t.Error("queued operation ran after the client disconnected")
+	default:
+	}
+
+	// Late replies are dropped instead of panicking on the closed channel
+	client.sendError("late", "too late")
+	client.sendCardError("late", core.ErrReaderBusy)
+}
+
+func TestWSClient_handleCancel_Invalid(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	for _, payload := range []string{`invalid`, `{}`, `{"id": "missing"}`} {
+		client.handleCancel("cancel-1", json.RawMessage(payload))
+		if decoded := readWSMessage(t, client); decoded.Type != "error" {
+			t.Errorf("payload %s: expected error, got '%s'", payload, decoded.Type)
+		}
+	}
+}
+
+func readWSMessage(t *testing.T, client *WSClient) WSMessage {
+	t.Helper()
+	select {
+	case msg := <-client.send:
+		var decoded WSMessage
+		json.Unmarshal(msg, &decoded)
+		return decoded
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for response")
+		return WSMessage{}
+	}
+}
+
+// fakeReaderSequence returns a read function that replays UIDs; "" means no card.
+func fakeReaderSequence(uids ...string) func(string) (*core.Card, error) {
+	var mu sync.Mutex
+	i := 0
+	return func(readerName string) (*core.Card, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		uid := ""
+		if i < len(uids) {
+			uid = uids[i]
+		}
+		i++
+		if uid == "" {
+			return nil, errors.New("no card present")
+		}
+		return &core.Card{UID: uid}, nil
+	}
+}
+
+func TestRunScanSession(t *testing.T) {
+	// Card A stays for two polls, is removed, re-presented (ignored), then B and C
+	read := fakeReaderSequence("a", "a", "", "a", "", "b", "", "c", "d")
+
+	var detected []string
+	uids, err := runScanSession(context.Background(), read, "Reader A", 3, time.Millisecond, func(card *core.Card, scanned int) {
+		detected = append(detected, card.UID)
+		if scanned != len(detected) {
+			t.Errorf("scanned = %d, want %d", scanned, len(detected))
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(uids, ",") != "a,b,c" || strings.Join(detected, ",") != "a,b,c" {
+		t.Errorf("uids = %v, detected = %v, want a,b,c", uids, detected)
+	}
+}
+
+func TestRunScanSession_ReportsDuplicates(t *testing.T) {
+	// A is re-presented twice after being counted, B once
+	read := fakeReaderSequence("a", "", "b", "", "a", "", "b", "", "a", "", "c")
+
+	start := time.Now()
+	var duplicates []string
+	uids, err := runScanSession(context.Background(), read, "Reader A", 3, time.Millisecond, func(*core.Card, int) {}, func(card *core.Card, previous time.Time, sightings int) {
+		duplicates = append(duplicates, fmt.Sprintf("%s:%d", card.UID, sightings))
+		if previous.Before(start) || previous.After(time.Now()) {
+			t.Errorf("previous sighting of %s at %v is outside the session", card.UID, previous)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(uids, ",") != "a,b,c" {
+		t.Errorf("uids = %v, want a,b,c", uids)
+	}
+	if got := strings.Join(duplicates, " "); got != "a:2 b:2 a:3" {
+		t.Errorf("duplicates = %s, want a:2 b:2 a:3", got)
+	}
+}
+
+func TestRunScanSession_RequiresRemoval(t *testing.T) {
+	// B replaces A without a gap, so it isn't counted until removed and re-presented
+	read := fakeReaderSequence("a", "b", "b", "", "b")
+
+	uids, err := runScanSession(context.Background(), read, "Reader A", 2, time.Millisecond, func(*core.Card, int) {}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(uids, ",") != "a,b" {
+		t.Errorf("uids = %v, want a,b", uids)
+	}
+}
+
+func TestRunScanSession_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	uids, err := runScanSession(ctx, fakeReaderSequence("a"), "Reader A", 2, time.Millisecond, func(*core.Card, int) {}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if strings.Join(uids, ",") != "a" {
+		t.Errorf("expected partial result [a], got %v", uids)
+	}
+}
+
+func TestRunBatchWriteSession(t *testing.T) {
+	// A is written, stays, is re-presented (skipped); B fails, is re-presented and written
+	read := fakeReaderSequence("a", "a", "", "a", "", "b", "", "b", "c")
+	failB := true
+	write := func(uid string) error {
+		if uid == "b" && failB {
+			failB = false
+			return errors.New("write failed")
+		}
+		return nil
+	}
+
+	var results []string
+	uids, err := runBatchWriteSession(context.Background(), read, write, "Reader A", 2, time.Millisecond, func(result batchWriteResult, written int) {
+		results = append(results, fmt.Sprintf("%s:%t:%d", result.UID, result.Success, written))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(uids, ",") != "a,b" {
+		t.Errorf("uids = %v, want a,b", uids)
+	}
+	if got := strings.Join(results, " "); got != "a:true:1 b:false:1 b:true:2" {
+		t.Errorf("results = %s", got)
+	}
+}
+
+func TestWSClient_handleBatchWriteSession_InvalidPayload(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.handleBatchWriteSession("test-id", json.RawMessage(`invalid`))
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error, got '%s'", decoded.Type)
+	}
+}
+
+func TestWSClient_handleEraseSession_InvalidPayload(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.handleEraseSession("test-id", json.RawMessage(`invalid`))
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error, got '%s'", decoded.Type)
+	}
+}
+
+func TestWSClient_handleScanSession_InvalidPayload(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.handleScanSession("test-id", json.RawMessage(`invalid`))
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error, got '%s'", decoded.Type)
+	}
+}
+
+func TestWSClient_claimedRead(t *testing.T) {
+	t.Cleanup(func() { readerClaims = newClaimRegistry() })
+	owner := &WSClient{send: make(chan []byte, 256)}
+	other := &WSClient{send: make(chan []byte, 256)}
+
+	if _, err := readerClaims.claim("Reader A", owner.claimOwner(), time.Minute); err != nil {
+		t.Fatalf("claim failed: %v", err)
+	}
+	if _, err := other.claimedRead("Reader A"); !errors.Is(err, errReaderClaimed) {
+		t.Errorf("expected errReaderClaimed, got %v", err)
+	}
+
+	other.sendCardError("test-id", readerClaims.check("Reader A", other.claimOwner()))
+	if decoded := readWSMessage(t, other); decoded.Code != "READER_CLAIMED" {
+		t.Errorf("expected code READER_CLAIMED, got '%s'", decoded.Code)
+	}
+}
+
+func TestWSClient_sendCardErrorPayload(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.sendCardErrorPayload("test-id", core.ErrWriteProtected, nil, map[string]interface{}{
+		"success":  false,
+		"page":     41,
+		"restored": true,
+	})
+	decoded := readWSMessage(t, client)
+	if decoded.Type != "error" || decoded.Code != "WRITE_PROTECTED" {
+		t.Fatalf("expected a WRITE_PROTECTED error, got %s/%s", decoded.Type, decoded.Code)
+	}
+	var payload struct {
+		Page     int  `json:"page"`
+		Restored bool `json:"restored"`
+	}
+	if err := json.Unmarshal(decoded.Payload, &payload); err != nil || payload.Page != 41 || !payload.Restored {
+		t.Errorf("expected the page and restore state in the payload, got %s (%v)", decoded.Payload, err)
+	}
+
+	client.sendCardError("test-id", core.ErrWriteProtected)
+	if decoded := readWSMessage(t, client); decoded.Payload != nil {
+		t.Errorf("expected no payload, got %s", decoded.Payload)
+	}
+}
+
+func TestWSClient_handleClaimReader_InvalidPayload(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+
+	client.handleClaimReader("test-id", json.RawMessage(`invalid`))
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error, got '%s'", decoded.Type)
+	}
+
+	client.handleReleaseReader("test-id", json.RawMessage(`invalid`))
+	if decoded := readWSMessage(t, client); decoded.Type != "error" {
+		t.Errorf("expected error, got '%s'", decoded.Type)
+	}
+}
+
+func TestSignalReaderFeedback(t *testing.T) {
+	orig := signalSuccess
+	t.Cleanup(func() { signalSuccess = orig })
+
+	var called []string
+	signalSuccess = func(readerName string) error {
+		called = append(called, readerName)
+		if readerName == "Unsupported Reader" {
+			return core.ErrFeedbackUnsupported
+		}
+		return nil
+	}
+
+	signalReaderFeedback("ACS ACR122U")
+	signalReaderFeedback("Unsupported Reader")
+
+	if len(called) != 2 || called[0] != "ACS ACR122U" {
+		t.Errorf("unexpected feedback calls: %v", called)
+	}
+}
+
+func TestWSClient_handleEcho(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 1)}
+
+	client.handleMessage(WSMessage{Type: "echo", ID: "ping-1", Payload: json.RawMessage(`{"seq":7,"sentAt":1712345678}`)})
+
+	select {
+	case msg := <-client.send:
+		var decoded WSMessage
+		json.Unmarshal(msg, &decoded)
+		if decoded.Type != "echo" || decoded.ID != "ping-1" {
+			t.Errorf("unexpected response %s", msg)
+		}
+		if string(decoded.Payload) != `{"seq":7,"sentAt":1712345678}` {
+			t.Errorf("payload changed: %s", decoded.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for response")
+	}
+}
+
+func TestWSClient_handleStats(t *testing.T) {
+	connectedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	client := &WSClient{
+		send:          make(chan []byte, 1),
+		subscriptions: make(map[string]*wsSubscription),
+		connectedAt:   connectedAt,
+	}
+	client.subscriptions["Reader A"] = &wsSubscription{ReaderIndex: 0, ReaderName: "Reader A", IntervalMs: 500}
+	client.messagesReceived.Add(3)
+	client.messagesSent.Add(5)
+
+	client.handleMessage(WSMessage{Type: "stats", ID: "stats-1"})
+
+	select {
+	case msg := <-client.send:
+		var decoded struct {
+			Type    string `json:"type"`
+			Payload struct {
+				ConnectedAt      time.Time        `json:"connectedAt"`
+				MessagesReceived int64            `json:"messagesReceived"`
+				MessagesSent     int64            `json:"messagesSent"`
+				Subscriptions    []wsSubscription `json:"subscriptions"`
+				ReadLimit        int              `json:"readLimit"`
+			} `json:"payload"`
+		}
+		json.Unmarshal(msg, &decoded)
+
+		if decoded.Type != "stats" {
+			t.Errorf("expected type 'stats', got '%s'", decoded.Type)
+		}
+		p := decoded.Payload
+		if !p.ConnectedAt.Equal(connectedAt) {
+			t.Errorf("connectedAt = %v, want %v", p.ConnectedAt, connectedAt)
+		}
+		if p.MessagesReceived != 3 || p.MessagesSent != 5 {
+			t.Errorf("expected 3 received and 5 sent, got %d and %d", p.MessagesReceived, p.MessagesSent)
+		}
+		if len(p.Subscriptions) != 1 || p.Subscriptions[0].ReaderName != "Reader A" {
+			t.Errorf("unexpected subscriptions: %+v", p.Subscriptions)
+		}
+		if p.ReadLimit != wsReadLimit {
+			t.Errorf("readLimit = %d, want %d", p.ReadLimit, wsReadLimit)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for response")
+	}
+}
+
 // Benchmarks
 func BenchmarkWSMessage_Marshal(b *testing.B) {
 	msg := WSMessage{
@@ -786,3 +1551,176 @@ func BenchmarkWSClient_sendResponse(b *testing.B) {
 		client.sendResponse("id", "type", payload)
 	}
 }
+
+func TestWSClient_runEventSubscription(t *testing.T) {
+	origWait := waitCardPresenceChange
+	defer func() { waitCardPresenceChange = origWait }()
+
+	changes := make(chan bool)
+	waitCardPresenceChange = func(ctx context.Context, readerName string, present bool) (bool, error) {
+		select {
+		case now := <-changes:
+			return now, nil
+		case <-ctx.Done():
+			return present, ctx.Err()
+		}
+	}
+
+	var mu sync.Mutex
+	reads := 0
+	read := fakeReaderSequence("", "04AABB", "")
+	poller := &cardPoller{read: func(readerName string) (*core.Card, error) {
+		mu.Lock()
+		reads++
+		mu.Unlock()
+		return read(readerName)
+	}}
+
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	sub := &wsSubscription{ReaderName: "Reader A", IntervalMs: 10, Mode: "event", wake: make(chan struct{}, 1)}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.runEventSubscription(ctx, sub, ticker, poller)
+		close(done)
+	}()
+
+	changes <- true
+	if msg := readWSMessage(t, client); msg.Type != "card_detected" {
+		t.Fatalf("expected card_detected, got %s", msg.Type)
+	}
+	changes <- false
+	if msg := readWSMessage(t, client); msg.Type != "card_removed" {
+		t.Fatalf("expected card_removed, got %s", msg.Type)
+	}
+
+	// No reads while waiting for the next change
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if reads != 3 {
+		t.Errorf("expected 3 reads (start, placed, removed), got %d", reads)
+	}
+	mu.Unlock()
+
+	cancel()
+	<-done
+}
+
+func TestWSClient_runEventSubscription_CardPresentAtStart(t *testing.T) {
+	origWait := waitCardPresenceChange
+	defer func() { waitCardPresenceChange = origWait }()
+	waiting := make(chan bool, 1)
+	waitCardPresenceChange = func(ctx context.Context, readerName string, present bool) (bool, error) {
+		waiting <- present
+		<-ctx.Done()
+		return present, ctx.Err()
+	}
+
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	poller := &cardPoller{read: fakeReaderSequence("04AABB")}
+	sub := &wsSubscription{ReaderName: "Reader A", IntervalMs: 10, Mode: "event", wake: make(chan struct{}, 1)}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.runEventSubscription(ctx, sub, ticker, poller)
+
+	if msg := readWSMessage(t, client); msg.Type != "card_detected" {
+		t.Fatalf("expected card_detected, got %s", msg.Type)
+	}
+	if present := <-waiting; !present {
+		t.Error("expected to wait for the card to be removed")
+	}
+}
+
+func TestWSClient_runEventSubscription_RetriesTimedOutRead(t *testing.T) {
+	origWait, origMin := waitCardPresenceChange, minPollReadTimeout
+	defer func() { waitCardPresenceChange, minPollReadTimeout = origWait, origMin }()
+	minPollReadTimeout = 0
+	waitCardPresenceChange = func(ctx context.Context, readerName string, present bool) (bool, error) {
+		<-ctx.Done() // The card never moves
+		return present, ctx.Err()
+	}
+
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	var mu sync.Mutex
+	reads := 0
+	poller := &cardPoller{read: func(readerName string) (*core.Card, error) {
+		mu.Lock()
+		reads++
+		first := reads == 1
+		mu.Unlock()
+		if first {
+			time.Sleep(pollReadTimeout(10*time.Millisecond) + 20*time.Millisecond) // Outlives the read timeout
+		}
+		return &core.Card{UID: "04AABB"}, nil
+	}}
+	sub := &wsSubscription{ReaderName: "Reader A", IntervalMs: 10, Mode: "event", wake: make(chan struct{}, 1)}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.runEventSubscription(ctx, sub, ticker, poller)
+
+	// The card is detected by a retry, not by a presence change
+	if msg := readWSMessage(t, client); msg.Type != "card_detected" {
+		t.Fatalf("expected card_detected, got %s", msg.Type)
+	}
+}
+
+func TestWSClient_runEventSubscription_FallsBackToPolling(t *testing.T) {
+	origWait := waitCardPresenceChange
+	defer func() { waitCardPresenceChange = origWait }()
+	waitCardPresenceChange = func(ctx context.Context, readerName string, present bool) (bool, error) {
+		return present, errors.New("status change unsupported")
+	}
+
+	client := &WSClient{
+		send:          make(chan []byte, 256),
+		lastUIDs:      make(map[string]string),
+		subscriptions: make(map[string]*wsSubscription),
+	}
+	poller := &cardPoller{read: fakeReaderSequence("", "", "04AABB")}
+	sub := &wsSubscription{ReaderName: "Reader A", IntervalMs: 10, Mode: "event", wake: make(chan struct{}, 1)}
+	client.subscriptions["Reader A"] = sub
+	ticker := time.NewTicker(time.Hour)
+	ticker.Stop()
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.runEventSubscription(ctx, sub, ticker, poller)
+
+	// The ticker is restarted, so the card placed later is still detected
+	if msg := readWSMessage(t, client); msg.Type != "card_detected" {
+		t.Fatalf("expected card_detected, got %s", msg.Type)
+	}
+	if got := client.activeSubscriptions(); len(got) != 1 || got[0].Mode != "poll" {
+		t.Errorf("expected the subscription to switch to poll mode, got %+v", got)
+	}
+}
+
+func TestWSClient_handleSubscribe_InvalidMode(t *testing.T) {
+	client := &WSClient{send: make(chan []byte, 256)}
+	client.handleSubscribe("sub-1", json.RawMessage(`{"readerIndex": 0, "mode": "push"}`))
+	if msg := readWSMessage(t, client); msg.Type != "error" || !strings.Contains(msg.Error, "mode") {
+		t.Errorf("expected a mode error, got %s: %s", msg.Type, msg.Error)
+	}
+}
@@ -3,24 +3,81 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	DefaultPort = 32145
 	DefaultHost = "127.0.0.1"
+
+	DefaultMQTTTopicPrefix = "nfc-agent"
+
+	DefaultCardSettleDelay = 150 * time.Millisecond
 )
 
 // Config holds the application configuration.
 type Config struct {
 	Host string
 	Port int
+
+	// Separate port for the status web UI (0 serves it on Port with the API)
+	UIHost string
+	UIPort int
+
+	// Limits for multi-record NDEF writes (0 keeps the core defaults)
+	MaxNDEFRecords      int
+	MaxNDEFPayloadBytes int
+
+	// Card operations slower than this log a warning (0 keeps the core default)
+	SlowOperationThreshold time.Duration
+
+	// Page/block budget for NDEF reads (0 reads up to the card type's capacity)
+	MaxReadPages int
+
+	// Delays for slow-writing tags (0 writes without waiting)
+	PageWriteDelay   time.Duration // Between consecutive page writes
+	WriteSettleDelay time.Duration // After a write, before reading it back to verify
+	CardSettleDelay  time.Duration // Before retrying a failed first read of a newly placed card (0 disables the retry)
+
+	// Concurrent card operation limits (0 keeps the core defaults)
+	MaxConcurrentOperations int // Card operations running at once
+	MaxQueuedOperations     int // Operations waiting for a slot before new ones are rejected
+
+	// MQTT publishing of card events (empty URL disables it)
+	MQTTURL         string // e.g. mqtt://homeassistant.local:1883
+	MQTTTopicPrefix string
+	MQTTUsername    string
+	MQTTPassword    string
+
+	// Directory served at / instead of the embedded web UI (empty keeps the embedded UI)
+	WebRootOverride string
+
+	// Exit after this long with no WebSocket clients, HTTP requests or card operations (0 disables)
+	IdleShutdown time.Duration
+
+	// Check PC/SC and readers at startup and periodically, warning when none work
+	SelfCheck bool
+
+	// Connect to cards exclusively so no other application can use the reader mid-operation
+	ExclusiveReader bool
+
+	// Allow POST /v1/readers/{n}/script to send raw APDUs to cards
+	AllowRawAPDU bool
+
+	// Browser access (CORS)
+	CORSMaxAge         time.Duration // How long browsers cache preflight responses (0 keeps the API default)
+	CORSAllowedOrigins []string      // Origins allowed to call the API (empty allows any origin)
 }
 
 // Load reads configuration from environment variables with sensible defaults.
 func Load() *Config {
 	cfg := &Config{
-		Host: DefaultHost,
-		Port: DefaultPort,
+		Host:            DefaultHost,
+		Port:            DefaultPort,
+		UIHost:          DefaultHost,
+		MQTTTopicPrefix: DefaultMQTTTopicPrefix,
+		CardSettleDelay: DefaultCardSettleDelay,
 	}
 
 	// NFC_AGENT_PORT - override the default port
@@ -35,6 +92,144 @@ func Load() *Config {
 		cfg.Host = host
 	}
 
+	// NFC_AGENT_UI_PORT - serve the web UI on this port instead of the API port
+	if portStr := os.Getenv("NFC_AGENT_UI_PORT"); portStr != "" {
+		if port, err := strconv.Atoi(portStr); err == nil && port > 0 && port < 65536 && port != cfg.Port {
+			cfg.UIPort = port
+		}
+	}
+
+	// NFC_AGENT_UI_HOST - host for the separate web UI port (default: loopback)
+	if host := os.Getenv("NFC_AGENT_UI_HOST"); host != "" {
+		cfg.UIHost = host
+	}
+
+	// NFC_AGENT_MAX_NDEF_RECORDS - maximum number of records per multi-record write
+	if v := os.Getenv("NFC_AGENT_MAX_NDEF_RECORDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxNDEFRecords = n
+		}
+	}
+
+	// NFC_AGENT_MAX_NDEF_PAYLOAD - maximum total payload bytes per multi-record write
+	if v := os.Getenv("NFC_AGENT_MAX_NDEF_PAYLOAD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxNDEFPayloadBytes = n
+		}
+	}
+
+	// NFC_AGENT_SLOW_OP_MS - warn when a card operation takes longer than this
+	if v := os.Getenv("NFC_AGENT_SLOW_OP_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			cfg.SlowOperationThreshold = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	// NFC_AGENT_MAX_READ_PAGES - cap on pages/blocks read when looking for NDEF data
+	if v := os.Getenv("NFC_AGENT_MAX_READ_PAGES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxReadPages = n
+		}
+	}
+
+	// NFC_AGENT_PAGE_WRITE_DELAY_MS - wait between page writes (for clone tags that NAK fast writes)
+	if v := os.Getenv("NFC_AGENT_PAGE_WRITE_DELAY_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			cfg.PageWriteDelay = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	// NFC_AGENT_WRITE_SETTLE_MS - wait after a write before reading it back to verify
+	if v := os.Getenv("NFC_AGENT_WRITE_SETTLE_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			cfg.WriteSettleDelay = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	// NFC_AGENT_CARD_SETTLE_MS - wait before retrying a failed first read of a newly placed card
+	if v := os.Getenv("NFC_AGENT_CARD_SETTLE_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms >= 0 {
+			cfg.CardSettleDelay = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	// NFC_AGENT_MAX_CONCURRENT_OPS - card operations (PC/SC contexts) running at once
+	if v := os.Getenv("NFC_AGENT_MAX_CONCURRENT_OPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxConcurrentOperations = n
+		}
+	}
+
+	// NFC_AGENT_MAX_QUEUED_OPS - operations waiting for a slot before new ones get 503
+	if v := os.Getenv("NFC_AGENT_MAX_QUEUED_OPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxQueuedOperations = n
+		}
+	}
+
+	// NFC_AGENT_MQTT_URL - publish card events to this MQTT broker
+	if v := os.Getenv("NFC_AGENT_MQTT_URL"); v != "" {
+		cfg.MQTTURL = v
+	}
+
+	// NFC_AGENT_MQTT_TOPIC_PREFIX - topic prefix for published card events
+	if v := os.Getenv("NFC_AGENT_MQTT_TOPIC_PREFIX"); v != "" {
+		cfg.MQTTTopicPrefix = v
+	}
+
+	// NFC_AGENT_MQTT_USERNAME / NFC_AGENT_MQTT_PASSWORD - broker credentials
+	cfg.MQTTUsername = os.Getenv("NFC_AGENT_MQTT_USERNAME")
+	cfg.MQTTPassword = os.Getenv("NFC_AGENT_MQTT_PASSWORD")
+
+	// NFC_AGENT_WEB_ROOT - serve the web UI from this directory, falling back to the embedded files
+	if dir := os.Getenv("NFC_AGENT_WEB_ROOT"); dir != "" {
+		cfg.WebRootOverride = dir
+	}
+
+	// NFC_AGENT_IDLE_SHUTDOWN_SEC - exit after this many seconds without clients or activity
+	if v := os.Getenv("NFC_AGENT_IDLE_SHUTDOWN_SEC"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			cfg.IdleShutdown = time.Duration(secs) * time.Second
+		}
+	}
+
+	// NFC_AGENT_SELF_CHECK - check for a working reader at startup and keep retrying
+	if v := os.Getenv("NFC_AGENT_SELF_CHECK"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.SelfCheck = enabled
+		}
+	}
+
+	// NFC_AGENT_EXCLUSIVE_READER - hold the reader exclusively during card operations
+	if v := os.Getenv("NFC_AGENT_EXCLUSIVE_READER"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.ExclusiveReader = enabled
+		}
+	}
+
+	// NFC_AGENT_ALLOW_RAW_APDU - enable the raw APDU script endpoint for reader/card prototyping
+	if v := os.Getenv("NFC_AGENT_ALLOW_RAW_APDU"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.AllowRawAPDU = enabled
+		}
+	}
+
+	// NFC_AGENT_CORS_MAX_AGE - seconds browsers may cache a CORS preflight response
+	if v := os.Getenv("NFC_AGENT_CORS_MAX_AGE"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			cfg.CORSMaxAge = time.Duration(secs) * time.Second
+		}
+	}
+
+	// NFC_AGENT_CORS_ORIGINS - comma-separated origins allowed to call the API from a browser
+	if v := os.Getenv("NFC_AGENT_CORS_ORIGINS"); v != "" {
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.CORSAllowedOrigins = append(cfg.CORSAllowedOrigins, origin)
+			}
+		}
+	}
+
 	return cfg
 }
 
@@ -42,3 +237,12 @@ func Load() *Config {
 func (c *Config) Address() string {
 	return c.Host + ":" + strconv.Itoa(c.Port)
 }
+
+// UIAddress returns the host:port of the separate web UI server, or "" if
+// the UI is served on the API port.
+func (c *Config) UIAddress() string {
+	if c.UIPort == 0 {
+		return ""
+	}
+	return c.UIHost + ":" + strconv.Itoa(c.UIPort)
+}
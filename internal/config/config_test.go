@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -248,3 +249,221 @@ func BenchmarkAddress(b *testing.B) {
 		cfg.Address()
 	}
 }
+
+func TestLoad_OperationLimits(t *testing.T) {
+	os.Setenv("NFC_AGENT_MAX_NDEF_RECORDS", "4")
+	os.Setenv("NFC_AGENT_MAX_NDEF_PAYLOAD", "1024")
+	os.Setenv("NFC_AGENT_SLOW_OP_MS", "750")
+	os.Setenv("NFC_AGENT_MAX_READ_PAGES", "12")
+	defer os.Unsetenv("NFC_AGENT_MAX_READ_PAGES")
+	defer os.Unsetenv("NFC_AGENT_MAX_NDEF_RECORDS")
+	defer os.Unsetenv("NFC_AGENT_MAX_NDEF_PAYLOAD")
+	defer os.Unsetenv("NFC_AGENT_SLOW_OP_MS")
+
+	cfg := Load()
+
+	if cfg.MaxNDEFRecords != 4 {
+		t.Errorf("expected MaxNDEFRecords 4, got %d", cfg.MaxNDEFRecords)
+	}
+	if cfg.MaxNDEFPayloadBytes != 1024 {
+		t.Errorf("expected MaxNDEFPayloadBytes 1024, got %d", cfg.MaxNDEFPayloadBytes)
+	}
+	if cfg.SlowOperationThreshold != 750*time.Millisecond {
+		t.Errorf("expected SlowOperationThreshold 750ms, got %v", cfg.SlowOperationThreshold)
+	}
+	if cfg.MaxReadPages != 12 {
+		t.Errorf("expected MaxReadPages 12, got %d", cfg.MaxReadPages)
+	}
+}
+
+func TestLoad_WriteDelays(t *testing.T) {
+	t.Setenv("NFC_AGENT_PAGE_WRITE_DELAY_MS", "20")
+	t.Setenv("NFC_AGENT_WRITE_SETTLE_MS", "50")
+
+	cfg := Load()
+
+	if cfg.PageWriteDelay != 20*time.Millisecond {
+		t.Errorf("expected PageWriteDelay 20ms, got %v", cfg.PageWriteDelay)
+	}
+	if cfg.WriteSettleDelay != 50*time.Millisecond {
+		t.Errorf("expected WriteSettleDelay 50ms, got %v", cfg.WriteSettleDelay)
+	}
+
+	t.Setenv("NFC_AGENT_PAGE_WRITE_DELAY_MS", "-5")
+	t.Setenv("NFC_AGENT_WRITE_SETTLE_MS", "abc")
+
+	cfg = Load()
+
+	if cfg.PageWriteDelay != 0 || cfg.WriteSettleDelay != 0 {
+		t.Errorf("expected invalid values to keep zero delays, got %v and %v", cfg.PageWriteDelay, cfg.WriteSettleDelay)
+	}
+}
+
+func TestLoad_ConcurrencyLimits(t *testing.T) {
+	t.Setenv("NFC_AGENT_MAX_CONCURRENT_OPS", "2")
+	t.Setenv("NFC_AGENT_MAX_QUEUED_OPS", "8")
+
+	cfg := Load()
+
+	if cfg.MaxConcurrentOperations != 2 {
+		t.Errorf("expected MaxConcurrentOperations 2, got %d", cfg.MaxConcurrentOperations)
+	}
+	if cfg.MaxQueuedOperations != 8 {
+		t.Errorf("expected MaxQueuedOperations 8, got %d", cfg.MaxQueuedOperations)
+	}
+
+	t.Setenv("NFC_AGENT_MAX_CONCURRENT_OPS", "0")
+	t.Setenv("NFC_AGENT_MAX_QUEUED_OPS", "x")
+
+	cfg = Load()
+
+	if cfg.MaxConcurrentOperations != 0 || cfg.MaxQueuedOperations != 0 {
+		t.Errorf("expected invalid values to keep the core defaults, got %d and %d", cfg.MaxConcurrentOperations, cfg.MaxQueuedOperations)
+	}
+}
+
+func TestLoad_MQTT(t *testing.T) {
+	cfg := Load()
+	if cfg.MQTTURL != "" || cfg.MQTTTopicPrefix != DefaultMQTTTopicPrefix {
+		t.Errorf("expected MQTT disabled with the default prefix, got %q and %q", cfg.MQTTURL, cfg.MQTTTopicPrefix)
+	}
+
+	t.Setenv("NFC_AGENT_MQTT_URL", "mqtt://homeassistant.local")
+	t.Setenv("NFC_AGENT_MQTT_TOPIC_PREFIX", "home/nfc")
+	t.Setenv("NFC_AGENT_MQTT_USERNAME", "ha")
+	t.Setenv("NFC_AGENT_MQTT_PASSWORD", "secret")
+
+	cfg = Load()
+
+	if cfg.MQTTURL != "mqtt://homeassistant.local" || cfg.MQTTTopicPrefix != "home/nfc" {
+		t.Errorf("unexpected MQTT URL %q or prefix %q", cfg.MQTTURL, cfg.MQTTTopicPrefix)
+	}
+	if cfg.MQTTUsername != "ha" || cfg.MQTTPassword != "secret" {
+		t.Errorf("unexpected MQTT credentials %q/%q", cfg.MQTTUsername, cfg.MQTTPassword)
+	}
+}
+
+func TestLoad_WebRootOverride(t *testing.T) {
+	if cfg := Load(); cfg.WebRootOverride != "" {
+		t.Errorf("expected no web root override by default, got %q", cfg.WebRootOverride)
+	}
+
+	t.Setenv("NFC_AGENT_WEB_ROOT", "/srv/kiosk")
+	if cfg := Load(); cfg.WebRootOverride != "/srv/kiosk" {
+		t.Errorf("expected WebRootOverride /srv/kiosk, got %q", cfg.WebRootOverride)
+	}
+}
+
+func TestLoad_UIPort(t *testing.T) {
+	if cfg := Load(); cfg.UIPort != 0 || cfg.UIAddress() != "" {
+		t.Errorf("expected the UI on the API port by default, got UIPort %d, UIAddress %q", cfg.UIPort, cfg.UIAddress())
+	}
+
+	t.Setenv("NFC_AGENT_HOST", "0.0.0.0")
+	t.Setenv("NFC_AGENT_UI_PORT", "32146")
+	cfg := Load()
+	if got, want := cfg.UIAddress(), DefaultHost+":32146"; got != want {
+		t.Errorf("UIAddress() = %q, want %q", got, want)
+	}
+
+	t.Setenv("NFC_AGENT_UI_HOST", "192.168.1.10")
+	if got, want := Load().UIAddress(), "192.168.1.10:32146"; got != want {
+		t.Errorf("UIAddress() = %q, want %q", got, want)
+	}
+
+	// The API port, or an invalid one, keeps everything on one port
+	for _, v := range []string{"32145", "abc", "70000"} {
+		t.Setenv("NFC_AGENT_UI_PORT", v)
+		if cfg := Load(); cfg.UIPort != 0 {
+			t.Errorf("NFC_AGENT_UI_PORT=%s: expected UIPort 0, got %d", v, cfg.UIPort)
+		}
+	}
+}
+
+func TestLoad_IdleShutdown(t *testing.T) {
+	if cfg := Load(); cfg.IdleShutdown != 0 {
+		t.Errorf("expected idle shutdown disabled by default, got %v", cfg.IdleShutdown)
+	}
+
+	t.Setenv("NFC_AGENT_IDLE_SHUTDOWN_SEC", "900")
+	if cfg := Load(); cfg.IdleShutdown != 15*time.Minute {
+		t.Errorf("expected IdleShutdown 15m, got %v", cfg.IdleShutdown)
+	}
+
+	t.Setenv("NFC_AGENT_IDLE_SHUTDOWN_SEC", "-5")
+	if cfg := Load(); cfg.IdleShutdown != 0 {
+		t.Errorf("expected a negative value to be ignored, got %v", cfg.IdleShutdown)
+	}
+}
+
+func TestLoad_SelfCheck(t *testing.T) {
+	if Load().SelfCheck {
+		t.Error("expected the self-check to be disabled by default")
+	}
+
+	t.Setenv("NFC_AGENT_SELF_CHECK", "true")
+	if !Load().SelfCheck {
+		t.Error("expected NFC_AGENT_SELF_CHECK=true to enable the self-check")
+	}
+
+	t.Setenv("NFC_AGENT_SELF_CHECK", "maybe")
+	if Load().SelfCheck {
+		t.Error("expected an invalid value to keep the self-check disabled")
+	}
+}
+
+func TestLoad_ExclusiveReader(t *testing.T) {
+	if Load().ExclusiveReader {
+		t.Error("expected shared reader access by default")
+	}
+
+	t.Setenv("NFC_AGENT_EXCLUSIVE_READER", "1")
+	if !Load().ExclusiveReader {
+		t.Error("expected NFC_AGENT_EXCLUSIVE_READER=1 to enable exclusive access")
+	}
+}
+
+func TestLoad_AllowRawAPDU(t *testing.T) {
+	if Load().AllowRawAPDU {
+		t.Error("expected raw APDU scripts to be disabled by default")
+	}
+
+	t.Setenv("NFC_AGENT_ALLOW_RAW_APDU", "true")
+	if !Load().AllowRawAPDU {
+		t.Error("expected NFC_AGENT_ALLOW_RAW_APDU=true to enable raw APDU scripts")
+	}
+}
+
+func TestLoad_CORS(t *testing.T) {
+	cfg := Load()
+	if cfg.CORSMaxAge != 0 || len(cfg.CORSAllowedOrigins) != 0 {
+		t.Errorf("expected no CORS overrides by default, got max age %v and origins %v", cfg.CORSMaxAge, cfg.CORSAllowedOrigins)
+	}
+
+	t.Setenv("NFC_AGENT_CORS_MAX_AGE", "600")
+	t.Setenv("NFC_AGENT_CORS_ORIGINS", "https://simplyprint.io, http://localhost:3000,")
+	cfg = Load()
+	if cfg.CORSMaxAge != 10*time.Minute {
+		t.Errorf("CORSMaxAge = %v, want 10m", cfg.CORSMaxAge)
+	}
+	want := []string{"https://simplyprint.io", "http://localhost:3000"}
+	if len(cfg.CORSAllowedOrigins) != len(want) || cfg.CORSAllowedOrigins[0] != want[0] || cfg.CORSAllowedOrigins[1] != want[1] {
+		t.Errorf("CORSAllowedOrigins = %v, want %v", cfg.CORSAllowedOrigins, want)
+	}
+}
+
+func TestLoad_CardSettleDelay(t *testing.T) {
+	if got := Load().CardSettleDelay; got != DefaultCardSettleDelay {
+		t.Errorf("expected default CardSettleDelay %v, got %v", DefaultCardSettleDelay, got)
+	}
+
+	t.Setenv("NFC_AGENT_CARD_SETTLE_MS", "0")
+	if got := Load().CardSettleDelay; got != 0 {
+		t.Errorf("expected NFC_AGENT_CARD_SETTLE_MS=0 to disable the retry, got %v", got)
+	}
+
+	t.Setenv("NFC_AGENT_CARD_SETTLE_MS", "-5")
+	if got := Load().CardSettleDelay; got != DefaultCardSettleDelay {
+		t.Errorf("expected invalid value to keep the default, got %v", got)
+	}
+}
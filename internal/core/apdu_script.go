@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// MaxAPDUScriptSteps caps the number of steps in one APDU script.
+const MaxAPDUScriptSteps = 256
+
+// APDUStep is one command of an APDU script. APDU is hex (spaces allowed);
+// ExpectStatus, if set, is the status word the card must answer with, e.g.
+// "9000".
+type APDUStep struct {
+	APDU         string `json:"apdu"`
+	ExpectStatus string `json:"expectStatus,omitempty"`
+}
+
+// APDUResult is the outcome of one executed step. Response holds the data
+// before the status word.
+type APDUResult struct {
+	Step     int    `json:"step"`
+	APDU     string `json:"apdu"`
+	Response string `json:"response"`
+	Status   string `json:"status"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ValidateAPDUScript checks that every step holds a well-formed command APDU
+// and expected status, without needing a card.
+func ValidateAPDUScript(script []APDUStep) error {
+	_, err := parseAPDUScript(script)
+	return err
+}
+
+// RunAPDUScript sends the script's APDUs to the card on readerName in one
+// session, for prototyping support for new readers and cards. Execution stops
+// at the first step that fails or answers with an unexpected status; the
+// results cover the steps that ran.
+func RunAPDUScript(readerName string, script []APDUStep) (_ []APDUResult, err error) {
+	defer trackOperation("apdu_script", readerName, &err)()
+
+	cmds, err := parseAPDUScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	results := runAPDUScript(card, cmds, script)
+	logging.Info(logging.CatCard, "APDU script executed", map[string]any{
+		"reader": readerName,
+		"steps":  len(script),
+		"ran":    len(results),
+	})
+	return results, nil
+}
+
+// runAPDUScript transmits the parsed commands in order.
+func runAPDUScript(card cardTransmitter, cmds [][]byte, script []APDUStep) []APDUResult {
+	results := make([]APDUResult, 0, len(cmds))
+	for i, cmd := range cmds {
+		result := APDUResult{Step: i, APDU: hex.EncodeToString(cmd)}
+		rsp, err := transmit(card, cmd)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case len(rsp) < 2:
+			result.Response = hex.EncodeToString(rsp)
+			result.Error = "response has no status word"
+		default:
+			result.Response = hex.EncodeToString(rsp[:len(rsp)-2])
+			result.Status = hex.EncodeToString(rsp[len(rsp)-2:])
+			want := normalizeHex(script[i].ExpectStatus)
+			if want != "" && want != result.Status {
+				result.Error = fmt.Sprintf("expected status %s, got %s", want, result.Status)
+			}
+		}
+		result.OK = result.Error == ""
+		results = append(results, result)
+		if !result.OK {
+			break
+		}
+	}
+	return results
+}
+
+// parseAPDUScript decodes the script's APDUs.
+func parseAPDUScript(script []APDUStep) ([][]byte, error) {
+	if len(script) == 0 {
+		return nil, fmt.Errorf("script has no steps")
+	}
+	if len(script) > MaxAPDUScriptSteps {
+		return nil, fmt.Errorf("script has %d steps, maximum is %d", len(script), MaxAPDUScriptSteps)
+	}
+
+	cmds := make([][]byte, len(script))
+	for i, step := range script {
+		cmd, err := hex.DecodeString(normalizeHex(step.APDU))
+		if err != nil {
+			return nil, fmt.Errorf("step %d: invalid APDU hex: %w", i, err)
+		}
+		if len(cmd) < 4 {
+			return nil, fmt.Errorf("step %d: APDU must have at least CLA, INS, P1 and P2", i)
+		}
+		if want := normalizeHex(step.ExpectStatus); want != "" {
+			if sw, err := hex.DecodeString(want); err != nil || len(sw) != 2 {
+				return nil, fmt.Errorf("step %d: expectStatus must be 2 hex bytes, e.g. 9000", i)
+			}
+		}
+		cmds[i] = cmd
+	}
+	return cmds, nil
+}
+
+// normalizeHex lowercases s and drops spaces, so "90 00" matches "9000".
+func normalizeHex(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, " ", ""))
+}
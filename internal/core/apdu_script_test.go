@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunAPDUScript(t *testing.T) {
+	card := apduResponder(func(cmd []byte) []byte {
+		switch cmd[1] {
+		case 0xCA:
+			return []byte{0x04, 0xA1, 0xB2, 0x90, 0x00}
+		case 0xB0:
+			return []byte{0x6A, 0x82}
+		}
+		return []byte{0x90, 0x00}
+	})
+	script := []APDUStep{
+		{APDU: "FF CA 00 00 00", ExpectStatus: "90 00"},
+		{APDU: "ff82000006ffffffffffff"},
+		{APDU: "FFB0000410", ExpectStatus: "9000"},
+		{APDU: "FFCA000000"},
+	}
+
+	cmds, err := parseAPDUScript(script)
+	if err != nil {
+		t.Fatalf("parseAPDUScript failed: %v", err)
+	}
+	results := runAPDUScript(card, cmds, script)
+
+	if len(results) != 3 {
+		t.Fatalf("expected the script to stop after step 2, ran %d steps", len(results))
+	}
+	if r := results[0]; !r.OK || r.APDU != "ffca000000" || r.Response != "04a1b2" || r.Status != "9000" {
+		t.Errorf("step 0 = %+v", r)
+	}
+	if r := results[2]; r.OK || r.Status != "6a82" || r.Error == "" {
+		t.Errorf("step 2 should fail on the unexpected status, got %+v", r)
+	}
+}
+
+// failingTransmitter fails every APDU with err.
+type failingTransmitter struct{ err error }
+
+func (f failingTransmitter) Transmit([]byte) ([]byte, error) { return nil, f.err }
+
+func TestRunAPDUScript_TransmitError(t *testing.T) {
+	card := failingTransmitter{errors.New("card removed")}
+	script := []APDUStep{{APDU: "FFCA000000"}, {APDU: "FFCA000000"}}
+
+	cmds, _ := parseAPDUScript(script)
+	results := runAPDUScript(card, cmds, script)
+	if len(results) != 1 || results[0].OK || results[0].Error != "card removed" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestParseAPDUScript_Invalid(t *testing.T) {
+	tests := map[string][]APDUStep{
+		"empty":       nil,
+		"not hex":     {{APDU: "FFCAZZ00"}},
+		"too short":   {{APDU: "FFCA00"}},
+		"long status": {{APDU: "FFCA000000", ExpectStatus: "900000"}},
+		"too many":    make([]APDUStep, MaxAPDUScriptSteps+1),
+	}
+	for name, script := range tests {
+		if _, err := parseAPDUScript(script); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// maxAPDUTraceLines caps a trace so a long operation can't grow it unbounded.
+const maxAPDUTraceLines = 2000
+
+// APDUTrace records the APDU exchanges and debug log of a single card
+// operation, for debugging one request without raising the global log level.
+// Pass it in ReadOptions.Trace or WriteOptions.Trace: only the card
+// connection that operation opens is recorded, along with the entries logged
+// about that connection, so operations of other requests on the same reader
+// don't show up. The methods are no-ops on a nil trace.
+type APDUTrace struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// apduTraces maps traced card connections to their trace. active skips the
+// lookup when no connection is traced.
+var apduTraces = struct {
+	mu     sync.Mutex
+	byCard map[*scard.Card]*APDUTrace
+	active atomic.Int32
+}{
+	byCard: make(map[*scard.Card]*APDUTrace),
+}
+
+// NewAPDUTrace returns an empty trace to pass to a card operation.
+func NewAPDUTrace() *APDUTrace {
+	return &APDUTrace{}
+}
+
+// Lines returns what the trace recorded, in order: "> " and a command, then
+// "< " and the response or "! " and the error, each with the exchange's
+// duration, interleaved with the entries logged about the connection at every
+// level. Key and password bytes are always masked; all hex is redacted when
+// sensitive log scrubbing is enabled.
+func (t *APDUTrace) Lines() []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string{}, t.lines...)
+}
+
+// attach records the exchanges on card, a connection opened by the traced
+// operation, and the entries logged about it. The returned function stops
+// recording the connection.
+func (t *APDUTrace) attach(card *scard.Card) (detach func()) {
+	if t == nil {
+		return func() {}
+	}
+	apduTraces.mu.Lock()
+	defer apduTraces.mu.Unlock()
+	apduTraces.byCard[card] = t
+	apduTraces.active.Add(1)
+
+	return func() {
+		apduTraces.mu.Lock()
+		defer apduTraces.mu.Unlock()
+		if apduTraces.byCard[card] == t {
+			delete(apduTraces.byCard, card)
+			apduTraces.active.Add(-1)
+		}
+	}
+}
+
+// traceOf returns the trace recording card's connection, or nil.
+func traceOf(card cardTransmitter) *APDUTrace {
+	if apduTraces.active.Load() == 0 {
+		return nil
+	}
+	c, ok := card.(*scard.Card)
+	if !ok {
+		return nil
+	}
+	apduTraces.mu.Lock()
+	defer apduTraces.mu.Unlock()
+	return apduTraces.byCard[c]
+}
+
+// logCard logs an entry about card's connection, adding it to the
+// connection's trace if it is being traced.
+func logCard(card cardTransmitter, level logging.Level, category logging.Category, message string, data map[string]any) {
+	logging.Get().Log(level, category, message, data)
+	if t := traceOf(card); t != nil {
+		t.logEntry(logging.TraceEntry(level, category, message, data))
+	}
+}
+
+// transmit sends cmd to the card, recording the exchange if the connection
+// is being traced.
+func transmit(card cardTransmitter, cmd []byte) ([]byte, error) {
+	return transmitTraced(card, cmd, false)
+}
+
+// transmitSecret is transmit for a command whose data bytes are a password
+// or key, which a trace masks.
+func transmitSecret(card cardTransmitter, cmd []byte) ([]byte, error) {
+	return transmitTraced(card, cmd, true)
+}
+
+func transmitTraced(card cardTransmitter, cmd []byte, secret bool) ([]byte, error) {
+	if apduTraces.active.Load() == 0 {
+		return card.Transmit(cmd)
+	}
+	start := time.Now()
+	rsp, err := card.Transmit(cmd)
+	if t := traceOf(card); t != nil {
+		t.record(cmd, rsp, err, secret, time.Since(start))
+	}
+	return rsp, err
+}
+
+func (t *APDUTrace) record(cmd, rsp []byte, err error, secret bool, elapsed time.Duration) {
+	cmdHex, rspHex := maskAPDUSecrets(cmd, rsp, secret)
+	result := fmt.Sprintf("< %s (%dms)", rspHex, elapsed.Milliseconds())
+	if err != nil {
+		result = fmt.Sprintf("! %v (%dms)", err, elapsed.Milliseconds())
+	}
+	t.add("> "+cmdHex, result)
+}
+
+// logEntry adds a captured log entry as "LEVEL category: message key=value ...".
+func (t *APDUTrace) logEntry(e logging.Entry) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %s", e.Level, e.Category, e.Message)
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, e.Data[k])
+	}
+	t.add(b.String())
+}
+
+func (t *APDUTrace) add(lines ...string) {
+	if logging.ScrubSensitiveEnabled() {
+		for i := range lines {
+			lines[i] = logging.ScrubString(lines[i])
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.lines) < maxAPDUTraceLines {
+		t.lines = append(t.lines, lines...)
+	}
+}
+
+// maskedSecret replaces key and password bytes in traced APDUs.
+const maskedSecret = "**"
+
+// maskAPDUSecrets returns cmd and rsp as hex with the bytes of MIFARE keys
+// (LOAD KEYS, sector trailer writes) and NTAG/Ultralight passwords (PWD_AUTH
+// and its PACK reply) masked, along with the data of a secret command.
+func maskAPDUSecrets(cmd, rsp []byte, secret bool) (string, string) {
+	secretFrom, maskResponse := len(cmd), false
+	switch {
+	case secret && len(cmd) > 5:
+		secretFrom = 5
+	case len(cmd) > 5 && cmd[0] == 0xFF && cmd[1] == 0x82: // LOAD KEYS
+		secretFrom = 5
+	case len(cmd) == 21 && cmd[0] == 0xFF && cmd[1] == 0xD6 && cmd[4] == 16 && isSectorTrailer(int(cmd[2])<<8|int(cmd[3])):
+		secretFrom = 5 // Sector trailer: keys and access bits
+	case len(cmd) >= 12 && cmd[0] == 0xFF && cmd[5] == 0xD4 && cmd[6] == 0x42 && cmd[7] == 0x1B: // PWD_AUTH via InCommunicateThru
+		secretFrom, maskResponse = 8, true
+	case len(cmd) == 5 && cmd[0] == 0x1B: // Raw PWD_AUTH
+		secretFrom, maskResponse = 1, true
+	}
+
+	cmdHex := fmt.Sprintf("%X", cmd[:secretFrom]) + strings.Repeat(maskedSecret, len(cmd)-secretFrom)
+	rspHex := fmt.Sprintf("%X", rsp)
+	if maskResponse && len(rsp) > 2 {
+		rspHex = strings.Repeat(maskedSecret, len(rsp)-2) + fmt.Sprintf("%X", rsp[len(rsp)-2:])
+	}
+	return cmdHex, rspHex
+}
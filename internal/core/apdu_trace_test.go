@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+func TestAPDUTrace(t *testing.T) {
+	trace := NewAPDUTrace()
+	card := &scard.Card{}
+	detach := trace.attach(card)
+
+	if traceOf(card) != trace {
+		t.Fatal("expected the attached connection to be traced")
+	}
+
+	trace.record([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, []byte{0x04, 0xA1, 0x90, 0x00}, nil, false, 3*time.Millisecond)
+	logCard(card, logging.LevelDebug, logging.CatCard, "NDEF page written", map[string]any{"page": 4, "method": 0})
+	logging.Debug(logging.CatCard, "Another connection", nil)
+	logCard(&scard.Card{}, logging.LevelDebug, logging.CatCard, "Untraced connection", nil)
+
+	// Entries about the connection are recorded from any goroutine
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logCard(card, logging.LevelWarn, logging.CatCard, "Poll read", map[string]any{"password": "12345678"})
+	}()
+	<-done
+
+	trace.record([]byte{0xFF, 0xB0, 0x00, 0x04, 0x04}, nil, errors.New("card removed"), false, 0)
+	detach()
+	logCard(card, logging.LevelDebug, logging.CatCard, "After the operation", nil)
+
+	want := []string{
+		"> FFCA000000", "< 04A19000 (3ms)",
+		"DEBUG card: NDEF page written method=0 page=4",
+		"WARN card: Poll read password=" + logging.Redacted,
+		"> FFB0000404", "! card removed (0ms)",
+	}
+	if got := trace.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+	if apduTraces.active.Load() != 0 || len(apduTraces.byCard) != 0 {
+		t.Error("detaching should stop tracing the connection")
+	}
+}
+
+func TestAPDUTrace_Nil(t *testing.T) {
+	var trace *APDUTrace
+	defer trace.attach(&scard.Card{})()
+	if trace.Lines() != nil || apduTraces.active.Load() != 0 {
+		t.Error("a nil trace should record nothing")
+	}
+	if got := NewAPDUTrace().Lines(); got == nil || len(got) != 0 {
+		t.Errorf("Lines() = %#v, want an empty, non-nil log", got)
+	}
+}
+
+func TestAPDUTrace_Scrubbed(t *testing.T) {
+	logging.SetScrubSensitive(true)
+	defer logging.SetScrubSensitive(false)
+
+	trace := NewAPDUTrace()
+	trace.record([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00}, []byte{0x04, 0xA1, 0xB2, 0xC3, 0x90, 0x00}, nil, false, 0)
+	for _, line := range trace.Lines() {
+		if line != "> "+logging.Redacted && line != "< "+logging.Redacted+" (0ms)" {
+			t.Errorf("expected APDU hex to be redacted, got %q", line)
+		}
+	}
+}
+
+func TestMaskAPDUSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     []byte
+		rsp     []byte
+		secret  bool
+		wantCmd string
+		wantRsp string
+	}{
+		{"load keys", []byte{0xFF, 0x82, 0x00, 0x00, 0x06, 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}, []byte{0x90, 0x00},
+			false, "FF82000006" + strings.Repeat(maskedSecret, 6), "9000"},
+		{"sector trailer", append([]byte{0xFF, 0xD6, 0x00, 0x07, 0x10}, make([]byte, 16)...), []byte{0x90, 0x00},
+			false, "FFD6000710" + strings.Repeat(maskedSecret, 16), "9000"},
+		{"data block", append([]byte{0xFF, 0xD6, 0x00, 0x04, 0x10}, make([]byte, 16)...), []byte{0x90, 0x00},
+			false, "FFD6000410" + strings.Repeat("00", 16), "9000"},
+		{"pwd_auth", []byte{0xFF, 0x00, 0x00, 0x00, 0x07, 0xD4, 0x42, 0x1B, 0x12, 0x34, 0x56, 0x78}, []byte{0xD5, 0x43, 0x00, 0xAB, 0xCD, 0x90, 0x00},
+			false, "FF00000007D4421B" + strings.Repeat(maskedSecret, 4), strings.Repeat(maskedSecret, 5) + "9000"},
+		{"raw pwd_auth", []byte{0x1B, 0x12, 0x34, 0x56, 0x78}, []byte{0xAB, 0xCD, 0x90, 0x00},
+			false, "1B" + strings.Repeat(maskedSecret, 4), strings.Repeat(maskedSecret, 2) + "9000"},
+		{"secret write", []byte{0xFF, 0xD6, 0x00, 0x2B, 0x04, 0x12, 0x34, 0x56, 0x78}, []byte{0x90, 0x00},
+			true, "FFD6002B04" + strings.Repeat(maskedSecret, 4), "9000"},
+		{"read", []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}, []byte{0x03, 0x0A, 0x90, 0x00},
+			false, "FFB0000404", "030A9000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, rsp := maskAPDUSecrets(tt.cmd, tt.rsp, tt.secret)
+			if cmd != tt.wantCmd || rsp != tt.wantRsp {
+				t.Errorf("maskAPDUSecrets = %q, %q; want %q, %q", cmd, rsp, tt.wantCmd, tt.wantRsp)
+			}
+		})
+	}
+}
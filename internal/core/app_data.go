@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AppDataMIMEType is the MIME type of the record written by WriteAppData.
+// The payload is a JSON object {"version": N, "data": ...}, where data is the
+// application's own JSON.
+const AppDataMIMEType = "application/vnd.simplyprint.tag+json"
+
+// AppDataVersion is the app data container version written by WriteAppData
+// and the newest one ReadAppData understands.
+const AppDataVersion = 1
+
+// ErrNoAppData is returned by ReadAppData when the card holds no app data record.
+var ErrNoAppData = errors.New("no app data record found on card")
+
+// ErrUnsupportedAppDataVersion is returned by ReadAppData for a record written
+// with a newer container version than this agent understands.
+var ErrUnsupportedAppDataVersion = errors.New("unsupported app data version")
+
+// AppData is a decoded app data record. Other fields next to version and
+// data are ignored, so they can be added without a version bump. Expired is
+// computed on read from ExpiresAt and is never stored.
+type AppData struct {
+	Version   int             `json:"version"`
+	ExpiresAt int64           `json:"expiresAt,omitempty"` // Unix time in seconds, 0 if the record doesn't expire
+	Expired   bool            `json:"expired,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WriteAppData stores data on the card as a single app data MIME record,
+// replacing any existing NDEF data. A non-zero expiresAt (unix seconds) is
+// stored in the container.
+func WriteAppData(readerName string, data json.RawMessage, expiresAt int64) error {
+	return WriteAppDataWithOptions(readerName, data, expiresAt, WriteOptions{})
+}
+
+// WriteAppDataWithOptions is like WriteAppData but applies write options (Force and
+// ExpectUID are used).
+func WriteAppDataWithOptions(readerName string, data json.RawMessage, expiresAt int64, opts WriteOptions) error {
+	payload, err := encodeAppData(data, expiresAt)
+	if err != nil {
+		return err
+	}
+	return WriteRawNDEFWithOptions(readerName, createNDEFRecordRaw(0x02, []byte(AppDataMIMEType), payload, true, true), opts)
+}
+
+// ReadAppData reads the app data record written by WriteAppData.
+func ReadAppData(readerName string) (*AppData, error) {
+	card, err := GetCardUID(readerName)
+	if err != nil {
+		return nil, err
+	}
+	return appDataFromCard(card)
+}
+
+// appDataFromCard decodes the first app data record on a card.
+func appDataFromCard(card *Card) (*AppData, error) {
+	for _, rec := range card.Records {
+		if rec.TNF == 0x02 && MediaType(rec.Type) == AppDataMIMEType {
+			return decodeAppData(rec.Payload)
+		}
+	}
+	return nil, ErrNoAppData
+}
+
+func encodeAppData(data json.RawMessage, expiresAt int64) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("app data must not be empty")
+	}
+	if expiresAt < 0 {
+		return nil, fmt.Errorf("expiry must not be negative")
+	}
+	// Compact so the record takes as little tag memory as possible
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, fmt.Errorf("app data is not valid JSON: %w", err)
+	}
+	return json.Marshal(AppData{Version: AppDataVersion, ExpiresAt: expiresAt, Data: compact.Bytes()})
+}
+
+func decodeAppData(payload []byte) (*AppData, error) {
+	var app AppData
+	if err := json.Unmarshal(payload, &app); err != nil {
+		return nil, fmt.Errorf("invalid app data record: %w", err)
+	}
+	if app.Version < 1 {
+		return nil, fmt.Errorf("invalid app data record: missing version")
+	}
+	if app.Version > AppDataVersion {
+		return nil, fmt.Errorf("%w %d (newest supported is %d)", ErrUnsupportedAppDataVersion, app.Version, AppDataVersion)
+	}
+	if len(app.Data) == 0 {
+		return nil, fmt.Errorf("invalid app data record: missing data")
+	}
+	if app.ExpiresAt < 0 {
+		return nil, fmt.Errorf("invalid app data record: negative expiresAt")
+	}
+	app.Expired = isExpired(app.ExpiresAt, time.Now())
+	return &app, nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEncodeAppData(t *testing.T) {
+	payload, err := encodeAppData(json.RawMessage(`{ "printer": "mk4", "slots": [1, 2] }`), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != `{"version":1,"data":{"printer":"mk4","slots":[1,2]}}` {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+
+	if _, err := encodeAppData(nil, 0); err == nil {
+		t.Error("expected error for empty data")
+	}
+	if _, err := encodeAppData(json.RawMessage(`{"printer":`), 0); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestAppDataFromCard(t *testing.T) {
+	payload, _ := encodeAppData(json.RawMessage(`{"spool":42}`), 0)
+	message := createNDEFRecordRaw(0x02, []byte(AppDataMIMEType), payload, true, true)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	app, err := appDataFromCard(card)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if app.Version != AppDataVersion || string(app.Data) != `{"spool":42}` {
+		t.Errorf("unexpected app data: version %d, data %s", app.Version, app.Data)
+	}
+	if card.DataType != "json" {
+		t.Errorf("expected app data record to read as json, got %s", card.DataType)
+	}
+
+	// Card without an app data record
+	if _, err := appDataFromCard(&Card{Records: []ParsedRecord{{TNF: 0x01, Type: "T"}}}); !errors.Is(err, ErrNoAppData) {
+		t.Errorf("expected ErrNoAppData, got %v", err)
+	}
+}
+
+func TestDecodeAppData_Versions(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr error
+		ok      bool
+	}{
+		{"current", `{"version":1,"data":"x"}`, nil, true},
+		{"unknown fields ignored", `{"version":1,"data":[1],"writer":"app"}`, nil, true},
+		{"newer version", `{"version":2,"data":{}}`, ErrUnsupportedAppDataVersion, false},
+		{"missing version", `{"data":{}}`, nil, false},
+		{"missing data", `{"version":1}`, nil, false},
+		{"negative expiry", `{"version":1,"expiresAt":-1,"data":{}}`, nil, false},
+		{"not JSON", `version 1`, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeAppData([]byte(tt.payload))
+			if tt.ok != (err == nil) {
+				t.Fatalf("got error %v, want ok=%v", err, tt.ok)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAppData_Expiry(t *testing.T) {
+	past := time.Now().Add(-time.Hour).Unix()
+	payload, err := encodeAppData(json.RawMessage(`{"door":3}`), past)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf(`{"version":1,"expiresAt":%d,"data":{"door":3}}`, past); string(payload) != want {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+	app, err := decodeAppData(payload)
+	if err != nil || app.ExpiresAt != past || !app.Expired {
+		t.Errorf("expected expired app data, got %+v (%v)", app, err)
+	}
+
+	// A stored expired flag is ignored in favour of the expiry
+	future := time.Now().Add(time.Hour).Unix()
+	app, err = decodeAppData([]byte(fmt.Sprintf(`{"version":1,"expiresAt":%d,"expired":true,"data":1}`, future)))
+	if err != nil || app.Expired {
+		t.Errorf("expected unexpired app data, got %+v (%v)", app, err)
+	}
+
+	if _, err := encodeAppData(json.RawMessage(`1`), -1); err == nil {
+		t.Error("expected error for negative expiry")
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// PC/SC Part 3 standard bytes (SS) for contactless storage cards
+const (
+	atrStandardISO14443A = 0x03 // ISO 14443 A, part 3 (NTAG, MIFARE)
+	atrStandardISO15693  = 0x0B // ISO 15693, part 3 (ICODE SLIX)
+)
+
+// pcscRID is the registered application provider ID of the PC/SC workgroup,
+// which prefixes the historical bytes of contactless storage card ATRs.
+var pcscRID = []byte{0xA0, 0x00, 0x00, 0x03, 0x06}
+
+var errShortATR = errors.New("ATR truncated")
+
+// ATR is an Answer To Reset split into its ISO 7816-3 structure.
+type ATR struct {
+	TS         byte
+	T0         byte
+	Interface  []byte // TA/TB/TC/TD interface bytes, in order
+	TDs        []byte // The TDi bytes alone
+	Historical []byte
+	TCK        *byte // Check byte, present unless only T=0 is indicated
+}
+
+// ParseATR parses an ATR by walking its TS/T0/TDi structure rather than fixed
+// offsets, so extra interface bytes or an odd number of historical bytes don't
+// shift the fields. Bytes past the declared structure are ignored.
+func ParseATR(raw []byte) (*ATR, error) {
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("%w: %d bytes", errShortATR, len(raw))
+	}
+	if raw[0] != 0x3B && raw[0] != 0x3F {
+		return nil, fmt.Errorf("invalid ATR: TS %02X", raw[0])
+	}
+
+	atr := &ATR{TS: raw[0], T0: raw[1]}
+	pos := 2
+	indicator := raw[1] >> 4
+	needTCK := false
+	for {
+		count := 0
+		for bit := byte(1); bit <= 8; bit <<= 1 {
+			if indicator&bit != 0 {
+				count++
+			}
+		}
+		if pos+count > len(raw) {
+			return nil, fmt.Errorf("%w: interface bytes", errShortATR)
+		}
+		atr.Interface = append(atr.Interface, raw[pos:pos+count]...)
+		pos += count
+
+		if indicator&0x08 == 0 {
+			break
+		}
+		td := raw[pos-1]
+		atr.TDs = append(atr.TDs, td)
+		if td&0x0F != 0 {
+			needTCK = true
+		}
+		indicator = td >> 4
+	}
+
+	k := int(raw[1] & 0x0F)
+	if pos+k > len(raw) {
+		return nil, fmt.Errorf("%w: expected %d historical bytes, got %d", errShortATR, k, len(raw)-pos)
+	}
+	atr.Historical = raw[pos : pos+k]
+	pos += k
+
+	if needTCK && pos < len(raw) {
+		tck := raw[pos]
+		atr.TCK = &tck
+	}
+
+	return atr, nil
+}
+
+// parseATRHex is ParseATR for the hex ATR stored on Card.
+func parseATRHex(atrHex string) (*ATR, error) {
+	raw, err := hex.DecodeString(atrHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATR hex: %w", err)
+	}
+	return ParseATR(raw)
+}
+
+// Contactless reports whether the ATR has the form PC/SC readers construct for
+// contactless cards: TD1 = 0x80, TD2 = 0x01.
+func (a *ATR) Contactless() bool {
+	return len(a.TDs) >= 2 && a.TDs[0] == 0x80 && a.TDs[1] == 0x01
+}
+
+// StorageCard returns the standard (SS) and card name (NN NN) bytes of a
+// PC/SC Part 3 contactless storage card ATR. Historical bytes are:
+// 80 4F [len] A0 00 00 03 06 [SS] [NN NN] [RFU...]
+func (a *ATR) StorageCard() (standard byte, cardName uint16, ok bool) {
+	h := a.Historical
+	if len(h) < 11 || h[0] != 0x80 || h[1] != 0x4F || !bytes.Equal(h[3:8], pcscRID) {
+		return 0, 0, false
+	}
+	return h[8], uint16(h[9])<<8 | uint16(h[10]), true
+}
+
+// atrStandard returns the storage card standard byte of a hex ATR, or 0 if the
+// ATR is malformed or not a contactless storage card.
+func atrStandard(atrHex string) byte {
+	atr, err := parseATRHex(atrHex)
+	if err != nil {
+		return 0
+	}
+	standard, _, ok := atr.StorageCard()
+	if !ok {
+		return 0
+	}
+	return standard
+}
+
+// isISO15693ATR reports whether a hex ATR identifies an ISO 15693 card.
+func isISO15693ATR(atrHex string) bool {
+	return atrStandard(atrHex) == atrStandardISO15693
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestParseATR(t *testing.T) {
+	tests := []struct {
+		name          string
+		atr           string
+		contactless   bool
+		storage       bool
+		standard      byte
+		cardName      uint16
+		historicalHex string
+	}{
+		{
+			name: "ACR122U NTAG", atr: "3b8f8001804f0ca0000003060300030000000068",
+			contactless: true, storage: true, standard: atrStandardISO14443A, cardName: 0x0003,
+			historicalHex: "804f0ca00000030603000300000000",
+		},
+		{
+			name: "ACR1552U ICODE SLIX", atr: "3b8f8001804f0ca0000003060b00140000000077",
+			contactless: true, storage: true, standard: atrStandardISO15693, cardName: 0x0014,
+			historicalHex: "804f0ca0000003060b001400000000",
+		},
+		{
+			// Extra TA1 byte shifts every field after T0 by one byte
+			name: "storage card with TA1", atr: "3b9f118001804f0ca000000306030001000000007b",
+			contactless: true, storage: true, standard: atrStandardISO14443A, cardName: 0x0001,
+			historicalHex: "804f0ca00000030603000100000000",
+		},
+		{
+			name: "DESFire (ISO 14443-4)", atr: "3b8180018080",
+			contactless: true, historicalHex: "80",
+		},
+		{
+			name: "contact smart card", atr: "3b6800000073c84013009000",
+			historicalHex: "0073c84013009000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atr, err := parseATRHex(tt.atr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if atr.Contactless() != tt.contactless {
+				t.Errorf("Contactless() = %v, want %v", atr.Contactless(), tt.contactless)
+			}
+			standard, cardName, ok := atr.StorageCard()
+			if ok != tt.storage || standard != tt.standard || cardName != tt.cardName {
+				t.Errorf("StorageCard() = %02x, %04x, %v; want %02x, %04x, %v",
+					standard, cardName, ok, tt.standard, tt.cardName, tt.storage)
+			}
+			if got := hex.EncodeToString(atr.Historical); got != tt.historicalHex {
+				t.Errorf("Historical = %s, want %s", got, tt.historicalHex)
+			}
+		})
+	}
+}
+
+func TestParseATR_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		atr  string
+	}{
+		{"empty", ""},
+		{"TS only", "3b"},
+		{"bad TS", "ff8f8001"},
+		{"missing TD2", "3b8f80"},
+		{"truncated historical bytes", "3b8f8001804f0ca0000003060300"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.atr)
+			if _, err := ParseATR(raw); err == nil {
+				t.Error("expected error")
+			}
+			if atrStandard(tt.atr) != 0 || isISO15693ATR(tt.atr) {
+				t.Error("malformed ATR should not identify a card standard")
+			}
+		})
+	}
+
+	if _, err := ParseATR(nil); !errors.Is(err, errShortATR) {
+		t.Errorf("expected errShortATR, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// cascadeTag is the first byte of cascade level 1 for 7-byte UIDs; it is
+// included in the first check byte.
+const cascadeTag = 0x88
+
+// expectedBCCs returns the check bytes a tag should store for its UID: BCC0
+// for a 4-byte UID, BCC0 and BCC1 for a 7-byte UID. Other UID lengths have
+// none.
+func expectedBCCs(uid []byte) []byte {
+	switch len(uid) {
+	case 4:
+		return []byte{xorBytes(0, uid)}
+	case 7:
+		return []byte{xorBytes(cascadeTag, uid[:3]), xorBytes(0, uid[3:])}
+	}
+	return nil
+}
+
+func xorBytes(seed byte, data []byte) byte {
+	for _, b := range data {
+		seed ^= b
+	}
+	return seed
+}
+
+// checkBCC reads the check bytes stored on the tag, where the reader can
+// get at them, and sets cardInfo.BCCValid. MIFARE Classic cards with a
+// 4-byte UID store BCC0 in block 0 (read with the default keys); Type 2 tags
+// with a 7-byte UID store BCC0 in page 0 and BCC1 in page 2. A mismatch
+// usually means a damaged or cloned tag and is logged as a warning.
+func checkBCC(card cardTransmitter, cardInfo *Card) {
+	uid, err := hex.DecodeString(cardInfo.UID)
+	if err != nil {
+		return
+	}
+
+	var stored []byte
+	switch {
+	case cardInfo.Type == "MIFARE Classic" && len(uid) == 4:
+		lastAuthSector := -1
+		block, err := readMifareClassicBlock(card, 0, &lastAuthSector)
+		if err != nil || len(block) < 5 {
+			return
+		}
+		stored = block[4:5]
+	case cardInfo.Protocol == "NFC-A" && cardInfo.Type != "MIFARE Classic" && len(uid) == 7:
+		if stored, err = readType2BCCs(card); err != nil {
+			return
+		}
+	default:
+		return
+	}
+
+	setBCCValid(cardInfo, uid, stored)
+}
+
+// readType2BCCs reads BCC0 (page 0, byte 3) and BCC1 (page 2, byte 0) from a
+// Type 2 tag, with one READ of pages 0-3.
+func readType2BCCs(card cardTransmitter) ([]byte, error) {
+	// READ BINARY for 16 bytes, then the ACR122U InCommunicateThru READ (0x30)
+	// like readNTAGPage, which answers D5 43 00 and the 16 bytes
+	rsp, err := transmit(card, []byte{0xFF, 0xB0, 0x00, 0x00, 0x10})
+	if err == nil && len(rsp) >= 18 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
+		return []byte{rsp[3], rsp[8]}, nil
+	}
+	rsp, err = transmit(card, []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x42, 0x30, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+	if len(rsp) >= 21 && rsp[0] == 0xD5 && rsp[1] == 0x43 && rsp[2] == 0x00 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
+		return []byte{rsp[3+3], rsp[3+8]}, nil
+	}
+	return nil, fmt.Errorf("read of pages 0-3 failed: % X", rsp)
+}
+
+// setBCCValid compares the stored check bytes with the ones expected for uid.
+func setBCCValid(cardInfo *Card, uid, stored []byte) {
+	expected := expectedBCCs(uid)
+	valid := bytes.Equal(expected, stored)
+	cardInfo.BCCValid = &valid
+	if !valid {
+		logging.Warn(logging.CatCard, "UID check byte mismatch, tag may be damaged or cloned", map[string]any{
+			"uid":      cardInfo.UID,
+			"expected": hex.EncodeToString(expected),
+			"stored":   hex.EncodeToString(stored),
+		})
+	}
+}
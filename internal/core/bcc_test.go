@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExpectedBCCs(t *testing.T) {
+	tests := []struct {
+		name string
+		uid  []byte
+		want []byte
+	}{
+		// 12 ^ 34 ^ 56 ^ 78 = 0x08
+		{"4-byte UID", []byte{0x12, 0x34, 0x56, 0x78}, []byte{0x08}},
+		// 0x88 ^ 04 ^ A1 ^ B2 = 0x9F, C3 ^ D4 ^ E5 ^ F6 = 0x04
+		{"7-byte UID", []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6}, []byte{0x9F, 0x04}},
+		{"10-byte UID", make([]byte, 10), nil},
+		{"empty UID", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expectedBCCs(tt.uid); !bytes.Equal(got, tt.want) {
+				t.Errorf("expectedBCCs(% X) = % X, want % X", tt.uid, got, tt.want)
+			}
+		})
+	}
+}
+
+// bccTag answers the reads checkBCC sends: a 16-byte READ BINARY of the
+// first pages or block, and for MIFARE Classic the key load and authentication
+// before it.
+type bccTag struct {
+	first    [16]byte // Pages 0-3 or block 0
+	commands int
+}
+
+func (t *bccTag) Transmit(cmd []byte) ([]byte, error) {
+	t.commands++
+	switch {
+	case len(cmd) > 2 && cmd[0] == 0xFF && (cmd[1] == 0x82 || cmd[1] == 0x86): // LOAD KEYS, GENERAL AUTHENTICATE
+		return []byte{0x90, 0x00}, nil
+	case len(cmd) == 5 && cmd[0] == 0xFF && cmd[1] == 0xB0 && cmd[3] == 0 && cmd[4] == 0x10:
+		return append(t.first[:], 0x90, 0x00), nil
+	}
+	return []byte{0x6A, 0x81}, nil
+}
+
+func TestCheckBCC_Type2(t *testing.T) {
+	tag := &bccTag{first: [16]byte{
+		0x04, 0xA1, 0xB2, 0x9F, // UID0-2, BCC0
+		0xC3, 0xD4, 0xE5, 0xF6, // UID3-6
+		0x04, 0x48, 0x00, 0x00, // BCC1, internal, lock bytes
+		0xE1, 0x10, 0x6D, 0x00, // CC
+	}}
+	card := &Card{UID: "04a1b2c3d4e5f6", Type: "NTAG216", Protocol: "NFC-A"}
+	checkBCC(tag, card)
+	if card.BCCValid == nil || !*card.BCCValid {
+		t.Errorf("expected a valid BCC, got %v", card.BCCValid)
+	}
+	if tag.commands != 1 {
+		t.Errorf("expected pages 0-3 in one read, sent %d commands", tag.commands)
+	}
+
+	// A clone whose UID was rewritten without fixing BCC1
+	tag.first[8] = 0x05
+	card.BCCValid = nil
+	checkBCC(tag, card)
+	if card.BCCValid == nil || *card.BCCValid {
+		t.Errorf("expected an invalid BCC, got %v", card.BCCValid)
+	}
+}
+
+func TestCheckBCC_MifareClassic(t *testing.T) {
+	// Block 0: UID 12 34 56 78, BCC0 08, then manufacturer data
+	tag := &bccTag{first: [16]byte{0x12, 0x34, 0x56, 0x78, 0x08, 0x08, 0x04, 0x00}}
+	card := &Card{UID: "12345678", Type: "MIFARE Classic", Protocol: "NFC-A"}
+	checkBCC(tag, card)
+	if card.BCCValid == nil || !*card.BCCValid {
+		t.Errorf("expected a valid BCC, got %v", card.BCCValid)
+	}
+
+	tag.first[4] = 0x09
+	card.BCCValid = nil
+	checkBCC(tag, card)
+	if card.BCCValid == nil || *card.BCCValid {
+		t.Errorf("expected an invalid BCC, got %v", card.BCCValid)
+	}
+
+	// 7-byte Classic UIDs keep no BCC in block 0
+	card = &Card{UID: "04a1b2c3d4e5f6", Type: "MIFARE Classic", Protocol: "NFC-A"}
+	checkBCC(tag, card)
+	if card.BCCValid != nil {
+		t.Errorf("expected no BCC check for a 7-byte Classic UID, got %v", *card.BCCValid)
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+// ClearCaches empties the per-reader state core keeps in memory: the card
+// type last detected on each reader and each reader's last operation error.
+// It returns the number of entries removed, by cache name.
+func ClearCaches() map[string]int {
+	lastCardTypes.mu.Lock()
+	cardTypes := len(lastCardTypes.types)
+	lastCardTypes.types = make(map[string]string)
+	lastCardTypes.mu.Unlock()
+
+	lastReaderErrors.mu.Lock()
+	readerErrors := len(lastReaderErrors.errs)
+	lastReaderErrors.errs = make(map[string]ReaderError)
+	lastReaderErrors.mu.Unlock()
+
+	return map[string]int{
+		"cardTypes":    cardTypes,
+		"readerErrors": readerErrors,
+	}
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClearCaches(t *testing.T) {
+	const reader = "Test Reader (clear caches)"
+	ClearCaches()
+	rememberCardType(reader, "NTAG215")
+	recordOperationResult(reader, "read_card", errors.New("transmit failed"))
+
+	cleared := ClearCaches()
+	if cleared["cardTypes"] != 1 || cleared["readerErrors"] != 1 {
+		t.Errorf("cleared = %v, want one card type and one reader error", cleared)
+	}
+	if got := lastCardType(reader); got != "" {
+		t.Errorf("card type should be cleared, got %q", got)
+	}
+	if got := LastReaderError(reader); got != nil {
+		t.Errorf("reader error should be cleared, got %+v", got)
+	}
+
+	if cleared := ClearCaches(); cleared["cardTypes"] != 0 || cleared["readerErrors"] != 0 {
+		t.Errorf("second clear = %v, want nothing", cleared)
+	}
+}
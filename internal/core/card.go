@@ -1,13 +1,23 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"crypto/aes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/SimplyPrint/nfc-agent/internal/data"
 	"github.com/SimplyPrint/nfc-agent/internal/logging"
 	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
 	"github.com/ebfe/scard"
@@ -17,6 +27,9 @@ import (
 type Card struct {
 	UID         string `json:"uid"`
 	ATR         string `json:"atr,omitempty"`
+	ATQA        string `json:"atqa,omitempty"`        // ISO 14443-A ATQA (hex), empty if the reader can't provide it
+	SAK         string `json:"sak,omitempty"`         // ISO 14443-A SAK (hex), empty if the reader can't provide it
+	BCCValid    *bool  `json:"bccValid,omitempty"`    // Stored UID check bytes match the UID; nil unless ReadOptions.CheckBCC is set and they could be read
 	Type        string `json:"type,omitempty"`        // e.g., "NTAG213", "NTAG215", "NTAG216", "MIFARE Classic"
 	Protocol    string `json:"protocol,omitempty"`    // Short protocol: "NFC-A", "NFC-V"
 	ProtocolISO string `json:"protocolISO,omitempty"` // Full ISO protocol: "ISO 14443-3A", "ISO 15693"
@@ -25,23 +38,205 @@ type Card struct {
 	URL         string `json:"url,omitempty"`         // URL from first NDEF record (if URI record)
 	Data        string `json:"data,omitempty"`        // NDEF data read from the tag (if available)
 	DataType    string `json:"dataType,omitempty"`    // Type of data: "text", "json", "binary", or "unknown"
+
+	Records []ParsedRecord `json:"records,omitempty"` // All NDEF records found on the tag
+
+	ContentHash string `json:"contentHash,omitempty"` // SHA-256 (hex) of the NDEF message, empty if the tag has none
+
+	PagesRead int `json:"pagesRead,omitempty"` // Debug: pages/blocks read while looking for NDEF data
+
+	NDEFMalformed       bool   `json:"ndefMalformed,omitempty"`       // NDEF message structure is invalid (records were still extracted)
+	NDEFMalformedReason string `json:"ndefMalformedReason,omitempty"` // First structural problem found
+
+	NDEFReadOnly bool `json:"ndefReadOnly,omitempty"` // Capability container marks the NDEF area read-only (Type 2 tags)
+
+	MultipleNDEFMessages bool             `json:"multipleNdefMessages,omitempty"` // Tag holds more than one NDEF TLV (non-standard); only detected with ReadOptions.ReadAll
+	ExtraNDEFMessages    [][]ParsedRecord `json:"extraNdefMessages,omitempty"`    // Records of each NDEF message after the first, with ReadOptions.ReadAll
+
+	Debug *NDEFDebug `json:"debug,omitempty"` // NDEF TLV location and raw bytes, with ReadOptions.Debug
+
+	DebugLog []string `json:"debugLog,omitempty"` // APDU exchanges and log entries of this read, when the request asked for an APDU trace
+
+	PasswordProtected bool `json:"passwordProtected,omitempty"` // Reading stopped at pages behind the tag's password
+	ProtectedFromPage int  `json:"protectedFromPage,omitempty"` // AUTH0: first password-protected page, if it could be read
+
+	DetectionMethod string `json:"detectionMethod,omitempty"` // Detection step that set Type: "TYPE4-CC", "GET_VERSION-1a", "GET_VERSION-1b", "CC-2a", "CC-2b", "MIFARE-auth-probe", "ATR" or "none"
+	Confidence      string `json:"confidence,omitempty"`      // How far Type can be trusted: "high", "medium" or "low"
+
+	Diagnostics *CardDiagnostics `json:"diagnostics,omitempty"` // Raw detection data, only for cards whose type wasn't recognised
+
+	RawNDEF []byte `json:"-"` // Raw NDEF message (without TLV wrapping), if one was found
+}
+
+// CardDiagnostics holds what type detection saw on a card it couldn't
+// identify, so new card variants can be reported without debug logging.
+type CardDiagnostics struct {
+	GetVersion string   `json:"getVersion,omitempty"` // GET_VERSION response including status word (hex)
+	CC         string   `json:"cc,omitempty"`         // Capability container, page 3 (hex)
+	Methods    []string `json:"methods"`              // Detection methods that ran: "4", "1a", "1b", "2a", "2b", "2c", "3"
+}
+
+// Detection confidence levels. High means the tag identified itself (GET_VERSION,
+// a Type 4 capability container, MIFARE Classic authentication); medium means
+// the type was inferred from the rewritable capability container; low means
+// only the ATR pattern, which some readers report wrongly, or nothing matched.
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// Card types detection falls back to when nothing matched.
+const (
+	cardTypeUnknown            = "NFC Tag (type unknown)"
+	cardTypeUnknownContactless = "Unknown ISO 14443/15693 tag"
+)
+
+// ParsedRecord is a single decoded NDEF record.
+type ParsedRecord struct {
+	TNF      byte   `json:"tnf"`                // Type Name Format (0x01 well-known, 0x02 MIME, ...)
+	Type     string `json:"type"`               // Record type, e.g. "T", "U" or a MIME type
+	Data     string `json:"data,omitempty"`     // Decoded payload
+	DataType string `json:"dataType,omitempty"` // "text", "url", "json", "cbor", "binary", "openprinttag", "handover", "empty" or "unknown"
+	Lang     string `json:"lang,omitempty"`     // Language code (text records only)
+	ID       string `json:"id,omitempty"`       // Record ID, e.g. a carrier referenced by a handover record
+
+	Raw string `json:"raw,omitempty"` // Hex of the complete record (header, type, ID, payload); only with ReadOptions.IncludeRaw
+
+	Payload  []byte `json:"-"` // Raw record payload
+	RawBytes []byte `json:"-"` // Complete raw record
+}
+
+// ReadOptions controls how card data is interpreted on read.
+type ReadOptions struct {
+	Lang         string // Preferred language for text records (falls back to the first text record)
+	MaxPages     int    // Page/block read budget for this read (0 uses MaxNDEFReadPages)
+	IncludeRaw   bool   // Attach the raw hex of each NDEF record to Records
+	MifareBlocks int    // MIFARE Classic block count, e.g. 128 for Classic 2K (0 infers it from the detected size)
+	Prefer       string // Record data type ("openprinttag", "url", "text") that populates Data/DataType when present
+	Encoding     string // Encoding of binary payloads: "hex" (default) or "base64"
+	ReadAll      bool   // Read past the first NDEF TLV and return the records of every NDEF message on the tag
+	Debug        bool   // Attach NDEFDebug with the TLV offset and the raw bytes read
+	CheckBCC     bool   // Read the UID check bytes stored on the tag and set BCCValid
+
+	Trace *APDUTrace // Record this read's APDU exchanges and log entries; nil disables tracing
+}
+
+// NDEFDebug describes where the NDEF data was found, for debugging reads that
+// return unexpected data. Only set with ReadOptions.Debug.
+type NDEFDebug struct {
+	TLVOffset  int    `json:"tlvOffset"`  // Offset of the NDEF TLV in the data area read, -1 if none was found or the tag has no TLV area (Type 4)
+	NDEFLength int    `json:"ndefLength"` // Declared length of the NDEF message
+	RawHex     string `json:"rawHex"`     // All bytes read from the data area (Type 4: the NDEF message)
+	PagesRead  int    `json:"pagesRead"`  // Pages/blocks read
+}
+
+// binaryEncoders maps the ReadOptions.Encoding values to how binary payloads
+// are rendered in Data.
+var binaryEncoders = map[string]func([]byte) string{
+	"hex":    hex.EncodeToString,
+	"base64": base64.StdEncoding.EncodeToString,
+}
+
+// IsBinaryEncoding reports whether name is a supported ReadOptions.Encoding.
+// An empty name selects the default (hex).
+func IsBinaryEncoding(name string) bool {
+	_, ok := binaryEncoders[name]
+	return ok || name == ""
+}
+
+// MaxMifareBlocks is the largest MIFARE Classic block count (Classic 4K).
+const MaxMifareBlocks = 256
+
+// MaxNDEFReadPages caps how many pages/blocks are read while looking for NDEF
+// data, bounding read time on unknown or non-NDEF cards. 0 means no cap beyond
+// the per-card-type defaults.
+var MaxNDEFReadPages = 0
+
+// PageWriteDelay is waited between consecutive page writes, for cheap clone
+// tags that NAK fast bulk writes. 0 writes back to back.
+var PageWriteDelay time.Duration
+
+// WriteSettleDelay is waited after a write before reading it back to verify
+// it. 0 reads back immediately.
+var WriteSettleDelay time.Duration
+
+// waitBeforePageWrite applies PageWriteDelay before every page write but the first.
+func waitBeforePageWrite(i int) {
+	if i > 0 && PageWriteDelay > 0 {
+		time.Sleep(PageWriteDelay)
+	}
+}
+
+// waitWriteSettle applies WriteSettleDelay before a verification read.
+func waitWriteSettle() {
+	if WriteSettleDelay > 0 {
+		time.Sleep(WriteSettleDelay)
+	}
 }
 
 // GetCardUID connects to the specified reader and attempts to read the card UID.
 // Returns an error if no card is present or if reading fails.
 func GetCardUID(readerName string) (*Card, error) {
-	ctx, err := scard.EstablishContext()
+	return GetCardUIDWithOptions(readerName, ReadOptions{})
+}
+
+// CardSettleDelay is waited before retrying a failed first read of a card that
+// was just placed, while its RF field stabilizes. 0 disables the retry.
+var CardSettleDelay = 150 * time.Millisecond
+
+// GetPlacedCardUID is GetCardUID for a card that was just placed on the
+// reader: if the read fails with the card still there, it waits
+// CardSettleDelay and retries once.
+func GetPlacedCardUID(readerName string) (*Card, error) {
+	return readPlacedCard(GetCardUID, readerName)
+}
+
+func readPlacedCard(read func(readerName string) (*Card, error), readerName string) (*Card, error) {
+	card, err := read(readerName)
+	if err == nil || CardSettleDelay <= 0 || !settleRetryable(err) {
+		return card, err
+	}
+	logging.Debug(logging.CatCard, "First read of placed card failed, retrying after settle delay", map[string]any{
+		"reader":   readerName,
+		"error":    err.Error(),
+		"settleMs": CardSettleDelay.Milliseconds(),
+	})
+	time.Sleep(CardSettleDelay)
+	return read(readerName)
+}
+
+// settleRetryable reports whether a failed read may succeed once the field
+// has settled, i.e. it didn't fail for lack of a card, reader or PC/SC slot.
+func settleRetryable(err error) bool {
+	for _, target := range []error{
+		scard.ErrNoSmartcard, scard.ErrRemovedCard, scard.ErrUnknownReader, scard.ErrReaderUnavailable,
+		ErrReaderBusy, ErrPCSCUnavailable, ErrTooManyOperations,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCardUIDWithOptions is like GetCardUID but applies read options.
+func GetCardUIDWithOptions(readerName string, opts ReadOptions) (_ *Card, err error) {
+	defer trackOperation("read_card", readerName, &err)()
+
+	ctx, err := establishContext()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
 	// Connect to the reader
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
+	defer opts.Trace.attach(card)()
 
 	// Get the ATR (Answer To Reset)
 	status, err := card.Status()
@@ -53,7 +248,7 @@ func GetCardUID(readerName string) (*Card, error) {
 	// This is a common command for getting the UID from PC/SC readers
 	getUIDCmd := []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
 
-	rsp, err := card.Transmit(getUIDCmd)
+	rsp, err := transmit(card, getUIDCmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to transmit get UID command: %w", err)
 	}
@@ -78,40 +273,141 @@ func GetCardUID(readerName string) (*Card, error) {
 		ATR: hex.EncodeToString(status.Atr),
 	}
 
+	// ATQA/SAK are only meaningful for ISO 14443-A cards
+	if !isISO15693ATR(cardInfo.ATR) {
+		cardInfo.ATQA, cardInfo.SAK = readATQASAK(card)
+	}
+
 	// Detect card type by reading version info (for NTAG cards)
 	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
 
 	// Try to read NDEF data from the card
-	readNDEFData(card, cardInfo)
+	readNDEFData(card, cardInfo, opts)
+	if opts.CheckBCC {
+		checkBCC(card, cardInfo)
+	}
+	encodeBinaryData(cardInfo, opts.Encoding)
+	preferRecord(cardInfo, opts.Prefer)
+	selectTextRecord(cardInfo, opts.Lang)
+
+	if opts.IncludeRaw {
+		for i := range cardInfo.Records {
+			cardInfo.Records[i].Raw = hex.EncodeToString(cardInfo.Records[i].RawBytes)
+		}
+		for _, records := range cardInfo.ExtraNDEFMessages {
+			for i := range records {
+				records[i].Raw = hex.EncodeToString(records[i].RawBytes)
+			}
+		}
+	}
 
 	return cardInfo, nil
 }
 
+// readATQASAK retrieves the ATQA and SAK using the GET DATA variants supported by
+// ACS readers. Returns empty strings if the reader doesn't provide them.
+func readATQASAK(card *scard.Card) (atqa, sak string) {
+	for _, cmd := range [][]byte{
+		{0xFF, 0xCA, 0x0F, 0x00, 0x00}, // GET DATA (card info) - ATQA + SAK on supporting ACS readers
+		{0xFF, 0xCA, 0x01, 0x00, 0x00}, // Alternative GET DATA variant used by some ACS readers
+	} {
+		rsp, err := transmit(card, cmd)
+		if err != nil || len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+			continue
+		}
+		if atqa, sak = parseATQASAK(rsp[:len(rsp)-2]); atqa != "" {
+			logCard(card, logging.LevelDebug, logging.CatCard, "ATQA/SAK read", map[string]any{
+				"command": hex.EncodeToString(cmd),
+				"atqa":    atqa,
+				"sak":     sak,
+			})
+			return atqa, sak
+		}
+	}
+	return "", ""
+}
+
+// parseATQASAK extracts ATQA and SAK from a GET DATA response body.
+// Only a 3-byte ATQA (2 bytes) + SAK (1 byte) layout is accepted; anything else
+// (e.g. historical bytes) is ignored.
+func parseATQASAK(data []byte) (atqa, sak string) {
+	if len(data) != 3 {
+		return "", ""
+	}
+	return hex.EncodeToString(data[0:2]), hex.EncodeToString(data[2:3])
+}
+
 // detectCardType attempts to determine the card type (NTAG213/215/216, MIFARE, etc.)
-func detectCardType(card *scard.Card, cardInfo *Card) {
-	// Log the final detection result when function returns
+func detectCardType(card cardTransmitter, cardInfo *Card) {
+	// Set if the capability container was read during detection
+	ccRead := false
+
+	// Raw responses, attached to cards that end up unidentified
+	diag := &CardDiagnostics{}
+
+	// Read the CC access byte if detection skipped it, then log the final
+	// detection result when function returns
 	defer func() {
-		logging.Debug(logging.CatCard, "Card type detection complete", map[string]any{
+		// Tags identified by GET_VERSION skip the CC reads; read it now for the access byte
+		if !ccRead && cardInfo.Protocol == "NFC-A" && cardInfo.Type != "MIFARE Classic" {
+			if cc, err := readNTAGPage(card, 3); err == nil {
+				applyCCAccess(cardInfo, cc)
+				if diag.CC == "" {
+					diag.CC = hex.EncodeToString(cc)
+				}
+			}
+		}
+		if cardInfo.NDEFReadOnly {
+			cardInfo.Writable = false
+		}
+		if cardInfo.Type == cardTypeUnknown || cardInfo.Type == cardTypeUnknownContactless {
+			cardInfo.Diagnostics = diag
+		}
+		logCard(card, logging.LevelDebug, logging.CatCard, "Card type detection complete", map[string]any{
 			"uid":         cardInfo.UID,
 			"type":        cardInfo.Type,
 			"size":        cardInfo.Size,
 			"atr":         cardInfo.ATR,
+			"sak":         cardInfo.SAK,
 			"protocol":    cardInfo.Protocol,
 			"protocolISO": cardInfo.ProtocolISO,
+			"method":      cardInfo.DetectionMethod,
+			"confidence":  cardInfo.Confidence,
 		})
 	}()
 
-	// Detect protocol from ATR patterns (set early, before type detection)
+	// Detect protocol from the ATR (set early, before type detection)
 	atr := cardInfo.ATR
-	if len(atr) >= 30 && (atr[0:4] == "3b8f" || atr[0:4] == "3b8b") {
-		if contains(atr, "03060b") {
-			// ISO 15693 (NFC-V) - ICode SLI/SLIX/SLIX2
-			cardInfo.Protocol = "NFC-V"
-			cardInfo.ProtocolISO = "ISO 15693"
-		} else if contains(atr, "03060300") {
-			// ISO 14443-3A (NFC-A) - NTAG, MIFARE Classic, MIFARE Ultralight
-			cardInfo.Protocol = "NFC-A"
-			cardInfo.ProtocolISO = "ISO 14443-3A"
+	parsedATR, atrErr := parseATRHex(atr)
+	if atrErr != nil && atr != "" {
+		logCard(card, logging.LevelDebug, logging.CatCard, "Malformed ATR, skipping ATR-based detection", map[string]any{
+			"atr":   atr,
+			"error": atrErr.Error(),
+		})
+	}
+	switch atrStandard(atr) {
+	case atrStandardISO15693:
+		// ISO 15693 (NFC-V) - ICode SLI/SLIX/SLIX2
+		cardInfo.Protocol = "NFC-V"
+		cardInfo.ProtocolISO = "ISO 15693"
+	case atrStandardISO14443A:
+		// ISO 14443-3A (NFC-A) - NTAG, MIFARE Classic, MIFARE Ultralight
+		cardInfo.Protocol = "NFC-A"
+		cardInfo.ProtocolISO = "ISO 14443-3A"
+	}
+
+	// ISO-DEP (ISO 14443-4) cards have a contactless ATR without the storage card
+	// historical bytes. Check them for a Type 4 NDEF application first, as the
+	// Type 2 commands below don't apply.
+	if parsedATR != nil && parsedATR.Contactless() && atrStandard(atr) == 0 {
+		diag.Methods = append(diag.Methods, "4")
+		if cc, err := selectType4NDEF(card); err == nil {
+			cardInfo.Protocol = "ISO-DEP"
+			cardInfo.ProtocolISO = "ISO 14443-4"
+			applyType4CC(cardInfo, cc)
+			setDetection(cardInfo, "TYPE4-CC", confidenceHigh)
+			return
 		}
 	}
 
@@ -129,11 +425,13 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 
 	// Method 1a: Try GET_VERSION with standard PC/SC passthrough
 	getVersionCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x02, 0x60, 0x00}
-	rsp, err := card.Transmit(getVersionCmd)
+	rsp, err := transmit(card, getVersionCmd)
+	diag.Methods = append(diag.Methods, "1a")
 
 	// Log GET_VERSION response for diagnostics
 	if err == nil && len(rsp) >= 2 {
-		logging.Debug(logging.CatCard, "GET_VERSION response", map[string]any{
+		diag.GetVersion = hex.EncodeToString(rsp)
+		logCard(card, logging.LevelDebug, logging.CatCard, "GET_VERSION response", map[string]any{
 			"method":   "1a",
 			"response": hex.EncodeToString(rsp),
 			"status":   fmt.Sprintf("%02x%02x", rsp[len(rsp)-2], rsp[len(rsp)-1]),
@@ -147,14 +445,14 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		productType := rsp[2]
 		storageSize := rsp[6]
 
-		logging.Debug(logging.CatCard, "GET_VERSION parsed (Method 1a)", map[string]any{
+		logCard(card, logging.LevelDebug, logging.CatCard, "GET_VERSION parsed (Method 1a)", map[string]any{
 			"header":      fmt.Sprintf("0x%02x", header),
 			"productType": fmt.Sprintf("0x%02x", productType),
 			"storageSize": fmt.Sprintf("0x%02x", storageSize),
 		})
 
 		if header != 0x00 {
-			logging.Debug(logging.CatCard, "Invalid GET_VERSION header (Method 1a), ignoring", map[string]any{
+			logCard(card, logging.LevelDebug, logging.CatCard, "Invalid GET_VERSION header (Method 1a), ignoring", map[string]any{
 				"expected": "0x00",
 				"got":      fmt.Sprintf("0x%02x", header),
 			})
@@ -162,42 +460,10 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		} else {
 			getVersionSucceeded = true
 
-			if productType == 0x04 { // NTAG family
-				switch storageSize {
-				case 0x0F: // NTAG213
-					cardInfo.Type = "NTAG213"
-					cardInfo.Size = 180
-					cardInfo.Writable = true
-					return
-				case 0x11: // NTAG215
-					cardInfo.Type = "NTAG215"
-					cardInfo.Size = 504
-					cardInfo.Writable = true
-					return
-				case 0x13: // NTAG216
-					cardInfo.Type = "NTAG216"
-					cardInfo.Size = 888
-					cardInfo.Writable = true
-					return
-				}
-			} else if productType == 0x03 { // MIFARE Ultralight family
-				switch storageSize {
-				case 0x0B: // Ultralight EV1 MF0UL11 (48 bytes)
-					cardInfo.Type = "MIFARE Ultralight EV1"
-					cardInfo.Size = 48
-					cardInfo.Writable = true
-					return
-				case 0x0E: // Ultralight EV1 MF0UL21 (128 bytes)
-					cardInfo.Type = "MIFARE Ultralight EV1"
-					cardInfo.Size = 128
-					cardInfo.Writable = true
-					return
-				default: // Unknown Ultralight variant
-					cardInfo.Type = "MIFARE Ultralight"
-					cardInfo.Size = 64
-					cardInfo.Writable = true
-					return
-				}
+			if match, ok := cardTypeTable().MatchVersion(productType, storageSize); ok {
+				applyCardType(cardInfo, match)
+				setDetection(cardInfo, "GET_VERSION-1a", confidenceHigh)
+				return
 			}
 		}
 	}
@@ -209,11 +475,16 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		time.Sleep(150 * time.Millisecond)
 	}
 	getVersionCmd2 := []byte{0xFF, 0x00, 0x00, 0x00, 0x01, 0x60}
-	rsp, err = card.Transmit(getVersionCmd2)
+	rsp, err = transmit(card, getVersionCmd2)
+	diag.Methods = append(diag.Methods, "1b")
 
 	// Log Method 1b response
 	if err == nil && len(rsp) >= 2 {
-		logging.Debug(logging.CatCard, "GET_VERSION response", map[string]any{
+		// Keep a successful Method 1a response over whatever 1b returned
+		if diag.GetVersion == "" || method1aFailed {
+			diag.GetVersion = hex.EncodeToString(rsp)
+		}
+		logCard(card, logging.LevelDebug, logging.CatCard, "GET_VERSION response", map[string]any{
 			"method":   "1b",
 			"response": hex.EncodeToString(rsp),
 			"status":   fmt.Sprintf("%02x%02x", rsp[len(rsp)-2], rsp[len(rsp)-1]),
@@ -227,14 +498,14 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		productType := rsp[2]
 		storageSize := rsp[6]
 
-		logging.Debug(logging.CatCard, "GET_VERSION parsed (Method 1b)", map[string]any{
+		logCard(card, logging.LevelDebug, logging.CatCard, "GET_VERSION parsed (Method 1b)", map[string]any{
 			"header":      fmt.Sprintf("0x%02x", header),
 			"productType": fmt.Sprintf("0x%02x", productType),
 			"storageSize": fmt.Sprintf("0x%02x", storageSize),
 		})
 
 		if header != 0x00 {
-			logging.Debug(logging.CatCard, "Invalid GET_VERSION header (Method 1b), ignoring", map[string]any{
+			logCard(card, logging.LevelDebug, logging.CatCard, "Invalid GET_VERSION header (Method 1b), ignoring", map[string]any{
 				"expected": "0x00",
 				"got":      fmt.Sprintf("0x%02x", header),
 			})
@@ -242,42 +513,10 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		} else {
 			getVersionSucceeded = true
 
-			if productType == 0x04 { // NTAG family
-				switch storageSize {
-				case 0x0F: // NTAG213
-					cardInfo.Type = "NTAG213"
-					cardInfo.Size = 180
-					cardInfo.Writable = true
-					return
-				case 0x11: // NTAG215
-					cardInfo.Type = "NTAG215"
-					cardInfo.Size = 504
-					cardInfo.Writable = true
-					return
-				case 0x13: // NTAG216
-					cardInfo.Type = "NTAG216"
-					cardInfo.Size = 888
-					cardInfo.Writable = true
-					return
-				}
-			} else if productType == 0x03 { // MIFARE Ultralight family
-				switch storageSize {
-				case 0x0B: // Ultralight EV1 MF0UL11 (48 bytes)
-					cardInfo.Type = "MIFARE Ultralight EV1"
-					cardInfo.Size = 48
-					cardInfo.Writable = true
-					return
-				case 0x0E: // Ultralight EV1 MF0UL21 (128 bytes)
-					cardInfo.Type = "MIFARE Ultralight EV1"
-					cardInfo.Size = 128
-					cardInfo.Writable = true
-					return
-				default: // Unknown Ultralight variant
-					cardInfo.Type = "MIFARE Ultralight"
-					cardInfo.Size = 64
-					cardInfo.Writable = true
-					return
-				}
+			if match, ok := cardTypeTable().MatchVersion(productType, storageSize); ok {
+				applyCardType(cardInfo, match)
+				setDetection(cardInfo, "GET_VERSION-1b", confidenceHigh)
+				return
 			}
 		}
 	}
@@ -285,13 +524,15 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 	// Method 2a: Try reading pages 1-4 (works on ACR1252U where direct page 3 read fails)
 	// Page 3 contains the capability container at offset 8 in this 16-byte response
 	readCmd1 := []byte{0xFF, 0xB0, 0x00, 0x01, 0x10} // Read 16 bytes from page 1
-	rsp, err = card.Transmit(readCmd1)
+	rsp, err = transmit(card, readCmd1)
+	diag.Methods = append(diag.Methods, "2a")
 
 	if err == nil && len(rsp) >= 12 && rsp[len(rsp)-2] == 0x90 {
+		diag.CC = hex.EncodeToString(rsp[8:12])
 		// CC is at bytes 8-11 (page 3 within the 4-page read)
 		// CC byte 0 (index 8): NDEF magic (must be 0xE1 for valid NDEF)
 		// CC byte 2 (index 10): Memory size indicator
-		logging.Debug(logging.CatCard, "CC read (Method 2a)", map[string]any{
+		logCard(card, logging.LevelDebug, logging.CatCard, "CC read (Method 2a)", map[string]any{
 			"response":  hex.EncodeToString(rsp),
 			"cc_bytes":  hex.EncodeToString(rsp[8:12]),
 			"cc_magic":  fmt.Sprintf("0x%02x", rsp[8]),
@@ -299,38 +540,15 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 		})
 		if len(rsp) >= 11 && rsp[8] == 0xE1 { // Validate NDEF magic byte
 			ccDetectionFoundNDEF = true
+			ccRead = true
+			applyCCAccess(cardInfo, rsp[8:12])
 			ccSize := rsp[10]
 
-			// IMPORTANT: For NTAG detection, we require GET_VERSION to have succeeded.
-			// All NXP NTAG21x tags support GET_VERSION. Plain MIFARE Ultralight does NOT.
-			// If GET_VERSION failed but CC size suggests NTAG, it's likely plain Ultralight
-			// with non-standard CC (formatted with more capacity than it has).
-			switch ccSize {
-			case 0x06: // 48 bytes -> MIFARE Ultralight
-				cardInfo.Type = "MIFARE Ultralight"
-				cardInfo.Size = 48
-				cardInfo.Writable = true
-				return
-			case 0x12: // 144 bytes -> NTAG213
-				// CC size 0x12 is too large for plain Ultralight (48 bytes max)
-				// so this must be NTAG213 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG213"
-				cardInfo.Size = 180
-				cardInfo.Writable = true
-				return
-			case 0x3E: // 496 bytes -> NTAG215
-				// CC size 0x3E is too large for plain Ultralight (64 bytes max)
-				// so this must be NTAG215 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG215"
-				cardInfo.Size = 504
-				cardInfo.Writable = true
-				return
-			case 0x6D: // 872 bytes -> NTAG216
-				// CC size 0x6D is too large for plain Ultralight (64 bytes max)
-				// so this must be NTAG216 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG216"
-				cardInfo.Size = 888
-				cardInfo.Writable = true
+			// NTAG CC sizes (0x12, 0x3E, 0x6D) are too large for plain MIFARE Ultralight,
+			// so they identify NTAG21x regardless of the GET_VERSION result.
+			if match, ok := cardTypeTable().MatchCC(ccSize); ok {
+				applyCardType(cardInfo, match)
+				setDetection(cardInfo, "CC-2a", confidenceMedium)
 				return
 			}
 		}
@@ -339,44 +557,27 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 	// Method 2b: Try reading page 3 directly to get capability container (works on ACR122U)
 	// Read 4 pages starting from page 3 (CC bytes)
 	readCmd := []byte{0xFF, 0xB0, 0x00, 0x03, 0x10} // Read 16 bytes from page 3
-	rsp, err = card.Transmit(readCmd)
+	rsp, err = transmit(card, readCmd)
+	diag.Methods = append(diag.Methods, "2b")
 
 	if err == nil && len(rsp) >= 6 && rsp[len(rsp)-2] == 0x90 {
+		if diag.CC == "" {
+			diag.CC = hex.EncodeToString(rsp[:4])
+		}
 		// Page 3 contains capability container
 		// CC byte 0 (index 0): NDEF magic (must be 0xE1 for valid NDEF)
 		// CC byte 2 (index 2): Memory size indicator
 		if len(rsp) >= 3 && rsp[0] == 0xE1 { // Validate NDEF magic byte
 			ccDetectionFoundNDEF = true
+			ccRead = true
+			applyCCAccess(cardInfo, rsp[:4])
 			ccSize := rsp[2]
 
-			// IMPORTANT: For NTAG detection, we require GET_VERSION to have succeeded.
-			// All NXP NTAG21x tags support GET_VERSION. Plain MIFARE Ultralight does NOT.
-			switch ccSize {
-			case 0x06: // 48 bytes -> MIFARE Ultralight
-				cardInfo.Type = "MIFARE Ultralight"
-				cardInfo.Size = 48
-				cardInfo.Writable = true
-				return
-			case 0x12: // 144 bytes -> NTAG213
-				// CC size 0x12 is too large for plain Ultralight (48 bytes max)
-				// so this must be NTAG213 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG213"
-				cardInfo.Size = 180
-				cardInfo.Writable = true
-				return
-			case 0x3E: // 496 bytes -> NTAG215
-				// CC size 0x3E is too large for plain Ultralight (64 bytes max)
-				// so this must be NTAG215 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG215"
-				cardInfo.Size = 504
-				cardInfo.Writable = true
-				return
-			case 0x6D: // 872 bytes -> NTAG216
-				// CC size 0x6D is too large for plain Ultralight (64 bytes max)
-				// so this must be NTAG216 regardless of GET_VERSION result
-				cardInfo.Type = "NTAG216"
-				cardInfo.Size = 888
-				cardInfo.Writable = true
+			// NTAG CC sizes (0x12, 0x3E, 0x6D) are too large for plain MIFARE Ultralight,
+			// so they identify NTAG21x regardless of the GET_VERSION result.
+			if match, ok := cardTypeTable().MatchCC(ccSize); ok {
+				applyCardType(cardInfo, match)
+				setDetection(cardInfo, "CC-2b", confidenceMedium)
 				return
 			}
 		}
@@ -387,87 +588,89 @@ func detectCardType(card *scard.Card, cardInfo *Card) {
 	// causing them to be misidentified as Type 2 tags. This probe tries to authenticate
 	// to sector 0 - Classic cards require this, NTAG/Ultralight don't support it.
 	// Only try this if we haven't identified the card yet and ATR suggests ISO 14443-A.
-	if contains(atr, "03060300") {
+	if atrStandard(atr) == atrStandardISO14443A {
+		diag.Methods = append(diag.Methods, "2c")
+
 		// Load default transport key (FFFFFFFFFFFF) into reader's key slot
 		loadKeyCmd := []byte{0xFF, 0x82, 0x00, 0x00, 0x06, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
-		rsp, err = card.Transmit(loadKeyCmd)
+		rsp, err = transmit(card, loadKeyCmd)
 		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 			// Try to authenticate to block 0 with Key A (0x60)
 			// If this succeeds, it's definitely MIFARE Classic
 			authCmd := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, 0x00, 0x60, 0x00}
-			rsp, err = card.Transmit(authCmd)
+			rsp, err = transmit(card, authCmd)
 			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
-				logging.Debug(logging.CatCard, "MIFARE Classic detected via authentication probe", nil)
+				logCard(card, logging.LevelDebug, logging.CatCard, "MIFARE Classic detected via authentication probe", nil)
 				cardInfo.Type = "MIFARE Classic"
 				cardInfo.Writable = true
 				cardInfo.Size = 1024
+				setDetection(cardInfo, "MIFARE-auth-probe", confidenceHigh)
 				return
 			}
 		}
 	}
 
 	// Method 3: Check ATR patterns for NTAG, MIFARE, and ISO 15693
-	// Note: atr is already set at the start of detectCardType for protocol detection
-	if len(atr) >= 30 && (atr[0:4] == "3b8f" || atr[0:4] == "3b8b") {
-		// Check for ISO 15693 cards (ICode SLI, ICode Slix, ICode Slix 2)
-		// Pattern: 03 06 0b at bytes 10-12 (hex string position 20-25)
-		if contains(atr, "03060b") {
-			// ISO 15693 family - check UID manufacturer byte to identify vendor
-			// For ISO 15693, the UID is 8 bytes with manufacturer code in last byte (reversed order)
-			// 0xE0 = NXP (ICode SLI/Slix/Slix-2)
-			uid := cardInfo.UID
-			if len(uid) >= 16 && uid[14:16] == "e0" {
-				// NXP ICode family (SLI, Slix, Slix2)
-				cardInfo.Type = "ICode SLIX"
-				cardInfo.Writable = true
-				cardInfo.Size = 896 // ICode SLIX2 has 2560 bits = 320 bytes, SLIX has 896 bytes
-				return
-			}
-			// Generic ISO 15693
-			cardInfo.Type = "ISO 15693"
-			cardInfo.Writable = true
-			cardInfo.Size = 1024
+	// Note: parsedATR is set at the start of detectCardType for protocol detection
+	if parsedATR != nil && parsedATR.Contactless() {
+		diag.Methods = append(diag.Methods, "3")
+
+		// ATR patterns distinguish ISO 15693 (03060b) from ISO 14443-3A (03060300);
+		// for the latter, byte 14 separates MIFARE Classic (01) from Type 2 tags (03).
+		// A Type 2 tag that neither GET_VERSION nor CC detection recognised is most
+		// likely plain MIFARE Ultralight without NDEF formatting.
+		identified := ccDetectionFoundNDEF || getVersionSucceeded
+		if match, ok := cardTypeTable().MatchATR(atr, cardInfo.UID, cardInfo.SAK, identified); ok {
+			applyCardType(cardInfo, match)
+			setDetection(cardInfo, "ATR", confidenceLow)
 			return
 		}
 
-		// Both NTAG, MIFARE Ultralight, and MIFARE Classic can have ATRs starting with
-		// 3b8f and containing 03060300. The key difference is at byte 14 (hex position 28-29):
-		// - MIFARE Classic: 01
-		// - NTAG / MIFARE Ultralight: 03 (can't distinguish by ATR alone)
-
-		if contains(atr, "03060300") {
-			// Check byte 14 to distinguish MIFARE Classic from other types
-			if atr[28:30] == "01" {
-				// MIFARE Classic
-				cardInfo.Type = "MIFARE Classic"
-				cardInfo.Writable = true
-				cardInfo.Size = 1024
-				return
-			} else if atr[28:30] == "03" {
-				// ISO 14443-3A Type 2 tag (could be NTAG or MIFARE Ultralight)
-				// If we found valid NDEF CC earlier, CC detection should have identified it.
-				// If we reach here with valid NDEF, it means CC size didn't match known types.
-				// If CC detection failed (no valid NDEF), this is likely plain MIFARE Ultralight
-				// without NDEF formatting, or with non-standard CC.
-				if !ccDetectionFoundNDEF && !getVersionSucceeded {
-					cardInfo.Type = "MIFARE Ultralight"
-					cardInfo.Size = 64 // Plain Ultralight has 64 bytes (48 user bytes)
-					cardInfo.Writable = true
-					return
-				}
-				// Otherwise report unknown - we have NDEF but unknown CC size
-			}
-		}
-
-		// Fallback: if ATR starts with 3b8f/3b8b but doesn't match above patterns
+		// Fallback: a contactless ATR that doesn't match the patterns above
 		// Could be older MIFARE or unknown card type
-		cardInfo.Type = "Unknown ISO 14443/15693 tag"
+		cardInfo.Type = cardTypeUnknownContactless
 		cardInfo.Writable = true
+		setDetection(cardInfo, "ATR", confidenceLow)
 		return
 	}
 
 	// Default fallback
-	cardInfo.Type = "NFC Tag (type unknown)"
+	cardInfo.Type = cardTypeUnknown
+	cardInfo.Writable = true
+	setDetection(cardInfo, "none", confidenceLow)
+}
+
+// setDetection records which detection step set the card type.
+func setDetection(cardInfo *Card, method, confidence string) {
+	cardInfo.DetectionMethod = method
+	cardInfo.Confidence = confidence
+}
+
+var (
+	cardTypesOnce sync.Once
+	cardTypes     *data.CardTypesData
+)
+
+// cardTypeTable returns the embedded card type detection table, loaded once.
+// See internal/data/card_types.json to add new tag variants.
+func cardTypeTable() *data.CardTypesData {
+	cardTypesOnce.Do(func() {
+		table, err := data.GetCardTypes()
+		if err != nil {
+			logging.Error(logging.CatCard, "Failed to load card type table", map[string]any{
+				"error": err.Error(),
+			})
+			table = &data.CardTypesData{}
+		}
+		cardTypes = table
+	})
+	return cardTypes
+}
+
+// applyCardType sets the detected type and size on cardInfo
+func applyCardType(cardInfo *Card, match data.CardTypeMatch) {
+	cardInfo.Type = match.Type
+	cardInfo.Size = match.Size
 	cardInfo.Writable = true
 }
 
@@ -494,17 +697,56 @@ func WriteData(readerName string, data []byte, dataType string) error {
 // WriteDataWithURL writes data to an NTAG card with an optional URL as the first record.
 // If url is non-empty, it creates a multi-record NDEF message with URL first, then data.
 func WriteDataWithURL(readerName string, data []byte, dataType string, url string) error {
-	ctx, err := scard.EstablishContext()
+	return WriteDataWithOptions(readerName, data, dataType, url, WriteOptions{})
+}
+
+// WriteOptions controls the safety checks applied before a write.
+type WriteOptions struct {
+	Force bool // Overwrite even if the tag holds a write-protected OpenPrintTag
+
+	ControlTLVs []TLV // Lock/memory control or proprietary TLVs to write before the NDEF TLV (Type 2 tags only)
+
+	ExpectUID string // Abort with ErrUIDMismatch unless the card has this UID (hex); empty skips the check
+
+	Verify bool // Read the NDEF message back after writing; a difference fails with ErrVerifyMismatch (multi-record writes only)
+
+	ZeroMemory bool // Erase only: zero all user memory after the empty NDEF message, not just the first pages
+
+	LongRecords bool // Encode every record in the long-record format, even payloads under 256 bytes (interop testing)
+
+	Trace *APDUTrace // Record this write's APDU exchanges and log entries; nil disables tracing
+}
+
+// ErrWriteProtected is returned when a write would overwrite an OpenPrintTag
+// whose main section is write-protected.
+var ErrWriteProtected = errors.New("OpenPrintTag main section is write-protected")
+
+// WriteDataWithOptions is like WriteDataWithURL but applies write options.
+func WriteDataWithOptions(readerName string, data []byte, dataType string, url string, opts WriteOptions) (err error) {
+	defer trackOperation("write_card", readerName, &err)()
+
+	if dataType == "cbor" {
+		if data, err = encodeGenericCBOR(data); err != nil {
+			return err
+		}
+	}
+
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
+	defer opts.Trace.attach(card)()
+
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
 
 	// Get card status to obtain ATR for type detection
 	status, err := card.Status()
@@ -517,6 +759,11 @@ func WriteDataWithURL(readerName string, data []byte, dataType string, url strin
 		ATR: hex.EncodeToString(status.Atr),
 	}
 	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	if err := checkWriteProtection(card, cardInfo, opts.Force); err != nil {
+		return err
+	}
 
 	var ndefMessage []byte
 
@@ -535,6 +782,8 @@ func WriteDataWithURL(readerName string, data []byte, dataType string, url strin
 			ndefMessage = createNDEFTextRecord(string(data))
 		case "binary":
 			ndefMessage = createNDEFMimeRecord("application/octet-stream", data)
+		case "cbor":
+			ndefMessage = createNDEFMimeRecord(CBORMIMEType, data)
 		case "url":
 			ndefMessage = createNDEFURIRecord(string(data))
 		case "openprinttag":
@@ -549,17 +798,39 @@ func WriteDataWithURL(readerName string, data []byte, dataType string, url strin
 			}
 			ndefMessage = createNDEFMimeRecord(openprinttag.MIMEType, cborPayload)
 		default:
-			return fmt.Errorf("unsupported data type: %s (use 'json', 'text', 'binary', 'url', or 'openprinttag')", dataType)
+			return fmt.Errorf("unsupported data type: %s (use 'json', 'text', 'binary', 'url', 'cbor', or 'openprinttag')", dataType)
+		}
+	}
+
+	if opts.LongRecords {
+		if ndefMessage, err = withLongRecords(ndefMessage); err != nil {
+			return err
 		}
 	}
 
+	ndefMessage, err = withControlTLVs(cardInfo, ndefMessage, opts.ControlTLVs)
+	if err != nil {
+		return err
+	}
+
 	// Write NDEF message based on card type
 	if cardInfo.Type == "MIFARE Classic" {
 		if err := writeMifareClassic(card, ndefMessage); err != nil {
 			return fmt.Errorf("failed to write NDEF message: %w", err)
 		}
+	} else if cardInfo.Type == cardTypeType4 {
+		if err := writeType4NDEFTLV(card, ndefMessage); err != nil {
+			return fmt.Errorf("failed to write NDEF message: %w", err)
+		}
 	} else {
 		// NTAG and other cards use page-based writes
+		if err := checkNDEFWritable(cardInfo); err != nil {
+			return err
+		}
+		ndefMessage = omitTerminatorIfFull(cardInfo, 4, ndefMessage)
+		if err := checkUserMemory(cardInfo, 4, len(ndefMessage)); err != nil {
+			return err
+		}
 		if err := writeNTAGPages(card, 4, ndefMessage); err != nil {
 			return fmt.Errorf("failed to write NDEF message: %w", err)
 		}
@@ -588,6 +859,8 @@ func createMultiRecordNDEF(url string, data []byte, dataType string) []byte {
 		dataRecord = createNDEFRecordRaw(0x01, []byte("T"), textPayload, false, true)
 	case "binary":
 		dataRecord = createNDEFRecordRaw(0x02, []byte("application/octet-stream"), data, false, true)
+	case "cbor":
+		dataRecord = createNDEFRecordRaw(0x02, []byte(CBORMIMEType), data, false, true)
 	case "openprinttag":
 		// Data is already CBOR-encoded at this point
 		dataRecord = createNDEFRecordRaw(0x02, []byte(openprinttag.MIMEType), data, false, true)
@@ -618,6 +891,12 @@ func createMultiRecordNDEF(url string, data []byte, dataType string) []byte {
 
 // createNDEFRecordRaw creates a raw NDEF record without TLV wrapping
 func createNDEFRecordRaw(tnf byte, recordType []byte, payload []byte, mb bool, me bool) []byte {
+	return createNDEFRecordWithID(tnf, recordType, nil, payload, mb, me)
+}
+
+// createNDEFRecordWithID is like createNDEFRecordRaw but sets a record ID (IL
+// flag), e.g. for records referenced from a connection handover.
+func createNDEFRecordWithID(tnf byte, recordType, id, payload []byte, mb, me bool) []byte {
 	header := tnf & 0x07
 	if mb {
 		header |= 0x80
@@ -628,6 +907,9 @@ func createNDEFRecordRaw(tnf byte, recordType []byte, payload []byte, mb bool, m
 	if len(payload) < 256 {
 		header |= 0x10
 	}
+	if len(id) > 0 {
+		header |= 0x08
+	}
 
 	record := []byte{header}
 	record = append(record, byte(len(recordType)))
@@ -640,8 +922,12 @@ func createNDEFRecordRaw(tnf byte, recordType []byte, payload []byte, mb bool, m
 		record = append(record, byte(len(payload)>>8))
 		record = append(record, byte(len(payload)))
 	}
+	if len(id) > 0 {
+		record = append(record, byte(len(id)))
+	}
 
 	record = append(record, recordType...)
+	record = append(record, id...)
 	record = append(record, payload...)
 
 	return record
@@ -742,6 +1028,12 @@ func createNDEFRecord(tnf byte, recordType []byte, payload []byte, mb bool, me b
 // writeMifareClassic writes NDEF data to a MIFARE Classic card
 // Tries multiple common keys for authentication
 func writeMifareClassic(card *scard.Card, data []byte) error {
+	return writeMifareClassicBlocks(card, 0, data)
+}
+
+// writeMifareClassicBlocks is like writeMifareClassic but starts at the
+// first-th data block (counting from block 4, skipping sector trailers).
+func writeMifareClassicBlocks(card *scard.Card, first int, data []byte) error {
 	// Common keys to try:
 	// 1. Default transport key
 	// 2. NFC Forum default key
@@ -765,6 +1057,11 @@ func writeMifareClassic(card *scard.Card, data []byte) error {
 	}
 
 	blockNum := 4 // Start at sector 1, block 0 (absolute block 4)
+	for skipped := 0; skipped < first; blockNum++ {
+		if (blockNum+1)%4 != 0 {
+			skipped++
+		}
+	}
 	dataOffset := 0
 	lastAuthSector := -1
 	currentKeyIndex := -1
@@ -789,14 +1086,14 @@ func writeMifareClassic(card *scard.Card, data []byte) error {
 				loadKeyCmd := []byte{0xFF, 0x82, 0x00, 0x00, 0x06}
 				loadKeyCmd = append(loadKeyCmd, key...)
 
-				rsp, err := card.Transmit(loadKeyCmd)
+				rsp, err := transmit(card, loadKeyCmd)
 				if err != nil || len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
 					continue
 				}
 
 				// Try Key A authentication
 				authCmd := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), 0x60, 0x00}
-				rsp, err = card.Transmit(authCmd)
+				rsp, err = transmit(card, authCmd)
 				if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 					authenticated = true
 					currentKeyIndex = keyIdx
@@ -805,7 +1102,7 @@ func writeMifareClassic(card *scard.Card, data []byte) error {
 
 				// Try Key B authentication
 				authCmd = []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), 0x61, 0x00}
-				rsp, err = card.Transmit(authCmd)
+				rsp, err = transmit(card, authCmd)
 				if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 					authenticated = true
 					currentKeyIndex = keyIdx
@@ -824,7 +1121,7 @@ func writeMifareClassic(card *scard.Card, data []byte) error {
 		writeCmd := []byte{0xFF, 0xD6, 0x00, byte(blockNum), 0x10}
 		writeCmd = append(writeCmd, blockData...)
 
-		rsp, err := card.Transmit(writeCmd)
+		rsp, err := transmit(card, writeCmd)
 		if err != nil {
 			return fmt.Errorf("failed to write block %d: %w", blockNum, err)
 		}
@@ -839,8 +1136,77 @@ func writeMifareClassic(card *scard.Card, data []byte) error {
 	return nil
 }
 
+// ErrNDEFReadOnly is returned when writing NDEF data to a Type 2 tag whose
+// capability container marks the NDEF area read-only.
+var ErrNDEFReadOnly = errors.New("tag NDEF area is read-only (capability container write access 0x0F)")
+
+// ccReadOnly reports whether a Type 2 capability container (page 3) marks the
+// NDEF area read-only: its last byte holds the read access in the high nibble
+// and the write access in the low nibble, 0x0 granted and 0xF none.
+func ccReadOnly(cc []byte) bool {
+	return len(cc) >= 4 && cc[0] == 0xE1 && cc[3]&0x0F == 0x0F
+}
+
+// applyCCAccess records a read-only capability container on the card.
+func applyCCAccess(cardInfo *Card, cc []byte) {
+	if ccReadOnly(cc) {
+		cardInfo.NDEFReadOnly = true
+	}
+}
+
+// checkNDEFWritable returns ErrNDEFReadOnly if the card's capability
+// container marks its NDEF area read-only.
+func checkNDEFWritable(cardInfo *Card) error {
+	if cardInfo.NDEFReadOnly {
+		return ErrNDEFReadOnly
+	}
+	return nil
+}
+
+// ErrExceedsUserMemory is returned when a write would run past the tag's user
+// memory into its lock, configuration or password pages.
+var ErrExceedsUserMemory = errors.New("data exceeds tag user memory")
+
+// ntagUserPageRange returns the first and last user-memory pages of an NTAG or
+// MIFARE Ultralight card. The pages after the last user page hold the dynamic
+// lock bytes, configuration, PWD and PACK. ok is false for other card types.
+func ntagUserPageRange(cardInfo *Card) (first, last int, ok bool) {
+	switch cardInfo.Type {
+	case "NTAG213":
+		return 4, 39, true
+	case "NTAG215":
+		return 4, 129, true
+	case "NTAG216":
+		return 4, 225, true
+	case "MIFARE Ultralight":
+		return 4, 15, true
+	case "MIFARE Ultralight EV1":
+		if cardInfo.Size == 128 { // MF0UL21
+			return 4, 35, true
+		}
+		return 4, 15, true // MF0UL11
+	}
+	return 0, 0, false
+}
+
+// checkUserMemory returns ErrExceedsUserMemory if writing dataLen bytes from
+// startPage would reach past the card's user memory. Cards of unknown type
+// are not checked.
+func checkUserMemory(cardInfo *Card, startPage, dataLen int) error {
+	_, last, ok := ntagUserPageRange(cardInfo)
+	if !ok {
+		return nil
+	}
+	endPage := startPage + (dataLen+3)/4 - 1
+	if endPage > last {
+		return fmt.Errorf("%w: %d bytes would reach page %d, but %s user memory ends at page %d",
+			ErrExceedsUserMemory, dataLen, endPage, cardInfo.Type, last)
+	}
+	return nil
+}
+
 // writeNTAGPages writes data to NTAG card pages (4 bytes per page)
-func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
+func writeNTAGPages(card cardTransmitter, startPage int, data []byte) error {
 	// Pad data to multiple of 4 bytes
 	for len(data)%4 != 0 {
 		data = append(data, 0x00)
@@ -850,16 +1216,17 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 	for i := 0; i < len(data); i += 4 {
 		pageNum := startPage + (i / 4)
 		pageData := data[i : i+4]
+		waitBeforePageWrite(i)
 
 		// Method 0: Raw NTAG WRITE command - some readers pass through raw NFC commands
 		// Format: A2 [page] [4 bytes data]
 		rawCmd := []byte{0xA2, byte(pageNum)}
 		rawCmd = append(rawCmd, pageData...)
-		rsp, err := card.Transmit(rawCmd)
+		rsp, err := transmit(card, rawCmd)
 		if err == nil && len(rsp) >= 1 {
 			// NTAG write returns ACK (0x0A) on success
 			if rsp[0] == 0x0A || (len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00) {
-				logging.Debug(logging.CatCard, "NDEF page written", map[string]any{
+				logCard(card, logging.LevelDebug, logging.CatCard, "NDEF page written", map[string]any{
 					"page":   pageNum,
 					"data":   hex.EncodeToString(pageData),
 					"method": 0,
@@ -872,15 +1239,15 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 		// APDU: FF D6 00 [page] 04 [4 bytes]
 		writeCmd := []byte{0xFF, 0xD6, 0x00, byte(pageNum), 0x04}
 		writeCmd = append(writeCmd, pageData...)
-		rsp, err = card.Transmit(writeCmd)
-		logging.Debug(logging.CatCard, "NDEF write method 1", map[string]any{
+		rsp, err = transmit(card, writeCmd)
+		logCard(card, logging.LevelDebug, logging.CatCard, "NDEF write method 1", map[string]any{
 			"page":     pageNum,
 			"cmd":      hex.EncodeToString(writeCmd),
 			"response": hex.EncodeToString(rsp),
 			"err":      fmt.Sprintf("%v", err),
 		})
 		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
-			logging.Debug(logging.CatCard, "NDEF page written", map[string]any{
+			logCard(card, logging.LevelDebug, logging.CatCard, "NDEF page written", map[string]any{
 				"page":   pageNum,
 				"data":   hex.EncodeToString(pageData),
 				"method": 1,
@@ -892,8 +1259,8 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 		// Format: FF 00 00 00 08 D4 42 A2 [page] [4 bytes data]
 		directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x08, 0xD4, 0x42, 0xA2, byte(pageNum)}
 		directCmd = append(directCmd, pageData...)
-		rsp, err = card.Transmit(directCmd)
-		logging.Debug(logging.CatCard, "NDEF write method 2", map[string]any{
+		rsp, err = transmit(card, directCmd)
+		logCard(card, logging.LevelDebug, logging.CatCard, "NDEF write method 2", map[string]any{
 			"page":     pageNum,
 			"cmd":      hex.EncodeToString(directCmd),
 			"response": hex.EncodeToString(rsp),
@@ -908,7 +1275,7 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 						return fmt.Errorf("write failed at page %d: card error %02X", pageNum, rsp[2])
 					}
 				}
-				logging.Debug(logging.CatCard, "NDEF page written", map[string]any{
+				logCard(card, logging.LevelDebug, logging.CatCard, "NDEF page written", map[string]any{
 					"page":   pageNum,
 					"data":   hex.EncodeToString(pageData),
 					"method": 2,
@@ -924,18 +1291,18 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 		endSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
 
 		// End any stale session first (ignore result)
-		card.Transmit(endSession)
+		transmit(card, endSession)
 
-		rsp, err = card.Transmit(startSession)
-		logging.Debug(logging.CatCard, "NDEF write method 3 - start session", map[string]any{
+		rsp, err = transmit(card, startSession)
+		logCard(card, logging.LevelDebug, logging.CatCard, "NDEF write method 3 - start session", map[string]any{
 			"page":     pageNum,
 			"response": hex.EncodeToString(rsp),
 			"err":      fmt.Sprintf("%v", err),
 		})
 		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 			// Set protocol to ISO 14443-A Layer 3
-			rsp, err = card.Transmit(setProtocol)
-			logging.Debug(logging.CatCard, "NDEF write method 3 - set protocol", map[string]any{
+			rsp, err = transmit(card, setProtocol)
+			logCard(card, logging.LevelDebug, logging.CatCard, "NDEF write method 3 - set protocol", map[string]any{
 				"page":     pageNum,
 				"response": hex.EncodeToString(rsp),
 				"err":      fmt.Sprintf("%v", err),
@@ -948,18 +1315,18 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 				transparentCmd := []byte{0xFF, 0xC2, 0x00, 0x01, byte(len(writeData) + 2), 0x95, byte(len(writeData))}
 				transparentCmd = append(transparentCmd, writeData...)
 
-				rsp, err = card.Transmit(transparentCmd)
-				logging.Debug(logging.CatCard, "NDEF write method 3 - write cmd", map[string]any{
+				rsp, err = transmit(card, transparentCmd)
+				logCard(card, logging.LevelDebug, logging.CatCard, "NDEF write method 3 - write cmd", map[string]any{
 					"page":     pageNum,
 					"cmd":      hex.EncodeToString(transparentCmd),
 					"response": hex.EncodeToString(rsp),
 					"err":      fmt.Sprintf("%v", err),
 				})
-				card.Transmit(endSession) // Always end session
+				transmit(card, endSession) // Always end session
 
 				// Check for success - response contains status in TLV format
 				if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
-					logging.Debug(logging.CatCard, "NDEF page written", map[string]any{
+					logCard(card, logging.LevelDebug, logging.CatCard, "NDEF page written", map[string]any{
 						"page":   pageNum,
 						"data":   hex.EncodeToString(pageData),
 						"method": 3,
@@ -967,7 +1334,7 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 					continue // Success, next page
 				}
 			} else {
-				card.Transmit(endSession)
+				transmit(card, endSession)
 			}
 		}
 
@@ -980,7 +1347,7 @@ func writeNTAGPages(card *scard.Card, startPage int, data []byte) error {
 // WaitForCard waits for a card to be present on the specified reader.
 // This is a blocking call that returns when a card is detected.
 func WaitForCard(readerName string) error {
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
@@ -1010,7 +1377,7 @@ func WaitForCard(readerName string) error {
 
 // readMifareClassicBlock reads a 16-byte block from a MIFARE Classic card
 // Handles authentication with multiple common keys
-func readMifareClassicBlock(card *scard.Card, blockNum int, lastAuthSector *int) ([]byte, error) {
+func readMifareClassicBlock(card cardTransmitter, blockNum int, lastAuthSector *int) ([]byte, error) {
 	keys := [][]byte{
 		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, // Default transport
 		{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7}, // NFC Forum default
@@ -1018,25 +1385,30 @@ func readMifareClassicBlock(card *scard.Card, blockNum int, lastAuthSector *int)
 		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // Zero key
 	}
 
+	// 4-block sectors up to block 127, 16-block sectors above (4K layout)
 	sector := blockNum / 4
+	authBlock := sector*4 + 3
+	if blockNum >= 128 {
+		sector = 32 + (blockNum-128)/16
+		authBlock = 128 + (sector-32)*16 + 15
+	}
 
 	// Authenticate if we're in a new sector
 	if *lastAuthSector != sector {
-		authBlock := sector*4 + 3
 		authenticated := false
 
 		for _, key := range keys {
 			// Load key
 			loadKeyCmd := []byte{0xFF, 0x82, 0x00, 0x00, 0x06}
 			loadKeyCmd = append(loadKeyCmd, key...)
-			rsp, err := card.Transmit(loadKeyCmd)
+			rsp, err := transmit(card, loadKeyCmd)
 			if err != nil || len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
 				continue
 			}
 
 			// Try Key A
 			authCmd := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), 0x60, 0x00}
-			rsp, err = card.Transmit(authCmd)
+			rsp, err = transmit(card, authCmd)
 			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 				authenticated = true
 				break
@@ -1044,7 +1416,7 @@ func readMifareClassicBlock(card *scard.Card, blockNum int, lastAuthSector *int)
 
 			// Try Key B
 			authCmd = []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), 0x61, 0x00}
-			rsp, err = card.Transmit(authCmd)
+			rsp, err = transmit(card, authCmd)
 			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 				authenticated = true
 				break
@@ -1059,7 +1431,7 @@ func readMifareClassicBlock(card *scard.Card, blockNum int, lastAuthSector *int)
 
 	// Read block: FF B0 00 [block] 10
 	readCmd := []byte{0xFF, 0xB0, 0x00, byte(blockNum), 0x10}
-	rsp, err := card.Transmit(readCmd)
+	rsp, err := transmit(card, readCmd)
 	if err != nil {
 		return nil, err
 	}
@@ -1072,10 +1444,10 @@ func readMifareClassicBlock(card *scard.Card, blockNum int, lastAuthSector *int)
 
 // readNTAGPage reads a single 4-byte page from an NTAG card
 // Uses fallback to ACR122U direct transmit if standard command fails
-func readNTAGPage(card *scard.Card, pageNum int) ([]byte, error) {
+func readNTAGPage(card cardTransmitter, pageNum int) ([]byte, error) {
 	// Method 1: Standard READ BINARY command
 	readCmd := []byte{0xFF, 0xB0, 0x00, byte(pageNum), 0x04}
-	rsp, err := card.Transmit(readCmd)
+	rsp, err := transmit(card, readCmd)
 
 	if err == nil && len(rsp) >= 6 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
 		return rsp[:len(rsp)-2], nil
@@ -1085,7 +1457,7 @@ func readNTAGPage(card *scard.Card, pageNum int) ([]byte, error) {
 	// Format: FF 00 00 00 [len] D4 42 30 [page]
 	// NTAG READ returns 16 bytes (4 pages starting from pageNum)
 	directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x42, 0x30, byte(pageNum)}
-	rsp, err = card.Transmit(directCmd)
+	rsp, err = transmit(card, directCmd)
 
 	if err != nil {
 		return nil, fmt.Errorf("read failed: %w", err)
@@ -1102,191 +1474,268 @@ func readNTAGPage(card *scard.Card, pageNum int) ([]byte, error) {
 	return nil, fmt.Errorf("read failed with status: %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
 }
 
-// readNDEFData attempts to read NDEF data from a card
-func readNDEFData(card *scard.Card, cardInfo *Card) {
-	logging.Debug(logging.CatCard, "Reading NDEF data", map[string]any{
+// readNDEFData attempts to read NDEF data from a card.
+// opts.MaxPages overrides MaxNDEFReadPages for this read (0 keeps the global
+// budget); opts.MifareBlocks overrides the MIFARE Classic layout. With
+// opts.ReadAll reading continues to the terminator TLV, and NDEF messages
+// after the first go to ExtraNDEFMessages.
+func readNDEFData(card *scard.Card, cardInfo *Card, opts ReadOptions) {
+	logCard(card, logging.LevelDebug, logging.CatCard, "Reading NDEF data", map[string]any{
 		"cardType": cardInfo.Type,
 	})
 
+	if cardInfo.Type == cardTypeType4 {
+		// Type 4 tags hold the NDEF message in a file rather than a TLV area
+		cc, err := selectType4NDEF(card)
+		var message []byte
+		if err == nil {
+			message, err = readType4NDEF(card, cc)
+		}
+		if err != nil {
+			logCard(card, logging.LevelDebug, logging.CatCard, "NDEF read failed", map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		if opts.Debug {
+			cardInfo.Debug = &NDEFDebug{TLVOffset: -1, NDEFLength: len(message), RawHex: hex.EncodeToString(message)}
+		}
+		if len(message) > 0 {
+			cardInfo.RawNDEF = message
+			cardInfo.ContentHash = ndefContentHash(message)
+			parseNDEFRecords(message, cardInfo)
+		}
+		return
+	}
+
+	maxPages := opts.MaxPages
 	var allData []byte
+	pagesRead := 0
+	readComplete := ndefReadComplete
+	if opts.ReadAll {
+		readComplete = allNDEFReadComplete
+	}
 
 	if cardInfo.Type == "MIFARE Classic" {
 		// MIFARE Classic: read blocks starting from sector 1 (block 4)
+		blocks := mifareClassicBlocks(cardInfo, opts.MifareBlocks)
+		budget := ndefReadBudget(mifareDataBlocks(blocks), maxPages)
 		lastAuthSector := -1
-		for blockNum := 4; blockNum < 64; blockNum++ { // MIFARE 1K has 64 blocks
-			// Skip sector trailers
-			if (blockNum+1)%4 == 0 {
+		for blockNum := 4; blockNum < blocks && pagesRead < budget; blockNum++ {
+			if isSectorTrailer(blockNum) {
 				continue
 			}
 
 			blockData, err := readMifareClassicBlock(card, blockNum, &lastAuthSector)
 			if err != nil {
-				logging.Debug(logging.CatCard, "NDEF read failed", map[string]any{
+				logCard(card, logging.LevelDebug, logging.CatCard, "NDEF read failed", map[string]any{
 					"block": blockNum,
 					"error": err.Error(),
 				})
 				break
 			}
+			pagesRead++
 
 			allData = append(allData, blockData...)
-
-			// Check for NDEF terminator
-			for _, b := range blockData {
-				if b == 0xFE {
-					goto done
-				}
-			}
-
-			// Check if we have complete NDEF message
-			if len(allData) > 2 && allData[0] == 0x03 {
-				var ndefLength, ndefStart int
-				if allData[1] == 0xFF && len(allData) >= 4 {
-					ndefLength = int(allData[2])<<8 | int(allData[3])
-					ndefStart = 4
-				} else if allData[1] != 0xFF {
-					ndefLength = int(allData[1])
-					ndefStart = 2
-				}
-				if ndefStart > 0 && len(allData) >= ndefStart+ndefLength+1 {
-					break
-				}
+			if readComplete(allData) {
+				break
 			}
 		}
 	} else if cardInfo.Type == "ICode SLIX" {
-		// ISO 15693 (Type 5) tags: NDEF starts at block 1 (after CC at block 0)
-		maxBlocks := 79 // 80 blocks total, skip CC at block 0
-		for blockNum := 1; blockNum < 1+maxBlocks; blockNum++ {
-			blockData, err := readNTAGPage(card, blockNum)
-			if err != nil {
-				logging.Debug(logging.CatCard, "NDEF read failed", map[string]any{
-					"block": blockNum,
-					"error": err.Error(),
-				})
-				break
-			}
-
-			allData = append(allData, blockData...)
-
-			// Check for NDEF terminator
-			for _, b := range blockData {
-				if b == 0xFE {
-					goto done
+		// ISO 15693 (Type 5) tags: read by the tag's own block size and count
+		// when the reader can tell us; otherwise assume 4-byte blocks with
+		// NDEF starting at block 1 (after CC at block 0)
+		var ok bool
+		allData, pagesRead, ok = readISO15693NDEFArea(card, maxPages, readComplete)
+		if !ok {
+			budget := ndefReadBudget(79, maxPages) // 80 blocks total, skip CC at block 0
+			for blockNum := 1; pagesRead < budget; blockNum++ {
+				blockData, err := readNTAGPage(card, blockNum)
+				if err != nil {
+					logCard(card, logging.LevelDebug, logging.CatCard, "NDEF read failed", map[string]any{
+						"block": blockNum,
+						"error": err.Error(),
+					})
+					break
 				}
-			}
+				pagesRead++
 
-			// Check if we have complete NDEF message
-			if len(allData) > 2 && allData[0] == 0x03 {
-				var ndefLength, ndefStart int
-				if allData[1] == 0xFF && len(allData) >= 4 {
-					ndefLength = int(allData[2])<<8 | int(allData[3])
-					ndefStart = 4
-				} else if allData[1] != 0xFF {
-					ndefLength = int(allData[1])
-					ndefStart = 2
-				}
-				if ndefStart > 0 && len(allData) >= ndefStart+ndefLength+1 {
+				allData = append(allData, blockData...)
+				if readComplete(allData) {
 					break
 				}
 			}
 		}
 	} else {
-		// NTAG and other cards: read pages starting from page 4
-		maxPages := 40
-		if cardInfo.Type == "NTAG215" {
-			maxPages = 126
-		} else if cardInfo.Type == "NTAG216" {
-			maxPages = 222
+		// NTAG and other cards: read pages starting from page 4, stopping
+		// before the lock/config pages when the card type is known
+		defaultPages := 40
+		if first, last, ok := ntagUserPageRange(cardInfo); ok {
+			defaultPages = last - first + 1
 		}
+		budget := ndefReadBudget(defaultPages, maxPages)
 
-		for pageNum := 4; pageNum < 4+maxPages; pageNum++ {
+		for pageNum := 4; pagesRead < budget; pageNum++ {
 			pageData, err := readNTAGPage(card, pageNum)
 			if err != nil {
-				logging.Debug(logging.CatCard, "NDEF read failed", map[string]any{
+				if retried, ok := readWithConfiguredPassword(card, pageNum, readNTAGPage); ok {
+					pageData, err = retried, nil
+				}
+			}
+			if err != nil {
+				logCard(card, logging.LevelDebug, logging.CatCard, "NDEF read failed", map[string]any{
 					"page":  pageNum,
 					"error": err.Error(),
 				})
+				if auth0, protected := passwordProtection(card, cardInfo, pageNum); protected {
+					cardInfo.PasswordProtected = true
+					cardInfo.ProtectedFromPage = auth0
+				}
 				break
 			}
+			pagesRead++
 
 			allData = append(allData, pageData...)
-
-			// Check for NDEF terminator
-			for _, b := range pageData {
-				if b == 0xFE {
-					goto done
-				}
-			}
-
-			// Check if we have complete NDEF message
-			if len(allData) > 2 && allData[0] == 0x03 {
-				var ndefLength, ndefStart int
-				if allData[1] == 0xFF && len(allData) >= 4 {
-					ndefLength = int(allData[2])<<8 | int(allData[3])
-					ndefStart = 4
-				} else if allData[1] != 0xFF {
-					ndefLength = int(allData[1])
-					ndefStart = 2
-				}
-				if ndefStart > 0 && len(allData) >= ndefStart+ndefLength+1 {
-					break
-				}
+			if readComplete(allData) {
+				break
 			}
 		}
 	}
-done:
+	cardInfo.PagesRead = pagesRead
+	if opts.Debug {
+		cardInfo.Debug = &NDEFDebug{TLVOffset: -1, RawHex: hex.EncodeToString(allData), PagesRead: pagesRead}
+	}
 
-	logging.Debug(logging.CatCard, "NDEF data read complete", map[string]any{
+	logCard(card, logging.LevelDebug, logging.CatCard, "NDEF data read complete", map[string]any{
 		"totalBytes": len(allData),
+		"pagesRead":  pagesRead,
 	})
 
 	if len(allData) < 3 {
-		logging.Debug(logging.CatCard, "Not enough NDEF data", map[string]any{
+		logCard(card, logging.LevelDebug, logging.CatCard, "Not enough NDEF data", map[string]any{
 			"bytes": len(allData),
 		})
 		return // Can't read data, leave fields empty
 	}
 
-	data := allData
+	// Locate the NDEF TLV, skipping any lock/memory control TLVs before it
+	start, length, skipped, state := locateNDEFTLV(allData)
+	for _, t := range skipped {
+		logCard(card, logging.LevelDebug, logging.CatCard, "Skipped TLV before NDEF", map[string]any{
+			"type":  fmt.Sprintf("0x%02X", t.Type),
+			"value": hex.EncodeToString(t.Value),
+		})
+	}
+	if cardInfo.Debug != nil && start > 0 {
+		// Also set for an incomplete TLV, whose declared length wasn't satisfied
+		cardInfo.Debug.TLVOffset = ndefTLVHeaderOffset(allData, start, length)
+		cardInfo.Debug.NDEFLength = length
+	}
+	if state != ndefTLVFound {
+		return // Not NDEF format, or invalid length
+	}
 
-	// Parse NDEF TLV format
-	if len(data) < 3 || data[0] != 0x03 {
-		return // Not NDEF format
+	cardInfo.RawNDEF = allData[start : start+length]
+	cardInfo.ContentHash = ndefContentHash(cardInfo.RawNDEF)
+	parseNDEFRecords(cardInfo.RawNDEF, cardInfo)
+	if opts.ReadAll {
+		parseExtraNDEFMessages(allData[start+length:], cardInfo)
 	}
+}
 
-	// Get NDEF message length
-	var ndefLength int
-	var ndefStart int
-	if data[1] == 0xFF {
-		// 3-byte length format
-		if len(data) < 5 {
+// ndefContentHash is the hex SHA-256 of an NDEF message (without TLV
+// wrapping). It covers only the NDEF content, so it doesn't change with the
+// UID, the capability container or config pages, and is the same whichever
+// tag type or reader the message was read from. An empty message (a blank
+// tag's NDEF TLV) has no hash.
+func ndefContentHash(message []byte) string {
+	if len(message) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(message)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseExtraNDEFMessages parses the NDEF TLVs that follow the first one in
+// data into ExtraNDEFMessages. Tags written by buggy tools sometimes hold
+// more than one.
+func parseExtraNDEFMessages(data []byte, cardInfo *Card) {
+	for {
+		start, length, _, state := locateNDEFTLV(data)
+		if state != ndefTLVFound {
 			return
 		}
-		ndefLength = int(data[2])<<8 | int(data[3])
-		ndefStart = 4
-	} else {
-		// 1-byte length format
-		ndefLength = int(data[1])
-		ndefStart = 2
+		var scratch Card
+		parseNDEFRecords(data[start:start+length], &scratch)
+		if scratch.NDEFMalformed {
+			markNDEFMalformed(cardInfo, fmt.Sprintf("NDEF message %d: %s", len(cardInfo.ExtraNDEFMessages)+2, scratch.NDEFMalformedReason))
+		}
+		cardInfo.MultipleNDEFMessages = true
+		cardInfo.ExtraNDEFMessages = append(cardInfo.ExtraNDEFMessages, scratch.Records)
+		data = data[start+length:]
 	}
+}
 
-	if ndefStart+ndefLength > len(data) {
-		return // Invalid length
+// ndefReadBudget returns how many pages/blocks to read for a card whose NDEF
+// area holds defaultPages. A per-request override replaces MaxNDEFReadPages;
+// neither can raise the budget above the card's own capacity.
+func ndefReadBudget(defaultPages, override int) int {
+	limit := MaxNDEFReadPages
+	if override > 0 {
+		limit = override
+	}
+	if limit > 0 && limit < defaultPages {
+		return limit
 	}
+	return defaultPages
+}
+
+// ndefReadComplete reports whether enough data has been read: either the NDEF
+// TLV's declared length is satisfied, or the data holds no NDEF TLV at all
+// (empty or non-NDEF tag), so reading further can't help.
+func ndefReadComplete(data []byte) bool {
+	_, _, _, state := locateNDEFTLV(data)
+	return state != ndefTLVIncomplete
+}
 
-	ndefMessage := data[ndefStart : ndefStart+ndefLength]
+// allNDEFReadComplete is ndefReadComplete for ReadOptions.ReadAll: enough has
+// been read once every NDEF TLV is complete and the terminator (or anything
+// else that isn't a TLV) follows the last one.
+func allNDEFReadComplete(data []byte) bool {
+	for {
+		start, length, _, state := locateNDEFTLV(data)
+		if state != ndefTLVFound {
+			return state != ndefTLVIncomplete
+		}
+		data = data[start+length:]
+	}
+}
 
-	// Parse all NDEF records
+// parseNDEFRecords parses the records of an NDEF message (without TLV wrapping)
+// and fills in the URL/Data/DataType fields of cardInfo.
+func parseNDEFRecords(ndefMessage []byte, cardInfo *Card) {
+	seenText := false
+	sawME := false
 	offset := 0
 	for offset < len(ndefMessage) {
 		if len(ndefMessage)-offset < 3 {
+			markNDEFMalformed(cardInfo, fmt.Sprintf("truncated record header at offset %d", offset))
 			break
 		}
 
 		header := ndefMessage[offset]
 		tnf := header & 0x07
 		sr := (header & 0x10) != 0
+		il := (header & 0x08) != 0 // ID Length present
+		mb := (header & 0x80) != 0 // Message Begin flag
 		me := (header & 0x40) != 0 // Message End flag
 
+		if offset == 0 && !mb {
+			markNDEFMalformed(cardInfo, "first record is missing the MB flag")
+		} else if offset > 0 && mb {
+			markNDEFMalformed(cardInfo, fmt.Sprintf("MB flag set on record at offset %d", offset))
+		}
+
 		typeLength := int(ndefMessage[offset+1])
 		var payloadLength int
 		var headerSize int
@@ -1298,72 +1747,104 @@ done:
 		} else {
 			// Long record
 			if len(ndefMessage)-offset < 6 {
+				markNDEFMalformed(cardInfo, fmt.Sprintf("truncated record header at offset %d", offset))
 				break
 			}
 			payloadLength = int(ndefMessage[offset+2])<<24 | int(ndefMessage[offset+3])<<16 | int(ndefMessage[offset+4])<<8 | int(ndefMessage[offset+5])
 			headerSize = 6
 		}
 
+		// Optional ID length byte follows the payload length
+		idLength := 0
+		if il {
+			if len(ndefMessage)-offset < headerSize+1 {
+				markNDEFMalformed(cardInfo, fmt.Sprintf("truncated record header at offset %d", offset))
+				break
+			}
+			idLength = int(ndefMessage[offset+headerSize])
+			headerSize++
+		}
+
 		recordStart := offset + headerSize
-		if recordStart+typeLength+payloadLength > len(ndefMessage) {
+		if recordStart+typeLength+idLength+payloadLength > len(ndefMessage) {
+			markNDEFMalformed(cardInfo, fmt.Sprintf("record at offset %d exceeds message length", offset))
 			break
 		}
 
 		recordType := ndefMessage[recordStart : recordStart+typeLength]
-		payload := ndefMessage[recordStart+typeLength : recordStart+typeLength+payloadLength]
+		payloadStart := recordStart + typeLength + idLength
+		payload := ndefMessage[payloadStart : payloadStart+payloadLength]
 
 		// Process this record
+		record := ParsedRecord{
+			TNF:      tnf,
+			Type:     string(recordType),
+			ID:       string(ndefMessage[recordStart+typeLength : payloadStart]),
+			Payload:  payload,
+			RawBytes: ndefMessage[offset : payloadStart+payloadLength],
+		}
 		if tnf == 0x01 && len(recordType) == 1 && recordType[0] == 'U' {
 			// URI record - store in URL field
 			if len(payload) >= 1 {
 				uriPrefix := getURIPrefix(payload[0])
 				cardInfo.URL = uriPrefix + string(payload[1:])
+				record.Data = cardInfo.URL
+				record.DataType = "url"
 			}
 		} else if tnf == 0x01 && len(recordType) == 1 && recordType[0] == 'T' {
-			// Text record
+			// Text record - only the first text record populates Data
 			if len(payload) >= 1 {
 				langCodeLen := int(payload[0] & 0x3F)
 				if 1+langCodeLen <= len(payload) {
-					cardInfo.Data = string(payload[1+langCodeLen:])
-					cardInfo.DataType = "text"
+					record.Lang = string(payload[1 : 1+langCodeLen])
+					record.Data = string(payload[1+langCodeLen:])
+					record.DataType = "text"
+					if !seenText {
+						cardInfo.Data = record.Data
+						cardInfo.DataType = "text"
+						seenText = true
+					}
 				}
 			}
-		} else if tnf == 0x02 {
-			// MIME type record
-			mimeType := string(recordType)
-			if mimeType == "application/json" {
-				cardInfo.Data = string(payload)
-				cardInfo.DataType = "json"
-			} else if mimeType == openprinttag.MIMEType || mimeType == "application/cbor" {
-				// OpenPrintTag format (application/vnd.openprinttag or application/cbor)
-				opt, err := openprinttag.Decode(payload)
-				if err == nil {
-					resp := opt.ToResponse()
-					jsonData, _ := json.Marshal(resp)
-					cardInfo.Data = string(jsonData)
-					cardInfo.DataType = "openprinttag"
-				} else {
-					// Fallback to binary if CBOR decode fails
-					cardInfo.Data = hex.EncodeToString(payload)
-					cardInfo.DataType = "binary"
-				}
-			} else if mimeType == "application/octet-stream" {
-				cardInfo.Data = hex.EncodeToString(payload)
-				cardInfo.DataType = "binary"
+		} else if tnf == 0x01 && string(recordType) == "Hs" {
+			// Handover Select - list the alternative carriers
+			if _, carriers, err := ParseHandoverSelect(payload); err == nil {
+				carriersJSON, _ := json.Marshal(carriers)
+				record.Data = string(carriersJSON)
+				record.DataType = "handover"
 			} else {
-				cardInfo.Data = string(payload)
-				cardInfo.DataType = "unknown"
+				record.Data = hex.EncodeToString(payload)
+				record.DataType = "binary"
 			}
+		} else if tnf == 0x02 {
+			// MIME type record
+			record.Data, record.DataType = decodeMimePayload(string(recordType), payload)
+			cardInfo.Data = record.Data
+			cardInfo.DataType = record.DataType
+		} else if tnf == 0x00 {
+			record.DataType = "empty"
+		} else {
+			record.Data = hex.EncodeToString(payload)
+			record.DataType = "binary"
 		}
+		cardInfo.Records = append(cardInfo.Records, record)
 
 		// Move to next record
-		offset = recordStart + typeLength + payloadLength
+		offset = payloadStart + payloadLength
 
 		if me {
+			sawME = true
+			if offset < len(ndefMessage) {
+				markNDEFMalformed(cardInfo, fmt.Sprintf("%d bytes after the ME record (more than one message)", len(ndefMessage)-offset))
+			}
 			break // Last record
 		}
 	}
 
+	if !sawME && len(cardInfo.Records) > 0 {
+		markNDEFMalformed(cardInfo, "last record is missing the ME flag")
+	}
+
 	// If we only have a URL and no other data, put URL in Data field too for backwards compatibility
 	if cardInfo.URL != "" && cardInfo.Data == "" {
 		cardInfo.Data = cardInfo.URL
@@ -1371,6 +1852,135 @@ done:
 	}
 }
 
+// markNDEFMalformed flags the card's NDEF message as malformed, keeping the
+// first reason found.
+func markNDEFMalformed(cardInfo *Card, reason string) {
+	if cardInfo.NDEFMalformed {
+		return
+	}
+	cardInfo.NDEFMalformed = true
+	cardInfo.NDEFMalformedReason = reason
+	logging.Debug(logging.CatCard, "Malformed NDEF message", map[string]any{
+		"reason": reason,
+	})
+}
+
+// ParseNDEFMessage decodes the records of a bare NDEF message, such as the
+// payload of a Smart Poster record.
+func ParseNDEFMessage(message []byte) []ParsedRecord {
+	var scratch Card
+	parseNDEFRecords(message, &scratch)
+	return scratch.Records
+}
+
+// MediaType returns the lowercased media type of a MIME record type with any
+// parameters stripped, e.g. "Application/JSON; charset=utf-8" -> "application/json".
+func MediaType(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// decodeMimePayload converts a MIME record payload into its response
+// representation and data type.
+func decodeMimePayload(mimeType string, payload []byte) (string, string) {
+	switch MediaType(mimeType) {
+	case "application/json", KeyValueMIMEType, AppDataMIMEType:
+		return string(payload), "json"
+	case openprinttag.MIMEType, CBORMIMEType:
+		// OpenPrintTag format (application/vnd.openprinttag or application/cbor).
+		// Other CBOR maps may decode too, so application/cbor only counts as
+		// an OpenPrintTag with its required fields.
+		opt, err := openprinttag.Decode(payload)
+		if err == nil && MediaType(mimeType) == CBORMIMEType && !opt.HasRequiredFields() {
+			err = errors.New("not an OpenPrintTag")
+		}
+		if err != nil {
+			// Generic CBOR that isn't an OpenPrintTag, else fall back to binary
+			if MediaType(mimeType) == CBORMIMEType {
+				if jsonData, err := decodeGenericCBOR(payload); err == nil {
+					return jsonData, "cbor"
+				}
+			}
+			return hex.EncodeToString(payload), "binary"
+		}
+		jsonData, _ := json.Marshal(opt.ToResponse())
+		return string(jsonData), "openprinttag"
+	case "application/octet-stream":
+		return hex.EncodeToString(payload), "binary"
+	default:
+		return string(payload), "unknown"
+	}
+}
+
+// encodeBinaryData re-encodes the binary payloads of a read, which are decoded
+// as hex, with the named encoder. Hex and unknown encodings are left as-is.
+func encodeBinaryData(cardInfo *Card, encoding string) {
+	encode, ok := binaryEncoders[encoding]
+	if !ok || encoding == "hex" {
+		return
+	}
+	for _, records := range append([][]ParsedRecord{cardInfo.Records}, cardInfo.ExtraNDEFMessages...) {
+		for i := range records {
+			if records[i].DataType == "binary" {
+				records[i].Data = encode(records[i].Payload)
+			}
+		}
+	}
+	if cardInfo.DataType == "binary" {
+		if payload, err := hex.DecodeString(cardInfo.Data); err == nil {
+			cardInfo.Data = encode(payload)
+		}
+	}
+}
+
+// preferRecord populates the top-level Data/DataType from the first record of
+// the preferred data type, overriding the default record-order choice. If no
+// record has that type the card is left unchanged.
+func preferRecord(cardInfo *Card, prefer string) {
+	if prefer == "" {
+		return
+	}
+	for _, rec := range cardInfo.Records {
+		if rec.DataType != prefer {
+			continue
+		}
+		cardInfo.Data = rec.Data
+		cardInfo.DataType = rec.DataType
+		if rec.DataType == "url" {
+			cardInfo.URL = rec.Data
+		}
+		return
+	}
+}
+
+// selectTextRecord replaces a text Data field with the text record matching
+// the preferred language. A preference of "en" also matches "en-US". If no
+// record matches, the first text record is kept.
+func selectTextRecord(cardInfo *Card, lang string) {
+	if lang == "" || cardInfo.DataType != "text" {
+		return
+	}
+	var prefixMatch *ParsedRecord
+	for i := range cardInfo.Records {
+		rec := &cardInfo.Records[i]
+		if rec.DataType != "text" {
+			continue
+		}
+		if strings.EqualFold(rec.Lang, lang) {
+			cardInfo.Data = rec.Data
+			return
+		}
+		if prefixMatch == nil && strings.HasPrefix(strings.ToLower(rec.Lang), strings.ToLower(lang)+"-") {
+			prefixMatch = rec
+		}
+	}
+	if prefixMatch != nil {
+		cardInfo.Data = prefixMatch.Data
+	}
+}
+
 // getURIPrefix returns the URI prefix for NDEF URI record identifier codes
 func getURIPrefix(code byte) string {
 	prefixes := map[byte]string{
@@ -1419,22 +2029,47 @@ func getURIPrefix(code byte) string {
 
 // EraseCard erases all NDEF data from an NFC tag by writing an empty NDEF message
 func EraseCard(readerName string) error {
-	ctx, err := scard.EstablishContext()
+	return EraseCardWithOptions(readerName, WriteOptions{})
+}
+
+// EraseCardWithOptions is like EraseCard but applies write options (only
+// ExpectUID and ZeroMemory are used).
+func EraseCardWithOptions(readerName string, opts WriteOptions) (err error) {
+	defer trackOperation("erase_card", readerName, &err)()
+
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
+	if cc, err := readNTAGPage(card, 3); err == nil && ccReadOnly(cc) {
+		return ErrNDEFReadOnly
+	}
+
 	// Write an empty NDEF message (just TLV header and terminator)
 	// 0x03 = NDEF TLV, 0x00 = length, 0xFE = terminator
 	emptyNDEF := []byte{0x03, 0x00, 0xFE, 0x00}
 
+	if opts.ZeroMemory {
+		cardInfo := &Card{}
+		detectCardType(card, cardInfo)
+		rememberCardType(readerName, cardInfo.Type)
+		if emptyNDEF, err = zeroFillUserMemory(cardInfo, emptyNDEF); err != nil {
+			return err
+		}
+	}
+
 	if err := writeNTAGPages(card, 4, emptyNDEF); err != nil {
 		return fmt.Errorf("failed to erase card: %w", err)
 	}
@@ -1442,27 +2077,54 @@ func EraseCard(readerName string) error {
 	return nil
 }
 
+// zeroFillUserMemory pads data with zeros to the end of the card's user
+// memory, so that writing it from page 4 leaves no old data behind. Lock and
+// configuration pages past the user memory are not touched.
+func zeroFillUserMemory(cardInfo *Card, data []byte) ([]byte, error) {
+	first, last, ok := ntagUserPageRange(cardInfo)
+	if !ok {
+		return nil, fmt.Errorf("full erase is not supported for %s (NTAG21x and MIFARE Ultralight only)", cardInfo.Type)
+	}
+	size := (last - first + 1) * 4
+	if len(data) > size {
+		return data, nil
+	}
+	return append(data, make([]byte, size-len(data))...), nil
+}
+
 // LockCard makes an NTAG card permanently read-only by setting the lock bits
 // WARNING: This is IRREVERSIBLE! Once locked, the card cannot be written to again.
 func LockCard(readerName string) error {
-	ctx, err := scard.EstablishContext()
+	return LockCardWithOptions(readerName, WriteOptions{})
+}
+
+// LockCardWithOptions is like LockCard but applies write options (only
+// ExpectUID is used).
+func LockCardWithOptions(readerName string, opts WriteOptions) (err error) {
+	defer trackOperation("lock_card", readerName, &err)()
+
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
 	// For NTAG cards, page 2 contains the static lock bytes at bytes 2-3
 	// Setting bits in these bytes locks pages permanently
 
 	// First, read page 2 to preserve UID bytes
 	readCmd := []byte{0xFF, 0xB0, 0x00, 0x02, 0x04}
-	rsp, err := card.Transmit(readCmd)
+	rsp, err := transmit(card, readCmd)
 	if err != nil {
 		return fmt.Errorf("failed to read page 2: %w", err)
 	}
@@ -1477,7 +2139,7 @@ func LockCard(readerName string) error {
 	writeCmd := []byte{0xFF, 0xD6, 0x00, 0x02, 0x04}
 	writeCmd = append(writeCmd, page2Data...)
 
-	rsp, err = card.Transmit(writeCmd)
+	rsp, err = transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write lock bytes: %w", err)
 	}
@@ -1492,26 +2154,21 @@ func LockCard(readerName string) error {
 	// Detect card type to know where dynamic lock bytes are
 	cardInfo := &Card{}
 	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
 
-	var dynamicLockPage int
-	switch cardInfo.Type {
-	case "NTAG213":
-		dynamicLockPage = 40
-	case "NTAG215":
-		dynamicLockPage = 130
-	case "NTAG216":
-		dynamicLockPage = 226
-	default:
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
 		// Unknown card type, skip dynamic locks
 		return nil
 	}
+	dynamicLockPage := layout.dynamicLockPage
 
 	// Write dynamic lock bytes (all 1s = all pages locked)
 	dynamicLockData := []byte{0xFF, 0xFF, 0xFF, 0x00}
 	writeCmd = []byte{0xFF, 0xD6, 0x00, byte(dynamicLockPage), 0x04}
 	writeCmd = append(writeCmd, dynamicLockData...)
 
-	_, err = card.Transmit(writeCmd)
+	_, err = transmit(card, writeCmd)
 	if err != nil {
 		// Dynamic locks may fail on some cards, but static locks were set
 		logging.Warn(logging.CatCard, "Failed to set dynamic lock bytes", map[string]any{
@@ -1525,7 +2182,9 @@ func LockCard(readerName string) error {
 // SetPassword sets a password on an NTAG card (NTAG213/215/216 only)
 // The password protects pages from the specified startPage onwards
 // Note: Password is 4 bytes, PACK (password acknowledge) is 2 bytes
-func SetPassword(readerName string, password []byte, pack []byte, startPage byte) error {
+func SetPassword(readerName string, password []byte, pack []byte, startPage byte) (err error) {
+	defer trackOperation("set_password", readerName, &err)()
+
 	if len(password) != 4 {
 		return fmt.Errorf("password must be exactly 4 bytes")
 	}
@@ -1533,44 +2192,41 @@ func SetPassword(readerName string, password []byte, pack []byte, startPage byte
 		return fmt.Errorf("PACK must be exactly 2 bytes")
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
 	// Detect card type to find config pages
 	cardInfo := &Card{}
 	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
 
-	var pwdPage, packPage, authPage int
-	switch cardInfo.Type {
-	case "NTAG213":
-		pwdPage = 43
-		packPage = 44
-		authPage = 41
-	case "NTAG215":
-		pwdPage = 133
-		packPage = 134
-		authPage = 131
-	case "NTAG216":
-		pwdPage = 229
-		packPage = 230
-		authPage = 227
-	default:
+	return setNTAGPassword(card, cardInfo, password, pack, startPage)
+}
+
+// setNTAGPassword writes PWD and PACK and sets AUTH0 over an open connection.
+func setNTAGPassword(card *scard.Card, cardInfo *Card, password []byte, pack []byte, startPage byte) error {
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
 		return fmt.Errorf("password protection not supported for card type: %s", cardInfo.Type)
 	}
+	// CFG0 (holding AUTH0) and CFG1 follow the dynamic lock page, then PWD and PACK
+	authPage := layout.dynamicLockPage + 1
+	pwdPage := authPage + 2
+	packPage := authPage + 3
 
 	// Write password to PWD page
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(pwdPage), 0x04}
 	writeCmd = append(writeCmd, password...)
-	rsp, err := card.Transmit(writeCmd)
+	rsp, err := transmitSecret(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write password: %w", err)
 	}
@@ -1582,7 +2238,7 @@ func SetPassword(readerName string, password []byte, pack []byte, startPage byte
 	packData := []byte{pack[0], pack[1], 0x00, 0x00}
 	writeCmd = []byte{0xFF, 0xD6, 0x00, byte(packPage), 0x04}
 	writeCmd = append(writeCmd, packData...)
-	rsp, err = card.Transmit(writeCmd)
+	rsp, err = transmitSecret(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write PACK: %w", err)
 	}
@@ -1592,7 +2248,7 @@ func SetPassword(readerName string, password []byte, pack []byte, startPage byte
 
 	// Read AUTH0 page to preserve other config bits
 	readCmd := []byte{0xFF, 0xB0, 0x00, byte(authPage), 0x04}
-	rsp, err = card.Transmit(readCmd)
+	rsp, err = transmit(card, readCmd)
 	if err != nil {
 		return fmt.Errorf("failed to read AUTH0 page: %w", err)
 	}
@@ -1605,7 +2261,7 @@ func SetPassword(readerName string, password []byte, pack []byte, startPage byte
 	authData := []byte{rsp[0], rsp[1], rsp[2], startPage}
 	writeCmd = []byte{0xFF, 0xD6, 0x00, byte(authPage), 0x04}
 	writeCmd = append(writeCmd, authData...)
-	rsp, err = card.Transmit(writeCmd)
+	rsp, err = transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write AUTH0: %w", err)
 	}
@@ -1618,43 +2274,40 @@ func SetPassword(readerName string, password []byte, pack []byte, startPage byte
 
 // RemovePassword removes password protection from an NTAG card
 // Requires the current password to authenticate first
-func RemovePassword(readerName string, password []byte) error {
+func RemovePassword(readerName string, password []byte) (err error) {
+	defer trackOperation("remove_password", readerName, &err)()
+
 	if len(password) != 4 {
 		return fmt.Errorf("password must be exactly 4 bytes")
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
 	// Detect card type
 	cardInfo := &Card{}
 	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
 
-	var authPage int
-	switch cardInfo.Type {
-	case "NTAG213":
-		authPage = 41
-	case "NTAG215":
-		authPage = 131
-	case "NTAG216":
-		authPage = 227
-	default:
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
 		return fmt.Errorf("password protection not supported for card type: %s", cardInfo.Type)
 	}
+	authPage := layout.dynamicLockPage + 1
 
 	// Authenticate with current password (PWD_AUTH command via pseudo-APDU)
 	authCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x07, 0xD4, 0x42, 0x1B}
 	authCmd = append(authCmd, password...)
-	rsp, err := card.Transmit(authCmd)
+	rsp, err := transmit(card, authCmd)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -1665,7 +2318,7 @@ func RemovePassword(readerName string, password []byte) error {
 
 	// Set AUTH0 to 0xFF to disable password protection (all pages unprotected)
 	readCmd := []byte{0xFF, 0xB0, 0x00, byte(authPage), 0x04}
-	rsp, err = card.Transmit(readCmd)
+	rsp, err = transmit(card, readCmd)
 	if err != nil {
 		return fmt.Errorf("failed to read AUTH0 page: %w", err)
 	}
@@ -1677,7 +2330,7 @@ func RemovePassword(readerName string, password []byte) error {
 	authData := []byte{rsp[0], rsp[1], rsp[2], 0xFF}
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(authPage), 0x04}
 	writeCmd = append(writeCmd, authData...)
-	rsp, err = card.Transmit(writeCmd)
+	rsp, err = transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to disable password: %w", err)
 	}
@@ -1690,39 +2343,290 @@ func RemovePassword(readerName string, password []byte) error {
 
 // WriteMultipleRecords writes multiple NDEF records to a card
 type NDEFRecord struct {
-	Type     string `json:"type"`               // "url", "text", "json", "binary", "mime"
-	Data     string `json:"data"`               // Data content
+	Type     string `json:"type"`               // "url", "text", "json", "cbor", "binary", "mime", "openprinttag", "handover", "empty", "unknown"
+	Data     string `json:"data"`               // Data content (openprinttag: Input JSON; handover: HandoverInput JSON; cbor: any JSON, stored as CBOR)
 	MimeType string `json:"mimeType,omitempty"` // For generic mime records (e.g., "application/vnd.openprinttag")
 	DataType string `json:"dataType,omitempty"` // "binary" for base64-encoded data
 }
 
+// Limits applied to multi-record writes before any data is decoded or sent to
+// the card. They can be overridden at startup via SetNDEFLimits.
+var (
+	MaxNDEFRecords      = 16
+	MaxNDEFPayloadBytes = 8192
+)
+
+// ErrNDEFLimitExceeded is returned when a record set exceeds the configured limits.
+var ErrNDEFLimitExceeded = errors.New("NDEF record limit exceeded")
+
+// SetNDEFLimits overrides the record count and total payload limits.
+// Non-positive values leave the current limit unchanged.
+func SetNDEFLimits(maxRecords, maxPayloadBytes int) {
+	if maxRecords > 0 {
+		MaxNDEFRecords = maxRecords
+	}
+	if maxPayloadBytes > 0 {
+		MaxNDEFPayloadBytes = maxPayloadBytes
+	}
+}
+
+// ValidateNDEFRecords checks a record set against MaxNDEFRecords and
+// MaxNDEFPayloadBytes. Payload sizes are estimated from the encoded length so
+// oversized hex/base64 data is rejected without being decoded.
+func ValidateNDEFRecords(records []NDEFRecord) error {
+	if len(records) > MaxNDEFRecords {
+		return fmt.Errorf("%w: %d records (max %d)", ErrNDEFLimitExceeded, len(records), MaxNDEFRecords)
+	}
+
+	total := 0
+	for i, rec := range records {
+		if rec.Type == "text" && !utf8.ValidString(rec.Data) {
+			return fmt.Errorf("record %d: text is not valid UTF-8 (use type 'binary' for binary data)", i)
+		}
+		if rec.Type == "url" {
+			if err := CheckURI(rec.Data); err != nil {
+				return fmt.Errorf("record %d: %w", i, err)
+			}
+		}
+
+		size := len(rec.Data)
+		switch {
+		case rec.Type == "binary":
+			size = hex.DecodedLen(len(rec.Data))
+		case (rec.Type == "mime" || rec.Type == "unknown") && rec.DataType == "binary":
+			size = base64.StdEncoding.DecodedLen(len(rec.Data))
+		}
+		total += size + len(rec.MimeType)
+		if total > MaxNDEFPayloadBytes {
+			return fmt.Errorf("%w: payload exceeds %d bytes at record %d", ErrNDEFLimitExceeded, MaxNDEFPayloadBytes, i)
+		}
+	}
+	return nil
+}
+
 func WriteMultipleRecords(readerName string, records []NDEFRecord) error {
+	return WriteMultipleRecordsWithOptions(readerName, records, WriteOptions{})
+}
+
+// WriteMultipleRecordsWithOptions is like WriteMultipleRecords but applies write options.
+func WriteMultipleRecordsWithOptions(readerName string, records []NDEFRecord, opts WriteOptions) (err error) {
+	defer trackOperation("write_records", readerName, &err)()
+
 	if len(records) == 0 {
 		return fmt.Errorf("no records to write")
 	}
+	if err := ValidateNDEFRecords(records); err != nil {
+		return err
+	}
 
-	ctx, err := scard.EstablishContext()
+	tlv, err := buildNDEFRecordsTLV(records)
+	if err != nil {
+		return err
+	}
+	if opts.LongRecords {
+		if tlv, err = withLongRecords(tlv); err != nil {
+			return err
+		}
+	}
+
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
-	// Get ATR to detect card type
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
 	status, err := card.Status()
 	if err != nil {
 		return fmt.Errorf("failed to get card status: %w", err)
 	}
-	atr := hex.EncodeToString(status.Atr)
-	isISO15693 := contains(atr, "03060b")
+	cardInfo := &Card{
+		ATR: hex.EncodeToString(status.Atr),
+	}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	if err := checkWriteProtection(card, cardInfo, opts.Force); err != nil {
+		return err
+	}
+
+	tlv, err = withControlTLVs(cardInfo, tlv, opts.ControlTLVs)
+	if err != nil {
+		return err
+	}
+
+	if err := writeNDEFTLV(card, cardInfo, tlv); err != nil {
+		return err
+	}
+	if opts.Verify {
+		return verifyNDEFTLV(card, cardInfo, tlv)
+	}
+	return nil
+}
 
+// verifyNDEFTLV reads the NDEF message back and checks that it matches the one
+// in the written TLV area.
+func verifyNDEFTLV(card *scard.Card, cardInfo *Card, tlv []byte) error {
+	start, length, _, state := locateNDEFTLV(tlv)
+	if state != ndefTLVFound {
+		return fmt.Errorf("no NDEF message to verify")
+	}
+
+	waitWriteSettle()
+	readBack := &Card{ATR: cardInfo.ATR, Type: cardInfo.Type, Size: cardInfo.Size}
+	readNDEFData(card, readBack, ReadOptions{})
+	if !bytes.Equal(readBack.RawNDEF, tlv[start:start+length]) {
+		return ErrVerifyMismatch
+	}
+	return nil
+}
+
+// checkWriteProtection reads the tag's current NDEF data and refuses the write
+// if it holds an OpenPrintTag with a write-protected main section. With force
+// the write goes ahead, but is logged.
+func checkWriteProtection(card *scard.Card, cardInfo *Card, force bool) error {
+	existing := *cardInfo
+	readNDEFData(card, &existing, ReadOptions{})
+
+	for _, rec := range existing.Records {
+		if rec.DataType != "openprinttag" {
+			continue
+		}
+		opt, err := openprinttag.Decode(rec.Payload)
+		if err != nil || !opt.IsWriteProtected() {
+			continue
+		}
+		if !force {
+			return ErrWriteProtected
+		}
+		logCard(card, logging.LevelWarn, logging.CatCard, "Overwriting write-protected OpenPrintTag (forced)", map[string]any{
+			"cardType":        cardInfo.Type,
+			"materialName":    opt.Main.MaterialName,
+			"brandName":       opt.Main.BrandName,
+			"writeProtection": opt.Main.WriteProtection,
+		})
+	}
+	return nil
+}
+
+// WriteRawNDEF writes an already-encoded NDEF message (records without TLV
+// wrapping) to the card, for clients that build their own records.
+func WriteRawNDEF(readerName string, message []byte) error {
+	return WriteRawNDEFWithOptions(readerName, message, WriteOptions{})
+}
+
+// WriteRawNDEFWithOptions is like WriteRawNDEF but applies write options
+// (Force and ExpectUID are used).
+func WriteRawNDEFWithOptions(readerName string, message []byte, opts WriteOptions) (err error) {
+	defer trackOperation("write_raw_ndef", readerName, &err)()
+
+	if len(message) < 3 {
+		return fmt.Errorf("NDEF message too short: %d bytes", len(message))
+	}
+	if message[0]&0x80 == 0 {
+		return fmt.Errorf("invalid NDEF message: first record is missing the MB flag")
+	}
+	if len(message) > MaxNDEFPayloadBytes {
+		return fmt.Errorf("%w: message is %d bytes (max %d)", ErrNDEFLimitExceeded, len(message), MaxNDEFPayloadBytes)
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
+	status, err := card.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get card status: %w", err)
+	}
+	cardInfo := &Card{
+		ATR: hex.EncodeToString(status.Atr),
+	}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	if err := checkWriteProtection(card, cardInfo, opts.Force); err != nil {
+		return err
+	}
+
+	return writeNDEFTLV(card, cardInfo, wrapNDEFTLV(message))
+}
+
+// writeNDEFTLV writes a complete NDEF TLV to the NDEF area of a Type 2 or Type 5
+// tag or the NDEF sectors of a MIFARE Classic card, or the message it holds to
+// the NDEF file of a Type 4 tag.
+func writeNDEFTLV(card *scard.Card, cardInfo *Card, tlv []byte) error {
+	isISO15693 := isISO15693ATR(cardInfo.ATR)
+
+	if cardInfo.Type == "MIFARE Classic" {
+		if err := writeMifareClassic(card, tlv); err != nil {
+			return fmt.Errorf("failed to write NDEF records: %w", err)
+		}
+	} else if cardInfo.Type == cardTypeType4 {
+		if err := writeType4NDEFTLV(card, tlv); err != nil {
+			return fmt.Errorf("failed to write NDEF records: %w", err)
+		}
+	} else if isISO15693 {
+		// ISO 15693 (Type 5) tags: CC at block 0, NDEF at block 1
+		// CC format: E1 [version/access] [size/8] [features]
+		// - 0xE1: Magic number
+		// - 0x40: Version 1.0 (4), read/write access (0)
+		// - Size: Available memory / 8 (we'll use 0x40 = 512 bytes, conservative)
+		// - 0x00: No special features
+		cc := []byte{0xE1, 0x40, 0x40, 0x00}
+
+		// Write CC at block 0
+		if err := writeNTAGPages(card, 0, cc); err != nil {
+			return fmt.Errorf("failed to write CC block: %w", err)
+		}
+
+		// Write NDEF TLV starting at block 1
+		if err := writeNTAGPages(card, 1, tlv); err != nil {
+			return fmt.Errorf("failed to write NDEF records: %w", err)
+		}
+	} else {
+		// NTAG (Type 2) tags: NDEF at page 4
+		if err := checkNDEFWritable(cardInfo); err != nil {
+			return err
+		}
+		tlv = omitTerminatorIfFull(cardInfo, 4, tlv)
+		if err := checkUserMemory(cardInfo, 4, len(tlv)); err != nil {
+			return err
+		}
+		if err := writeNTAGPages(card, 4, tlv); err != nil {
+			return fmt.Errorf("failed to write NDEF records: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// buildNDEFRecordsTLV encodes records as a single NDEF message wrapped in a
+// TLV block with terminator.
+func buildNDEFRecordsTLV(records []NDEFRecord) ([]byte, error) {
 	// Build multi-record NDEF message
 	var ndefRecords []byte
+	carrierRefs := 0 // Handover carrier record IDs used so far
 	for i, rec := range records {
 		isFirst := i == 0
 		isLast := i == len(records)-1
@@ -1745,69 +2649,88 @@ func WriteMultipleRecords(readerName string, records []NDEFRecord) error {
 			// Decode hex
 			decoded, err := hex.DecodeString(rec.Data)
 			if err != nil {
-				return fmt.Errorf("invalid binary data in record %d: %w", i, err)
+				return nil, fmt.Errorf("invalid binary data in record %d: %w", i, err)
 			}
 			recordBytes = createNDEFRecordRaw(0x02, []byte("application/octet-stream"), decoded, isFirst, isLast)
+		case "cbor":
+			payload, err := encodeGenericCBOR([]byte(rec.Data))
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			recordBytes = createNDEFRecordRaw(0x02, []byte(CBORMIMEType), payload, isFirst, isLast)
+		case "empty":
+			// TNF 0x00: type, ID and payload must all be empty
+			if rec.Data != "" {
+				return nil, fmt.Errorf("empty record %d must not contain data", i)
+			}
+			recordBytes = createNDEFRecordRaw(0x00, nil, nil, isFirst, isLast)
+		case "unknown":
+			// TNF 0x05: no type, opaque payload
+			payload := []byte(rec.Data)
+			if rec.DataType == "binary" {
+				decoded, err := base64.StdEncoding.DecodeString(rec.Data)
+				if err != nil {
+					return nil, fmt.Errorf("invalid base64 data in unknown record %d: %w", i, err)
+				}
+				payload = decoded
+			}
+			recordBytes = createNDEFRecordRaw(0x05, nil, payload, isFirst, isLast)
 		case "mime":
 			if rec.MimeType == "" {
-				return fmt.Errorf("mimeType required for mime record type in record %d", i)
+				return nil, fmt.Errorf("mimeType required for mime record type in record %d", i)
 			}
 			var payload []byte
 			if rec.DataType == "binary" {
 				// Data is base64 encoded
 				decoded, err := base64.StdEncoding.DecodeString(rec.Data)
 				if err != nil {
-					return fmt.Errorf("invalid base64 data in mime record %d: %w", i, err)
+					return nil, fmt.Errorf("invalid base64 data in mime record %d: %w", i, err)
 				}
 				payload = decoded
 			} else {
 				payload = []byte(rec.Data)
 			}
 			recordBytes = createNDEFRecordRaw(0x02, []byte(rec.MimeType), payload, isFirst, isLast)
+		case "handover":
+			records, refs, err := createHandoverRecords(rec.Data, carrierRefs, isFirst, isLast)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i, err)
+			}
+			carrierRefs += refs
+			recordBytes = records
+		case "openprinttag":
+			var input openprinttag.Input
+			if err := json.Unmarshal([]byte(rec.Data), &input); err != nil {
+				return nil, fmt.Errorf("invalid openprinttag JSON in record %d: %w", i, err)
+			}
+			cborPayload, err := input.Encode()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode openprinttag record %d: %w", i, err)
+			}
+			recordBytes = createNDEFRecordRaw(0x02, []byte(openprinttag.MIMEType), cborPayload, isFirst, isLast)
 		default:
-			return fmt.Errorf("unsupported record type: %s", rec.Type)
+			return nil, fmt.Errorf("unsupported record type: %s", rec.Type)
 		}
 		ndefRecords = append(ndefRecords, recordBytes...)
 	}
 
-	// Wrap in TLV format
+	return wrapNDEFTLV(ndefRecords), nil
+}
+
+// wrapNDEFTLV wraps an NDEF message in an NDEF Message TLV followed by a terminator TLV.
+func wrapNDEFTLV(message []byte) []byte {
 	tlv := []byte{0x03}
-	if len(ndefRecords) < 255 {
-		tlv = append(tlv, byte(len(ndefRecords)))
+	if len(message) < 255 {
+		tlv = append(tlv, byte(len(message)))
 	} else {
 		tlv = append(tlv, 0xFF)
-		tlv = append(tlv, byte(len(ndefRecords)>>8))
-		tlv = append(tlv, byte(len(ndefRecords)))
+		tlv = append(tlv, byte(len(message)>>8))
+		tlv = append(tlv, byte(len(message)))
 	}
-	tlv = append(tlv, ndefRecords...)
+	tlv = append(tlv, message...)
 	tlv = append(tlv, 0xFE)
 
-	if isISO15693 {
-		// ISO 15693 (Type 5) tags: CC at block 0, NDEF at block 1
-		// CC format: E1 [version/access] [size/8] [features]
-		// - 0xE1: Magic number
-		// - 0x40: Version 1.0 (4), read/write access (0)
-		// - Size: Available memory / 8 (we'll use 0x40 = 512 bytes, conservative)
-		// - 0x00: No special features
-		cc := []byte{0xE1, 0x40, 0x40, 0x00}
-
-		// Write CC at block 0
-		if err := writeNTAGPages(card, 0, cc); err != nil {
-			return fmt.Errorf("failed to write CC block: %w", err)
-		}
-
-		// Write NDEF TLV starting at block 1
-		if err := writeNTAGPages(card, 1, tlv); err != nil {
-			return fmt.Errorf("failed to write NDEF records: %w", err)
-		}
-	} else {
-		// NTAG (Type 2) tags: NDEF at page 4
-		if err := writeNTAGPages(card, 4, tlv); err != nil {
-			return fmt.Errorf("failed to write NDEF records: %w", err)
-		}
-	}
-
-	return nil
+	return tlv
 }
 
 // defaultMifareKeys contains common MIFARE Classic authentication keys
@@ -1818,6 +2741,43 @@ var defaultMifareKeys = [][]byte{
 	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // Zero key
 }
 
+// mifareClassicBlocks returns how many blocks to assume on a MIFARE Classic
+// card: the override if set, otherwise inferred from the SAK or the detected
+// size (Mini = 20, 1K = 64, 2K = 128, 4K = 256 blocks). The SAK is checked
+// first because the authentication-probe detection path reports 1K.
+func mifareClassicBlocks(cardInfo *Card, override int) int {
+	if override > 0 {
+		if override > MaxMifareBlocks {
+			return MaxMifareBlocks
+		}
+		return override
+	}
+	switch cardInfo.SAK {
+	case "09":
+		return 20
+	case "19":
+		return 128
+	case "18", "38":
+		return 256
+	}
+	if cardInfo.Size >= 320 && cardInfo.Size <= MaxMifareBlocks*16 {
+		return cardInfo.Size / 16
+	}
+	return 64
+}
+
+// mifareDataBlocks counts the data blocks after sector 0 in a MIFARE Classic
+// layout of the given size, skipping sector trailers.
+func mifareDataBlocks(blocks int) int {
+	n := 0
+	for b := 4; b < blocks; b++ {
+		if !isSectorTrailer(b) {
+			n++
+		}
+	}
+	return n
+}
+
 // isSectorTrailer returns true if the block is a sector trailer (contains keys and access bits)
 func isSectorTrailer(block int) bool {
 	// For MIFARE Classic 1K (sectors 0-15, 4 blocks each), trailer is every 4th block starting at 3
@@ -1862,14 +2822,14 @@ func authenticateMifareBlock(card *scard.Card, blockNum int, key []byte, keyType
 		// Load key into reader's key slot 0
 		loadKeyCmd := []byte{0xFF, 0x82, 0x00, 0x00, 0x06}
 		loadKeyCmd = append(loadKeyCmd, k...)
-		rsp, err := card.Transmit(loadKeyCmd)
+		rsp, err := transmit(card, loadKeyCmd)
 		if err != nil || len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
 			continue
 		}
 
 		// Try specified key type first
 		authCmd := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), keyType, 0x00}
-		rsp, err = card.Transmit(authCmd)
+		rsp, err = transmit(card, authCmd)
 		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 			return nil // Success
 		}
@@ -1881,7 +2841,7 @@ func authenticateMifareBlock(card *scard.Card, blockNum int, key []byte, keyType
 				otherKeyType = 0x60
 			}
 			authCmd = []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, byte(authBlock), otherKeyType, 0x00}
-			rsp, err = card.Transmit(authCmd)
+			rsp, err = transmit(card, authCmd)
 			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 				return nil // Success
 			}
@@ -1894,7 +2854,9 @@ func authenticateMifareBlock(card *scard.Card, blockNum int, key []byte, keyType
 // ReadMifareBlock reads a 16-byte block from a MIFARE Classic card.
 // If key is nil/empty, tries default keys (FFFFFFFFFFFF, D3F7D3F7D3F7, etc.)
 // keyType should be 'A' or 'B' (defaults to 'A')
-func ReadMifareBlock(readerName string, block int, key []byte, keyType byte) ([]byte, error) {
+func ReadMifareBlock(readerName string, block int, key []byte, keyType byte) (_ []byte, err error) {
+	defer trackOperation("read_mifare_block", readerName, &err)()
+
 	if block < 0 || block > 255 {
 		return nil, fmt.Errorf("invalid block number: %d (must be 0-255)", block)
 	}
@@ -1902,15 +2864,15 @@ func ReadMifareBlock(readerName string, block int, key []byte, keyType byte) ([]
 		return nil, fmt.Errorf("cannot read sector trailer block %d (contains authentication keys)", block)
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -1925,28 +2887,108 @@ func ReadMifareBlock(readerName string, block int, key []byte, keyType byte) ([]
 		return nil, err
 	}
 
-	// Read block: FF B0 00 [block] 10
-	readCmd := []byte{0xFF, 0xB0, 0x00, byte(block), 0x10}
-	rsp, err := card.Transmit(readCmd)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read block %d: %w", block, err)
-	}
-	if len(rsp) < 18 || rsp[len(rsp)-2] != 0x90 {
-		return nil, fmt.Errorf("read failed for block %d: status %02X %02X", block, rsp[len(rsp)-2], rsp[len(rsp)-1])
+	// Read block: FF B0 00 [block] 10
+	readCmd := []byte{0xFF, 0xB0, 0x00, byte(block), 0x10}
+	rsp, err := transmit(card, readCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block %d: %w", block, err)
+	}
+	if len(rsp) < 18 || rsp[len(rsp)-2] != 0x90 {
+		return nil, fmt.Errorf("read failed for block %d: status %02X %02X", block, rsp[len(rsp)-2], rsp[len(rsp)-1])
+	}
+
+	logging.Info(logging.CatCard, "MIFARE block read", map[string]any{
+		"block": block,
+		"data":  hex.EncodeToString(rsp[:16]),
+	})
+
+	return rsp[:16], nil
+}
+
+// ReadMifareBlocks reads count consecutive MIFARE Classic blocks starting at
+// start in a single card session, re-authenticating when crossing sectors.
+// Sector trailers are not read; their entries in the result are nil.
+// If key is nil/empty, tries default keys. keyType should be 'A' or 'B' (defaults to 'A').
+func ReadMifareBlocks(readerName string, start, count int, key []byte, keyType byte) (_ [][]byte, err error) {
+	defer trackOperation("read_mifare_blocks", readerName, &err)()
+
+	if start < 0 || start > 255 {
+		return nil, fmt.Errorf("invalid start block: %d (must be 0-255)", start)
+	}
+	if count < 1 || start+count > 256 {
+		return nil, fmt.Errorf("invalid block count: %d (range must end by block 255)", count)
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	// Convert key type character to APDU byte
+	var keyTypeByte byte = 0x60 // Default Key A
+	if keyType == 'B' || keyType == 'b' || keyType == 0x61 {
+		keyTypeByte = 0x61
+	}
+
+	blocks := make([][]byte, count)
+	lastAuthSector := -1
+
+	for i := range blocks {
+		block := start + i
+		if isSectorTrailer(block) {
+			continue
+		}
+
+		// Calculate sector for this block
+		sector := block / 4
+		if block >= 128 {
+			sector = 32 + (block-128)/16
+		}
+
+		// Re-authenticate if sector changed
+		if sector != lastAuthSector {
+			if err := authenticateMifareBlock(card, block, key, keyTypeByte); err != nil {
+				return nil, fmt.Errorf("block %d: %w", block, err)
+			}
+			lastAuthSector = sector
+		}
+
+		// Read block: FF B0 00 [block] 10
+		readCmd := []byte{0xFF, 0xB0, 0x00, byte(block), 0x10}
+		rsp, err := transmit(card, readCmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", block, err)
+		}
+		if len(rsp) < 2 {
+			return nil, fmt.Errorf("read failed for block %d: short response % X", block, rsp)
+		}
+		if len(rsp) < 18 || rsp[len(rsp)-2] != 0x90 {
+			return nil, fmt.Errorf("read failed for block %d: status %02X %02X", block, rsp[len(rsp)-2], rsp[len(rsp)-1])
+		}
+		blocks[i] = append([]byte(nil), rsp[:16]...)
 	}
 
-	logging.Info(logging.CatCard, "MIFARE block read", map[string]any{
-		"block": block,
-		"data":  hex.EncodeToString(rsp[:16]),
+	logging.Info(logging.CatCard, "MIFARE blocks read", map[string]any{
+		"start": start,
+		"count": count,
 	})
 
-	return rsp[:16], nil
+	return blocks, nil
 }
 
 // WriteMifareBlock writes 16 bytes to a MIFARE Classic block.
 // If key is nil/empty, tries default keys (FFFFFFFFFFFF, D3F7D3F7D3F7, etc.)
 // keyType should be 'A' or 'B' (defaults to 'A')
-func WriteMifareBlock(readerName string, block int, data []byte, key []byte, keyType byte) error {
+func WriteMifareBlock(readerName string, block int, data []byte, key []byte, keyType byte) (err error) {
+	defer trackOperation("write_mifare_block", readerName, &err)()
+
 	if block < 0 || block > 255 {
 		return fmt.Errorf("invalid block number: %d (must be 0-255)", block)
 	}
@@ -1957,15 +2999,15 @@ func WriteMifareBlock(readerName string, block int, data []byte, key []byte, key
 		return fmt.Errorf("data must be exactly 16 bytes, got %d", len(data))
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -1983,7 +3025,7 @@ func WriteMifareBlock(readerName string, block int, data []byte, key []byte, key
 	// Write block: FF D6 00 [block] 10 [16 bytes]
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(block), 0x10}
 	writeCmd = append(writeCmd, data...)
-	rsp, err := card.Transmit(writeCmd)
+	rsp, err := transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write block %d: %w", block, err)
 	}
@@ -2003,20 +3045,22 @@ func WriteMifareBlock(readerName string, block int, data []byte, key []byte, key
 // page: Page number (0-255, actual range depends on card variant)
 // password: Optional 4-byte password for EV1 variants (nil = no auth)
 // Returns 4 bytes of page data.
-func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, error) {
+func ReadUltralightPage(readerName string, page int, password []byte) (_ []byte, err error) {
+	defer trackOperation("read_ultralight_page", readerName, &err)()
+
 	if page < 0 || page > 255 {
 		return nil, fmt.Errorf("invalid page number: %d (must be 0-255)", page)
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -2025,14 +3069,41 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 		if err := authenticateUltralight(card, password); err != nil {
 			return nil, err
 		}
+		return readUltralightPageRaw(card, page)
+	}
+
+	data, err := readUltralightPageRaw(card, page)
+	if err == nil {
+		return data, nil
+	}
+
+	// The page may be behind the password: retry with the configured one
+	if data, ok := readWithConfiguredPassword(card, page, readUltralightPageRaw); ok {
+		return data, nil
+	}
+
+	// The NAK sent the tag back to IDLE, so it must be reset to detect its type
+	cardInfo := &Card{}
+	if reactivateCard(card) == nil {
+		detectCardType(card, cardInfo)
+	}
+	if auth0, protected := passwordProtection(card, cardInfo, page); protected {
+		if auth0 > 0 {
+			return nil, fmt.Errorf("%w: page %d is protected (AUTH0 = %d)", ErrPasswordRequired, page, auth0)
+		}
+		return nil, fmt.Errorf("%w: page %d is protected", ErrPasswordRequired, page)
 	}
+	return nil, err
+}
 
+// readUltralightPageRaw reads one page, trying each reader's read method in turn.
+func readUltralightPageRaw(card cardTransmitter, page int) ([]byte, error) {
 	// Method 1: Standard READ BINARY command (works on most readers including ACR1252U)
 	// APDU: FF B0 00 [page] 10 (reads 16 bytes = 4 pages)
 	readCmd := []byte{0xFF, 0xB0, 0x00, byte(page), 0x10}
-	rsp, err := card.Transmit(readCmd)
+	rsp, err := transmit(card, readCmd)
 	if err == nil && len(rsp) >= 6 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
-		logging.Info(logging.CatCard, "Ultralight page read", map[string]any{
+		logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page read", map[string]any{
 			"page":   page,
 			"data":   hex.EncodeToString(rsp[:4]),
 			"method": 1,
@@ -2043,10 +3114,10 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 	// Method 2: ACR122U InCommunicateThru with native READ command (0x30)
 	// Format: FF 00 00 00 04 D4 42 30 [page]
 	directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x42, 0x30, byte(page)}
-	rsp, err = card.Transmit(directCmd)
+	rsp, err = transmit(card, directCmd)
 	if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
 		if len(rsp) >= 19 && rsp[0] == 0xD5 && rsp[1] == 0x43 && rsp[2] == 0x00 {
-			logging.Info(logging.CatCard, "Ultralight page read", map[string]any{
+			logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page read", map[string]any{
 				"page":   page,
 				"data":   hex.EncodeToString(rsp[3:7]),
 				"method": 2,
@@ -2061,15 +3132,15 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 	setProtocol := []byte{0xFF, 0xC2, 0x00, 0x02, 0x04, 0x8F, 0x02, 0x00, 0x03}
 	endSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
 
-	rsp, err = card.Transmit(startSession)
+	rsp, err = transmit(card, startSession)
 	if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 		// Set protocol to ISO 14443-A Layer 3
-		rsp, err = card.Transmit(setProtocol)
+		rsp, err = transmit(card, setProtocol)
 		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 			// Native READ command: 30 [page]
 			transparentCmd := []byte{0xFF, 0xC2, 0x00, 0x01, 0x04, 0x95, 0x02, 0x30, byte(page)}
-			rsp, err = card.Transmit(transparentCmd)
-			card.Transmit(endSession) // Always end session
+			rsp, err = transmit(card, transparentCmd)
+			transmit(card, endSession) // Always end session
 
 			// Response contains data in TLV format with tag 0x97
 			// Parse to find the 16 bytes of page data
@@ -2079,7 +3150,7 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 					if rsp[i] == 0x97 && i+1 < len(rsp) {
 						tagLen := int(rsp[i+1])
 						if i+2+tagLen <= len(rsp) && tagLen >= 4 {
-							logging.Info(logging.CatCard, "Ultralight page read", map[string]any{
+							logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page read", map[string]any{
 								"page":   page,
 								"data":   hex.EncodeToString(rsp[i+2 : i+2+4]),
 								"method": 3,
@@ -2090,7 +3161,7 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 				}
 			}
 		} else {
-			card.Transmit(endSession)
+			transmit(card, endSession)
 		}
 	}
 
@@ -2101,7 +3172,9 @@ func ReadUltralightPage(readerName string, page int, password []byte) ([]byte, e
 // page: Page number to write (minimum 4 for user data to protect system pages)
 // data: Exactly 4 bytes to write
 // password: Optional 4-byte password for EV1 variants (nil = no auth)
-func WriteUltralightPage(readerName string, page int, data []byte, password []byte) error {
+func WriteUltralightPage(readerName string, page int, data []byte, password []byte) (err error) {
+	defer trackOperation("write_ultralight_page", readerName, &err)()
+
 	if page < 0 || page > 255 {
 		return fmt.Errorf("invalid page number: %d (must be 0-255)", page)
 	}
@@ -2112,15 +3185,15 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 		return fmt.Errorf("data must be exactly 4 bytes, got %d", len(data))
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -2135,7 +3208,7 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 	// Format: A2 [page] [4 bytes data]
 	rawCmd := []byte{0xA2, byte(page)}
 	rawCmd = append(rawCmd, data...)
-	rsp, err := card.Transmit(rawCmd)
+	rsp, err := transmit(card, rawCmd)
 	if err == nil && len(rsp) >= 1 {
 		// NTAG write returns ACK (0x0A) on success
 		if rsp[0] == 0x0A || (len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00) {
@@ -2152,7 +3225,7 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 	// APDU: FF D6 00 [page] 04 [4 bytes]
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(page), 0x04}
 	writeCmd = append(writeCmd, data...)
-	rsp, err = card.Transmit(writeCmd)
+	rsp, err = transmit(card, writeCmd)
 	logging.Debug(logging.CatCard, "Ultralight write method 1", map[string]any{
 		"page":     page,
 		"cmd":      hex.EncodeToString(writeCmd),
@@ -2172,7 +3245,7 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 	// Format: FF 00 00 00 08 D4 42 A2 [page] [4 bytes data]
 	directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x08, 0xD4, 0x42, 0xA2, byte(page)}
 	directCmd = append(directCmd, data...)
-	rsp, err = card.Transmit(directCmd)
+	rsp, err = transmit(card, directCmd)
 	logging.Debug(logging.CatCard, "Ultralight write method 2", map[string]any{
 		"page":     page,
 		"cmd":      hex.EncodeToString(directCmd),
@@ -2204,16 +3277,16 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 	endSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
 
 	// End any stale session first (ignore result)
-	card.Transmit(endSession)
+	transmit(card, endSession)
 
-	rsp, err = card.Transmit(startSession)
+	rsp, err = transmit(card, startSession)
 	logging.Debug(logging.CatCard, "Ultralight write method 3 - start session", map[string]any{
 		"response": hex.EncodeToString(rsp),
 		"err":      fmt.Sprintf("%v", err),
 	})
 	if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
 		// Set protocol to ISO 14443-A Layer 3
-		rsp, err = card.Transmit(setProtocol)
+		rsp, err = transmit(card, setProtocol)
 		logging.Debug(logging.CatCard, "Ultralight write method 3 - set protocol", map[string]any{
 			"response": hex.EncodeToString(rsp),
 			"err":      fmt.Sprintf("%v", err),
@@ -2226,13 +3299,13 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 			transparentCmd := []byte{0xFF, 0xC2, 0x00, 0x01, byte(len(writeData) + 2), 0x95, byte(len(writeData))}
 			transparentCmd = append(transparentCmd, writeData...)
 
-			rsp, err = card.Transmit(transparentCmd)
+			rsp, err = transmit(card, transparentCmd)
 			logging.Debug(logging.CatCard, "Ultralight write method 3 - write cmd", map[string]any{
 				"cmd":      hex.EncodeToString(transparentCmd),
 				"response": hex.EncodeToString(rsp),
 				"err":      fmt.Sprintf("%v", err),
 			})
-			card.Transmit(endSession) // Always end session
+			transmit(card, endSession) // Always end session
 
 			// Check for success - response contains status in TLV format
 			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
@@ -2244,13 +3317,232 @@ func WriteUltralightPage(readerName string, page int, data []byte, password []by
 				return nil
 			}
 		} else {
-			card.Transmit(endSession)
+			transmit(card, endSession)
 		}
 	}
 
 	return fmt.Errorf("write failed for page %d: no supported method worked", page)
 }
 
+// IncrementTagCounter treats a user memory page as a big-endian 4-byte counter.
+// It reads the current value, writes value+1 and reads it back to verify, all
+// in a single card session. Returns the new value. Only NTAG213/215/216 and
+// MIFARE Ultralight (Type 2 tags with 4-byte pages) are supported; the page
+// must be in the tag's user memory.
+func IncrementTagCounter(readerName string, page int, password []byte) (_ uint32, err error) {
+	defer trackOperation("increment_counter", readerName, &err)()
+
+	if page < 4 || page > 255 {
+		return 0, fmt.Errorf("invalid counter page: %d (must be 4-255)", page)
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return 0, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return 0, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	cardInfo := &Card{}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	first, last, ok := ntagUserPageRange(cardInfo)
+	if !ok {
+		return 0, fmt.Errorf("tag counters not supported for card type: %s (NTAG and MIFARE Ultralight only)", cardInfo.Type)
+	}
+	if page < first || page > last {
+		return 0, fmt.Errorf("invalid counter page: %d (%s user memory is pages %d-%d)", page, cardInfo.Type, first, last)
+	}
+
+	if len(password) > 0 {
+		if err := authenticateUltralight(card, password); err != nil {
+			return 0, err
+		}
+	}
+
+	current, err := readNTAGPage(card, page)
+	if err != nil || len(current) < 4 {
+		return 0, fmt.Errorf("failed to read counter page %d: %v", page, err)
+	}
+
+	value := binary.BigEndian.Uint32(current[:4])
+	if value == math.MaxUint32 {
+		return 0, fmt.Errorf("counter on page %d has reached its maximum value", page)
+	}
+	value++
+
+	newData := make([]byte, 4)
+	binary.BigEndian.PutUint32(newData, value)
+	if err := writeUltralightBatchPage(card, UltralightPageWrite{Page: page, Data: newData}); err != nil {
+		return 0, fmt.Errorf("failed to write counter page %d: %w", page, err)
+	}
+
+	// Read back to make sure the write landed (guards against lost updates)
+	waitWriteSettle()
+	verify, err := readNTAGPage(card, page)
+	if err != nil || len(verify) < 4 {
+		return 0, fmt.Errorf("failed to verify counter page %d: %v", page, err)
+	}
+	if !bytes.Equal(verify[:4], newData) {
+		return 0, fmt.Errorf("counter verification failed on page %d: wrote %s, read %s",
+			page, hex.EncodeToString(newData), hex.EncodeToString(verify[:4]))
+	}
+
+	logging.Info(logging.CatCard, "Tag counter incremented", map[string]any{
+		"page":  page,
+		"value": value,
+	})
+
+	return value, nil
+}
+
+// WriteTestResult reports the outcome of a non-destructive write test.
+type WriteTestResult struct {
+	Page     int    `json:"page"`             // Page (MIFARE Classic: block) used as scratch space
+	Method   string `json:"method,omitempty"` // Write method that worked
+	Restored bool   `json:"restored"`         // Whether the original bytes were written back
+}
+
+// writeTestPattern is written to the scratch page during TestWrite.
+var writeTestPattern = []byte{0xA5, 0x5A, 0xC3, 0x3C}
+
+// TestWrite checks that the card can be written without changing its contents.
+// It reads the first user page (page 4, block 1 on ISO 15693 tags, block 4 on
+// MIFARE Classic), writes a known pattern, reads it back to confirm, then
+// restores the original bytes.
+func TestWrite(readerName string) (_ *WriteTestResult, err error) {
+	defer trackOperation("test_write", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	status, err := card.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card status: %w", err)
+	}
+	cardInfo := &Card{
+		ATR: hex.EncodeToString(status.Atr),
+	}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	if cardInfo.Type == "MIFARE Classic" {
+		const block = 4
+		lastAuthSector := -1
+		return runWriteTest(block,
+			func() ([]byte, error) {
+				return readMifareClassicBlock(card, block, &lastAuthSector)
+			},
+			func(data []byte) (string, error) {
+				writeCmd := []byte{0xFF, 0xD6, 0x00, block, 0x10}
+				writeCmd = append(writeCmd, data...)
+				rsp, err := transmit(card, writeCmd)
+				if err != nil {
+					return "", err
+				}
+				if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
+					return "", fmt.Errorf("write failed: status % X", rsp)
+				}
+				return writeMethodUpdateBinary, nil
+			})
+	}
+
+	page := 4
+	if isISO15693ATR(cardInfo.ATR) {
+		page = 1 // ISO 15693: block 0 holds the capability container
+	}
+	return runWriteTest(page,
+		func() ([]byte, error) {
+			data, err := readNTAGPage(card, page)
+			if err != nil {
+				return nil, err
+			}
+			if len(data) < 4 {
+				return nil, fmt.Errorf("short read: %d bytes", len(data))
+			}
+			return data[:4], nil
+		},
+		func(data []byte) (string, error) {
+			return writeUltralightPageMethod(card, UltralightPageWrite{Page: page, Data: data})
+		})
+}
+
+// runWriteTest performs the read/write/verify/restore sequence of TestWrite
+// against a single page using the given read and write functions.
+func runWriteTest(page int, read func() ([]byte, error), write func([]byte) (string, error)) (*WriteTestResult, error) {
+	result := &WriteTestResult{Page: page}
+
+	original, err := read()
+	if err != nil {
+		return result, fmt.Errorf("failed to read page %d: %w", page, err)
+	}
+	original = append([]byte(nil), original...)
+
+	// Repeat the pattern to the page size, inverting it if the page already holds it
+	pattern := make([]byte, len(original))
+	for i := range pattern {
+		pattern[i] = writeTestPattern[i%len(writeTestPattern)]
+	}
+	if bytes.Equal(pattern, original) {
+		for i := range pattern {
+			pattern[i] ^= 0xFF
+		}
+	}
+
+	method, err := write(pattern)
+	if err != nil {
+		return result, fmt.Errorf("failed to write test pattern to page %d: %w", page, err)
+	}
+	result.Method = method
+
+	waitWriteSettle()
+	verify, verifyErr := read()
+
+	// Restore before checking the read-back so the card is left as we found it
+	_, restoreErr := write(original)
+	if restoreErr == nil {
+		waitWriteSettle()
+		restored, err := read()
+		result.Restored = err == nil && bytes.Equal(restored, original)
+	}
+
+	if verifyErr != nil {
+		return result, fmt.Errorf("failed to read back page %d: %w", page, verifyErr)
+	}
+	if !bytes.Equal(verify, pattern) {
+		return result, fmt.Errorf("write test verification failed on page %d: wrote %s, read %s",
+			page, hex.EncodeToString(pattern), hex.EncodeToString(verify))
+	}
+	if restoreErr != nil {
+		return result, fmt.Errorf("failed to restore page %d to %s: %w", page, hex.EncodeToString(original), restoreErr)
+	}
+	if !result.Restored {
+		return result, fmt.Errorf("could not verify restore of page %d to %s", page, hex.EncodeToString(original))
+	}
+
+	logging.Info(logging.CatCard, "Write test passed", map[string]any{
+		"page":   page,
+		"method": method,
+	})
+
+	return result, nil
+}
+
 // UltralightPageWrite represents a single page write operation.
 type UltralightPageWrite struct {
 	Page int    `json:"page"`
@@ -2262,45 +3554,103 @@ type UltralightWriteResult struct {
 	Page    int    `json:"page"`
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
+	Actual  string `json:"actual,omitempty"` // Hex of the page as read back, when verifying
+}
+
+// UltralightRollback reports the outcome of restoring pages after a failed batch write.
+type UltralightRollback struct {
+	Attempted     bool   `json:"attempted"`
+	Success       bool   `json:"success"`
+	RestoredPages []int  `json:"restoredPages,omitempty"`
+	Error         string `json:"error,omitempty"`
 }
 
 // WriteUltralightPages writes multiple pages to a MIFARE Ultralight / NTAG card
 // in a single card session. This is more efficient and reliable than multiple
 // individual WriteUltralightPage calls.
 func WriteUltralightPages(readerName string, pages []UltralightPageWrite, password []byte) ([]UltralightWriteResult, error) {
+	results, _, err := WriteUltralightPagesWithRollback(readerName, pages, password, false)
+	return results, err
+}
+
+// WriteUltralightPagesWithRollback is like WriteUltralightPages but, when
+// rollbackOnError is set, snapshots the target pages before writing. If a page
+// write fails, the remaining pages are skipped and the pages already written are
+// restored (best effort) from the snapshot. The returned rollback is nil when no
+// rollback was needed.
+func WriteUltralightPagesWithRollback(readerName string, pages []UltralightPageWrite, password []byte, rollbackOnError bool) ([]UltralightWriteResult, *UltralightRollback, error) {
+	return WriteUltralightPagesContext(context.Background(), readerName, pages, password, rollbackOnError, false, "")
+}
+
+// WriteUltralightPagesContext is like WriteUltralightPagesWithRollback but stops
+// between pages once ctx is done. The remaining pages are reported as canceled
+// (and written pages rolled back if requested) and the error wraps ctx.Err().
+// When verify is set, each page is read back after writing; the bytes read are
+// reported in Actual and a mismatch fails the page.
+// A non-empty expectUID aborts with ErrUIDMismatch before writing if the card
+// has a different UID.
+func WriteUltralightPagesContext(ctx context.Context, readerName string, pages []UltralightPageWrite, password []byte, rollbackOnError, verify bool, expectUID string) (_ []UltralightWriteResult, _ *UltralightRollback, err error) {
+	defer trackOperation("write_ultralight_pages", readerName, &err)()
+
 	if len(pages) == 0 {
-		return nil, fmt.Errorf("no pages to write")
+		return nil, nil, fmt.Errorf("no pages to write")
 	}
 
 	// Validate all pages before connecting
 	for _, p := range pages {
 		if p.Page < 0 || p.Page > 255 {
-			return nil, fmt.Errorf("invalid page number: %d (must be 0-255)", p.Page)
+			return nil, nil, fmt.Errorf("invalid page number: %d (must be 0-255)", p.Page)
 		}
 		if p.Page < 4 {
-			return nil, fmt.Errorf("cannot write to system pages 0-3 (page %d)", p.Page)
+			return nil, nil, fmt.Errorf("cannot write to system pages 0-3 (page %d)", p.Page)
 		}
 		if len(p.Data) != 4 {
-			return nil, fmt.Errorf("page %d: data must be exactly 4 bytes, got %d", p.Page, len(p.Data))
+			return nil, nil, fmt.Errorf("page %d: data must be exactly 4 bytes, got %d", p.Page, len(p.Data))
 		}
 	}
 
-	ctx, err := scard.EstablishContext()
+	scardCtx, err := establishContext()
 	if err != nil {
-		return nil, fmt.Errorf("failed to establish context: %w", err)
+		return nil, nil, fmt.Errorf("failed to establish context: %w", err)
 	}
-	defer ctx.Release()
+	defer scardCtx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(scardCtx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
+	if err := checkExpectedUID(card, expectUID); err != nil {
+		return nil, nil, err
+	}
+
 	// Authenticate with password if provided (for Ultralight EV1)
 	if len(password) > 0 {
 		if err := authenticateUltralight(card, password); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	}
+
+	return writeUltralightBatch(ctx, card, pages, rollbackOnError, verify)
+}
+
+// writeUltralightBatch writes validated pages on a connected card for
+// WriteUltralightPagesContext.
+func writeUltralightBatch(ctx context.Context, card cardTransmitter, pages []UltralightPageWrite, rollbackOnError, verify bool) ([]UltralightWriteResult, *UltralightRollback, error) {
+	// Snapshot the target pages so they can be restored on failure
+	var snapshot map[int][]byte
+	if rollbackOnError {
+		snapshot = make(map[int][]byte, len(pages))
+		for _, p := range pages {
+			if _, ok := snapshot[p.Page]; ok {
+				continue
+			}
+			data, err := readNTAGPage(card, p.Page)
+			if err != nil || len(data) < 4 {
+				return nil, nil, fmt.Errorf("failed to snapshot page %d for rollback: %v", p.Page, err)
+			}
+			snapshot[p.Page] = append([]byte(nil), data[:4]...)
 		}
 	}
 
@@ -2309,86 +3659,173 @@ func WriteUltralightPages(readerName string, pages []UltralightPageWrite, passwo
 	for i, p := range pages {
 		results[i].Page = p.Page
 
-		// Try Method 1: Standard UPDATE BINARY (works on most readers)
-		writeCmd := []byte{0xFF, 0xD6, 0x00, byte(p.Page), 0x04}
-		writeCmd = append(writeCmd, p.Data...)
-		rsp, err := card.Transmit(writeCmd)
+		if ctx.Err() != nil {
+			for j := i; j < len(pages); j++ {
+				results[j].Page = pages[j].Page
+				results[j].Error = "canceled"
+			}
+			var rollback *UltralightRollback
+			if rollbackOnError && i > 0 {
+				rollback = rollbackUltralightPages(card, results[:i], snapshot)
+			}
+			return results, rollback, fmt.Errorf("%w after %d of %d pages", ctx.Err(), i, len(pages))
+		}
 
-		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
-			results[i].Success = true
-			logging.Info(logging.CatCard, "Ultralight page written (batch)", map[string]any{
-				"page": p.Page,
-				"data": hex.EncodeToString(p.Data),
-			})
+		waitBeforePageWrite(i)
+		written := i // Pages to restore if this one fails
+		err := writeUltralightBatchPage(card, p)
+		if err == nil && verify {
+			written = i + 1 // The page was written, just not as requested
+			results[i].Actual, err = verifyUltralightPage(card, p)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			if rollbackOnError {
+				for j := i + 1; j < len(pages); j++ {
+					results[j].Page = pages[j].Page
+					results[j].Error = "skipped after earlier failure"
+				}
+				return results, rollbackUltralightPages(card, results[:written], snapshot), nil
+			}
 			continue
 		}
+		results[i].Success = true
+	}
 
-		// Try Method 2: ACR122U InCommunicateThru
-		directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x08, 0xD4, 0x42, 0xA2, byte(p.Page)}
-		directCmd = append(directCmd, p.Data...)
-		rsp, err = card.Transmit(directCmd)
+	return results, nil, nil
+}
 
-		if err == nil && len(rsp) >= 2 {
-			sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
-			if sw1 == 0x90 && sw2 == 0x00 {
-				if len(rsp) >= 3 && rsp[0] == 0xD5 && rsp[1] == 0x43 && rsp[2] != 0x00 {
-					results[i].Error = fmt.Sprintf("card error %02X", rsp[2])
-					continue
-				}
-				results[i].Success = true
-				logging.Info(logging.CatCard, "Ultralight page written (batch)", map[string]any{
-					"page": p.Page,
-					"data": hex.EncodeToString(p.Data),
-				})
-				continue
-			}
+// verifyUltralightPage reads a page back after a write and returns its bytes
+// as hex, failing if they differ from what was written.
+func verifyUltralightPage(card cardTransmitter, p UltralightPageWrite) (string, error) {
+	waitWriteSettle()
+	data, err := readNTAGPage(card, p.Page)
+	if err != nil {
+		return "", fmt.Errorf("verify read failed: %w", err)
+	}
+	if len(data) < 4 {
+		return "", fmt.Errorf("verify read failed: got %d bytes", len(data))
+	}
+	actual := hex.EncodeToString(data[:4])
+	if !bytes.Equal(data[:4], p.Data) {
+		return actual, fmt.Errorf("verify mismatch: wrote %s, read %s", hex.EncodeToString(p.Data), actual)
+	}
+	return actual, nil
+}
+
+// rollbackUltralightPages restores successfully written pages from a snapshot,
+// in reverse write order.
+func rollbackUltralightPages(card cardTransmitter, written []UltralightWriteResult, snapshot map[int][]byte) *UltralightRollback {
+	rollback := &UltralightRollback{Attempted: true, Success: true}
+
+	for i := len(written) - 1; i >= 0; i-- {
+		page := written[i].Page
+		waitBeforePageWrite(len(written) - 1 - i)
+		if err := writeUltralightBatchPage(card, UltralightPageWrite{Page: page, Data: snapshot[page]}); err != nil {
+			rollback.Success = false
+			rollback.Error = fmt.Sprintf("page %d: %v", page, err)
+			continue
 		}
+		rollback.RestoredPages = append(rollback.RestoredPages, page)
+	}
 
-		// Try Method 3: ACR1552 Transparent Exchange with native WRITE command (0xA2)
-		startSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x81, 0x00}
-		setProtocol := []byte{0xFF, 0xC2, 0x00, 0x02, 0x04, 0x8F, 0x02, 0x00, 0x03}
-		endSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
+	logCard(card, logging.LevelWarn, logging.CatCard, "Ultralight batch write rolled back", map[string]any{
+		"restored": rollback.RestoredPages,
+		"success":  rollback.Success,
+	})
 
-		// End any stale session first (ignore result)
-		card.Transmit(endSession)
+	return rollback
+}
 
-		rsp, err = card.Transmit(startSession)
-		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
-			rsp, err = card.Transmit(setProtocol)
-			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
-				// Build transparent write command: A2 [page] [4 bytes]
-				writeData := []byte{0xA2, byte(p.Page)}
-				writeData = append(writeData, p.Data...)
-				// Wrap in transparent exchange: FF C2 00 01 [len+2] 95 [len] [data]
-				transparentCmd := []byte{0xFF, 0xC2, 0x00, 0x01, byte(len(writeData) + 2), 0x95, byte(len(writeData))}
-				transparentCmd = append(transparentCmd, writeData...)
+// writeUltralightBatchPage writes a single 4-byte page, trying each supported
+// write method in turn.
+func writeUltralightBatchPage(card cardTransmitter, p UltralightPageWrite) error {
+	_, err := writeUltralightPageMethod(card, p)
+	return err
+}
 
-				rsp, err = card.Transmit(transparentCmd)
-				card.Transmit(endSession) // Always end session
+// Names of the page write methods tried by writeUltralightPageMethod, in order.
+const (
+	writeMethodUpdateBinary        = "update_binary"        // Standard PC/SC UPDATE BINARY
+	writeMethodDirectTransmit      = "direct_transmit"      // ACR122U InCommunicateThru
+	writeMethodTransparentExchange = "transparent_exchange" // ACR1552 transparent session
+)
 
-				if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
-					results[i].Success = true
-					logging.Info(logging.CatCard, "Ultralight page written (batch)", map[string]any{
-						"page":   p.Page,
-						"data":   hex.EncodeToString(p.Data),
-						"method": 3,
-					})
-					continue
-				}
-			} else {
-				card.Transmit(endSession)
+// writeUltralightPageMethod writes one page, trying each write method in turn,
+// and returns the name of the method that worked.
+func writeUltralightPageMethod(card cardTransmitter, p UltralightPageWrite) (string, error) {
+	// Try Method 1: Standard UPDATE BINARY (works on most readers)
+	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(p.Page), 0x04}
+	writeCmd = append(writeCmd, p.Data...)
+	rsp, err := transmit(card, writeCmd)
+
+	if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
+		logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page written (batch)", map[string]any{
+			"page": p.Page,
+			"data": hex.EncodeToString(p.Data),
+		})
+		return writeMethodUpdateBinary, nil
+	}
+
+	// Try Method 2: ACR122U InCommunicateThru
+	directCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x08, 0xD4, 0x42, 0xA2, byte(p.Page)}
+	directCmd = append(directCmd, p.Data...)
+	rsp, err = transmit(card, directCmd)
+
+	if err == nil && len(rsp) >= 2 {
+		sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]
+		if sw1 == 0x90 && sw2 == 0x00 {
+			if len(rsp) >= 3 && rsp[0] == 0xD5 && rsp[1] == 0x43 && rsp[2] != 0x00 {
+				return "", fmt.Errorf("card error %02X", rsp[2])
 			}
+			logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page written (batch)", map[string]any{
+				"page": p.Page,
+				"data": hex.EncodeToString(p.Data),
+			})
+			return writeMethodDirectTransmit, nil
 		}
+	}
 
-		// All methods failed
-		if err != nil {
-			results[i].Error = err.Error()
+	// Try Method 3: ACR1552 Transparent Exchange with native WRITE command (0xA2)
+	startSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x81, 0x00}
+	setProtocol := []byte{0xFF, 0xC2, 0x00, 0x02, 0x04, 0x8F, 0x02, 0x00, 0x03}
+	endSession := []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
+
+	// End any stale session first (ignore result)
+	transmit(card, endSession)
+
+	rsp, err = transmit(card, startSession)
+	if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
+		rsp, err = transmit(card, setProtocol)
+		if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
+			// Build transparent write command: A2 [page] [4 bytes]
+			writeData := []byte{0xA2, byte(p.Page)}
+			writeData = append(writeData, p.Data...)
+			// Wrap in transparent exchange: FF C2 00 01 [len+2] 95 [len] [data]
+			transparentCmd := []byte{0xFF, 0xC2, 0x00, 0x01, byte(len(writeData) + 2), 0x95, byte(len(writeData))}
+			transparentCmd = append(transparentCmd, writeData...)
+
+			rsp, err = transmit(card, transparentCmd)
+			transmit(card, endSession) // Always end session
+
+			if err == nil && len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 {
+				logCard(card, logging.LevelInfo, logging.CatCard, "Ultralight page written (batch)", map[string]any{
+					"page":   p.Page,
+					"data":   hex.EncodeToString(p.Data),
+					"method": 3,
+				})
+				return writeMethodTransparentExchange, nil
+			}
 		} else {
-			results[i].Error = fmt.Sprintf("write failed: status %02X %02X", rsp[len(rsp)-2], rsp[len(rsp)-1])
+			transmit(card, endSession)
 		}
 	}
 
-	return results, nil
+	// All methods failed
+	if err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("write failed: status % X", rsp)
 }
 
 // MifareBlockWrite represents a single block write operation.
@@ -2408,6 +3845,16 @@ type MifareWriteResult struct {
 // in a single card session. This is more efficient and reliable than multiple
 // individual WriteMifareBlock calls. Re-authenticates when crossing sectors.
 func WriteMifareBlocks(readerName string, blocks []MifareBlockWrite, key []byte, keyType byte) ([]MifareWriteResult, error) {
+	return WriteMifareBlocksContext(context.Background(), readerName, blocks, key, keyType, "")
+}
+
+// WriteMifareBlocksContext is like WriteMifareBlocks but stops between blocks
+// once ctx is done. The remaining blocks are reported as canceled and the
+// error wraps ctx.Err(). A non-empty expectUID aborts with ErrUIDMismatch
+// before writing if the card has a different UID.
+func WriteMifareBlocksContext(ctx context.Context, readerName string, blocks []MifareBlockWrite, key []byte, keyType byte, expectUID string) (_ []MifareWriteResult, err error) {
+	defer trackOperation("write_mifare_blocks", readerName, &err)()
+
 	if len(blocks) == 0 {
 		return nil, fmt.Errorf("no blocks to write")
 	}
@@ -2425,18 +3872,22 @@ func WriteMifareBlocks(readerName string, blocks []MifareBlockWrite, key []byte,
 		}
 	}
 
-	ctx, err := scard.EstablishContext()
+	scardCtx, err := establishContext()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
-	defer ctx.Release()
+	defer scardCtx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(scardCtx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
+	if err := checkExpectedUID(card, expectUID); err != nil {
+		return nil, err
+	}
+
 	// Convert key type character to APDU byte
 	var keyTypeByte byte = 0x60 // Default Key A
 	if keyType == 'B' || keyType == 'b' || keyType == 0x61 {
@@ -2449,6 +3900,14 @@ func WriteMifareBlocks(readerName string, blocks []MifareBlockWrite, key []byte,
 	for i, b := range blocks {
 		results[i].Block = b.Block
 
+		if ctx.Err() != nil {
+			for j := i; j < len(blocks); j++ {
+				results[j].Block = blocks[j].Block
+				results[j].Error = "canceled"
+			}
+			return results, fmt.Errorf("%w after %d of %d blocks", ctx.Err(), i, len(blocks))
+		}
+
 		// Calculate sector for this block
 		sector := b.Block / 4
 		if b.Block >= 128 {
@@ -2467,7 +3926,7 @@ func WriteMifareBlocks(readerName string, blocks []MifareBlockWrite, key []byte,
 		// Write block: FF D6 00 [block] 10 [16 bytes]
 		writeCmd := []byte{0xFF, 0xD6, 0x00, byte(b.Block), 0x10}
 		writeCmd = append(writeCmd, b.Data...)
-		rsp, err := card.Transmit(writeCmd)
+		rsp, err := transmit(card, writeCmd)
 		if err != nil {
 			results[i].Error = fmt.Sprintf("transmit error: %v", err)
 			lastAuthSector = -1 // Force re-auth on next block
@@ -2499,7 +3958,7 @@ func authenticateUltralight(card *scard.Card, password []byte) error {
 	// PWD_AUTH command via pseudo-APDU: FF 00 00 00 07 D4 42 1B [4-byte password]
 	authCmd := []byte{0xFF, 0x00, 0x00, 0x00, 0x07, 0xD4, 0x42, 0x1B}
 	authCmd = append(authCmd, password...)
-	rsp, err := card.Transmit(authCmd)
+	rsp, err := transmit(card, authCmd)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -2509,7 +3968,7 @@ func authenticateUltralight(card *scard.Card, password []byte) error {
 		return fmt.Errorf("authentication failed: wrong password or unsupported card")
 	}
 
-	logging.Debug(logging.CatCard, "Ultralight authenticated", nil)
+	logCard(card, logging.LevelDebug, logging.CatCard, "Ultralight authenticated", nil)
 	return nil
 }
 
@@ -2542,26 +4001,28 @@ func aesECBEncrypt(key, data []byte) ([]byte, error) {
 //  4. Return first 6 bytes as the derived MIFARE key
 //
 // aesKey must be exactly 16 bytes (the AES-128 encryption key).
-func DeriveUIDKeyAES(readerName string, aesKey []byte) ([]byte, error) {
+func DeriveUIDKeyAES(readerName string, aesKey []byte) (_ []byte, err error) {
+	defer trackOperation("derive_uid_key_aes", readerName, &err)()
+
 	if len(aesKey) != 16 {
 		return nil, fmt.Errorf("AES key must be 16 bytes, got %d", len(aesKey))
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to reader: %w", err)
+		return nil, err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
 	// Get UID using standard command: FF CA 00 00 00
 	getUIDCmd := []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
-	rsp, err := card.Transmit(getUIDCmd)
+	rsp, err := transmit(card, getUIDCmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get UID: %w", err)
 	}
@@ -2603,7 +4064,9 @@ func DeriveUIDKeyAES(readerName string, aesKey []byte) ([]byte, error) {
 // aesKey: 16-byte AES encryption key
 // authKey: 6-byte MIFARE sector authentication key
 // authKeyType: 'A' or 'B' (defaults to 'A')
-func AESEncryptAndWriteBlock(readerName string, block int, data, aesKey, authKey []byte, authKeyType byte) error {
+func AESEncryptAndWriteBlock(readerName string, block int, data, aesKey, authKey []byte, authKeyType byte) (err error) {
+	defer trackOperation("aes_encrypt_and_write_block", readerName, &err)()
+
 	if len(data) != 16 {
 		return fmt.Errorf("data must be exactly 16 bytes, got %d", len(data))
 	}
@@ -2623,15 +4086,15 @@ func AESEncryptAndWriteBlock(readerName string, block int, data, aesKey, authKey
 		return fmt.Errorf("AES encryption failed: %w", err)
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -2649,7 +4112,7 @@ func AESEncryptAndWriteBlock(readerName string, block int, data, aesKey, authKey
 	// Write encrypted data: FF D6 00 [block] 10 [16 bytes]
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(block), 0x10}
 	writeCmd = append(writeCmd, encrypted...)
-	rsp, err := card.Transmit(writeCmd)
+	rsp, err := transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write block %d: %w", block, err)
 	}
@@ -2677,7 +4140,9 @@ func AESEncryptAndWriteBlock(readerName string, block int, data, aesKey, authKey
 // If accessBits is nil, the existing access bits are preserved.
 // If accessBits is 3 bytes, a 0x00 user data byte is appended.
 // If accessBits is 4 bytes, it's used as-is.
-func WriteSectorTrailer(readerName string, block int, keyA, keyB, accessBits, authKey []byte, authKeyType byte) error {
+func WriteSectorTrailer(readerName string, block int, keyA, keyB, accessBits, authKey []byte, authKeyType byte) (err error) {
+	defer trackOperation("write_mifare_sector_trailer", readerName, &err)()
+
 	if !isSectorTrailer(block) {
 		return fmt.Errorf("block %d is not a sector trailer", block)
 	}
@@ -2691,15 +4156,15 @@ func WriteSectorTrailer(readerName string, block int, keyA, keyB, accessBits, au
 		return fmt.Errorf("accessBits must be 3 or 4 bytes, got %d", len(accessBits))
 	}
 
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishContext()
 	if err != nil {
 		return fmt.Errorf("failed to establish context: %w", err)
 	}
 	defer ctx.Release()
 
-	card, err := ctx.Connect(readerName, scard.ShareShared, scard.ProtocolAny)
+	card, err := connectCard(ctx, readerName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to reader: %w", err)
+		return err
 	}
 	defer card.Disconnect(scard.LeaveCard)
 
@@ -2727,7 +4192,7 @@ func WriteSectorTrailer(readerName string, block int, keyA, keyB, accessBits, au
 	} else {
 		// Read current sector trailer to preserve existing access bits
 		readCmd := []byte{0xFF, 0xB0, 0x00, byte(block), 0x10}
-		rsp, err := card.Transmit(readCmd)
+		rsp, err := transmit(card, readCmd)
 		if err != nil {
 			return fmt.Errorf("failed to read sector trailer: %w", err)
 		}
@@ -2748,7 +4213,7 @@ func WriteSectorTrailer(readerName string, block int, keyA, keyB, accessBits, au
 	// Write new sector trailer
 	writeCmd := []byte{0xFF, 0xD6, 0x00, byte(block), 0x10}
 	writeCmd = append(writeCmd, newTrailer...)
-	rsp, err := card.Transmit(writeCmd)
+	rsp, err := transmit(card, writeCmd)
 	if err != nil {
 		return fmt.Errorf("failed to write sector trailer: %w", err)
 	}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -453,6 +454,127 @@ func TestICodeSLIXDetection(t *testing.T) {
 	}
 }
 
+// TestParseATQASAK tests extraction of ATQA/SAK from GET DATA responses
+// apduResponder answers APDUs with a function, for driving detectCardType.
+type apduResponder func(cmd []byte) []byte
+
+func (f apduResponder) Transmit(cmd []byte) ([]byte, error) {
+	return f(cmd), nil
+}
+
+func TestDetectCardType_Diagnostics(t *testing.T) {
+	unknown := apduResponder(func(cmd []byte) []byte {
+		switch {
+		case len(cmd) == 7 && cmd[5] == 0x60: // GET_VERSION (1a)
+			return []byte{0x6A, 0x81}
+		case len(cmd) == 6 && cmd[5] == 0x60: // GET_VERSION (1b)
+			return []byte{0x67, 0x00}
+		case cmd[1] == 0xB0 && cmd[3] == 0x01: // Pages 1-4
+			rsp := make([]byte, 16)
+			copy(rsp[8:], []byte{0xAB, 0x10, 0x12, 0x00})
+			return append(rsp, 0x90, 0x00)
+		}
+		return []byte{0x63, 0x00}
+	})
+
+	cardInfo := &Card{}
+	detectCardType(unknown, cardInfo)
+	if cardInfo.Type != cardTypeUnknown {
+		t.Fatalf("Type = %q, want %q", cardInfo.Type, cardTypeUnknown)
+	}
+	d := cardInfo.Diagnostics
+	if d == nil {
+		t.Fatal("expected diagnostics for an unknown card")
+	}
+	if d.GetVersion != "6700" {
+		t.Errorf("GetVersion = %q, want 6700", d.GetVersion)
+	}
+	if d.CC != "ab101200" {
+		t.Errorf("CC = %q, want ab101200", d.CC)
+	}
+	if got := strings.Join(d.Methods, ","); got != "1a,1b,2a,2b" {
+		t.Errorf("Methods = %s, want 1a,1b,2a,2b", got)
+	}
+
+	ntag213 := apduResponder(func(cmd []byte) []byte {
+		if len(cmd) == 7 && cmd[5] == 0x60 {
+			return []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x0F, 0x03, 0x90, 0x00}
+		}
+		return []byte{0x63, 0x00}
+	})
+
+	cardInfo = &Card{}
+	detectCardType(ntag213, cardInfo)
+	if cardInfo.Type != "NTAG213" {
+		t.Fatalf("Type = %q, want NTAG213", cardInfo.Type)
+	}
+	if cardInfo.Diagnostics != nil {
+		t.Errorf("recognised cards should have no diagnostics, got %+v", cardInfo.Diagnostics)
+	}
+}
+
+func TestDetectCardType_DetectionMethod(t *testing.T) {
+	tests := []struct {
+		name       string
+		respond    apduResponder
+		method     string
+		confidence string
+	}{
+		{"GET_VERSION", func(cmd []byte) []byte {
+			if len(cmd) == 7 && cmd[5] == 0x60 {
+				return []byte{0x00, 0x04, 0x04, 0x02, 0x01, 0x00, 0x11, 0x03, 0x90, 0x00}
+			}
+			return []byte{0x63, 0x00}
+		}, "GET_VERSION-1a", "high"},
+		{"capability container", func(cmd []byte) []byte {
+			if cmd[1] == 0xB0 && cmd[3] == 0x03 {
+				rsp := make([]byte, 16)
+				copy(rsp, []byte{0xE1, 0x10, 0x3E, 0x00})
+				return append(rsp, 0x90, 0x00)
+			}
+			return []byte{0x63, 0x00}
+		}, "CC-2b", "medium"},
+		{"nothing matched", func(cmd []byte) []byte { return []byte{0x63, 0x00} }, "none", "low"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cardInfo := &Card{}
+			detectCardType(tt.respond, cardInfo)
+			if cardInfo.DetectionMethod != tt.method || cardInfo.Confidence != tt.confidence {
+				t.Errorf("got %s/%s (type %q), want %s/%s", cardInfo.DetectionMethod, cardInfo.Confidence, cardInfo.Type, tt.method, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestParseATQASAK(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		expectedATQA string
+		expectedSAK  string
+	}{
+		{"MIFARE Classic 1K", []byte{0x00, 0x04, 0x08}, "0004", "08"},
+		{"NTAG213", []byte{0x00, 0x44, 0x00}, "0044", "00"},
+		{"MIFARE Classic 4K", []byte{0x00, 0x02, 0x18}, "0002", "18"},
+		{"empty response", []byte{}, "", ""},
+		{"historical bytes", []byte{0x80, 0x4f, 0x0c, 0xa0}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atqa, sak := parseATQASAK(tt.data)
+			if atqa != tt.expectedATQA {
+				t.Errorf("expected ATQA %q, got %q", tt.expectedATQA, atqa)
+			}
+			if sak != tt.expectedSAK {
+				t.Errorf("expected SAK %q, got %q", tt.expectedSAK, sak)
+			}
+		})
+	}
+}
+
 // hexEncodeString is a helper to encode bytes to hex string
 func hexEncodeString(b []byte) string {
 	const hexChars = "0123456789abcdef"
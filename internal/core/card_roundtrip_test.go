@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+// simulatedNTAG is an in-memory Type 2 tag answering the raw WRITE and READ
+// BINARY commands used by writeNTAGPages and readNTAGPage.
+type simulatedNTAG struct {
+	pages    [][4]byte
+	lastUser int          // Last user memory page; writes past it are NAKed
+	written  map[int]bool // Pages written so far
+}
+
+func newSimulatedNTAG216() *simulatedNTAG {
+	return &simulatedNTAG{
+		pages:    make([][4]byte, 231),
+		lastUser: 225,
+		written:  make(map[int]bool),
+	}
+}
+
+func (s *simulatedNTAG) Transmit(cmd []byte) ([]byte, error) {
+	switch {
+	case len(cmd) == 6 && cmd[0] == 0xA2: // WRITE page
+		page := int(cmd[1])
+		if page < 4 || page > s.lastUser {
+			return []byte{0x00}, nil // NAK
+		}
+		copy(s.pages[page][:], cmd[2:6])
+		s.written[page] = true
+		return []byte{0x0A}, nil
+	case len(cmd) == 5 && cmd[0] == 0xFF && cmd[1] == 0xB0: // READ BINARY, one page
+		page := int(cmd[3])
+		if page >= len(s.pages) {
+			return []byte{0x6A, 0x82}, nil
+		}
+		return append(s.pages[page][:], 0x90, 0x00), nil
+	}
+	return []byte{0x6A, 0x81}, nil
+}
+
+// readUserMemory reads every user page back, as readNDEFData does for NTAGs.
+func (s *simulatedNTAG) readUserMemory(t *testing.T) []byte {
+	t.Helper()
+	var data []byte
+	for page := 4; page <= s.lastUser; page++ {
+		pageData, err := readNTAGPage(s, page)
+		if err != nil {
+			t.Fatalf("readNTAGPage(%d) failed: %v", page, err)
+		}
+		data = append(data, pageData...)
+		if ndefReadComplete(data) {
+			break
+		}
+	}
+	return data
+}
+
+func TestNTAG216_LargeBinaryRoundTrip(t *testing.T) {
+	payload := make([]byte, 800)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	tlv := createNDEFMimeRecord("application/octet-stream", payload)
+
+	cardInfo := &Card{Type: "NTAG216"}
+	if err := checkUserMemory(cardInfo, 4, len(tlv)); err != nil {
+		t.Fatalf("800-byte payload should fit an NTAG216: %v", err)
+	}
+
+	tag := newSimulatedNTAG216()
+	if err := writeNTAGPages(tag, 4, tlv); err != nil {
+		t.Fatalf("writeNTAGPages failed: %v", err)
+	}
+
+	data := tag.readUserMemory(t)
+	start, length, _, state := locateNDEFTLV(data)
+	if state != ndefTLVFound {
+		t.Fatalf("NDEF TLV not found in read-back data (state %v)", state)
+	}
+
+	readBack := &Card{}
+	parseNDEFRecords(data[start:start+length], readBack)
+	if readBack.NDEFMalformed {
+		t.Fatalf("read-back NDEF malformed: %s", readBack.NDEFMalformedReason)
+	}
+	if len(readBack.Records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(readBack.Records))
+	}
+	if got := readBack.Records[0].Payload; !bytes.Equal(got, payload) {
+		t.Errorf("payload differs after round trip (got %d bytes, want %d)", len(got), len(payload))
+	}
+}
+
+func TestNTAG216_FillsAllUserPages(t *testing.T) {
+	// 888 bytes of user memory: 4-byte TLV header + record + terminator
+	const userBytes = (225 - 4 + 1) * 4
+	recordOverhead := 1 + 1 + 4 + len("application/octet-stream")
+	payload := bytes.Repeat([]byte{0x5A}, userBytes-4-recordOverhead-1)
+	tlv := createNDEFMimeRecord("application/octet-stream", payload)
+	if len(tlv) != userBytes {
+		t.Fatalf("test setup: TLV is %d bytes, want %d", len(tlv), userBytes)
+	}
+
+	cardInfo := &Card{Type: "NTAG216"}
+	if err := checkUserMemory(cardInfo, 4, len(tlv)); err != nil {
+		t.Fatalf("exactly full user memory should be accepted: %v", err)
+	}
+	if err := checkUserMemory(cardInfo, 4, len(tlv)+1); err == nil {
+		t.Error("one byte past user memory should be rejected")
+	}
+
+	tag := newSimulatedNTAG216()
+	if err := writeNTAGPages(tag, 4, tlv); err != nil {
+		t.Fatalf("writeNTAGPages failed: %v", err)
+	}
+	for page := 4; page <= 225; page++ {
+		if !tag.written[page] {
+			t.Fatalf("page %d was not written", page)
+		}
+	}
+	if tag.written[226] {
+		t.Error("dynamic lock page 226 must not be written")
+	}
+	if last := tag.pages[225]; last[3] != 0xFE {
+		t.Errorf("last user page should end with the terminator TLV, got %X", last)
+	}
+}
+
+func TestCreateNDEFRecord_LengthBoundaries(t *testing.T) {
+	mime := "application/octet-stream"
+	tests := []struct {
+		name        string
+		payloadLen  int
+		wantSR      bool
+		wantLongTLV bool
+	}{
+		{"short record, short TLV", 200, true, false},
+		{"short record at 255 bytes", 255, true, true},
+		{"long record at 256 bytes", 256, false, true},
+		{"long record, 800 bytes", 800, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlv := createNDEFMimeRecord(mime, make([]byte, tt.payloadLen))
+
+			headerLen := 2
+			recordLen := int(tlv[1])
+			if tlv[1] == 0xFF {
+				headerLen = 4
+				recordLen = int(tlv[2])<<8 | int(tlv[3])
+			}
+			if (headerLen == 4) != tt.wantLongTLV {
+				t.Errorf("3-byte TLV length = %v, want %v", headerLen == 4, tt.wantLongTLV)
+			}
+			if headerLen+recordLen+1 != len(tlv) || tlv[len(tlv)-1] != 0xFE {
+				t.Errorf("TLV length %d doesn't match %d-byte TLV", recordLen, len(tlv))
+			}
+
+			record := tlv[headerLen : headerLen+recordLen]
+			sr := record[0]&0x10 != 0
+			if sr != tt.wantSR {
+				t.Errorf("SR flag = %v, want %v", sr, tt.wantSR)
+			}
+			gotLen := int(record[2])
+			if !sr {
+				gotLen = int(record[2])<<24 | int(record[3])<<16 | int(record[4])<<8 | int(record[5])
+			}
+			if gotLen != tt.payloadLen {
+				t.Errorf("encoded payload length %d, want %d", gotLen, tt.payloadLen)
+			}
+		})
+	}
+}
@@ -1,7 +1,17 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
 )
 
 // Mock data from real NFC tags read from hardware:
@@ -570,6 +580,874 @@ func TestURIPrefixRoundtrip(t *testing.T) {
 	}
 }
 
+func TestValidateNDEFRecords(t *testing.T) {
+	tooMany := make([]NDEFRecord, MaxNDEFRecords+1)
+	for i := range tooMany {
+		tooMany[i] = NDEFRecord{Type: "text", Data: "x"}
+	}
+
+	tests := []struct {
+		name    string
+		records []NDEFRecord
+		wantErr bool
+	}{
+		{"single record", []NDEFRecord{{Type: "url", Data: "https://example.com"}}, false},
+		{"too many records", tooMany, true},
+		{"oversized text", []NDEFRecord{{Type: "text", Data: strings.Repeat("a", MaxNDEFPayloadBytes+1)}}, true},
+		{"oversized hex", []NDEFRecord{{Type: "binary", Data: strings.Repeat("ab", MaxNDEFPayloadBytes+1)}}, true},
+		{"hex at limit", []NDEFRecord{{Type: "binary", Data: strings.Repeat("ab", MaxNDEFPayloadBytes)}}, false},
+		{"oversized base64", []NDEFRecord{{Type: "mime", MimeType: "a/b", DataType: "binary", Data: strings.Repeat("QUJD", MaxNDEFPayloadBytes/3+1)}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNDEFRecords(tt.records)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNDEFLimitExceeded) {
+					t.Errorf("expected ErrNDEFLimitExceeded, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if err := ValidateNDEFRecords([]NDEFRecord{{Type: "text", Data: "caf\xe9"}}); err == nil || errors.Is(err, ErrNDEFLimitExceeded) {
+		t.Errorf("expected a UTF-8 error for invalid text, got %v", err)
+	}
+}
+
+func TestSetNDEFLimits(t *testing.T) {
+	origRecords, origPayload := MaxNDEFRecords, MaxNDEFPayloadBytes
+	defer func() {
+		MaxNDEFRecords, MaxNDEFPayloadBytes = origRecords, origPayload
+	}()
+
+	SetNDEFLimits(2, 0)
+	if MaxNDEFRecords != 2 {
+		t.Errorf("expected MaxNDEFRecords 2, got %d", MaxNDEFRecords)
+	}
+	if MaxNDEFPayloadBytes != origPayload {
+		t.Errorf("expected MaxNDEFPayloadBytes unchanged, got %d", MaxNDEFPayloadBytes)
+	}
+
+	records := []NDEFRecord{{Type: "text", Data: "a"}, {Type: "text", Data: "b"}, {Type: "text", Data: "c"}}
+	if err := WriteMultipleRecords("Nonexistent Reader", records); !errors.Is(err, ErrNDEFLimitExceeded) {
+		t.Errorf("expected WriteMultipleRecords to reject records before connecting, got %v", err)
+	}
+}
+
+func TestWriteUltralightPagesWithRollback_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []UltralightPageWrite
+	}{
+		{"no pages", nil},
+		{"system page", []UltralightPageWrite{{Page: 2, Data: []byte{0, 0, 0, 0}}}},
+		{"short data", []UltralightPageWrite{{Page: 4, Data: []byte{0, 0}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, rollback, err := WriteUltralightPagesWithRollback("Nonexistent Reader", tt.pages, nil, true)
+			if err == nil {
+				t.Error("expected validation error")
+			}
+			if results != nil || rollback != nil {
+				t.Error("expected no results or rollback on validation failure")
+			}
+		})
+	}
+}
+
+func TestWriteUltralightBatch_RollsBackOnFailure(t *testing.T) {
+	pages := map[int][]byte{
+		4: {0x01, 0x01, 0x01, 0x01},
+		5: {0x02, 0x02, 0x02, 0x02},
+		6: {0x03, 0x03, 0x03, 0x03},
+	}
+	tag := apduResponder(func(cmd []byte) []byte {
+		switch {
+		case len(cmd) == 5 && cmd[0] == 0xFF && cmd[1] == 0xB0:
+			return append(append([]byte(nil), pages[int(cmd[3])]...), 0x90, 0x00)
+		case len(cmd) == 9 && cmd[0] == 0xFF && cmd[1] == 0xD6 && cmd[3] != 5: // Page 5 fails
+			pages[int(cmd[3])] = append([]byte(nil), cmd[5:]...)
+			return []byte{0x90, 0x00}
+		}
+		return []byte{0x6A, 0x81}
+	})
+
+	writes := []UltralightPageWrite{
+		{Page: 4, Data: []byte{0xAA, 0xAA, 0xAA, 0xAA}},
+		{Page: 5, Data: []byte{0xBB, 0xBB, 0xBB, 0xBB}},
+		{Page: 6, Data: []byte{0xCC, 0xCC, 0xCC, 0xCC}},
+	}
+	results, rollback, err := writeUltralightBatch(context.Background(), tag, writes, true, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !results[0].Success || results[1].Success || results[1].Error == "" || results[2].Error != "skipped after earlier failure" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if rollback == nil || !rollback.Success || len(rollback.RestoredPages) != 1 || rollback.RestoredPages[0] != 4 {
+		t.Fatalf("expected page 4 restored, got %+v", rollback)
+	}
+	if !bytes.Equal(pages[4], []byte{0x01, 0x01, 0x01, 0x01}) || !bytes.Equal(pages[6], []byte{0x03, 0x03, 0x03, 0x03}) {
+		t.Errorf("expected the original pages back, got %X and %X", pages[4], pages[6])
+	}
+}
+
+func TestWriteUltralightPageMethod_ShortReply(t *testing.T) {
+	for _, reply := range [][]byte{{}, {0x63}} {
+		tag := apduResponder(func(cmd []byte) []byte { return reply })
+		if _, err := writeUltralightPageMethod(tag, UltralightPageWrite{Page: 4, Data: []byte{1, 2, 3, 4}}); err == nil {
+			t.Errorf("reply % X: expected an error", reply)
+		}
+	}
+}
+
+func TestVerifyUltralightPage(t *testing.T) {
+	page := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	tag := apduResponder(func(cmd []byte) []byte {
+		if bytes.Equal(cmd, []byte{0xFF, 0xB0, 0x00, 0x04, 0x04}) {
+			return append(append([]byte(nil), page...), 0x90, 0x00)
+		}
+		return []byte{0x6A, 0x81}
+	})
+
+	actual, err := verifyUltralightPage(tag, UltralightPageWrite{Page: 4, Data: []byte{0xDE, 0xAD, 0xBE, 0xEF}})
+	if err != nil || actual != "deadbeef" {
+		t.Errorf("matching page: got %q, %v", actual, err)
+	}
+
+	page[3] = 0x00
+	actual, err = verifyUltralightPage(tag, UltralightPageWrite{Page: 4, Data: []byte{0xDE, 0xAD, 0xBE, 0xEF}})
+	if err == nil || !strings.Contains(err.Error(), "verify mismatch") || actual != "deadbe00" {
+		t.Errorf("mismatched page: got %q, %v", actual, err)
+	}
+
+	if _, err := verifyUltralightPage(tag, UltralightPageWrite{Page: 5, Data: []byte{0, 0, 0, 0}}); err == nil {
+		t.Error("expected an error when the page cannot be read back")
+	}
+}
+
+func TestBuildNDEFRecordsTLV_EmptyAndUnknown(t *testing.T) {
+	tlv, err := buildNDEFRecordsTLV([]NDEFRecord{
+		{Type: "empty"},
+		{Type: "unknown", Data: "AQID", DataType: "binary"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x03, 0x09, // TLV header
+		0x90, 0x00, 0x00, // MB + SR, TNF 0x00, no type, no payload
+		0x55, 0x00, 0x03, 0x01, 0x02, 0x03, // ME + SR, TNF 0x05, no type, 3-byte payload
+		0xFE,
+	}
+	if string(tlv) != string(expected) {
+		t.Errorf("expected % X, got % X", expected, tlv)
+	}
+
+	if _, err := buildNDEFRecordsTLV([]NDEFRecord{{Type: "empty", Data: "x"}}); err == nil {
+		t.Error("expected error for empty record with data")
+	}
+}
+
+func TestBuildNDEFRecordsTLV_OpenPrintTag(t *testing.T) {
+	tlv, err := buildNDEFRecordsTLV([]NDEFRecord{
+		{Type: "url", Data: "https://example.com/spool/1"},
+		{Type: "openprinttag", Data: `{"materialName":"PLA","brandName":"TestBrand","materialType":0,"nominalWeight":1000}`},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Strip TLV header and terminator, then parse the message back
+	card := &Card{}
+	parseNDEFRecords(tlv[2:len(tlv)-1], card)
+
+	if len(card.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(card.Records))
+	}
+	if card.Records[0].DataType != "url" {
+		t.Errorf("expected first record to be url, got %s", card.Records[0].DataType)
+	}
+	if card.Records[1].DataType != "openprinttag" || card.Records[1].Type != openprinttag.MIMEType {
+		t.Errorf("expected openprinttag record, got %s (%s)", card.Records[1].DataType, card.Records[1].Type)
+	}
+	if !strings.Contains(card.Records[1].Data, "TestBrand") {
+		t.Errorf("expected decoded openprinttag data, got %s", card.Records[1].Data)
+	}
+
+	if _, err := buildNDEFRecordsTLV([]NDEFRecord{{Type: "openprinttag", Data: "not json"}}); err == nil {
+		t.Error("expected error for invalid openprinttag JSON")
+	}
+}
+
+func TestParseNDEFRecords_Malformed(t *testing.T) {
+	text := func(mb, me bool) []byte {
+		return createNDEFRecordRaw(0x01, []byte("T"), []byte{0x02, 'e', 'n', 'h', 'i'}, mb, me)
+	}
+	join := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		message   []byte
+		malformed bool
+		records   int
+	}{
+		{"well formed", join(text(true, false), text(false, true)), false, 2},
+		{"missing MB", join(text(false, false), text(false, true)), true, 2},
+		{"MB on second record", join(text(true, false), text(true, true)), true, 2},
+		{"missing ME", join(text(true, false), text(false, false)), true, 2},
+		{"second message", join(text(true, true), text(true, true)), true, 1},
+		{"truncated record", join(text(true, false), text(false, true)[:4]), true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &Card{}
+			parseNDEFRecords(tt.message, card)
+
+			if card.NDEFMalformed != tt.malformed {
+				t.Errorf("NDEFMalformed = %v, want %v (reason %q)", card.NDEFMalformed, tt.malformed, card.NDEFMalformedReason)
+			}
+			if tt.malformed && card.NDEFMalformedReason == "" {
+				t.Error("expected a malformed reason")
+			}
+			if len(card.Records) != tt.records {
+				t.Errorf("expected %d records, got %d", tt.records, len(card.Records))
+			}
+			if card.Data != "hi" {
+				t.Errorf("expected text to still be extracted, got %q", card.Data)
+			}
+		})
+	}
+}
+
+func TestParseNDEFRecords_RawBytes(t *testing.T) {
+	first := createNDEFRecordRaw(0x01, []byte("U"), []byte{0x04, 'a', '.', 'b'}, true, false)
+	second := createNDEFRecordRaw(0x01, []byte("T"), []byte{0x02, 'e', 'n', 'h', 'i'}, false, true)
+	message := append(append([]byte(nil), first...), second...)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	if len(card.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(card.Records))
+	}
+	if !bytes.Equal(card.Records[0].RawBytes, first) || !bytes.Equal(card.Records[1].RawBytes, second) {
+		t.Errorf("raw record bytes mismatch: %X / %X", card.Records[0].RawBytes, card.Records[1].RawBytes)
+	}
+	if card.Records[0].Raw != "" {
+		t.Error("Raw hex should only be set when requested")
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	tests := map[string]string{
+		"application/json":                 "application/json",
+		"application/json; charset=utf-8":  "application/json",
+		"Application/JSON;charset=UTF-8":   "application/json",
+		" application/octet-stream ; q=1 ": "application/octet-stream",
+		"application/vnd.openprinttag;v=1": "application/vnd.openprinttag",
+		"":                                 "",
+	}
+	for input, want := range tests {
+		if got := MediaType(input); got != want {
+			t.Errorf("MediaType(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseNDEFRecords_MimeParameters(t *testing.T) {
+	message := createNDEFRecordRaw(0x02, []byte("application/json; charset=utf-8"), []byte(`{"a":1}`), true, true)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	if len(card.Records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(card.Records))
+	}
+	rec := card.Records[0]
+	if rec.DataType != "json" || rec.Data != `{"a":1}` {
+		t.Errorf("expected json record, got %q (%s)", rec.Data, rec.DataType)
+	}
+	if rec.Type != "application/json; charset=utf-8" {
+		t.Errorf("expected full type to be preserved, got %q", rec.Type)
+	}
+
+	if _, dataType := decodeMimePayload("APPLICATION/OCTET-STREAM; x=y", []byte{0x01}); dataType != "binary" {
+		t.Errorf("expected binary for octet-stream with parameters, got %s", dataType)
+	}
+}
+
+func TestParseNDEFRecords_EdgeCases(t *testing.T) {
+	// Empty record, record with ID field, then a text record
+	message := []byte{0x90, 0x00, 0x00}
+	message = append(message, 0x19, 0x01, 0x01, 0x02, 'U', 'i', 'd', 0x00) // SR + IL, URI record with 2-byte ID
+	message = append(message, createNDEFRecordRaw(0x01, []byte("T"), []byte{0x02, 'e', 'n', 'h', 'i'}, false, true)...)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	if card.Data != "hi" || card.DataType != "text" {
+		t.Errorf("expected text 'hi', got %q (%s)", card.Data, card.DataType)
+	}
+
+	// Lone empty and unknown-TNF records must not produce data
+	card = &Card{}
+	parseNDEFRecords([]byte{0x90, 0x00, 0x00, 0x55, 0x00, 0x01, 0xAA}, card)
+	if card.Data != "" || card.URL != "" {
+		t.Errorf("expected no data, got %q / %q", card.Data, card.URL)
+	}
+}
+
+func TestParseNDEFRecords_TextLanguages(t *testing.T) {
+	textRecord := func(lang, text string, mb, me bool) []byte {
+		payload := append([]byte{byte(len(lang))}, lang...)
+		payload = append(payload, text...)
+		return createNDEFRecordRaw(0x01, []byte("T"), payload, mb, me)
+	}
+
+	message := textRecord("en", "Hello", true, false)
+	message = append(message, textRecord("de-DE", "Hallo", false, false)...)
+	message = append(message, textRecord("fr", "Bonjour", false, true)...)
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"", "Hello"},
+		{"fr", "Bonjour"},
+		{"FR", "Bonjour"},
+		{"de", "Hallo"},
+		{"es", "Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			card := &Card{}
+			parseNDEFRecords(message, card)
+			selectTextRecord(card, tt.lang)
+
+			if card.Data != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, card.Data)
+			}
+			if len(card.Records) != 3 {
+				t.Fatalf("expected 3 records, got %d", len(card.Records))
+			}
+			if card.Records[1].Lang != "de-DE" || card.Records[1].Data != "Hallo" {
+				t.Errorf("unexpected second record: %+v", card.Records[1])
+			}
+		})
+	}
+}
+
+func TestIncrementTagCounter_InvalidPage(t *testing.T) {
+	for _, page := range []int{-1, 0, 3, 256} {
+		if _, err := IncrementTagCounter("Nonexistent Reader", page, nil); err == nil {
+			t.Errorf("expected error for page %d", page)
+		}
+	}
+}
+
+func TestNDEFReadBudget(t *testing.T) {
+	defer func() { MaxNDEFReadPages = 0 }()
+
+	tests := []struct {
+		name     string
+		global   int
+		override int
+		expected int
+	}{
+		{"type default", 0, 0, 40},
+		{"global cap", 10, 0, 10},
+		{"override replaces global", 10, 20, 20},
+		{"override lowers budget", 0, 5, 5},
+		{"capped at type default", 0, 100, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxNDEFReadPages = tt.global
+			if got := ndefReadBudget(40, tt.override); got != tt.expected {
+				t.Errorf("ndefReadBudget(40, %d) = %d, want %d", tt.override, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNDEFReadComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{"nothing read", nil, false},
+		{"not an NDEF TLV", []byte{0x00, 0x00, 0x00, 0x00}, true},
+		{"empty tag terminator", []byte{0xFE, 0x00, 0x00, 0x00}, true},
+		{"short TLV incomplete", []byte{0x03, 0x08, 0xD1, 0x01}, false},
+		{"short TLV complete", []byte{0x03, 0x02, 0xD0, 0x00}, true},
+		{"long TLV header incomplete", []byte{0x03, 0xFF, 0x01}, false},
+		{"long TLV incomplete", []byte{0x03, 0xFF, 0x01, 0x00, 0xD1}, false},
+		{"long TLV complete", append([]byte{0x03, 0xFF, 0x00, 0x02}, 0xD0, 0x00), true},
+		{"lock control then incomplete NDEF", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x08, 0xD1}, false},
+		{"lock control then complete NDEF", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x02, 0xD0, 0x00}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ndefReadComplete(tt.data); got != tt.expected {
+				t.Errorf("ndefReadComplete(%X) = %v, want %v", tt.data, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAllNDEFReadComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{"nothing read", nil, false},
+		{"first TLV complete, nothing after", []byte{0x03, 0x02, 0xD0, 0x00}, false},
+		{"first TLV then terminator", []byte{0x03, 0x02, 0xD0, 0x00, 0xFE}, true},
+		{"second TLV incomplete", []byte{0x03, 0x02, 0xD0, 0x00, 0x03, 0x08, 0xD1}, false},
+		{"two TLVs then terminator", []byte{0x03, 0x02, 0xD0, 0x00, 0x03, 0x02, 0xD0, 0x00, 0xFE}, true},
+		{"zero padding after TLV", []byte{0x03, 0x02, 0xD0, 0x00, 0x00, 0x00, 0x00, 0x00}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allNDEFReadComplete(tt.data); got != tt.expected {
+				t.Errorf("allNDEFReadComplete(%X) = %v, want %v", tt.data, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseExtraNDEFMessages(t *testing.T) {
+	first := createNDEFRecordRaw(0x01, []byte("T"), append([]byte{0x02, 'e', 'n'}, "one"...), true, true)
+	second := createNDEFRecordRaw(0x01, []byte("U"), append([]byte{0x04}, "example.com"...), true, true)
+	firstTLV := wrapNDEFTLV(first)
+	data := append(firstTLV[:len(firstTLV)-1], wrapNDEFTLV(second)...) // No terminator in between
+
+	card := &Card{}
+	start, length, _, _ := locateNDEFTLV(data)
+	parseNDEFRecords(data[start:start+length], card)
+	parseExtraNDEFMessages(data[start+length:], card)
+
+	if !card.MultipleNDEFMessages || len(card.ExtraNDEFMessages) != 1 {
+		t.Fatalf("expected one extra message, got %+v", card.ExtraNDEFMessages)
+	}
+	if len(card.Records) != 1 || card.Records[0].Data != "one" {
+		t.Errorf("first message records changed: %+v", card.Records)
+	}
+	if recs := card.ExtraNDEFMessages[0]; len(recs) != 1 || recs[0].Data != "https://example.com" {
+		t.Errorf("unexpected extra message records: %+v", recs)
+	}
+	if card.URL != "" {
+		t.Errorf("extra messages must not change the top-level fields, got URL %q", card.URL)
+	}
+
+	// A single message is not flagged
+	card = &Card{}
+	parseExtraNDEFMessages([]byte{0xFE}, card)
+	if card.MultipleNDEFMessages {
+		t.Error("terminator alone should not be an extra message")
+	}
+}
+
+func TestWrapNDEFTLV(t *testing.T) {
+	short := wrapNDEFTLV([]byte{0xD0, 0x00, 0x00})
+	if !bytes.Equal(short, []byte{0x03, 0x03, 0xD0, 0x00, 0x00, 0xFE}) {
+		t.Errorf("unexpected short TLV: %X", short)
+	}
+
+	long := wrapNDEFTLV(make([]byte, 300))
+	if !bytes.Equal(long[:4], []byte{0x03, 0xFF, 0x01, 0x2C}) {
+		t.Errorf("unexpected long TLV header: %X", long[:4])
+	}
+	if len(long) != 4+300+1 || long[len(long)-1] != 0xFE {
+		t.Errorf("unexpected long TLV length %d or terminator", len(long))
+	}
+}
+
+func TestWriteRawNDEF_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"too short", []byte{0xD0}},
+		{"missing MB flag", []byte{0x50, 0x00, 0x00}},
+		{"too large", append([]byte{0xD2}, make([]byte, MaxNDEFPayloadBytes)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := WriteRawNDEF("Nonexistent Reader", tt.message); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+// fakeWritePage is an in-memory page for exercising runWriteTest.
+type fakeWritePage struct {
+	data      []byte
+	writes    [][]byte
+	failWrite int // 1-based write number that fails (0 = never)
+	stuck     bool
+}
+
+func (p *fakeWritePage) read() ([]byte, error) {
+	return append([]byte(nil), p.data...), nil
+}
+
+func (p *fakeWritePage) write(data []byte) (string, error) {
+	p.writes = append(p.writes, append([]byte(nil), data...))
+	if len(p.writes) == p.failWrite {
+		return "", errors.New("write rejected")
+	}
+	if !p.stuck {
+		p.data = append([]byte(nil), data...)
+	}
+	return "update_binary", nil
+}
+
+func TestRunWriteTest(t *testing.T) {
+	t.Run("success restores original", func(t *testing.T) {
+		page := &fakeWritePage{data: []byte{0x01, 0x02, 0x03, 0x04}}
+		result, err := runWriteTest(4, page.read, page.write)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Method != "update_binary" || !result.Restored {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if !bytes.Equal(page.data, []byte{0x01, 0x02, 0x03, 0x04}) {
+			t.Errorf("page not restored: %X", page.data)
+		}
+		if !bytes.Equal(page.writes[0], writeTestPattern) {
+			t.Errorf("expected test pattern, wrote %X", page.writes[0])
+		}
+	})
+
+	t.Run("pattern inverted when page already holds it", func(t *testing.T) {
+		page := &fakeWritePage{data: append([]byte(nil), writeTestPattern...)}
+		if _, err := runWriteTest(4, page.read, page.write); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bytes.Equal(page.writes[0], writeTestPattern) {
+			t.Error("expected inverted pattern")
+		}
+	})
+
+	t.Run("write failure", func(t *testing.T) {
+		page := &fakeWritePage{data: make([]byte, 16), failWrite: 1}
+		result, err := runWriteTest(4, page.read, page.write)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if result.Method != "" || len(page.writes) != 1 {
+			t.Errorf("unexpected result %+v after %d writes", result, len(page.writes))
+		}
+	})
+
+	t.Run("verification failure still restores", func(t *testing.T) {
+		page := &fakeWritePage{data: []byte{0x01, 0x02, 0x03, 0x04}, stuck: true}
+		result, err := runWriteTest(4, page.read, page.write)
+		if err == nil {
+			t.Fatal("expected verification error")
+		}
+		if len(page.writes) != 2 || !result.Restored {
+			t.Errorf("expected restore attempt, got %+v after %d writes", result, len(page.writes))
+		}
+	})
+
+	t.Run("restore failure", func(t *testing.T) {
+		page := &fakeWritePage{data: []byte{0x01, 0x02, 0x03, 0x04}, failWrite: 2}
+		result, err := runWriteTest(4, page.read, page.write)
+		if err == nil {
+			t.Fatal("expected restore error")
+		}
+		if result.Restored {
+			t.Error("expected Restored to be false")
+		}
+	})
+}
+
+func TestCheckUserMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    *Card
+		dataLen int
+		wantErr bool
+	}{
+		{"NTAG213 fits", &Card{Type: "NTAG213"}, 144, false},
+		{"NTAG213 reaches dynamic lock page", &Card{Type: "NTAG213"}, 145, true},
+		{"NTAG215 fits", &Card{Type: "NTAG215"}, 504, false},
+		{"NTAG215 too large", &Card{Type: "NTAG215"}, 505, true},
+		{"NTAG216 fits", &Card{Type: "NTAG216"}, 888, false},
+		{"NTAG216 reaches page 226", &Card{Type: "NTAG216"}, 889, true},
+		{"Ultralight EV1 MF0UL21", &Card{Type: "MIFARE Ultralight EV1", Size: 128}, 128, false},
+		{"Ultralight EV1 MF0UL11", &Card{Type: "MIFARE Ultralight EV1", Size: 48}, 49, true},
+		{"unknown type not checked", &Card{Type: "NFC Tag (type unknown)"}, 4096, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUserMemory(tt.card, 4, tt.dataLen)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkUserMemory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrExceedsUserMemory) {
+				t.Errorf("expected ErrExceedsUserMemory, got %v", err)
+			}
+		})
+	}
+}
+
+func TestZeroFillUserMemory(t *testing.T) {
+	empty := []byte{0x03, 0x00, 0xFE, 0x00}
+	data, err := zeroFillUserMemory(&Card{Type: "NTAG213"}, empty)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(data) != 144 || !bytes.Equal(data[:4], empty) || !bytes.Equal(data[4:], make([]byte, 140)) {
+		t.Errorf("expected empty NDEF followed by zeros up to 144 bytes, got %d bytes: % X", len(data), data[:8])
+	}
+	if err := checkUserMemory(&Card{Type: "NTAG213"}, 4, len(data)); err != nil {
+		t.Errorf("zero fill must stay within user memory: %v", err)
+	}
+
+	if _, err := zeroFillUserMemory(&Card{Type: "MIFARE Classic 1K"}, empty); err == nil {
+		t.Error("expected error for a card without a known user memory range")
+	}
+}
+
+func TestCCReadOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		cc   []byte
+		want bool
+	}{
+		{"read/write", []byte{0xE1, 0x10, 0x3E, 0x00}, false},
+		{"read-only", []byte{0xE1, 0x10, 0x3E, 0x0F}, true},
+		{"read-only, read access nibble set", []byte{0xE1, 0x10, 0x12, 0xFF}, true},
+		{"not NDEF formatted", []byte{0x00, 0x00, 0x00, 0x0F}, false},
+		{"too short", []byte{0xE1, 0x10, 0x3E}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ccReadOnly(tt.cc); got != tt.want {
+				t.Errorf("ccReadOnly(% X) = %v, want %v", tt.cc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNDEFWritable(t *testing.T) {
+	cardInfo := &Card{Type: "NTAG215"}
+	applyCCAccess(cardInfo, []byte{0xE1, 0x10, 0x3E, 0x00})
+	if err := checkNDEFWritable(cardInfo); err != nil {
+		t.Errorf("read/write tag rejected: %v", err)
+	}
+
+	applyCCAccess(cardInfo, []byte{0xE1, 0x10, 0x3E, 0x0F})
+	if !cardInfo.NDEFReadOnly {
+		t.Fatal("NDEFReadOnly not set for a read-only CC")
+	}
+	if err := checkNDEFWritable(cardInfo); !errors.Is(err, ErrNDEFReadOnly) {
+		t.Errorf("expected ErrNDEFReadOnly, got %v", err)
+	}
+}
+
+func TestMifareClassicBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     Card
+		override int
+		want     int
+	}{
+		{"1K default", Card{Size: 1024, SAK: "08"}, 0, 64},
+		{"4K by SAK", Card{Size: 1024, SAK: "18"}, 0, 256},
+		{"2K by SAK", Card{Size: 1024, SAK: "19"}, 0, 128},
+		{"Mini by size", Card{Size: 320}, 0, 20},
+		{"2K by size", Card{Size: 2048}, 0, 128},
+		{"unknown size", Card{}, 0, 64},
+		{"override", Card{Size: 1024, SAK: "08"}, 128, 128},
+		{"override capped", Card{}, 1000, MaxMifareBlocks},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mifareClassicBlocks(&tt.card, tt.override); got != tt.want {
+				t.Errorf("mifareClassicBlocks() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMifareDataBlocks(t *testing.T) {
+	tests := map[int]int{
+		20:  12,  // Mini: 4 sectors x 3 data blocks
+		64:  45,  // 1K: 15 sectors x 3 data blocks
+		128: 93,  // 2K: 31 sectors x 3 data blocks
+		256: 213, // 4K: 31 x 3 + 8 x 15
+	}
+	for blocks, want := range tests {
+		if got := mifareDataBlocks(blocks); got != want {
+			t.Errorf("mifareDataBlocks(%d) = %d, want %d", blocks, got, want)
+		}
+	}
+}
+
+func TestReadMifareBlocks_InvalidRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		start, count int
+	}{
+		{"negative start", -1, 1},
+		{"start past last block", 256, 1},
+		{"zero count", 4, 0},
+		{"range past last block", 250, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadMifareBlocks("Test Reader", tt.start, tt.count, nil, 'A'); err == nil {
+				t.Error("expected error for invalid block range")
+			}
+		})
+	}
+}
+
+func TestRunWriteTest_SettleDelay(t *testing.T) {
+	orig := WriteSettleDelay
+	WriteSettleDelay = 20 * time.Millisecond
+	t.Cleanup(func() { WriteSettleDelay = orig })
+
+	page := &fakeWritePage{data: []byte{0x01, 0x02, 0x03, 0x04}}
+	start := time.Now()
+	if _, err := runWriteTest(4, page.read, page.write); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Settles before the verify read and before the restore check
+	if elapsed := time.Since(start); elapsed < 2*WriteSettleDelay {
+		t.Errorf("expected at least %v of settle time, took %v", 2*WriteSettleDelay, elapsed)
+	}
+}
+
+func TestWaitBeforePageWrite(t *testing.T) {
+	orig := PageWriteDelay
+	PageWriteDelay = 20 * time.Millisecond
+	t.Cleanup(func() { PageWriteDelay = orig })
+
+	start := time.Now()
+	waitBeforePageWrite(0)
+	if elapsed := time.Since(start); elapsed >= PageWriteDelay {
+		t.Errorf("first page write should not wait, took %v", elapsed)
+	}
+
+	start = time.Now()
+	waitBeforePageWrite(1)
+	if elapsed := time.Since(start); elapsed < PageWriteDelay {
+		t.Errorf("expected to wait %v before later page writes, took %v", PageWriteDelay, elapsed)
+	}
+}
+
+func TestPreferRecord(t *testing.T) {
+	newCard := func() *Card {
+		return &Card{
+			URL:      "https://example.com",
+			Data:     "hello",
+			DataType: "text",
+			Records: []ParsedRecord{
+				{TNF: 0x01, Type: "U", Data: "https://example.com", DataType: "url"},
+				{TNF: 0x01, Type: "T", Data: "hello", DataType: "text"},
+				{TNF: 0x02, Type: "application/vnd.openprinttag", Data: `{"materialName":"PLA"}`, DataType: "openprinttag"},
+			},
+		}
+	}
+
+	tests := []struct {
+		prefer       string
+		wantData     string
+		wantDataType string
+	}{
+		{"", "hello", "text"},
+		{"openprinttag", `{"materialName":"PLA"}`, "openprinttag"},
+		{"url", "https://example.com", "url"},
+		{"text", "hello", "text"},
+		{"json", "hello", "text"}, // No matching record keeps the default
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefer, func(t *testing.T) {
+			card := newCard()
+			preferRecord(card, tt.prefer)
+			if card.Data != tt.wantData || card.DataType != tt.wantDataType {
+				t.Errorf("got %q (%s), want %q (%s)", card.Data, card.DataType, tt.wantData, tt.wantDataType)
+			}
+			if len(card.Records) != 3 {
+				t.Errorf("records must be kept, got %d", len(card.Records))
+			}
+		})
+	}
+}
+
+func TestEncodeBinaryData(t *testing.T) {
+	// An octet-stream record and a CBOR record that failed to decode
+	message := append(
+		createNDEFRecordRaw(0x02, []byte("application/octet-stream"), []byte{0x01, 0x02, 0xFF}, true, false),
+		createNDEFRecordRaw(0x02, []byte("application/cbor"), []byte{0xFF, 0xFE}, false, true)...,
+	)
+
+	for _, tt := range []struct {
+		encoding string
+		wantData string
+		wantRecs []string
+	}{
+		{"", "fffe", []string{"0102ff", "fffe"}},
+		{"hex", "fffe", []string{"0102ff", "fffe"}},
+		{"base64", "//4=", []string{"AQL/", "//4="}},
+	} {
+		t.Run(tt.encoding, func(t *testing.T) {
+			card := &Card{}
+			parseNDEFRecords(message, card)
+			encodeBinaryData(card, tt.encoding)
+
+			if card.Data != tt.wantData || card.DataType != "binary" {
+				t.Errorf("data = %q (%s), want %q (binary)", card.Data, card.DataType, tt.wantData)
+			}
+			if len(card.Records) != len(tt.wantRecs) {
+				t.Fatalf("got %d records, want %d", len(card.Records), len(tt.wantRecs))
+			}
+			for i, want := range tt.wantRecs {
+				if card.Records[i].Data != want {
+					t.Errorf("record %d data = %q, want %q", i, card.Records[i].Data, want)
+				}
+			}
+		})
+	}
+
+	if !IsBinaryEncoding("") || !IsBinaryEncoding("base64") || IsBinaryEncoding("base32") {
+		t.Error("IsBinaryEncoding accepts the wrong encodings")
+	}
+}
+
 // Benchmark tests
 func BenchmarkFindURIPrefix(b *testing.B) {
 	uri := "https://example.com/path/to/resource?query=value"
@@ -605,3 +1483,68 @@ func BenchmarkContains(b *testing.B) {
 		contains(s, substr)
 	}
 }
+
+func TestReadPlacedCard(t *testing.T) {
+	orig := CardSettleDelay
+	CardSettleDelay = time.Millisecond
+	t.Cleanup(func() { CardSettleDelay = orig })
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first read succeeds", []error{nil}, 1, false},
+		{"settles on retry", []error{errors.New("failed to transmit get UID command"), nil}, 2, false},
+		{"fails twice", []error{errors.New("card mute"), errors.New("card mute")}, 2, true},
+		{"no card", []error{fmt.Errorf("failed to connect to reader: %w", scard.ErrNoSmartcard)}, 1, true},
+		{"reader busy", []error{ErrReaderBusy}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			read := func(string) (*Card, error) {
+				err := tt.errs[calls]
+				calls++
+				if err != nil {
+					return nil, err
+				}
+				return &Card{UID: "04AABBCC"}, nil
+			}
+
+			card, err := readPlacedCard(read, "Reader A")
+			if calls != tt.wantCalls {
+				t.Errorf("read called %d times, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr || (err == nil && card == nil) {
+				t.Errorf("got card %v, err %v", card, err)
+			}
+		})
+	}
+
+	CardSettleDelay = 0
+	calls := 0
+	readPlacedCard(func(string) (*Card, error) { calls++; return nil, errors.New("card mute") }, "Reader A")
+	if calls != 1 {
+		t.Errorf("expected no retry with CardSettleDelay 0, got %d reads", calls)
+	}
+}
+
+func TestNDEFContentHash(t *testing.T) {
+	message := createNDEFURIRecord("https://example.com")
+	hash := ndefContentHash(message)
+	if len(hash) != 64 {
+		t.Fatalf("hash = %q, want 64 hex characters", hash)
+	}
+	if got := ndefContentHash(append([]byte(nil), message...)); got != hash {
+		t.Errorf("same message hashed to %q and %q", hash, got)
+	}
+	if got := ndefContentHash(createNDEFURIRecord("https://example.org")); got == hash {
+		t.Error("different messages should hash differently")
+	}
+	if got := ndefContentHash(nil); got != "" {
+		t.Errorf("empty message hash = %q, want empty", got)
+	}
+}
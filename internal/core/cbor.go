@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// CBORMIMEType is the MIME type of generic CBOR records.
+const CBORMIMEType = "application/cbor"
+
+// encodeGenericCBOR encodes a JSON document as canonical CBOR, the way
+// OpenPrintTag payloads are encoded (map keys sorted shortest first). Integral
+// numbers become CBOR integers, other numbers floats.
+func encodeGenericCBOR(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON for CBOR: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid JSON for CBOR: trailing data")
+	}
+	converted, err := jsonCBORValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return openprinttag.Marshal(converted)
+}
+
+// jsonCBORValue replaces the json.Numbers in a decoded JSON value with
+// integers or floats.
+func jsonCBORValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			converted, err := jsonCBORValue(value)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case []interface{}:
+		for i := range v {
+			converted, err := jsonCBORValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if n, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return n, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s for CBOR: %w", v, err)
+		}
+		return f, nil
+	default:
+		return v, nil
+	}
+}
+
+// decodeGenericCBOR decodes a CBOR payload that isn't an OpenPrintTag into
+// JSON. Map keys that aren't strings are formatted as strings, byte strings
+// become hex and tagged values {"tag": n, "value": v}.
+func decodeGenericCBOR(payload []byte) (string, error) {
+	var v interface{}
+	if err := cbor.Unmarshal(payload, &v); err != nil {
+		return "", fmt.Errorf("invalid CBOR: %w", err)
+	}
+	jsonData, err := json.Marshal(cborJSONValue(v))
+	if err != nil {
+		return "", fmt.Errorf("CBOR value has no JSON representation: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// cborJSONValue converts a value decoded by cbor.Unmarshal into one
+// encoding/json can marshal.
+func cborJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(cborJSONValue(key))] = cborJSONValue(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = cborJSONValue(v[i])
+		}
+		return v
+	case []byte:
+		return hex.EncodeToString(v)
+	case big.Int:
+		return v.String()
+	case cbor.Tag:
+		return map[string]interface{}{"tag": v.Number, "value": cborJSONValue(v.Content)}
+	default:
+		return v
+	}
+}
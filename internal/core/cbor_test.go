@@ -0,0 +1,137 @@
+package core
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+func TestDecodeMimePayload_GenericCBOR(t *testing.T) {
+	payload, err := cbor.Marshal(map[string]interface{}{
+		"name":  "spool",
+		"grams": 750,
+		"tags":  []interface{}{"pla", []byte{0xCA, 0xFE}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, dataType := decodeMimePayload("application/cbor", payload)
+	if dataType != "cbor" {
+		t.Fatalf("expected dataType cbor, got %q (%s)", dataType, data)
+	}
+	if want := `{"grams":750,"name":"spool","tags":["pla","cafe"]}`; data != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	// Only application/cbor falls back to generic CBOR
+	if _, dataType := decodeMimePayload("application/vnd.openprinttag", payload); dataType != "binary" {
+		t.Errorf("expected binary for an invalid OpenPrintTag, got %q", dataType)
+	}
+	// Invalid CBOR stays binary
+	if data, dataType := decodeMimePayload("application/cbor", []byte{0xFF}); dataType != "binary" || data != "ff" {
+		t.Errorf("expected binary ff, got %q %q", dataType, data)
+	}
+}
+
+func TestDecodeMimePayload_CBOROpenPrintTag(t *testing.T) {
+	// An empty map decodes as an OpenPrintTag without error, but lacks its fields
+	empty, err := encodeGenericCBOR([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, dataType := decodeMimePayload(CBORMIMEType, empty); dataType != "cbor" || data != `{}` {
+		t.Errorf("expected generic cbor {}, got %q %s", dataType, data)
+	}
+
+	payload, err := (&openprinttag.OpenPrintTag{Main: openprinttag.MainSection{MaterialName: "PLA"}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, dataType := decodeMimePayload(CBORMIMEType, payload); dataType != "openprinttag" {
+		t.Errorf("expected an OpenPrintTag in application/cbor, got %q", dataType)
+	}
+}
+
+func TestDecodeGenericCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		cbor string // hex
+		want string
+	}{
+		{"integer keys", "a201616102f5", `{"1":"a","2":true}`},
+		{"negative bignum", "c349010000000000000000", `"-18446744073709551617"`},
+		{"unknown tag", "d9ffff0a", `{"tag":65535,"value":10}`},
+		{"null", "f6", `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := hex.DecodeString(tt.cbor)
+			got, err := decodeGenericCBOR(payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := decodeGenericCBOR([]byte{0xF9, 0x7E, 0x00}); err == nil {
+		t.Error("expected an error for NaN, which has no JSON representation")
+	}
+}
+
+func TestEncodeGenericCBOR(t *testing.T) {
+	payload, err := encodeGenericCBOR([]byte(`{"name": "spool", "grams": 750, "diameter": 1.75, "big": 18446744073709551616, "ok": true, "none": null}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Canonical: keys sorted by encoded length then bytewise, integers stay integers
+	var decoded map[string]interface{}
+	if err := cbor.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("invalid CBOR: %v", err)
+	}
+	if decoded["grams"] != uint64(750) || decoded["diameter"] != 1.75 || decoded["ok"] != true {
+		t.Errorf("unexpected values %v", decoded)
+	}
+	if payload[0] != 0xA6 || payload[1] != 0x62 || string(payload[2:4]) != "ok" {
+		t.Errorf("expected the shortest key first, got % X", payload[:4])
+	}
+
+	// Reads back as generic CBOR
+	data, dataType := decodeMimePayload(CBORMIMEType, payload)
+	if dataType != "cbor" {
+		t.Fatalf("expected dataType cbor, got %q", dataType)
+	}
+	if want := `{"big":"18446744073709551616","diameter":1.75,"grams":750,"name":"spool","none":null,"ok":true}`; data != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	for _, input := range []string{`{"a":`, `{} {}`, ``} {
+		if _, err := encodeGenericCBOR([]byte(input)); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestBuildNDEFRecordsTLV_CBOR(t *testing.T) {
+	tlv, err := buildNDEFRecordsTLV([]NDEFRecord{{Type: "cbor", Data: `[1, "two"]`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start, length, _, _ := locateNDEFTLV(tlv)
+	card := &Card{}
+	parseNDEFRecords(tlv[start:start+length], card)
+	if len(card.Records) != 1 || card.Records[0].Type != CBORMIMEType || card.Records[0].Data != `[1,"two"]` {
+		t.Errorf("unexpected records %+v", card.Records)
+	}
+
+	if _, err := buildNDEFRecordsTLV([]NDEFRecord{{Type: "cbor", Data: `not json`}}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUIDMismatch is returned when the card on the reader is not the one a
+// write expected, e.g. because it was swapped after the client read it.
+var ErrUIDMismatch = errors.New("card UID does not match the expected UID")
+
+// checkExpectedUID reads the card's UID and fails with ErrUIDMismatch if it
+// differs from expect. An empty expect skips the check. It runs before any
+// write so a swapped card is left untouched.
+func checkExpectedUID(card cardTransmitter, expect string) error {
+	if expect == "" {
+		return nil
+	}
+
+	rsp, err := transmit(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err != nil {
+		return fmt.Errorf("failed to get UID: %w", err)
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return fmt.Errorf("failed to get UID: bad response %X", rsp)
+	}
+
+	uid := hex.EncodeToString(rsp[:len(rsp)-2])
+	if uid != normalizeUID(expect) {
+		return fmt.Errorf("%w: card has %s, expected %s", ErrUIDMismatch, uid, expect)
+	}
+	return nil
+}
+
+// normalizeUID lowercases a hex UID and drops ':', '-' and space separators.
+func normalizeUID(uid string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", " ", "").Replace(uid))
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckExpectedUID(t *testing.T) {
+	card := apduResponder(func(cmd []byte) []byte {
+		return []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6, 0x90, 0x00}
+	})
+
+	tests := []struct {
+		expect  string
+		wantErr error
+	}{
+		{"", nil},
+		{"04a1b2c3d4e5f6", nil},
+		{"04:A1:B2:C3:D4:E5:F6", nil},
+		{"04a1b2c3d4e5f7", ErrUIDMismatch},
+		{"04a1b2c3", ErrUIDMismatch},
+	}
+
+	for _, tt := range tests {
+		err := checkExpectedUID(card, tt.expect)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("checkExpectedUID(%q) = %v, want %v", tt.expect, err, tt.wantErr)
+		}
+	}
+
+	failing := apduResponder(func(cmd []byte) []byte { return []byte{0x6A, 0x81} })
+	if err := checkExpectedUID(failing, "04a1b2c3"); err == nil || errors.Is(err, ErrUIDMismatch) {
+		t.Errorf("expected a GET_UID error, got %v", err)
+	}
+	if err := checkExpectedUID(failing, ""); err != nil {
+		t.Errorf("empty expectUID should not read the UID, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ebfe/scard"
+)
+
+// ErrFeedbackUnsupported is returned for readers without a known LED/buzzer command.
+var ErrFeedbackUnsupported = errors.New("reader does not support LED/buzzer feedback")
+
+// acsEscapeIoctl is the control code for ACS reader escape commands.
+var acsEscapeIoctl = scard.CtlCode(3500)
+
+// Success feedback commands per reader family.
+var (
+	// ACR122U LED/buzzer pseudo-APDU: blink green once (100ms on, 100ms off)
+	// with the buzzer on during the on phase
+	acr122SuccessAPDU = []byte{0xFF, 0x00, 0x40, 0xA8, 0x04, 0x01, 0x01, 0x01, 0x01}
+
+	// ACR1252U/ACR1552U buzzer escape command: sound for 100ms (units of 10ms)
+	acsBuzzerEscape = []byte{0xE0, 0x00, 0x00, 0x28, 0x01, 0x0A}
+)
+
+// SignalSuccess flashes the reader's LED and/or sounds its buzzer to confirm a
+// scan. It returns ErrFeedbackUnsupported for readers it has no command for.
+func SignalSuccess(readerName string) error {
+	defer trackOperation("reader_feedback", readerName, nil)()
+
+	if feedbackMethod(readerName) == feedbackNone {
+		return ErrFeedbackUnsupported
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	return signalSuccess(card, readerName)
+}
+
+// Ways of triggering reader feedback.
+const (
+	feedbackNone = iota
+	feedbackAPDU
+	feedbackEscape
+)
+
+// feedbackMethod picks the feedback command family from the reader name.
+func feedbackMethod(readerName string) int {
+	name := strings.ToUpper(readerName)
+	switch {
+	case strings.Contains(name, "ACR122"):
+		return feedbackAPDU
+	case strings.Contains(name, "ACR1252"), strings.Contains(name, "ACR1552"):
+		return feedbackEscape
+	}
+	return feedbackNone
+}
+
+// signalSuccess sends the success feedback command over an open card connection.
+func signalSuccess(card *scard.Card, readerName string) error {
+	switch feedbackMethod(readerName) {
+	case feedbackAPDU:
+		rsp, err := transmit(card, acr122SuccessAPDU)
+		if err != nil {
+			return fmt.Errorf("failed to signal reader: %w", err)
+		}
+		if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
+			return fmt.Errorf("failed to signal reader: unexpected response %X", rsp)
+		}
+		return nil
+	case feedbackEscape:
+		if _, err := card.Control(acsEscapeIoctl, acsBuzzerEscape); err != nil {
+			return fmt.Errorf("failed to signal reader: %w", err)
+		}
+		return nil
+	}
+	return ErrFeedbackUnsupported
+}
@@ -0,0 +1,14 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignalSuccess_UnsupportedReader(t *testing.T) {
+	for _, name := range []string{"Identiv uTrust 3700 F", "HID OMNIKEY 5422 CL"} {
+		if err := SignalSuccess(name); !errors.Is(err, ErrFeedbackUnsupported) {
+			t.Errorf("%s: expected ErrFeedbackUnsupported, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/ebfe/scard"
+)
+
+// ListCardsInField returns the UIDs of all ISO 14443-A cards in the reader's
+// RF field. On readers with a PN532 front end (ACR122U) it runs the chip's
+// anti-collision via InListPassiveTarget, which reports at most two cards.
+// Other readers only expose the card PC/SC connected to, so the result is that
+// single UID.
+func ListCardsInField(readerName string) (_ []string, err error) {
+	defer trackOperation("list_cards", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	// UID of the card PC/SC connected to: FF CA 00 00 00
+	rsp, err := transmit(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, fmt.Errorf("failed to transmit get UID command: %w", err)
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, fmt.Errorf("get UID failed: response %s", hex.EncodeToString(rsp))
+	}
+	uids := []string{hex.EncodeToString(rsp[:len(rsp)-2])}
+
+	// PN532 InListPassiveTarget, up to 2 targets at 106 kbps type A, wrapped in
+	// the ACR122U direct transmit pseudo-APDU: FF 00 00 00 04 D4 4A 02 00
+	rsp, err = transmit(card, []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x4A, 0x02, 0x00})
+	if err != nil {
+		logging.Debug(logging.CatCard, "Anti-collision not supported, returning connected card", map[string]any{
+			"reader": readerName,
+			"error":  err.Error(),
+		})
+		return uids, nil
+	}
+	listed, err := parseInListPassiveTarget(rsp)
+
+	// Release the targets again (InRelease all) so later commands start clean
+	_, _ = transmit(card, []byte{0xFF, 0x00, 0x00, 0x00, 0x03, 0xD4, 0x52, 0x00})
+
+	if err != nil {
+		logging.Debug(logging.CatCard, "Anti-collision not supported, returning connected card", map[string]any{
+			"reader":   readerName,
+			"response": hex.EncodeToString(rsp),
+		})
+		return uids, nil
+	}
+
+	for _, uid := range listed {
+		if uid != uids[0] {
+			uids = append(uids, uid)
+		}
+	}
+
+	logging.Debug(logging.CatCard, "Cards in field", map[string]any{
+		"reader": readerName,
+		"uids":   uids,
+	})
+
+	return uids, nil
+}
+
+// parseInListPassiveTarget extracts the NFCIDs from a PN532 InListPassiveTarget
+// response for 106 kbps type A targets:
+// D5 4B NbTg {Tg SENS_RES(2) SEL_RES NFCIDLength NFCID [ATS]}... 90 00
+func parseInListPassiveTarget(rsp []byte) ([]string, error) {
+	if len(rsp) < 5 || rsp[0] != 0xD5 || rsp[1] != 0x4B || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, fmt.Errorf("not an InListPassiveTarget response")
+	}
+	data := rsp[2 : len(rsp)-2]
+	count := int(data[0])
+	data = data[1:]
+
+	uids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 5 {
+			return nil, fmt.Errorf("truncated target %d", i+1)
+		}
+		selRes := data[3]
+		n := int(data[4])
+		if len(data) < 5+n {
+			return nil, fmt.Errorf("truncated NFCID of target %d", i+1)
+		}
+		uids = append(uids, hex.EncodeToString(data[5:5+n]))
+		data = data[5+n:]
+
+		// ISO 14443-4 capable targets append their ATS (first byte is its length)
+		if selRes&0x20 != 0 && len(data) > 0 {
+			atsLen := int(data[0])
+			if atsLen == 0 || atsLen > len(data) {
+				return nil, fmt.Errorf("truncated ATS of target %d", i+1)
+			}
+			data = data[atsLen:]
+		}
+	}
+	return uids, nil
+}
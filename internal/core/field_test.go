@@ -0,0 +1,56 @@
+package core
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseInListPassiveTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		rsp     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "two NTAGs",
+			rsp:  "d54b02" + "01004400070442488a837280" + "02004400070411223344556f" + "9000",
+			want: []string{"0442488a837280", "0411223344556f"},
+		},
+		{
+			name: "MIFARE Classic",
+			rsp:  "d54b01" + "0100040804932bae0e" + "9000",
+			want: []string{"932bae0e"},
+		},
+		{
+			name: "ISO 14443-4 target with ATS then Classic",
+			rsp:  "d54b02" + "010344200704123456789012067577810280" + "0200040804932bae0e" + "9000",
+			want: []string{"04123456789012", "932bae0e"},
+		},
+		{
+			name: "no targets",
+			rsp:  "d54b009000",
+			want: []string{},
+		},
+		{name: "error status", rsp: "6300", wantErr: true},
+		{name: "other response", rsp: "d54300" + "9000", wantErr: true},
+		{name: "truncated NFCID", rsp: "d54b01" + "0100440007044248" + "9000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := hex.DecodeString(tt.rsp)
+			if err != nil {
+				t.Fatalf("bad test data: %v", err)
+			}
+			got, err := parseInListPassiveTarget(rsp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
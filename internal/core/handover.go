@@ -0,0 +1,277 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MIME types of the carrier configuration records in a connection handover.
+const (
+	WiFiMIMEType         = "application/vnd.wfa.wsc"
+	BluetoothOOBMIMEType = "application/vnd.bluetooth.ep.oob"
+)
+
+// handoverVersion is the Connection Handover version written to Hs records (1.2).
+const handoverVersion = 0x12
+
+// HandoverInput is the data of a "handover" record: a Handover Select record
+// referencing one carrier configuration record per entry, in order.
+type HandoverInput struct {
+	Carriers []HandoverCarrier `json:"carriers"`
+}
+
+// HandoverCarrier is one alternative carrier. Type is "wifi" or "bluetooth";
+// the other fields apply to the carrier type named after them.
+type HandoverCarrier struct {
+	Type string `json:"type"`
+	CPS  string `json:"cps,omitempty"` // Carrier power state: "active" (default), "inactive", "activating" or "unknown"
+
+	// Wi-Fi
+	SSID           string `json:"ssid,omitempty"`
+	NetworkKey     string `json:"networkKey,omitempty"`
+	AuthType       string `json:"authType,omitempty"`       // "open", "wpa-personal", "wpa2-personal" (default with a key), "wpa/wpa2-personal"
+	EncryptionType string `json:"encryptionType,omitempty"` // "none", "tkip", "aes" (default with a key), "aes/tkip"
+	MACAddress     string `json:"macAddress,omitempty"`     // Access point MAC address, broadcast if empty
+
+	// Bluetooth BR/EDR
+	Address string `json:"address,omitempty"` // Device address, e.g. "00:11:22:33:44:55"
+	Name    string `json:"name,omitempty"`
+}
+
+// AlternativeCarrier is an alternative carrier record of a Handover Select
+// record. CarrierRef is the ID of the carrier configuration record.
+type AlternativeCarrier struct {
+	CPS        string   `json:"cps"`
+	CarrierRef string   `json:"carrierRef"`
+	AuxRefs    []string `json:"auxRefs,omitempty"`
+}
+
+var carrierPowerStates = []string{"inactive", "active", "activating", "unknown"}
+
+// Wi-Fi Simple Configuration attribute IDs used in NFC credential tokens
+// (application/vnd.wfa.wsc).
+const (
+	WSCVersion        = 0x104A
+	WSCCredential     = 0x100E
+	WSCNetworkIndex   = 0x1026
+	WSCSSID           = 0x1045
+	WSCAuthType       = 0x1003
+	WSCEncryptionType = 0x100F
+	WSCNetworkKey     = 0x1027
+	WSCMACAddress     = 0x1020
+	WSCVendorExt      = 0x1049
+)
+
+var wscAuthTypes = map[string]uint16{
+	"open":              0x0001,
+	"wpa-personal":      0x0002,
+	"wpa2-personal":     0x0020,
+	"wpa/wpa2-personal": 0x0022,
+}
+
+var wscEncryptionTypes = map[string]uint16{
+	"none":     0x0001,
+	"tkip":     0x0004,
+	"aes":      0x0008,
+	"aes/tkip": 0x000C,
+}
+
+// createHandoverRecords encodes a "handover" record's JSON data as a Handover
+// Select record followed by its carrier configuration records, which get the
+// IDs firstRef, firstRef+1, ... in order so several handovers in one message
+// don't share IDs. It returns the number of IDs used. mb and me apply to the
+// first and last record.
+func createHandoverRecords(data string, firstRef int, mb, me bool) ([]byte, int, error) {
+	var input HandoverInput
+	if err := json.Unmarshal([]byte(data), &input); err != nil {
+		return nil, 0, fmt.Errorf("invalid handover JSON: %w", err)
+	}
+	if len(input.Carriers) == 0 {
+		return nil, 0, fmt.Errorf("handover needs at least one carrier")
+	}
+
+	var acRecords, carrierRecords []byte
+	for i, c := range input.Carriers {
+		cps := 1
+		if c.CPS != "" {
+			if cps = carrierPowerState(c.CPS); cps < 0 {
+				return nil, 0, fmt.Errorf("carrier %d: unknown carrier power state %q", i, c.CPS)
+			}
+		}
+
+		var mimeType string
+		var payload []byte
+		var err error
+		switch c.Type {
+		case "wifi":
+			mimeType = WiFiMIMEType
+			payload, err = encodeWiFiCredential(c)
+		case "bluetooth":
+			mimeType = BluetoothOOBMIMEType
+			payload, err = encodeBluetoothOOB(c)
+		default:
+			err = fmt.Errorf("unsupported carrier type %q (use wifi or bluetooth)", c.Type)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("carrier %d: %w", i, err)
+		}
+
+		ref := strconv.Itoa(firstRef + i)
+		ac := []byte{byte(cps), byte(len(ref))}
+		ac = append(ac, ref...)
+		ac = append(ac, 0x00) // No auxiliary data references
+		first, last := i == 0, i == len(input.Carriers)-1
+		acRecords = append(acRecords, createNDEFRecordRaw(0x01, []byte("ac"), ac, first, last)...)
+		carrierRecords = append(carrierRecords, createNDEFRecordWithID(0x02, []byte(mimeType), []byte(ref), payload, false, last && me)...)
+	}
+
+	hs := createNDEFRecordRaw(0x01, []byte("Hs"), append([]byte{handoverVersion}, acRecords...), mb, false)
+	return append(hs, carrierRecords...), len(input.Carriers), nil
+}
+
+// ParseHandoverSelect decodes the payload of a Handover Select ("Hs") record
+// into its version and alternative carriers.
+func ParseHandoverSelect(payload []byte) (string, []AlternativeCarrier, error) {
+	if len(payload) < 1 {
+		return "", nil, fmt.Errorf("empty handover select record")
+	}
+	version := fmt.Sprintf("%d.%d", payload[0]>>4, payload[0]&0x0F)
+
+	var carriers []AlternativeCarrier
+	for _, rec := range ParseNDEFMessage(payload[1:]) {
+		if rec.TNF != 0x01 || rec.Type != "ac" {
+			continue
+		}
+		ac, err := parseAlternativeCarrier(rec.Payload)
+		if err != nil {
+			return version, carriers, err
+		}
+		carriers = append(carriers, ac)
+	}
+	return version, carriers, nil
+}
+
+func parseAlternativeCarrier(p []byte) (AlternativeCarrier, error) {
+	var ac AlternativeCarrier
+	if len(p) < 2 || len(p) < 2+int(p[1]) {
+		return ac, fmt.Errorf("truncated alternative carrier record")
+	}
+	ac.CPS = carrierPowerStates[p[0]&0x03]
+	ac.CarrierRef = string(p[2 : 2+int(p[1])])
+
+	p = p[2+int(p[1]):]
+	if len(p) == 0 {
+		return ac, nil
+	}
+	count := int(p[0])
+	p = p[1:]
+	for i := 0; i < count; i++ {
+		if len(p) < 1 || len(p) < 1+int(p[0]) {
+			return ac, fmt.Errorf("truncated auxiliary data reference")
+		}
+		ac.AuxRefs = append(ac.AuxRefs, string(p[1:1+int(p[0])]))
+		p = p[1+int(p[0]):]
+	}
+	return ac, nil
+}
+
+// encodeWiFiCredential encodes a Wi-Fi Simple Configuration credential token
+// (application/vnd.wfa.wsc). Without a network key the network is open.
+func encodeWiFiCredential(c HandoverCarrier) ([]byte, error) {
+	if c.SSID == "" || len(c.SSID) > 32 {
+		return nil, fmt.Errorf("ssid must be 1-32 bytes")
+	}
+
+	auth, enc := c.AuthType, c.EncryptionType
+	if auth == "" {
+		auth = "wpa2-personal"
+		if c.NetworkKey == "" {
+			auth = "open"
+		}
+	}
+	if enc == "" {
+		enc = "aes"
+		if auth == "open" {
+			enc = "none"
+		}
+	}
+	authType, ok := wscAuthTypes[auth]
+	if !ok {
+		return nil, fmt.Errorf("unsupported authType %q", auth)
+	}
+	encType, ok := wscEncryptionTypes[enc]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encryptionType %q", enc)
+	}
+	if auth != "open" && (len(c.NetworkKey) < 8 || len(c.NetworkKey) > 64) {
+		return nil, fmt.Errorf("networkKey must be 8-64 characters for %s", auth)
+	}
+
+	mac := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	if c.MACAddress != "" {
+		var err error
+		if mac, err = hex.DecodeString(normalizeUID(c.MACAddress)); err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid macAddress %q", c.MACAddress)
+		}
+	}
+
+	var credential []byte
+	credential = appendWSC(credential, WSCNetworkIndex, []byte{0x01})
+	credential = appendWSC(credential, WSCSSID, []byte(c.SSID))
+	credential = appendWSC(credential, WSCAuthType, binary.BigEndian.AppendUint16(nil, authType))
+	credential = appendWSC(credential, WSCEncryptionType, binary.BigEndian.AppendUint16(nil, encType))
+	credential = appendWSC(credential, WSCNetworkKey, []byte(c.NetworkKey))
+	credential = appendWSC(credential, WSCMACAddress, mac)
+
+	payload := appendWSC(nil, WSCVersion, []byte{0x10})
+	payload = appendWSC(payload, WSCCredential, credential)
+	// WFA vendor extension: Version2 = 2.0
+	payload = appendWSC(payload, WSCVendorExt, []byte{0x00, 0x37, 0x2A, 0x00, 0x01, 0x20})
+	return payload, nil
+}
+
+// appendWSC appends a Wi-Fi Simple Configuration attribute (2-byte ID and
+// length, big-endian) to b.
+func appendWSC(b []byte, id uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, id)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+// encodeBluetoothOOB encodes a Bluetooth BR/EDR out-of-band record
+// (application/vnd.bluetooth.ep.oob): total length, device address
+// (little-endian), then the local name as EIR data.
+func encodeBluetoothOOB(c HandoverCarrier) ([]byte, error) {
+	addr, err := hex.DecodeString(normalizeUID(c.Address))
+	if err != nil || len(addr) != 6 {
+		return nil, fmt.Errorf("invalid bluetooth address %q", c.Address)
+	}
+	if len(c.Name) > 248 {
+		return nil, fmt.Errorf("bluetooth name exceeds 248 bytes")
+	}
+
+	payload := []byte{0x00, 0x00}
+	for i := len(addr) - 1; i >= 0; i-- {
+		payload = append(payload, addr[i])
+	}
+	if c.Name != "" {
+		payload = append(payload, byte(len(c.Name)+1), 0x09) // Complete local name
+		payload = append(payload, c.Name...)
+	}
+	binary.LittleEndian.PutUint16(payload, uint16(len(payload)))
+	return payload, nil
+}
+
+// carrierPowerState returns the CPS value for a power state name, or -1.
+func carrierPowerState(name string) int {
+	for i, v := range carrierPowerStates {
+		if strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCreateHandoverRecords(t *testing.T) {
+	tlv, err := buildNDEFRecordsTLV([]NDEFRecord{
+		{Type: "handover", Data: `{"carriers": [
+			{"type": "wifi", "ssid": "Workshop", "networkKey": "printer-pass", "cps": "active"},
+			{"type": "bluetooth", "address": "00:11:22:33:44:55", "name": "Printer", "cps": "inactive"}
+		]}`},
+		{Type: "url", Data: "https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("buildNDEFRecordsTLV failed: %v", err)
+	}
+	start, length, _, _ := locateNDEFTLV(tlv)
+
+	card := &Card{}
+	parseNDEFRecords(tlv[start:start+length], card)
+	if card.NDEFMalformed || len(card.Records) != 4 {
+		t.Fatalf("got %d records (malformed: %v %s)", len(card.Records), card.NDEFMalformed, card.NDEFMalformedReason)
+	}
+
+	hs := card.Records[0]
+	if hs.Type != "Hs" || hs.DataType != "handover" {
+		t.Fatalf("first record = %+v", hs)
+	}
+	version, carriers, err := ParseHandoverSelect(hs.Payload)
+	if err != nil || version != "1.2" || len(carriers) != 2 {
+		t.Fatalf("ParseHandoverSelect = %s, %+v, %v", version, carriers, err)
+	}
+	if carriers[0].CPS != "active" || carriers[1].CPS != "inactive" {
+		t.Errorf("carrier power states = %s, %s", carriers[0].CPS, carriers[1].CPS)
+	}
+
+	wifi, bt := card.Records[1], card.Records[2]
+	if wifi.ID != carriers[0].CarrierRef || wifi.Type != WiFiMIMEType {
+		t.Errorf("wifi record %q (ID %q) not referenced by %q", wifi.Type, wifi.ID, carriers[0].CarrierRef)
+	}
+	if !bytes.Contains(wifi.Payload, []byte("Workshop")) || !bytes.Contains(wifi.Payload, []byte("printer-pass")) {
+		t.Errorf("wifi payload is missing the credential: %x", wifi.Payload)
+	}
+	if bt.ID != carriers[1].CarrierRef || bt.Type != BluetoothOOBMIMEType {
+		t.Errorf("bluetooth record %q (ID %q) not referenced by %q", bt.Type, bt.ID, carriers[1].CarrierRef)
+	}
+	wantBT := append([]byte{0x11, 0x00, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00, 0x08, 0x09}, "Printer"...)
+	if !bytes.Equal(bt.Payload, wantBT) {
+		t.Errorf("bluetooth payload = % X, want % X", bt.Payload, wantBT)
+	}
+	if card.Records[3].DataType != "url" {
+		t.Errorf("records after the handover should be kept, got %+v", card.Records[3])
+	}
+}
+
+func TestCreateHandoverRecords_UniqueRefs(t *testing.T) {
+	carrier := `{"carriers": [{"type": "bluetooth", "address": "00:11:22:33:44:55"}, {"type": "wifi", "ssid": "Workshop"}]}`
+	tlv, err := buildNDEFRecordsTLV([]NDEFRecord{{Type: "handover", Data: carrier}, {Type: "handover", Data: carrier}})
+	if err != nil {
+		t.Fatalf("buildNDEFRecordsTLV failed: %v", err)
+	}
+	start, length, _, _ := locateNDEFTLV(tlv)
+	card := &Card{}
+	parseNDEFRecords(tlv[start:start+length], card)
+
+	// Hs, 2 carriers, Hs, 2 carriers: each Hs references its own carriers
+	if len(card.Records) != 6 {
+		t.Fatalf("got %d records", len(card.Records))
+	}
+	seen := map[string]bool{}
+	for _, hs := range []int{0, 3} {
+		_, carriers, err := ParseHandoverSelect(card.Records[hs].Payload)
+		if err != nil || len(carriers) != 2 {
+			t.Fatalf("ParseHandoverSelect = %+v, %v", carriers, err)
+		}
+		for i, ac := range carriers {
+			if rec := card.Records[hs+1+i]; rec.ID != ac.CarrierRef {
+				t.Errorf("record %d has ID %q, referenced as %q", hs+1+i, rec.ID, ac.CarrierRef)
+			}
+			if seen[ac.CarrierRef] {
+				t.Errorf("carrier ID %q used twice", ac.CarrierRef)
+			}
+			seen[ac.CarrierRef] = true
+		}
+	}
+}
+
+func TestCreateHandoverRecords_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no carriers":   `{"carriers": []}`,
+		"unknown type":  `{"carriers": [{"type": "zigbee"}]}`,
+		"bad cps":       `{"carriers": [{"type": "bluetooth", "address": "00:11:22:33:44:55", "cps": "on"}]}`,
+		"bad address":   `{"carriers": [{"type": "bluetooth", "address": "00:11"}]}`,
+		"short key":     `{"carriers": [{"type": "wifi", "ssid": "x", "networkKey": "short"}]}`,
+		"missing ssid":  `{"carriers": [{"type": "wifi"}]}`,
+		"bad auth type": `{"carriers": [{"type": "wifi", "ssid": "x", "authType": "wep"}]}`,
+	}
+	for name, data := range tests {
+		if _, _, err := createHandoverRecords(data, 0, true, true); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if !strings.Contains(err.Error(), "carrier") && name != "no carriers" {
+			t.Errorf("%s: error should name the carrier: %v", name, err)
+		}
+	}
+}
@@ -14,6 +14,12 @@ type SmartCard interface {
 	Disconnect(disposition uint32) error
 }
 
+// cardTransmitter is the part of a card connection that page reads and writes
+// need, so tests can run them against a simulated tag.
+type cardTransmitter interface {
+	Transmit(cmd []byte) ([]byte, error)
+}
+
 // SmartCardStatus represents the status of a smart card
 type SmartCardStatus struct {
 	Reader         string
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// ErrBlockReadRejected is returned when an ISO 15693 tag refuses a block
+// read, typically because it is in privacy mode or the block is read-protected.
+var ErrBlockReadRejected = errors.New("block read rejected (tag in privacy mode or block read-protected?)")
+
+// ISO 15693 commands, sent with the high data rate request flag.
+const (
+	iso15693FlagHighDataRate  = 0x02
+	iso15693CmdReadBlock      = 0x20
+	iso15693CmdGetSystemInfo  = 0x2B
+	iso15693ResponseErrorFlag = 0x01
+)
+
+// Transparent exchange session commands (PC/SC part 3, ACR1552). The switch
+// protocol object selects ISO 15693 layer 3.
+var (
+	iso15693StartSession = []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x81, 0x00}
+	iso15693SetProtocol  = []byte{0xFF, 0xC2, 0x00, 0x02, 0x04, 0x8F, 0x02, 0x02, 0x03}
+	iso15693EndSession   = []byte{0xFF, 0xC2, 0x00, 0x00, 0x02, 0x82, 0x00}
+)
+
+// ISO15693SystemInfo is the decoded Get System Information response.
+type ISO15693SystemInfo struct {
+	UID         string `json:"uid"`                   // MSB first, e.g. "e004..."
+	DSFID       string `json:"dsfid,omitempty"`       // Hex, if reported
+	AFI         string `json:"afi,omitempty"`         // Hex, if reported
+	BlockCount  int    `json:"blockCount"`            // Number of blocks
+	BlockSize   int    `json:"blockSize"`             // Bytes per block
+	ICReference string `json:"icReference,omitempty"` // Hex, if reported
+}
+
+// ISO15693Block is one memory block of a dump.
+type ISO15693Block struct {
+	Block int    `json:"block"`
+	Data  string `json:"data"` // Hex
+}
+
+// ISO15693Dump is the full memory of an ISO 15693 tag, block by block.
+type ISO15693Dump struct {
+	SystemInfo ISO15693SystemInfo `json:"systemInfo"`
+	Blocks     []ISO15693Block    `json:"blocks"`
+}
+
+// DumpISO15693 reads the system information of an ISO 15693 tag (ICODE SLIX,
+// SLIX2) to learn its block count and size, then reads every block.
+func DumpISO15693(readerName string) (_ *ISO15693Dump, err error) {
+	defer trackOperation("dump_iso15693", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	status, err := card.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card status: %w", err)
+	}
+	if !isISO15693ATR(hex.EncodeToString(status.Atr)) {
+		return nil, fmt.Errorf("not an ISO 15693 tag")
+	}
+
+	return dumpISO15693(card)
+}
+
+// dumpISO15693 runs the dump within one transparent exchange session.
+func dumpISO15693(card cardTransmitter) (*ISO15693Dump, error) {
+	if err := startISO15693Session(card); err != nil {
+		return nil, err
+	}
+	defer transmit(card, iso15693EndSession)
+
+	rsp, err := iso15693Exchange(card, []byte{iso15693FlagHighDataRate, iso15693CmdGetSystemInfo})
+	if err != nil {
+		return nil, fmt.Errorf("get system information failed: %w", err)
+	}
+	info, err := parseISO15693SystemInfo(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &ISO15693Dump{SystemInfo: *info, Blocks: make([]ISO15693Block, 0, info.BlockCount)}
+	for block := 0; block < info.BlockCount; block++ {
+		rsp, err := iso15693Exchange(card, []byte{iso15693FlagHighDataRate, iso15693CmdReadBlock, byte(block)})
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", block, err)
+		}
+		if len(rsp) < info.BlockSize {
+			return nil, fmt.Errorf("block %d: short response (%d bytes, block size %d)", block, len(rsp), info.BlockSize)
+		}
+		dump.Blocks = append(dump.Blocks, ISO15693Block{
+			Block: block,
+			Data:  hex.EncodeToString(rsp[:info.BlockSize]),
+		})
+	}
+	return dump, nil
+}
+
+// readISO15693NDEFArea reads the NDEF area of an ISO 15693 tag, using the
+// block count and size from Get System Information, and returns it without
+// the capability container. The CC takes 4 bytes, or 8 for tags over 2 KB,
+// so with 8-byte blocks (SLIX2) the NDEF TLV starts inside block 0. ok is
+// false when the reader has no transparent exchange or the tag doesn't report
+// its geometry. Reading stops early once complete reports the NDEF data read.
+func readISO15693NDEFArea(card cardTransmitter, maxBlocks int, complete func([]byte) bool) (data []byte, blocksRead int, ok bool) {
+	if err := startISO15693Session(card); err != nil {
+		return nil, 0, false
+	}
+	defer transmit(card, iso15693EndSession)
+
+	rsp, err := iso15693Exchange(card, []byte{iso15693FlagHighDataRate, iso15693CmdGetSystemInfo})
+	if err != nil {
+		return nil, 0, false
+	}
+	info, err := parseISO15693SystemInfo(rsp)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	var raw []byte
+	budget := ndefReadBudget(info.BlockCount, maxBlocks)
+	for block := 0; block < info.BlockCount && blocksRead < budget; block++ {
+		rsp, err := iso15693Exchange(card, []byte{iso15693FlagHighDataRate, iso15693CmdReadBlock, byte(block)})
+		if err == nil && len(rsp) < info.BlockSize {
+			err = fmt.Errorf("short response (%d bytes, block size %d)", len(rsp), info.BlockSize)
+		}
+		if err != nil {
+			logCard(card, logging.LevelDebug, logging.CatCard, "NDEF read failed", map[string]any{
+				"block": block,
+				"error": err.Error(),
+			})
+			break
+		}
+		blocksRead++
+
+		raw = append(raw, rsp[:info.BlockSize]...)
+		if cc := iso15693CCLength(raw); cc > 0 && len(raw) > cc && complete(raw[cc:]) {
+			break
+		}
+	}
+
+	if cc := iso15693CCLength(raw); cc > 0 && len(raw) > cc {
+		data = raw[cc:]
+	}
+	return data, blocksRead, true
+}
+
+// iso15693CCLength returns the length of the Type 5 capability container at
+// the start of data: 8 bytes when the memory size byte is zero (the size is
+// then in the extended CC), otherwise 4. It returns 0 while data is shorter
+// than 4 bytes.
+func iso15693CCLength(data []byte) int {
+	switch {
+	case len(data) < 4:
+		return 0
+	case data[2] == 0:
+		return 8
+	}
+	return 4
+}
+
+// startISO15693Session opens a transparent exchange session for ISO 15693.
+func startISO15693Session(card cardTransmitter) error {
+	for _, cmd := range [][]byte{iso15693StartSession, iso15693SetProtocol} {
+		rsp, err := transmit(card, cmd)
+		if err != nil {
+			return fmt.Errorf("failed to start transparent session: %w", err)
+		}
+		if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
+			transmit(card, iso15693EndSession)
+			return fmt.Errorf("reader does not support ISO 15693 transparent exchange (status % X)", rsp)
+		}
+	}
+	return nil
+}
+
+// iso15693Exchange sends a raw ISO 15693 request and returns the response
+// after the flags byte. A tag that answers with the error flag, or doesn't
+// answer at all, is reported as ErrBlockReadRejected.
+func iso15693Exchange(card cardTransmitter, frame []byte) ([]byte, error) {
+	cmd := []byte{0xFF, 0xC2, 0x00, 0x01, byte(len(frame) + 2), 0x95, byte(len(frame))}
+	cmd = append(cmd, frame...)
+	rsp, err := transmit(card, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 {
+		return nil, fmt.Errorf("transparent exchange failed (status % X)", rsp)
+	}
+
+	data, ok := findDataObject(rsp[:len(rsp)-2], 0x97)
+	if !ok || len(data) == 0 {
+		// Tags in privacy mode stay silent
+		return nil, ErrBlockReadRejected
+	}
+	if data[0]&iso15693ResponseErrorFlag != 0 {
+		if len(data) >= 2 {
+			return nil, fmt.Errorf("%w: error code 0x%02X", ErrBlockReadRejected, data[1])
+		}
+		return nil, ErrBlockReadRejected
+	}
+	return data[1:], nil
+}
+
+// findDataObject returns the value of the first tag data object in a
+// transparent exchange response.
+func findDataObject(rsp []byte, tag byte) ([]byte, bool) {
+	for i := 0; i+1 < len(rsp); {
+		length := int(rsp[i+1])
+		if i+2+length > len(rsp) {
+			return nil, false
+		}
+		if rsp[i] == tag {
+			return rsp[i+2 : i+2+length], true
+		}
+		i += 2 + length
+	}
+	return nil, false
+}
+
+// parseISO15693SystemInfo decodes a Get System Information response (after
+// the flags byte): info flags, UID (LSB first), then the optional DSFID, AFI,
+// memory size and IC reference fields the info flags announce.
+func parseISO15693SystemInfo(rsp []byte) (*ISO15693SystemInfo, error) {
+	if len(rsp) < 9 {
+		return nil, fmt.Errorf("system information too short (%d bytes)", len(rsp))
+	}
+	infoFlags := rsp[0]
+	uid := make([]byte, 8)
+	for i := range uid {
+		uid[i] = rsp[8-i]
+	}
+	info := &ISO15693SystemInfo{UID: hex.EncodeToString(uid)}
+
+	rest := rsp[9:]
+	next := func(n int) ([]byte, error) {
+		if len(rest) < n {
+			return nil, fmt.Errorf("system information truncated")
+		}
+		b := rest[:n]
+		rest = rest[n:]
+		return b, nil
+	}
+	if infoFlags&0x01 != 0 {
+		b, err := next(1)
+		if err != nil {
+			return nil, err
+		}
+		info.DSFID = hex.EncodeToString(b)
+	}
+	if infoFlags&0x02 != 0 {
+		b, err := next(1)
+		if err != nil {
+			return nil, err
+		}
+		info.AFI = hex.EncodeToString(b)
+	}
+	if infoFlags&0x04 == 0 {
+		return nil, fmt.Errorf("tag did not report its memory size")
+	}
+	b, err := next(2)
+	if err != nil {
+		return nil, err
+	}
+	info.BlockCount = int(b[0]) + 1
+	info.BlockSize = int(b[1]&0x1F) + 1
+	if infoFlags&0x08 != 0 {
+		b, err := next(1)
+		if err != nil {
+			return nil, err
+		}
+		info.ICReference = hex.EncodeToString(b)
+	}
+	return info, nil
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// simulatedSLIX2 answers ISO 15693 requests wrapped in transparent exchange
+// commands, as an ACR1552 does.
+type simulatedSLIX2 struct {
+	blocks   [][]byte
+	privacy  bool // Tag stays silent, as in privacy mode
+	protect  int  // First read-protected block (0 = none)
+	sessions int  // Open transparent sessions
+}
+
+func (s *simulatedSLIX2) Transmit(cmd []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cmd, iso15693StartSession):
+		s.sessions++
+		return []byte{0xC0, 0x03, 0x00, 0x90, 0x00, 0x90, 0x00}, nil
+	case bytes.Equal(cmd, iso15693EndSession):
+		s.sessions--
+		return []byte{0xC0, 0x03, 0x00, 0x90, 0x00, 0x90, 0x00}, nil
+	case bytes.Equal(cmd, iso15693SetProtocol):
+		return []byte{0xC0, 0x03, 0x00, 0x90, 0x00, 0x90, 0x00}, nil
+	case len(cmd) > 7 && cmd[0] == 0xFF && cmd[1] == 0xC2 && cmd[5] == 0x95:
+		return s.answer(cmd[7:]), nil
+	}
+	return []byte{0x6A, 0x81}, nil
+}
+
+func (s *simulatedSLIX2) answer(frame []byte) []byte {
+	status := []byte{0xC0, 0x03, 0x00, 0x90, 0x00}
+	if s.privacy {
+		return append(status, 0x90, 0x00)
+	}
+	var data []byte
+	switch frame[1] {
+	case iso15693CmdGetSystemInfo:
+		data = []byte{0x00, 0x0F, 0x78, 0x56, 0x34, 0x12, 0x08, 0x01, 0x04, 0xE0, 0x00, 0x00, byte(len(s.blocks) - 1), byte(len(s.blocks[0]) - 1), 0x01}
+	case iso15693CmdReadBlock:
+		block := int(frame[2])
+		if s.protect > 0 && block >= s.protect {
+			data = []byte{0x01, 0x10}
+		} else {
+			data = append([]byte{0x00}, s.blocks[block]...)
+		}
+	}
+	rsp := append(status, 0x97, byte(len(data)))
+	rsp = append(rsp, data...)
+	return append(rsp, 0x90, 0x00)
+}
+
+func newSimulatedSLIX2(blocks int) *simulatedSLIX2 {
+	s := &simulatedSLIX2{blocks: make([][]byte, blocks)}
+	for i := range s.blocks {
+		s.blocks[i] = []byte{byte(i), 0xA0, 0xB0, 0xC0}
+	}
+	return s
+}
+
+// newSimulatedSLIX2Memory splits memory into blocks of blockSize bytes.
+func newSimulatedSLIX2Memory(memory []byte, blockSize int) *simulatedSLIX2 {
+	s := &simulatedSLIX2{}
+	for i := 0; i < len(memory); i += blockSize {
+		s.blocks = append(s.blocks, memory[i:i+blockSize])
+	}
+	return s
+}
+
+func TestDumpISO15693(t *testing.T) {
+	tag := newSimulatedSLIX2(80)
+
+	dump, err := dumpISO15693(tag)
+	if err != nil {
+		t.Fatalf("dumpISO15693 failed: %v", err)
+	}
+	info := dump.SystemInfo
+	if info.UID != "e004010812345678" {
+		t.Errorf("UID = %q, want MSB first", info.UID)
+	}
+	if info.BlockCount != 80 || info.BlockSize != 4 {
+		t.Errorf("got %d blocks of %d bytes, want 80 of 4", info.BlockCount, info.BlockSize)
+	}
+	if info.DSFID != "00" || info.AFI != "00" || info.ICReference != "01" {
+		t.Errorf("unexpected optional fields: %+v", info)
+	}
+	if len(dump.Blocks) != 80 {
+		t.Fatalf("expected 80 blocks, got %d", len(dump.Blocks))
+	}
+	if b := dump.Blocks[79]; b.Block != 79 || b.Data != "4fa0b0c0" {
+		t.Errorf("last block = %+v", b)
+	}
+	if tag.sessions != 0 {
+		t.Errorf("transparent session left open")
+	}
+}
+
+func TestDumpISO15693_Rejected(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  *simulatedSLIX2
+	}{
+		{"privacy mode", &simulatedSLIX2{blocks: newSimulatedSLIX2(8).blocks, privacy: true}},
+		{"read-protected block", &simulatedSLIX2{blocks: newSimulatedSLIX2(8).blocks, protect: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dumpISO15693(tt.tag)
+			if !errors.Is(err, ErrBlockReadRejected) {
+				t.Errorf("expected ErrBlockReadRejected, got %v", err)
+			}
+			if tt.tag.sessions != 0 {
+				t.Errorf("transparent session left open")
+			}
+		})
+	}
+}
+
+func TestReadISO15693NDEFArea(t *testing.T) {
+	message := createNDEFRecordRaw(0x01, []byte("T"), []byte("\x02enOpenPrintTag spool"), true, true)
+	ndef := append([]byte{0x03, byte(len(message))}, message...)
+	ndef = append(ndef, 0xFE)
+
+	tests := []struct {
+		name      string
+		cc        []byte
+		blockSize int
+	}{
+		{"SLIX2 8-byte blocks", []byte{0xE1, 0x40, 0x28, 0x01}, 8},
+		{"4-byte blocks", []byte{0xE1, 0x40, 0x10, 0x01}, 4},
+		{"extended CC", []byte{0xE1, 0x40, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00}, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := make([]byte, 40*tt.blockSize)
+			copy(memory, tt.cc)
+			copy(memory[len(tt.cc):], ndef)
+			tag := newSimulatedSLIX2Memory(memory, tt.blockSize)
+
+			data, blocksRead, ok := readISO15693NDEFArea(tag, 0, ndefReadComplete)
+			if !ok {
+				t.Fatal("expected the system information to be used")
+			}
+			if !bytes.HasPrefix(data, ndef) {
+				t.Errorf("data = % X, want NDEF TLV first", data)
+			}
+			if want := (len(tt.cc) + len(ndef) + tt.blockSize - 1) / tt.blockSize; blocksRead != want {
+				t.Errorf("read %d blocks, want %d", blocksRead, want)
+			}
+			if tag.sessions != 0 {
+				t.Errorf("transparent session left open")
+			}
+		})
+	}
+}
+
+func TestReadISO15693NDEFArea_NoSystemInfo(t *testing.T) {
+	reader := apduResponder(func(cmd []byte) []byte { return []byte{0x6A, 0x81} })
+	if _, _, ok := readISO15693NDEFArea(reader, 0, ndefReadComplete); ok {
+		t.Error("expected a fallback when the reader has no transparent exchange")
+	}
+
+	tag := newSimulatedSLIX2(8)
+	tag.privacy = true
+	if _, _, ok := readISO15693NDEFArea(tag, 0, ndefReadComplete); ok {
+		t.Error("expected a fallback when the tag doesn't report its geometry")
+	}
+}
+
+func TestParseISO15693SystemInfo(t *testing.T) {
+	uid := []byte{0x78, 0x56, 0x34, 0x12, 0x08, 0x01, 0x04, 0xE0}
+	tests := []struct {
+		name    string
+		rsp     []byte
+		want    ISO15693SystemInfo
+		wantErr bool
+	}{
+		{
+			name: "memory size only",
+			rsp:  append(append([]byte{0x04}, uid...), 0x4F, 0x03),
+			want: ISO15693SystemInfo{UID: "e004010812345678", BlockCount: 80, BlockSize: 4},
+		},
+		{
+			name: "all fields",
+			rsp:  append(append([]byte{0x0F}, uid...), 0x01, 0x02, 0x4F, 0x03, 0x01),
+			want: ISO15693SystemInfo{UID: "e004010812345678", DSFID: "01", AFI: "02", BlockCount: 80, BlockSize: 4, ICReference: "01"},
+		},
+		{"no memory size", append([]byte{0x03}, append(uid, 0x00, 0x00)...), ISO15693SystemInfo{}, true},
+		{"truncated", append([]byte{0x0F}, append(uid, 0x01)...), ISO15693SystemInfo{}, true},
+		{"too short", []byte{0x04, 0x01}, ISO15693SystemInfo{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseISO15693SystemInfo(tt.rsp)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
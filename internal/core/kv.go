@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyValueMIMEType is the MIME type of the record written by WriteKeyValues.
+// The payload is a compact JSON object mapping string keys to string values,
+// with keys in sorted order.
+const KeyValueMIMEType = "application/x-nfc-kv"
+
+// KeyValueExpiresAtKey is the reserved key holding a key-value record's
+// expiry, as a decimal unix time in seconds.
+const KeyValueExpiresAtKey = "_expiresAt"
+
+// ErrNoKeyValues is returned by ReadKeyValues when the card holds no key-value record.
+var ErrNoKeyValues = errors.New("no key-value record found on card")
+
+// KeyValues is a decoded key-value record. Expired is computed on read from
+// ExpiresAt and is never stored.
+type KeyValues struct {
+	Values    map[string]string `json:"values"`
+	ExpiresAt int64             `json:"expiresAt,omitempty"` // Unix time in seconds, 0 if the record doesn't expire
+	Expired   bool              `json:"expired,omitempty"`
+}
+
+// WriteKeyValues stores kv on the card as a single key-value MIME record,
+// replacing any existing NDEF data. A non-zero expiresAt (unix seconds) is
+// stored with the values.
+func WriteKeyValues(readerName string, kv map[string]string, expiresAt int64) error {
+	return WriteKeyValuesWithOptions(readerName, kv, expiresAt, WriteOptions{})
+}
+
+// WriteKeyValuesWithOptions is like WriteKeyValues but applies write options (Force and
+// ExpectUID are used).
+func WriteKeyValuesWithOptions(readerName string, kv map[string]string, expiresAt int64, opts WriteOptions) error {
+	payload, err := encodeKeyValues(kv, expiresAt)
+	if err != nil {
+		return err
+	}
+	return WriteRawNDEFWithOptions(readerName, createNDEFRecordRaw(0x02, []byte(KeyValueMIMEType), payload, true, true), opts)
+}
+
+// ReadKeyValues reads the key-value record written by WriteKeyValues.
+func ReadKeyValues(readerName string) (*KeyValues, error) {
+	card, err := GetCardUID(readerName)
+	if err != nil {
+		return nil, err
+	}
+	return keyValuesFromCard(card)
+}
+
+// keyValuesFromCard decodes the first key-value record on a card.
+func keyValuesFromCard(card *Card) (*KeyValues, error) {
+	for _, rec := range card.Records {
+		if rec.TNF == 0x02 && MediaType(rec.Type) == KeyValueMIMEType {
+			return decodeKeyValues(rec.Payload)
+		}
+	}
+	return nil, ErrNoKeyValues
+}
+
+func encodeKeyValues(kv map[string]string, expiresAt int64) ([]byte, error) {
+	stored := make(map[string]string, len(kv)+1)
+	for key, value := range kv {
+		if key == "" {
+			return nil, fmt.Errorf("key-value keys must not be empty")
+		}
+		if key == KeyValueExpiresAtKey {
+			return nil, fmt.Errorf("key %q is reserved for the expiry", KeyValueExpiresAtKey)
+		}
+		stored[key] = value
+	}
+	if expiresAt < 0 {
+		return nil, fmt.Errorf("expiry must not be negative")
+	}
+	if expiresAt > 0 {
+		stored[KeyValueExpiresAtKey] = strconv.FormatInt(expiresAt, 10)
+	}
+	return json.Marshal(stored)
+}
+
+func decodeKeyValues(payload []byte) (*KeyValues, error) {
+	kv := &KeyValues{Values: map[string]string{}}
+	if err := json.Unmarshal(payload, &kv.Values); err != nil {
+		return nil, fmt.Errorf("invalid key-value record: %w", err)
+	}
+	if s, ok := kv.Values[KeyValueExpiresAtKey]; ok {
+		expiresAt, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || expiresAt < 0 {
+			return nil, fmt.Errorf("invalid key-value record: invalid %s %q", KeyValueExpiresAtKey, s)
+		}
+		delete(kv.Values, KeyValueExpiresAtKey)
+		kv.ExpiresAt = expiresAt
+		kv.Expired = isExpired(expiresAt, time.Now())
+	}
+	return kv, nil
+}
+
+// isExpired reports whether an expiry in unix seconds has passed at now.
+// Zero means no expiry.
+func isExpired(expiresAt int64, now time.Time) bool {
+	return expiresAt > 0 && now.Unix() >= expiresAt
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodeKeyValues(t *testing.T) {
+	payload, err := encodeKeyValues(map[string]string{"mode": "dark", "lang": "en"}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Keys are sorted so the same map always encodes identically
+	if string(payload) != `{"lang":"en","mode":"dark"}` {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+
+	payload, err = encodeKeyValues(nil, 0)
+	if err != nil || string(payload) != "{}" {
+		t.Errorf("expected empty object for nil map, got %s (%v)", payload, err)
+	}
+
+	if _, err := encodeKeyValues(map[string]string{"": "x"}, 0); err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
+func TestKeyValuesFromCard(t *testing.T) {
+	payload, _ := encodeKeyValues(map[string]string{"spool": "42", "note": "dry before use"}, 0)
+	message := createNDEFRecordRaw(0x02, []byte(KeyValueMIMEType), payload, true, true)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	kv, err := keyValuesFromCard(card)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(kv.Values) != 2 || kv.Values["spool"] != "42" || kv.Values["note"] != "dry before use" {
+		t.Errorf("unexpected values: %v", kv.Values)
+	}
+	if kv.ExpiresAt != 0 || kv.Expired {
+		t.Errorf("expected no expiry, got %+v", kv)
+	}
+	if card.DataType != "json" {
+		t.Errorf("expected key-value record to read as json, got %s", card.DataType)
+	}
+
+	// Card without a key-value record
+	if _, err := keyValuesFromCard(&Card{Records: []ParsedRecord{{TNF: 0x01, Type: "T"}}}); !errors.Is(err, ErrNoKeyValues) {
+		t.Errorf("expected ErrNoKeyValues, got %v", err)
+	}
+
+	// Corrupt payload
+	bad := &Card{Records: []ParsedRecord{{TNF: 0x02, Type: KeyValueMIMEType, Payload: []byte("{not json")}}}
+	if _, err := keyValuesFromCard(bad); err == nil || errors.Is(err, ErrNoKeyValues) {
+		t.Errorf("expected decode error, got %v", err)
+	}
+}
+
+func TestKeyValues_Expiry(t *testing.T) {
+	payload, err := encodeKeyValues(map[string]string{"door": "3"}, 1700000000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != `{"_expiresAt":"1700000000","door":"3"}` {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+	kv, err := decodeKeyValues(payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kv.ExpiresAt != 1700000000 || !kv.Expired {
+		t.Errorf("expected expired record, got %+v", kv)
+	}
+	if _, ok := kv.Values[KeyValueExpiresAtKey]; ok || len(kv.Values) != 1 {
+		t.Errorf("expiry should not be returned as a value: %v", kv.Values)
+	}
+
+	future := time.Now().Add(time.Hour).Unix()
+	payload, _ = encodeKeyValues(map[string]string{"door": "3"}, future)
+	if kv, err := decodeKeyValues(payload); err != nil || kv.Expired {
+		t.Errorf("expected unexpired record, got %+v (%v)", kv, err)
+	}
+
+	if _, err := encodeKeyValues(map[string]string{KeyValueExpiresAtKey: "1"}, 0); err == nil {
+		t.Error("expected error for reserved key")
+	}
+	if _, err := decodeKeyValues([]byte(`{"_expiresAt":"soon"}`)); err == nil {
+		t.Error("expected error for invalid expiry")
+	}
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// ErrInvalidLockPages is returned for a page list LockPages cannot lock
+// exactly: pages outside the lockable range, or only part of a group of pages
+// that share a dynamic lock bit.
+var ErrInvalidLockPages = errors.New("invalid pages to lock")
+
+// ntagLockBits returns the static lock bits (page 2, bytes 2-3, bit n locking
+// page n) and dynamic lock bits (dynamic lock page, bytes 0-1) that lock
+// exactly pages. Pages 3-15 have a static bit each; from page 16 up to the
+// dynamic lock page each dynamic bit locks layout.pagesPerLockBit pages, all
+// of which must be listed.
+func ntagLockBits(layout ntagLayout, pages []int) (static, dynamic uint16, err error) {
+	if len(pages) == 0 {
+		return 0, 0, fmt.Errorf("%w: no pages given", ErrInvalidLockPages)
+	}
+	last := layout.dynamicLockPage - 1
+	requested := make(map[int]bool, len(pages))
+	for _, page := range pages {
+		if page < 3 || page > last {
+			return 0, 0, fmt.Errorf("%w: page %d is outside the lockable pages 3-%d", ErrInvalidLockPages, page, last)
+		}
+		requested[page] = true
+	}
+
+	for page := range requested {
+		if page <= 15 {
+			static |= 1 << page
+			continue
+		}
+		bit := (page - 16) / layout.pagesPerLockBit
+		first := 16 + bit*layout.pagesPerLockBit
+		groupLast := min(first+layout.pagesPerLockBit-1, last)
+		for p := first; p <= groupLast; p++ {
+			if !requested[p] {
+				return 0, 0, fmt.Errorf("%w: page %d can only be locked together with pages %d-%d", ErrInvalidLockPages, page, first, groupLast)
+			}
+		}
+		dynamic |= 1 << bit
+	}
+	return static, dynamic, nil
+}
+
+// LockPages permanently locks the given pages of an NTAG213/215/216 by
+// setting only their lock bits; lock bits already set stay set and the
+// block-lock bits are left alone. Pages 16 and up are locked in groups (2
+// pages on an NTAG213, 16 on an NTAG215/216) and a group must be listed in
+// full. WARNING: This is IRREVERSIBLE!
+func LockPages(readerName string, pages []int) error {
+	return LockPagesWithOptions(readerName, pages, WriteOptions{})
+}
+
+// LockPagesWithOptions is like LockPages but applies write options (only
+// ExpectUID is used).
+func LockPagesWithOptions(readerName string, pages []int, opts WriteOptions) (err error) {
+	defer trackOperation("lock_pages", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
+	cardInfo := &Card{}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
+		return fmt.Errorf("page locking not supported for card type: %s", cardInfo.Type)
+	}
+	static, dynamic, err := ntagLockBits(layout, pages)
+	if err != nil {
+		return err
+	}
+
+	sorted := append([]int(nil), pages...)
+	sort.Ints(sorted)
+	logging.Warn(logging.CatCard, "Locking pages permanently", map[string]any{
+		"reader": readerName,
+		"type":   cardInfo.Type,
+		"pages":  sorted,
+	})
+
+	return setNTAGLockBits(card, layout, static, dynamic)
+}
+
+// setNTAGLockBits ORs the lock bits into page 2 and the dynamic lock page,
+// writing only pages that change, and reads them back to check the bits
+// took (a set block-lock bit makes the tag ignore changes to its lock bits).
+func setNTAGLockBits(card cardTransmitter, layout ntagLayout, static, dynamic uint16) error {
+	for _, lock := range []struct {
+		page   int
+		offset int // Byte the 16 lock bits start at
+		bits   uint16
+	}{
+		{2, 2, static},
+		{layout.dynamicLockPage, 0, dynamic},
+	} {
+		if lock.bits == 0 {
+			continue
+		}
+		data, err := readNTAGPage(card, lock.page)
+		if err != nil || len(data) < 4 {
+			return fmt.Errorf("failed to read lock page %d: %v", lock.page, err)
+		}
+		updated := orLockBits(data[:4], lock.offset, lock.bits)
+		if !bytes.Equal(updated, data[:4]) {
+			if err := writeNTAGPages(card, lock.page, updated); err != nil {
+				return fmt.Errorf("failed to write lock page %d: %w", lock.page, err)
+			}
+			waitWriteSettle()
+		}
+
+		check, err := readNTAGPage(card, lock.page)
+		if err != nil || len(check) < 4 {
+			return fmt.Errorf("failed to verify lock page %d: %v", lock.page, err)
+		}
+		if got := uint16(check[lock.offset+1])<<8 | uint16(check[lock.offset]); got&lock.bits != lock.bits {
+			return fmt.Errorf("lock bits on page %d did not take (block-locked?): wanted %04X, read %04X", lock.page, lock.bits, got)
+		}
+	}
+	return nil
+}
+
+// orLockBits returns a copy of page with bits ORed into the two bytes
+// starting at offset, low byte first.
+func orLockBits(page []byte, offset int, bits uint16) []byte {
+	updated := append([]byte(nil), page...)
+	updated[offset] |= byte(bits)
+	updated[offset+1] |= byte(bits >> 8)
+	return updated
+}
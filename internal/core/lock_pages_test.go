@@ -0,0 +1,130 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNTAGLockBits(t *testing.T) {
+	tests := []struct {
+		name        string
+		cardType    string
+		pages       []int
+		wantStatic  uint16
+		wantDynamic uint16
+	}{
+		{"CC", "NTAG213", []int{3}, 0x0008, 0},
+		{"static pages", "NTAG213", []int{4, 5, 15}, 0x8030, 0},
+		{"NTAG213 pairs", "NTAG213", []int{16, 17, 39, 38}, 0, 0x0801},
+		{"NTAG215 group", "NTAG215", rangeOfPages(128, 129), 0, 0x0080},
+		{"NTAG216 last group", "NTAG216", rangeOfPages(224, 225), 0, 0x2000},
+		{"duplicates", "NTAG216", []int{8, 8}, 0x0100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			static, dynamic, err := ntagLockBits(ntagLayouts[tt.cardType], tt.pages)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if static != tt.wantStatic || dynamic != tt.wantDynamic {
+				t.Errorf("bits = %04X/%04X, want %04X/%04X", static, dynamic, tt.wantStatic, tt.wantDynamic)
+			}
+
+			// The bits must decode back to exactly the requested pages
+			page2 := orLockBits([]byte{0x48, 0x00, 0x00, 0x00}, 2, static)
+			dynLock := orLockBits([]byte{0x00, 0x00, 0x00, 0xBD}, 0, dynamic)
+			cfg := decodeNTAGConfig(tt.cardType, ntagLayouts[tt.cardType], page2, dynLock, make([]byte, 4), make([]byte, 4))
+			want := map[int]bool{}
+			for _, p := range tt.pages {
+				want[p] = true
+			}
+			if len(cfg.LockedPages) != len(want) {
+				t.Fatalf("lockedPages = %v, want %v", cfg.LockedPages, tt.pages)
+			}
+			for _, p := range cfg.LockedPages {
+				if !want[p] {
+					t.Fatalf("lockedPages = %v, want %v", cfg.LockedPages, tt.pages)
+				}
+			}
+		})
+	}
+}
+
+// rangeOfPages returns the pages first to last.
+func rangeOfPages(first, last int) []int {
+	var pages []int
+	for p := first; p <= last; p++ {
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+func TestNTAGLockBits_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		cardType string
+		pages    []int
+	}{
+		{"empty", "NTAG213", nil},
+		{"UID page", "NTAG213", []int{2}},
+		{"dynamic lock page", "NTAG213", []int{40}},
+		{"config page", "NTAG215", []int{131}},
+		{"half a pair", "NTAG213", []int{16}},
+		{"part of a group", "NTAG215", rangeOfPages(16, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ntagLockBits(ntagLayouts[tt.cardType], tt.pages); !errors.Is(err, ErrInvalidLockPages) {
+				t.Errorf("expected ErrInvalidLockPages, got %v", err)
+			}
+		})
+	}
+}
+
+// lockableNTAG is a simulated tag that accepts writes to the lock pages.
+type lockableNTAG struct {
+	simulatedNTAG
+	ignoreLockWrites bool // Behave as if the block-lock bits were set
+}
+
+func (s *lockableNTAG) Transmit(cmd []byte) ([]byte, error) {
+	if len(cmd) == 6 && cmd[0] == 0xA2 && (cmd[1] == 2 || int(cmd[1]) == ntagLayouts["NTAG216"].dynamicLockPage) {
+		if !s.ignoreLockWrites {
+			copy(s.pages[cmd[1]][:], cmd[2:6])
+			s.written[int(cmd[1])] = true
+		}
+		return []byte{0x0A}, nil
+	}
+	return s.simulatedNTAG.Transmit(cmd)
+}
+
+func TestSetNTAGLockBits(t *testing.T) {
+	tag := &lockableNTAG{simulatedNTAG: *newSimulatedNTAG216()}
+	layout := ntagLayouts["NTAG216"]
+	tag.pages[2] = [4]byte{0x48, 0x00, 0x10, 0x00}                      // Page 4 already locked
+	tag.pages[layout.dynamicLockPage] = [4]byte{0x00, 0x00, 0x3F, 0xBD} // Block-lock bits set
+
+	if err := setNTAGLockBits(tag, layout, 0x0100, 0x0002); err != nil {
+		t.Fatalf("setNTAGLockBits failed: %v", err)
+	}
+	if got := tag.pages[2]; got != [4]byte{0x48, 0x00, 0x10, 0x01} {
+		t.Errorf("page 2 = % X, want page 4 still locked and page 8 added", got)
+	}
+	if got := tag.pages[layout.dynamicLockPage]; got != [4]byte{0x02, 0x00, 0x3F, 0xBD} {
+		t.Errorf("dynamic lock page = % X, want bit 1 added and bytes 2-3 kept", got)
+	}
+
+	// Bits that are already set need no write
+	tag.written = map[int]bool{}
+	if err := setNTAGLockBits(tag, layout, 0x0010, 0); err != nil {
+		t.Fatalf("setNTAGLockBits failed: %v", err)
+	}
+	if len(tag.written) != 0 {
+		t.Errorf("expected no writes, wrote pages %v", tag.written)
+	}
+
+	tag.ignoreLockWrites = true
+	if err := setNTAGLockBits(tag, layout, 0x0200, 0); err == nil {
+		t.Error("expected an error when the lock bits don't take")
+	}
+}
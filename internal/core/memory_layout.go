@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/ebfe/scard"
+)
+
+// Layout is the memory map of a card type: its page or block size and the
+// regions (UID, lock bytes, user data, configuration, ...) in memory order.
+type Layout struct {
+	Type     string         `json:"type"`
+	PageSize int            `json:"pageSize"` // Bytes per page (4) or block (16)
+	Pages    int            `json:"pages"`    // Total pages or blocks
+	Regions  []MemoryRegion `json:"regions"`
+}
+
+// MemoryRegion is a contiguous area of card memory.
+type MemoryRegion struct {
+	// "uid", "internal", "lock", "cc", "user", "dynamicLock", "config",
+	// "password", "pack" (Type 2) or "manufacturer", "data", "sectorTrailer"
+	// (MIFARE Classic)
+	Name      string `json:"name"`
+	FirstPage int    `json:"firstPage"`
+	LastPage  int    `json:"lastPage"`
+	Offset    int    `json:"offset"` // Byte offset from the start of memory
+	Length    int    `json:"length"` // Bytes
+}
+
+// MemoryLayout returns the memory layout of a card type, or nil if it is not
+// known. Size-dependent types use their smallest variant: MF0UL11 for
+// "MIFARE Ultralight EV1" and 1K for "MIFARE Classic".
+func MemoryLayout(cardType string) *Layout {
+	return cardMemoryLayout(&Card{Type: cardType})
+}
+
+// ReadMemoryLayout detects the card on the reader and returns its memory
+// layout, telling the Ultralight EV1 and MIFARE Classic variants apart.
+func ReadMemoryLayout(readerName string) (_ *Layout, err error) {
+	defer trackOperation("read_memory_layout", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	cardInfo := &Card{}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+	if cardInfo.Type == "MIFARE Classic" {
+		// The SAK tells Mini, 1K, 2K and 4K apart
+		cardInfo.ATQA, cardInfo.SAK = readATQASAK(card)
+	}
+
+	layout := cardMemoryLayout(cardInfo)
+	if layout == nil {
+		return nil, fmt.Errorf("memory layout not known for card type: %s", cardInfo.Type)
+	}
+	return layout, nil
+}
+
+// cardMemoryLayout builds the layout of a detected card, or nil if its type
+// is not known.
+func cardMemoryLayout(cardInfo *Card) *Layout {
+	if cardInfo.Type == "MIFARE Classic" {
+		return mifareClassicLayout(mifareClassicBlocks(cardInfo, 0))
+	}
+
+	first, last, ok := ntagUserPageRange(cardInfo)
+	if !ok {
+		return nil
+	}
+
+	// Pages 0-3: UID and check bytes, internal byte, static lock bytes, CC
+	layout := &Layout{Type: cardInfo.Type, PageSize: 4}
+	layout.Regions = []MemoryRegion{
+		{Name: "uid", FirstPage: 0, LastPage: 2, Offset: 0, Length: 9},
+		{Name: "internal", FirstPage: 2, LastPage: 2, Offset: 9, Length: 1},
+		{Name: "lock", FirstPage: 2, LastPage: 2, Offset: 10, Length: 2},
+		pageRegion("cc", 3, 3),
+		pageRegion("user", first, last),
+	}
+	layout.Pages = last + 1
+
+	// Password-capable tags follow user memory with the dynamic lock bytes
+	// (if any), CFG0/CFG1, PWD and PACK
+	if cfg, ok := ultralightConfigPage(cardInfo); ok {
+		if cfg > last+1 {
+			layout.Regions = append(layout.Regions, pageRegion("dynamicLock", last+1, cfg-1))
+		}
+		layout.Regions = append(layout.Regions,
+			pageRegion("config", cfg, cfg+1),
+			pageRegion("password", cfg+2, cfg+2),
+			pageRegion("pack", cfg+3, cfg+3),
+		)
+		layout.Pages = cfg + 4
+	}
+	return layout
+}
+
+// mifareClassicLayout lays out a MIFARE Classic card of the given block count:
+// the manufacturer block, then each sector's data blocks and trailer.
+func mifareClassicLayout(blocks int) *Layout {
+	layout := &Layout{Type: "MIFARE Classic", PageSize: 16, Pages: blocks}
+	layout.Regions = append(layout.Regions, blockRegion("manufacturer", 0, 0))
+
+	start := 1
+	for b := 1; b < blocks; b++ {
+		if !isSectorTrailer(b) {
+			continue
+		}
+		if start < b {
+			layout.Regions = append(layout.Regions, blockRegion("data", start, b-1))
+		}
+		layout.Regions = append(layout.Regions, blockRegion("sectorTrailer", b, b))
+		start = b + 1
+	}
+	return layout
+}
+
+func pageRegion(name string, first, last int) MemoryRegion {
+	return MemoryRegion{Name: name, FirstPage: first, LastPage: last, Offset: first * 4, Length: (last - first + 1) * 4}
+}
+
+func blockRegion(name string, first, last int) MemoryRegion {
+	return MemoryRegion{Name: name, FirstPage: first, LastPage: last, Offset: first * 16, Length: (last - first + 1) * 16}
+}
@@ -0,0 +1,80 @@
+package core
+
+import "testing"
+
+func TestMemoryLayout_NTAG(t *testing.T) {
+	layout := MemoryLayout("NTAG215")
+	if layout == nil {
+		t.Fatal("expected a layout for NTAG215")
+	}
+	if layout.PageSize != 4 || layout.Pages != 135 {
+		t.Errorf("pageSize %d, pages %d; want 4, 135", layout.PageSize, layout.Pages)
+	}
+
+	want := map[string][2]int{
+		"cc":          {3, 3},
+		"user":        {4, 129},
+		"dynamicLock": {130, 130},
+		"config":      {131, 132},
+		"password":    {133, 133},
+		"pack":        {134, 134},
+	}
+	for _, r := range layout.Regions {
+		if w, ok := want[r.Name]; ok {
+			if r.FirstPage != w[0] || r.LastPage != w[1] || r.Offset != w[0]*4 {
+				t.Errorf("%s: pages %d-%d offset %d, want %d-%d offset %d", r.Name, r.FirstPage, r.LastPage, r.Offset, w[0], w[1], w[0]*4)
+			}
+			delete(want, r.Name)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("missing regions: %v", want)
+	}
+}
+
+func TestMemoryLayout_UltralightEV1Variants(t *testing.T) {
+	small := cardMemoryLayout(&Card{Type: "MIFARE Ultralight EV1", Size: 48})
+	large := cardMemoryLayout(&Card{Type: "MIFARE Ultralight EV1", Size: 128})
+	if small.Pages != 20 || large.Pages != 41 {
+		t.Errorf("pages = %d and %d, want 20 (MF0UL11) and 41 (MF0UL21)", small.Pages, large.Pages)
+	}
+	for _, r := range small.Regions {
+		if r.Name == "dynamicLock" {
+			t.Error("MF0UL11 has no dynamic lock bytes")
+		}
+	}
+
+	if plain := MemoryLayout("MIFARE Ultralight"); plain.Pages != 16 || plain.Regions[len(plain.Regions)-1].Name != "user" {
+		t.Errorf("MIFARE Ultralight layout = %+v", plain)
+	}
+}
+
+func TestMemoryLayout_MifareClassic(t *testing.T) {
+	layout := MemoryLayout("MIFARE Classic")
+	if layout.PageSize != 16 || layout.Pages != 64 {
+		t.Fatalf("pageSize %d, pages %d; want 16, 64", layout.PageSize, layout.Pages)
+	}
+
+	trailers := 0
+	for _, r := range layout.Regions {
+		if r.Name == "sectorTrailer" {
+			trailers++
+			if !isSectorTrailer(r.FirstPage) {
+				t.Errorf("block %d is not a sector trailer", r.FirstPage)
+			}
+		}
+	}
+	if trailers != 16 {
+		t.Errorf("got %d sector trailers, want 16", trailers)
+	}
+
+	if k4 := mifareClassicLayout(256); k4.Regions[len(k4.Regions)-1].FirstPage != 255 {
+		t.Errorf("4K layout should end with the trailer in block 255")
+	}
+}
+
+func TestMemoryLayout_Unknown(t *testing.T) {
+	if MemoryLayout(cardTypeUnknown) != nil {
+		t.Error("expected no layout for an unknown card type")
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+)
+
+// MADKeyA is the public key A of the MIFARE Application Directory sectors.
+var MADKeyA = []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+
+// ErrMADAuthFailed is returned by ReadMAD when a MAD sector can't be
+// authenticated with the MAD key.
+var ErrMADAuthFailed = errors.New("cannot authenticate MAD sector with the MAD key")
+
+// ErrNoMAD is returned by ReadMAD when the general purpose byte says the card
+// has no MIFARE Application Directory.
+var ErrNoMAD = errors.New("card has no MIFARE Application Directory")
+
+// MAD is a decoded MIFARE Application Directory (NXP AN10787).
+type MAD struct {
+	Version          int         `json:"version"`          // 1, or 2 for cards with more than 16 sectors
+	MultiApplication bool        `json:"multiApplication"` // MA bit of the general purpose byte
+	GPB              string      `json:"gpb"`              // General purpose byte of sector 0's trailer, hex
+	PublisherSector  int         `json:"publisherSector,omitempty"`
+	CRCValid         bool        `json:"crcValid"` // False if any MAD sector's CRC doesn't match
+	Sectors          []MADSector `json:"sectors"`
+}
+
+// MADSector is the application assigned to one sector by the MAD.
+type MADSector struct {
+	Sector      int    `json:"sector"`
+	AID         string `json:"aid"`                   // Function cluster code then application code, e.g. "E103"
+	Application string `json:"application,omitempty"` // Name of well-known AIDs, e.g. "ndef" or "free"
+}
+
+// madApplications names the reserved AIDs and common application AIDs.
+var madApplications = map[uint16]string{
+	0x0000: "free",
+	0x0001: "defect",
+	0x0002: "reserved",
+	0x0003: "additional directory info",
+	0x0004: "card holder info",
+	0x0005: "not applicable",
+	0xE103: "ndef",
+}
+
+// General purpose byte bits, MAD info byte and CRC parameters
+const (
+	gpbMADAvailable   = 0x80 // DA
+	gpbMultiApp       = 0x40 // MA
+	gpbMADVersionMask = 0x03 // ADV
+	madInfoSectorMask = 0x3F
+	madCRCPreset      = 0xC7
+	madCRCPolynomial  = 0x1D
+)
+
+// ReadMAD reads and decodes the MIFARE Application Directory of a MIFARE
+// Classic card: sector 0, plus sector 16 for a version 2 MAD. Both are
+// authenticated with key A; if key is nil/empty, MADKeyA is used.
+func ReadMAD(readerName string, key []byte) (_ *MAD, err error) {
+	defer trackOperation("read_mad", readerName, &err)()
+
+	if len(key) == 0 {
+		key = MADKeyA
+	}
+	if len(key) != 6 {
+		return nil, fmt.Errorf("MAD key must be 6 bytes")
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	sector0, err := readMADSector(card, 0, key)
+	if err != nil {
+		return nil, err
+	}
+	var sector16 [][]byte
+	if sector0[3][9]&gpbMADAvailable != 0 && sector0[3][9]&gpbMADVersionMask == 2 {
+		if sector16, err = readMADSector(card, 64, key); err != nil {
+			return nil, err
+		}
+	}
+	return decodeMAD(sector0, sector16)
+}
+
+// readMADSector reads the four blocks of the sector starting at firstBlock,
+// including the trailer, whose keys read as zeros.
+func readMADSector(card *scard.Card, firstBlock int, key []byte) ([][]byte, error) {
+	if err := authenticateMifareBlock(card, firstBlock, key, 0x60); err != nil {
+		return nil, fmt.Errorf("%w (sector %d): %v", ErrMADAuthFailed, firstBlock/4, err)
+	}
+	blocks := make([][]byte, 4)
+	for i := range blocks {
+		block := firstBlock + i
+		rsp, err := transmit(card, []byte{0xFF, 0xB0, 0x00, byte(block), 0x10})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", block, err)
+		}
+		if len(rsp) < 18 || rsp[len(rsp)-2] != 0x90 {
+			return nil, fmt.Errorf("read failed for block %d: response % X", block, rsp)
+		}
+		blocks[i] = append([]byte(nil), rsp[:16]...)
+	}
+	return blocks, nil
+}
+
+// decodeMAD decodes the blocks of sector 0 and, for a version 2 MAD, sector 16.
+func decodeMAD(sector0, sector16 [][]byte) (*MAD, error) {
+	gpb := sector0[3][9]
+	if gpb&gpbMADAvailable == 0 {
+		return nil, fmt.Errorf("%w (general purpose byte %02X)", ErrNoMAD, gpb)
+	}
+	mad := &MAD{
+		Version:          int(gpb & gpbMADVersionMask),
+		MultiApplication: gpb&gpbMultiApp != 0,
+		GPB:              fmt.Sprintf("%02X", gpb),
+	}
+	if mad.Version != 1 && mad.Version != 2 {
+		return nil, fmt.Errorf("unsupported MAD version %d (general purpose byte %02X)", mad.Version, gpb)
+	}
+
+	// MAD1: CRC, info byte and the AIDs of sectors 1-15 in blocks 1 and 2
+	mad1 := append(append([]byte(nil), sector0[1]...), sector0[2]...)
+	mad.PublisherSector = int(mad1[1] & madInfoSectorMask)
+	mad.CRCValid = madCRC(mad1[1:]) == mad1[0]
+	mad.Sectors = madSectors(mad1[2:], 1)
+
+	if mad.Version == 2 {
+		if len(sector16) < 3 {
+			return nil, fmt.Errorf("MAD version 2 needs sector 16")
+		}
+		// MAD2: CRC, info byte and the AIDs of sectors 17-39 in blocks 64-66
+		mad2 := append(append(append([]byte(nil), sector16[0]...), sector16[1]...), sector16[2]...)
+		mad.CRCValid = mad.CRCValid && madCRC(mad2[1:]) == mad2[0]
+		mad.Sectors = append(mad.Sectors, madSectors(mad2[2:], 17)...)
+	}
+	return mad, nil
+}
+
+// madSectors decodes the little-endian AIDs of consecutive sectors from
+// firstSector on.
+func madSectors(aids []byte, firstSector int) []MADSector {
+	sectors := make([]MADSector, 0, len(aids)/2)
+	for i := 0; i+1 < len(aids); i += 2 {
+		aid := uint16(aids[i+1])<<8 | uint16(aids[i])
+		sectors = append(sectors, MADSector{
+			Sector:      firstSector + i/2,
+			AID:         fmt.Sprintf("%04X", aid),
+			Application: madApplications[aid],
+		})
+	}
+	return sectors
+}
+
+// madCRC computes the MAD CRC-8 (polynomial 0x1D, preset 0xC7) over the info
+// byte and AIDs.
+func madCRC(data []byte) byte {
+	crc := byte(madCRCPreset)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ madCRCPolynomial
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// ndefMADSector0 is sector 0 of a MIFARE Classic 1K formatted for NDEF by the
+// NFC Forum mapping: every sector holds NDEF data.
+func ndefMADSector0(gpb byte) [][]byte {
+	block1 := append([]byte{0x14, 0x01}, bytes.Repeat([]byte{0x03, 0xE1}, 7)...)
+	block2 := bytes.Repeat([]byte{0x03, 0xE1}, 8)
+	trailer := []byte{0, 0, 0, 0, 0, 0, 0x78, 0x77, 0x88, gpb, 0, 0, 0, 0, 0, 0}
+	return [][]byte{make([]byte, 16), block1, block2, trailer}
+}
+
+func TestDecodeMAD_Version1(t *testing.T) {
+	mad, err := decodeMAD(ndefMADSector0(0xC1), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mad.Version != 1 || !mad.MultiApplication || mad.GPB != "C1" {
+		t.Errorf("unexpected header: %+v", mad)
+	}
+	if !mad.CRCValid {
+		t.Error("expected valid CRC")
+	}
+	if mad.PublisherSector != 1 {
+		t.Errorf("expected publisher sector 1, got %d", mad.PublisherSector)
+	}
+	if len(mad.Sectors) != 15 {
+		t.Fatalf("expected 15 sectors, got %d", len(mad.Sectors))
+	}
+	if s := mad.Sectors[0]; s.Sector != 1 || s.AID != "E103" || s.Application != "ndef" {
+		t.Errorf("unexpected sector 1: %+v", s)
+	}
+	if s := mad.Sectors[14]; s.Sector != 15 {
+		t.Errorf("expected last sector 15, got %d", s.Sector)
+	}
+
+	sector0 := ndefMADSector0(0xC1)
+	sector0[2][0] = 0x00 // Sector 8 free, CRC now stale
+	mad, _ = decodeMAD(sector0, nil)
+	if mad.CRCValid {
+		t.Error("expected CRC mismatch after changing an AID")
+	}
+	if s := mad.Sectors[7]; s.AID != "E100" || s.Application != "" {
+		t.Errorf("unexpected sector 8: %+v", s)
+	}
+}
+
+func TestDecodeMAD_Version2(t *testing.T) {
+	sector16 := [][]byte{
+		append([]byte{0x00, 0x00}, make([]byte, 14)...),
+		make([]byte, 16),
+		make([]byte, 16),
+		make([]byte, 16),
+	}
+	sector16[0][0] = madCRC(append(append(append([]byte(nil), sector16[0][1:]...), sector16[1]...), sector16[2]...))
+
+	mad, err := decodeMAD(ndefMADSector0(0xC2), sector16)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mad.Version != 2 || !mad.CRCValid {
+		t.Errorf("unexpected MAD: %+v", mad)
+	}
+	if len(mad.Sectors) != 15+23 {
+		t.Fatalf("expected 38 sectors, got %d", len(mad.Sectors))
+	}
+	if s := mad.Sectors[15]; s.Sector != 17 || s.Application != "free" {
+		t.Errorf("unexpected sector 17: %+v", s)
+	}
+	if s := mad.Sectors[len(mad.Sectors)-1]; s.Sector != 39 {
+		t.Errorf("expected last sector 39, got %d", s.Sector)
+	}
+
+	if _, err := decodeMAD(ndefMADSector0(0xC2), nil); err == nil {
+		t.Error("expected error for MAD2 without sector 16")
+	}
+}
+
+func TestDecodeMAD_NoMAD(t *testing.T) {
+	if _, err := decodeMAD(ndefMADSector0(0x69), nil); !errors.Is(err, ErrNoMAD) {
+		t.Errorf("expected ErrNoMAD, got %v", err)
+	}
+	if _, err := decodeMAD(ndefMADSector0(0x83), nil); err == nil || errors.Is(err, ErrNoMAD) {
+		t.Errorf("expected unsupported version error, got %v", err)
+	}
+}
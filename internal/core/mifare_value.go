@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrNotValueBlock is returned when a block does not hold a valid MIFARE
+// Classic value block.
+var ErrNotValueBlock = errors.New("block is not a valid value block")
+
+// MifareValue is a decoded MIFARE Classic value block.
+type MifareValue struct {
+	Value   int32 `json:"value"`
+	Address byte  `json:"address"`
+}
+
+// DecodeMifareValue decodes a 16-byte MIFARE Classic value block. The layout
+// is value, ~value, value (signed 32-bit little-endian) followed by
+// addr, ~addr, addr, ~addr; any mismatch fails with ErrNotValueBlock.
+func DecodeMifareValue(data []byte) (MifareValue, error) {
+	if len(data) != 16 {
+		return MifareValue{}, fmt.Errorf("%w: expected 16 bytes, got %d", ErrNotValueBlock, len(data))
+	}
+
+	v := binary.LittleEndian.Uint32(data[0:4])
+	if binary.LittleEndian.Uint32(data[4:8]) != ^v || binary.LittleEndian.Uint32(data[8:12]) != v {
+		return MifareValue{}, fmt.Errorf("%w: value copies do not match", ErrNotValueBlock)
+	}
+
+	addr := data[12]
+	if data[13] != ^addr || data[14] != addr || data[15] != ^addr {
+		return MifareValue{}, fmt.Errorf("%w: address copies do not match", ErrNotValueBlock)
+	}
+
+	return MifareValue{Value: int32(v), Address: addr}, nil
+}
+
+// ReadMifareValue reads a MIFARE Classic block and decodes it as a value block.
+func ReadMifareValue(readerName string, block int, key []byte, keyType byte) (MifareValue, error) {
+	data, err := ReadMifareBlock(readerName, block, key, keyType)
+	if err != nil {
+		return MifareValue{}, err
+	}
+	return DecodeMifareValue(data)
+}
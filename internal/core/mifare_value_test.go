@@ -0,0 +1,48 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDecodeMifareValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		hex     string
+		want    MifareValue
+		wantErr bool
+	}{
+		{"zero", "00000000ffffffff0000000004fb04fb", MifareValue{Value: 0, Address: 4}, false},
+		{"positive", "640000009bffffff6400000005fa05fa", MifareValue{Value: 100, Address: 5}, false},
+		{"negative", "ffffffff00000000ffffffff00ff00ff", MifareValue{Value: -1, Address: 0}, false},
+		{"inverted value mismatch", "64000000000000006400000005fa05fa", MifareValue{}, true},
+		{"third copy mismatch", "640000009bffffff6500000005fa05fa", MifareValue{}, true},
+		{"address mismatch", "640000009bffffff6400000005fa06fa", MifareValue{}, true},
+		{"inverted address mismatch", "640000009bffffff640000000500050a", MifareValue{}, true},
+		{"all zero", "00000000000000000000000000000000", MifareValue{}, true},
+		{"short", "64000000", MifareValue{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatalf("bad test data: %v", err)
+			}
+			got, err := DecodeMifareValue(data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotValueBlock) {
+					t.Errorf("expected ErrNotValueBlock, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeMifareValue() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
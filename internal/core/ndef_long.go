@@ -0,0 +1,67 @@
+package core
+
+import "fmt"
+
+// withLongRecords re-encodes the NDEF message in an NDEF TLV area so every
+// record uses the long-record format (SR clear, 4-byte payload length), even
+// for payloads under 256 bytes. Used to check that third-party readers handle
+// both encodings.
+func withLongRecords(tlv []byte) ([]byte, error) {
+	start, length, _, state := locateNDEFTLV(tlv)
+	if state != ndefTLVFound {
+		return nil, fmt.Errorf("no NDEF message to re-encode")
+	}
+	message, err := longRecordMessage(tlv[start : start+length])
+	if err != nil {
+		return nil, err
+	}
+	return wrapNDEFTLV(message), nil
+}
+
+// longRecordMessage converts the short records of an NDEF message to long
+// records. Records that already use the long format are copied unchanged.
+func longRecordMessage(message []byte) ([]byte, error) {
+	out := make([]byte, 0, len(message)+3)
+	offset := 0
+	for offset < len(message) {
+		if len(message)-offset < 3 {
+			return nil, fmt.Errorf("truncated record header at offset %d", offset)
+		}
+		header := message[offset]
+		typeLength := int(message[offset+1])
+
+		var payloadLength, headerSize int
+		if header&0x10 != 0 {
+			payloadLength = int(message[offset+2])
+			headerSize = 3
+		} else {
+			if len(message)-offset < 6 {
+				return nil, fmt.Errorf("truncated record header at offset %d", offset)
+			}
+			payloadLength = int(message[offset+2])<<24 | int(message[offset+3])<<16 | int(message[offset+4])<<8 | int(message[offset+5])
+			headerSize = 6
+		}
+		idLength := 0
+		if header&0x08 != 0 {
+			if len(message)-offset < headerSize+1 {
+				return nil, fmt.Errorf("truncated record header at offset %d", offset)
+			}
+			idLength = int(message[offset+headerSize])
+			headerSize++
+		}
+
+		end := offset + headerSize + typeLength + idLength + payloadLength
+		if end > len(message) {
+			return nil, fmt.Errorf("record at offset %d exceeds message length", offset)
+		}
+
+		out = append(out, header&^0x10, byte(typeLength),
+			byte(payloadLength>>24), byte(payloadLength>>16), byte(payloadLength>>8), byte(payloadLength))
+		if header&0x08 != 0 {
+			out = append(out, byte(idLength))
+		}
+		out = append(out, message[end-typeLength-idLength-payloadLength:end]...)
+		offset = end
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWithLongRecords(t *testing.T) {
+	uri := createNDEFRecordRaw(0x01, []byte("U"), []byte{0x04, 'e', '.', 'x'}, true, false)
+	text := createNDEFRecordWithID(0x01, []byte("T"), []byte("id"), []byte{0x02, 'e', 'n', 'H', 'i'}, false, true)
+	tlv := wrapNDEFTLV(append(uri, text...))
+
+	got, err := withLongRecords(tlv)
+	if err != nil {
+		t.Fatalf("withLongRecords: %v", err)
+	}
+
+	start, length, _, state := locateNDEFTLV(got)
+	if state != ndefTLVFound {
+		t.Fatalf("no NDEF TLV in result % X", got)
+	}
+	message := got[start : start+length]
+	want := []byte{
+		0x81, 0x01, 0x00, 0x00, 0x00, 0x04, 'U', 0x04, 'e', '.', 'x',
+		0x49, 0x01, 0x00, 0x00, 0x00, 0x05, 0x02, 'T', 'i', 'd', 0x02, 'e', 'n', 'H', 'i',
+	}
+	if !bytes.Equal(message, want) {
+		t.Fatalf("got % X, want % X", message, want)
+	}
+	if got[len(got)-1] != 0xFE {
+		t.Error("expected a terminator TLV")
+	}
+
+	// The read parser handles long records with small payloads
+	card := &Card{}
+	parseNDEFRecords(message, card)
+	if card.NDEFMalformed || len(card.Records) != 2 {
+		t.Fatalf("expected 2 well-formed records, got %+v", card)
+	}
+	if card.Records[1].ID != "id" || string(card.Records[1].Payload) != "\x02enHi" {
+		t.Errorf("unexpected text record %+v", card.Records[1])
+	}
+
+	// Already-long records are unchanged
+	again, err := withLongRecords(got)
+	if err != nil || !bytes.Equal(again, got) {
+		t.Errorf("expected long records to be kept, got % X (%v)", again, err)
+	}
+}
+
+func TestLongRecordMessage_Truncated(t *testing.T) {
+	if _, err := longRecordMessage([]byte{0xD1, 0x01, 0x05, 'U', 0x00}); err == nil {
+		t.Error("expected an error for a record exceeding the message")
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ebfe/scard"
+)
+
+// NTAGConfig is the decoded configuration area of an NTAG21x tag.
+type NTAGConfig struct {
+	Type       string `json:"type"`       // e.g. "NTAG215"
+	ConfigPage int    `json:"configPage"` // First configuration page (CFG0)
+
+	Mirror NTAGMirror `json:"mirror"`
+	Access NTAGAccess `json:"access"`
+
+	Auth0       int  `json:"auth0"`       // First page that requires the password
+	PasswordSet bool `json:"passwordSet"` // Password protection is active (AUTH0 within memory); PWD/PACK themselves read back as zeros
+
+	StaticLock  string `json:"staticLock"`  // Static lock bytes (page 2, bytes 2-3) as hex
+	DynamicLock string `json:"dynamicLock"` // Dynamic lock bytes as hex
+	LockedPages []int  `json:"lockedPages"` // Pages permanently locked by the static and dynamic lock bits
+}
+
+// NTAGMirror is the decoded UID/NFC counter ASCII mirror configuration.
+type NTAGMirror struct {
+	Mode             string `json:"mode"`             // "none", "uid", "counter" or "uid+counter"
+	Page             int    `json:"page"`             // MIRROR_PAGE: page the mirror starts on (0 = disabled)
+	Byte             int    `json:"byte"`             // MIRROR_BYTE: byte within the page
+	StrongModulation bool   `json:"strongModulation"` // STRG_MOD_EN
+}
+
+// NTAGAccess is the decoded ACCESS byte (CFG1).
+type NTAGAccess struct {
+	ReadProtected               bool `json:"readProtected"`               // PROT: reads from AUTH0 on also need the password
+	ConfigLocked                bool `json:"configLocked"`                // CFGLCK: configuration is permanently locked
+	AuthLimit                   int  `json:"authLimit"`                   // AUTHLIM: failed password attempts allowed (0 = unlimited)
+	NFCCounterEnabled           bool `json:"nfcCounterEnabled"`           // NFC_CNT_EN
+	NFCCounterPasswordProtected bool `json:"nfcCounterPasswordProtected"` // NFC_CNT_PWD_PROT
+}
+
+// ntagLayout describes where an NTAG21x keeps its dynamic lock and
+// configuration pages, and how many pages each dynamic lock bit covers.
+type ntagLayout struct {
+	dynamicLockPage int
+	pagesPerLockBit int
+}
+
+var ntagLayouts = map[string]ntagLayout{
+	"NTAG213": {dynamicLockPage: 40, pagesPerLockBit: 2},
+	"NTAG215": {dynamicLockPage: 130, pagesPerLockBit: 16},
+	"NTAG216": {dynamicLockPage: 226, pagesPerLockBit: 16},
+}
+
+// ReadNTAGConfig reads and decodes the lock bytes and configuration pages of an
+// NTAG213/215/216. The password is never read (the tag returns zeros for it).
+func ReadNTAGConfig(readerName string) (_ *NTAGConfig, err error) {
+	defer trackOperation("read_ntag_config", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	cardInfo := &Card{}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
+		return nil, fmt.Errorf("configuration pages not supported for card type: %s", cardInfo.Type)
+	}
+
+	pages := make(map[int][]byte, 4)
+	for _, page := range []int{2, layout.dynamicLockPage, layout.dynamicLockPage + 1, layout.dynamicLockPage + 2} {
+		data, err := readNTAGPage(card, page)
+		if err != nil || len(data) < 4 {
+			return nil, fmt.Errorf("failed to read page %d (read-protected tag?): %v", page, err)
+		}
+		pages[page] = data[:4]
+	}
+
+	return decodeNTAGConfig(cardInfo.Type, layout, pages[2], pages[layout.dynamicLockPage],
+		pages[layout.dynamicLockPage+1], pages[layout.dynamicLockPage+2]), nil
+}
+
+// decodeNTAGConfig decodes page 2 (static lock bytes), the dynamic lock page
+// and the CFG0/CFG1 pages.
+func decodeNTAGConfig(cardType string, layout ntagLayout, page2, dynLock, cfg0, cfg1 []byte) *NTAGConfig {
+	configPage := layout.dynamicLockPage + 1
+	cfg := &NTAGConfig{
+		Type:        cardType,
+		ConfigPage:  configPage,
+		Auth0:       int(cfg0[3]),
+		StaticLock:  hex.EncodeToString(page2[2:4]),
+		DynamicLock: hex.EncodeToString(dynLock[:3]),
+		LockedPages: []int{},
+	}
+
+	// CFG0: MIRROR, RFUI, MIRROR_PAGE, AUTH0
+	mirror := cfg0[0]
+	cfg.Mirror = NTAGMirror{
+		Mode:             [...]string{"none", "uid", "counter", "uid+counter"}[mirror>>6],
+		Byte:             int(mirror>>4) & 0x03,
+		StrongModulation: mirror&0x04 != 0,
+		Page:             int(cfg0[2]),
+	}
+
+	// Protection is off while AUTH0 points past the last page (0xFF by default)
+	cfg.PasswordSet = cfg.Auth0 <= configPage+3
+
+	// CFG1: ACCESS
+	access := cfg1[0]
+	cfg.Access = NTAGAccess{
+		ReadProtected:               access&0x80 != 0,
+		ConfigLocked:                access&0x40 != 0,
+		NFCCounterEnabled:           access&0x10 != 0,
+		NFCCounterPasswordProtected: access&0x08 != 0,
+		AuthLimit:                   int(access & 0x07),
+	}
+
+	// Static lock bits: byte 2 bit 3 locks the CC (page 3), bits 4-7 pages 4-7;
+	// byte 3 bits 0-7 lock pages 8-15
+	staticLock := uint16(page2[3])<<8 | uint16(page2[2])
+	for page := 3; page <= 15; page++ {
+		if staticLock&(1<<page) != 0 {
+			cfg.LockedPages = append(cfg.LockedPages, page)
+		}
+	}
+
+	// Dynamic lock bits (bytes 0-1) lock groups of pages from page 16 up to
+	// the dynamic lock page
+	dynamicLock := uint16(dynLock[1])<<8 | uint16(dynLock[0])
+	for bit := 0; bit < 16; bit++ {
+		first := 16 + bit*layout.pagesPerLockBit
+		if first >= layout.dynamicLockPage {
+			break
+		}
+		if dynamicLock&(1<<bit) == 0 {
+			continue
+		}
+		for page := first; page < first+layout.pagesPerLockBit && page < layout.dynamicLockPage; page++ {
+			cfg.LockedPages = append(cfg.LockedPages, page)
+		}
+	}
+
+	return cfg
+}
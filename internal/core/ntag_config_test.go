@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestDecodeNTAGConfig_FactoryDefaults(t *testing.T) {
+	layout := ntagLayouts["NTAG215"]
+	cfg := decodeNTAGConfig("NTAG215", layout,
+		[]byte{0x48, 0x00, 0x00, 0x00}, // page 2: no static locks
+		[]byte{0x00, 0x00, 0x00, 0xBD}, // dynamic lock page
+		[]byte{0x04, 0x00, 0x00, 0xFF}, // CFG0: STRG_MOD_EN, AUTH0 0xFF
+		[]byte{0x00, 0x05, 0x00, 0x00}, // CFG1: ACCESS 0
+	)
+
+	if cfg.ConfigPage != 131 || cfg.Auth0 != 255 || cfg.PasswordSet {
+		t.Errorf("unexpected protection state: %+v", cfg)
+	}
+	if cfg.Mirror != (NTAGMirror{Mode: "none", StrongModulation: true}) {
+		t.Errorf("unexpected mirror: %+v", cfg.Mirror)
+	}
+	if cfg.Access != (NTAGAccess{}) {
+		t.Errorf("unexpected access: %+v", cfg.Access)
+	}
+	if len(cfg.LockedPages) != 0 {
+		t.Errorf("expected no locked pages, got %v", cfg.LockedPages)
+	}
+}
+
+func TestDecodeNTAGConfig_Protected(t *testing.T) {
+	layout := ntagLayouts["NTAG213"]
+	cfg := decodeNTAGConfig("NTAG213", layout,
+		[]byte{0x48, 0x00, 0xF8, 0x01}, // CC and pages 4-8 locked
+		[]byte{0x03, 0x08, 0x00, 0xBD}, // pages 16-19 and 38-39 locked
+		[]byte{0xD4, 0x00, 0x05, 0x10}, // UID+counter mirror at page 5 byte 1, AUTH0 16
+		[]byte{0xC3, 0x05, 0x00, 0x00}, // PROT, CFGLCK, AUTHLIM 3
+	)
+
+	if cfg.Auth0 != 16 || !cfg.PasswordSet {
+		t.Errorf("expected password protection from page 16, got auth0=%d passwordSet=%v", cfg.Auth0, cfg.PasswordSet)
+	}
+	wantMirror := NTAGMirror{Mode: "uid+counter", Page: 5, Byte: 1, StrongModulation: true}
+	if cfg.Mirror != wantMirror {
+		t.Errorf("mirror = %+v, want %+v", cfg.Mirror, wantMirror)
+	}
+	wantAccess := NTAGAccess{ReadProtected: true, ConfigLocked: true, AuthLimit: 3}
+	if cfg.Access != wantAccess {
+		t.Errorf("access = %+v, want %+v", cfg.Access, wantAccess)
+	}
+
+	want := []int{3, 4, 5, 6, 7, 8, 16, 17, 18, 19, 38, 39}
+	if len(cfg.LockedPages) != len(want) {
+		t.Fatalf("lockedPages = %v, want %v", cfg.LockedPages, want)
+	}
+	for i := range want {
+		if cfg.LockedPages[i] != want[i] {
+			t.Fatalf("lockedPages = %v, want %v", cfg.LockedPages, want)
+		}
+	}
+	if cfg.StaticLock != "f801" || cfg.DynamicLock != "030800" {
+		t.Errorf("unexpected lock bytes: static %s dynamic %s", cfg.StaticLock, cfg.DynamicLock)
+	}
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ebfe/scard"
+)
+
+// ErrInvalidMirrorTemplate is returned for URL templates the NTAG mirror cannot fill.
+var ErrInvalidMirrorTemplate = errors.New("invalid mirror URL template")
+
+// Mirror placeholders and the ASCII length the tag substitutes for them: the
+// 7-byte UID as 14 hex characters, the 24-bit NFC counter as 6, and both
+// separated by an 'x' that the tag writes itself.
+const (
+	mirrorUIDPlaceholder     = "{UID}"
+	mirrorCounterPlaceholder = "{CNT}"
+	mirrorBothPlaceholder    = mirrorUIDPlaceholder + "x" + mirrorCounterPlaceholder
+
+	mirrorUIDLen     = 14
+	mirrorCounterLen = 6
+	mirrorBothLen    = mirrorUIDLen + 1 + mirrorCounterLen
+)
+
+// MIRROR_CONF values (CFG0 byte 0, bits 7-6)
+const (
+	mirrorConfUID     byte = 0x01
+	mirrorConfCounter byte = 0x02
+	mirrorConfBoth    byte = 0x03
+)
+
+// mirroredURL is an NDEF URI TLV with a zero-filled placeholder, plus the mirror
+// settings that make the tag fill it in.
+type mirroredURL struct {
+	tlv        []byte
+	conf       byte
+	mirrorPage int
+	mirrorByte int
+}
+
+// buildMirroredURL expands a URL template containing {UID}, {CNT} or
+// {UID}x{CNT} into an NDEF URI TLV written from page 4, and works out where the
+// placeholder lands in tag memory.
+func buildMirroredURL(template string) (*mirroredURL, error) {
+	placeholder, conf, length := "", byte(0), 0
+	hasUID := strings.Count(template, mirrorUIDPlaceholder)
+	hasCounter := strings.Count(template, mirrorCounterPlaceholder)
+	switch {
+	case hasUID > 1 || hasCounter > 1:
+		return nil, fmt.Errorf("%w: each placeholder may appear only once", ErrInvalidMirrorTemplate)
+	case hasUID == 1 && hasCounter == 1:
+		if !strings.Contains(template, mirrorBothPlaceholder) {
+			return nil, fmt.Errorf("%w: {UID} and {CNT} must be written together as %s", ErrInvalidMirrorTemplate, mirrorBothPlaceholder)
+		}
+		placeholder, conf, length = mirrorBothPlaceholder, mirrorConfBoth, mirrorBothLen
+	case hasUID == 1:
+		placeholder, conf, length = mirrorUIDPlaceholder, mirrorConfUID, mirrorUIDLen
+	case hasCounter == 1:
+		placeholder, conf, length = mirrorCounterPlaceholder, mirrorConfCounter, mirrorCounterLen
+	default:
+		return nil, fmt.Errorf("%w: template needs a {UID} or {CNT} placeholder", ErrInvalidMirrorTemplate)
+	}
+
+	url := strings.Replace(template, placeholder, strings.Repeat("0", length), 1)
+	if strings.ContainsAny(url, "{}") {
+		return nil, fmt.Errorf("%w: unknown placeholder in %q", ErrInvalidMirrorTemplate, template)
+	}
+
+	tlv := createNDEFURIRecord(url)
+
+	// The URI prefix code replaces the start of the URL, so locate the
+	// placeholder in the abbreviated payload
+	_, remainder := findURIPrefix(url)
+	offset := strings.Index(template, placeholder) - (len(url) - len(remainder))
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: placeholder cannot be part of the URL scheme", ErrInvalidMirrorTemplate)
+	}
+	payloadStart := len(tlv) - 1 - len(remainder) // Before the terminator TLV
+	addr := 4*4 + payloadStart + offset           // NDEF TLV starts at page 4
+
+	return &mirroredURL{
+		tlv:        tlv,
+		conf:       conf,
+		mirrorPage: addr / 4,
+		mirrorByte: addr % 4,
+	}, nil
+}
+
+// ValidateMirrorTemplate checks that a URL template can be filled by the NTAG
+// UID/counter mirror, without touching the card.
+func ValidateMirrorTemplate(template string) error {
+	_, err := buildMirroredURL(template)
+	return err
+}
+
+// WriteMirroredURL writes a URL template such as
+// "https://example.com/t?id={UID}&c={CNT}" to an NTAG213/215/216 and configures
+// the UID/NFC counter mirror so the tag fills in the placeholders on every read.
+// {UID} and {CNT} may be used alone, or together as "{UID}x{CNT}" (the tag
+// writes the separator). Using {CNT} also enables the NFC counter.
+func WriteMirroredURL(readerName, template string) error {
+	return WriteMirroredURLWithOptions(readerName, template, WriteOptions{})
+}
+
+// WriteMirroredURLWithOptions is like WriteMirroredURL but applies write
+// options (only Force and ExpectUID are used). Like the other NDEF writes it
+// refuses to overwrite a write-protected OpenPrintTag unless Force is set.
+func WriteMirroredURLWithOptions(readerName, template string, opts WriteOptions) (err error) {
+	defer trackOperation("write_mirrored_url", readerName, &err)()
+
+	mirrored, err := buildMirroredURL(template)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		return fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return err
+	}
+
+	cardInfo := &Card{}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+
+	layout, ok := ntagLayouts[cardInfo.Type]
+	if !ok {
+		return fmt.Errorf("UID/counter mirror not supported for card type: %s", cardInfo.Type)
+	}
+	if err := checkNDEFWritable(cardInfo); err != nil {
+		return err
+	}
+	if err := checkWriteProtection(card, cardInfo, opts.Force); err != nil {
+		return err
+	}
+	mirrored.tlv = omitTerminatorIfFull(cardInfo, 4, mirrored.tlv)
+	if err := checkUserMemory(cardInfo, 4, len(mirrored.tlv)); err != nil {
+		return err
+	}
+
+	cfgPage := layout.dynamicLockPage + 1
+	cfg0, err := readNTAGPage(card, cfgPage)
+	if err != nil || len(cfg0) < 4 {
+		return fmt.Errorf("failed to read CFG0 page: %v", err)
+	}
+	cfg1, err := readNTAGPage(card, cfgPage+1)
+	if err != nil || len(cfg1) < 4 {
+		return fmt.Errorf("failed to read CFG1 page: %v", err)
+	}
+	if cfg1[0]&0x40 != 0 {
+		return fmt.Errorf("configuration is locked (CFGLCK set)")
+	}
+
+	if err := writeNTAGPages(card, 4, mirrored.tlv); err != nil {
+		return fmt.Errorf("failed to write NDEF records: %w", err)
+	}
+
+	// CFG0: keep STRG_MOD_EN and AUTH0, replace MIRROR_CONF, MIRROR_BYTE and MIRROR_PAGE
+	newCfg0 := []byte{
+		mirrored.conf<<6 | byte(mirrored.mirrorByte)<<4 | cfg0[0]&0x04,
+		cfg0[1],
+		byte(mirrored.mirrorPage),
+		cfg0[3],
+	}
+	waitWriteSettle()
+	if err := writeNTAGPages(card, cfgPage, newCfg0); err != nil {
+		return fmt.Errorf("failed to write mirror configuration: %w", err)
+	}
+
+	if mirrored.conf&mirrorConfCounter != 0 && cfg1[0]&0x10 == 0 {
+		newCfg1 := []byte{cfg1[0] | 0x10, cfg1[1], cfg1[2], cfg1[3]}
+		if err := writeNTAGPages(card, cfgPage+1, newCfg1); err != nil {
+			return fmt.Errorf("failed to enable NFC counter: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuildMirroredURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		conf     byte
+		length   int
+		filled   string
+	}{
+		{"uid", "https://x.io/t?id={UID}", mirrorConfUID, mirrorUIDLen, "https://x.io/t?id=04A1B2C3D4E5F6"},
+		{"counter", "https://x.io/t?c={CNT}&v=1", mirrorConfCounter, mirrorCounterLen, "https://x.io/t?c=00002A&v=1"},
+		{"both", "https://x.io/t?m={UID}x{CNT}", mirrorConfBoth, mirrorBothLen, "https://x.io/t?m=04A1B2C3D4E5F6x00002A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := buildMirroredURL(tt.template)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m.conf != tt.conf {
+				t.Errorf("conf = %d, want %d", m.conf, tt.conf)
+			}
+			if m.mirrorPage < 4 || m.mirrorByte > 3 {
+				t.Fatalf("mirror position out of range: page %d byte %d", m.mirrorPage, m.mirrorByte)
+			}
+
+			// Simulate the tag filling the placeholder at the mirror position,
+			// then check the NDEF decodes to the expected URL
+			memory := make([]byte, 16, 16+len(m.tlv))
+			memory = append(memory, m.tlv...)
+			pos := m.mirrorPage*4 + m.mirrorByte
+			value := strings.TrimPrefix(tt.filled, tt.template[:strings.Index(tt.template, "{")])
+			copy(memory[pos:pos+tt.length], value[:tt.length])
+
+			card := &Card{}
+			parseNDEFRecords(memory[16+2:len(memory)-1], card)
+			if card.URL != tt.filled {
+				t.Errorf("URL = %q, want %q", card.URL, tt.filled)
+			}
+		})
+	}
+}
+
+func TestBuildMirroredURL_Invalid(t *testing.T) {
+	templates := []string{
+		"https://x.io/t",
+		"https://x.io/{UID}/{UID}",
+		"https://x.io/{UID}/{CNT}",
+		"https://x.io/{UID}?v={VER}",
+	}
+
+	for _, template := range templates {
+		if _, err := buildMirroredURL(template); !errors.Is(err, ErrInvalidMirrorTemplate) {
+			t.Errorf("%q: expected ErrInvalidMirrorTemplate, got %v", template, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// ErrNoOpenPrintTag is returned when the card holds no OpenPrintTag record.
+var ErrNoOpenPrintTag = errors.New("no OpenPrintTag record found on card")
+
+// ErrNDEFIncomplete is returned when the card's NDEF message is malformed or
+// could not be read in full, so it can't be patched in place.
+var ErrNDEFIncomplete = errors.New("NDEF message is malformed or incomplete")
+
+// PatchOpenPrintTagAux applies a JSON Patch to the aux section of the card's
+// OpenPrintTag and writes it back, keeping the meta and main sections and any
+// other records as they are. The read and the write share one connection, so
+// the patch applies to the tag it was computed from, and only the pages or
+// blocks whose bytes change are written. Only ExpectUID is used from opts;
+// write-protection of the main section does not apply, since it is never
+// touched. Returns the updated tag.
+func PatchOpenPrintTagAux(readerName string, ops []openprinttag.AuxPatchOp, opts WriteOptions) (_ *openprinttag.OpenPrintTag, err error) {
+	defer trackOperation("patch_openprinttag_aux", readerName, &err)()
+
+	ctx, err := establishContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return nil, err
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	if err := checkExpectedUID(card, opts.ExpectUID); err != nil {
+		return nil, err
+	}
+
+	status, err := card.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card status: %w", err)
+	}
+	cardInfo := &Card{
+		ATR: hex.EncodeToString(status.Atr),
+	}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+	readNDEFData(card, cardInfo, ReadOptions{Debug: true})
+	if cardInfo.NDEFMalformed {
+		return nil, fmt.Errorf("%w: %s", ErrNDEFIncomplete, cardInfo.NDEFMalformedReason)
+	}
+	if cardInfo.RawNDEF == nil && cardInfo.Debug != nil && cardInfo.Debug.TLVOffset >= 0 {
+		return nil, fmt.Errorf("%w: NDEF TLV declares %d bytes but the read stopped after %d pages", ErrNDEFIncomplete, cardInfo.Debug.NDEFLength, cardInfo.PagesRead)
+	}
+
+	message, updated, err := patchOpenPrintTagRecord(cardInfo.Records, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeChangedNDEF(card, cardInfo, message); err != nil {
+		return nil, err
+	}
+
+	logging.Info(logging.CatCard, "OpenPrintTag aux section updated", map[string]any{
+		"reader":         readerName,
+		"consumedWeight": updated.Aux.ConsumedWeight,
+	})
+	return updated, nil
+}
+
+// patchOpenPrintTagRecord applies ops to the aux section of the first
+// OpenPrintTag record and returns the rebuilt NDEF message with the decoded
+// result. The other records are copied unchanged.
+func patchOpenPrintTagRecord(records []ParsedRecord, ops []openprinttag.AuxPatchOp) ([]byte, *openprinttag.OpenPrintTag, error) {
+	index := -1
+	for i, rec := range records {
+		if rec.DataType == "openprinttag" {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, nil, ErrNoOpenPrintTag
+	}
+
+	rec := records[index]
+	current, err := openprinttag.Decode(rec.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode OpenPrintTag: %w", err)
+	}
+	aux, err := openprinttag.ApplyAuxPatch(current.Aux, ops)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := openprinttag.ReplaceAux(rec.Payload, aux)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated, err := openprinttag.Decode(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode updated OpenPrintTag: %w", err)
+	}
+
+	var message []byte
+	for i, r := range records {
+		if i == index {
+			var id []byte
+			if r.ID != "" {
+				id = []byte(r.ID)
+			}
+			message = append(message, createNDEFRecordWithID(r.TNF, []byte(r.Type), id, payload, i == 0, i == len(records)-1)...)
+			continue
+		}
+		message = append(message, r.RawBytes...)
+	}
+	return message, updated, nil
+}
+
+// writeChangedNDEF replaces the NDEF message read into cardInfo (with
+// ReadOptions.Debug) by message, writing only the pages (Type 2) or blocks
+// (MIFARE Classic) whose bytes change. TLVs in front of the NDEF TLV are
+// kept. Other tag types get the whole message written.
+func writeChangedNDEF(card *scard.Card, cardInfo *Card, message []byte) error {
+	classic := cardInfo.Type == "MIFARE Classic"
+	if cardInfo.Debug == nil || cardInfo.Debug.TLVOffset < 0 || cardInfo.Type == cardTypeType4 || isISO15693ATR(cardInfo.ATR) {
+		return writeNDEFTLV(card, cardInfo, wrapNDEFTLV(message))
+	}
+	old, err := hex.DecodeString(cardInfo.Debug.RawHex)
+	if err != nil {
+		return fmt.Errorf("failed to decode NDEF data read: %w", err)
+	}
+	area := append(append([]byte(nil), old[:cardInfo.Debug.TLVOffset]...), wrapNDEFTLV(message)...)
+
+	unit := 4
+	if classic {
+		unit = 16
+	} else {
+		if err := checkNDEFWritable(cardInfo); err != nil {
+			return err
+		}
+		area = omitTerminatorIfFull(cardInfo, 4, area)
+		if err := checkUserMemory(cardInfo, 4, len(area)); err != nil {
+			return err
+		}
+	}
+
+	first, data := changedUnits(old, area, unit)
+	if data == nil {
+		return nil
+	}
+	logCard(card, logging.LevelDebug, logging.CatCard, "Writing changed NDEF bytes", map[string]any{
+		"offset": first,
+		"bytes":  len(data),
+	})
+	if classic {
+		err = writeMifareClassicBlocks(card, first/16, data)
+	} else {
+		err = writeNTAGPages(card, 4+first/4, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write NDEF records: %w", err)
+	}
+	return nil
+}
+
+// changedUnits returns the offset and bytes of the run of unit-sized pages or
+// blocks covering every byte where updated differs from old (bytes past the
+// end of old count as changed). The last unit is filled from old, or zeros
+// past its end. data is nil when nothing changed.
+func changedUnits(old, updated []byte, unit int) (first int, data []byte) {
+	first, end := -1, 0
+	for i, b := range updated {
+		if i >= len(old) || old[i] != b {
+			if first < 0 {
+				first = i
+			}
+			end = i + 1
+		}
+	}
+	if first < 0 {
+		return 0, nil
+	}
+
+	first -= first % unit
+	data = append([]byte(nil), updated[first:end]...)
+	for i := end; (i-first)%unit != 0; i++ {
+		switch {
+		case i < len(updated):
+			data = append(data, updated[i])
+		case i < len(old):
+			data = append(data, old[i])
+		default:
+			data = append(data, 0x00)
+		}
+	}
+	return first, data
+}
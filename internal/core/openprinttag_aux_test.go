@@ -0,0 +1,102 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+func TestPatchOpenPrintTagRecord(t *testing.T) {
+	opt := &openprinttag.OpenPrintTag{
+		Main: openprinttag.MainSection{MaterialName: "PLA", BrandName: "Brand", NominalNettoFullWeight: 1000},
+		Aux:  openprinttag.AuxSection{ConsumedWeight: 200},
+	}
+	payload, err := opt.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := createNDEFRecordRaw(0x01, []byte("U"), append([]byte{0x04}, "example.com"...), true, false)
+	message := append(uri, createNDEFRecordRaw(0x02, []byte(openprinttag.MIMEType), payload, false, true)...)
+
+	card := &Card{}
+	parseNDEFRecords(message, card)
+
+	ops := []openprinttag.AuxPatchOp{{Op: "increment", Path: "/consumedWeight", Value: json.RawMessage("25")}}
+	patched, updated, err := patchOpenPrintTagRecord(card.Records, ops)
+	if err != nil {
+		t.Fatalf("patchOpenPrintTagRecord failed: %v", err)
+	}
+	if updated.Aux.ConsumedWeight != 225 || updated.Main.MaterialName != "PLA" {
+		t.Errorf("updated tag = %+v", updated)
+	}
+
+	result := &Card{}
+	parseNDEFRecords(patched, result)
+	if result.NDEFMalformed || len(result.Records) != 2 {
+		t.Fatalf("rebuilt message has %d records (malformed: %v)", len(result.Records), result.NDEFMalformed)
+	}
+	if !bytes.Equal(result.Records[0].RawBytes, uri) {
+		t.Error("other records must be kept unchanged")
+	}
+	decoded, _ := openprinttag.Decode(result.Records[1].Payload)
+	if decoded == nil || decoded.Aux.ConsumedWeight != 225 {
+		t.Errorf("written aux = %+v", decoded)
+	}
+}
+
+func TestPatchOpenPrintTagRecord_NoOpenPrintTag(t *testing.T) {
+	card := &Card{}
+	parseNDEFRecords(createNDEFRecordRaw(0x01, []byte("T"), []byte("\x02enhello"), true, true), card)
+
+	ops := []openprinttag.AuxPatchOp{{Op: "remove", Path: "/workgroup"}}
+	if _, _, err := patchOpenPrintTagRecord(card.Records, ops); !errors.Is(err, ErrNoOpenPrintTag) {
+		t.Errorf("expected ErrNoOpenPrintTag, got %v", err)
+	}
+}
+
+func TestPatchOpenPrintTagRecord_KeepsID(t *testing.T) {
+	payload, err := (&openprinttag.OpenPrintTag{Main: openprinttag.MainSection{MaterialName: "PLA"}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := &Card{}
+	parseNDEFRecords(createNDEFRecordWithID(0x02, []byte(openprinttag.MIMEType), []byte("spool"), payload, true, true), card)
+
+	ops := []openprinttag.AuxPatchOp{{Op: "replace", Path: "/consumedWeight", Value: json.RawMessage("10")}}
+	patched, _, err := patchOpenPrintTagRecord(card.Records, ops)
+	if err != nil {
+		t.Fatalf("patchOpenPrintTagRecord failed: %v", err)
+	}
+	result := &Card{}
+	parseNDEFRecords(patched, result)
+	if len(result.Records) != 1 || result.Records[0].ID != "spool" {
+		t.Errorf("records = %+v, want the record ID kept", result.Records)
+	}
+}
+
+func TestChangedUnits(t *testing.T) {
+	old := []byte{0x01, 0x03, 0x06, 0xD1, 0x01, 0x02, 0x54, 0x41, 0x42, 0xFE, 0x00, 0x00}
+	tests := []struct {
+		name      string
+		updated   []byte
+		unit      int
+		wantFirst int
+		wantData  []byte
+	}{
+		{"unchanged", old[:10], 4, 0, nil},
+		{"one byte", []byte{0x01, 0x03, 0x06, 0xD1, 0x01, 0x02, 0x54, 0x41, 0x43, 0xFE}, 4, 8, []byte{0x43, 0xFE, 0x00, 0x00}},
+		{"grown past old", []byte{0x01, 0x03, 0x06, 0xD1, 0x01, 0x02, 0x54, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0xFE}, 4, 8, []byte{0x42, 0x43, 0x44, 0x45, 0x46, 0xFE, 0x00, 0x00}},
+		{"block unit", []byte{0x01, 0x03, 0x06, 0xD1, 0x01, 0x02, 0x54, 0x41, 0x43, 0xFE}, 16, 0, []byte{0x01, 0x03, 0x06, 0xD1, 0x01, 0x02, 0x54, 0x41, 0x43, 0xFE, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, data := changedUnits(old, tt.updated, tt.unit)
+			if first != tt.wantFirst || !bytes.Equal(data, tt.wantData) {
+				t.Errorf("changedUnits = %d, % X; want %d, % X", first, data, tt.wantFirst, tt.wantData)
+			}
+		})
+	}
+}
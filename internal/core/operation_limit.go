@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTooManyOperations is returned when the concurrent operation limit is
+// reached and the queue of waiting operations is full, or an operation waited
+// in the queue for longer than OperationQueueTimeout.
+var ErrTooManyOperations = errors.New("too many concurrent card operations")
+
+// Limits on concurrent PC/SC contexts, which protect pcscd on constrained
+// hardware from bursts of API calls. Per-reader claims still apply on top.
+var (
+	MaxConcurrentOperations = 4               // Contexts held at once (0 = unlimited)
+	MaxQueuedOperations     = 16              // Operations allowed to wait for a slot
+	OperationQueueTimeout   = 5 * time.Second // Longest wait for a slot
+)
+
+var operationSlots = struct {
+	mu     sync.Mutex
+	active int
+	queued int
+	freed  chan struct{} // Closed and replaced whenever a slot is released
+}{freed: make(chan struct{})}
+
+// acquireOperationSlot waits for one of the MaxConcurrentOperations slots and
+// returns the function that releases it. Operations beyond the limit queue;
+// once MaxQueuedOperations are waiting, further ones fail immediately.
+func acquireOperationSlot() (func(), error) {
+	operationSlots.mu.Lock()
+	if MaxConcurrentOperations <= 0 || operationSlots.active < MaxConcurrentOperations {
+		operationSlots.active++
+		operationSlots.mu.Unlock()
+		return releaseOperationSlotOnce(), nil
+	}
+	if operationSlots.queued >= MaxQueuedOperations {
+		operationSlots.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d running, %d queued", ErrTooManyOperations, MaxConcurrentOperations, MaxQueuedOperations)
+	}
+	operationSlots.queued++
+
+	timer := time.NewTimer(OperationQueueTimeout)
+	defer timer.Stop()
+	for {
+		freed := operationSlots.freed
+		operationSlots.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			operationSlots.mu.Lock()
+			operationSlots.queued--
+			operationSlots.mu.Unlock()
+			return nil, fmt.Errorf("%w: no slot free after %v", ErrTooManyOperations, OperationQueueTimeout)
+		}
+
+		operationSlots.mu.Lock()
+		if operationSlots.active < MaxConcurrentOperations {
+			operationSlots.active++
+			operationSlots.queued--
+			operationSlots.mu.Unlock()
+			return releaseOperationSlotOnce(), nil
+		}
+	}
+}
+
+// releaseOperationSlotOnce returns a function that releases a slot the first
+// time it is called and wakes the queued operations.
+func releaseOperationSlotOnce() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			operationSlots.mu.Lock()
+			operationSlots.active--
+			close(operationSlots.freed)
+			operationSlots.freed = make(chan struct{})
+			operationSlots.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setOperationLimits overrides the operation limits for one test.
+func setOperationLimits(t *testing.T, concurrent, queued int, timeout time.Duration) {
+	t.Helper()
+	oldConcurrent, oldQueued, oldTimeout := MaxConcurrentOperations, MaxQueuedOperations, OperationQueueTimeout
+	MaxConcurrentOperations, MaxQueuedOperations, OperationQueueTimeout = concurrent, queued, timeout
+	t.Cleanup(func() {
+		MaxConcurrentOperations, MaxQueuedOperations, OperationQueueTimeout = oldConcurrent, oldQueued, oldTimeout
+	})
+}
+
+func TestAcquireOperationSlot_QueuesUntilReleased(t *testing.T) {
+	setOperationLimits(t, 1, 1, time.Second)
+
+	release, err := acquireOperationSlot()
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		release2, err := acquireOperationSlot()
+		if err == nil {
+			release2()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second operation should wait while the slot is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	release() // Releasing twice must not free a second slot
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("queued operation failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued operation was not woken by the release")
+	}
+
+	operationSlots.mu.Lock()
+	active, queued := operationSlots.active, operationSlots.queued
+	operationSlots.mu.Unlock()
+	if active != 0 || queued != 0 {
+		t.Errorf("expected no active or queued operations, got %d and %d", active, queued)
+	}
+}
+
+func TestAcquireOperationSlot_QueueFull(t *testing.T) {
+	setOperationLimits(t, 1, 0, time.Second)
+
+	release, err := acquireOperationSlot()
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	defer release()
+
+	if _, err := acquireOperationSlot(); !errors.Is(err, ErrTooManyOperations) {
+		t.Errorf("expected ErrTooManyOperations with a full queue, got %v", err)
+	}
+}
+
+func TestAcquireOperationSlot_QueueTimeout(t *testing.T) {
+	setOperationLimits(t, 1, 1, 20*time.Millisecond)
+
+	release, err := acquireOperationSlot()
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	defer release()
+
+	if _, err := acquireOperationSlot(); !errors.Is(err, ErrTooManyOperations) {
+		t.Errorf("expected ErrTooManyOperations after the queue timeout, got %v", err)
+	}
+
+	operationSlots.mu.Lock()
+	queued := operationSlots.queued
+	operationSlots.mu.Unlock()
+	if queued != 0 {
+		t.Errorf("timed-out operation still counted as queued (%d)", queued)
+	}
+}
+
+func TestAcquireOperationSlot_Unlimited(t *testing.T) {
+	setOperationLimits(t, 0, 0, time.Second)
+
+	var releases []func()
+	for i := 0; i < 10; i++ {
+		release, err := acquireOperationSlot()
+		if err != nil {
+			t.Fatalf("acquire %d failed with no limit: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	for _, release := range releases {
+		release()
+	}
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/ebfe/scard"
+)
+
+// ErrPCSCUnavailable is returned while the PC/SC service is down (e.g. pcscd
+// is restarting). The agent reconnects in the background.
+var ErrPCSCUnavailable = errors.New("PC/SC service unavailable")
+
+// Backoff bounds for background reconnect attempts.
+var (
+	ReconnectInitialDelay = 500 * time.Millisecond
+	ReconnectMaxDelay     = 30 * time.Second
+)
+
+// PCSCStatus describes the state of the PC/SC service connection.
+type PCSCStatus struct {
+	Available bool       `json:"available"`
+	Since     *time.Time `json:"since,omitempty"`    // When the service became unavailable
+	Error     string     `json:"error,omitempty"`    // Most recent PC/SC error
+	Attempts  int        `json:"attempts,omitempty"` // Reconnect attempts so far
+}
+
+// pcscState is the PC/SC service state. generation changes whenever the
+// state is reset, which stops a running reconnect loop.
+var pcscState = struct {
+	mu          sync.Mutex
+	unavailable bool
+	since       time.Time
+	lastErr     string
+	attempts    int
+	generation  int
+}{}
+
+// resetPCSCState marks PC/SC available and never established, and stops any
+// reconnect loop. Used by tests.
+func resetPCSCState() {
+	pcscState.mu.Lock()
+	defer pcscState.mu.Unlock()
+	pcscState.unavailable = false
+	pcscState.since = time.Time{}
+	pcscState.lastErr = ""
+	pcscState.attempts = 0
+	pcscState.generation++
+	pcscEstablished.Store(false)
+}
+
+// pcscProbe checks whether a PC/SC context can be established.
+// Overridden in tests.
+var pcscProbe = func() error {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return err
+	}
+	ctx.Release()
+	return nil
+}
+
+// pcscEstablished is set once a PC/SC context has been established.
+var pcscEstablished atomic.Bool
+
+// PCSCReady reports whether a PC/SC context has been established at least
+// once since startup. Until then it tries to establish one on each call.
+func PCSCReady() bool {
+	if pcscEstablished.Load() {
+		return true
+	}
+	if pcscProbe() != nil {
+		return false
+	}
+	pcscEstablished.Store(true)
+	return true
+}
+
+// GetPCSCStatus returns the current PC/SC service state.
+func GetPCSCStatus() PCSCStatus {
+	pcscState.mu.Lock()
+	defer pcscState.mu.Unlock()
+
+	if !pcscState.unavailable {
+		return PCSCStatus{Available: true}
+	}
+	since := pcscState.since
+	return PCSCStatus{
+		Since:    &since,
+		Error:    pcscState.lastErr,
+		Attempts: pcscState.attempts,
+	}
+}
+
+// pcscContext is a PC/SC context holding one of the concurrent operation
+// slots (see MaxConcurrentOperations) until it is released.
+type pcscContext struct {
+	*scard.Context
+	releaseSlot func()
+}
+
+// Release releases the PC/SC context and its operation slot.
+func (c *pcscContext) Release() error {
+	defer c.releaseSlot()
+	return c.Context.Release()
+}
+
+// establishContext waits for a concurrent operation slot and establishes a
+// PC/SC context. While the service is known to be down it fails fast with
+// ErrPCSCUnavailable instead of hitting PC/SC.
+func establishContext() (*pcscContext, error) {
+	if !GetPCSCStatus().Available {
+		return nil, ErrPCSCUnavailable
+	}
+
+	release, err := acquireOperationSlot()
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := establishUnlimitedContext()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &pcscContext{Context: ctx, releaseSlot: release}, nil
+}
+
+// establishUnlimitedContext establishes a PC/SC context without taking an
+// operation slot. Used for listing readers, which precedes every reader
+// request and must keep working while card operations are queued.
+func establishUnlimitedContext() (*scard.Context, error) {
+	if !GetPCSCStatus().Available {
+		return nil, ErrPCSCUnavailable
+	}
+
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, handlePCSCError(err)
+	}
+	pcscEstablished.Store(true)
+	return ctx, nil
+}
+
+// isPCSCServiceError reports whether err means the PC/SC service itself is gone.
+func isPCSCServiceError(err error) bool {
+	return errors.Is(err, scard.ErrNoService) || errors.Is(err, scard.ErrServiceStopped)
+}
+
+// handlePCSCError checks a PC/SC error for a lost service. If a service that
+// worked before is gone it marks PC/SC unavailable, starts the background
+// reconnect loop and returns ErrPCSCUnavailable; other errors are returned
+// unchanged. A service that has never been reached (e.g. none is installed)
+// isn't an outage: the error is returned without changing the state, and
+// the next operation tries again.
+func handlePCSCError(err error) error {
+	if !isPCSCServiceError(err) {
+		return err
+	}
+	if !pcscEstablished.Load() {
+		return fmt.Errorf("%w: %v", ErrPCSCUnavailable, err)
+	}
+
+	pcscState.mu.Lock()
+	alreadyDown := pcscState.unavailable
+	if !alreadyDown {
+		pcscState.unavailable = true
+		pcscState.since = time.Now()
+		pcscState.attempts = 0
+	}
+	pcscState.lastErr = err.Error()
+	generation := pcscState.generation
+	pcscState.mu.Unlock()
+
+	if !alreadyDown {
+		logging.Error(logging.CatReader, "PC/SC service unavailable, reconnecting in background", map[string]any{
+			"error": err.Error(),
+		})
+		go reconnectPCSC(generation)
+	}
+
+	return fmt.Errorf("%w: %v", ErrPCSCUnavailable, err)
+}
+
+// reconnectPCSC retries establishing a context with exponential backoff until
+// the service is back, then marks PC/SC available again. It stops early if the
+// state is reset.
+func reconnectPCSC(generation int) {
+	defer logging.RecoverAndLog("PC/SC reconnect", false)
+
+	delay := ReconnectInitialDelay
+	for {
+		time.Sleep(delay)
+
+		err := pcscProbe()
+
+		pcscState.mu.Lock()
+		if pcscState.generation != generation {
+			pcscState.mu.Unlock()
+			return
+		}
+		pcscState.attempts++
+		attempts := pcscState.attempts
+		if err == nil {
+			downtime := time.Since(pcscState.since)
+			pcscState.unavailable = false
+			pcscState.lastErr = ""
+			pcscState.mu.Unlock()
+
+			logging.Info(logging.CatReader, "PC/SC service recovered", map[string]any{
+				"attempts":   attempts,
+				"downtimeMs": downtime.Milliseconds(),
+			})
+			return
+		}
+		pcscState.lastErr = err.Error()
+		pcscState.mu.Unlock()
+
+		logging.Debug(logging.CatReader, "PC/SC reconnect attempt failed", map[string]any{
+			"attempt": attempts,
+			"error":   err.Error(),
+			"retryMs": delay.Milliseconds(),
+		})
+
+		delay *= 2
+		if delay > ReconnectMaxDelay {
+			delay = ReconnectMaxDelay
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestHandlePCSCError_PassesOtherErrorsThrough(t *testing.T) {
+	err := errors.New("some other failure")
+	if got := handlePCSCError(err); got != err {
+		t.Errorf("expected error to be returned unchanged, got %v", got)
+	}
+	if !GetPCSCStatus().Available {
+		t.Error("PC/SC should still be available")
+	}
+}
+
+func TestHandlePCSCError_NeverEstablished(t *testing.T) {
+	resetPCSCState()
+	defer resetPCSCState()
+
+	err := handlePCSCError(scard.ErrNoService)
+	if !errors.Is(err, ErrPCSCUnavailable) {
+		t.Fatalf("expected ErrPCSCUnavailable, got %v", err)
+	}
+	if !GetPCSCStatus().Available {
+		t.Error("a service that was never reached should not be reported as down")
+	}
+}
+
+func TestHandlePCSCError_ReconnectsWithBackoff(t *testing.T) {
+	origProbe, origDelay := pcscProbe, ReconnectInitialDelay
+	defer func() {
+		pcscProbe, ReconnectInitialDelay = origProbe, origDelay
+		resetPCSCState()
+	}()
+	resetPCSCState()
+	pcscEstablished.Store(true)
+
+	probes := make(chan struct{}, 10)
+	failures := 2
+	pcscProbe = func() error {
+		probes <- struct{}{}
+		if failures > 0 {
+			failures--
+			return scard.ErrNoService
+		}
+		return nil
+	}
+	ReconnectInitialDelay = time.Millisecond
+
+	err := handlePCSCError(scard.ErrServiceStopped)
+	if !errors.Is(err, ErrPCSCUnavailable) {
+		t.Fatalf("expected ErrPCSCUnavailable, got %v", err)
+	}
+
+	status := GetPCSCStatus()
+	if status.Available || status.Since == nil {
+		t.Errorf("expected degraded status with a start time, got %+v", status)
+	}
+
+	// Operations fail fast while the service is down
+	if _, err := establishContext(); !errors.Is(err, ErrPCSCUnavailable) {
+		t.Errorf("expected ErrPCSCUnavailable while degraded, got %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for !GetPCSCStatus().Available {
+		select {
+		case <-deadline:
+			t.Fatal("timeout waiting for PC/SC to recover")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	if len(probes) != 3 {
+		t.Errorf("expected 3 reconnect attempts, got %d", len(probes))
+	}
+}
+
+func TestResetPCSCState_StopsReconnect(t *testing.T) {
+	origProbe, origDelay := pcscProbe, ReconnectInitialDelay
+	defer func() {
+		pcscProbe, ReconnectInitialDelay = origProbe, origDelay
+		resetPCSCState()
+	}()
+	resetPCSCState()
+	pcscEstablished.Store(true)
+
+	probes := make(chan struct{}, 100)
+	pcscProbe = func() error {
+		probes <- struct{}{}
+		return scard.ErrNoService
+	}
+	ReconnectInitialDelay = time.Millisecond
+
+	handlePCSCError(scard.ErrServiceStopped)
+	<-probes
+	resetPCSCState()
+	if !GetPCSCStatus().Available {
+		t.Fatal("reset should mark PC/SC available")
+	}
+
+	// The loop stops at its next attempt and sends no more probes after it
+	time.Sleep(20 * time.Millisecond)
+	for len(probes) > 0 {
+		<-probes
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(probes) != 0 {
+		t.Errorf("reconnect loop kept probing after reset (%d probes)", len(probes))
+	}
+}
+
+func TestPCSCReady(t *testing.T) {
+	origProbe := pcscProbe
+	defer func() {
+		pcscProbe = origProbe
+		resetPCSCState()
+	}()
+	resetPCSCState()
+
+	probes := 0
+	var probeErr error = scard.ErrNoService
+	pcscProbe = func() error {
+		probes++
+		return probeErr
+	}
+
+	if PCSCReady() {
+		t.Fatal("expected not ready while no context can be established")
+	}
+	probeErr = nil
+	if !PCSCReady() {
+		t.Fatal("expected ready once a context was established")
+	}
+
+	// Stays ready without probing again, even if PC/SC goes away later
+	probeErr = scard.ErrNoService
+	if !PCSCReady() {
+		t.Error("expected to stay ready")
+	}
+	if probes != 2 {
+		t.Errorf("expected 2 probes, got %d", probes)
+	}
+}
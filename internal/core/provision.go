@@ -0,0 +1,200 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+// Provisioning steps, in the order they run. ProvisionError.Step names the
+// one that failed.
+const (
+	ProvisionStepEncode   = "encode"
+	ProvisionStepConnect  = "connect"
+	ProvisionStepWrite    = "write"
+	ProvisionStepProtect  = "protect"
+	ProvisionStepVerify   = "verify"
+	ProvisionStepFeedback = "feedback"
+)
+
+// ErrVerifyMismatch is returned when the data read back after provisioning,
+// or after a write with WriteOptions.Verify, differs from what was written.
+var ErrVerifyMismatch = errors.New("read-back data does not match what was written")
+
+// ProvisionOptions controls the optional steps of ProvisionSpool.
+type ProvisionOptions struct {
+	Protect  bool   // Password-protect writes from the first user page
+	Password []byte // 4-byte password, required with Protect
+	Feedback bool   // Beep/flash the reader once the spool is provisioned
+	Force    bool   // Overwrite a write-protected OpenPrintTag
+}
+
+// ProvisionResult reports what ProvisionSpool did. On failure it holds the
+// state reached before the failing step.
+type ProvisionResult struct {
+	UID  string `json:"uid,omitempty"`
+	Type string `json:"type,omitempty"`
+
+	InstanceUUID string `json:"instanceUuid,omitempty"`
+	PackageUUID  string `json:"packageUuid,omitempty"`
+	MaterialUUID string `json:"materialUuid,omitempty"`
+	BrandUUID    string `json:"brandUuid,omitempty"`
+
+	Written   bool `json:"written"`   // OpenPrintTag data written
+	Protected bool `json:"protected"` // Password protection set
+	Verified  bool `json:"verified"`  // Read-back matched the written data
+	Feedback  bool `json:"feedback"`  // Reader feedback given
+}
+
+// ProvisionError wraps the error of the step that failed.
+type ProvisionError struct {
+	Step string
+	Err  error
+}
+
+func (e *ProvisionError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Step, e.Err)
+}
+
+func (e *ProvisionError) Unwrap() error {
+	return e.Err
+}
+
+// ProvisionSpool writes an OpenPrintTag to the card, optionally sets password
+// protection and gives reader feedback, and reads the data back to verify it,
+// all within one card connection so the card can't change between steps.
+// The result is returned even on error, holding the partial state.
+func ProvisionSpool(readerName string, input openprinttag.Input, opts ProvisionOptions) (_ *ProvisionResult, err error) {
+	defer trackOperation("provision_spool", readerName, &err)()
+
+	result := &ProvisionResult{}
+	fail := func(step string, err error) (*ProvisionResult, error) {
+		logging.Warn(logging.CatCard, "Spool provisioning failed", map[string]any{
+			"reader": readerName,
+			"step":   step,
+			"error":  err.Error(),
+		})
+		return result, &ProvisionError{Step: step, Err: err}
+	}
+
+	if opts.Protect && len(opts.Password) != 4 {
+		return fail(ProvisionStepEncode, fmt.Errorf("password must be exactly 4 bytes"))
+	}
+
+	// Resolve the UUIDs once so what is written is what gets reported
+	opt, err := input.ToOpenPrintTag()
+	if err != nil {
+		return fail(ProvisionStepEncode, err)
+	}
+	payload, err := opt.Encode()
+	if err != nil {
+		return fail(ProvisionStepEncode, fmt.Errorf("failed to encode openprinttag: %w", err))
+	}
+	resolved := opt.ToResponse()
+	result.InstanceUUID = resolved.InstanceUUID
+	result.PackageUUID = resolved.PackageUUID
+	result.MaterialUUID = resolved.MaterialUUID
+	result.BrandUUID = resolved.BrandUUID
+
+	ctx, err := establishContext()
+	if err != nil {
+		return fail(ProvisionStepConnect, fmt.Errorf("failed to establish context: %w", err))
+	}
+	defer ctx.Release()
+
+	card, err := connectCard(ctx, readerName)
+	if err != nil {
+		return fail(ProvisionStepConnect, err)
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	status, err := card.Status()
+	if err != nil {
+		return fail(ProvisionStepConnect, fmt.Errorf("failed to get card status: %w", err))
+	}
+	cardInfo := &Card{
+		ATR: hex.EncodeToString(status.Atr),
+	}
+	detectCardType(card, cardInfo)
+	rememberCardType(readerName, cardInfo.Type)
+	if rsp, err := transmit(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}); err == nil && len(rsp) > 2 && rsp[len(rsp)-2] == 0x90 {
+		result.UID = hex.EncodeToString(rsp[:len(rsp)-2])
+	}
+	result.Type = cardInfo.Type
+
+	// Check protection support before touching the card
+	if _, ok := ntagLayouts[cardInfo.Type]; opts.Protect && !ok {
+		return fail(ProvisionStepProtect, fmt.Errorf("password protection not supported for card type: %s", cardInfo.Type))
+	}
+
+	if err := checkWriteProtection(card, cardInfo, opts.Force); err != nil {
+		return fail(ProvisionStepWrite, err)
+	}
+	if err := writeProvisionedTag(card, cardInfo, payload); err != nil {
+		return fail(ProvisionStepWrite, err)
+	}
+	result.Written = true
+
+	if opts.Protect {
+		if err := setNTAGPassword(card, cardInfo, opts.Password, []byte{0x00, 0x00}, 4); err != nil {
+			return fail(ProvisionStepProtect, err)
+		}
+		result.Protected = true
+	}
+
+	if err := verifyProvisionedTag(card, cardInfo, payload); err != nil {
+		return fail(ProvisionStepVerify, err)
+	}
+	result.Verified = true
+
+	if opts.Feedback {
+		if err := signalSuccess(card, readerName); err != nil {
+			return fail(ProvisionStepFeedback, err)
+		}
+		result.Feedback = true
+	}
+
+	logging.Info(logging.CatCard, "Spool provisioned", map[string]any{
+		"reader":       readerName,
+		"cardType":     cardInfo.Type,
+		"instanceUuid": result.InstanceUUID,
+		"protected":    result.Protected,
+	})
+	return result, nil
+}
+
+// writeProvisionedTag writes payload as a single OpenPrintTag NDEF record.
+func writeProvisionedTag(card *scard.Card, cardInfo *Card, payload []byte) error {
+	tlv := createNDEFMimeRecord(openprinttag.MIMEType, payload)
+	if cardInfo.Type == "MIFARE Classic" {
+		if err := writeMifareClassic(card, tlv); err != nil {
+			return fmt.Errorf("failed to write NDEF message: %w", err)
+		}
+		return nil
+	}
+	return writeNDEFTLV(card, cardInfo, tlv)
+}
+
+// verifyProvisionedTag reads the NDEF data back and checks that it holds
+// exactly the OpenPrintTag payload that was written.
+func verifyProvisionedTag(card *scard.Card, cardInfo *Card, payload []byte) error {
+	readBack := &Card{ATR: cardInfo.ATR, Type: cardInfo.Type, Size: cardInfo.Size}
+	readNDEFData(card, readBack, ReadOptions{})
+
+	for _, rec := range readBack.Records {
+		if rec.DataType != "openprinttag" {
+			continue
+		}
+		if !bytes.Equal(rec.Payload, payload) {
+			return ErrVerifyMismatch
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no OpenPrintTag record found", ErrVerifyMismatch)
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SimplyPrint/nfc-agent/internal/openprinttag"
+)
+
+func TestProvisionSpool_RejectsBadInputBeforeConnecting(t *testing.T) {
+	tests := []struct {
+		name  string
+		input openprinttag.Input
+		opts  ProvisionOptions
+	}{
+		{"protect with short password", openprinttag.Input{MaterialName: "PLA"}, ProvisionOptions{Protect: true, Password: []byte{1, 2}}},
+		{"invalid instance UUID", openprinttag.Input{MaterialName: "PLA", InstanceUUID: "nope"}, ProvisionOptions{}},
+		{"invalid color", openprinttag.Input{MaterialName: "PLA", PrimaryColor: "#12"}, ProvisionOptions{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProvisionSpool("Test Reader", tt.input, tt.opts)
+			var provErr *ProvisionError
+			if !errors.As(err, &provErr) {
+				t.Fatalf("expected ProvisionError, got %v", err)
+			}
+			if provErr.Step != ProvisionStepEncode {
+				t.Errorf("Step = %q, want %q", provErr.Step, ProvisionStepEncode)
+			}
+			if result == nil || result.Written {
+				t.Errorf("expected an unwritten result, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestProvisionError_Unwrap(t *testing.T) {
+	err := &ProvisionError{Step: ProvisionStepVerify, Err: ErrVerifyMismatch}
+	if !errors.Is(err, ErrVerifyMismatch) {
+		t.Error("ProvisionError should unwrap to the step error")
+	}
+	if got := err.Error(); got != "verify failed: read-back data does not match what was written" {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// ReaderError is the most recent failed operation on a reader.
+type ReaderError struct {
+	Operation string    `json:"operation"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// lastReaderErrors holds the last operation error per reader. A successful
+// operation on the reader clears it.
+var lastReaderErrors = struct {
+	mu   sync.Mutex
+	errs map[string]ReaderError
+}{errs: make(map[string]ReaderError)}
+
+// recordOperationResult updates a reader's last error after an operation.
+// An empty field isn't a reader fault, so "no card" results leave it as is.
+func recordOperationResult(readerName, operation string, err error) {
+	if err != nil && (errors.Is(err, scard.ErrNoSmartcard) || errors.Is(err, scard.ErrRemovedCard)) {
+		return
+	}
+
+	lastReaderErrors.mu.Lock()
+	defer lastReaderErrors.mu.Unlock()
+
+	if err == nil {
+		delete(lastReaderErrors.errs, readerName)
+		return
+	}
+	lastReaderErrors.errs[readerName] = ReaderError{
+		Operation: operation,
+		Message:   err.Error(),
+		Time:      time.Now(),
+	}
+}
+
+// LastReaderError returns the most recent error on a reader, or nil if its
+// last operation succeeded or none has failed yet.
+func LastReaderError(readerName string) *ReaderError {
+	lastReaderErrors.mu.Lock()
+	defer lastReaderErrors.mu.Unlock()
+
+	e, ok := lastReaderErrors.errs[readerName]
+	if !ok {
+		return nil
+	}
+	return &e
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestRecordOperationResult(t *testing.T) {
+	const reader = "Test Reader (last error)"
+	defer recordOperationResult(reader, "cleanup", nil)
+
+	if got := LastReaderError(reader); got != nil {
+		t.Fatalf("expected no error initially, got %+v", got)
+	}
+
+	recordOperationResult(reader, "read_card", errors.New("transmit failed"))
+	got := LastReaderError(reader)
+	if got == nil || got.Operation != "read_card" || got.Message != "transmit failed" || got.Time.IsZero() {
+		t.Fatalf("unexpected last error %+v", got)
+	}
+
+	// No card in the field is not a reader fault
+	recordOperationResult(reader, "read_card", fmt.Errorf("failed to connect to reader: %w", scard.ErrNoSmartcard))
+	if got := LastReaderError(reader); got == nil || got.Message != "transmit failed" {
+		t.Errorf("no-card result should keep the previous error, got %+v", got)
+	}
+
+	recordOperationResult(reader, "write_card", nil)
+	if got := LastReaderError(reader); got != nil {
+		t.Errorf("success should clear the error, got %+v", got)
+	}
+}
+
+func TestTrackOperation_RecordsError(t *testing.T) {
+	const reader = "Test Reader (tracked)"
+	defer recordOperationResult(reader, "cleanup", nil)
+
+	op := func() (err error) {
+		defer trackOperation("erase_card", reader, &err)()
+		return errors.New("write failed at page 4")
+	}
+	_ = op()
+
+	if got := LastReaderError(reader); got == nil || got.Operation != "erase_card" {
+		t.Errorf("expected erase_card error to be recorded, got %+v", got)
+	}
+}
+
+func TestRecordOperationResult_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reader := fmt.Sprintf("Concurrent Reader %d", i%5)
+			recordOperationResult(reader, "read_card", errors.New("boom"))
+			_ = LastReaderError(reader)
+			recordOperationResult(reader, "read_card", nil)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -13,12 +15,118 @@ type Reader struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	Type string `json:"type"` // "picc" for contactless readers, "sam" for SAM slots
+
+	LastError *ReaderError `json:"lastError,omitempty"` // Most recent failed operation, cleared by a successful one
+}
+
+// ErrReaderBusy is returned when another application holds the reader exclusively.
+var ErrReaderBusy = errors.New("reader is in use by another application")
+
+// ExclusiveReader connects to cards with ShareExclusive instead of ShareShared,
+// so no other application can talk to the reader during an operation.
+var ExclusiveReader bool
+
+// cardShareMode returns the share mode card connections use.
+func cardShareMode() scard.ShareMode {
+	if ExclusiveReader {
+		return scard.ShareExclusive
+	}
+	return scard.ShareShared
+}
+
+// connectCard connects to the card on the given reader, in shared mode unless
+// ExclusiveReader is set. A PC/SC sharing violation is reported as
+// ErrReaderBusy and a lost PC/SC service as ErrPCSCUnavailable.
+func connectCard(ctx *pcscContext, readerName string) (*scard.Card, error) {
+	card, err := ctx.Connect(readerName, cardShareMode(), scard.ProtocolAny)
+	if err != nil {
+		if errors.Is(err, scard.ErrSharingViolation) {
+			logging.Warn(logging.CatReader, "Reader is held exclusively by another application", map[string]any{
+				"reader": readerName,
+			})
+			return nil, fmt.Errorf("%w (%s): %v", ErrReaderBusy, readerName, err)
+		}
+		return nil, fmt.Errorf("failed to connect to reader: %w", handlePCSCError(err))
+	}
+	return card, nil
+}
+
+// WaitCardPresenceChange blocks in PC/SC GetStatusChange until a card is
+// placed on the reader (present false) or removed from it (present true), and
+// returns whether a card is now present. Waiting causes no reader traffic. It
+// returns ctx.Err() once ctx is cancelled, and an error if the reader's state
+// can't be tracked.
+func WaitCardPresenceChange(ctx context.Context, readerName string, present bool) (bool, error) {
+	pcscCtx, err := establishUnlimitedContext()
+	if err != nil {
+		return present, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer pcscCtx.Release()
+
+	// Cancel unblocks GetStatusChange with ErrCancelled
+	stop := context.AfterFunc(ctx, func() { pcscCtx.Cancel() })
+	defer stop()
+
+	rs := []scard.ReaderState{{Reader: readerName, CurrentState: scard.StateUnaware}}
+	for {
+		if err := pcscCtx.GetStatusChange(rs, -1); err != nil {
+			if ctx.Err() != nil {
+				return present, ctx.Err()
+			}
+			return present, fmt.Errorf("failed to get status change: %w", handlePCSCError(err))
+		}
+		state := rs[0].EventState
+		if state&(scard.StateUnknown|scard.StateUnavailable) != 0 {
+			return present, fmt.Errorf("reader %s state unavailable", readerName)
+		}
+		if now := state&scard.StatePresent != 0; now != present {
+			return now, nil
+		}
+		rs[0].CurrentState = state
+	}
+}
+
+// CheckExclusiveAccess tries an exclusive connection to each reader and
+// returns the names of those another application already holds, logging a
+// warning for each. Readers without a card are not reported. Called at
+// startup in exclusive mode; busy readers stay usable and are retried on each
+// operation.
+func CheckExclusiveAccess() []string {
+	ctx, err := establishUnlimitedContext()
+	if err != nil {
+		logging.Warn(logging.CatReader, "Exclusive reader check skipped", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	defer ctx.Release()
+
+	var busy []string
+	for _, r := range ListReaders() {
+		card, err := ctx.Connect(r.Name, scard.ShareExclusive, scard.ProtocolAny)
+		if err == nil {
+			card.Disconnect(scard.LeaveCard)
+			continue
+		}
+		if errors.Is(err, scard.ErrSharingViolation) {
+			logging.Warn(logging.CatReader, "Reader is held by another application, exclusive mode will retry per operation", map[string]any{
+				"reader": r.Name,
+			})
+			busy = append(busy, r.Name)
+		}
+	}
+	return busy
 }
 
 // ListReaders returns a list of available NFC readers using PC/SC.
 // Only returns PICC (contactless) readers, filtering out SAM slots.
 func ListReaders() []Reader {
-	ctx, err := scard.EstablishContext()
+	ctx, err := establishUnlimitedContext()
+	if errors.Is(err, ErrPCSCUnavailable) {
+		// Already logged when the service went away; a reconnect is in progress
+		logging.Debug(logging.CatReader, "PC/SC unavailable, no readers listed", nil)
+		return []Reader{}
+	}
 	if err != nil {
 		// Log the error for diagnostics - this usually means pcscd is not running
 		logging.Error(logging.CatReader, "Failed to establish PC/SC context - is pcscd running?", map[string]any{
@@ -52,6 +160,8 @@ func ListReaders() []Reader {
 			ID:   fmt.Sprintf("reader-%d", readerIndex),
 			Name: name,
 			Type: readerType,
+
+			LastError: LastReaderError(name),
 		})
 		readerIndex++
 	}
@@ -1,6 +1,10 @@
 package core
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/ebfe/scard"
+)
 
 // Mock reader names from real hardware
 var mockReaderNames = []string{
@@ -96,6 +100,18 @@ func TestDetectReaderType_RealHardware(t *testing.T) {
 	}
 }
 
+func TestCardShareMode(t *testing.T) {
+	if cardShareMode() != scard.ShareShared {
+		t.Error("expected shared access by default")
+	}
+
+	ExclusiveReader = true
+	t.Cleanup(func() { ExclusiveReader = false })
+	if cardShareMode() != scard.ShareExclusive {
+		t.Error("expected exclusive access with ExclusiveReader set")
+	}
+}
+
 func TestReaderStruct(t *testing.T) {
 	tests := []struct {
 		id       string
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// SelfCheckInterval is how often the startup self-check re-checks readers.
+var SelfCheckInterval = 30 * time.Second
+
+// SelfCheckStatus is the latest result of the reader self-check.
+type SelfCheckStatus struct {
+	Enabled     bool       `json:"enabled"`
+	OK          bool       `json:"ok"`                  // PC/SC works and at least one reader is connected
+	ReaderCount int        `json:"readerCount"`         // Readers found by the last check
+	Error       string     `json:"error,omitempty"`     // Why the last check failed
+	CheckedAt   *time.Time `json:"checkedAt,omitempty"` // Unset until the first check has run
+}
+
+var selfCheckState = struct {
+	mu     sync.Mutex
+	status SelfCheckStatus
+	seen   map[string]bool // Readers already reported as available
+}{}
+
+// selfCheckListReaders establishes a PC/SC context, so a failure is reported
+// rather than hidden as an empty list, and lists the readers.
+// Overridden in tests.
+var selfCheckListReaders = func() ([]Reader, error) {
+	ctx, err := establishUnlimitedContext()
+	if err != nil {
+		return nil, err
+	}
+	ctx.Release()
+	return ListReaders(), nil
+}
+
+// StartSelfCheck checks PC/SC and the connected readers now and then every
+// SelfCheckInterval, so a unit without a working reader is reported at boot
+// rather than on the first failed request. Problems are logged as warnings
+// when they start, and each reader is logged when it first becomes available.
+func StartSelfCheck() {
+	selfCheckState.mu.Lock()
+	selfCheckState.status.Enabled = true
+	selfCheckState.mu.Unlock()
+
+	go func() {
+		defer logging.RecoverAndLog("Reader self-check", false)
+
+		for {
+			runSelfCheck()
+			time.Sleep(SelfCheckInterval)
+		}
+	}()
+}
+
+// GetSelfCheckStatus returns the latest self-check result.
+func GetSelfCheckStatus() SelfCheckStatus {
+	selfCheckState.mu.Lock()
+	defer selfCheckState.mu.Unlock()
+	return selfCheckState.status
+}
+
+// runSelfCheck runs one check and records the result.
+func runSelfCheck() {
+	readers, err := selfCheckListReaders()
+	now := time.Now()
+
+	selfCheckState.mu.Lock()
+	defer selfCheckState.mu.Unlock()
+
+	prev := selfCheckState.status
+	st := &selfCheckState.status
+	st.CheckedAt = &now
+	st.ReaderCount = len(readers)
+	st.Error = ""
+	switch {
+	case err != nil:
+		st.Error = "PC/SC context failed: " + err.Error()
+	case len(readers) == 0:
+		st.Error = "no readers found"
+	}
+	st.OK = st.Error == ""
+
+	// Warn once per distinct problem rather than on every retry
+	if !st.OK && (prev.CheckedAt == nil || prev.Error != st.Error) {
+		logging.Warn(logging.CatReader, "Self-check failed, retrying", map[string]any{
+			"error":   st.Error,
+			"retryMs": SelfCheckInterval.Milliseconds(),
+		})
+	}
+
+	if selfCheckState.seen == nil {
+		selfCheckState.seen = make(map[string]bool)
+	}
+	for _, r := range readers {
+		if selfCheckState.seen[r.Name] {
+			continue
+		}
+		selfCheckState.seen[r.Name] = true
+		logging.Info(logging.CatReader, "Reader available", map[string]any{
+			"reader": r.Name,
+			"type":   r.Type,
+		})
+	}
+}
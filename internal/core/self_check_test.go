@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunSelfCheck(t *testing.T) {
+	origList := selfCheckListReaders
+	defer func() {
+		selfCheckListReaders = origList
+		selfCheckState.status, selfCheckState.seen = SelfCheckStatus{}, nil
+	}()
+
+	var readers []Reader
+	var listErr error
+	selfCheckListReaders = func() ([]Reader, error) { return readers, listErr }
+
+	if GetSelfCheckStatus().CheckedAt != nil {
+		t.Fatal("no check should have run yet")
+	}
+
+	listErr = errors.New("no service")
+	runSelfCheck()
+	st := GetSelfCheckStatus()
+	if st.OK || st.CheckedAt == nil || st.Error != "PC/SC context failed: no service" {
+		t.Errorf("after a context failure got %+v", st)
+	}
+
+	listErr = nil
+	runSelfCheck()
+	if st := GetSelfCheckStatus(); st.OK || st.Error != "no readers found" {
+		t.Errorf("with no readers got %+v", st)
+	}
+
+	readers = []Reader{{Name: "ACS ACR1252 PICC"}}
+	runSelfCheck()
+	if st := GetSelfCheckStatus(); !st.OK || st.ReaderCount != 1 || st.Error != "" {
+		t.Errorf("with a reader got %+v", st)
+	}
+	if !selfCheckState.seen["ACS ACR1252 PICC"] {
+		t.Error("reader should be recorded as seen")
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// SystemInfo describes the PC/SC stack and readers, for bug reports.
+type SystemInfo struct {
+	OS      string       `json:"os"` // GOOS/GOARCH
+	PCSC    PCSCInfo     `json:"pcsc"`
+	Readers []ReaderInfo `json:"readers"`
+}
+
+// PCSCInfo identifies the PC/SC implementation in use.
+type PCSCInfo struct {
+	Implementation string `json:"implementation"`    // "pcsc-lite", "WinSCard" or "PCSC.framework"
+	Version        string `json:"version,omitempty"` // pcsc-lite version, or the OS version the others ship with
+	Available      bool   `json:"available"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ReaderInfo holds what a reader reports about itself. Fields the reader or
+// driver doesn't provide are left empty.
+type ReaderInfo struct {
+	Name          string `json:"name"`
+	Vendor        string `json:"vendor,omitempty"`        // SCARD_ATTR_VENDOR_NAME
+	DriverVersion string `json:"driverVersion,omitempty"` // SCARD_ATTR_VENDOR_IFD_VERSION
+	Firmware      string `json:"firmware,omitempty"`      // ACS get-firmware response, e.g. "ACR122U215"
+	Error         string `json:"error,omitempty"`
+
+	LastError *ReaderError `json:"lastError,omitempty"` // Most recent failed card operation
+}
+
+// acsGetFirmwareAPDU asks ACS readers for their firmware version string.
+var acsGetFirmwareAPDU = []byte{0xFF, 0x00, 0x48, 0x00, 0x00}
+
+// versionCommandTimeout bounds the external commands run to find versions.
+const versionCommandTimeout = 2 * time.Second
+
+// runVersionCommand runs a command and returns its combined output.
+// Overridden in tests.
+var runVersionCommand = func(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	hideConsoleWindow(cmd)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// GetSystemInfo returns the PC/SC implementation and version and what each
+// reader reports about itself. Missing details are left empty; if the PC/SC
+// service is unreachable the error is returned along with what is known.
+func GetSystemInfo() (*SystemInfo, error) {
+	info := &SystemInfo{
+		OS:      runtime.GOOS + "/" + runtime.GOARCH,
+		PCSC:    pcscImplementation(runtime.GOOS),
+		Readers: []ReaderInfo{},
+	}
+
+	ctx, err := establishContext()
+	if err != nil {
+		info.PCSC.Error = err.Error()
+		return info, fmt.Errorf("failed to establish context: %w", err)
+	}
+	defer ctx.Release()
+	info.PCSC.Available = true
+
+	names, err := ctx.ListReaders()
+	if err != nil {
+		// No readers connected
+		return info, nil
+	}
+	for _, name := range names {
+		info.Readers = append(info.Readers, readerInfo(ctx, name))
+	}
+	return info, nil
+}
+
+// pcscImplementation names the platform's PC/SC stack and finds its version.
+func pcscImplementation(goos string) PCSCInfo {
+	switch goos {
+	case "windows":
+		out, _ := runVersionCommand("cmd", "/c", "ver")
+		return PCSCInfo{Implementation: "WinSCard", Version: parseWindowsVersion(out)}
+	case "darwin":
+		out, _ := runVersionCommand("sw_vers", "-productVersion")
+		return PCSCInfo{Implementation: "PCSC.framework", Version: strings.TrimSpace(out)}
+	default:
+		out, _ := runVersionCommand("pcscd", "--version")
+		return PCSCInfo{Implementation: "pcsc-lite", Version: parsePCSCDVersion(out)}
+	}
+}
+
+var (
+	pcscdVersionPattern   = regexp.MustCompile(`pcsc-lite version ([0-9][0-9.]*[0-9])`)
+	windowsVersionPattern = regexp.MustCompile(`Version ([0-9][0-9.]*[0-9])`)
+)
+
+// parsePCSCDVersion extracts the version from `pcscd --version` output.
+func parsePCSCDVersion(out string) string {
+	if m := pcscdVersionPattern.FindStringSubmatch(out); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// parseWindowsVersion extracts the build number from `ver` output.
+func parseWindowsVersion(out string) string {
+	if m := windowsVersionPattern.FindStringSubmatch(out); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// readerInfo queries one reader. A direct connection works without a card
+// in the field; if the driver refuses it, a card connection is tried instead.
+func readerInfo(ctx *pcscContext, name string) ReaderInfo {
+	info := ReaderInfo{Name: name, LastError: LastReaderError(name)}
+
+	card, err := ctx.Connect(name, scard.ShareDirect, scard.ProtocolUndefined)
+	direct := err == nil
+	if !direct {
+		card, err = connectCard(ctx, name)
+	}
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	if vendor, err := card.GetAttrib(scard.AttrVendorName); err == nil {
+		info.Vendor = cString(vendor)
+	}
+	if version, err := card.GetAttrib(scard.AttrVendorIfdVersion); err == nil {
+		info.DriverVersion = formatIFDVersion(version)
+	}
+
+	if strings.Contains(strings.ToUpper(name), "ACS") || strings.Contains(strings.ToUpper(name), "ACR") {
+		var rsp []byte
+		if direct {
+			rsp, err = card.Control(acsEscapeIoctl, acsGetFirmwareAPDU)
+		} else {
+			rsp, err = transmit(card, acsGetFirmwareAPDU)
+		}
+		if err == nil {
+			info.Firmware = parseFirmwareResponse(rsp)
+		} else {
+			info.Error = fmt.Sprintf("failed to read firmware: %v", err)
+		}
+	}
+	return info
+}
+
+// parseFirmwareResponse returns the ASCII firmware string of a get-firmware
+// response, dropping a trailing 90 00 status word if the reader sends one.
+func parseFirmwareResponse(rsp []byte) string {
+	if len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
+		rsp = rsp[:len(rsp)-2]
+	}
+	var sb strings.Builder
+	for _, b := range rsp {
+		if b >= 0x20 && b < 0x7F {
+			sb.WriteByte(b)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// formatIFDVersion formats SCARD_ATTR_VENDOR_IFD_VERSION, a DWORD laid out
+// as 0xMMmmbbbb (major, minor, build).
+func formatIFDVersion(v []byte) string {
+	if len(v) < 4 {
+		return ""
+	}
+	n := binary.LittleEndian.Uint32(v)
+	return fmt.Sprintf("%d.%d.%d", n>>24, (n>>16)&0xFF, n&0xFFFF)
+}
+
+// cString converts a NUL-terminated attribute value to a string.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package core
+
+import "os/exec"
+
+// hideConsoleWindow is a no-op: only Windows opens a window for a console
+// command.
+func hideConsoleWindow(cmd *exec.Cmd) {}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePCSCDVersion(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"pcsc-lite version 1.9.9.\nCopyright (C) 1999-2002 by David Corcoran", "1.9.9"},
+		{"pcsc-lite version 2.0.3.\n", "2.0.3"},
+		{"bash: pcscd: command not found", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parsePCSCDVersion(tt.out); got != tt.want {
+			t.Errorf("parsePCSCDVersion(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestParseWindowsVersion(t *testing.T) {
+	if got := parseWindowsVersion("\r\nMicrosoft Windows [Version 10.0.19045.3803]\r\n"); got != "10.0.19045.3803" {
+		t.Errorf("parseWindowsVersion() = %q", got)
+	}
+}
+
+func TestPCSCImplementation(t *testing.T) {
+	orig := runVersionCommand
+	defer func() { runVersionCommand = orig }()
+
+	runVersionCommand = func(name string, args ...string) (string, error) {
+		if name == "pcscd" {
+			return "pcsc-lite version 1.9.9.\n", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		goos     string
+		wantImpl string
+		wantVer  string
+	}{
+		{"linux", "pcsc-lite", "1.9.9"},
+		{"windows", "WinSCard", ""},
+		{"darwin", "PCSC.framework", ""},
+	}
+	for _, tt := range tests {
+		got := pcscImplementation(tt.goos)
+		if got.Implementation != tt.wantImpl || got.Version != tt.wantVer {
+			t.Errorf("pcscImplementation(%s) = %+v, want %s %q", tt.goos, got, tt.wantImpl, tt.wantVer)
+		}
+	}
+}
+
+func TestParseFirmwareResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		rsp  []byte
+		want string
+	}{
+		{"ACR122U plain", []byte("ACR122U215"), "ACR122U215"},
+		{"with status word", append([]byte("ACR1252U_V1.11"), 0x90, 0x00), "ACR1252U_V1.11"},
+		{"non-printable", []byte{0x00, 'A', 'C', 'R', 0x01}, "ACR"},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseFirmwareResponse(tt.rsp); got != tt.want {
+				t.Errorf("parseFirmwareResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatIFDVersion(t *testing.T) {
+	// 0x01040000 little-endian: version 1.4.0
+	if got := formatIFDVersion([]byte{0x00, 0x00, 0x04, 0x01}); got != "1.4.0" {
+		t.Errorf("formatIFDVersion() = %q, want 1.4.0", got)
+	}
+	if got := formatIFDVersion([]byte{0x01}); got != "" {
+		t.Errorf("formatIFDVersion(short) = %q, want empty", got)
+	}
+}
+
+func TestCString(t *testing.T) {
+	if got := cString([]byte("ACS\x00junk")); got != "ACS" {
+		t.Errorf("cString() = %q, want ACS", got)
+	}
+}
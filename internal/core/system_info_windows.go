@@ -0,0 +1,16 @@
+//go:build windows
+
+package core
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// hideConsoleWindow keeps a console command from flashing a window when the
+// agent runs without a console, as it does from the tray.
+func hideConsoleWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: windows.CREATE_NO_WINDOW}
+}
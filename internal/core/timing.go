@@ -0,0 +1,74 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// SlowOperationThreshold is the duration above which a core card operation
+// logs a warning. Slow operations usually point to a degrading reader or a
+// card sitting at the edge of the field.
+var SlowOperationThreshold = 2 * time.Second
+
+// lastCardTypes holds the most recently detected card type per reader, used to
+// attribute operation latency to a card type.
+var lastCardTypes = struct {
+	mu    sync.Mutex
+	types map[string]string
+}{types: make(map[string]string)}
+
+// lastOperationAt is when the last card operation started or finished, in
+// unix nanoseconds (0 before the first).
+var lastOperationAt atomic.Int64
+
+// LastOperationTime returns when the last card operation started or finished,
+// or the zero time if none has run.
+func LastOperationTime() time.Time {
+	if ns := lastOperationAt.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// rememberCardType records the card type detected on a reader.
+func rememberCardType(readerName, cardType string) {
+	lastCardTypes.mu.Lock()
+	defer lastCardTypes.mu.Unlock()
+	lastCardTypes.types[readerName] = cardType
+}
+
+// lastCardType returns the card type last detected on a reader, if any.
+func lastCardType(readerName string) string {
+	lastCardTypes.mu.Lock()
+	defer lastCardTypes.mu.Unlock()
+	return lastCardTypes.types[readerName]
+}
+
+// trackOperation starts timing a card operation and returns a function that
+// records its latency (see logging.LatencyStats) and logs a warning if it ran
+// longer than SlowOperationThreshold. If errp is non-nil, the operation's
+// result also updates the reader's last error (see LastReaderError).
+// Use as: defer trackOperation("read_card", readerName, &err)()
+func trackOperation(operation, readerName string, errp *error) func() {
+	start := time.Now()
+	lastOperationAt.Store(start.UnixNano())
+	return func() {
+		lastOperationAt.Store(time.Now().UnixNano())
+		if errp != nil {
+			recordOperationResult(readerName, operation, *errp)
+		}
+		elapsed := time.Since(start)
+		logging.RecordLatency(operation, lastCardType(readerName), elapsed)
+		if SlowOperationThreshold > 0 && elapsed > SlowOperationThreshold {
+			logging.Warn(logging.CatReader, "Slow card operation", map[string]any{
+				"operation":   operation,
+				"reader":      readerName,
+				"durationMs":  elapsed.Milliseconds(),
+				"thresholdMs": SlowOperationThreshold.Milliseconds(),
+			})
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// Type 2 tag TLV block types (NFC Forum Type 2 Tag spec, section 2.3).
+const (
+	TLVNull          byte = 0x00
+	TLVLockControl   byte = 0x01
+	TLVMemoryControl byte = 0x02
+	TLVNDEF          byte = 0x03
+	TLVProprietary   byte = 0xFD
+	TLVTerminator    byte = 0xFE
+)
+
+// maxNullPadding is how many consecutive NULL TLVs are skipped before the
+// data area is taken to be unformatted rather than padded: a whole page of
+// zeros is treated as an empty tag.
+const maxNullPadding = 4
+
+// TLV is a control or proprietary TLV block stored before the NDEF TLV.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// ValidateControlTLVs checks that tlvs only holds lock-control, memory-control
+// or proprietary blocks of the right size.
+func ValidateControlTLVs(tlvs []TLV) error {
+	for i, t := range tlvs {
+		switch t.Type {
+		case TLVLockControl, TLVMemoryControl:
+			if len(t.Value) != 3 {
+				return fmt.Errorf("tlv %d: lock/memory control value must be 3 bytes, got %d", i, len(t.Value))
+			}
+		case TLVProprietary:
+			if len(t.Value) > 0xFFFE {
+				return fmt.Errorf("tlv %d: value too long (%d bytes)", i, len(t.Value))
+			}
+		default:
+			return fmt.Errorf("tlv %d: type 0x%02X not allowed (use 0x01, 0x02 or 0xFD)", i, t.Type)
+		}
+	}
+	return nil
+}
+
+// encodeTLVs encodes tlvs back to back, using the 3-byte length format for
+// values of 255 bytes or more.
+func encodeTLVs(tlvs []TLV) []byte {
+	var out []byte
+	for _, t := range tlvs {
+		out = append(out, t.Type)
+		if len(t.Value) < 0xFF {
+			out = append(out, byte(len(t.Value)))
+		} else {
+			out = append(out, 0xFF, byte(len(t.Value)>>8), byte(len(t.Value)))
+		}
+		out = append(out, t.Value...)
+	}
+	return out
+}
+
+// withControlTLVs prepends control TLVs to an NDEF TLV. Only Type 2 tags
+// (NTAG, Ultralight) define them.
+func withControlTLVs(cardInfo *Card, ndefTLV []byte, tlvs []TLV) ([]byte, error) {
+	if len(tlvs) == 0 {
+		return ndefTLV, nil
+	}
+	if err := ValidateControlTLVs(tlvs); err != nil {
+		return nil, err
+	}
+	if cardInfo.Type == "MIFARE Classic" || cardInfo.Type == cardTypeType4 || isISO15693ATR(cardInfo.ATR) {
+		return nil, fmt.Errorf("control TLVs are not supported for card type: %s", cardInfo.Type)
+	}
+	return append(encodeTLVs(tlvs), ndefTLV...), nil
+}
+
+// omitTerminatorIfFull drops the terminator TLV from the end of tlv when tlv
+// only fits the user memory from startPage without it. The Type 2 Tag spec
+// allows leaving out the terminator when the data area is exactly full;
+// readers find the end of the NDEF message from the TLV length.
+func omitTerminatorIfFull(cardInfo *Card, startPage int, tlv []byte) []byte {
+	n := len(tlv)
+	if n == 0 || tlv[n-1] != TLVTerminator || checkUserMemory(cardInfo, startPage, n) == nil {
+		return tlv
+	}
+	if checkUserMemory(cardInfo, startPage, n-1) != nil {
+		return tlv
+	}
+	logging.Debug(logging.CatCard, "Omitting terminator TLV on full tag", map[string]any{
+		"type":  cardInfo.Type,
+		"bytes": n - 1,
+	})
+	return tlv[:n-1]
+}
+
+// ndefTLVState is the outcome of scanning a data area for the NDEF TLV.
+type ndefTLVState int
+
+const (
+	ndefTLVIncomplete ndefTLVState = iota // More data is needed to find or finish it
+	ndefTLVFound                          // Complete NDEF TLV found
+	ndefTLVAbsent                         // Terminator or unknown block reached first
+)
+
+// locateNDEFTLV walks the TLV blocks at the start of a Type 2 tag's data area,
+// skipping NULL, lock-control, memory-control and proprietary blocks, and
+// returns the offset and length of the NDEF message value. Any control and
+// proprietary blocks passed on the way are returned too.
+func locateNDEFTLV(data []byte) (start, length int, skipped []TLV, state ndefTLVState) {
+	offset, nulls := 0, 0
+	for offset < len(data) {
+		tag := data[offset]
+		if tag == TLVNull {
+			offset++
+			if nulls++; nulls >= maxNullPadding {
+				return 0, 0, skipped, ndefTLVAbsent
+			}
+			continue
+		}
+		nulls = 0
+
+		switch tag {
+		case TLVNDEF, TLVLockControl, TLVMemoryControl, TLVProprietary:
+		default:
+			// Terminator, or a block whose length can't be trusted
+			return 0, 0, skipped, ndefTLVAbsent
+		}
+
+		if offset+1 >= len(data) {
+			return 0, 0, skipped, ndefTLVIncomplete
+		}
+		valueStart := offset + 2
+		valueLen := int(data[offset+1])
+		if data[offset+1] == 0xFF {
+			if offset+3 >= len(data) {
+				return 0, 0, skipped, ndefTLVIncomplete
+			}
+			valueLen = int(data[offset+2])<<8 | int(data[offset+3])
+			valueStart = offset + 4
+		}
+
+		if tag == TLVNDEF {
+			if valueStart+valueLen > len(data) {
+				return valueStart, valueLen, skipped, ndefTLVIncomplete
+			}
+			return valueStart, valueLen, skipped, ndefTLVFound
+		}
+
+		if valueStart+valueLen > len(data) {
+			return 0, 0, skipped, ndefTLVIncomplete
+		}
+		value := data[valueStart : valueStart+valueLen]
+		skipped = append(skipped, TLV{Type: tag, Value: value})
+		offset = valueStart + valueLen
+	}
+	return 0, 0, skipped, ndefTLVIncomplete
+}
+
+// ndefTLVHeaderOffset returns the offset of the NDEF TLV's tag byte, given the
+// value start and length returned by locateNDEFTLV.
+func ndefTLVHeaderOffset(data []byte, start, length int) int {
+	if start >= 4 && data[start-3] == 0xFF && int(data[start-2])<<8|int(data[start-1]) == length {
+		return start - 4 // Three-byte length format
+	}
+	return start - 2
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLocateNDEFTLV(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantStart   int
+		wantLength  int
+		wantSkipped int
+		wantState   ndefTLVState
+	}{
+		{"NDEF at offset 0", []byte{0x03, 0x02, 0xD0, 0x00, 0xFE}, 2, 2, 0, ndefTLVFound},
+		{"after lock control", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x02, 0xD0, 0x00, 0xFE}, 7, 2, 1, ndefTLVFound},
+		{"after lock and memory control", []byte{0x01, 0x03, 0xA0, 0x10, 0x44, 0x02, 0x03, 0x82, 0x04, 0x00, 0x03, 0x02, 0xD0, 0x00}, 12, 2, 2, ndefTLVFound},
+		{"after NULL padding", []byte{0x00, 0x00, 0x03, 0x02, 0xD0, 0x00}, 4, 2, 0, ndefTLVFound},
+		{"after proprietary", []byte{0xFD, 0x01, 0x42, 0x03, 0x02, 0xD0, 0x00}, 5, 2, 1, ndefTLVFound},
+		{"long NDEF length", append([]byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0xFF, 0x00, 0x02}, 0xD0, 0x00), 9, 2, 1, ndefTLVFound},
+		{"terminator only", []byte{0xFE, 0x00, 0x00, 0x00}, 0, 0, 0, ndefTLVAbsent},
+		{"unformatted page", []byte{0x00, 0x00, 0x00, 0x00}, 0, 0, 0, ndefTLVAbsent},
+		{"unknown block", []byte{0x42, 0x01, 0x00}, 0, 0, 0, ndefTLVAbsent},
+		{"lock control truncated", []byte{0x01, 0x03, 0xA0}, 0, 0, 0, ndefTLVIncomplete},
+		{"NDEF truncated", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x08, 0xD1}, 7, 8, 1, ndefTLVIncomplete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, length, skipped, state := locateNDEFTLV(tt.data)
+			if state != tt.wantState {
+				t.Fatalf("state = %v, want %v", state, tt.wantState)
+			}
+			if state == ndefTLVAbsent {
+				return
+			}
+			if start != tt.wantStart || length != tt.wantLength {
+				t.Errorf("got start %d length %d, want %d %d", start, length, tt.wantStart, tt.wantLength)
+			}
+			if len(skipped) != tt.wantSkipped {
+				t.Errorf("skipped %d TLVs, want %d", len(skipped), tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestValidateControlTLVs(t *testing.T) {
+	tests := []struct {
+		name    string
+		tlvs    []TLV
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"lock control", []TLV{{Type: TLVLockControl, Value: []byte{0xA0, 0x0C, 0x34}}}, false},
+		{"memory control", []TLV{{Type: TLVMemoryControl, Value: []byte{0x03, 0x82, 0x04}}}, false},
+		{"proprietary", []TLV{{Type: TLVProprietary, Value: []byte("vendor")}}, false},
+		{"short lock control", []TLV{{Type: TLVLockControl, Value: []byte{0xA0}}}, true},
+		{"NDEF type", []TLV{{Type: TLVNDEF, Value: []byte{0xD0, 0x00, 0x00}}}, true},
+		{"terminator type", []TLV{{Type: TLVTerminator}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateControlTLVs(tt.tlvs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateControlTLVs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithControlTLVs(t *testing.T) {
+	ndef := wrapNDEFTLV([]byte{0xD0, 0x00, 0x00})
+	lock := []TLV{{Type: TLVLockControl, Value: []byte{0xA0, 0x0C, 0x34}}}
+
+	got, err := withControlTLVs(&Card{Type: "NTAG216"}, ndef, lock)
+	if err != nil {
+		t.Fatalf("withControlTLVs failed: %v", err)
+	}
+	want := append([]byte{0x01, 0x03, 0xA0, 0x0C, 0x34}, ndef...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("withControlTLVs() = %X, want %X", got, want)
+	}
+
+	// What is written must read back to the same NDEF message
+	start, length, _, state := locateNDEFTLV(got)
+	if state != ndefTLVFound || !bytes.Equal(got[start:start+length], []byte{0xD0, 0x00, 0x00}) {
+		t.Errorf("written TLVs don't locate the NDEF message: state %v", state)
+	}
+
+	if _, err := withControlTLVs(&Card{Type: "MIFARE Classic"}, ndef, lock); err == nil {
+		t.Error("expected error for MIFARE Classic")
+	}
+	if got, _ := withControlTLVs(&Card{Type: "MIFARE Classic"}, ndef, nil); !bytes.Equal(got, ndef) {
+		t.Error("no control TLVs should leave the NDEF TLV unchanged")
+	}
+}
+
+func TestEncodeTLVs_LongValue(t *testing.T) {
+	value := make([]byte, 300)
+	got := encodeTLVs([]TLV{{Type: TLVProprietary, Value: value}})
+	if !bytes.Equal(got[:4], []byte{0xFD, 0xFF, 0x01, 0x2C}) || len(got) != 304 {
+		t.Errorf("unexpected long TLV header %X (len %d)", got[:4], len(got))
+	}
+}
+
+func TestOmitTerminatorIfFull(t *testing.T) {
+	ntag213 := &Card{Type: "NTAG213"} // 144 bytes of user memory
+
+	// NDEF TLV filling user memory exactly: the terminator is dropped
+	full := wrapNDEFTLV(createNDEFRecordRaw(0x02, []byte("a/b"), make([]byte, 136), true, true))
+	if len(full) != 145 {
+		t.Fatalf("test TLV is %d bytes, want 145", len(full))
+	}
+	got := omitTerminatorIfFull(ntag213, 4, full)
+	if len(got) != 144 || checkUserMemory(ntag213, 4, len(got)) != nil {
+		t.Fatalf("expected a 144-byte TLV that fits, got %d bytes", len(got))
+	}
+
+	// The declared length still locates the whole message without a terminator
+	start, length, _, state := locateNDEFTLV(got)
+	if state != ndefTLVFound || start+length != len(got) {
+		t.Errorf("full-capacity TLV not located: state %v, start %d, length %d", state, start, length)
+	}
+
+	// Room for the terminator: kept
+	short := wrapNDEFTLV([]byte{0xD0, 0x00, 0x00})
+	if got := omitTerminatorIfFull(ntag213, 4, short); !bytes.Equal(got, short) {
+		t.Errorf("terminator dropped although it fits: %X", got)
+	}
+
+	// Too large even without the terminator: unchanged, so the capacity check fails
+	tooLarge := append(append([]byte{}, full[:len(full)-1]...), 0x00, TLVTerminator)
+	if got := omitTerminatorIfFull(ntag213, 4, tooLarge); len(got) != len(tooLarge) {
+		t.Errorf("expected oversized TLV to be left unchanged, got %d bytes", len(got))
+	}
+
+	// Unknown card types have no known capacity
+	if got := omitTerminatorIfFull(&Card{Type: "Unknown"}, 4, full); !bytes.Equal(got, full) {
+		t.Error("expected terminator kept for a card of unknown capacity")
+	}
+}
+
+func TestNDEFTLVHeaderOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"NDEF at offset 0", []byte{0x03, 0x02, 0xD0, 0x00, 0xFE}, 0},
+		{"after lock control", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0x02, 0xD0, 0x00, 0xFE}, 5},
+		{"long NDEF length", []byte{0x01, 0x03, 0xA0, 0x0C, 0x34, 0x03, 0xFF, 0x00, 0x02, 0xD0, 0x00}, 5},
+		{"short length after 0xFF value byte", []byte{0xFD, 0x01, 0xFF, 0x03, 0x02, 0xD0, 0x00}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, length, _, state := locateNDEFTLV(tt.data)
+			if state != ndefTLVFound {
+				t.Fatalf("state = %v, want found", state)
+			}
+			if got := ndefTLVHeaderOffset(tt.data, start, length); got != tt.want {
+				t.Errorf("got offset %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// cardTypeType4 is the card type of ISO-DEP tags with an NFC Forum Type 4
+// NDEF application (NTAG 424 DNA, DESFire with NDEF).
+const cardTypeType4 = "NFC Forum Type 4"
+
+// Type 4 tag ISO 7816-4 commands.
+var (
+	type4SelectNDEFApp = []byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00}
+	type4CCFileID      = []byte{0xE1, 0x03}
+)
+
+// Type 4 CC access conditions for the NDEF file.
+const (
+	type4AccessGranted = 0x00
+	type4AccessDenied  = 0xFF
+)
+
+// type4CC is the NDEF File Control TLV of a Type 4 capability container.
+type type4CC struct {
+	MLe         int // Max bytes per READ BINARY
+	MLc         int // Max bytes per UPDATE BINARY
+	FileID      []byte
+	MaxSize     int // NDEF file size, including the 2-byte NLEN
+	ReadAccess  byte
+	WriteAccess byte
+}
+
+// selectType4NDEF selects the NDEF application, reads the capability
+// container and leaves the NDEF file selected.
+func selectType4NDEF(card cardTransmitter) (*type4CC, error) {
+	if _, err := type4Command(card, type4SelectNDEFApp); err != nil {
+		return nil, fmt.Errorf("select NDEF application: %w", err)
+	}
+	if err := type4SelectFile(card, type4CCFileID); err != nil {
+		return nil, fmt.Errorf("select CC file: %w", err)
+	}
+	rsp, err := type4Command(card, []byte{0x00, 0xB0, 0x00, 0x00, 0x0F})
+	if err != nil {
+		return nil, fmt.Errorf("read CC file: %w", err)
+	}
+	cc, err := parseType4CC(rsp)
+	if err != nil {
+		return nil, err
+	}
+	if err := type4SelectFile(card, cc.FileID); err != nil {
+		return nil, fmt.Errorf("select NDEF file: %w", err)
+	}
+	return cc, nil
+}
+
+// parseType4CC decodes the capability container: CCLEN, mapping version,
+// MLe, MLc, then the NDEF File Control TLV (T=04, L=06).
+func parseType4CC(cc []byte) (*type4CC, error) {
+	if len(cc) < 15 || cc[7] != 0x04 || cc[8] < 0x06 {
+		return nil, fmt.Errorf("invalid Type 4 capability container: %s", hex.EncodeToString(cc))
+	}
+	parsed := &type4CC{
+		MLe:         int(cc[3])<<8 | int(cc[4]),
+		MLc:         int(cc[5])<<8 | int(cc[6]),
+		FileID:      []byte{cc[9], cc[10]},
+		MaxSize:     int(cc[11])<<8 | int(cc[12]),
+		ReadAccess:  cc[13],
+		WriteAccess: cc[14],
+	}
+	if parsed.MLe < 1 || parsed.MLc < 1 || parsed.MaxSize < 2 {
+		return nil, fmt.Errorf("invalid Type 4 capability container: %s", hex.EncodeToString(cc))
+	}
+	return parsed, nil
+}
+
+// applyType4CC records a detected Type 4 tag on cardInfo.
+func applyType4CC(cardInfo *Card, cc *type4CC) {
+	cardInfo.Type = cardTypeType4
+	cardInfo.Size = cc.MaxSize - 2
+	cardInfo.Writable = cc.WriteAccess == type4AccessGranted
+	cardInfo.NDEFReadOnly = cc.WriteAccess == type4AccessDenied
+}
+
+// readType4NDEF reads the NDEF message from the selected NDEF file: the NLEN
+// prefix, then the message in chunks of at most MLe bytes.
+func readType4NDEF(card cardTransmitter, cc *type4CC) ([]byte, error) {
+	nlen, err := type4ReadBinary(card, 0, 2)
+	if err != nil {
+		return nil, fmt.Errorf("read NLEN: %w", err)
+	}
+	length := int(nlen[0])<<8 | int(nlen[1])
+	if length > cc.MaxSize-2 {
+		return nil, fmt.Errorf("NLEN %d exceeds NDEF file size %d", length, cc.MaxSize)
+	}
+
+	chunk := min(cc.MLe, 0xFF)
+	message := make([]byte, 0, length)
+	for len(message) < length {
+		n := min(chunk, length-len(message))
+		data, err := type4ReadBinary(card, 2+len(message), n)
+		if err != nil {
+			return nil, fmt.Errorf("read NDEF at offset %d: %w", 2+len(message), err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("read NDEF at offset %d: no data", 2+len(message))
+		}
+		message = append(message, data...)
+	}
+	return message[:length], nil
+}
+
+// writeType4NDEF writes an NDEF message (without TLV wrapping) to the
+// selected NDEF file. NLEN is zeroed first and written last, so an
+// interrupted write leaves an empty file rather than a truncated message.
+func writeType4NDEF(card cardTransmitter, cc *type4CC, message []byte) error {
+	if cc.WriteAccess != type4AccessGranted {
+		return ErrNDEFReadOnly
+	}
+	if len(message) > cc.MaxSize-2 {
+		return fmt.Errorf("NDEF message is %d bytes, tag holds %d", len(message), cc.MaxSize-2)
+	}
+
+	if err := type4UpdateBinary(card, 0, []byte{0x00, 0x00}); err != nil {
+		return fmt.Errorf("clear NLEN: %w", err)
+	}
+
+	chunk := min(cc.MLc, 0xFF)
+	for offset := 0; offset < len(message); offset += chunk {
+		end := min(offset+chunk, len(message))
+		if err := type4UpdateBinary(card, 2+offset, message[offset:end]); err != nil {
+			return fmt.Errorf("write NDEF at offset %d: %w", 2+offset, err)
+		}
+	}
+
+	nlen := []byte{byte(len(message) >> 8), byte(len(message))}
+	if err := type4UpdateBinary(card, 0, nlen); err != nil {
+		return fmt.Errorf("write NLEN: %w", err)
+	}
+	return nil
+}
+
+// writeType4NDEFTLV writes the NDEF message held in an NDEF TLV, as built for
+// Type 2 tags, to a Type 4 tag.
+func writeType4NDEFTLV(card cardTransmitter, tlv []byte) error {
+	start, length, _, state := locateNDEFTLV(tlv)
+	if state != ndefTLVFound {
+		return fmt.Errorf("no NDEF message to write")
+	}
+	cc, err := selectType4NDEF(card)
+	if err != nil {
+		return err
+	}
+	return writeType4NDEF(card, cc, tlv[start:start+length])
+}
+
+func type4SelectFile(card cardTransmitter, fileID []byte) error {
+	_, err := type4Command(card, append([]byte{0x00, 0xA4, 0x00, 0x0C, 0x02}, fileID...))
+	return err
+}
+
+func type4ReadBinary(card cardTransmitter, offset, n int) ([]byte, error) {
+	return type4Command(card, []byte{0x00, 0xB0, byte(offset >> 8), byte(offset), byte(n)})
+}
+
+func type4UpdateBinary(card cardTransmitter, offset int, data []byte) error {
+	cmd := append([]byte{0x00, 0xD6, byte(offset >> 8), byte(offset), byte(len(data))}, data...)
+	_, err := type4Command(card, cmd)
+	return err
+}
+
+// type4Command sends an APDU and returns its data, failing unless the status
+// word is 9000.
+func type4Command(card cardTransmitter, cmd []byte) ([]byte, error) {
+	rsp, err := transmit(card, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp) < 2 {
+		return nil, fmt.Errorf("invalid response length: %d", len(rsp))
+	}
+	if sw1, sw2 := rsp[len(rsp)-2], rsp[len(rsp)-1]; sw1 != 0x90 || sw2 != 0x00 {
+		return nil, fmt.Errorf("command failed with status: %02X %02X", sw1, sw2)
+	}
+	return rsp[:len(rsp)-2], nil
+}
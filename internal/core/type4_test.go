@@ -0,0 +1,174 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// simulatedType4 is an in-memory NTAG 424 DNA style Type 4 tag with a CC file
+// (E103) and an NDEF file (E104).
+type simulatedType4 struct {
+	cc       []byte
+	ndef     []byte
+	selected []byte // Selected file ID
+	appOK    bool   // NDEF application selected
+	updates  []int  // Offsets of UPDATE BINARY commands, in order
+	failAt   int    // Fail the UPDATE BINARY with this index (0 = never)
+}
+
+func newSimulatedType4(mle, mlc, size int, writeAccess byte) *simulatedType4 {
+	return &simulatedType4{
+		cc: []byte{
+			0x00, 0x17, 0x20,
+			byte(mle >> 8), byte(mle), byte(mlc >> 8), byte(mlc),
+			0x04, 0x06, 0xE1, 0x04, byte(size >> 8), byte(size), 0x00, writeAccess,
+		},
+		ndef: make([]byte, size),
+	}
+}
+
+func (s *simulatedType4) file() []byte {
+	switch {
+	case bytes.Equal(s.selected, []byte{0xE1, 0x03}):
+		return s.cc
+	case bytes.Equal(s.selected, []byte{0xE1, 0x04}):
+		return s.ndef
+	}
+	return nil
+}
+
+func (s *simulatedType4) Transmit(cmd []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cmd, type4SelectNDEFApp):
+		s.appOK = true
+		return []byte{0x90, 0x00}, nil
+	case len(cmd) == 7 && cmd[1] == 0xA4 && cmd[2] == 0x00:
+		s.selected = cmd[5:7]
+		if !s.appOK || s.file() == nil {
+			return []byte{0x6A, 0x82}, nil
+		}
+		return []byte{0x90, 0x00}, nil
+	case len(cmd) == 5 && cmd[1] == 0xB0:
+		offset, n := int(cmd[2])<<8|int(cmd[3]), int(cmd[4])
+		f := s.file()
+		if offset+n > len(f) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		return append(append([]byte(nil), f[offset:offset+n]...), 0x90, 0x00), nil
+	case len(cmd) > 5 && cmd[1] == 0xD6:
+		offset, n := int(cmd[2])<<8|int(cmd[3]), int(cmd[4])
+		s.updates = append(s.updates, offset)
+		if len(s.updates) == s.failAt {
+			return []byte{0x65, 0x81}, nil
+		}
+		f := s.file()
+		if offset+n > len(f) || len(cmd) != 5+n {
+			return []byte{0x6B, 0x00}, nil
+		}
+		copy(f[offset:], cmd[5:])
+		return []byte{0x90, 0x00}, nil
+	}
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestType4_WriteReadRoundtrip(t *testing.T) {
+	tag := newSimulatedType4(0x3B, 0x34, 256, type4AccessGranted)
+	message := createNDEFRecordRaw(0x01, []byte("T"), append([]byte{0x02, 'e', 'n'}, bytes.Repeat([]byte("x"), 150)...), true, true)
+
+	if err := writeType4NDEFTLV(tag, wrapNDEFTLV(message)); err != nil {
+		t.Fatalf("writeType4NDEFTLV failed: %v", err)
+	}
+
+	// NLEN cleared first, message chunked by MLc, NLEN written last
+	want := []int{0, 2, 2 + 0x34, 2 + 2*0x34, 2 + 3*0x34, 0}
+	if len(tag.updates) != len(want) {
+		t.Fatalf("update offsets = %v, want %v", tag.updates, want)
+	}
+	for i := range want {
+		if tag.updates[i] != want[i] {
+			t.Fatalf("update offsets = %v, want %v", tag.updates, want)
+		}
+	}
+
+	cc, err := selectType4NDEF(tag)
+	if err != nil {
+		t.Fatalf("selectType4NDEF failed: %v", err)
+	}
+	got, err := readType4NDEF(tag, cc)
+	if err != nil {
+		t.Fatalf("readType4NDEF failed: %v", err)
+	}
+	if !bytes.Equal(got, message) {
+		t.Errorf("read back %x, want %x", got, message)
+	}
+}
+
+func TestType4_InterruptedWriteLeavesEmptyFile(t *testing.T) {
+	tag := newSimulatedType4(0x3B, 0x34, 256, type4AccessGranted)
+	old := createNDEFTextRecord("old")
+	if err := writeType4NDEFTLV(tag, old); err != nil {
+		t.Fatalf("initial write failed: %v", err)
+	}
+
+	tag.updates, tag.failAt = nil, 3 // Fail partway through the message
+	message := createNDEFRecordRaw(0x01, []byte("T"), append([]byte{0x02, 'e', 'n'}, bytes.Repeat([]byte("y"), 150)...), true, true)
+	if err := writeType4NDEFTLV(tag, wrapNDEFTLV(message)); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+
+	cc, _ := selectType4NDEF(tag)
+	got, err := readType4NDEF(tag, cc)
+	if err != nil || len(got) != 0 {
+		t.Errorf("after an interrupted write got %x (%v), want an empty message", got, err)
+	}
+}
+
+func TestType4_ReadOnlyAndTooLarge(t *testing.T) {
+	tag := newSimulatedType4(0x3B, 0x34, 64, type4AccessDenied)
+	cc, err := selectType4NDEF(tag)
+	if err != nil {
+		t.Fatalf("selectType4NDEF failed: %v", err)
+	}
+
+	cardInfo := &Card{}
+	applyType4CC(cardInfo, cc)
+	if cardInfo.Type != cardTypeType4 || cardInfo.Size != 62 || cardInfo.Writable || !cardInfo.NDEFReadOnly {
+		t.Errorf("applyType4CC = %+v", cardInfo)
+	}
+
+	if err := writeType4NDEF(tag, cc, []byte{0xD1, 0x01, 0x00, 'T'}); !errors.Is(err, ErrNDEFReadOnly) {
+		t.Errorf("write to read-only file: got %v, want ErrNDEFReadOnly", err)
+	}
+
+	cc.WriteAccess = type4AccessGranted
+	if err := writeType4NDEF(tag, cc, make([]byte, 63)); err == nil {
+		t.Error("expected an error for a message larger than the NDEF file")
+	}
+	if len(tag.updates) != 0 {
+		t.Errorf("refused writes should not touch the tag, got updates at %v", tag.updates)
+	}
+}
+
+func TestDetectCardType_Type4(t *testing.T) {
+	tag := newSimulatedType4(0x3B, 0x34, 256, type4AccessGranted)
+	// ISO 14443-4 ATR: contactless, but no PC/SC storage card historical bytes
+	cardInfo := &Card{ATR: "3b8180018080"}
+
+	detectCardType(tag, cardInfo)
+	if cardInfo.Type != cardTypeType4 || cardInfo.Protocol != "ISO-DEP" || cardInfo.Size != 254 || !cardInfo.Writable {
+		t.Errorf("detected %+v", cardInfo)
+	}
+}
+
+func TestParseType4CC_Invalid(t *testing.T) {
+	for _, cc := range [][]byte{
+		{0x00, 0x0F, 0x20, 0x00, 0x3B},
+		{0x00, 0x0F, 0x20, 0x00, 0x3B, 0x00, 0x34, 0x05, 0x06, 0xE1, 0x04, 0x01, 0x00, 0x00, 0x00},
+		{0x00, 0x0F, 0x20, 0x00, 0x00, 0x00, 0x34, 0x04, 0x06, 0xE1, 0x04, 0x01, 0x00, 0x00, 0x00},
+	} {
+		if _, err := parseType4CC(cc); err == nil {
+			t.Errorf("parseType4CC(% X) should fail", cc)
+		}
+	}
+}
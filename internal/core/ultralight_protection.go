@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/ebfe/scard"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// ErrPasswordRequired is returned when a page is behind the tag's password
+// (at or after AUTH0) and no password, or no working one, was available.
+var ErrPasswordRequired = errors.New("password required")
+
+// UltralightPassword returns the password to retry protected Ultralight EV1 and
+// NTAG reads with when the caller didn't supply one, or nil if none is
+// configured. Set by main from the user settings.
+var UltralightPassword = func() []byte { return nil }
+
+// ultralightConfigPage returns the CFG0 page (holding AUTH0 in byte 3) of a
+// password-capable Ultralight EV1 or NTAG21x tag.
+func ultralightConfigPage(cardInfo *Card) (int, bool) {
+	if layout, ok := ntagLayouts[cardInfo.Type]; ok {
+		return layout.dynamicLockPage + 1, true
+	}
+	if cardInfo.Type == "MIFARE Ultralight EV1" {
+		if cardInfo.Size == 128 { // MF0UL21
+			return 0x25, true
+		}
+		return 0x10, true // MF0UL11
+	}
+	return 0, false
+}
+
+// reactivateCard resets the card so it can be selected again. A NAK, such as
+// the one a protected page read gets, sends the tag back to IDLE, after which
+// it ignores further commands.
+func reactivateCard(card *scard.Card) error {
+	return card.Reconnect(cardShareMode(), scard.ProtocolAny, scard.ResetCard)
+}
+
+// readWithConfiguredPassword retries a failed page read after authenticating
+// with the configured password. It reports false if no password is configured
+// or the retry failed too.
+func readWithConfiguredPassword(card *scard.Card, page int, read func(cardTransmitter, int) ([]byte, error)) ([]byte, bool) {
+	password := UltralightPassword()
+	if len(password) != 4 {
+		return nil, false
+	}
+	if err := reactivateCard(card); err != nil {
+		return nil, false
+	}
+	if err := authenticateUltralight(card, password); err != nil {
+		logCard(card, logging.LevelDebug, logging.CatCard, "Configured Ultralight password rejected", map[string]any{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	data, err := read(card, page)
+	if err != nil {
+		return nil, false
+	}
+	logCard(card, logging.LevelDebug, logging.CatCard, "Page read with configured password", map[string]any{
+		"page": page,
+	})
+	return data, true
+}
+
+// passwordProtection reports whether a failed read of failedPage on a card
+// detected as cardInfo was caused by password protection. auth0 is the first
+// protected page, or 0 if the configuration pages are themselves protected
+// and AUTH0 can't be read. Cards without a password are not probed.
+func passwordProtection(card *scard.Card, cardInfo *Card, failedPage int) (auth0 int, protected bool) {
+	cfgPage, ok := ultralightConfigPage(cardInfo)
+	if !ok {
+		return 0, false
+	}
+	if err := reactivateCard(card); err != nil {
+		return 0, false
+	}
+
+	cfg, err := readNTAGPage(card, cfgPage)
+	if err != nil || len(cfg) < 4 {
+		// With PROT set the configuration pages are unreadable too
+		return 0, true
+	}
+	auth0 = int(cfg[3])
+	return auth0, failedPage >= auth0
+}
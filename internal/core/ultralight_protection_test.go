@@ -0,0 +1,38 @@
+package core
+
+import "testing"
+
+func TestUltralightConfigPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     Card
+		wantPage int
+		wantOK   bool
+	}{
+		{"NTAG213", Card{Type: "NTAG213"}, 0x29, true},
+		{"NTAG215", Card{Type: "NTAG215"}, 0x83, true},
+		{"NTAG216", Card{Type: "NTAG216"}, 0xE3, true},
+		{"Ultralight EV1 MF0UL11", Card{Type: "MIFARE Ultralight EV1", Size: 48}, 0x10, true},
+		{"Ultralight EV1 MF0UL21", Card{Type: "MIFARE Ultralight EV1", Size: 128}, 0x25, true},
+		{"MIFARE Classic", Card{Type: "MIFARE Classic"}, 0, false},
+		{"unknown", Card{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, ok := ultralightConfigPage(&tt.card)
+			if page != tt.wantPage || ok != tt.wantOK {
+				t.Errorf("ultralightConfigPage() = (%#x, %v), want (%#x, %v)", page, ok, tt.wantPage, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPasswordProtection_SkipsCardsWithoutPassword(t *testing.T) {
+	// A card without a password is never reset or read, so no connection is needed
+	for _, cardType := range []string{"MIFARE Ultralight", "ICode SLIX", "Unknown"} {
+		if auth0, protected := passwordProtection(nil, &Card{Type: cardType}, 4); protected || auth0 != 0 {
+			t.Errorf("%s: passwordProtection() = (%d, %v), want (0, false)", cardType, auth0, protected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidURI is returned for a URI that phones may refuse to open: one with
+// characters that must be percent-encoded, a malformed percent-escape, or
+// that net/url can't parse.
+var ErrInvalidURI = errors.New("invalid URI")
+
+// CheckURI reports whether uri can be written to a URI record as is.
+func CheckURI(uri string) error {
+	if uri == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidURI)
+	}
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		if c == '%' {
+			if !validPercentEscape(uri, i) {
+				return fmt.Errorf("%w: malformed percent-escape at position %d", ErrInvalidURI, i)
+			}
+			i += 2
+			continue
+		}
+		if !isURIChar(c) {
+			r, _ := utf8.DecodeRuneInString(uri[i:])
+			return fmt.Errorf("%w: %q at position %d must be percent-encoded", ErrInvalidURI, r, i)
+		}
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURI, err)
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return fmt.Errorf("%w: %s URI has no host", ErrInvalidURI, u.Scheme)
+	}
+	return nil
+}
+
+// EncodeURI percent-encodes the bytes of uri that aren't allowed in a URI,
+// such as spaces and non-ASCII characters (as UTF-8), and any '%' that
+// doesn't start a valid escape. Reserved characters and valid escapes are
+// kept. The result is checked with CheckURI.
+func EncodeURI(uri string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		if (c == '%' && validPercentEscape(uri, i)) || (c != '%' && isURIChar(c)) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	encoded := b.String()
+	return encoded, CheckURI(encoded)
+}
+
+// isURIChar reports whether c may appear unencoded in a URI: an unreserved or
+// reserved character of RFC 3986.
+func isURIChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~:/?#[]@!$&'()*+,;=", c) >= 0
+}
+
+// validPercentEscape reports whether s[i] starts a "%XX" escape.
+func validPercentEscape(s string, i int) bool {
+	return i+2 < len(s) && isHexDigit(s[i+1]) && isHexDigit(s[i+2])
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckURI(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{"https://example.com/path?q=1#top", true},
+		{"https://example.com/a%20b", true},
+		{"tel:+15551234567", true},
+		{"mailto:info@example.com", true},
+		{"urn:nfc:sn:123", true},
+		{"https://example.com/[x]", true},
+		{"", false},
+		{"https://example.com/a b", false},
+		{"https://example.com/ä", false},
+		{"https://example.com/<script>", false},
+		{"https://example.com/100%", false},
+		{"https://example.com/%zz", false},
+		{"https://", false},
+		{"http://[::1", false},
+	}
+	for _, tt := range tests {
+		err := CheckURI(tt.uri)
+		if tt.valid && err != nil {
+			t.Errorf("CheckURI(%q) = %v, want valid", tt.uri, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidURI) {
+			t.Errorf("CheckURI(%q) = %v, want ErrInvalidURI", tt.uri, err)
+		}
+	}
+}
+
+func TestEncodeURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://example.com/a b", "https://example.com/a%20b"},
+		{"https://example.com/ä", "https://example.com/%C3%A4"},
+		{"https://example.com/100%", "https://example.com/100%25"},
+		{"https://example.com/a%20b", "https://example.com/a%20b"},
+		{"https://example.com/?q=\"x\"", "https://example.com/?q=%22x%22"},
+	}
+	for _, tt := range tests {
+		got, err := EncodeURI(tt.uri)
+		if err != nil || got != tt.want {
+			t.Errorf("EncodeURI(%q) = %q, %v, want %q", tt.uri, got, err, tt.want)
+		}
+	}
+
+	// Encoding can't fix a URI that doesn't parse
+	if _, err := EncodeURI("https://"); !errors.Is(err, ErrInvalidURI) {
+		t.Errorf("expected ErrInvalidURI for a URI without host, got %v", err)
+	}
+}
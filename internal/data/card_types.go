@@ -0,0 +1,131 @@
+package data
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CardTypeMatch is the card type and memory size a detection rule resolves to
+type CardTypeMatch struct {
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+// VersionRule maps a GET_VERSION response to a card type.
+// Bytes are hex strings; an empty StorageSize matches any storage size.
+type VersionRule struct {
+	ProductType string `json:"productType"`
+	StorageSize string `json:"storageSize,omitempty"`
+	CardTypeMatch
+}
+
+// CCRule maps the capability container memory size byte to a card type
+type CCRule struct {
+	CCSize string `json:"ccSize"`
+	CardTypeMatch
+}
+
+// ATRRule maps an ATR pattern to a card type
+type ATRRule struct {
+	Pattern          string         `json:"pattern"`                    // Hex substring the ATR must contain
+	Byte14           string         `json:"byte14,omitempty"`           // Required ATR byte 14 (Classic vs Type 2)
+	Manufacturer     string         `json:"manufacturer,omitempty"`     // Required ISO 15693 manufacturer code (UID byte 7)
+	UnidentifiedOnly bool           `json:"unidentifiedOnly,omitempty"` // Only when GET_VERSION and CC detection found nothing
+	SAKSizes         map[string]int `json:"sakSizes,omitempty"`         // Size overrides keyed by SAK
+	CardTypeMatch
+}
+
+// CardTypesData is the root structure of the card type detection table.
+// Rules are evaluated in order and the first match wins, so specific rules
+// must come before wildcards.
+type CardTypesData struct {
+	GetVersion          []VersionRule `json:"getVersion"`
+	CapabilityContainer []CCRule      `json:"capabilityContainer"`
+	ATR                 []ATRRule     `json:"atr"`
+}
+
+//go:embed card_types.json
+var cardTypesJSON []byte
+
+// GetCardTypes returns the card type detection table
+func GetCardTypes() (*CardTypesData, error) {
+	var data CardTypesData
+	if err := json.Unmarshal(cardTypesJSON, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// MatchVersion looks up a card type by GET_VERSION product type and storage size bytes
+func (d *CardTypesData) MatchVersion(productType, storageSize byte) (CardTypeMatch, bool) {
+	for _, rule := range d.GetVersion {
+		if !hexByteEquals(rule.ProductType, productType) {
+			continue
+		}
+		if rule.StorageSize != "" && !hexByteEquals(rule.StorageSize, storageSize) {
+			continue
+		}
+		return rule.CardTypeMatch, true
+	}
+	return CardTypeMatch{}, false
+}
+
+// MatchCC looks up a card type by capability container memory size byte
+func (d *CardTypesData) MatchCC(ccSize byte) (CardTypeMatch, bool) {
+	for _, rule := range d.CapabilityContainer {
+		if hexByteEquals(rule.CCSize, ccSize) {
+			return rule.CardTypeMatch, true
+		}
+	}
+	return CardTypeMatch{}, false
+}
+
+// MatchATR looks up a card type by ATR, UID and SAK (all lowercase hex).
+// identified reports whether GET_VERSION or CC detection already recognised the tag.
+func (d *CardTypesData) MatchATR(atr, uid, sak string, identified bool) (CardTypeMatch, bool) {
+	for _, rule := range d.ATR {
+		if !strings.Contains(atr, strings.ToLower(rule.Pattern)) {
+			continue
+		}
+		if rule.Byte14 != "" && !strings.EqualFold(atrCardNameByte(atr), rule.Byte14) {
+			continue
+		}
+		if rule.Manufacturer != "" && (len(uid) < 16 || !strings.EqualFold(uid[14:16], rule.Manufacturer)) {
+			continue
+		}
+		if rule.UnidentifiedOnly && identified {
+			continue
+		}
+
+		match := rule.CardTypeMatch
+		if size, ok := rule.SAKSizes[strings.ToLower(sak)]; ok {
+			match.Size = size
+		}
+		return match, true
+	}
+	return CardTypeMatch{}, false
+}
+
+// atrCardNameByte returns the low card name byte (NN) of a PC/SC Part 3
+// storage card ATR, located after the PC/SC RID rather than at a fixed offset
+// (it is byte 14 of the common 20-byte ATR). Returns "" if there is none.
+func atrCardNameByte(atr string) string {
+	idx := strings.Index(atr, pcscRIDHex)
+	if idx < 0 || idx%2 != 0 {
+		return ""
+	}
+	start := idx + len(pcscRIDHex) + 4 // Skip SS and the high NN byte
+	if start+2 > len(atr) {
+		return ""
+	}
+	return atr[start : start+2]
+}
+
+// pcscRIDHex is the PC/SC workgroup RID that precedes the storage card fields.
+const pcscRIDHex = "a000000306"
+
+func hexByteEquals(s string, b byte) bool {
+	return strings.EqualFold(s, fmt.Sprintf("%02x", b))
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestGetCardTypes(t *testing.T) {
+	table, err := GetCardTypes()
+	if err != nil {
+		t.Fatalf("GetCardTypes() returned error: %v", err)
+	}
+
+	if len(table.GetVersion) == 0 {
+		t.Error("GetCardTypes() returned no GET_VERSION rules")
+	}
+	if len(table.CapabilityContainer) == 0 {
+		t.Error("GetCardTypes() returned no capability container rules")
+	}
+	if len(table.ATR) == 0 {
+		t.Error("GetCardTypes() returned no ATR rules")
+	}
+}
+
+func TestGetCardTypes_ValidData(t *testing.T) {
+	table, err := GetCardTypes()
+	if err != nil {
+		t.Fatalf("GetCardTypes() returned error: %v", err)
+	}
+
+	isHexByte := func(s string) bool {
+		b, err := hex.DecodeString(s)
+		return err == nil && len(b) == 1
+	}
+	checkMatch := func(kind string, i int, m CardTypeMatch) {
+		if m.Type == "" {
+			t.Errorf("%s[%d] has empty type", kind, i)
+		}
+		if m.Size <= 0 {
+			t.Errorf("%s[%d] (%s) has invalid size %d", kind, i, m.Type, m.Size)
+		}
+	}
+
+	for i, rule := range table.GetVersion {
+		if !isHexByte(rule.ProductType) {
+			t.Errorf("getVersion[%d] has invalid productType %q", i, rule.ProductType)
+		}
+		if rule.StorageSize != "" && !isHexByte(rule.StorageSize) {
+			t.Errorf("getVersion[%d] has invalid storageSize %q", i, rule.StorageSize)
+		}
+		checkMatch("getVersion", i, rule.CardTypeMatch)
+	}
+	for i, rule := range table.CapabilityContainer {
+		if !isHexByte(rule.CCSize) {
+			t.Errorf("capabilityContainer[%d] has invalid ccSize %q", i, rule.CCSize)
+		}
+		checkMatch("capabilityContainer", i, rule.CardTypeMatch)
+	}
+	for i, rule := range table.ATR {
+		if _, err := hex.DecodeString(rule.Pattern); err != nil || rule.Pattern == "" {
+			t.Errorf("atr[%d] has invalid pattern %q", i, rule.Pattern)
+		}
+		if rule.Byte14 != "" && !isHexByte(rule.Byte14) {
+			t.Errorf("atr[%d] has invalid byte14 %q", i, rule.Byte14)
+		}
+		if rule.Manufacturer != "" && !isHexByte(rule.Manufacturer) {
+			t.Errorf("atr[%d] has invalid manufacturer %q", i, rule.Manufacturer)
+		}
+		checkMatch("atr", i, rule.CardTypeMatch)
+	}
+}
+
+func TestCardTypesData_MatchVersion(t *testing.T) {
+	table, err := GetCardTypes()
+	if err != nil {
+		t.Fatalf("GetCardTypes() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		productType byte
+		storageSize byte
+		wantType    string
+		wantSize    int
+		wantOK      bool
+	}{
+		{"NTAG213", 0x04, 0x0F, "NTAG213", 180, true},
+		{"NTAG215", 0x04, 0x11, "NTAG215", 504, true},
+		{"NTAG216", 0x04, 0x13, "NTAG216", 888, true},
+		{"Ultralight EV1 48", 0x03, 0x0B, "MIFARE Ultralight EV1", 48, true},
+		{"Ultralight EV1 128", 0x03, 0x0E, "MIFARE Ultralight EV1", 128, true},
+		{"unknown Ultralight variant", 0x03, 0x42, "MIFARE Ultralight", 64, true},
+		{"unknown NTAG storage", 0x04, 0x42, "", 0, false},
+		{"unknown product", 0x07, 0x0F, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.MatchVersion(tt.productType, tt.storageSize)
+			if ok != tt.wantOK || got.Type != tt.wantType || got.Size != tt.wantSize {
+				t.Errorf("MatchVersion(0x%02x, 0x%02x) = %+v, %v; want %s/%d, %v",
+					tt.productType, tt.storageSize, got, ok, tt.wantType, tt.wantSize, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCardTypesData_MatchCC(t *testing.T) {
+	table, err := GetCardTypes()
+	if err != nil {
+		t.Fatalf("GetCardTypes() returned error: %v", err)
+	}
+
+	tests := []struct {
+		ccSize   byte
+		wantType string
+		wantSize int
+		wantOK   bool
+	}{
+		{0x06, "MIFARE Ultralight", 48, true},
+		{0x12, "NTAG213", 180, true},
+		{0x3E, "NTAG215", 504, true},
+		{0x6D, "NTAG216", 888, true},
+		{0x10, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := table.MatchCC(tt.ccSize)
+		if ok != tt.wantOK || got.Type != tt.wantType || got.Size != tt.wantSize {
+			t.Errorf("MatchCC(0x%02x) = %+v, %v; want %s/%d, %v",
+				tt.ccSize, got, ok, tt.wantType, tt.wantSize, tt.wantOK)
+		}
+	}
+}
+
+func TestCardTypesData_MatchATR(t *testing.T) {
+	table, err := GetCardTypes()
+	if err != nil {
+		t.Fatalf("GetCardTypes() returned error: %v", err)
+	}
+
+	const (
+		atrType2   = "3b8f8001804f0ca000000306030003000000006b"
+		atrClassic = "3b8f8001804f0ca000000306030001000000006a"
+		atr15693   = "3b8f8001804f0ca0000003060b00140000000077"
+	)
+
+	tests := []struct {
+		name       string
+		atr        string
+		uid        string
+		sak        string
+		identified bool
+		wantType   string
+		wantSize   int
+		wantOK     bool
+	}{
+		{"NXP manufacturer", atr15693, "01020304050607e0", "", false, "ICode SLIX", 896, true},
+		{"generic ISO 15693", atr15693, "0102030405060716", "", false, "ISO 15693", 1024, true},
+		{"MIFARE Classic 1K", atrClassic, "01020304", "08", false, "MIFARE Classic", 1024, true},
+		{"MIFARE Classic 4K", atrClassic, "01020304", "18", false, "MIFARE Classic", 4096, true},
+		{"MIFARE Mini", atrClassic, "01020304", "09", false, "MIFARE Classic", 320, true},
+		{"MIFARE Classic 2K", atrClassic, "01020304", "19", false, "MIFARE Classic", 2048, true},
+		{"unidentified Type 2", atrType2, "01020304050607", "00", false, "MIFARE Ultralight", 64, true},
+		{"identified Type 2", atrType2, "01020304050607", "00", true, "", 0, false},
+		{"unknown ATR", "3b8f8001804f0ca0000003060900000000000000", "", "", false, "", 0, false},
+		{"Classic with TA1", "3b9f118001804f0ca000000306030001000000007b", "01020304", "08", false, "MIFARE Classic", 1024, true},
+		{"truncated ATR", "3b8f8001804f0ca0000003060300", "01020304", "08", false, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.MatchATR(tt.atr, tt.uid, tt.sak, tt.identified)
+			if ok != tt.wantOK || got.Type != tt.wantType || got.Size != tt.wantSize {
+				t.Errorf("MatchATR() = %+v, %v; want %s/%d, %v",
+					got, ok, tt.wantType, tt.wantSize, tt.wantOK)
+			}
+		})
+	}
+}
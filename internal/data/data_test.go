@@ -307,3 +307,58 @@ func BenchmarkSupportedReaderJSON(b *testing.B) {
 		}
 	})
 }
+
+func TestMatchReader(t *testing.T) {
+	readers, err := GetSupportedReaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pcscName string
+		want     string
+	}{
+		{"ACS ACR122U PICC Interface 00 00", "ACR122U"},
+		{"ACS ACR1252 1S CL Reader [ACR1252 1S CL Reader(1)] 00 00", ""},
+		{"ACS ACR1252U-A1 PICC 00 00", "ACR1252U"},
+		{"ACS ACR1255U-J1 PICC", "ACR1255U-J1"},
+		{"HID Global OMNIKEY 5022 Smart Card Reader 00 00", "HID OMNIKEY"},
+		{"Identiv uTrust 3700 F CL Reader 00 00", "Identiv uTrust"},
+		{"Generic Smart Card Reader", ""},
+	}
+	for _, tt := range tests {
+		got, ok := MatchReader(readers, tt.pcscName)
+		if ok != (tt.want != "") || got.Name != tt.want {
+			t.Errorf("MatchReader(%q) = %q, %v; want %q", tt.pcscName, got.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestHasCapability(t *testing.T) {
+	reader := SupportedReader{
+		SupportedTags: []string{"NTAG", "ISO 15693"},
+		Capabilities:  ReaderCapability{Read: true, NDEF: true},
+	}
+
+	for name, want := range map[string]bool{
+		"read":           true,
+		"write":          false,
+		"ndef":           true,
+		"display":        false,
+		"iso15693":       true,
+		"iso15693-read":  true,
+		"iso15693-write": false,
+		"felica-read":    false,
+		"bogus":          false,
+	} {
+		if got := reader.HasCapability(name); got != want {
+			t.Errorf("HasCapability(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	for name, want := range map[string]bool{"ntag-write": true, "iso15693": true, "display": true, "iso15693-erase": false, "write-read": false, "": false} {
+		if got := ValidCapability(name); got != want {
+			t.Errorf("ValidCapability(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
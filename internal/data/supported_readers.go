@@ -3,6 +3,7 @@ package data
 import (
 	_ "embed"
 	"encoding/json"
+	"strings"
 )
 
 // SupportedReader represents a known-to-work NFC reader with its capabilities
@@ -40,3 +41,113 @@ func GetSupportedReaders() ([]SupportedReader, error) {
 	}
 	return data.Readers, nil
 }
+
+// tagFamilies maps the tag family names used in capability names to the
+// entries of SupportedReader.SupportedTags.
+var tagFamilies = map[string]string{
+	"ntag":              "NTAG",
+	"mifare-classic":    "MIFARE Classic",
+	"mifare-ultralight": "MIFARE Ultralight",
+	"mifare-desfire":    "MIFARE DESFire",
+	"felica":            "FeliCa",
+	"iclass":            "iCLASS",
+	"iso14443a":         "ISO 14443 Type A",
+	"iso14443b":         "ISO 14443 Type B",
+	"iso15693":          "ISO 15693",
+}
+
+// ValidCapability reports whether name is a capability HasCapability knows:
+// "read", "write", "ndef", "display", "bluetooth", a tag family such as
+// "iso15693", or a tag family with "-read" or "-write", e.g. "ntag-write".
+func ValidCapability(name string) bool {
+	switch name {
+	case "read", "write", "ndef", "display", "bluetooth":
+		return true
+	}
+	family := strings.TrimSuffix(strings.TrimSuffix(name, "-read"), "-write")
+	_, ok := tagFamilies[family]
+	return ok
+}
+
+// HasCapability reports whether the reader has the named capability (see
+// ValidCapability). Tag family capabilities need the family in SupportedTags,
+// plus read or write support for the "-read" and "-write" forms.
+func (r SupportedReader) HasCapability(name string) bool {
+	switch name {
+	case "read":
+		return r.Capabilities.Read
+	case "write":
+		return r.Capabilities.Write
+	case "ndef":
+		return r.Capabilities.NDEF
+	case "display":
+		return r.Capabilities.Display
+	case "bluetooth":
+		return r.Capabilities.Bluetooth
+	}
+
+	family, op := name, ""
+	if f, ok := strings.CutSuffix(name, "-read"); ok {
+		family, op = f, "read"
+	} else if f, ok := strings.CutSuffix(name, "-write"); ok {
+		family, op = f, "write"
+	}
+	tag, ok := tagFamilies[family]
+	if !ok {
+		return false
+	}
+	supported := false
+	for _, t := range r.SupportedTags {
+		if strings.EqualFold(t, tag) {
+			supported = true
+		}
+	}
+	switch op {
+	case "read":
+		return supported && r.Capabilities.Read
+	case "write":
+		return supported && r.Capabilities.Write
+	default:
+		return supported
+	}
+}
+
+// MatchReader finds the supported reader a PC/SC reader name refers to: one
+// whose name's words all appear in it, ignoring case (so "HID OMNIKEY"
+// matches "HID Global OMNIKEY 5022 Smart Card Reader"). The longest matching
+// name wins.
+func MatchReader(readers []SupportedReader, pcscName string) (SupportedReader, bool) {
+	words := strings.Fields(strings.ToUpper(pcscName))
+	var best SupportedReader
+	found := false
+	for _, r := range readers {
+		matched := true
+		for _, w := range strings.Fields(strings.ToUpper(r.Name)) {
+			if !containsWord(words, w) {
+				matched = false
+				break
+			}
+		}
+		if matched && (!found || len(r.Name) > len(best.Name)) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// containsWord reports whether any of words is w or starts with w followed by
+// a non-alphanumeric character, so "ACR122U" matches "ACR122U-A9" but not
+// "ACR1222L".
+func containsWord(words []string, w string) bool {
+	for _, word := range words {
+		rest, ok := strings.CutPrefix(word, w)
+		if ok && (rest == "" || !isAlphanumeric(rest[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
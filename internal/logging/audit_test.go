@@ -0,0 +1,46 @@
+package logging
+
+import "testing"
+
+func TestAudit_HashChain(t *testing.T) {
+	first := Audit(AuditEntry{Client: "127.0.0.1:5000", Reader: "Reader A", Operation: "write_card", UID: "04AABB", Result: "success"})
+	second := Audit(AuditEntry{Client: "127.0.0.1:5001", Reader: "Reader B", Operation: "erase_card", Result: "failure", Error: "no card"})
+
+	if second.Seq != first.Seq+1 {
+		t.Errorf("seq = %d, want %d", second.Seq, first.Seq+1)
+	}
+	if second.PrevHash != first.Hash {
+		t.Errorf("prevHash = %q, want hash of previous entry %q", second.PrevHash, first.Hash)
+	}
+	for _, e := range []AuditEntry{first, second} {
+		if e.Hash == "" || AuditHash(e) != e.Hash {
+			t.Errorf("entry %d hash does not verify", e.Seq)
+		}
+	}
+
+	tampered := second
+	tampered.Result = "success"
+	if AuditHash(tampered) == second.Hash {
+		t.Error("altering an entry should change its hash")
+	}
+}
+
+func TestAuditEntries_LimitAndFilter(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		Audit(AuditEntry{Client: "10.0.0.1:1", Reader: "Filter Reader", Operation: "lock_card", Result: "success"})
+	}
+	Audit(AuditEntry{Client: "10.0.0.2:1", Reader: "Other Reader", Operation: "lock_card", Result: "success"})
+
+	entries := AuditEntries(2, func(e AuditEntry) bool { return e.Reader == "Filter Reader" })
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Seq <= entries[1].Seq {
+		t.Errorf("entries not newest first: %d, %d", entries[0].Seq, entries[1].Seq)
+	}
+
+	newest := AuditEntries(1, nil)
+	if len(newest) != 1 || newest[0].Reader != "Other Reader" {
+		t.Errorf("newest entry = %+v, want Other Reader", newest)
+	}
+}
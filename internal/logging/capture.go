@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"strings"
+	"time"
+)
+
+// TraceEntry returns a log entry as a per-request trace records it: at any
+// level, whatever the logger's minimum level, so one request can be logged
+// at debug level without changing the global level. The entry is scrubbed as
+// the in-memory log would scrub it, and key and password fields are always
+// redacted.
+func TraceEntry(level Level, category Category, message string, data map[string]any) Entry {
+	if scrubSensitive.Load() {
+		message = ScrubString(message)
+		data = ScrubData(data)
+	} else {
+		data = maskSecretFields(data)
+	}
+	return Entry{
+		Timestamp: time.Now(),
+		Level:     level,
+		Category:  category,
+		Message:   message,
+		Data:      data,
+	}
+}
+
+// secretKeys are the data fields of keys and passwords, redacted from traced
+// entries even when sensitive scrubbing is off.
+var secretKeys = map[string]bool{
+	"key":      true,
+	"keya":     true,
+	"keyb":     true,
+	"authkey":  true,
+	"password": true,
+	"pwd":      true,
+	"pack":     true,
+}
+
+// maskSecretFields returns data with its secretKeys fields redacted, copying
+// it only if it has any.
+func maskSecretFields(data map[string]any) map[string]any {
+	for k := range data {
+		if !secretKeys[strings.ToLower(k)] {
+			continue
+		}
+		masked := make(map[string]any, len(data))
+		for k, v := range data {
+			if secretKeys[strings.ToLower(k)] {
+				v = Redacted
+			}
+			masked[k] = v
+		}
+		return masked
+	}
+	return data
+}
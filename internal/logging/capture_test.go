@@ -0,0 +1,19 @@
+package logging
+
+import "testing"
+
+func TestTraceEntry(t *testing.T) {
+	e := TraceEntry(LevelDebug, CatCard, "Page read", map[string]any{"page": 4, "keyA": "FFFFFFFFFFFF"})
+	if e.Level != LevelDebug || e.Message != "Page read" || e.Data["page"] != 4 {
+		t.Errorf("entry %+v", e)
+	}
+	if got := e.Data["keyA"]; got != Redacted {
+		t.Errorf("keyA = %v, want it always redacted", got)
+	}
+
+	SetScrubSensitive(true)
+	defer SetScrubSensitive(false)
+	if e := TraceEntry(LevelInfo, CatCard, "UID 04A1B2C3D4E5F6", nil); e.Message == "UID 04A1B2C3D4E5F6" {
+		t.Errorf("expected the message scrubbed, got %q", e.Message)
+	}
+}
@@ -72,8 +72,8 @@ Build Info:
 		time.Now().Format(time.RFC3339),
 		runtime.Version(),
 		runtime.GOOS, runtime.GOARCH,
-		panicValue,
-		string(stack),
+		scrubCrash(fmt.Sprintf("%v", panicValue)),
+		scrubCrash(string(stack)),
 		getBuildInfo(),
 	)
 
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxLatencySamples is how many recent durations are kept per operation and
+// card type for percentile calculation.
+const MaxLatencySamples = 500
+
+// LatencyStat summarises recorded durations for one operation and card type.
+type LatencyStat struct {
+	Operation string  `json:"operation"`
+	CardType  string  `json:"cardType"`
+	Count     int     `json:"count"` // Total operations recorded
+	P50Ms     float64 `json:"p50Ms"` // Median over the recent sample window
+	P95Ms     float64 `json:"p95Ms"`
+}
+
+type latencyKey struct {
+	operation string
+	cardType  string
+}
+
+type latencySeries struct {
+	count   int
+	samples []float64 // Ring buffer of durations in ms
+	next    int
+}
+
+var latency = struct {
+	mu     sync.Mutex
+	series map[latencyKey]*latencySeries
+}{series: make(map[latencyKey]*latencySeries)}
+
+// RecordLatency records how long an operation took on a card type.
+// An empty card type is recorded as "unknown".
+func RecordLatency(operation, cardType string, d time.Duration) {
+	if cardType == "" {
+		cardType = "unknown"
+	}
+	ms := float64(d) / float64(time.Millisecond)
+
+	latency.mu.Lock()
+	defer latency.mu.Unlock()
+
+	key := latencyKey{operation, cardType}
+	s, ok := latency.series[key]
+	if !ok {
+		s = &latencySeries{}
+		latency.series[key] = s
+	}
+	s.count++
+	if len(s.samples) < MaxLatencySamples {
+		s.samples = append(s.samples, ms)
+	} else {
+		s.samples[s.next] = ms
+		s.next = (s.next + 1) % MaxLatencySamples
+	}
+}
+
+// LatencyStats returns aggregated latency per operation and card type, sorted
+// by operation then card type.
+func LatencyStats() []LatencyStat {
+	latency.mu.Lock()
+	defer latency.mu.Unlock()
+
+	stats := make([]LatencyStat, 0, len(latency.series))
+	for key, s := range latency.series {
+		sorted := append([]float64(nil), s.samples...)
+		sort.Float64s(sorted)
+		stats = append(stats, LatencyStat{
+			Operation: key.operation,
+			CardType:  key.cardType,
+			Count:     s.count,
+			P50Ms:     percentile(sorted, 50),
+			P95Ms:     percentile(sorted, 95),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Operation != stats[j].Operation {
+			return stats[i].Operation < stats[j].Operation
+		}
+		return stats[i].CardType < stats[j].CardType
+	})
+	return stats
+}
+
+// ResetLatencyStats discards all recorded latencies.
+func ResetLatencyStats() {
+	latency.mu.Lock()
+	defer latency.mu.Unlock()
+	latency.series = make(map[latencyKey]*latencySeries)
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordLatency(t *testing.T) {
+	ResetLatencyStats()
+	defer ResetLatencyStats()
+
+	for i := 1; i <= 100; i++ {
+		RecordLatency("read_card", "NTAG215", time.Duration(i)*time.Millisecond)
+	}
+	RecordLatency("read_card", "ICode SLIX", 400*time.Millisecond)
+	RecordLatency("write_card", "", 50*time.Millisecond)
+
+	stats := LatencyStats()
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 stats, got %d: %+v", len(stats), stats)
+	}
+
+	// Sorted by operation, then card type
+	if stats[0].CardType != "ICode SLIX" || stats[1].CardType != "NTAG215" || stats[2].Operation != "write_card" {
+		t.Errorf("unexpected order: %+v", stats)
+	}
+
+	ntag := stats[1]
+	if ntag.Count != 100 || ntag.P50Ms != 50 || ntag.P95Ms != 95 {
+		t.Errorf("NTAG215 stats = %+v, want count 100, p50 50, p95 95", ntag)
+	}
+	if stats[0].P50Ms != 400 || stats[0].P95Ms != 400 {
+		t.Errorf("single sample stats = %+v", stats[0])
+	}
+	if stats[2].CardType != "unknown" {
+		t.Errorf("expected empty card type to be recorded as unknown, got %q", stats[2].CardType)
+	}
+}
+
+func TestRecordLatency_SampleWindow(t *testing.T) {
+	ResetLatencyStats()
+	defer ResetLatencyStats()
+
+	// Old slow samples fall out of the window; the count keeps growing
+	for i := 0; i < MaxLatencySamples; i++ {
+		RecordLatency("read_card", "NTAG213", time.Second)
+	}
+	for i := 0; i < MaxLatencySamples; i++ {
+		RecordLatency("read_card", "NTAG213", 10*time.Millisecond)
+	}
+
+	stats := LatencyStats()
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 stat, got %d", len(stats))
+	}
+	if stats[0].Count != 2*MaxLatencySamples {
+		t.Errorf("Count = %d, want %d", stats[0].Count, 2*MaxLatencySamples)
+	}
+	if stats[0].P95Ms != 10 {
+		t.Errorf("P95Ms = %v, want 10", stats[0].P95Ms)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+	if got := percentile([]float64{1, 2, 3, 4}, 50); got != 2 {
+		t.Errorf("percentile p50 = %v, want 2", got)
+	}
+	if got := percentile([]float64{1, 2, 3, 4}, 95); got != 4 {
+		t.Errorf("percentile p95 = %v, want 4", got)
+	}
+}
@@ -45,6 +45,7 @@ const (
 	CatReader    Category = "reader"
 	CatCard      Category = "card"
 	CatSystem    Category = "system"
+	CatAudit     Category = "audit"
 )
 
 // Entry represents a single log entry.
@@ -114,6 +115,11 @@ func (l *Logger) Log(level Level, category Category, message string, data map[st
 		return
 	}
 
+	if scrubSensitive.Load() {
+		message = ScrubString(message)
+		data = ScrubData(data)
+	}
+
 	entry := Entry{
 		Timestamp: time.Now(),
 		Level:     level,
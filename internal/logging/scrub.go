@@ -0,0 +1,156 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces sensitive values in scrubbed output.
+const Redacted = "[REDACTED]"
+
+var (
+	scrubCrashReports atomic.Bool // Scrub crash logs and Sentry events
+	scrubSensitive    atomic.Bool // Also scrub the in-memory log
+)
+
+func init() {
+	scrubCrashReports.Store(true)
+}
+
+// SetScrubCrashReports sets whether crash logs and Sentry events are scrubbed
+// of card data before they are written or sent. Enabled by default.
+func SetScrubCrashReports(enabled bool) {
+	scrubCrashReports.Store(enabled)
+}
+
+// SetScrubSensitive sets whether log entries (including APDU and response hex
+// in debug logs) are scrubbed of card data when they are recorded.
+func SetScrubSensitive(enabled bool) {
+	scrubSensitive.Store(enabled)
+}
+
+// ScrubSensitiveEnabled returns whether log entries are scrubbed.
+func ScrubSensitiveEnabled() bool {
+	return scrubSensitive.Load()
+}
+
+// sensitiveKeys are data fields whose values are always redacted: card
+// identifiers, NDEF contents, keys, passwords and raw APDU traffic.
+var sensitiveKeys = map[string]bool{
+	"uid":      true,
+	"uids":     true,
+	"data":     true,
+	"payload":  true,
+	"ndef":     true,
+	"text":     true,
+	"url":      true,
+	"key":      true,
+	"keya":     true,
+	"keyb":     true,
+	"authkey":  true,
+	"password": true,
+	"pwd":      true,
+	"pack":     true,
+	"cmd":      true,
+	"apdu":     true,
+	"response": true,
+	"rsp":      true,
+}
+
+// hexRun matches hex that could be card data: runs of 8 or more hex digits,
+// long enough for a 4-byte UID or password and short enough to leave line
+// numbers and small offsets alone, and 4 or more bytes written as pairs
+// separated by colons or single spaces ("04:A1:B2:C3", "04 A1 B2 C3").
+var hexRun = regexp.MustCompile(`[0-9A-Fa-f]{8,}|[0-9A-Fa-f]{2}(?::[0-9A-Fa-f]{2}){3,}|[0-9A-Fa-f]{2}(?: [0-9A-Fa-f]{2}){3,}`)
+
+// sensitiveLabel matches the end of text labelling the value after it as card
+// data, such as "uid=", "key: " or `"password":"`.
+var sensitiveLabel = regexp.MustCompile(`(?i)\b(?:uids?|keya|keyb|authkey|key|password|pwd|pack|data|payload|ndef|cmd|apdu|response|rsp)["']?\s*[:=]?\s*["']?$`)
+
+// ScrubString redacts hex that could be UIDs, keys, passwords or APDU
+// traffic. Runs that are part of a longer word and Go pointer values ("0x...")
+// are kept, and so are decimal-only runs unless a sensitive field name
+// precedes them or they are an APDU trace line's command or response.
+func ScrubString(s string) string {
+	matches := hexRun.FindAllStringIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if !scrubbableHex(s, start, end) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Redacted)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func scrubbableHex(s string, start, end int) bool {
+	if start > 0 && isWordChar(s[start-1]) {
+		return false // Includes the "x" of a 0x prefix
+	}
+	if end < len(s) && isWordChar(s[end]) {
+		return false
+	}
+	if strings.Trim(s[start:end], "0123456789 :") != "" {
+		return true
+	}
+	return sensitiveContext(s, start)
+}
+
+// sensitiveContext reports whether the value at start is labelled as card
+// data or follows the "> " or "< " of an APDU trace line.
+func sensitiveContext(s string, start int) bool {
+	if start >= 2 && (s[start-2] == '>' || s[start-2] == '<') && s[start-1] == ' ' && (start == 2 || s[start-3] == '\n') {
+		return true
+	}
+	return sensitiveLabel.MatchString(s[max(0, start-32):start])
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ScrubData returns a copy of data with sensitive fields redacted and hex runs
+// scrubbed from the remaining string values.
+func ScrubData(data map[string]any) map[string]any {
+	if data == nil {
+		return nil
+	}
+	scrubbed := make(map[string]any, len(data))
+	for k, v := range data {
+		scrubbed[k] = scrubValue(k, v)
+	}
+	return scrubbed
+}
+
+func scrubValue(key string, v any) any {
+	if sensitiveKeys[strings.ToLower(key)] {
+		return Redacted
+	}
+	switch val := v.(type) {
+	case string:
+		return ScrubString(val)
+	case error:
+		return ScrubString(val.Error())
+	case map[string]any:
+		return ScrubData(val)
+	}
+	return v
+}
+
+// scrubCrash applies ScrubString when crash report scrubbing is enabled.
+func scrubCrash(s string) string {
+	if !scrubCrashReports.Load() {
+		return s
+	}
+	return ScrubString(s)
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+)
+
+func TestScrubString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"uid", "card 04A1B2C3D4E5F6 detected", "card [REDACTED] detected"},
+		{"apdu", "cmd=FFB0000410 rsp=0103A00C9000", "cmd=[REDACTED] rsp=[REDACTED]"},
+		{"short hex kept", "status 9000", "status 9000"},
+		{"pointer kept", "main.go:42 +0x1a5 (0xc000123456)", "main.go:42 +0x1a5 (0xc000123456)"},
+		{"decimal kept", "goroutine 123456789 [running]", "goroutine 123456789 [running]"},
+		{"word kept", "DeadbeefCafeBabeHandler", "DeadbeefCafeBabeHandler"},
+		{"colon-separated", "uid 04:A1:B2:C3 read", "uid [REDACTED] read"},
+		{"space-separated", "page 04 A1 B2 C3", "page [REDACTED]"},
+		{"decimal uid", "card uid=04123456789012 detected", "card uid=[REDACTED] detected"},
+		{"decimal key", `{"keyA":"000000000000"}`, `{"keyA":"[REDACTED]"}`},
+		{"decimal password", "password: 12345678", "password: [REDACTED]"},
+		{"decimal separated uid", "UID 04 12 34 56", "UID [REDACTED]"},
+		{"decimal apdu trace", "> FF00000005\n< 12345678909000 (3ms)", "> [REDACTED]\n< [REDACTED] (3ms)"},
+		{"separated decimal kept", "at 12:30:45 took 10 20 30 ms", "at 12:30:45 took 10 20 30 ms"},
+		{"date kept", "2026-10-16 12:30:45", "2026-10-16 12:30:45"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScrubString(tt.in); got != tt.want {
+				t.Errorf("ScrubString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubData(t *testing.T) {
+	data := map[string]any{
+		"uid":    "04a1b2c3",
+		"keyA":   "FFFFFFFFFFFF",
+		"page":   4,
+		"err":    errors.New("auth failed for 04a1b2c3d4"),
+		"reader": "ACS ACR122U",
+		"nested": map[string]any{"password": "12345678"},
+	}
+
+	got := ScrubData(data)
+
+	if got["uid"] != Redacted || got["keyA"] != Redacted {
+		t.Errorf("sensitive keys not redacted: %v", got)
+	}
+	if got["page"] != 4 || got["reader"] != "ACS ACR122U" {
+		t.Errorf("non-sensitive values changed: %v", got)
+	}
+	if got["err"] != "auth failed for [REDACTED]" {
+		t.Errorf("err = %v", got["err"])
+	}
+	if got["nested"].(map[string]any)["password"] != Redacted {
+		t.Errorf("nested password not redacted: %v", got["nested"])
+	}
+	if data["uid"] != "04a1b2c3" {
+		t.Error("ScrubData modified its input")
+	}
+}
+
+func TestLogger_ScrubSensitive(t *testing.T) {
+	SetScrubSensitive(true)
+	t.Cleanup(func() { SetScrubSensitive(false) })
+
+	logger := &Logger{entries: make([]Entry, 10), maxSize: 10}
+	logger.Debug(CatCard, "read 04A1B2C3D4E5F6", map[string]any{"response": "d503009000"})
+
+	entry := logger.GetEntries(1, nil, nil)[0]
+	if strings.Contains(entry.Message, "04A1B2C3D4E5F6") || entry.Data["response"] != Redacted {
+		t.Errorf("entry not scrubbed: %+v", entry)
+	}
+}
+
+func TestScrubEvent(t *testing.T) {
+	event := &sentry.Event{
+		Message:     "panic reading 04A1B2C3D4E5F6",
+		Exception:   []sentry.Exception{{Value: "bad key FFFFFFFFFFFF"}},
+		Extra:       map[string]interface{}{"stack_trace": "card.go:10 uid 04A1B2C3D4E5F6", "uid": "04a1b2c3"},
+		Breadcrumbs: []*sentry.Breadcrumb{{Message: "wrote 0103A00C"}},
+	}
+
+	scrubEvent(event)
+
+	for _, s := range []string{event.Message, event.Exception[0].Value, event.Extra["stack_trace"].(string), event.Breadcrumbs[0].Message} {
+		if !strings.Contains(s, Redacted) {
+			t.Errorf("not scrubbed: %q", s)
+		}
+	}
+	if event.Extra["uid"] != Redacted {
+		t.Errorf("uid extra not redacted: %v", event.Extra["uid"])
+	}
+}
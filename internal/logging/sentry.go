@@ -47,6 +47,12 @@ func InitSentry(version string, crashReportingEnabled bool) bool {
 		AttachStacktrace: true,
 		// Sample rate for performance monitoring (disabled by default)
 		TracesSampleRate: 0.0,
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			if scrubCrashReports.Load() {
+				scrubEvent(event)
+			}
+			return event
+		},
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize Sentry: %v\n", err)
@@ -134,3 +140,17 @@ func CaptureMessage(message string, level sentry.Level, data map[string]interfac
 		sentry.CaptureMessage(message)
 	})
 }
+
+// scrubEvent redacts card data from an event's message, exceptions, extras
+// and breadcrumbs.
+func scrubEvent(event *sentry.Event) {
+	event.Message = ScrubString(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = ScrubString(event.Exception[i].Value)
+	}
+	event.Extra = ScrubData(event.Extra)
+	for _, b := range event.Breadcrumbs {
+		b.Message = ScrubString(b.Message)
+		b.Data = ScrubData(b.Data)
+	}
+}
@@ -0,0 +1,134 @@
+package mqtt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, as the first header byte.
+const (
+	packetConnect    = 0x10
+	packetConnAck    = 0x20
+	packetPublish    = 0x30 // QoS 0, no retain
+	packetPingReq    = 0xC0
+	packetDisconnect = 0xE0
+)
+
+// Connect flags.
+const (
+	connectCleanSession = 0x02
+	connectPassword     = 0x40
+	connectUsername     = 0x80
+)
+
+// connectPacket builds a CONNECT packet with a clean session.
+func connectPacket(opts Options) []byte {
+	body := appendString(nil, "MQTT")
+	body = append(body, 0x04) // Protocol level 4 (3.1.1)
+
+	flags := byte(connectCleanSession)
+	if opts.Username != "" {
+		flags |= connectUsername
+		if opts.Password != "" {
+			flags |= connectPassword
+		}
+	}
+	keepAlive := int(opts.KeepAlive.Seconds())
+	body = append(body, flags, byte(keepAlive>>8), byte(keepAlive))
+
+	body = appendString(body, opts.ClientID)
+	if flags&connectUsername != 0 {
+		body = appendString(body, opts.Username)
+	}
+	if flags&connectPassword != 0 {
+		body = appendString(body, opts.Password)
+	}
+	return packet(packetConnect, body)
+}
+
+// publishPacket builds a QoS 0 PUBLISH packet.
+func publishPacket(topic string, payload []byte) []byte {
+	body := appendString(nil, topic)
+	return packet(packetPublish, append(body, payload...))
+}
+
+// packet prefixes body with the fixed header.
+func packet(header byte, body []byte) []byte {
+	out := append([]byte{header}, encodeRemainingLength(len(body))...)
+	return append(out, body...)
+}
+
+// appendString appends a length-prefixed UTF-8 string.
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// encodeRemainingLength encodes n as a variable byte integer.
+func encodeRemainingLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			return out
+		}
+	}
+}
+
+func writePacket(w io.Writer, p []byte) error {
+	_, err := w.Write(p)
+	return err
+}
+
+// readPacket reads one packet and returns its type (the high nibble of the
+// first header byte) and body.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xF0, body, nil
+}
+
+// connAckReason describes a CONNACK return code.
+func connAckReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return "unknown reason"
+}
@@ -0,0 +1,299 @@
+// Package mqtt implements a minimal MQTT 3.1.1 publisher: QoS 0 messages to a
+// single broker, with lazy connection and automatic reconnects.
+package mqtt
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SimplyPrint/nfc-agent/internal/logging"
+)
+
+// Connection defaults.
+const (
+	DefaultKeepAlive  = 30 * time.Second
+	dialTimeout       = 10 * time.Second
+	writeTimeout      = 10 * time.Second // A broker that stops reading fails the write instead of blocking it
+	queueSize         = 64
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = time.Minute
+)
+
+// Options configures a Publisher.
+type Options struct {
+	URL       string        // mqtt://host:1883, or mqtts:// for TLS; credentials may be in the URL
+	ClientID  string        // Empty lets the broker assign one
+	Username  string        // Overrides the URL's user
+	Password  string        // Overrides the URL's password
+	KeepAlive time.Duration // 0 uses DefaultKeepAlive
+}
+
+// maxClientIDLen is the longest client ID every MQTT 3.1.1 broker must accept.
+const maxClientIDLen = 23
+
+// DefaultClientID returns a client ID unique to this host: "nfc-agent-" and
+// the host name's letters and digits, or random hex if the host name is
+// unavailable, so agents on different hosts don't disconnect each other.
+func DefaultClientID() string {
+	host, _ := os.Hostname()
+	return clientIDForHost(host)
+}
+
+func clientIDForHost(host string) string {
+	const prefix = "nfc-agent-"
+	var b strings.Builder
+	for _, r := range host {
+		if b.Len() == maxClientIDLen-len(prefix) {
+			break
+		}
+		if r < 0x80 && (r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		id := make([]byte, 6)
+		rand.Read(id)
+		return prefix + hex.EncodeToString(id)
+	}
+	return prefix + b.String()
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// Publisher sends QoS 0 messages to a broker. It connects on the first
+// message and reconnects with backoff after the connection drops. Publish
+// never blocks: messages are dropped while the queue is full.
+type Publisher struct {
+	opts    Options
+	network string // "tcp" or "tls"
+	addr    string
+	queue   chan message
+	done    chan struct{}
+	once    sync.Once
+
+	dial         func(network, addr string) (net.Conn, error) // Overridden in tests
+	writeTimeout time.Duration                                // Overridden in tests
+}
+
+// NewPublisher validates opts and starts the publisher.
+func NewPublisher(opts Options) (*Publisher, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT URL: %w", err)
+	}
+
+	p := &Publisher{
+		opts:  opts,
+		queue: make(chan message, queueSize),
+		done:  make(chan struct{}),
+	}
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		p.network, p.addr = "tcp", hostPort(u, "1883")
+	case "mqtts", "ssl", "tls":
+		p.network, p.addr = "tls", hostPort(u, "8883")
+	default:
+		return nil, fmt.Errorf("unsupported MQTT URL scheme %q (use mqtt:// or mqtts://)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("MQTT URL has no host")
+	}
+	if u.User != nil && p.opts.Username == "" {
+		p.opts.Username = u.User.Username()
+		if pw, ok := u.User.Password(); ok && p.opts.Password == "" {
+			p.opts.Password = pw
+		}
+	}
+	if p.opts.KeepAlive <= 0 {
+		p.opts.KeepAlive = DefaultKeepAlive
+	}
+	p.dial = p.dialBroker
+	p.writeTimeout = writeTimeout
+
+	go p.run()
+	return p, nil
+}
+
+// hostPort returns the URL's host with the default port added if missing.
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// Publish queues a message for topic. It reports false if the queue is full
+// or the publisher is closed, in which case the message is dropped.
+func (p *Publisher) Publish(topic string, payload []byte) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+	select {
+	case p.queue <- message{topic: topic, payload: payload}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close disconnects from the broker and stops the publisher.
+func (p *Publisher) Close() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// run connects when the first message arrives and keeps publishing until
+// Close, reconnecting with exponential backoff whenever the connection fails.
+func (p *Publisher) run() {
+	defer logging.RecoverAndLog("MQTT publisher", false)
+
+	var pending *message // Message that failed to send and is retried after reconnecting
+	delay := reconnectMinDelay
+	for {
+		if pending == nil {
+			select {
+			case <-p.done:
+				return
+			case m := <-p.queue:
+				pending = &m
+			}
+		}
+
+		conn, err := p.connect()
+		if err != nil {
+			logging.Warn(logging.CatSystem, "MQTT connection failed", map[string]any{
+				"broker":  p.addr,
+				"error":   err.Error(),
+				"retryMs": delay.Milliseconds(),
+			})
+			select {
+			case <-p.done:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, reconnectMaxDelay)
+			continue
+		}
+		delay = reconnectMinDelay
+		logging.Info(logging.CatSystem, "MQTT connected", map[string]any{
+			"broker": p.addr,
+		})
+
+		pending = p.serve(conn, pending)
+		conn.Close()
+	}
+}
+
+// serve publishes queued messages and keeps the connection alive until it
+// fails or the publisher is closed. It returns the message that was being
+// sent when the connection failed, if any.
+func (p *Publisher) serve(conn net.Conn, pending *message) *message {
+	// Drain incoming packets (PINGRESP) so a dropped connection is noticed
+	broken := make(chan struct{})
+	go func() {
+		defer close(broken)
+		r := bufio.NewReader(conn)
+		for {
+			conn.SetReadDeadline(time.Now().Add(p.opts.KeepAlive * 3 / 2))
+			if _, _, err := readPacket(r); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(p.opts.KeepAlive / 2)
+	defer ping.Stop()
+
+	for {
+		if pending != nil {
+			if err := p.write(conn, publishPacket(pending.topic, pending.payload)); err != nil {
+				p.logDrop(err)
+				return pending
+			}
+			pending = nil
+		}
+
+		select {
+		case <-p.done:
+			p.write(conn, []byte{packetDisconnect, 0x00})
+			return nil
+		case <-broken:
+			p.logDrop(io.ErrUnexpectedEOF)
+			return nil
+		case <-ping.C:
+			if err := p.write(conn, []byte{packetPingReq, 0x00}); err != nil {
+				p.logDrop(err)
+				return nil
+			}
+		case m := <-p.queue:
+			pending = &m
+		}
+	}
+}
+
+// write sends a packet on an established connection, failing it if the
+// broker doesn't take it within the write timeout.
+func (p *Publisher) write(conn net.Conn, packet []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(p.writeTimeout))
+	return writePacket(conn, packet)
+}
+
+func (p *Publisher) logDrop(err error) {
+	logging.Warn(logging.CatSystem, "MQTT connection lost, reconnecting", map[string]any{
+		"broker": p.addr,
+		"error":  err.Error(),
+	})
+}
+
+// connect dials the broker and completes the CONNECT/CONNACK handshake.
+func (p *Publisher) connect() (net.Conn, error) {
+	conn, err := p.dial(p.network, p.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := writePacket(conn, connectPacket(p.opts)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	typ, body, err := readPacket(bufio.NewReader(conn))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no CONNACK: %w", err)
+	}
+	if typ != packetConnAck || len(body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected packet 0x%02X instead of CONNACK", typ)
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused: %s", connAckReason(body[1]))
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (p *Publisher) dialBroker(network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if network == "tls" {
+		host, _, _ := net.SplitHostPort(addr)
+		return tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	}
+	return dialer.Dial(network, addr)
+}
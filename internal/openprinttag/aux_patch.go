@@ -0,0 +1,193 @@
+package openprinttag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMainSectionImmutable is returned when an aux patch addresses the main
+// (or meta) section, which must not be changed by updates.
+var ErrMainSectionImmutable = errors.New("patch would modify the immutable main section")
+
+// AuxPatchOp is a JSON Patch (RFC 6902) operation on the aux section. Paths
+// name aux fields, e.g. "/consumedWeight" (an "/aux" prefix is accepted).
+// Besides "add", "replace", "remove" and "test", the "increment" operation
+// adds its value to consumedWeight, so a printer can report usage as a delta.
+type AuxPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ValidateAuxPatch checks that every operation is supported and addresses an
+// aux field, without needing the current tag.
+func ValidateAuxPatch(ops []AuxPatchOp) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("empty patch")
+	}
+	for i, op := range ops {
+		field, err := auxPatchField(op.Path)
+		if err == nil {
+			switch op.Op {
+			case "add", "replace", "test", "increment":
+				if len(op.Value) == 0 {
+					err = fmt.Errorf("missing value")
+				}
+			case "remove":
+			default:
+				err = fmt.Errorf("unsupported operation")
+			}
+		}
+		if err == nil && op.Op == "increment" && field != "consumedWeight" {
+			err = fmt.Errorf("increment is only supported for consumedWeight")
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+// ApplyAuxPatch applies ops to aux and returns the result. The patch is
+// applied as a whole: if any operation fails, aux is returned unchanged.
+func ApplyAuxPatch(aux AuxSection, ops []AuxPatchOp) (AuxSection, error) {
+	if err := ValidateAuxPatch(ops); err != nil {
+		return aux, err
+	}
+	patched := aux
+	for i, op := range ops {
+		if err := patched.applyOp(op); err != nil {
+			return aux, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return patched, nil
+}
+
+func (a *AuxSection) applyOp(op AuxPatchOp) error {
+	field, err := auxPatchField(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add", "replace":
+		return a.setField(field, op.Value)
+	case "remove":
+		return a.setField(field, nil)
+	case "test":
+		current, _ := json.Marshal(a.field(field))
+		var want interface{}
+		if err := json.Unmarshal(op.Value, &want); err != nil {
+			return fmt.Errorf("invalid value: %w", err)
+		}
+		wantJSON, _ := json.Marshal(want)
+		if !bytes.Equal(current, wantJSON) {
+			return fmt.Errorf("test failed: value is %s", current)
+		}
+		return nil
+	case "increment":
+		var delta float32
+		if err := json.Unmarshal(op.Value, &delta); err != nil {
+			return fmt.Errorf("invalid value: %w", err)
+		}
+		if a.ConsumedWeight+delta < 0 {
+			return fmt.Errorf("consumedWeight would become negative")
+		}
+		a.ConsumedWeight += delta
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation")
+	}
+}
+
+// auxPatchField returns the aux field a patch path points at.
+func auxPatchField(path string) (string, error) {
+	switch {
+	case strings.HasPrefix(path, "/main"), strings.HasPrefix(path, "/meta"):
+		return "", ErrMainSectionImmutable
+	case strings.HasPrefix(path, "/aux/"):
+		path = strings.TrimPrefix(path, "/aux")
+	}
+
+	field := strings.TrimPrefix(path, "/")
+	switch field {
+	case "consumedWeight", "workgroup", "generalPurposeUser", "lastStirTime":
+		return field, nil
+	}
+	return "", fmt.Errorf("unknown aux field")
+}
+
+func (a *AuxSection) field(name string) interface{} {
+	switch name {
+	case "consumedWeight":
+		return a.ConsumedWeight
+	case "workgroup":
+		return a.Workgroup
+	case "generalPurposeUser":
+		return a.GeneralPurposeUser
+	default:
+		return a.LastStirTime
+	}
+}
+
+// setField sets an aux field from a JSON value; a nil value clears it.
+func (a *AuxSection) setField(name string, value json.RawMessage) error {
+	var target interface{}
+	switch name {
+	case "consumedWeight":
+		a.ConsumedWeight, target = 0, &a.ConsumedWeight
+	case "workgroup":
+		a.Workgroup, target = "", &a.Workgroup
+	case "generalPurposeUser":
+		a.GeneralPurposeUser, target = "", &a.GeneralPurposeUser
+	default:
+		a.LastStirTime, target = 0, &a.LastStirTime
+	}
+	if value == nil {
+		return nil
+	}
+	if err := json.Unmarshal(value, target); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	if a.ConsumedWeight < 0 {
+		return fmt.Errorf("consumedWeight must not be negative")
+	}
+	return nil
+}
+
+// ReplaceAux returns payload with its aux section replaced by aux. The meta
+// and main sections are kept byte for byte. A fixed-size aux region (AuxSize
+// in the meta section) is zero-padded to its size. Payloads without a meta
+// section have no aux region and are refused.
+func ReplaceAux(payload []byte, aux AuxSection) ([]byte, error) {
+	opt, err := Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	offset := int(opt.Meta.AuxOffset)
+	if opt.SpecVersion == SpecVersionDraft || offset == 0 || offset > len(payload) {
+		return nil, fmt.Errorf("tag has no aux region; rewrite it to migrate to the current layout")
+	}
+
+	auxBytes, err := encodeIndefiniteMap(aux.toKeyValuePairs())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode auxiliary section: %w", err)
+	}
+	size := len(auxBytes)
+	if opt.Meta.AuxSize > 0 {
+		if len(auxBytes) > int(opt.Meta.AuxSize) {
+			return nil, fmt.Errorf("auxiliary section exceeds its %d byte region (got %d)", opt.Meta.AuxSize, len(auxBytes))
+		}
+		size = int(opt.Meta.AuxSize)
+	} else if size > MaxSectionSize {
+		return nil, fmt.Errorf("auxiliary section exceeds %d bytes (got %d)", MaxSectionSize, size)
+	}
+
+	result := make([]byte, offset+size)
+	copy(result, payload[:offset])
+	copy(result[offset:], auxBytes)
+	return result, nil
+}
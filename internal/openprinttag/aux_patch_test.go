@@ -0,0 +1,99 @@
+package openprinttag
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestApplyAuxPatch(t *testing.T) {
+	var ops []AuxPatchOp
+	if err := json.Unmarshal([]byte(`[
+		{"op": "test", "path": "/consumedWeight", "value": 100},
+		{"op": "increment", "path": "/consumedWeight", "value": 12.5},
+		{"op": "replace", "path": "/aux/workgroup", "value": "farm-2"},
+		{"op": "remove", "path": "/generalPurposeUser"}
+	]`), &ops); err != nil {
+		t.Fatal(err)
+	}
+
+	aux, err := ApplyAuxPatch(AuxSection{ConsumedWeight: 100, GeneralPurposeUser: "x"}, ops)
+	if err != nil {
+		t.Fatalf("ApplyAuxPatch failed: %v", err)
+	}
+	if aux.ConsumedWeight != 112.5 || aux.Workgroup != "farm-2" || aux.GeneralPurposeUser != "" {
+		t.Errorf("patched aux = %+v", aux)
+	}
+}
+
+func TestApplyAuxPatch_Rejected(t *testing.T) {
+	original := AuxSection{ConsumedWeight: 10, Workgroup: "a"}
+	tests := []struct {
+		name string
+		ops  string
+	}{
+		{"main section", `[{"op": "replace", "path": "/main/materialName", "value": "PETG"}]`},
+		{"unknown field", `[{"op": "replace", "path": "/color", "value": "red"}]`},
+		{"unsupported op", `[{"op": "move", "from": "/workgroup", "path": "/generalPurposeUser"}]`},
+		{"wrong type", `[{"op": "replace", "path": "/consumedWeight", "value": "heavy"}]`},
+		{"negative weight", `[{"op": "increment", "path": "/consumedWeight", "value": -11}]`},
+		{"increment other field", `[{"op": "increment", "path": "/lastStirTime", "value": 1}]`},
+		{"failed test", `[{"op": "replace", "path": "/workgroup", "value": "b"}, {"op": "test", "path": "/consumedWeight", "value": 5}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []AuxPatchOp
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+			aux, err := ApplyAuxPatch(original, ops)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if aux != original {
+				t.Errorf("a rejected patch must not change aux, got %+v", aux)
+			}
+		})
+	}
+
+	ops := []AuxPatchOp{{Op: "replace", Path: "/meta/auxOffset", Value: json.RawMessage("0")}}
+	if _, err := ApplyAuxPatch(original, ops); !errors.Is(err, ErrMainSectionImmutable) {
+		t.Errorf("expected ErrMainSectionImmutable, got %v", err)
+	}
+}
+
+func TestReplaceAux(t *testing.T) {
+	opt := &OpenPrintTag{
+		Main: MainSection{MaterialName: "PLA", BrandName: "Brand", NominalNettoFullWeight: 1000},
+		Aux:  AuxSection{ConsumedWeight: 50},
+	}
+	payload, err := opt.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := ReplaceAux(payload, AuxSection{ConsumedWeight: 75, Workgroup: "lab"})
+	if err != nil {
+		t.Fatalf("ReplaceAux failed: %v", err)
+	}
+
+	decoded, err := Decode(updated)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Aux.ConsumedWeight != 75 || decoded.Aux.Workgroup != "lab" {
+		t.Errorf("aux = %+v", decoded.Aux)
+	}
+	offset := int(decoded.Meta.AuxOffset)
+	if !bytes.Equal(updated[:offset], payload[:offset]) {
+		t.Error("meta and main sections must be kept byte for byte")
+	}
+
+	// A bare main section (draft layout) has no aux region
+	draft, _ := encodeIndefiniteMap(opt.Main.toKeyValuePairs())
+	if _, err := ReplaceAux(draft, AuxSection{}); err == nil {
+		t.Error("expected an error for a tag without an aux region")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/google/uuid"
@@ -16,6 +17,12 @@ var (
 	encMode cbor.EncMode
 )
 
+// Marshal encodes v with the package's canonical encoding (map keys sorted
+// shortest first), for other CBOR stored on tags.
+func Marshal(v interface{}) ([]byte, error) {
+	return encMode.Marshal(v)
+}
+
 func init() {
 	var err error
 
@@ -128,6 +135,8 @@ func Decode(payload []byte) (*OpenPrintTag, error) {
 		if err := mainDecoder.Decode(&opt.Main); err != nil {
 			return nil, fmt.Errorf("failed to decode main section: %w", err)
 		}
+		opt.decodeRawMain(payload)
+		opt.detectSpecVersion(false)
 		return opt, nil
 	}
 
@@ -188,6 +197,7 @@ func Decode(payload []byte) (*OpenPrintTag, error) {
 		if err := mainDecoder.Decode(&opt.Main); err != nil {
 			return nil, fmt.Errorf("failed to decode main section: %w", err)
 		}
+		opt.decodeRawMain(mainData)
 	}
 
 	// Decode Auxiliary section
@@ -199,6 +209,8 @@ func Decode(payload []byte) (*OpenPrintTag, error) {
 		_ = auxDecoder.Decode(&opt.Aux)
 	}
 
+	opt.detectSpecVersion(true)
+
 	return opt, nil
 }
 
@@ -493,6 +505,7 @@ func (i *Input) ToOpenPrintTag() (*OpenPrintTag, error) {
 	opt.Main.MaxPrintTemp = i.MaxPrintTemp
 	opt.Main.ManufacturedDate = i.ManufacturedDate
 	opt.Main.ExpirationDate = i.ExpirationDate
+	opt.Main.GTIN = i.GTIN
 
 	// Parse and set UUIDs
 	if i.InstanceUUID != "" {
@@ -507,14 +520,6 @@ func (i *Input) ToOpenPrintTag() (*OpenPrintTag, error) {
 		opt.Main.InstanceUUID = newUUID[:]
 	}
 
-	if i.PackageUUID != "" {
-		b, err := parseUUID(i.PackageUUID)
-		if err != nil {
-			return nil, fmt.Errorf("invalid packageUuid: %w", err)
-		}
-		opt.Main.PackageUUID = b
-	}
-
 	if i.MaterialUUID != "" {
 		b, err := parseUUID(i.MaterialUUID)
 		if err != nil {
@@ -537,6 +542,17 @@ func (i *Input) ToOpenPrintTag() (*OpenPrintTag, error) {
 		opt.Main.BrandUUID = GenerateBrandUUID(i.BrandName)
 	}
 
+	if i.PackageUUID != "" {
+		b, err := parseUUID(i.PackageUUID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid packageUuid: %w", err)
+		}
+		opt.Main.PackageUUID = b
+	} else if i.GTIN != 0 {
+		// Derive package UUID from brand UUID + GTIN
+		opt.Main.PackageUUID = GeneratePackageUUID(opt.Main.BrandUUID, strconv.FormatUint(i.GTIN, 10))
+	}
+
 	// Parse color
 	if i.PrimaryColor != "" {
 		c, err := parseHexColor(i.PrimaryColor)
@@ -596,7 +612,7 @@ func GenerateMaterialUUID(brandName, materialName string) []byte {
 // Per OpenPrintTag spec: uuid5(packageNamespace, brand_uuid + gtin)
 func GeneratePackageUUID(brandUUID []byte, gtin string) []byte {
 	// Concatenate brand_uuid (binary) + gtin (as string)
-	data := append(brandUUID, []byte(gtin)...)
+	data := append(append([]byte{}, brandUUID...), gtin...)
 	u := uuid.NewSHA1(packageNamespace, data)
 	return u[:]
 }
@@ -5,6 +5,9 @@ package openprinttag
 import (
 	"encoding/hex"
 	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 // MIME type for OpenPrintTag NDEF records (per OpenPrintTag spec)
@@ -157,6 +160,11 @@ type OpenPrintTag struct {
 	Meta MetaSection
 	Main MainSection
 	Aux  AuxSection
+
+	SpecVersion SpecVersion // Layout the tag conforms to, set by Decode
+	UnknownKeys []int       // Main section keys not in any known spec version
+
+	rawMain map[int]cbor.RawMessage // Main section as read, for Migrate
 }
 
 // Response is the JSON-friendly API response structure
@@ -197,9 +205,17 @@ type Response struct {
 	// Dates
 	ManufacturedDate uint32 `json:"manufacturedDate,omitempty"`
 	ExpirationDate   uint32 `json:"expirationDate,omitempty"`
+	Expired          bool   `json:"expired,omitempty"` // ExpirationDate has passed
 
 	// Auxiliary data
 	Workgroup string `json:"workgroup,omitempty"`
+
+	// Protection
+	WriteProtected bool `json:"writeProtected,omitempty"` // Main section must not be overwritten
+
+	// Spec version detected from the layout and keys present
+	SpecVersion string `json:"specVersion,omitempty"`
+	UnknownKeys []int  `json:"unknownKeys,omitempty"` // Main section keys from a newer spec
 }
 
 // Input is the JSON structure for API write requests
@@ -214,6 +230,7 @@ type Input struct {
 	// Optional fields
 	InstanceUUID     string  `json:"instanceUuid,omitempty"`
 	PackageUUID      string  `json:"packageUuid,omitempty"`
+	GTIN             uint64  `json:"gtin,omitempty"` // Derives packageUuid when that is not given
 	MaterialUUID     string  `json:"materialUuid,omitempty"`
 	BrandUUID        string  `json:"brandUuid,omitempty"`
 	FilamentDiameter float32 `json:"filamentDiameter,omitempty"`
@@ -247,7 +264,11 @@ func (o *OpenPrintTag) ToResponse() *Response {
 		MaxBedTemp:       o.Main.MaxBedTemp,
 		ManufacturedDate: o.Main.ManufacturedDate,
 		ExpirationDate:   o.Main.ExpirationDate,
+		Expired:          o.Main.ExpirationDate != 0 && time.Now().Unix() >= int64(o.Main.ExpirationDate),
 		Workgroup:        o.Aux.Workgroup,
+		WriteProtected:   o.IsWriteProtected(),
+		SpecVersion:      string(o.SpecVersion),
+		UnknownKeys:      o.UnknownKeys,
 	}
 
 	// Calculate remaining weight
@@ -280,6 +301,11 @@ func (o *OpenPrintTag) ToResponse() *Response {
 	return resp
 }
 
+// IsWriteProtected reports whether the main section is marked write-protected (key 13).
+func (o *OpenPrintTag) IsWriteProtected() bool {
+	return o.Main.WriteProtection != 0
+}
+
 // materialClassToString converts MaterialClass enum to string
 func materialClassToString(mc MaterialClass) string {
 	switch mc {
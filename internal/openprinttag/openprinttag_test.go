@@ -1,8 +1,10 @@
 package openprinttag
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestEncodeDecodeRoundtrip(t *testing.T) {
@@ -138,6 +140,44 @@ func TestInputToOpenPrintTag(t *testing.T) {
 	}
 }
 
+func TestInputToOpenPrintTag_PackageUUIDFromGTIN(t *testing.T) {
+	input := &Input{MaterialName: "PETG", BrandName: "TestBrand", GTIN: 4006381333931}
+
+	opt, err := input.ToOpenPrintTag()
+	if err != nil {
+		t.Fatalf("ToOpenPrintTag failed: %v", err)
+	}
+	if opt.Main.GTIN != input.GTIN {
+		t.Errorf("GTIN mismatch: got %d, want %d", opt.Main.GTIN, input.GTIN)
+	}
+	want := GeneratePackageUUID(GenerateBrandUUID("TestBrand"), "4006381333931")
+	if !bytes.Equal(opt.Main.PackageUUID, want) {
+		t.Errorf("PackageUUID = %x, want %x", opt.Main.PackageUUID, want)
+	}
+
+	// Deterministic across writes
+	again, _ := input.ToOpenPrintTag()
+	if !bytes.Equal(again.Main.PackageUUID, opt.Main.PackageUUID) {
+		t.Error("PackageUUID should be the same for the same brand and GTIN")
+	}
+
+	// An explicit package UUID wins
+	input.PackageUUID = "11111111-2222-3333-4444-555555555555"
+	opt, err = input.ToOpenPrintTag()
+	if err != nil {
+		t.Fatalf("ToOpenPrintTag failed: %v", err)
+	}
+	if formatUUID(opt.Main.PackageUUID) != input.PackageUUID {
+		t.Errorf("PackageUUID = %s, want %s", formatUUID(opt.Main.PackageUUID), input.PackageUUID)
+	}
+
+	// No GTIN, no package UUID
+	opt, _ = (&Input{MaterialName: "PETG", BrandName: "TestBrand"}).ToOpenPrintTag()
+	if opt.Main.PackageUUID != nil {
+		t.Errorf("PackageUUID should be unset without a GTIN, got %x", opt.Main.PackageUUID)
+	}
+}
+
 func TestInputEncode(t *testing.T) {
 	input := &Input{
 		MaterialName:  "ABS",
@@ -215,6 +255,76 @@ func TestToResponse(t *testing.T) {
 	if resp.PrimaryColor != "#12345678" {
 		t.Errorf("PrimaryColor mismatch: got %q, want %q", resp.PrimaryColor, "#12345678")
 	}
+	if resp.WriteProtected {
+		t.Error("WriteProtected should be false when key 13 is absent")
+	}
+
+	opt.Main.WriteProtection = 1
+	if !opt.ToResponse().WriteProtected {
+		t.Error("WriteProtected should be true when key 13 is set")
+	}
+
+	if resp.Expired {
+		t.Error("Expired should be false without an expiration date")
+	}
+	opt.Main.ExpirationDate = uint32(time.Now().Add(-time.Hour).Unix())
+	if !opt.ToResponse().Expired {
+		t.Error("Expired should be true once the expiration date has passed")
+	}
+	opt.Main.ExpirationDate = uint32(time.Now().Add(time.Hour).Unix())
+	if opt.ToResponse().Expired {
+		t.Error("Expired should be false before the expiration date")
+	}
+}
+
+func TestToSections(t *testing.T) {
+	opt := &OpenPrintTag{
+		Meta: MetaSection{MainOffset: 4},
+		Main: MainSection{
+			MaterialName:  "PETG",
+			MaterialClass: MaterialClassFFF,
+			MaterialType:  MaterialTypePETG,
+			GTIN:          4006381333931,
+			PrimaryColor:  []byte{0xFF, 0x00, 0x00},
+			Tags:          []uint8{1, 7},
+		},
+		Aux: AuxSection{Workgroup: "lab"},
+	}
+	opt.Main.BrandUUID = GenerateBrandUUID("Brand")
+
+	sections := opt.ToSections()
+
+	checks := []struct {
+		section map[string]interface{}
+		key     string
+		want    interface{}
+	}{
+		{sections.Meta, "mainOffset", uint16(4)},
+		{sections.Meta, "auxSize", uint16(0)},
+		{sections.Main, "materialName", "PETG"},
+		{sections.Main, "materialType", "PETG"},
+		{sections.Main, "gtin", uint64(4006381333931)},
+		{sections.Main, "primaryColor", "#FF0000"},
+		{sections.Main, "brandUUID", formatUUID(opt.Main.BrandUUID)},
+		{sections.Main, "minPrintTemp", uint16(0)},
+		{sections.Aux, "workgroup", "lab"},
+		{sections.Aux, "consumedWeight", float32(0)},
+	}
+	for _, c := range checks {
+		got, ok := c.section[c.key]
+		if !ok {
+			t.Errorf("missing key %q", c.key)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: got %v (%T), want %v (%T)", c.key, got, got, c.want, c.want)
+		}
+	}
+
+	tags, ok := sections.Main["tags"].([]int)
+	if !ok || len(tags) != 2 || tags[1] != 7 {
+		t.Errorf("unexpected tags: %v", sections.Main["tags"])
+	}
 }
 
 func TestDecodeEmptyPayload(t *testing.T) {
@@ -0,0 +1,82 @@
+package openprinttag
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Sections is a per-section view of a decoded tag. Every known key is present,
+// including zero values, so it shows exactly what is (and isn't) on the tag.
+type Sections struct {
+	SpecVersion string                 `json:"specVersion,omitempty"`
+	Meta        map[string]interface{} `json:"meta"`
+	Main        map[string]interface{} `json:"main"`
+	Aux         map[string]interface{} `json:"aux"`
+}
+
+// ToSections builds the per-section view directly from the decoded structs.
+func (o *OpenPrintTag) ToSections() *Sections {
+	return &Sections{
+		SpecVersion: string(o.SpecVersion),
+		Meta:        sectionToMap(reflect.ValueOf(o.Meta)),
+		Main:        sectionToMap(reflect.ValueOf(o.Main)),
+		Aux:         sectionToMap(reflect.ValueOf(o.Aux)),
+	}
+}
+
+// sectionToMap converts a section struct to a map keyed by the lowerCamel field
+// name. UUIDs, colors and enums are rendered the same way as in Response.
+func sectionToMap(v reflect.Value) map[string]interface{} {
+	t := v.Type()
+	out := make(map[string]interface{}, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("cbor") == "" {
+			continue
+		}
+		name := lowerFirst(field.Name)
+		value := v.Field(i).Interface()
+
+		switch val := value.(type) {
+		case MaterialClass:
+			out[name] = materialClassToString(val)
+		case MaterialType:
+			out[name] = materialTypeToString(val)
+		case []byte:
+			switch {
+			case strings.HasSuffix(field.Name, "UUID") && len(val) == 16:
+				out[name] = formatUUID(val)
+			case strings.Contains(field.Name, "Color"):
+				out[name] = colorToHex(val)
+			case field.Name == "Tags" || field.Name == "Certifications":
+				ints := make([]int, len(val))
+				for j, b := range val {
+					ints[j] = int(b)
+				}
+				out[name] = ints
+			default:
+				out[name] = hex.EncodeToString(val)
+			}
+		default:
+			out[name] = value
+		}
+	}
+
+	return out
+}
+
+// lowerFirst lowercases the leading run of capitals, e.g. "GTIN" -> "gtin",
+// "InstanceUUID" -> "instanceUUID".
+func lowerFirst(s string) string {
+	r := []rune(s)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
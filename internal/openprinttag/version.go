@@ -0,0 +1,173 @@
+package openprinttag
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// SpecVersion identifies the OpenPrintTag layout a tag conforms to.
+type SpecVersion string
+
+const (
+	// SpecVersionDraft is the early layout: a bare main section without the
+	// meta and auxiliary regions.
+	SpecVersionDraft SpecVersion = "draft"
+	// SpecVersion1 is the sectioned meta + main + aux layout with the main
+	// section keys of MainSection.
+	SpecVersion1 SpecVersion = "1.0"
+
+	// LatestSpecVersion is the layout Encode writes.
+	LatestSpecVersion = SpecVersion1
+)
+
+// specVersions lists the known versions, oldest first.
+var specVersions = []SpecVersion{SpecVersionDraft, SpecVersion1}
+
+// keyMigration records a main section key that a spec version renamed.
+type keyMigration struct {
+	since SpecVersion // First version using the new key
+	from  int         // Deprecated key
+	to    int         // Replacement key
+}
+
+// mainKeyMigrations lists main section key renames, oldest first. Add an entry
+// here whenever a spec revision moves a key; detection and Migrate pick it up.
+var mainKeyMigrations []keyMigration
+
+// knownMainKeys are the main section keys of the latest spec version.
+var knownMainKeys = func() map[int]bool {
+	keys := make(map[int]bool)
+	t := reflect.TypeOf(MainSection{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("cbor")
+		if key, err := strconv.Atoi(strings.Split(tag, ",")[0]); err == nil {
+			keys[key] = true
+		}
+	}
+	return keys
+}()
+
+func specVersionIndex(v SpecVersion) int {
+	for i, known := range specVersions {
+		if known == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// decodeRawMain keeps the main section's raw key/value pairs so keys the
+// struct doesn't know about survive for version detection and migration.
+func (o *OpenPrintTag) decodeRawMain(data []byte) {
+	var raw map[int]cbor.RawMessage
+	if err := decMode.NewDecoder(bytes.NewReader(data)).Decode(&raw); err == nil {
+		o.rawMain = raw
+	}
+}
+
+// requiredMainKeys are the main section keys every tag has: material class
+// and material type, which Encode writes even when 0.
+var requiredMainKeys = []int{8, 9}
+
+// HasRequiredFields reports whether the decoded main section has the keys
+// every OpenPrintTag has, telling a tag apart from other CBOR maps that
+// decode without error, such as an empty one.
+func (o *OpenPrintTag) HasRequiredFields() bool {
+	for _, key := range requiredMainKeys {
+		if _, ok := o.rawMain[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// detectSpecVersion records which spec version the decoded tag appears to
+// conform to, based on its layout and the main section keys present.
+func (o *OpenPrintTag) detectSpecVersion(hasMeta bool) {
+	version := LatestSpecVersion
+	if !hasMeta {
+		version = SpecVersionDraft
+	}
+
+	o.UnknownKeys = nil
+	for key := range o.rawMain {
+		deprecated := false
+		for _, m := range mainKeyMigrations {
+			if m.from == key {
+				deprecated = true
+				// The tag predates the version that renamed this key
+				if before := specVersionIndex(m.since) - 1; before >= 0 && before < specVersionIndex(version) {
+					version = specVersions[before]
+				}
+			}
+		}
+		if !deprecated && !knownMainKeys[key] {
+			o.UnknownKeys = append(o.UnknownKeys, key)
+		}
+	}
+	sort.Ints(o.UnknownKeys)
+
+	o.SpecVersion = version
+}
+
+// Migrate returns a copy of opt remapped to targetVersion: deprecated main
+// section keys are moved to their replacements. Encoding the result writes the
+// current sectioned layout. Downgrading is not supported.
+func Migrate(opt *OpenPrintTag, targetVersion SpecVersion) (*OpenPrintTag, error) {
+	target := specVersionIndex(targetVersion)
+	if target < 0 {
+		return nil, fmt.Errorf("unknown spec version: %s", targetVersion)
+	}
+	current := specVersionIndex(opt.SpecVersion)
+	if current < 0 {
+		current = 0 // Not decoded from a tag; treat as oldest so all renames apply
+	}
+	if target < current {
+		return nil, fmt.Errorf("cannot migrate from spec version %s down to %s", opt.SpecVersion, targetVersion)
+	}
+
+	migrated := &OpenPrintTag{
+		Main:        opt.Main,
+		Aux:         opt.Aux,
+		SpecVersion: targetVersion,
+	}
+	if opt.rawMain == nil {
+		return migrated, nil
+	}
+
+	raw := make(map[int]cbor.RawMessage, len(opt.rawMain))
+	for k, v := range opt.rawMain {
+		raw[k] = v
+	}
+	for _, m := range mainKeyMigrations {
+		since := specVersionIndex(m.since)
+		if since <= current || since > target {
+			continue
+		}
+		if value, ok := raw[m.from]; ok {
+			if _, exists := raw[m.to]; !exists {
+				raw[m.to] = value
+			}
+			delete(raw, m.from)
+		}
+	}
+
+	data, err := encMode.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode main section: %w", err)
+	}
+	if err := decMode.Unmarshal(data, &migrated.Main); err != nil {
+		return nil, fmt.Errorf("failed to decode migrated main section: %w", err)
+	}
+	migrated.rawMain = raw
+	migrated.detectSpecVersion(true)
+	migrated.SpecVersion = targetVersion
+
+	return migrated, nil
+}
@@ -0,0 +1,90 @@
+package openprinttag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecode_SpecVersion(t *testing.T) {
+	opt := &OpenPrintTag{Main: MainSection{MaterialName: "PLA", BrandName: "Brand"}}
+	sectioned, err := opt.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(sectioned)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.SpecVersion != SpecVersion1 || decoded.UnknownKeys != nil {
+		t.Errorf("sectioned tag: version %q unknown %v", decoded.SpecVersion, decoded.UnknownKeys)
+	}
+	if got := decoded.ToResponse().SpecVersion; got != string(SpecVersion1) {
+		t.Errorf("Response.SpecVersion = %q", got)
+	}
+
+	// A bare main section with an unknown key 99
+	mainOnly, err := encodeIndefiniteMap([]keyValue{{key: 10, value: "PLA"}, {key: 99, value: 1}})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	decoded, err = Decode(mainOnly)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.SpecVersion != SpecVersionDraft {
+		t.Errorf("main-only tag: version %q, want %q", decoded.SpecVersion, SpecVersionDraft)
+	}
+	if !reflect.DeepEqual(decoded.UnknownKeys, []int{99}) {
+		t.Errorf("UnknownKeys = %v, want [99]", decoded.UnknownKeys)
+	}
+}
+
+func TestMigrate_RenamedKey(t *testing.T) {
+	orig := mainKeyMigrations
+	t.Cleanup(func() { mainKeyMigrations = orig })
+	// Pretend 1.0 moved the material name from key 12 to key 10
+	mainKeyMigrations = []keyMigration{{since: SpecVersion1, from: 12, to: 10}}
+
+	payload, err := encodeIndefiniteMap([]keyValue{{key: 11, value: "Brand"}, {key: 12, value: "PETG"}})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	opt, err := Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if opt.SpecVersion != SpecVersionDraft || opt.Main.MaterialName != "" {
+		t.Fatalf("before migration: version %q name %q", opt.SpecVersion, opt.Main.MaterialName)
+	}
+
+	migrated, err := Migrate(opt, SpecVersion1)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if migrated.SpecVersion != SpecVersion1 || migrated.Main.MaterialName != "PETG" || migrated.Main.BrandName != "Brand" {
+		t.Errorf("after migration: %+v", migrated)
+	}
+
+	// The migrated tag re-encodes in the sectioned layout
+	encoded, err := migrated.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	roundTrip, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if roundTrip.SpecVersion != SpecVersion1 || roundTrip.Main.MaterialName != "PETG" {
+		t.Errorf("round trip: version %q name %q", roundTrip.SpecVersion, roundTrip.Main.MaterialName)
+	}
+}
+
+func TestMigrate_Invalid(t *testing.T) {
+	opt := &OpenPrintTag{SpecVersion: SpecVersion1}
+	if _, err := Migrate(opt, "9.9"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+	if _, err := Migrate(opt, SpecVersionDraft); err == nil {
+		t.Error("expected error for downgrade")
+	}
+}
@@ -0,0 +1,263 @@
+package settings
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrKeyProfileNotFound is returned when no key profile has the requested name.
+var ErrKeyProfileNotFound = errors.New("key profile not found")
+
+// KeyProfile is a named set of MIFARE Classic keys and/or an Ultralight EV1
+// (and NTAG21x) password. At least one of them is set.
+type KeyProfile struct {
+	KeyA     []byte `json:"keyA,omitempty"`
+	KeyB     []byte `json:"keyB,omitempty"`
+	Password []byte `json:"password,omitempty"`
+}
+
+// Files next to settings.json holding the key profile secret and API token.
+// Both are created on first use, readable only by the current user.
+const (
+	keySecretFile = "keys.secret"
+	apiTokenFile  = "api-token"
+)
+
+var keyProfileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateKeyProfile checks a profile name and its keys.
+func ValidateKeyProfile(name string, p KeyProfile) error {
+	if !keyProfileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid key profile name %q (1-64 letters, digits, '-' or '_')", name)
+	}
+	if p.KeyA == nil && p.KeyB == nil && p.Password == nil {
+		return errors.New("key profile needs keyA, keyB or password")
+	}
+	if p.KeyA != nil && len(p.KeyA) != 6 {
+		return fmt.Errorf("keyA must be 6 bytes, got %d", len(p.KeyA))
+	}
+	if p.KeyB != nil && len(p.KeyB) != 6 {
+		return fmt.Errorf("keyB must be 6 bytes, got %d", len(p.KeyB))
+	}
+	if p.Password != nil && len(p.Password) != 4 {
+		return fmt.Errorf("password must be 4 bytes, got %d", len(p.Password))
+	}
+	return nil
+}
+
+// SetKeyProfile encrypts p and stores it under name, replacing any existing
+// profile with that name.
+func SetKeyProfile(name string, p KeyProfile) error {
+	if err := ValidateKeyProfile(name, p); err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealKeyProfile(name, plain)
+	if err != nil {
+		return err
+	}
+
+	Get() // Make sure settings are loaded before changing them
+	mu.Lock()
+	if current.KeyProfiles == nil {
+		current.KeyProfiles = make(map[string]string)
+	}
+	current.KeyProfiles[name] = sealed
+	mu.Unlock()
+
+	return Save()
+}
+
+// GetKeyProfile decrypts the profile stored under name.
+func GetKeyProfile(name string) (KeyProfile, error) {
+	Get()
+	mu.RLock()
+	sealed, ok := current.KeyProfiles[name]
+	mu.RUnlock()
+	if !ok {
+		return KeyProfile{}, fmt.Errorf("%w: %s", ErrKeyProfileNotFound, name)
+	}
+
+	plain, err := openKeyProfile(name, sealed)
+	if err != nil {
+		return KeyProfile{}, err
+	}
+	var p KeyProfile
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return KeyProfile{}, fmt.Errorf("failed to decode key profile %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// DeleteKeyProfile removes the profile stored under name.
+func DeleteKeyProfile(name string) error {
+	Get()
+	mu.Lock()
+	if _, ok := current.KeyProfiles[name]; !ok {
+		mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrKeyProfileNotFound, name)
+	}
+	delete(current.KeyProfiles, name)
+	mu.Unlock()
+
+	return Save()
+}
+
+// KeyProfileNames returns the names of all stored key profiles, sorted.
+func KeyProfileNames() []string {
+	Get()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(current.KeyProfiles))
+	for name := range current.KeyProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetUltralightPasswordProfile selects the key profile whose password is used
+// to retry protected Ultralight EV1 and NTAG reads. An empty name clears it.
+func SetUltralightPasswordProfile(name string) error {
+	if name != "" {
+		p, err := GetKeyProfile(name)
+		if err != nil {
+			return err
+		}
+		if p.Password == nil {
+			return fmt.Errorf("key profile %s has no password", name)
+		}
+	}
+
+	Get()
+	mu.Lock()
+	current.UltralightPasswordProfile = name
+	mu.Unlock()
+
+	return Save()
+}
+
+// UltralightPassword returns the password of the selected Ultralight password
+// profile, or nil if none is selected or it can't be decrypted.
+func UltralightPassword() []byte {
+	name := Get().UltralightPasswordProfile
+	if name == "" {
+		return nil
+	}
+	p, err := GetKeyProfile(name)
+	if err != nil {
+		return nil
+	}
+	return p.Password
+}
+
+// APIToken returns the token that protects sensitive API endpoints such as
+// key profile management. It is generated on first use and stored in the
+// config directory, so only local users with access to that file can read it.
+func APIToken() (string, error) {
+	token, err := loadOrCreateSecret(apiTokenFile, 16)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// sealKeyProfile encrypts a profile with AES-256-GCM. The profile name is
+// bound as additional data so sealed profiles can't be swapped between names.
+func sealKeyProfile(name string, plain []byte) (string, error) {
+	gcm, err := keyProfileCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openKeyProfile decrypts a profile sealed by sealKeyProfile.
+func openKeyProfile(name, sealed string) ([]byte, error) {
+	gcm, err := keyProfileCipher()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("key profile %s is corrupted", name)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key profile %s: %w", name, err)
+	}
+	return plain, nil
+}
+
+// keyProfileCipher returns the AES-GCM cipher for key profiles. Its key is
+// derived from the random secret in keys.secret, which is kept out of
+// settings.json so a copy of the settings alone doesn't reveal any keys.
+func keyProfileCipher() (cipher.AEAD, error) {
+	secret, err := loadOrCreateSecret(keySecretFile, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key profile secret: %w", err)
+	}
+	key := sha256.Sum256(append([]byte("nfc-agent key profiles\x00"), secret...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadOrCreateSecret reads a hex-encoded secret of size bytes from the config
+// directory, generating and writing one (mode 0600) if it doesn't exist yet.
+func loadOrCreateSecret(name string, size int) ([]byte, error) {
+	settingsPath, err := getSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(filepath.Dir(settingsPath), name)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if data, err := os.ReadFile(path); err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) != size {
+			return nil, fmt.Errorf("invalid secret in %s", path)
+		}
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,177 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempConfigDir points settings and secrets at a fresh temp directory.
+func useTempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	origDir := userConfigDir
+	userConfigDir = func() (string, error) { return dir, nil }
+
+	mu.Lock()
+	oldCurrent := current
+	current = DefaultSettings()
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		userConfigDir = origDir
+		mu.Lock()
+		current = oldCurrent
+		mu.Unlock()
+	})
+	return dir
+}
+
+func TestKeyProfileRoundTrip(t *testing.T) {
+	dir := useTempConfigDir(t)
+
+	profile := KeyProfile{
+		KeyA: []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5},
+		KeyB: []byte{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5},
+	}
+	if err := SetKeyProfile("transit", profile); err != nil {
+		t.Fatalf("SetKeyProfile failed: %v", err)
+	}
+
+	got, err := GetKeyProfile("transit")
+	if err != nil {
+		t.Fatalf("GetKeyProfile failed: %v", err)
+	}
+	if !bytes.Equal(got.KeyA, profile.KeyA) || !bytes.Equal(got.KeyB, profile.KeyB) {
+		t.Errorf("GetKeyProfile() = %+v, want %+v", got, profile)
+	}
+
+	// Keys must not appear in the settings file
+	data, err := os.ReadFile(filepath.Join(dir, "nfc-agent", "settings.json"))
+	if err != nil {
+		t.Fatalf("failed to read settings: %v", err)
+	}
+	if strings.Contains(strings.ToLower(string(data)), "a0a1a2a3a4a5") || bytes.Contains(data, []byte("oKGio6Sl")) {
+		t.Error("settings file contains the plaintext key")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "nfc-agent", keySecretFile))
+	if err != nil {
+		t.Fatalf("secret file missing: %v", err)
+	}
+	if info.Mode().Perm()&0077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("secret file mode = %v, want owner-only", info.Mode().Perm())
+	}
+
+	if names := KeyProfileNames(); len(names) != 1 || names[0] != "transit" {
+		t.Errorf("KeyProfileNames() = %v, want [transit]", names)
+	}
+
+	if err := DeleteKeyProfile("transit"); err != nil {
+		t.Fatalf("DeleteKeyProfile failed: %v", err)
+	}
+	if _, err := GetKeyProfile("transit"); !errors.Is(err, ErrKeyProfileNotFound) {
+		t.Errorf("expected ErrKeyProfileNotFound after delete, got %v", err)
+	}
+}
+
+func TestKeyProfileBoundToName(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := SetKeyProfile("a", KeyProfile{KeyA: make([]byte, 6)}); err != nil {
+		t.Fatalf("SetKeyProfile failed: %v", err)
+	}
+
+	// Move the sealed profile to another name; it must not decrypt there
+	mu.Lock()
+	current.KeyProfiles["b"] = current.KeyProfiles["a"]
+	mu.Unlock()
+
+	if _, err := GetKeyProfile("b"); err == nil {
+		t.Error("expected decryption failure for a profile moved to another name")
+	}
+}
+
+func TestValidateKeyProfile(t *testing.T) {
+	key := make([]byte, 6)
+	tests := []struct {
+		name    string
+		profile string
+		p       KeyProfile
+		wantErr bool
+	}{
+		{"key A only", "transit", KeyProfile{KeyA: key}, false},
+		{"key B only", "door_2", KeyProfile{KeyB: key}, false},
+		{"no keys", "transit", KeyProfile{}, true},
+		{"short key", "transit", KeyProfile{KeyA: key[:4]}, true},
+		{"password only", "spools", KeyProfile{Password: key[:4]}, false},
+		{"short password", "spools", KeyProfile{Password: key[:3]}, true},
+		{"empty name", "", KeyProfile{KeyA: key}, true},
+		{"name with slash", "a/b", KeyProfile{KeyA: key}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateKeyProfile(tt.profile, tt.p)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKeyProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUltralightPasswordProfile(t *testing.T) {
+	useTempConfigDir(t)
+
+	if got := UltralightPassword(); got != nil {
+		t.Errorf("UltralightPassword() = %x, want nil when unset", got)
+	}
+
+	if err := SetKeyProfile("mifare", KeyProfile{KeyA: make([]byte, 6)}); err != nil {
+		t.Fatalf("SetKeyProfile failed: %v", err)
+	}
+	if err := SetUltralightPasswordProfile("mifare"); err == nil {
+		t.Error("expected error selecting a profile without a password")
+	}
+	if err := SetUltralightPasswordProfile("missing"); !errors.Is(err, ErrKeyProfileNotFound) {
+		t.Errorf("expected ErrKeyProfileNotFound, got %v", err)
+	}
+
+	password := []byte{0x12, 0x34, 0x56, 0x78}
+	if err := SetKeyProfile("spools", KeyProfile{Password: password}); err != nil {
+		t.Fatalf("SetKeyProfile failed: %v", err)
+	}
+	if err := SetUltralightPasswordProfile("spools"); err != nil {
+		t.Fatalf("SetUltralightPasswordProfile failed: %v", err)
+	}
+	if got := UltralightPassword(); !bytes.Equal(got, password) {
+		t.Errorf("UltralightPassword() = %x, want %x", got, password)
+	}
+
+	if err := SetUltralightPasswordProfile(""); err != nil {
+		t.Fatalf("clearing failed: %v", err)
+	}
+	if got := UltralightPassword(); got != nil {
+		t.Errorf("UltralightPassword() = %x, want nil after clearing", got)
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	useTempConfigDir(t)
+
+	token, err := APIToken()
+	if err != nil {
+		t.Fatalf("APIToken failed: %v", err)
+	}
+	if len(token) != 32 {
+		t.Errorf("expected 32 hex chars, got %q", token)
+	}
+
+	again, err := APIToken()
+	if err != nil || again != token {
+		t.Errorf("APIToken should be stable, got %q (%v), want %q", again, err, token)
+	}
+}
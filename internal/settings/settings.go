@@ -9,7 +9,12 @@ import (
 
 // Settings holds user preferences that persist across restarts.
 type Settings struct {
-	CrashReporting bool `json:"crashReporting"` // Whether to send crash reports to Sentry
+	CrashReporting        bool              `json:"crashReporting"`                  // Whether to send crash reports to Sentry
+	DisableCrashScrubbing bool              `json:"disableCrashScrubbing,omitempty"` // Keep card data in crash logs and Sentry events (scrubbed by default)
+	ScrubSensitive        bool              `json:"scrubSensitive,omitempty"`        // Also scrub card data (UIDs, APDU hex) from the in-app log
+	KeyProfiles           map[string]string `json:"keyProfiles,omitempty"`           // Encrypted MIFARE key profiles by name, see SetKeyProfile
+
+	UltralightPasswordProfile string `json:"ultralightPasswordProfile,omitempty"` // Key profile whose password retries protected Ultralight reads
 }
 
 var (
@@ -25,9 +30,12 @@ func DefaultSettings() *Settings {
 	}
 }
 
+// userConfigDir returns the base config directory. Overridden in tests.
+var userConfigDir = os.UserConfigDir
+
 // getSettingsPath returns the path to the settings file.
 func getSettingsPath() (string, error) {
-	configDir, err := os.UserConfigDir()
+	configDir, err := userConfigDir()
 	if err != nil {
 		return "", err
 	}
@@ -120,6 +128,19 @@ func SetCrashReporting(enabled bool) error {
 	return Save()
 }
 
+// SetScrubbing updates the crash report and log scrubbing preferences and saves.
+func SetScrubbing(crashReports, sensitive bool) error {
+	mu.Lock()
+	if current == nil {
+		current = DefaultSettings()
+	}
+	current.DisableCrashScrubbing = !crashReports
+	current.ScrubSensitive = sensitive
+	mu.Unlock()
+
+	return Save()
+}
+
 // IsCrashReportingEnabled returns whether crash reporting is enabled.
 func IsCrashReportingEnabled() bool {
 	return Get().CrashReporting
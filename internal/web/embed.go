@@ -2,15 +2,22 @@ package web
 
 import (
 	"embed"
+	"errors"
 	"io/fs"
 	"net/http"
+	"os"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
-// Handler returns an HTTP handler that serves the embedded static files.
-// The status page is served at the root path.
+// RootOverride is a directory whose files are served instead of the embedded
+// ones; paths it doesn't contain fall back to the embedded UI. Empty serves
+// the embedded UI only.
+var RootOverride string
+
+// Handler returns an HTTP handler that serves the embedded static files,
+// overlaid with RootOverride if set. The status page is served at the root path.
 func Handler() http.Handler {
 	// Get the static subdirectory
 	fsys, err := fs.Sub(staticFiles, "static")
@@ -18,6 +25,10 @@ func Handler() http.Handler {
 		panic(err)
 	}
 
+	if RootOverride != "" {
+		fsys = overlayFS{top: os.DirFS(RootOverride), base: fsys}
+	}
+
 	return http.FileServer(http.FS(fsys))
 }
 
@@ -28,3 +39,17 @@ func HandlerFunc() http.HandlerFunc {
 		handler.ServeHTTP(w, r)
 	}
 }
+
+// overlayFS opens files from top, falling back to base for missing paths.
+type overlayFS struct {
+	top  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	return o.base.Open(name)
+}
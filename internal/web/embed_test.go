@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestHandler_Embedded(t *testing.T) {
+	w := get(t, Handler(), "/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	embedded, err := staticFiles.ReadFile("static/index.html")
+	if err != nil {
+		t.Fatalf("failed to read embedded index: %v", err)
+	}
+	if w.Body.String() != string(embedded) {
+		t.Error("expected the embedded index.html")
+	}
+}
+
+func TestHandler_RootOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>Kiosk</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := RootOverride
+	RootOverride = dir
+	t.Cleanup(func() { RootOverride = orig })
+
+	h := Handler()
+
+	if w := get(t, h, "/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Kiosk") {
+		t.Errorf("expected override index, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Files missing from the override come from the embedded UI
+	if w := get(t, h, "/test.html"); w.Code != http.StatusOK {
+		t.Errorf("expected embedded fallback for test.html, got %d", w.Code)
+	}
+
+	if w := get(t, h, "/missing.html"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing file, got %d", w.Code)
+	}
+}
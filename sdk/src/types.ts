@@ -23,6 +23,10 @@ export interface Card {
   uid: string;
   /** Answer To Reset (hex encoded) */
   atr?: string;
+  /** ISO 14443-A ATQA (hex encoded), when the reader provides it */
+  atqa?: string;
+  /** ISO 14443-A SAK (hex encoded), when the reader provides it */
+  sak?: string;
   /** Card type (e.g., "NTAG213", "NTAG215", "MIFARE Classic", "ICode SLIX") */
   type?: string;
   /** Short protocol name (e.g., "NFC-A", "NFC-V") */
@@ -37,6 +41,32 @@ export interface Card {
   data?: string;
   /** Type of data stored */
   dataType?: CardDataType;
+  /** All NDEF records found on the card */
+  records?: ParsedRecord[];
+  /** Pages/blocks read while looking for NDEF data (debug) */
+  pagesRead?: number;
+  /** Set when the NDEF message structure is invalid (MB/ME flags, truncation) */
+  ndefMalformed?: boolean;
+  /** First structural problem found in the NDEF message */
+  ndefMalformedReason?: string;
+}
+
+/**
+ * A single decoded NDEF record
+ */
+export interface ParsedRecord {
+  /** Type Name Format (1 = well-known, 2 = MIME, ...) */
+  tnf: number;
+  /** Record type (e.g., "T", "U", or a MIME type) */
+  type: string;
+  /** Decoded payload */
+  data?: string;
+  /** Type of the decoded payload */
+  dataType?: string;
+  /** Language code (text records only) */
+  lang?: string;
+  /** Hex of the complete raw record (only when requested with includeRaw) */
+  raw?: string;
 }
 
 /**
@@ -235,8 +265,8 @@ export interface RemovePasswordPayload {
  * Single NDEF record for write_records
  */
 export interface NDEFRecord {
-  type: 'text' | 'url' | 'json' | 'binary' | 'mime';
-  data: string;
+  type: 'text' | 'url' | 'json' | 'binary' | 'mime' | 'openprinttag';
+  data: string; // For 'openprinttag', JSON with materialName, brandName, etc.
   mimeType?: string; // For 'mime' type
 }
 